@@ -17,6 +17,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
@@ -41,8 +42,14 @@ var (
 	}
 
 	startOpts struct {
-		kubeconfig string
-		imagesFile string
+		kubeconfig          string
+		imagesFile          string
+		controllersReplicas int32
+		manageWebhookCerts  bool
+		// webhookFailurePolicies are the failure policies of the machine API webhooks by platform name.
+		webhookFailurePolicies map[string]string
+		// machineControllerConcurrency is passed on to the machine controller.
+		machineControllerConcurrency util.ConcurrencyOptions
 	}
 )
 
@@ -50,6 +57,13 @@ func init() {
 	rootCmd.AddCommand(startCmd)
 	startCmd.PersistentFlags().StringVar(&startOpts.kubeconfig, "kubeconfig", "", "Kubeconfig file to access a remote cluster (testing only)")
 	startCmd.PersistentFlags().StringVar(&startOpts.imagesFile, "images-json", "", "images.json file for MAO.")
+	startCmd.PersistentFlags().Int32Var(&startOpts.controllersReplicas, "controllers-replicas", 0, "Number of machine-api-controllers replicas, which serve the machine API webhooks. Defaults to 1 on single node control planes and 2 otherwise.")
+	startCmd.PersistentFlags().BoolVar(&startOpts.manageWebhookCerts, "manage-webhook-certs", false, "Generate and rotate the serving certificate of the machine API webhooks, and inject its CA bundle in the webhook configurations, instead of relying on the service CA operator. Only use it where the service CA operator does not run.")
+
+	startCmd.PersistentFlags().IntVar(&startOpts.machineControllerConcurrency.MaxConcurrentReconciles, "machine-controller-max-concurrent-reconciles", 0, "Number of machines the machine controller reconciles in parallel. Only passed on to the machine controllers built from this repository, which default to 1.")
+	startCmd.PersistentFlags().IntVar(&startOpts.machineControllerConcurrency.MaxConcurrentDrainsPerZone, "machine-controller-max-concurrent-drains-per-zone", 0, "Maximum number of machines of an availability zone the machine controller drains at the same time. Only passed on to the machine controllers built from this repository, which default to no limit.")
+
+	startCmd.PersistentFlags().StringToStringVar(&startOpts.webhookFailurePolicies, "webhook-failure-policy", nil, "Failure policy of the machine API webhooks by platform, eg. AWS=Fail,VSphere=Ignore. Fail rejects machine API requests while the webhooks are unavailable, and is only honored with several machine-api-controllers replicas. Defaults to Ignore.")
 
 	klog.InitFlags(nil)
 	flag.Parse()
@@ -66,6 +80,14 @@ func runStartCmd(cmd *cobra.Command, args []string) {
 		klog.Fatalf("--images-json should not be empty")
 	}
 
+	webhookFailurePolicies, err := operator.ParseWebhookFailurePolicies(startOpts.webhookFailurePolicies)
+	if err != nil {
+		klog.Fatalf("--webhook-failure-policy is invalid: %v", err)
+	}
+	if err := startOpts.machineControllerConcurrency.Validate(); err != nil {
+		klog.Fatalf("machine controller concurrency is invalid: %v", err)
+	}
+
 	cb, err := NewClientBuilder(startOpts.kubeconfig)
 	if err != nil {
 		klog.Fatalf("error creating clients: %v", err)
@@ -80,7 +102,7 @@ func runStartCmd(cmd *cobra.Command, args []string) {
 		Callbacks: leaderelection.LeaderCallbacks{
 			OnStartedLeading: func(ctx context.Context) {
 				ctrlCtx := CreateControllerContext(cb, stopCh, componentNamespace)
-				startControllers(ctrlCtx)
+				startControllers(ctrlCtx, webhookFailurePolicies)
 				ctrlCtx.KubeNamespacedInformerFactory.Start(ctrlCtx.Stop)
 				ctrlCtx.ConfigInformerFactory.Start(ctrlCtx.Stop)
 				initMachineAPIInformers(ctrlCtx)
@@ -118,19 +140,24 @@ func initRecorder(kubeClient kubernetes.Interface) record.EventRecorder {
 	return eventBroadcaster.NewRecorder(eventRecorderScheme, v1.EventSource{Component: "machineapioperator"})
 }
 
-func startControllers(ctx *ControllerContext) {
+func startControllers(ctx *ControllerContext, webhookFailurePolicies map[osconfigv1.PlatformType]admissionregistrationv1.FailurePolicyType) {
 	kubeClient := ctx.ClientBuilder.KubeClientOrDie(componentName)
 	recorder := initRecorder(kubeClient)
 	go operator.New(
 		componentNamespace, componentName,
 		startOpts.imagesFile,
 		config,
+		startOpts.controllersReplicas,
+		startOpts.manageWebhookCerts,
+		webhookFailurePolicies,
+		startOpts.machineControllerConcurrency,
 		ctx.KubeNamespacedInformerFactory.Apps().V1().Deployments(),
 		ctx.KubeNamespacedInformerFactory.Apps().V1().DaemonSets(),
 		ctx.ConfigInformerFactory.Config().V1().FeatureGates(),
 		ctx.KubeNamespacedInformerFactory.Admissionregistration().V1().ValidatingWebhookConfigurations(),
 		ctx.KubeNamespacedInformerFactory.Admissionregistration().V1().MutatingWebhookConfigurations(),
 		ctx.ConfigInformerFactory.Config().V1().Proxies(),
+		ctx.KubeNamespacedInformerFactory.Core().V1().ConfigMaps(),
 		ctx.ClientBuilder.KubeClientOrDie(componentName),
 		ctx.ClientBuilder.OpenshiftClientOrDie(componentName),
 		ctx.ClientBuilder.DynamicClientOrDie(componentName),
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	osclientset "github.com/openshift/client-go/config/clientset/versioned"
+	"github.com/openshift/machine-api-operator/pkg/webhooks"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+var (
+	webhookCheckCmd = &cobra.Command{
+		Use:   "webhook-check",
+		Short: "Checks that the machine webhooks are admitting Machines",
+		Long:  "Submits a minimal Machine for the cluster platform as a server-side dry-run and reports the webhook response, its latency and the webhook CA bundle expiry. Nothing is persisted.",
+		Run:   runWebhookCheckCmd,
+	}
+
+	webhookCheckOpts struct {
+		kubeconfig string
+		output     string
+		timeout    time.Duration
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(webhookCheckCmd)
+	webhookCheckCmd.PersistentFlags().StringVar(&webhookCheckOpts.kubeconfig, "kubeconfig", "", "Kubeconfig file to access a remote cluster (testing only)")
+	webhookCheckCmd.PersistentFlags().StringVarP(&webhookCheckOpts.output, "output", "o", "text", "Output format, one of: text, json")
+	webhookCheckCmd.PersistentFlags().DurationVar(&webhookCheckOpts.timeout, "timeout", 30*time.Second, "Timeout for the whole check")
+}
+
+func runWebhookCheckCmd(cmd *cobra.Command, args []string) {
+	flag.Set("logtostderr", "true")
+	flag.Parse()
+
+	if webhookCheckOpts.output != "text" && webhookCheckOpts.output != "json" {
+		klog.Exitf("--output must be one of: text, json")
+	}
+
+	config, err := getRestConfig(webhookCheckOpts.kubeconfig)
+	if err != nil {
+		klog.Exitf("Error creating client config: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookCheckOpts.timeout)
+	defer cancel()
+
+	osClient, err := osclientset.NewForConfig(config)
+	if err != nil {
+		klog.Exitf("Error creating client: %v", err)
+	}
+	infra, err := osClient.ConfigV1().Infrastructures().Get(ctx, "cluster", metav1.GetOptions{})
+	if err != nil {
+		klog.Exitf("Error getting Infrastructure: %v", err)
+	}
+
+	result, err := webhooks.RunSelfCheck(ctx, config, componentNamespace, infrastructurePlatform(infra))
+	if err != nil {
+		klog.Exitf("Error checking webhooks: %v", err)
+	}
+
+	if webhookCheckOpts.output == "json" {
+		out, err := result.JSON()
+		if err != nil {
+			klog.Exitf("Error encoding result: %v", err)
+		}
+		fmt.Println(out)
+		return
+	}
+	fmt.Println(result.String())
+}
+
+// infrastructurePlatform returns the platform of the cluster. Clusters installed before the platform
+// status was introduced only set the deprecated platform.
+func infrastructurePlatform(infra *osconfigv1.Infrastructure) osconfigv1.PlatformType {
+	if infra.Status.PlatformStatus != nil && infra.Status.PlatformStatus.Type != "" {
+		return infra.Status.PlatformStatus.Type
+	}
+	return infra.Status.Platform
+}
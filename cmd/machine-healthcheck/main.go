@@ -45,26 +45,13 @@ func main() {
 		"The address for health checking.",
 	)
 
-	leaderElectResourceNamespace := flag.String(
-		"leader-elect-resource-namespace",
-		"",
-		"The namespace of resource object that is used for locking during leader election. If unspecified and running in cluster, defaults to the service account namespace for the controller. Required for leader-election outside of a cluster.",
-	)
-
-	leaderElect := flag.Bool(
-		"leader-elect",
-		false,
-		"Start a leader election client and gain leadership before executing the main loop. Enable this when running replicated components for high availability.",
-	)
-
-	leaderElectLeaseDuration := flag.Duration(
-		"leader-elect-lease-duration",
-		util.LeaseDuration,
-		"The duration that non-leader candidates will wait after observing a leadership renewal until attempting to acquire leadership of a led but unrenewed leader slot. This is effectively the maximum duration that a leader can be stopped before it is replaced by another candidate. This is only applicable if leader election is enabled.",
-	)
+	leaderElection := util.AddLeaderElectionFlags(flag.CommandLine)
 
 	klog.InitFlags(nil)
 	flag.Parse()
+	if err := leaderElection.Validate(); err != nil {
+		klog.Fatal(err)
+	}
 	printVersion()
 
 	// Get a config to talk to the apiserver
@@ -74,15 +61,10 @@ func main() {
 	}
 
 	opts := manager.Options{
-		MetricsBindAddress:      *metricsAddress,
-		HealthProbeBindAddress:  *healthAddr,
-		LeaderElection:          *leaderElect,
-		LeaderElectionNamespace: *leaderElectResourceNamespace,
-		LeaderElectionID:        "cluster-api-provider-healthcheck-leader",
-		LeaseDuration:           leaderElectLeaseDuration,
-		RetryPeriod:             util.TimeDuration(util.RetryPeriod),
-		RenewDeadline:           util.TimeDuration(util.RenewDeadline),
+		MetricsBindAddress:     *metricsAddress,
+		HealthProbeBindAddress: *healthAddr,
 	}
+	leaderElection.ApplyTo(&opts, "cluster-api-provider-healthcheck-leader")
 
 	if *watchNamespace != "" {
 		opts.Namespace = *watchNamespace
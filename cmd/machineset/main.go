@@ -19,10 +19,16 @@ package main
 import (
 	"flag"
 	"log"
+	"strings"
 	"time"
 
+	osconfigv1 "github.com/openshift/api/config/v1"
 	machinev1 "github.com/openshift/api/machine/v1beta1"
 	"github.com/openshift/machine-api-operator/pkg/controller"
+	"github.com/openshift/machine-api-operator/pkg/controller/azurevmskus"
+	"github.com/openshift/machine-api-operator/pkg/controller/bootimage"
+	"github.com/openshift/machine-api-operator/pkg/controller/machinerollout"
+	"github.com/openshift/machine-api-operator/pkg/controller/machinerotation"
 	"github.com/openshift/machine-api-operator/pkg/controller/machineset"
 	"github.com/openshift/machine-api-operator/pkg/metrics"
 	"github.com/openshift/machine-api-operator/pkg/util"
@@ -57,31 +63,36 @@ func main() {
 	webhookCertdir := flag.String("webhook-cert-dir", defaultWebhookCertdir,
 		"Webhook cert dir, only used when webhook-enabled is true.")
 
+	tlsMinVersion := flag.String("tls-min-version", mapiwebhooks.DefaultTLSMinVersion,
+		"Minimum TLS version accepted by the webhook server, one of VersionTLS10, VersionTLS11, VersionTLS12 or VersionTLS13. Only used when webhook-enabled is true.")
+
+	tlsCipherSuites := flag.String("tls-cipher-suites", strings.Join(mapiwebhooks.DefaultTLSCipherSuites, ","),
+		"Comma separated list of IANA cipher suite names accepted by the webhook server for TLS 1.2 and below. Only used when webhook-enabled is true.")
+
+	validationMode := flag.String("validation-mode", string(mapiwebhooks.ValidationModeEnforce),
+		"Validating webhook mode, one of enforce or audit. In audit mode invalid resources are admitted with the validation errors returned as warnings. Overridden by the validationMode key of the machine-api-webhook-policy ConfigMap.")
+
+	hybridPlatformValidation := flag.Bool("hybrid-platform-validation", false,
+		"Validate machines against the rules of the platform named by their providerSpec kind rather than the cluster platform. For clusters hosting machines of other platforms.")
+
+	trustedCAFile := flag.String("trusted-ca-file", "",
+		"Path to a PEM bundle of CA certificates trusted by the webhooks for outbound HTTPS requests, in addition to the system roots. The file is reloaded when it changes.")
+
+	maxMachineAge := flag.String("max-machine-age", "",
+		"Maximum age of the machines of MachineSets, e.g. 720h or 30d, after which they are annotated as due for rotation. Overridden by the machine.openshift.io/max-age annotation of a MachineSet. Unset disables rotation.")
+
 	healthAddr := flag.String(
 		"health-addr",
 		":9441",
 		"The address for health checking.",
 	)
 
-	leaderElectResourceNamespace := flag.String(
-		"leader-elect-resource-namespace",
-		"",
-		"The namespace of resource object that is used for locking during leader election. If unspecified and running in cluster, defaults to the service account namespace for the controller. Required for leader-election outside of a cluster.",
-	)
-
-	leaderElect := flag.Bool(
-		"leader-elect",
-		false,
-		"Start a leader election client and gain leadership before executing the main loop. Enable this when running replicated components for high availability.",
-	)
-
-	leaderElectLeaseDuration := flag.Duration(
-		"leader-elect-lease-duration",
-		util.LeaseDuration,
-		"The duration that non-leader candidates will wait after observing a leadership renewal until attempting to acquire leadership of a led but unrenewed leader slot. This is effectively the maximum duration that a leader can be stopped before it is replaced by another candidate. This is only applicable if leader election is enabled.",
-	)
+	leaderElection := util.AddLeaderElectionFlags(flag.CommandLine)
 
 	flag.Parse()
+	if err := leaderElection.Validate(); err != nil {
+		log.Fatal(err)
+	}
 	if *watchNamespace != "" {
 		log.Printf("Watching cluster-api objects only in namespace %q for reconciliation.", *watchNamespace)
 	}
@@ -96,30 +107,30 @@ func main() {
 	// Create a new Cmd to provide shared dependencies and start components
 	syncPeriod := 10 * time.Minute
 	opts := manager.Options{
-		MetricsBindAddress:      *metricsAddress,
-		SyncPeriod:              &syncPeriod,
-		Namespace:               *watchNamespace,
-		HealthProbeBindAddress:  *healthAddr,
-		LeaderElection:          *leaderElect,
-		LeaderElectionNamespace: *leaderElectResourceNamespace,
-		LeaderElectionID:        "cluster-api-provider-machineset-leader",
-		LeaseDuration:           leaderElectLeaseDuration,
-		RetryPeriod:             util.TimeDuration(util.RetryPeriod),
-		RenewDeadline:           util.TimeDuration(util.RenewDeadline),
+		MetricsBindAddress:     *metricsAddress,
+		SyncPeriod:             &syncPeriod,
+		Namespace:              *watchNamespace,
+		HealthProbeBindAddress: *healthAddr,
 	}
+	leaderElection.ApplyTo(&opts, "cluster-api-provider-machineset-leader")
 
 	mgr, err := manager.New(cfg, opts)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	webhookValidationMode, err := mapiwebhooks.ParseValidationMode(*validationMode)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Enable defaulting and validating webhooks
 	machineDefaulter, err := mapiwebhooks.NewMachineDefaulter()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	machineValidator, err := mapiwebhooks.NewMachineValidator(mgr.GetClient())
+	machineValidator, err := mapiwebhooks.NewMachineValidator(mgr.GetClient(), webhookValidationMode, *hybridPlatformValidation)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -129,18 +140,57 @@ func main() {
 		log.Fatal(err)
 	}
 
-	machineSetValidator, err := mapiwebhooks.NewMachineSetValidator(mgr.GetClient())
+	machineSetValidator, err := mapiwebhooks.NewMachineSetValidator(mgr.GetClient(), webhookValidationMode, *hybridPlatformValidation)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	trustedCABundle, err := mapiwebhooks.NewTrustedCABundle(*trustedCAFile)
 	if err != nil {
 		log.Fatal(err)
 	}
+	httpClient := trustedCABundle.HTTPClient()
+	machineDefaulter.SetHTTPClient(httpClient)
+	machineValidator.SetHTTPClient(httpClient)
+	machineSetDefaulter.SetHTTPClient(httpClient)
+	machineSetValidator.SetHTTPClient(httpClient)
+
+	// The validators share the secrets cache, reading the secrets of other namespaces than the watched one
+	// from the API server.
+	secretsCache := mapiwebhooks.NewSecretsCache(mgr.GetCache(), *watchNamespace, mgr.GetAPIReader())
+	machineValidator.SetSecretsCache(secretsCache)
+	machineSetValidator.SetSecretsCache(secretsCache)
 
 	if *webhookEnabled {
-		mgr.GetWebhookServer().Port = *webhookPort
-		mgr.GetWebhookServer().CertDir = *webhookCertdir
-		mgr.GetWebhookServer().Register(mapiwebhooks.DefaultMachineMutatingHookPath, &webhook.Admission{Handler: machineDefaulter})
-		mgr.GetWebhookServer().Register(mapiwebhooks.DefaultMachineValidatingHookPath, &webhook.Admission{Handler: machineValidator})
-		mgr.GetWebhookServer().Register(mapiwebhooks.DefaultMachineSetMutatingHookPath, &webhook.Admission{Handler: machineSetDefaulter})
-		mgr.GetWebhookServer().Register(mapiwebhooks.DefaultMachineSetValidatingHookPath, &webhook.Admission{Handler: machineSetValidator})
+		metrics.InitializeWebhookMetrics()
+
+		if err := mgr.Add(trustedCABundle); err != nil {
+			log.Fatal(err)
+		}
+
+		webhookServer, err := mapiwebhooks.NewTLSServer(&webhook.Server{
+			Port:    *webhookPort,
+			CertDir: *webhookCertdir,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		if webhookServer.MinVersion, err = mapiwebhooks.ParseTLSVersion(*tlsMinVersion); err != nil {
+			log.Fatal(err)
+		}
+		if webhookServer.CipherSuites, err = mapiwebhooks.ParseTLSCipherSuites(strings.Split(*tlsCipherSuites, ",")); err != nil {
+			log.Fatal(err)
+		}
+
+		webhookServer.Register(mapiwebhooks.DefaultMachineMutatingHookPath, &webhook.Admission{Handler: machineDefaulter})
+		webhookServer.Register(mapiwebhooks.DefaultMachineValidatingHookPath, &webhook.Admission{Handler: machineValidator})
+		webhookServer.Register(mapiwebhooks.DefaultMachineSetMutatingHookPath, &webhook.Admission{Handler: machineSetDefaulter})
+		webhookServer.Register(mapiwebhooks.DefaultMachineSetValidatingHookPath, &webhook.Admission{Handler: machineSetValidator})
+		webhookServer.Register(mapiwebhooks.DefaultMachineHealthCheckValidatingHookPath, &webhook.Admission{Handler: mapiwebhooks.NewMachineHealthCheckValidator()})
+
+		if err := mgr.Add(webhookServer); err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	log.Printf("Registering Components.")
@@ -149,9 +199,26 @@ func main() {
 	if err := machinev1.AddToScheme(mgr.GetScheme()); err != nil {
 		log.Fatal(err)
 	}
+	if err := osconfigv1.AddToScheme(mgr.GetScheme()); err != nil {
+		log.Fatal(err)
+	}
 
 	// Setup all Controllers
-	if err := controller.AddToManager(mgr, opts, machineset.Add); err != nil {
+	if err := controller.AddToManager(mgr, opts, machineset.Add, machinerollout.Add, bootimage.Add); err != nil {
+		log.Fatal(err)
+	}
+
+	var machineAge time.Duration
+	if *maxMachineAge != "" {
+		if machineAge, err = machinerotation.ParseMaxAge(*maxMachineAge); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := machinerotation.AddWithMaxMachineAge(mgr, opts, machineAge); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := azurevmskus.Add(mgr, opts, httpClient); err != nil {
 		log.Fatal(err)
 	}
 
@@ -30,27 +30,14 @@ func main() {
 		"Namespace that the controller watches to reconcile machine-api objects. If unspecified, the controller watches for machine-api objects across all namespaces.",
 	)
 
-	leaderElectResourceNamespace := flag.String(
-		"leader-elect-resource-namespace",
-		"",
-		"The namespace of resource object that is used for locking during leader election. If unspecified and running in cluster, defaults to the service account namespace for the controller. Required for leader-election outside of a cluster.",
-	)
-
-	leaderElect := flag.Bool(
-		"leader-elect",
-		false,
-		"Start a leader election client and gain leadership before executing the main loop. Enable this when running replicated components for high availability.",
-	)
-
-	leaderElectLeaseDuration := flag.Duration(
-		"leader-elect-lease-duration",
-		util.LeaseDuration,
-		"The duration that non-leader candidates will wait after observing a leadership renewal until attempting to acquire leadership of a led but unrenewed leader slot. This is effectively the maximum duration that a leader can be stopped before it is replaced by another candidate. This is only applicable if leader election is enabled.",
-	)
+	leaderElection := util.AddLeaderElectionFlags(flag.CommandLine)
 
 	klog.InitFlags(nil)
 	flag.Set("logtostderr", "true")
 	flag.Parse()
+	if err := leaderElection.Validate(); err != nil {
+		klog.Fatal(err)
+	}
 
 	// Get a config to talk to the apiserver
 	cfg, err := config.GetConfig()
@@ -60,14 +47,9 @@ func main() {
 
 	opts := manager.Options{
 		// Disable metrics serving
-		MetricsBindAddress:      "0",
-		LeaderElection:          *leaderElect,
-		LeaderElectionNamespace: *leaderElectResourceNamespace,
-		LeaderElectionID:        "cluster-api-provider-nodelink-leader",
-		LeaseDuration:           leaderElectLeaseDuration,
-		RetryPeriod:             util.TimeDuration(util.RetryPeriod),
-		RenewDeadline:           util.TimeDuration(util.RenewDeadline),
+		MetricsBindAddress: "0",
 	}
+	leaderElection.ApplyTo(&opts, "cluster-api-provider-nodelink-leader")
 	if *watchNamespace != "" {
 		opts.Namespace = *watchNamespace
 		klog.Infof("Watching machine-api objects only in namespace %q for reconciliation.", opts.Namespace)
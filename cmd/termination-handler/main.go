@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"runtime"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/machine-api-operator/pkg/termination"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
+)
+
+func printVersion() {
+	klog.Infof("Go Version: %s", runtime.Version())
+	klog.Infof("Go OS/Arch: %s/%s", runtime.GOOS, runtime.GOARCH)
+}
+
+func main() {
+	printVersion()
+
+	nodeName := flag.String(
+		"node-name",
+		"",
+		"The name of the node the termination handler runs on.",
+	)
+
+	platform := flag.String(
+		"platform",
+		"",
+		"The platform of the instance, whose termination notice is watched. One of AWS, GCP or Azure.",
+	)
+
+	pollIntervalSeconds := flag.Int64(
+		"poll-interval-seconds",
+		5,
+		"The interval in seconds between polls of the termination notice.",
+	)
+
+	metadataEndpoint := flag.String(
+		"metadata-endpoint",
+		"",
+		"The endpoint of the instance metadata service. If unspecified, the metadata service of the platform is used.",
+	)
+
+	klog.InitFlags(nil)
+	flag.Set("logtostderr", "true")
+	flag.Parse()
+
+	if *nodeName == "" {
+		klog.Fatal("--node-name must be set")
+	}
+
+	watcher, err := termination.NewNoticeWatcher(configv1.PlatformType(*platform), *metadataEndpoint, nil)
+	if err != nil {
+		klog.Fatal(err)
+	}
+
+	// Get a config to talk to the apiserver
+	cfg, err := config.GetConfig()
+	if err != nil {
+		klog.Fatal(err)
+	}
+	c, err := client.New(cfg, client.Options{})
+	if err != nil {
+		klog.Fatal(err)
+	}
+
+	ctx := signals.SetupSignalHandler()
+
+	handler := termination.NewHandler(c, watcher, *nodeName, time.Duration(*pollIntervalSeconds)*time.Second)
+	if err := handler.Run(ctx); err != nil {
+		klog.Fatal(err)
+	}
+
+	// The node is marked for deletion, wait to be stopped rather than exiting,
+	// so that the DaemonSet does not restart the handler until the instance goes away.
+	<-ctx.Done()
+}
@@ -9,6 +9,7 @@ import (
 	configv1 "github.com/openshift/api/config/v1"
 	machinev1 "github.com/openshift/api/machine/v1beta1"
 	capimachine "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	"github.com/openshift/machine-api-operator/pkg/controller/orphanedinstance"
 	machine "github.com/openshift/machine-api-operator/pkg/controller/vsphere"
 	machinesetcontroller "github.com/openshift/machine-api-operator/pkg/controller/vsphere/machineset"
 	"github.com/openshift/machine-api-operator/pkg/metrics"
@@ -34,28 +35,62 @@ func main() {
 		"Namespace that the controller watches to reconcile machine-api objects. If unspecified, the controller watches for machine-api objects across all namespaces.",
 	)
 
-	leaderElectResourceNamespace := flag.String(
-		"leader-elect-resource-namespace",
-		"",
-		"The namespace of resource object that is used for locking during leader election. If unspecified and running in cluster, defaults to the service account namespace for the controller. Required for leader-election outside of a cluster.",
+	leaderElection := util.AddLeaderElectionFlags(flag.CommandLine)
+
+	metricsAddress := flag.String(
+		"metrics-bind-address",
+		metrics.DefaultMachineMetricsAddress,
+		"Address for hosting metrics",
 	)
 
-	leaderElect := flag.Bool(
-		"leader-elect",
-		false,
-		"Start a leader election client and gain leadership before executing the main loop. Enable this when running replicated components for high availability.",
+	concurrency := util.AddConcurrencyFlags(flag.CommandLine)
+
+	stuckProvisioningThreshold := flag.Duration(
+		"stuck-provisioning-threshold",
+		capimachine.DefaultStuckProvisioningThreshold,
+		"How long a machine may stay in the Provisioning phase before it is flagged as stuck. A negative threshold disables the check.",
 	)
 
-	leaderElectLeaseDuration := flag.Duration(
-		"leader-elect-lease-duration",
-		util.LeaseDuration,
-		"The duration that non-leader candidates will wait after observing a leadership renewal until attempting to acquire leadership of a led but unrenewed leader slot. This is effectively the maximum duration that a leader can be stopped before it is replaced by another candidate. This is only applicable if leader election is enabled.",
+	stuckDeletingThreshold := flag.Duration(
+		"stuck-deleting-threshold",
+		capimachine.DefaultStuckDeletingThreshold,
+		"How long a machine may stay in the Deleting phase before it is flagged as stuck. A negative threshold disables the check.",
 	)
 
-	metricsAddress := flag.String(
-		"metrics-bind-address",
-		metrics.DefaultMachineMetricsAddress,
-		"Address for hosting metrics",
+	backoffBaseDelay := flag.Duration(
+		"backoff-base-delay",
+		capimachine.DefaultBackoffBaseDelay,
+		"How long a machine is requeued after a first failure of the cloud provider, doubling with every consecutive failure.",
+	)
+
+	backoffMaxDelay := flag.Duration(
+		"backoff-max-delay",
+		capimachine.DefaultBackoffMaxDelay,
+		"The maximum delay before a machine whose cloud provider calls keep failing is requeued. Terminal errors wait for it right away.",
+	)
+
+	backoffJitter := flag.Float64(
+		"backoff-jitter",
+		capimachine.DefaultBackoffJitter,
+		"The maximum fraction of the backoff delay added to spread the retries of machines. A negative jitter disables it.",
+	)
+
+	orphanedInstancesInterval := flag.Duration(
+		"orphaned-instances-interval",
+		orphanedinstance.DefaultInterval,
+		"How often the VMs tagged with the cluster ID are compared against the machines to report the ones without a machine. Zero disables it.",
+	)
+
+	orphanedInstancesGracePeriod := flag.Duration(
+		"orphaned-instances-grace-period",
+		orphanedinstance.DefaultGracePeriod,
+		"How long a VM stays without a machine before it is deleted, when deleting orphaned instances is enabled.",
+	)
+
+	deleteOrphanedInstances := flag.Bool(
+		"delete-orphaned-instances",
+		false,
+		"Delete the VMs tagged with the cluster ID which stayed without a machine for the grace period, instead of only reporting them.",
 	)
 
 	flag.Set("logtostderr", "true")
@@ -65,6 +100,12 @@ func main() {
 		"The address for health checking.",
 	)
 	flag.Parse()
+	if err := leaderElection.Validate(); err != nil {
+		klog.Fatal(err)
+	}
+	if err := concurrency.Validate(); err != nil {
+		klog.Fatal(err)
+	}
 
 	if printVersion {
 		fmt.Println(version.String)
@@ -75,16 +116,11 @@ func main() {
 	syncPeriod := 10 * time.Minute
 
 	opts := manager.Options{
-		MetricsBindAddress:      *metricsAddress,
-		HealthProbeBindAddress:  *healthAddr,
-		SyncPeriod:              &syncPeriod,
-		LeaderElection:          *leaderElect,
-		LeaderElectionNamespace: *leaderElectResourceNamespace,
-		LeaderElectionID:        "cluster-api-provider-vsphere-leader",
-		LeaseDuration:           leaderElectLeaseDuration,
-		RetryPeriod:             util.TimeDuration(util.RetryPeriod),
-		RenewDeadline:           util.TimeDuration(util.RenewDeadline),
+		MetricsBindAddress:     *metricsAddress,
+		HealthProbeBindAddress: *healthAddr,
+		SyncPeriod:             &syncPeriod,
 	}
+	leaderElection.ApplyTo(&opts, "cluster-api-provider-vsphere-leader")
 
 	if *watchNamespace != "" {
 		opts.Namespace = *watchNamespace
@@ -121,7 +157,27 @@ func main() {
 		klog.Fatal(err)
 	}
 
-	capimachine.AddWithActuator(mgr, machineActuator)
+	capimachine.AddWithActuatorOpts(mgr, machineActuator, capimachine.Options{
+		MaxConcurrentReconciles:    concurrency.MaxConcurrentReconciles,
+		MaxConcurrentDrainsPerZone: concurrency.MaxConcurrentDrainsPerZone,
+		StuckProvisioningThreshold: *stuckProvisioningThreshold,
+		StuckDeletingThreshold:     *stuckDeletingThreshold,
+		BackoffBaseDelay:           *backoffBaseDelay,
+		BackoffMaxDelay:            *backoffMaxDelay,
+		BackoffJitter:              *backoffJitter,
+	})
+
+	if *orphanedInstancesInterval > 0 {
+		instanceProvider := machine.NewInstanceProvider(mgr.GetClient(), mgr.GetAPIReader())
+		if err := orphanedinstance.Add(mgr, instanceProvider, orphanedinstance.Options{
+			Provider:    "vsphere",
+			Interval:    *orphanedInstancesInterval,
+			GracePeriod: *orphanedInstancesGracePeriod,
+			Delete:      *deleteOrphanedInstances,
+		}); err != nil {
+			klog.Fatal(err)
+		}
+	}
 
 	ctrl.SetLogger(klogr.New())
 	setupLog := ctrl.Log.WithName("setup")
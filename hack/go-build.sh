@@ -19,7 +19,11 @@ if [ -z ${VERSION_OVERRIDE+a} ]; then
 	VERSION_OVERRIDE=$(git describe --abbrev=8 --dirty --always)
 fi
 
-GLDFLAGS+="-extldflags '-static' -X ${REPO}/pkg/version.Raw=${VERSION_OVERRIDE}"
+if [ -z ${COMMIT_OVERRIDE+a} ]; then
+	COMMIT_OVERRIDE=$(git rev-parse --short=8 HEAD)
+fi
+
+GLDFLAGS+="-extldflags '-static' -X ${REPO}/pkg/version.Raw=${VERSION_OVERRIDE} -X ${REPO}/pkg/version.Commit=${COMMIT_OVERRIDE}"
 
 eval $(go env)
 
@@ -0,0 +1,232 @@
+package azurevmskus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	mapiwebhooks "github.com/openshift/machine-api-operator/pkg/webhooks"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// Keys of the Azure credentials secrets minted by the cloud-credential-operator.
+	azureClientIDKey       = "azure_client_id"
+	azureClientSecretKey   = "azure_client_secret"
+	azureTenantIDKey       = "azure_tenant_id"
+	azureSubscriptionIDKey = "azure_subscription_id"
+
+	azureSKUsAPIVersion = "2021-07-01"
+	azureVMResourceType = "virtualMachines"
+
+	azureRestrictionTypeLocation = "Location"
+	azureRestrictionTypeZone     = "Zone"
+)
+
+// vmSKULister lists the VM SKUs available to the subscription in a location.
+type vmSKULister interface {
+	listVMSKUs(ctx context.Context, location string) (mapiwebhooks.AzureVMSKUs, error)
+}
+
+// azureEndpoints are the Active Directory and Resource Manager endpoints of an Azure cloud.
+type azureEndpoints struct {
+	activeDirectory string
+	resourceManager string
+}
+
+// azureCloudEndpoints are the endpoints of the Azure clouds. Azure Stack Hub clouds are not supported,
+// their Active Directory endpoint is only known from the metadata of their Resource Manager endpoint.
+var azureCloudEndpoints = map[osconfigv1.AzureCloudEnvironment]azureEndpoints{
+	osconfigv1.AzurePublicCloud:       {activeDirectory: "https://login.microsoftonline.com/", resourceManager: "https://management.azure.com/"},
+	osconfigv1.AzureUSGovernmentCloud: {activeDirectory: "https://login.microsoftonline.us/", resourceManager: "https://management.usgovcloudapi.net/"},
+	osconfigv1.AzureChinaCloud:        {activeDirectory: "https://login.chinacloudapi.cn/", resourceManager: "https://management.chinacloudapi.cn/"},
+	osconfigv1.AzureGermanCloud:       {activeDirectory: "https://login.microsoftonline.de/", resourceManager: "https://management.microsoftazure.de/"},
+}
+
+// azureSKUClient lists VM SKUs with the Resource SKUs REST API, authenticating as the service principal
+// of the credentials secret.
+type azureSKUClient struct {
+	httpClient     *http.Client
+	endpoints      azureEndpoints
+	clientID       string
+	clientSecret   string
+	tenantID       string
+	subscriptionID string
+
+	// token is the access token, requested on first use.
+	token string
+}
+
+// newAzureSKUClient returns a client for the cloud of the cluster using the service principal of the secret.
+func newAzureSKUClient(httpClient *http.Client, platformStatus *osconfigv1.PlatformStatus, secret *corev1.Secret) (vmSKULister, error) {
+	cloudName := osconfigv1.AzurePublicCloud
+	if platformStatus != nil && platformStatus.Azure != nil && platformStatus.Azure.CloudName != "" {
+		cloudName = platformStatus.Azure.CloudName
+	}
+	endpoints, ok := azureCloudEndpoints[cloudName]
+	if !ok {
+		return nil, fmt.Errorf("listing VM SKUs is not supported on %s", cloudName)
+	}
+
+	c := &azureSKUClient{httpClient: httpClient, endpoints: endpoints}
+	for key, value := range map[string]*string{
+		azureClientIDKey:       &c.clientID,
+		azureClientSecretKey:   &c.clientSecret,
+		azureTenantIDKey:       &c.tenantID,
+		azureSubscriptionIDKey: &c.subscriptionID,
+	} {
+		data, ok := secret.Data[key]
+		if !ok || len(data) == 0 {
+			return nil, fmt.Errorf("secret %s/%s has no %s", secret.GetNamespace(), secret.GetName(), key)
+		}
+		*value = string(data)
+	}
+	return c, nil
+}
+
+// azureResourceSKU is a resource SKU returned by the Resource SKUs API.
+// https://docs.microsoft.com/en-us/rest/api/compute/resource-skus/list
+type azureResourceSKU struct {
+	ResourceType string `json:"resourceType"`
+	Name         string `json:"name"`
+	LocationInfo []struct {
+		Location string   `json:"location"`
+		Zones    []string `json:"zones"`
+	} `json:"locationInfo"`
+	Restrictions []struct {
+		Type            string `json:"type"`
+		RestrictionInfo struct {
+			Locations []string `json:"locations"`
+			Zones     []string `json:"zones"`
+		} `json:"restrictionInfo"`
+	} `json:"restrictions"`
+}
+
+// listVMSKUs returns the VM sizes available to the subscription in the location, with the zones
+// they are available in. Sizes restricted in the location are left out, as are restricted zones.
+func (c *azureSKUClient) listVMSKUs(ctx context.Context, location string) (mapiwebhooks.AzureVMSKUs, error) {
+	query := url.Values{}
+	query.Set("api-version", azureSKUsAPIVersion)
+	query.Set("$filter", fmt.Sprintf("location eq '%s'", location))
+	next := fmt.Sprintf("%ssubscriptions/%s/providers/Microsoft.Compute/skus?%s", c.endpoints.resourceManager, url.PathEscape(c.subscriptionID), query.Encode())
+
+	skus := mapiwebhooks.AzureVMSKUs{}
+	for next != "" {
+		page := struct {
+			Value    []azureResourceSKU `json:"value"`
+			NextLink string             `json:"nextLink"`
+		}{}
+		if err := c.get(ctx, next, &page); err != nil {
+			return nil, err
+		}
+
+		for _, sku := range page.Value {
+			if sku.ResourceType != azureVMResourceType {
+				continue
+			}
+			if zones, ok := availableZones(sku, location); ok {
+				skus[sku.Name] = zones
+			}
+		}
+		next = page.NextLink
+	}
+	return skus, nil
+}
+
+// availableZones returns the zones of the location the SKU is available in,
+// and false when the SKU is not available in the location.
+func availableZones(sku azureResourceSKU, location string) ([]string, bool) {
+	restrictedZones := sets.NewString()
+	for _, restriction := range sku.Restrictions {
+		switch restriction.Type {
+		case azureRestrictionTypeLocation:
+			for _, restricted := range restriction.RestrictionInfo.Locations {
+				if strings.EqualFold(restricted, location) {
+					return nil, false
+				}
+			}
+		case azureRestrictionTypeZone:
+			restrictedZones.Insert(restriction.RestrictionInfo.Zones...)
+		}
+	}
+
+	for _, info := range sku.LocationInfo {
+		if !strings.EqualFold(info.Location, location) {
+			continue
+		}
+		zones := []string{}
+		for _, zone := range sets.NewString(info.Zones...).List() {
+			if !restrictedZones.Has(zone) {
+				zones = append(zones, zone)
+			}
+		}
+		return zones, true
+	}
+	return nil, false
+}
+
+// get fetches the Resource Manager URL into out.
+func (c *azureSKUClient) get(ctx context.Context, rawURL string, out interface{}) error {
+	if c.token == "" {
+		token, err := c.accessToken(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get an access token: %w", err)
+		}
+		c.token = token
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	return c.do(req, out)
+}
+
+// accessToken requests an access token to the Resource Manager with the client credentials grant.
+func (c *azureSKUClient) accessToken(ctx context.Context) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", c.clientID)
+	form.Set("client_secret", c.clientSecret)
+	form.Set("resource", c.endpoints.resourceManager)
+
+	tokenURL := fmt.Sprintf("%s%s/oauth2/token", c.endpoints.activeDirectory, url.PathEscape(c.tenantID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	token := struct {
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := c.do(req, &token); err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("no access token in the response of %s", tokenURL)
+	}
+	return token.AccessToken, nil
+}
+
+// do sends the request and decodes the JSON response into out.
+func (c *azureSKUClient) do(req *http.Request, out interface{}) error {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s: unexpected status %s: %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,122 @@
+package azurevmskus
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	mapiwebhooks "github.com/openshift/machine-api-operator/pkg/webhooks"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const testSKUsPage = `{
+  "value": [
+    {
+      "resourceType": "virtualMachines",
+      "name": "Standard_D4s_v3",
+      "locationInfo": [{"location": "eastus", "zones": ["3", "1", "2"]}],
+      "restrictions": []
+    },
+    {
+      "resourceType": "virtualMachines",
+      "name": "Standard_NC6",
+      "locationInfo": [{"location": "eastus", "zones": ["1", "2"]}],
+      "restrictions": [{"type": "Zone", "restrictionInfo": {"locations": ["eastus"], "zones": ["2"]}}]
+    },
+    {
+      "resourceType": "virtualMachines",
+      "name": "Standard_M416ms_v2",
+      "locationInfo": [{"location": "eastus", "zones": []}],
+      "restrictions": [{"type": "Location", "restrictionInfo": {"locations": ["eastus"]}}]
+    },
+    {
+      "resourceType": "disks",
+      "name": "Premium_LRS",
+      "locationInfo": [{"location": "eastus"}]
+    }
+  ],
+  "nextLink": "%s/next"
+}`
+
+const testSKUsNextPage = `{
+  "value": [
+    {
+      "resourceType": "virtualMachines",
+      "name": "Standard_A1",
+      "locationInfo": [{"location": "EastUS"}]
+    }
+  ]
+}`
+
+func TestListVMSKUs(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tenant/oauth2/token":
+			if err := r.ParseForm(); err != nil || r.Form.Get("client_secret") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"access_token": "token"}`)
+		case "/subscriptions/subscription/providers/Microsoft.Compute/skus":
+			if r.Header.Get("Authorization") != "Bearer token" || r.URL.Query().Get("$filter") != "location eq 'eastus'" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprintf(w, testSKUsPage, server.URL)
+		case "/next":
+			fmt.Fprint(w, testSKUsNextPage)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	newClient := func(clientSecret string) *azureSKUClient {
+		return &azureSKUClient{
+			httpClient:     server.Client(),
+			endpoints:      azureEndpoints{activeDirectory: server.URL + "/", resourceManager: server.URL + "/"},
+			clientID:       "client",
+			clientSecret:   clientSecret,
+			tenantID:       "tenant",
+			subscriptionID: "subscription",
+		}
+	}
+
+	skus, err := newClient("secret").listVMSKUs(context.TODO(), "eastus")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := mapiwebhooks.AzureVMSKUs{
+		"Standard_D4s_v3": {"1", "2", "3"},
+		"Standard_NC6":    {"1"},
+		"Standard_A1":     {},
+	}
+	if !reflect.DeepEqual(skus, expected) {
+		t.Errorf("expected: %v, got: %v", expected, skus)
+	}
+
+	if _, err := newClient("wrong").listVMSKUs(context.TODO(), "eastus"); err == nil {
+		t.Errorf("expected an error with invalid credentials")
+	}
+}
+
+func TestNewAzureSKUClient(t *testing.T) {
+	secret := &corev1.Secret{Data: map[string][]byte{
+		azureClientIDKey:       []byte("client"),
+		azureClientSecretKey:   []byte("secret"),
+		azureTenantIDKey:       []byte("tenant"),
+		azureSubscriptionIDKey: []byte("subscription"),
+	}}
+	if _, err := newAzureSKUClient(http.DefaultClient, nil, secret); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	delete(secret.Data, azureTenantIDKey)
+	if _, err := newAzureSKUClient(http.DefaultClient, nil, secret); err == nil {
+		t.Errorf("expected an error without a tenant ID")
+	}
+}
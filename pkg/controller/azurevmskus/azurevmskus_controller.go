@@ -0,0 +1,257 @@
+package azurevmskus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	mapiwebhooks "github.com/openshift/machine-api-operator/pkg/webhooks"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// machineAPINamespace is the namespace of the webhook ConfigMaps and of the MachineSets
+	// whose locations are cached.
+	machineAPINamespace = "openshift-machine-api"
+
+	// defaultRefreshInterval is how long the cached SKUs are served before they are listed again.
+	defaultRefreshInterval = 12 * time.Hour
+	// policyRecheckInterval is how often the validation policy is checked again while it does not
+	// enable the validation of Azure VM sizes.
+	policyRecheckInterval = 10 * time.Minute
+
+	infrastructureName = "cluster"
+
+	controllerName = "azurevmskus-controller"
+)
+
+// blank assignment to verify that ReconcileAzureVMSKUs implements reconcile.Reconciler
+var _ reconcile.Reconciler = &ReconcileAzureVMSKUs{}
+
+// ReconcileAzureVMSKUs caches the VM SKUs available in the locations of the Azure MachineSets
+// in the AzureVMSKUsConfigMapName ConfigMap, for the validating webhooks to check VM sizes against.
+// The SKUs are only listed while the validation policy enables the validation of Azure VM sizes.
+type ReconcileAzureVMSKUs struct {
+	client client.Client
+	// reader reads the Infrastructure, the ConfigMaps and the credentials secret without caching them,
+	// so that no informer is started for the ConfigMaps and secrets of the cluster.
+	reader client.Reader
+
+	// refreshInterval is how long the cached SKUs are served before they are listed again.
+	refreshInterval time.Duration
+
+	// newLister returns the SKU lister for the cluster and credentials secret.
+	newLister func(platformStatus *osconfigv1.PlatformStatus, secret *corev1.Secret) (vmSKULister, error)
+
+	// nowFunc is used to mock time in testing. It should be nil in production.
+	nowFunc func() time.Time
+}
+
+// Add creates a new Azure VM SKU controller and adds it to the Manager.
+// httpClient is used for the requests to the Azure APIs.
+func Add(mgr manager.Manager, opts manager.Options, httpClient *http.Client) error {
+	r := &ReconcileAzureVMSKUs{
+		client:          mgr.GetClient(),
+		reader:          mgr.GetAPIReader(),
+		refreshInterval: defaultRefreshInterval,
+		newLister: func(platformStatus *osconfigv1.PlatformStatus, secret *corev1.Secret) (vmSKULister, error) {
+			return newAzureSKUClient(httpClient, platformStatus, secret)
+		},
+	}
+
+	c, err := controller.New(controllerName, mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	// All MachineSets map to the single SKUs ConfigMap, so that a new location is cached as soon as
+	// a MachineSet uses it.
+	return c.Watch(
+		&source.Kind{Type: &machinev1.MachineSet{}},
+		handler.EnqueueRequestsFromMapFunc(func(client.Object) []reconcile.Request {
+			return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: machineAPINamespace, Name: mapiwebhooks.AzureVMSKUsConfigMapName}}}
+		}),
+	)
+}
+
+// Reconcile lists the VM SKUs of the locations of the Azure MachineSets into the SKUs ConfigMap,
+// when the cached SKUs are missing a location or are older than the refresh interval.
+func (r *ReconcileAzureVMSKUs) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	infra := &osconfigv1.Infrastructure{}
+	if err := r.reader.Get(ctx, client.ObjectKey{Name: infrastructureName}, infra); err != nil {
+		return reconcile.Result{}, err
+	}
+	if infra.Status.PlatformStatus == nil || infra.Status.PlatformStatus.Type != osconfigv1.AzurePlatformType {
+		return reconcile.Result{}, nil
+	}
+
+	policy, err := r.getConfigMap(ctx, mapiwebhooks.ValidationPolicyConfigMapName)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if !mapiwebhooks.AzureVMSizeAvailabilityFromPolicy(policy) {
+		return reconcile.Result{RequeueAfter: policyRecheckInterval}, nil
+	}
+
+	locations, credentialsSecret, err := r.machineSetLocations(ctx)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if len(locations) == 0 {
+		return reconcile.Result{}, nil
+	}
+
+	current, err := r.getConfigMap(ctx, request.Name)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if fresh, until := r.isFresh(current, locations); fresh {
+		return reconcile.Result{RequeueAfter: until}, nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.reader.Get(ctx, credentialsSecret, secret); err != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to get the Azure credentials secret: %w", err)
+	}
+	lister, err := r.newLister(infra.Status.PlatformStatus, secret)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	data := map[string]string{}
+	for _, location := range locations {
+		skus, err := lister.listVMSKUs(ctx, location)
+		if err != nil {
+			return reconcile.Result{}, fmt.Errorf("failed to list the VM SKUs of %s: %w", location, err)
+		}
+		rawSKUs, err := json.Marshal(skus)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		data[location] = string(rawSKUs)
+	}
+
+	if err := r.updateConfigMap(ctx, request.NamespacedName, current, data); err != nil {
+		return reconcile.Result{}, err
+	}
+	klog.Infof("Cached the Azure VM SKUs of locations %v", locations)
+	return reconcile.Result{RequeueAfter: r.refreshInterval}, nil
+}
+
+// machineSetLocations returns the sorted locations of the Azure MachineSets, and the credentials secret
+// of the first of them.
+func (r *ReconcileAzureVMSKUs) machineSetLocations(ctx context.Context) ([]string, client.ObjectKey, error) {
+	machineSets := &machinev1.MachineSetList{}
+	if err := r.client.List(ctx, machineSets, client.InNamespace(machineAPINamespace)); err != nil {
+		return nil, client.ObjectKey{}, err
+	}
+
+	var credentialsSecret client.ObjectKey
+	seen := map[string]bool{}
+	var locations []string
+	for _, ms := range machineSets.Items {
+		if ms.Spec.Template.Spec.ProviderSpec.Value == nil {
+			continue
+		}
+		providerSpec := &machinev1.AzureMachineProviderSpec{}
+		if err := yaml.Unmarshal(ms.Spec.Template.Spec.ProviderSpec.Value.Raw, providerSpec); err != nil {
+			klog.V(3).Infof("%v: ignoring MachineSet with an invalid Azure providerSpec: %v", ms.GetName(), err)
+			continue
+		}
+		if providerSpec.Location == "" {
+			continue
+		}
+		if !seen[providerSpec.Location] {
+			seen[providerSpec.Location] = true
+			locations = append(locations, providerSpec.Location)
+		}
+		if credentialsSecret.Name == "" && providerSpec.CredentialsSecret != nil && providerSpec.CredentialsSecret.Name != "" {
+			credentialsSecret = client.ObjectKey{Namespace: providerSpec.CredentialsSecret.Namespace, Name: providerSpec.CredentialsSecret.Name}
+			if credentialsSecret.Namespace == "" {
+				credentialsSecret.Namespace = machineAPINamespace
+			}
+		}
+	}
+
+	if len(locations) > 0 && credentialsSecret.Name == "" {
+		return nil, client.ObjectKey{}, fmt.Errorf("no Azure MachineSet references a credentials secret")
+	}
+	sort.Strings(locations)
+	return locations, credentialsSecret, nil
+}
+
+// isFresh returns whether the SKUs ConfigMap caches all the locations and was refreshed within the
+// refresh interval, with the time until it must be refreshed.
+func (r *ReconcileAzureVMSKUs) isFresh(current *corev1.ConfigMap, locations []string) (bool, time.Duration) {
+	if current == nil {
+		return false, 0
+	}
+	for _, location := range locations {
+		if _, ok := current.Data[location]; !ok {
+			return false, 0
+		}
+	}
+
+	refreshed, err := time.Parse(time.RFC3339, current.Annotations[mapiwebhooks.AzureVMSKUsRefreshedAnnotation])
+	if err != nil {
+		return false, 0
+	}
+	until := refreshed.Add(r.refreshInterval).Sub(r.now())
+	return until > 0, until
+}
+
+// updateConfigMap creates or replaces the SKUs ConfigMap with the data, recording the refresh time.
+// Locations no MachineSet uses anymore are dropped.
+func (r *ReconcileAzureVMSKUs) updateConfigMap(ctx context.Context, key types.NamespacedName, current *corev1.ConfigMap, data map[string]string) error {
+	configMap := current
+	if configMap == nil {
+		configMap = &corev1.ConfigMap{}
+		configMap.Namespace = key.Namespace
+		configMap.Name = key.Name
+	}
+	if configMap.Annotations == nil {
+		configMap.Annotations = map[string]string{}
+	}
+	configMap.Annotations[mapiwebhooks.AzureVMSKUsRefreshedAnnotation] = r.now().UTC().Format(time.RFC3339)
+	configMap.Data = data
+
+	if current == nil {
+		return r.client.Create(ctx, configMap)
+	}
+	return r.client.Update(ctx, configMap)
+}
+
+// getConfigMap returns the ConfigMap of the machine-api namespace, or nil if it does not exist.
+func (r *ReconcileAzureVMSKUs) getConfigMap(ctx context.Context, name string) (*corev1.ConfigMap, error) {
+	configMap := &corev1.ConfigMap{}
+	if err := r.reader.Get(ctx, client.ObjectKey{Namespace: machineAPINamespace, Name: name}, configMap); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return configMap, nil
+}
+
+// now is used to get the current time. If the reconciler nowFunc is no nil this will be used instead of time.Now().
+func (r *ReconcileAzureVMSKUs) now() time.Time {
+	if r.nowFunc != nil {
+		return r.nowFunc()
+	}
+	return time.Now()
+}
@@ -0,0 +1,217 @@
+package azurevmskus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	mapiwebhooks "github.com/openshift/machine-api-operator/pkg/webhooks"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var start = time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+var skusRequest = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: machineAPINamespace, Name: mapiwebhooks.AzureVMSKUsConfigMapName}}
+
+func init() {
+	// Add types to scheme
+	machinev1.AddToScheme(scheme.Scheme)
+	osconfigv1.AddToScheme(scheme.Scheme)
+}
+
+// fakeLister lists the SKUs of its locations and counts the locations listed.
+type fakeLister struct {
+	skus   map[string]mapiwebhooks.AzureVMSKUs
+	listed []string
+}
+
+func (l *fakeLister) listVMSKUs(_ context.Context, location string) (mapiwebhooks.AzureVMSKUs, error) {
+	l.listed = append(l.listed, location)
+	skus, ok := l.skus[location]
+	if !ok {
+		return nil, fmt.Errorf("unknown location %s", location)
+	}
+	return skus, nil
+}
+
+func newInfrastructure(platform osconfigv1.PlatformType) *osconfigv1.Infrastructure {
+	return &osconfigv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{Name: infrastructureName},
+		Status: osconfigv1.InfrastructureStatus{
+			PlatformStatus: &osconfigv1.PlatformStatus{Type: platform},
+		},
+	}
+}
+
+func newPolicy(enabled string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: mapiwebhooks.ValidationPolicyConfigMapName, Namespace: machineAPINamespace},
+		Data:       map[string]string{"azure.validateVMSizeAvailability": enabled},
+	}
+}
+
+func newMachineSet(t *testing.T, name, location string) *machinev1.MachineSet {
+	rawBytes, err := json.Marshal(&machinev1.AzureMachineProviderSpec{
+		Location:          location,
+		CredentialsSecret: &corev1.SecretReference{Name: "azure-cloud-credentials", Namespace: machineAPINamespace},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ms := &machinev1.MachineSet{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: machineAPINamespace}}
+	ms.Spec.Template.Spec.ProviderSpec.Value = &runtime.RawExtension{Raw: rawBytes}
+	return ms
+}
+
+func newReconciler(lister *fakeLister, now *time.Time, objects ...runtime.Object) *ReconcileAzureVMSKUs {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "azure-cloud-credentials", Namespace: machineAPINamespace}}
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, append(objects, secret)...)
+	return &ReconcileAzureVMSKUs{
+		client:          c,
+		reader:          c,
+		refreshInterval: defaultRefreshInterval,
+		newLister: func(*osconfigv1.PlatformStatus, *corev1.Secret) (vmSKULister, error) {
+			return lister, nil
+		},
+		nowFunc: func() time.Time { return *now },
+	}
+}
+
+func reconcileSKUs(t *testing.T, r *ReconcileAzureVMSKUs) reconcile.Result {
+	t.Helper()
+	result, err := r.Reconcile(context.TODO(), skusRequest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return result
+}
+
+func getSKUs(t *testing.T, r *ReconcileAzureVMSKUs) *corev1.ConfigMap {
+	t.Helper()
+	configMap := &corev1.ConfigMap{}
+	if err := r.client.Get(context.TODO(), skusRequest.NamespacedName, configMap); err != nil {
+		t.Fatalf("unexpected error getting the SKUs ConfigMap: %v", err)
+	}
+	return configMap
+}
+
+func TestReconcileDisabled(t *testing.T) {
+	testCases := []struct {
+		testCase       string
+		objects        []runtime.Object
+		expectedResult reconcile.Result
+	}{
+		{
+			testCase:       "without a policy",
+			objects:        []runtime.Object{newInfrastructure(osconfigv1.AzurePlatformType)},
+			expectedResult: reconcile.Result{RequeueAfter: policyRecheckInterval},
+		},
+		{
+			testCase:       "with the validation disabled",
+			objects:        []runtime.Object{newInfrastructure(osconfigv1.AzurePlatformType), newPolicy("false")},
+			expectedResult: reconcile.Result{RequeueAfter: policyRecheckInterval},
+		},
+		{
+			testCase:       "on another platform",
+			objects:        []runtime.Object{newInfrastructure(osconfigv1.AWSPlatformType), newPolicy("true")},
+			expectedResult: reconcile.Result{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			now := start
+			lister := &fakeLister{}
+			r := newReconciler(lister, &now, append(tc.objects, newMachineSet(t, "workers", "eastus"))...)
+
+			if result := reconcileSKUs(t, r); result != tc.expectedResult {
+				t.Errorf("expected: %v, got: %v", tc.expectedResult, result)
+			}
+			if len(lister.listed) != 0 {
+				t.Errorf("expected no SKUs to be listed, got: %v", lister.listed)
+			}
+		})
+	}
+}
+
+func TestReconcileRefresh(t *testing.T) {
+	now := start
+	lister := &fakeLister{skus: map[string]mapiwebhooks.AzureVMSKUs{
+		"eastus":  {"Standard_D4s_v3": {"1", "2", "3"}},
+		"westus2": {"Standard_D4s_v3": {"1"}},
+	}}
+	r := newReconciler(lister, &now,
+		newInfrastructure(osconfigv1.AzurePlatformType),
+		newPolicy("true"),
+		newMachineSet(t, "workers-1", "eastus"),
+		newMachineSet(t, "workers-2", "eastus"),
+	)
+
+	if result := reconcileSKUs(t, r); result.RequeueAfter != defaultRefreshInterval {
+		t.Errorf("expected a requeue after the refresh interval, got: %v", result.RequeueAfter)
+	}
+	configMap := getSKUs(t, r)
+	if expected := map[string]string{"eastus": `{"Standard_D4s_v3":["1","2","3"]}`}; !reflect.DeepEqual(configMap.Data, expected) {
+		t.Errorf("expected: %v, got: %v", expected, configMap.Data)
+	}
+	if refreshed := configMap.Annotations[mapiwebhooks.AzureVMSKUsRefreshedAnnotation]; refreshed != start.Format(time.RFC3339) {
+		t.Errorf("expected the SKUs to be refreshed at %v, got: %q", start, refreshed)
+	}
+
+	// The cached SKUs are served until the refresh interval has elapsed.
+	now = start.Add(time.Hour)
+	if result := reconcileSKUs(t, r); result.RequeueAfter != defaultRefreshInterval-time.Hour {
+		t.Errorf("expected a requeue when the SKUs are due for a refresh, got: %v", result.RequeueAfter)
+	}
+	if len(lister.listed) != 1 {
+		t.Errorf("expected the SKUs not to be listed again, got: %v", lister.listed)
+	}
+
+	// A new location is cached straight away.
+	if err := r.client.Create(context.TODO(), newMachineSet(t, "workers-3", "westus2")); err != nil {
+		t.Fatal(err)
+	}
+	reconcileSKUs(t, r)
+	if expected := []string{"eastus", "eastus", "westus2"}; !reflect.DeepEqual(lister.listed, expected) {
+		t.Errorf("expected: %v, got: %v", expected, lister.listed)
+	}
+	if data := getSKUs(t, r).Data; len(data) != 2 {
+		t.Errorf("expected the SKUs of 2 locations, got: %v", data)
+	}
+
+	// The SKUs are listed again once the refresh interval has elapsed.
+	now = now.Add(defaultRefreshInterval)
+	reconcileSKUs(t, r)
+	if len(lister.listed) != 5 {
+		t.Errorf("expected the SKUs to be listed again, got: %v", lister.listed)
+	}
+}
+
+func TestReconcileListFailure(t *testing.T) {
+	now := start
+	lister := &fakeLister{}
+	r := newReconciler(lister, &now,
+		newInfrastructure(osconfigv1.AzurePlatformType),
+		newPolicy("true"),
+		newMachineSet(t, "workers", "eastus"),
+	)
+
+	if _, err := r.Reconcile(context.TODO(), skusRequest); err == nil {
+		t.Errorf("expected an error when the SKUs cannot be listed")
+	}
+	if configMap, err := r.getConfigMap(context.TODO(), mapiwebhooks.AzureVMSKUsConfigMapName); err != nil || configMap != nil {
+		t.Errorf("expected no SKUs to be cached, got: %v, %v", configMap, err)
+	}
+}
@@ -0,0 +1,378 @@
+package bootimage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	yaml "sigs.k8s.io/yaml"
+)
+
+const (
+	// RefreshAnnotation is set to "true" on a MachineSet to update the boot image of its providerSpec
+	// when a new boot image ships with the cluster.
+	RefreshAnnotation = "machine.openshift.io/boot-image-refresh"
+
+	// ReleaseAnnotation is set by the controller on a MachineSet to the release of the boot image of its providerSpec.
+	ReleaseAnnotation = "machine.openshift.io/boot-image-release"
+
+	// VSphereTemplateFormatAnnotation is set on a vSphere MachineSet to the name of the template of each boot image
+	// release, in which "{release}" is replaced by the release, e.g. "rhcos-{release}". The boot image of vSphere
+	// MachineSets is shipped as an OVA, which has to be imported as a template before MachineSets can use it.
+	VSphereTemplateFormatAnnotation = "machine.openshift.io/boot-image-vsphere-template-format"
+
+	// BootImagesConfigMapNamespace and BootImagesConfigMapName are the ConfigMap holding the CoreOS stream metadata
+	// of the boot images of the cluster.
+	BootImagesConfigMapNamespace = "openshift-machine-config-operator"
+	BootImagesConfigMapName      = "coreos-bootimages"
+
+	// managedUserDataSuffix is the suffix of the user data secrets managed by the machine config operator. Clusters
+	// installed with boot images older than Ignition spec 3 keep the stub user data secret they were installed with,
+	// e.g. worker-user-data, which holds an Ignition spec 2 config that newer boot images cannot boot, and the machine
+	// config operator manages a spec 3 copy of it, e.g. worker-user-data-managed.
+	managedUserDataSuffix = "-managed"
+	userDataSecretKey     = "userData"
+
+	streamKey          = "stream"
+	releasePlaceholder = "{release}"
+	archLabel          = "kubernetes.io/arch"
+	defaultArch        = "x86_64"
+
+	// refreshInterval is the interval to check again for a new boot image, as the boot images ConfigMap
+	// lives outside of the watched namespace.
+	refreshInterval = 10 * time.Minute
+
+	controllerName = "bootimage-controller"
+)
+
+// streamArchs maps the node architectures to the architectures of the CoreOS stream metadata.
+var streamArchs = map[string]string{
+	"amd64":   "x86_64",
+	"arm64":   "aarch64",
+	"ppc64le": "ppc64le",
+	"s390x":   "s390x",
+}
+
+// stream is the subset of the CoreOS stream metadata of the boot images of the cluster used by the controller.
+type stream struct {
+	Architectures map[string]streamArch `json:"architectures"`
+}
+
+type streamArch struct {
+	Artifacts map[string]streamArtifacts `json:"artifacts"`
+	Images    streamImages               `json:"images"`
+}
+
+type streamArtifacts struct {
+	Release string `json:"release"`
+}
+
+type streamImages struct {
+	AWS *streamAWSImage `json:"aws,omitempty"`
+	GCP *streamGCPImage `json:"gcp,omitempty"`
+}
+
+type streamAWSImage struct {
+	Regions map[string]streamRegionImage `json:"regions"`
+}
+
+type streamRegionImage struct {
+	Release string `json:"release"`
+	Image   string `json:"image"`
+}
+
+type streamGCPImage struct {
+	Release string `json:"release"`
+	Project string `json:"project"`
+	Name    string `json:"name"`
+}
+
+// blank assignment to verify that ReconcileBootImage implements reconcile.Reconciler
+var _ reconcile.Reconciler = &ReconcileBootImage{}
+
+// ReconcileBootImage updates the AMI, GCP image or vSphere template of the providerSpec of MachineSets which opt in
+// to boot image refreshes to the boot image shipped with the cluster, so that new machines boot from it.
+type ReconcileBootImage struct {
+	client client.Client
+	// apiReader reads the boot images ConfigMap, which lives outside of the watched namespace.
+	apiReader client.Reader
+	recorder  record.EventRecorder
+}
+
+// Add creates a new boot image controller and adds it to the Manager.
+func Add(mgr manager.Manager, opts manager.Options) error {
+	r := &ReconcileBootImage{
+		client:    mgr.GetClient(),
+		apiReader: mgr.GetAPIReader(),
+		recorder:  mgr.GetEventRecorderFor(controllerName),
+	}
+
+	c, err := controller.New(controllerName, mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+	return c.Watch(&source.Kind{Type: &machinev1.MachineSet{}}, &handler.EnqueueRequestForObject{})
+}
+
+// Reconcile updates the boot image of a MachineSet to the boot image shipped with the cluster.
+func (r *ReconcileBootImage) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	ms := &machinev1.MachineSet{}
+	if err := r.client.Get(ctx, request.NamespacedName, ms); err != nil {
+		if apierrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	if !ms.DeletionTimestamp.IsZero() || ms.Annotations[RefreshAnnotation] != "true" {
+		return reconcile.Result{}, nil
+	}
+
+	s, err := r.getStream(ctx)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			klog.V(3).Infof("%v: no boot images ConfigMap %s/%s", ms.GetName(), BootImagesConfigMapNamespace, BootImagesConfigMapName)
+			return reconcile.Result{RequeueAfter: refreshInterval}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	raw, release, err := refreshProviderSpec(ms, s)
+	if err != nil {
+		klog.Warningf("%v: unable to refresh the boot image: %v", ms.GetName(), err)
+		r.recorder.Eventf(ms, corev1.EventTypeWarning, "BootImageRefreshFailed", "Unable to refresh the boot image: %v", err)
+		return reconcile.Result{RequeueAfter: refreshInterval}, nil
+	}
+	if raw == nil && ms.Annotations[ReleaseAnnotation] == release {
+		return reconcile.Result{RequeueAfter: refreshInterval}, nil
+	}
+
+	var managedUserData string
+	if raw != nil {
+		raw, managedUserData, err = r.switchLegacyUserData(ctx, ms.Namespace, raw)
+		if err != nil {
+			klog.Warningf("%v: unable to refresh the boot image to release %s: %v", ms.GetName(), release, err)
+			r.recorder.Eventf(ms, corev1.EventTypeWarning, "BootImageRefreshFailed", "Unable to refresh the boot image to release %s: %v", release, err)
+			return reconcile.Result{RequeueAfter: refreshInterval}, nil
+		}
+	}
+
+	baseToPatch := client.MergeFrom(ms.DeepCopy())
+	if raw != nil {
+		ms.Spec.Template.Spec.ProviderSpec.Value = &runtime.RawExtension{Raw: raw}
+	}
+	ms.Annotations[ReleaseAnnotation] = release
+	if err := r.client.Patch(ctx, ms, baseToPatch); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	switch {
+	case managedUserData != "":
+		klog.Infof("%v: updated the boot image to release %s and the user data secret to %s", ms.GetName(), release, managedUserData)
+		r.recorder.Eventf(ms, corev1.EventTypeNormal, "BootImageUpdated", "Updated the boot image to release %s and the user data secret to %s", release, managedUserData)
+	case raw != nil:
+		klog.Infof("%v: updated the boot image to release %s", ms.GetName(), release)
+		r.recorder.Eventf(ms, corev1.EventTypeNormal, "BootImageUpdated", "Updated the boot image to release %s", release)
+	}
+	return reconcile.Result{RequeueAfter: refreshInterval}, nil
+}
+
+// getStream returns the CoreOS stream metadata of the boot images of the cluster.
+func (r *ReconcileBootImage) getStream(ctx context.Context) (*stream, error) {
+	cm := &corev1.ConfigMap{}
+	key := client.ObjectKey{Namespace: BootImagesConfigMapNamespace, Name: BootImagesConfigMapName}
+	if err := r.apiReader.Get(ctx, key, cm); err != nil {
+		return nil, err
+	}
+
+	s := &stream{}
+	if err := json.Unmarshal([]byte(cm.Data[streamKey]), s); err != nil {
+		return nil, fmt.Errorf("invalid stream metadata in ConfigMap %s: %w", key, err)
+	}
+	return s, nil
+}
+
+// switchLegacyUserData switches the refreshed providerSpec from a stub user data secret holding an Ignition spec 2
+// config to the spec 3 copy managed by the machine config operator, as the refreshed boot image cannot boot spec 2
+// configs. It returns the providerSpec and the name of the managed secret when it switched to it, or an error when
+// there is no managed secret to switch to.
+func (r *ReconcileBootImage) switchLegacyUserData(ctx context.Context, namespace string, raw []byte) ([]byte, string, error) {
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, "", err
+	}
+	name, _, _ := unstructured.NestedString(fields, "userDataSecret", "name")
+	if name == "" {
+		return raw, "", nil
+	}
+
+	version, err := r.userDataIgnitionVersion(ctx, namespace, name)
+	if err != nil || !strings.HasPrefix(version, "2.") {
+		// Missing or invalid user data is reported by the machine controller.
+		return raw, "", nil
+	}
+
+	managed := name + managedUserDataSuffix
+	managedVersion, err := r.userDataIgnitionVersion(ctx, namespace, managed)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, "", fmt.Errorf("the user data secret %s holds an Ignition spec %s config, which the boot image cannot boot, and there is no %s secret to switch to", name, version, managed)
+		}
+		return nil, "", err
+	}
+	if strings.HasPrefix(managedVersion, "2.") {
+		return nil, "", fmt.Errorf("the user data secrets %s and %s hold Ignition spec %s configs, which the boot image cannot boot", name, managed, managedVersion)
+	}
+
+	if err := unstructured.SetNestedField(fields, managed, "userDataSecret", "name"); err != nil {
+		return nil, "", err
+	}
+	raw, err = json.Marshal(fields)
+	if err != nil {
+		return nil, "", err
+	}
+	return raw, managed, nil
+}
+
+// userDataIgnitionVersion returns the Ignition version of the config of the user data secret.
+func (r *ReconcileBootImage) userDataIgnitionVersion(ctx context.Context, namespace, name string) (string, error) {
+	secret := &corev1.Secret{}
+	if err := r.apiReader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, secret); err != nil {
+		return "", err
+	}
+	config := struct {
+		Ignition struct {
+			Version string `json:"version"`
+		} `json:"ignition"`
+	}{}
+	if err := json.Unmarshal(secret.Data[userDataSecretKey], &config); err != nil {
+		return "", fmt.Errorf("invalid Ignition config in secret %s: %w", name, err)
+	}
+	return config.Ignition.Version, nil
+}
+
+// refreshProviderSpec returns the providerSpec of the MachineSet referencing the boot image of the stream,
+// or nil when it references it already, and the release of the boot image.
+func refreshProviderSpec(ms *machinev1.MachineSet, s *stream) ([]byte, string, error) {
+	providerSpec := ms.Spec.Template.Spec.ProviderSpec.Value
+	if providerSpec == nil || len(providerSpec.Raw) == 0 {
+		return nil, "", fmt.Errorf("no providerSpec")
+	}
+	fields := map[string]interface{}{}
+	if err := yaml.Unmarshal(providerSpec.Raw, &fields); err != nil {
+		return nil, "", fmt.Errorf("invalid providerSpec: %w", err)
+	}
+
+	arch := defaultArch
+	if nodeArch, ok := ms.Spec.Template.Spec.ObjectMeta.Labels[archLabel]; ok {
+		if arch, ok = streamArchs[nodeArch]; !ok {
+			return nil, "", fmt.Errorf("unsupported architecture %q", nodeArch)
+		}
+	}
+	images, ok := s.Architectures[arch]
+	if !ok {
+		return nil, "", fmt.Errorf("no boot images for architecture %q", arch)
+	}
+
+	var release string
+	var changed bool
+	var err error
+	switch kind, _, _ := unstructured.NestedString(fields, "kind"); kind {
+	case "AWSMachineProviderConfig":
+		release, changed, err = refreshAWSImage(fields, images)
+	case "GCPMachineProviderSpec":
+		release, changed, err = refreshGCPImage(fields, images)
+	case "VSphereMachineProviderSpec":
+		release, changed, err = refreshVSphereTemplate(fields, images, ms.Annotations[VSphereTemplateFormatAnnotation])
+	default:
+		return nil, "", fmt.Errorf("unsupported providerSpec kind %q", kind)
+	}
+	if err != nil || !changed {
+		return nil, release, err
+	}
+
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return nil, "", err
+	}
+	return raw, release, nil
+}
+
+// refreshAWSImage sets the AMI ID of the AWS providerSpec to the AMI of its region.
+func refreshAWSImage(fields map[string]interface{}, images streamArch) (string, bool, error) {
+	region, _, _ := unstructured.NestedString(fields, "placement", "region")
+	if images.Images.AWS == nil {
+		return "", false, fmt.Errorf("no AWS boot images")
+	}
+	image, ok := images.Images.AWS.Regions[region]
+	if !ok {
+		return "", false, fmt.Errorf("no AWS boot image for region %q", region)
+	}
+
+	id, found, _ := unstructured.NestedString(fields, "ami", "id")
+	if !found {
+		return "", false, fmt.Errorf("the AMI is not referenced by ID")
+	}
+	if id == image.Image {
+		return image.Release, false, nil
+	}
+	return image.Release, true, unstructured.SetNestedField(fields, image.Image, "ami", "id")
+}
+
+// refreshGCPImage sets the image of the boot disk of the GCP providerSpec.
+func refreshGCPImage(fields map[string]interface{}, images streamArch) (string, bool, error) {
+	if images.Images.GCP == nil {
+		return "", false, fmt.Errorf("no GCP boot image")
+	}
+	image := fmt.Sprintf("projects/%s/global/images/%s", images.Images.GCP.Project, images.Images.GCP.Name)
+
+	disks, _, _ := unstructured.NestedSlice(fields, "disks")
+	for i := range disks {
+		disk, ok := disks[i].(map[string]interface{})
+		if !ok || disk["boot"] != true {
+			continue
+		}
+		if disk["image"] == image {
+			return images.Images.GCP.Release, false, nil
+		}
+		disk["image"] = image
+		return images.Images.GCP.Release, true, unstructured.SetNestedSlice(fields, disks, "disks")
+	}
+	return "", false, fmt.Errorf("no boot disk")
+}
+
+// refreshVSphereTemplate sets the template of the vSphere providerSpec to the template of the release
+// named by the format.
+func refreshVSphereTemplate(fields map[string]interface{}, images streamArch, format string) (string, bool, error) {
+	if format == "" {
+		return "", false, fmt.Errorf("the %s annotation is required to name the template of the boot image", VSphereTemplateFormatAnnotation)
+	}
+	if !strings.Contains(format, releasePlaceholder) {
+		return "", false, fmt.Errorf("the %s annotation %q does not contain %s", VSphereTemplateFormatAnnotation, format, releasePlaceholder)
+	}
+	artifacts, ok := images.Artifacts["vmware"]
+	if !ok || artifacts.Release == "" {
+		return "", false, fmt.Errorf("no vSphere boot image")
+	}
+
+	template := strings.ReplaceAll(format, releasePlaceholder, artifacts.Release)
+	if current, _, _ := unstructured.NestedString(fields, "template"); current == template {
+		return artifacts.Release, false, nil
+	}
+	fields["template"] = template
+	return artifacts.Release, true, nil
+}
@@ -0,0 +1,267 @@
+package bootimage
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	yaml "sigs.k8s.io/yaml"
+)
+
+const namespace = "openshift-machine-api"
+
+const streamMetadata = `{
+  "stream": "rhcos-4.10",
+  "architectures": {
+    "x86_64": {
+      "artifacts": {
+        "vmware": {"release": "410.84.202205191234-0"}
+      },
+      "images": {
+        "aws": {"regions": {"us-east-1": {"release": "410.84.202205191234-0", "image": "ami-new"}}},
+        "gcp": {"release": "410.84.202205191234-0", "project": "rhcos-cloud", "name": "rhcos-410-84-202205191234-0-gcp-x86-64"}
+      }
+    },
+    "aarch64": {
+      "images": {
+        "aws": {"regions": {"us-east-1": {"release": "410.84.202205191234-0", "image": "ami-new-arm"}}}
+      }
+    }
+  }
+}`
+
+func init() {
+	// Add types to scheme
+	machinev1.AddToScheme(scheme.Scheme)
+}
+
+func newMachineSet(annotations map[string]string, providerSpec string) *machinev1.MachineSet {
+	return &machinev1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "workers",
+			Namespace:   namespace,
+			Annotations: annotations,
+		},
+		Spec: machinev1.MachineSetSpec{
+			Template: machinev1.MachineTemplateSpec{
+				Spec: machinev1.MachineSpec{
+					ProviderSpec: machinev1.ProviderSpec{
+						Value: &runtime.RawExtension{Raw: []byte(providerSpec)},
+					},
+				},
+			},
+		},
+	}
+}
+
+func newReconciler(objects ...runtime.Object) *ReconcileBootImage {
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, objects...)
+	return &ReconcileBootImage{
+		client:    c,
+		apiReader: c,
+		recorder:  record.NewFakeRecorder(32),
+	}
+}
+
+func bootImagesConfigMap() *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: BootImagesConfigMapName, Namespace: BootImagesConfigMapNamespace},
+		Data:       map[string]string{streamKey: streamMetadata},
+	}
+}
+
+func userDataSecret(name, ignitionVersion string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Data:       map[string][]byte{userDataSecretKey: []byte(`{"ignition":{"version":"` + ignitionVersion + `"}}`)},
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	optIn := map[string]string{RefreshAnnotation: "true"}
+
+	testCases := []struct {
+		name                 string
+		annotations          map[string]string
+		nodeArch             string
+		providerSpec         string
+		configMap            bool
+		secrets              []runtime.Object
+		expectedProviderSpec string
+		expectedRelease      string
+		expectedEvent        string
+	}{
+		{
+			name:                 "AWS",
+			annotations:          optIn,
+			providerSpec:         `{"kind":"AWSMachineProviderConfig","ami":{"id":"ami-old"},"placement":{"region":"us-east-1"},"instanceType":"m5.large"}`,
+			configMap:            true,
+			expectedProviderSpec: `{"kind":"AWSMachineProviderConfig","ami":{"id":"ami-new"},"placement":{"region":"us-east-1"},"instanceType":"m5.large"}`,
+			expectedRelease:      "410.84.202205191234-0",
+			expectedEvent:        "BootImageUpdated",
+		},
+		{
+			name:                 "AWS arm64",
+			annotations:          optIn,
+			nodeArch:             "arm64",
+			providerSpec:         `{"kind":"AWSMachineProviderConfig","ami":{"id":"ami-old"},"placement":{"region":"us-east-1"}}`,
+			configMap:            true,
+			expectedProviderSpec: `{"kind":"AWSMachineProviderConfig","ami":{"id":"ami-new-arm"},"placement":{"region":"us-east-1"}}`,
+			expectedRelease:      "410.84.202205191234-0",
+			expectedEvent:        "BootImageUpdated",
+		},
+		{
+			name:                 "AWS up to date",
+			annotations:          optIn,
+			providerSpec:         `{"kind":"AWSMachineProviderConfig","ami":{"id":"ami-new"},"placement":{"region":"us-east-1"}}`,
+			configMap:            true,
+			expectedProviderSpec: `{"kind":"AWSMachineProviderConfig","ami":{"id":"ami-new"},"placement":{"region":"us-east-1"}}`,
+			expectedRelease:      "410.84.202205191234-0",
+		},
+		{
+			name:                 "AWS region without a boot image",
+			annotations:          optIn,
+			providerSpec:         `{"kind":"AWSMachineProviderConfig","ami":{"id":"ami-old"},"placement":{"region":"eu-west-1"}}`,
+			configMap:            true,
+			expectedProviderSpec: `{"kind":"AWSMachineProviderConfig","ami":{"id":"ami-old"},"placement":{"region":"eu-west-1"}}`,
+			expectedEvent:        "BootImageRefreshFailed",
+		},
+		{
+			name:                 "AWS with Ignition spec 3 user data",
+			annotations:          optIn,
+			providerSpec:         `{"kind":"AWSMachineProviderConfig","ami":{"id":"ami-old"},"placement":{"region":"us-east-1"},"userDataSecret":{"name":"worker-user-data"}}`,
+			configMap:            true,
+			secrets:              []runtime.Object{userDataSecret("worker-user-data", "3.2.0")},
+			expectedProviderSpec: `{"kind":"AWSMachineProviderConfig","ami":{"id":"ami-new"},"placement":{"region":"us-east-1"},"userDataSecret":{"name":"worker-user-data"}}`,
+			expectedRelease:      "410.84.202205191234-0",
+			expectedEvent:        "BootImageUpdated",
+		},
+		{
+			name:         "AWS with Ignition spec 2 user data",
+			annotations:  optIn,
+			providerSpec: `{"kind":"AWSMachineProviderConfig","ami":{"id":"ami-old"},"placement":{"region":"us-east-1"},"userDataSecret":{"name":"worker-user-data"}}`,
+			configMap:    true,
+			secrets: []runtime.Object{
+				userDataSecret("worker-user-data", "2.2.0"),
+				userDataSecret("worker-user-data-managed", "3.2.0"),
+			},
+			expectedProviderSpec: `{"kind":"AWSMachineProviderConfig","ami":{"id":"ami-new"},"placement":{"region":"us-east-1"},"userDataSecret":{"name":"worker-user-data-managed"}}`,
+			expectedRelease:      "410.84.202205191234-0",
+			expectedEvent:        "worker-user-data-managed",
+		},
+		{
+			name:                 "AWS with Ignition spec 2 user data without a managed secret",
+			annotations:          optIn,
+			providerSpec:         `{"kind":"AWSMachineProviderConfig","ami":{"id":"ami-old"},"placement":{"region":"us-east-1"},"userDataSecret":{"name":"worker-user-data"}}`,
+			configMap:            true,
+			secrets:              []runtime.Object{userDataSecret("worker-user-data", "2.2.0")},
+			expectedProviderSpec: `{"kind":"AWSMachineProviderConfig","ami":{"id":"ami-old"},"placement":{"region":"us-east-1"},"userDataSecret":{"name":"worker-user-data"}}`,
+			expectedEvent:        "BootImageRefreshFailed",
+		},
+		{
+			name:                 "GCP",
+			annotations:          optIn,
+			providerSpec:         `{"kind":"GCPMachineProviderSpec","disks":[{"boot":true,"image":"projects/rhcos-cloud/global/images/old","sizeGb":128},{"boot":false,"image":"data"}]}`,
+			configMap:            true,
+			expectedProviderSpec: `{"kind":"GCPMachineProviderSpec","disks":[{"boot":true,"image":"projects/rhcos-cloud/global/images/rhcos-410-84-202205191234-0-gcp-x86-64","sizeGb":128},{"boot":false,"image":"data"}]}`,
+			expectedRelease:      "410.84.202205191234-0",
+			expectedEvent:        "BootImageUpdated",
+		},
+		{
+			name:                 "vSphere",
+			annotations:          map[string]string{RefreshAnnotation: "true", VSphereTemplateFormatAnnotation: "rhcos-{release}"},
+			providerSpec:         `{"kind":"VSphereMachineProviderSpec","template":"rhcos-old"}`,
+			configMap:            true,
+			expectedProviderSpec: `{"kind":"VSphereMachineProviderSpec","template":"rhcos-410.84.202205191234-0"}`,
+			expectedRelease:      "410.84.202205191234-0",
+			expectedEvent:        "BootImageUpdated",
+		},
+		{
+			name:                 "vSphere without a template format",
+			annotations:          optIn,
+			providerSpec:         `{"kind":"VSphereMachineProviderSpec","template":"rhcos-old"}`,
+			configMap:            true,
+			expectedProviderSpec: `{"kind":"VSphereMachineProviderSpec","template":"rhcos-old"}`,
+			expectedEvent:        "BootImageRefreshFailed",
+		},
+		{
+			name:                 "without opt-in",
+			providerSpec:         `{"kind":"AWSMachineProviderConfig","ami":{"id":"ami-old"},"placement":{"region":"us-east-1"}}`,
+			configMap:            true,
+			expectedProviderSpec: `{"kind":"AWSMachineProviderConfig","ami":{"id":"ami-old"},"placement":{"region":"us-east-1"}}`,
+		},
+		{
+			name:                 "without boot images",
+			annotations:          optIn,
+			providerSpec:         `{"kind":"AWSMachineProviderConfig","ami":{"id":"ami-old"},"placement":{"region":"us-east-1"}}`,
+			expectedProviderSpec: `{"kind":"AWSMachineProviderConfig","ami":{"id":"ami-old"},"placement":{"region":"us-east-1"}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ms := newMachineSet(tc.annotations, tc.providerSpec)
+			if tc.nodeArch != "" {
+				ms.Spec.Template.Spec.ObjectMeta.Labels = map[string]string{archLabel: tc.nodeArch}
+			}
+			objects := []runtime.Object{ms}
+			if tc.configMap {
+				objects = append(objects, bootImagesConfigMap())
+			}
+			objects = append(objects, tc.secrets...)
+			r := newReconciler(objects...)
+
+			if _, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ms)}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := &machinev1.MachineSet{}
+			if err := r.client.Get(context.TODO(), client.ObjectKeyFromObject(ms), got); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			expected, actual := map[string]interface{}{}, map[string]interface{}{}
+			if err := yaml.Unmarshal([]byte(tc.expectedProviderSpec), &expected); err != nil {
+				t.Fatal(err)
+			}
+			if err := yaml.Unmarshal(got.Spec.Template.Spec.ProviderSpec.Value.Raw, &actual); err != nil {
+				t.Fatal(err)
+			}
+			if expectedJSON, actualJSON := mustMarshal(t, expected), mustMarshal(t, actual); expectedJSON != actualJSON {
+				t.Errorf("expected providerSpec %s, got: %s", expectedJSON, actualJSON)
+			}
+			if release := got.Annotations[ReleaseAnnotation]; release != tc.expectedRelease {
+				t.Errorf("expected release %q, got: %q", tc.expectedRelease, release)
+			}
+
+			events := r.recorder.(*record.FakeRecorder).Events
+			select {
+			case event := <-events:
+				if tc.expectedEvent == "" || !strings.Contains(event, tc.expectedEvent) {
+					t.Errorf("expected event %q, got: %q", tc.expectedEvent, event)
+				}
+			default:
+				if tc.expectedEvent != "" {
+					t.Errorf("expected event %q, got none", tc.expectedEvent)
+				}
+			}
+		})
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,118 @@
+package machine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/metrics"
+)
+
+const (
+	cloudAPIOperationCreate = "create"
+	cloudAPIOperationUpdate = "update"
+	cloudAPIOperationDelete = "delete"
+	cloudAPIOperationExists = "exists"
+
+	// unknownProvider and unknownErrorCode label the calls of machines without a provider spec kind,
+	// and the errors without a code.
+	unknownProvider  = "unknown"
+	unknownErrorCode = "Unknown"
+)
+
+// providerSpecKindSuffixes are trimmed from the kind of the provider spec to name its provider,
+// eg. AWSMachineProviderConfig is the aws provider.
+var providerSpecKindSuffixes = []string{"MachineProviderSpec", "MachineProviderConfig", "ProviderSpec"}
+
+// instrumentedActuator records the duration and the errors of the calls to the actuator, so that slow
+// reconciles caused by a slow or throttling cloud provider API can be told apart.
+type instrumentedActuator struct {
+	Actuator
+}
+
+func (a *instrumentedActuator) Create(ctx context.Context, m *machinev1.Machine) error {
+	return observeCloudAPICall(m, cloudAPIOperationCreate, func() error {
+		return a.Actuator.Create(ctx, m)
+	})
+}
+
+func (a *instrumentedActuator) Delete(ctx context.Context, m *machinev1.Machine) error {
+	return observeCloudAPICall(m, cloudAPIOperationDelete, func() error {
+		return a.Actuator.Delete(ctx, m)
+	})
+}
+
+func (a *instrumentedActuator) Update(ctx context.Context, m *machinev1.Machine) error {
+	return observeCloudAPICall(m, cloudAPIOperationUpdate, func() error {
+		return a.Actuator.Update(ctx, m)
+	})
+}
+
+func (a *instrumentedActuator) Exists(ctx context.Context, m *machinev1.Machine) (bool, error) {
+	var exists bool
+	err := observeCloudAPICall(m, cloudAPIOperationExists, func() error {
+		var err error
+		exists, err = a.Actuator.Exists(ctx, m)
+		return err
+	})
+	return exists, err
+}
+
+// observeCloudAPICall calls the actuator and updates the cloud API metrics of the operation.
+// Requeue requests of the actuator are not errors of the cloud provider API.
+func observeCloudAPICall(m *machinev1.Machine, operation string, call func() error) error {
+	provider := providerOf(m)
+	start := time.Now()
+	err := call()
+	metrics.CloudAPIRequestDurationSeconds.With(map[string]string{"provider": provider, "operation": operation}).Observe(time.Since(start).Seconds())
+
+	var requeueAfterError *RequeueAfterError
+	if err != nil && !errors.As(err, &requeueAfterError) {
+		metrics.CloudAPIErrorsTotal.With(map[string]string{"provider": provider, "operation": operation, "code": cloudAPIErrorCode(err)}).Inc()
+	}
+	return err
+}
+
+// providerOf returns the provider of the machine, named after the kind of its provider spec.
+func providerOf(m *machinev1.Machine) string {
+	if m.Spec.ProviderSpec.Value == nil || len(m.Spec.ProviderSpec.Value.Raw) == 0 {
+		return unknownProvider
+	}
+	typeMeta := struct {
+		Kind string `json:"kind"`
+	}{}
+	if err := json.Unmarshal(m.Spec.ProviderSpec.Value.Raw, &typeMeta); err != nil || typeMeta.Kind == "" {
+		return unknownProvider
+	}
+	for _, suffix := range providerSpecKindSuffixes {
+		if provider := strings.TrimSuffix(typeMeta.Kind, suffix); provider != typeMeta.Kind && provider != "" {
+			return strings.ToLower(provider)
+		}
+	}
+	return strings.ToLower(typeMeta.Kind)
+}
+
+// cloudAPIErrorCode returns the code of the error: the code of the cloud provider API error if the actuator
+// returns it, eg. Throttling, or its HTTP status code, and otherwise the reason of the machine error.
+func cloudAPIErrorCode(err error) string {
+	var codedErr interface{ Code() string }
+	if errors.As(err, &codedErr) && codedErr.Code() != "" {
+		return codedErr.Code()
+	}
+	var statusCodedErr interface{ StatusCode() int }
+	if errors.As(err, &statusCodedErr) && statusCodedErr.StatusCode() != 0 {
+		return strconv.Itoa(statusCodedErr.StatusCode())
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "Timeout"
+	}
+	var machineErr *MachineError
+	if errors.As(err, &machineErr) {
+		return string(machineErr.Reason)
+	}
+	return unknownErrorCode
+}
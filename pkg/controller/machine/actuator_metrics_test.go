@@ -0,0 +1,172 @@
+package machine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// codedError is a cloud provider API error with a code, as returned by the AWS SDK.
+type codedError struct {
+	code string
+}
+
+func (e *codedError) Error() string { return "cloud API error: " + e.code }
+func (e *codedError) Code() string  { return e.code }
+
+// statusCodedError is a cloud provider API error with an HTTP status code, as returned by the Azure SDK.
+type statusCodedError struct {
+	statusCode int
+}
+
+func (e *statusCodedError) Error() string   { return fmt.Sprintf("cloud API error: %d", e.statusCode) }
+func (e *statusCodedError) StatusCode() int { return e.statusCode }
+
+// failingActuator fails all its calls with err.
+type failingActuator struct {
+	err error
+}
+
+func (a *failingActuator) Create(context.Context, *machinev1.Machine) error { return a.err }
+func (a *failingActuator) Delete(context.Context, *machinev1.Machine) error { return a.err }
+func (a *failingActuator) Update(context.Context, *machinev1.Machine) error { return a.err }
+func (a *failingActuator) Exists(context.Context, *machinev1.Machine) (bool, error) {
+	return false, a.err
+}
+
+func cloudAPIErrorsCount(t *testing.T, provider, operation, code string) float64 {
+	metric := &dto.Metric{}
+	counter := metrics.CloudAPIErrorsTotal.With(map[string]string{"provider": provider, "operation": operation, "code": code})
+	if err := counter.(prometheus.Metric).Write(metric); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return metric.GetCounter().GetValue()
+}
+
+func cloudAPIRequestsCount(t *testing.T, provider, operation string) uint64 {
+	metric := &dto.Metric{}
+	observer := metrics.CloudAPIRequestDurationSeconds.With(map[string]string{"provider": provider, "operation": operation})
+	if err := observer.(prometheus.Metric).Write(metric); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return metric.GetHistogram().GetSampleCount()
+}
+
+func TestProviderOf(t *testing.T) {
+	testCases := []struct {
+		name             string
+		providerSpec     *runtime.RawExtension
+		expectedProvider string
+	}{
+		{
+			name:             "without provider spec",
+			expectedProvider: unknownProvider,
+		},
+		{
+			name:             "with an AWS provider spec",
+			providerSpec:     &runtime.RawExtension{Raw: []byte(`{"kind":"AWSMachineProviderConfig"}`)},
+			expectedProvider: "aws",
+		},
+		{
+			name:             "with a vSphere provider spec",
+			providerSpec:     &runtime.RawExtension{Raw: []byte(`{"kind":"VSphereMachineProviderSpec"}`)},
+			expectedProvider: "vsphere",
+		},
+		{
+			name:             "with an OpenStack provider spec",
+			providerSpec:     &runtime.RawExtension{Raw: []byte(`{"kind":"OpenstackProviderSpec"}`)},
+			expectedProvider: "openstack",
+		},
+		{
+			name:             "with a provider spec without kind",
+			providerSpec:     &runtime.RawExtension{Raw: []byte(`{}`)},
+			expectedProvider: unknownProvider,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			m := &machinev1.Machine{}
+			m.Spec.ProviderSpec.Value = tc.providerSpec
+			g.Expect(providerOf(m)).To(Equal(tc.expectedProvider))
+		})
+	}
+}
+
+func TestCloudAPIErrorCode(t *testing.T) {
+	testCases := []struct {
+		name         string
+		err          error
+		expectedCode string
+	}{
+		{
+			name:         "with a coded error",
+			err:          fmt.Errorf("failed to create instance: %w", &codedError{code: "RequestLimitExceeded"}),
+			expectedCode: "RequestLimitExceeded",
+		},
+		{
+			name:         "with a status coded error",
+			err:          &statusCodedError{statusCode: 429},
+			expectedCode: "429",
+		},
+		{
+			name:         "with a timeout",
+			err:          fmt.Errorf("failed to get instance: %w", context.DeadlineExceeded),
+			expectedCode: "Timeout",
+		},
+		{
+			name:         "with a machine error",
+			err:          InvalidMachineConfiguration("invalid"),
+			expectedCode: string(machinev1.InvalidConfigurationMachineError),
+		},
+		{
+			name:         "with another error",
+			err:          errors.New("failed"),
+			expectedCode: unknownErrorCode,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(cloudAPIErrorCode(tc.err)).To(Equal(tc.expectedCode))
+		})
+	}
+}
+
+func TestInstrumentedActuator(t *testing.T) {
+	g := NewWithT(t)
+
+	m := &machinev1.Machine{}
+	m.Spec.ProviderSpec.Value = &runtime.RawExtension{Raw: []byte(`{"kind":"GCPMachineProviderSpec"}`)}
+
+	createRequests := cloudAPIRequestsCount(t, "gcp", cloudAPIOperationCreate)
+	existsRequests := cloudAPIRequestsCount(t, "gcp", cloudAPIOperationExists)
+	createErrors := cloudAPIErrorsCount(t, "gcp", cloudAPIOperationCreate, "rateLimitExceeded")
+	updateErrors := cloudAPIErrorsCount(t, "gcp", cloudAPIOperationUpdate, unknownErrorCode)
+
+	actuator := &instrumentedActuator{Actuator: &failingActuator{err: &codedError{code: "rateLimitExceeded"}}}
+	g.Expect(actuator.Create(context.Background(), m)).ToNot(Succeed())
+	_, err := actuator.Exists(context.Background(), m)
+	g.Expect(err).To(HaveOccurred())
+
+	g.Expect(cloudAPIRequestsCount(t, "gcp", cloudAPIOperationCreate)).To(Equal(createRequests + 1))
+	g.Expect(cloudAPIRequestsCount(t, "gcp", cloudAPIOperationExists)).To(Equal(existsRequests + 1))
+	g.Expect(cloudAPIErrorsCount(t, "gcp", cloudAPIOperationCreate, "rateLimitExceeded")).To(Equal(createErrors + 1))
+
+	// Requeue requests are not errors of the cloud provider API.
+	actuator = &instrumentedActuator{Actuator: &failingActuator{err: &RequeueAfterError{RequeueAfter: time.Minute}}}
+	g.Expect(actuator.Update(context.Background(), m)).ToNot(Succeed())
+	g.Expect(cloudAPIErrorsCount(t, "gcp", cloudAPIOperationUpdate, unknownErrorCode)).To(Equal(updateErrors))
+}
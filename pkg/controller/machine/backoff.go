@@ -0,0 +1,115 @@
+package machine
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/machineapierrors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// DefaultBackoffBaseDelay is how long a machine is requeued after a first failure of the actuator
+	DefaultBackoffBaseDelay = 5 * time.Second
+
+	// DefaultBackoffMaxDelay caps how long a machine is requeued after consecutive failures of the actuator,
+	// before jitter
+	DefaultBackoffMaxDelay = 10 * time.Minute
+
+	// DefaultBackoffJitter is the maximum fraction of the delay added to spread the retries of machines
+	// failing at the same time
+	DefaultBackoffJitter = 0.1
+)
+
+// actuatorBackoff tracks the consecutive failures of the actuator for each machine, which is requeued
+// after a delay doubling with every failure, so that a machine whose instance keeps failing to be created
+// does not hammer the cloud provider API. Terminal errors, which retrying cannot fix, wait for the maximum
+// delay right away.
+type actuatorBackoff struct {
+	// baseDelay, maxDelay and jitter configure the delays, the defaults are used when zero.
+	// A negative jitter disables it.
+	baseDelay time.Duration
+	maxDelay  time.Duration
+	jitter    float64
+
+	lock     sync.Mutex
+	failures map[types.NamespacedName]int
+}
+
+// next records a failure of the actuator for the machine and returns how long to wait before retrying.
+func (b *actuatorBackoff) next(m *machinev1.Machine, terminal bool) time.Duration {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.failures == nil {
+		b.failures = map[types.NamespacedName]int{}
+	}
+	key := types.NamespacedName{Namespace: m.Namespace, Name: m.Name}
+	failures := b.failures[key]
+	b.failures[key] = failures + 1
+
+	baseDelay, maxDelay := b.baseDelay, b.maxDelay
+	if baseDelay <= 0 {
+		baseDelay = DefaultBackoffBaseDelay
+	}
+	if maxDelay <= 0 {
+		maxDelay = DefaultBackoffMaxDelay
+	}
+
+	delay := maxDelay
+	if d := float64(baseDelay) * math.Pow(2, float64(failures)); !terminal && d < float64(maxDelay) {
+		delay = time.Duration(d)
+	}
+
+	jitter := b.jitter
+	if jitter == 0 {
+		jitter = DefaultBackoffJitter
+	}
+	if jitter > 0 {
+		delay = wait.Jitter(delay, jitter)
+	}
+	return delay
+}
+
+// failuresOf returns the number of consecutive failures of the actuator for the machine.
+func (b *actuatorBackoff) failuresOf(m *machinev1.Machine) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.failures[types.NamespacedName{Namespace: m.Namespace, Name: m.Name}]
+}
+
+// reset forgets the failures of the actuator for the machine, after a successful call or once it is gone.
+func (b *actuatorBackoff) reset(m *machinev1.Machine) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	delete(b.failures, types.NamespacedName{Namespace: m.Namespace, Name: m.Name})
+}
+
+// requeueAfterActuatorError returns the result of a reconcile of the machine whose actuator failed with err.
+// The machine is requeued after the delay requested by the actuator, or else after its backoff delay.
+func (r *ReconcileMachine) requeueAfterActuatorError(m *machinev1.Machine, err error) (reconcile.Result, error) {
+	var requeueAfterError *RequeueAfterError
+	if errors.As(err, &requeueAfterError) {
+		klog.Infof("Actuator returned requeue-after error: %v", requeueAfterError)
+		return reconcile.Result{Requeue: true, RequeueAfter: requeueAfterError.RequeueAfter}, nil
+	}
+
+	// Terminal errors, unlike transient errors of the cloud provider API, eg. a throttled request,
+	// are not fixed by retrying.
+	terminal := machineapierrors.IsTerminal(err)
+	delay := r.backoff.next(m, terminal)
+	if terminal {
+		klog.Warningf("%v: actuator failed with a terminal error, retrying in %v: %v", m.GetName(), delay, err)
+	} else {
+		klog.Warningf("%v: actuator failed %d consecutive times, retrying in %v: %v", m.GetName(), r.backoff.failuresOf(m), delay, err)
+	}
+	return reconcile.Result{RequeueAfter: delay}, nil
+}
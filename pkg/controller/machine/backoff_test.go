@@ -0,0 +1,104 @@
+package machine
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/machineapierrors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestActuatorBackoff(t *testing.T) {
+	g := NewWithT(t)
+
+	m := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"}}
+	other := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"}}
+
+	b := &actuatorBackoff{baseDelay: time.Second, maxDelay: 10 * time.Second, jitter: -1}
+
+	// The delay doubles with every consecutive failure, up to the maximum delay.
+	var delays []time.Duration
+	for i := 0; i < 6; i++ {
+		delays = append(delays, b.next(m, false))
+	}
+	g.Expect(delays).To(Equal([]time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second,
+	}))
+	g.Expect(b.failuresOf(m)).To(Equal(6))
+
+	// The failures of other machines are tracked separately.
+	g.Expect(b.next(other, false)).To(Equal(time.Second))
+
+	// Terminal errors wait for the maximum delay right away.
+	g.Expect(b.next(other, true)).To(Equal(10 * time.Second))
+
+	// A success starts over from the base delay.
+	b.reset(m)
+	g.Expect(b.failuresOf(m)).To(BeZero())
+	g.Expect(b.next(m, false)).To(Equal(time.Second))
+}
+
+func TestActuatorBackoffDefaults(t *testing.T) {
+	g := NewWithT(t)
+
+	m := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"}}
+
+	b := &actuatorBackoff{}
+	delay := b.next(m, false)
+	g.Expect(delay).To(BeNumerically(">=", DefaultBackoffBaseDelay))
+	g.Expect(delay).To(BeNumerically("<=", time.Duration(float64(DefaultBackoffBaseDelay)*(1+DefaultBackoffJitter))))
+
+	for i := 0; i < 100; i++ {
+		delay = b.next(m, false)
+	}
+	g.Expect(delay).To(BeNumerically(">=", DefaultBackoffMaxDelay))
+	g.Expect(delay).To(BeNumerically("<=", time.Duration(float64(DefaultBackoffMaxDelay)*(1+DefaultBackoffJitter))))
+}
+
+func TestRequeueAfterActuatorError(t *testing.T) {
+	testCases := []struct {
+		name           string
+		err            error
+		expectedResult reconcile.Result
+	}{
+		{
+			name:           "with a requeue after error",
+			err:            &RequeueAfterError{RequeueAfter: time.Minute},
+			expectedResult: reconcile.Result{Requeue: true, RequeueAfter: time.Minute},
+		},
+		{
+			name:           "with a transient error",
+			err:            errors.New("throttled"),
+			expectedResult: reconcile.Result{RequeueAfter: time.Second},
+		},
+		{
+			name:           "with an unauthorized error",
+			err:            machineapierrors.Unauthorized("unable to login to vCenter"),
+			expectedResult: reconcile.Result{RequeueAfter: time.Second},
+		},
+		{
+			name:           "with a terminal error",
+			err:            InvalidMachineConfiguration("unknown instance type"),
+			expectedResult: reconcile.Result{RequeueAfter: time.Hour},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			m := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"}}
+			r := &ReconcileMachine{
+				backoff: actuatorBackoff{baseDelay: time.Second, maxDelay: time.Hour, jitter: -1},
+			}
+
+			result, err := r.requeueAfterActuatorError(m, tc.err)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(result).To(Equal(tc.expectedResult))
+		})
+	}
+}
@@ -24,9 +24,10 @@ import (
 	"time"
 
 	machinev1 "github.com/openshift/api/machine/v1beta1"
-	"github.com/openshift/machine-api-operator/pkg/metrics"
 	"github.com/openshift/machine-api-operator/pkg/util"
 	"github.com/openshift/machine-api-operator/pkg/util/conditions"
+	"github.com/openshift/machine-api-operator/pkg/util/machineapierrors"
+	"github.com/openshift/machine-api-operator/pkg/util/machines"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -63,6 +64,10 @@ const (
 	// MachineInstanceStateAnnotationName as annotation name for a machine instance state
 	MachineInstanceStateAnnotationName = "machine.openshift.io/instance-state"
 
+	// MachineInstanceBootTimeAnnotationName as annotation name for the time the machine instance was last booted,
+	// as reported by the provider
+	MachineInstanceBootTimeAnnotationName = "machine.openshift.io/instance-boot-time"
+
 	// MachineInstanceTypeLabelName as annotation name for a machine instance type
 	MachineInstanceTypeLabelName = "machine.openshift.io/instance-type"
 
@@ -101,18 +106,55 @@ const (
 
 var DefaultActuator Actuator
 
+// Options configures the machine controller.
+type Options struct {
+	// MaxConcurrentReconciles is the number of machines reconciled, and so drained, in parallel.
+	// The actuator must be safe for concurrent use when it is greater than 1. Defaults to 1.
+	MaxConcurrentReconciles int
+
+	// MaxConcurrentDrainsPerZone limits the number of machines of an availability zone draining
+	// their node at the same time. Zero means no limit.
+	MaxConcurrentDrainsPerZone int
+
+	// StuckProvisioningThreshold and StuckDeletingThreshold are how long a machine may stay in
+	// the Provisioning and Deleting phases before it is flagged as stuck. Zero means the default
+	// threshold, a negative threshold disables the check.
+	StuckProvisioningThreshold time.Duration
+	StuckDeletingThreshold     time.Duration
+
+	// BackoffBaseDelay and BackoffMaxDelay are how long a machine is requeued after a first failure of
+	// the actuator, doubling with every consecutive failure up to the maximum delay. BackoffJitter is the
+	// maximum fraction of the delay added to it. Zero means the default, a negative jitter disables it.
+	BackoffBaseDelay time.Duration
+	BackoffMaxDelay  time.Duration
+	BackoffJitter    float64
+}
+
 func AddWithActuator(mgr manager.Manager, actuator Actuator) error {
-	return add(mgr, newReconciler(mgr, actuator))
+	return AddWithActuatorOpts(mgr, actuator, Options{})
+}
+
+// AddWithActuatorOpts adds the machine controller to mgr, configured by opts.
+func AddWithActuatorOpts(mgr manager.Manager, actuator Actuator, opts Options) error {
+	return add(mgr, newReconciler(mgr, actuator, opts), opts)
 }
 
 // newReconciler returns a new reconcile.Reconciler
-func newReconciler(mgr manager.Manager, actuator Actuator) reconcile.Reconciler {
+func newReconciler(mgr manager.Manager, actuator Actuator, opts Options) reconcile.Reconciler {
 	r := &ReconcileMachine{
-		Client:        mgr.GetClient(),
-		eventRecorder: mgr.GetEventRecorderFor("machine-controller"),
-		config:        mgr.GetConfig(),
-		scheme:        mgr.GetScheme(),
-		actuator:      actuator,
+		Client:                     mgr.GetClient(),
+		eventRecorder:              mgr.GetEventRecorderFor("machine-controller"),
+		config:                     mgr.GetConfig(),
+		scheme:                     mgr.GetScheme(),
+		actuator:                   &instrumentedActuator{Actuator: actuator},
+		maxConcurrentDrainsPerZone: opts.MaxConcurrentDrainsPerZone,
+		stuckProvisioningThreshold: stuckThresholdOrDefault(opts.StuckProvisioningThreshold, DefaultStuckProvisioningThreshold),
+		stuckDeletingThreshold:     stuckThresholdOrDefault(opts.StuckDeletingThreshold, DefaultStuckDeletingThreshold),
+		backoff: actuatorBackoff{
+			baseDelay: opts.BackoffBaseDelay,
+			maxDelay:  opts.BackoffMaxDelay,
+			jitter:    opts.BackoffJitter,
+		},
 	}
 	return r
 }
@@ -125,9 +167,12 @@ func stringPointerDeref(stringPointer *string) string {
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
-func add(mgr manager.Manager, r reconcile.Reconciler) error {
+func add(mgr manager.Manager, r reconcile.Reconciler, opts Options) error {
 	// Create a new controller
-	c, err := controller.New("machine_controller", mgr, controller.Options{Reconciler: r})
+	c, err := controller.New("machine_controller", mgr, controller.Options{
+		Reconciler:              r,
+		MaxConcurrentReconciles: opts.MaxConcurrentReconciles,
+	})
 	if err != nil {
 		return err
 	}
@@ -149,6 +194,25 @@ type ReconcileMachine struct {
 
 	actuator Actuator
 
+	// drainSlots limits the number of concurrent drains of the machines of a MachineSet.
+	drainSlots drainSlots
+
+	// zoneDrainSlots limits the number of concurrent drains of the machines of an availability zone
+	// to maxConcurrentDrainsPerZone, unless zero.
+	zoneDrainSlots             drainSlots
+	maxConcurrentDrainsPerZone int
+
+	// stuckProvisioningThreshold and stuckDeletingThreshold flag machines stuck in the Provisioning
+	// and Deleting phases, unless zero.
+	stuckProvisioningThreshold time.Duration
+	stuckDeletingThreshold     time.Duration
+
+	// backoff delays the retries of the machines whose actuator calls keep failing.
+	backoff actuatorBackoff
+
+	// drainNodeFunc is used to mock draining in testing. It should be nil in production.
+	drainNodeFunc func(context.Context, *machinev1.Machine) error
+
 	// nowFunc is used to mock time in testing. It should be nil in production.
 	nowFunc func() time.Time
 }
@@ -170,6 +234,22 @@ func (r *ReconcileMachine) Reconcile(ctx context.Context, request reconcile.Requ
 		return reconcile.Result{}, err
 	}
 
+	// Return early if the machine is paused, reconciliation resumes once the annotation is removed.
+	if paused, err := r.reconcilePaused(ctx, m); err != nil || paused {
+		if paused {
+			klog.Infof("%v: reconciliation is paused", m.GetName())
+		}
+		return reconcile.Result{}, err
+	}
+
+	result, err := r.reconcile(ctx, m)
+	if err != nil {
+		return result, err
+	}
+	return r.requeueBeforeStuck(m, result), nil
+}
+
+func (r *ReconcileMachine) reconcile(ctx context.Context, m *machinev1.Machine) (reconcile.Result, error) {
 	// Implement controller logic here
 	machineName := m.GetName()
 	klog.Infof("%v: reconciling Machine", machineName)
@@ -224,33 +304,94 @@ func (r *ReconcileMachine) Reconcile(ctx context.Context, request reconcile.Requ
 		// deleted without a manual intervention.
 		if _, exists := m.ObjectMeta.Annotations[ExcludeNodeDrainingAnnotation]; !exists && m.Status.NodeRef != nil {
 			// pre-drain.delete lifecycle hook
-			// Return early without error, will requeue if/when the hook owner removes the annotation.
+			// Return early without error, will requeue if/when the hook owner removes the annotation,
+			// or once the hooks time out.
 			if len(m.Spec.LifecycleHooks.PreDrain) > 0 {
-				klog.Infof("%v: not draining machine: lifecycle blocked by pre-drain hook", machineName)
-				return reconcile.Result{}, nil
+				if blocked, result := r.lifecycleHooksBlock(m, machinev1.MachineDrainable, m.Spec.LifecycleHooks.PreDrain, originalConditions); blocked {
+					klog.Infof("%v: not draining machine: lifecycle blocked by pre-drain hook", machineName)
+					return result, nil
+				}
+				klog.Infof("%v: draining machine: pre-drain hooks timed out", machineName)
 			}
 
-			if err := r.drainNode(ctx, m); err != nil {
-				klog.Errorf("%v: failed to drain node for machine: %v", machineName, err)
+			acquired, waitingFor, err := r.acquireDrainSlot(ctx, m)
+			if err != nil {
+				klog.Errorf("%v: failed to acquire a drain slot for machine: %v", machineName, err)
+				return reconcile.Result{}, err
+			}
+			if !acquired {
+				klog.Infof("%v: not draining machine: waiting for other machines of the %s to finish draining", machineName, waitingFor)
 				conditions.Set(m, conditions.FalseCondition(
 					machinev1.MachineDrained,
-					machinev1.MachineDrainError,
-					machinev1.ConditionSeverityWarning,
-					"could not drain machine: %v", err,
+					MachineDrainPendingReason,
+					machinev1.ConditionSeverityInfo,
+					"Waiting for other machines of the %s to finish draining", waitingFor,
 				))
-				return delayIfRequeueAfterError(err)
+				if err := r.updateStatus(ctx, m, phaseDeleting, nil, originalConditions); err != nil {
+					return reconcile.Result{}, err
+				}
+				return reconcile.Result{RequeueAfter: drainPendingRequeueAfter}, nil
+			}
+
+			if err := r.runDrain(ctx, m); err != nil {
+				klog.Errorf("%v: failed to drain node for machine: %v", machineName, err)
+				policy := drainPolicyOf(m)
+				var blockedErr *pdbBlockedError
+				blockedByPDB := errors.As(err, &blockedErr)
+				if blockedByPDB {
+					r.reportDrainBlockedByPDB(m, blockedErr, originalConditions)
+				}
+				if !r.drainTimedOut(m, policy) {
+					conditions.Set(m, conditions.FalseCondition(
+						machinev1.MachineDrained,
+						machinev1.MachineDrainError,
+						machinev1.ConditionSeverityWarning,
+						"could not drain machine: %v", err,
+					))
+					if blockedByPDB {
+						if err := r.updateStatus(ctx, m, phaseDeleting, nil, originalConditions); err != nil {
+							return reconcile.Result{}, err
+						}
+					}
+					return delayIfRequeueAfterError(err)
+				}
+
+				r.reportDrainTimeout(m, policy, err, originalConditions)
+				if !policy.force {
+					klog.Warningf("%v: drain timed out after %v", machineName, policy.timeout)
+					if err := r.updateStatus(ctx, m, phaseDeleting, nil, originalConditions); err != nil {
+						return reconcile.Result{}, err
+					}
+					return delayIfRequeueAfterError(err)
+				}
+				klog.Warningf("%v: drain timed out after %v, force-completing it", machineName, policy.timeout)
+				r.releaseDrainSlot(m)
+				conditions.MarkTrue(m, machinev1.MachineDrainable)
+			} else {
+				r.releaseDrainSlot(m)
+				conditions.MarkTrue(m, machinev1.MachineDrainable)
+				conditions.Set(m, conditions.TrueCondition(machinev1.MachineDrained))
 			}
-			conditions.Set(m, conditions.TrueCondition(machinev1.MachineDrained))
 		}
 
 		// pre-term.delete lifecycle hook
-		// Return early without error, will requeue if/when the hook owner removes the annotation.
+		// Return early without error, will requeue if/when the hook owner removes the annotation,
+		// or once the hooks time out.
 		if len(m.Spec.LifecycleHooks.PreTerminate) > 0 {
-			klog.Infof("%v: not deleting machine: lifecycle blocked by pre-terminate hook", machineName)
-			return reconcile.Result{}, nil
+			if blocked, result := r.lifecycleHooksBlock(m, machinev1.MachineTerminable, m.Spec.LifecycleHooks.PreTerminate, originalConditions); blocked {
+				klog.Infof("%v: not deleting machine: lifecycle blocked by pre-terminate hook", machineName)
+				// Record the end of the drain, from which the pre-terminate hooks time out.
+				if err := r.updateStatus(ctx, m, phaseDeleting, nil, originalConditions); err != nil {
+					return reconcile.Result{}, err
+				}
+				return result, nil
+			}
+			klog.Infof("%v: deleting machine: pre-terminate hooks timed out", machineName)
 		}
 
-		if err := r.actuator.Delete(ctx, m); err != nil {
+		err := r.actuator.Delete(ctx, m)
+		r.recordActuatorOperation(ctx, m, lastOperationTypeDelete, err, lastOperationStateRequested, "Requested the termination of the instance")
+		if err != nil {
 			// isInvalidMachineConfiguration will take care of the case where the
 			// configuration is invalid from the beginning. len(m.Status.Addresses) > 0
 			// will handle the case when a machine configuration was invalidated
@@ -259,20 +400,21 @@ func (r *ReconcileMachine) Reconcile(ctx context.Context, request reconcile.Requ
 			// was sent and before a list of node addresses was set.
 			if len(m.Status.Addresses) > 0 || !isInvalidMachineConfigurationError(err) {
 				klog.Errorf("%v: failed to delete machine: %v", machineName, err)
-				return delayIfRequeueAfterError(err)
+				return r.requeueAfterActuatorError(m, err)
 			}
 		}
 
 		instanceExists, err := r.actuator.Exists(ctx, m)
 		if err != nil {
 			klog.Errorf("%v: failed to check if machine exists: %v", machineName, err)
-			return reconcile.Result{}, err
+			return r.requeueAfterActuatorError(m, err)
 		}
 
 		if instanceExists {
 			klog.V(3).Infof("%v: can't proceed deleting machine while cloud instance is being terminated, requeuing", machineName)
 			return reconcile.Result{RequeueAfter: requeueAfter}, nil
 		}
+		r.recordLastOperation(ctx, m, lastOperationTypeDelete, lastOperationStateSuccessful, "Terminated the instance")
 
 		if m.Status.NodeRef != nil {
 			klog.Infof("%v: deleting node %q for machine", machineName, m.Status.NodeRef.Name)
@@ -282,6 +424,9 @@ func (r *ReconcileMachine) Reconcile(ctx context.Context, request reconcile.Requ
 			}
 		}
 
+		// The machine may have skipped draining after taking a drain slot, e.g. once its node is gone.
+		r.releaseDrainSlot(m)
+
 		// Remove finalizer on successful deletion.
 		m.ObjectMeta.Finalizers = util.Filter(m.ObjectMeta.Finalizers, machinev1.MachineFinalizer)
 		if err := r.Client.Update(ctx, m); err != nil {
@@ -289,12 +434,14 @@ func (r *ReconcileMachine) Reconcile(ctx context.Context, request reconcile.Requ
 			return reconcile.Result{}, err
 		}
 
+		r.backoff.reset(m)
 		klog.Infof("%v: machine deletion successful", machineName)
 		return reconcile.Result{}, nil
 	}
 
 	if machineIsFailed(m) {
 		klog.Warningf("%v: machine has gone %q phase. It won't reconcile", machineName, phaseFailed)
+		r.backoff.reset(m)
 		return reconcile.Result{}, nil
 	}
 
@@ -312,20 +459,23 @@ func (r *ReconcileMachine) Reconcile(ctx context.Context, request reconcile.Requ
 			klog.Errorf("%v: error patching status: %v", machineName, patchErr)
 		}
 
-		return reconcile.Result{}, err
+		return r.requeueAfterActuatorError(m, err)
 	}
 
 	if instanceExists {
 		klog.Infof("%v: reconciling machine triggers idempotent update", machineName)
-		if err := r.actuator.Update(ctx, m); err != nil {
-			klog.Errorf("%v: error updating machine: %v, retrying in %v seconds", machineName, err, requeueAfter)
+		err := r.actuator.Update(ctx, m)
+		r.recordActuatorOperation(ctx, m, lastOperationTypeUpdate, err, lastOperationStateSuccessful, "Updated the instance")
+		if err != nil {
+			klog.Errorf("%v: error updating machine: %v", machineName, err)
 
 			if patchErr := r.updateStatus(ctx, m, pointer.StringPtrDerefOr(m.Status.Phase, ""), nil, originalConditions); patchErr != nil {
 				klog.Errorf("%v: error patching status: %v", machineName, patchErr)
 			}
 
-			return reconcile.Result{RequeueAfter: requeueAfter}, nil
+			return r.requeueAfterActuatorError(m, err)
 		}
+		r.backoff.reset(m)
 
 		// Mark the instance exists condition true after actuator update else the update may overwrite changes
 		conditions.MarkTrue(m, machinev1.InstanceExistsCondition)
@@ -383,22 +533,43 @@ func (r *ReconcileMachine) Reconcile(ctx context.Context, request reconcile.Requ
 		return reconcile.Result{RequeueAfter: requeueAfter}, nil
 	}
 
+	// The status of provisioning machines is not updated while their creation is retried, unless they get stuck
+	if phase := stringPointerDeref(m.Status.Phase); phase == phaseProvisioning {
+		r.setStuckCondition(m, phase)
+		if stuckConditionChanged(m, originalConditions) {
+			if err := r.updateStatus(ctx, m, phase, nil, originalConditions); err != nil {
+				return reconcile.Result{}, err
+			}
+		}
+	}
+
 	klog.Infof("%v: reconciling machine triggers idempotent create", machineName)
-	if err := r.actuator.Create(ctx, m); err != nil {
+	err = r.actuator.Create(ctx, m)
+	r.recordActuatorOperation(ctx, m, lastOperationTypeCreate, err, lastOperationStateSuccessful, "Created the instance")
+	if err != nil {
 		klog.Warningf("%v: failed to create machine: %v", machineName, err)
-		if isInvalidMachineConfigurationError(err) {
+		if machineapierrors.IsTerminal(err) {
 			if err := r.updateStatus(ctx, m, phaseFailed, err, originalConditions); err != nil {
 				return reconcile.Result{}, err
 			}
 			return reconcile.Result{}, nil
 		}
-		return delayIfRequeueAfterError(err)
+		return r.requeueAfterActuatorError(m, err)
 	}
+	r.backoff.reset(m)
 
 	klog.Infof("%v: created instance, requeuing", machineName)
 	return reconcile.Result{RequeueAfter: requeueAfter}, nil
 }
 
+// runDrain drains the node of the machine, using drainNodeFunc if it is set.
+func (r *ReconcileMachine) runDrain(ctx context.Context, machine *machinev1.Machine) error {
+	if r.drainNodeFunc != nil {
+		return r.drainNodeFunc(ctx, machine)
+	}
+	return r.drainNode(ctx, machine)
+}
+
 func (r *ReconcileMachine) drainNode(ctx context.Context, machine *machinev1.Machine) error {
 	kubeClient, err := kubernetes.NewForConfig(r.config)
 	if err != nil {
@@ -454,7 +625,7 @@ func (r *ReconcileMachine) drainNode(ctx context.Context, machine *machinev1.Mac
 	if err := drain.RunNodeDrain(drainer, node.Name); err != nil {
 		// Machine still tries to terminate after drain failure
 		klog.Warningf("drain failed for machine %q: %v", machine.Name, err)
-		return &RequeueAfterError{RequeueAfter: 20 * time.Second}
+		return diagnoseDrainFailure(ctx, drainer, node, &RequeueAfterError{RequeueAfter: 20 * time.Second})
 	}
 
 	klog.Infof("drain successful for machine %q", machine.Name)
@@ -486,12 +657,9 @@ func delayIfRequeueAfterError(err error) (reconcile.Result, error) {
 }
 
 func isInvalidMachineConfigurationError(err error) bool {
-	var machineError *MachineError
-	if errors.As(err, &machineError) {
-		if machineError.Reason == machinev1.InvalidConfigurationMachineError {
-			klog.Infof("Actuator returned invalid configuration error: %v", machineError)
-			return true
-		}
+	if machineapierrors.HasReason(err, machinev1.InvalidConfigurationMachineError) {
+		klog.Infof("Actuator returned invalid configuration error: %v", err)
+		return true
 	}
 	return false
 }
@@ -500,12 +668,16 @@ func isInvalidMachineConfigurationError(err error) bool {
 // Because the conditions are set on the machine outside of this function, we must pass the original state of the
 // machine conditions so that the diff can be calculated properly within this function.
 func (r *ReconcileMachine) updateStatus(ctx context.Context, machine *machinev1.Machine, phase string, failureCause error, originalConditions []machinev1.Condition) error {
-	if stringPointerDeref(machine.Status.Phase) != phase {
+	previousPhase := stringPointerDeref(machine.Status.Phase)
+	previousEnteredAt, previousKnown := machines.PhaseEnteredAt(machine, previousPhase)
+	if previousPhase != phase {
 		klog.V(3).Infof("%v: going into phase %q", machine.GetName(), phase)
 	}
 
 	// Ensure the lifecycle hook conditions are accurate whenever the status is updated
 	setLifecycleHookConditions(machine)
+	setLifecycleHookTimeoutConditions(machine, r.now())
+	r.setStuckCondition(machine, phase)
 
 	// Conditions need to be deep copied as they are set outside of this function.
 	// They will be restored after any updates to the base (done by patching annotations).
@@ -522,6 +694,14 @@ func (r *ReconcileMachine) updateStatus(ctx context.Context, machine *machinev1.
 		}
 	}
 
+	now := r.now()
+	if previousPhase != phase {
+		// The status is still updated when the transition cannot be recorded, its duration is then not observed.
+		if err := r.patchPhaseTransition(ctx, machine, phase, now); err != nil {
+			klog.Errorf("Failed to record phase transition of machine %q: %v", machine.GetName(), err)
+		}
+	}
+
 	// To ensure conditions can be patched properly, set the original conditions on the baseMachine.
 	// This allows the difference to be calculated as part of the patch.
 	baseMachine := machine.DeepCopy()
@@ -555,8 +735,8 @@ func (r *ReconcileMachine) updateStatus(ctx context.Context, machine *machinev1.
 
 	if !reflect.DeepEqual(baseMachine.Status, machine.Status) {
 		// Something on the status has been changed this reconcile
-		now := metav1.NewTime(r.now())
-		machine.Status.LastUpdated = &now
+		lastUpdated := metav1.NewTime(now)
+		machine.Status.LastUpdated = &lastUpdated
 	}
 
 	if err := r.Client.Status().Patch(ctx, machine, baseToPatch); err != nil {
@@ -564,14 +744,12 @@ func (r *ReconcileMachine) updateStatus(ctx context.Context, machine *machinev1.
 		return err
 	}
 
-	// Update the metric after everything else has succeeded to prevent duplicate
+	// Update the metrics after everything else has succeeded to prevent duplicate
 	// entries when there are failures
-	if phase != phaseDeleting {
-		// Apart from deleting, update the transition metric
-		// Deleting would always end up in the infinite bucket
-		timeElapsed := r.now().Sub(machine.GetCreationTimestamp().Time).Seconds()
-		metrics.MachinePhaseTransitionSeconds.With(map[string]string{"phase": phase}).Observe(timeElapsed)
+	if previousPhase != phase {
+		observePhaseTransition(machine, previousPhase, phase, previousEnteredAt, previousKnown, now)
 	}
+	observeStuck(machine, phase, originalConditions)
 
 	return nil
 }
@@ -652,7 +830,8 @@ func setLifecycleHookConditions(m *machinev1.Machine) {
 			machinev1.ConditionSeverityWarning,
 			"Drain operation currently blocked by: %+v", m.Spec.LifecycleHooks.PreDrain,
 		))
-	} else {
+	} else if !hasConditionReason(m.Status.Conditions, machinev1.MachineDrainable, MachineBlockedByPDBReason) {
+		// The drain clears the PodDisruptionBudget diagnostics once it completes
 		conditions.MarkTrue(m, machinev1.MachineDrainable)
 	}
 
@@ -714,6 +714,14 @@ func TestUpdateStatus(t *testing.T) {
 			g.Expect(got.Status.Conditions).To(conditions.MatchConditions(tc.conditions))
 			g.Expect(machine.Status.Conditions).To(conditions.MatchConditions(tc.conditions))
 
+			// Phase transitions are recorded whenever the phase changes, regardless of the test case
+			for _, m := range []*machinev1.Machine{&got, machine} {
+				g.Expect(m.GetAnnotations()).To(HaveKey(PhaseTransitionsAnnotation))
+				delete(m.Annotations, PhaseTransitionsAnnotation)
+				if len(m.Annotations) == 0 {
+					m.Annotations = nil
+				}
+			}
 			g.Expect(got.GetAnnotations()).To(Equal(tc.annotations))
 			g.Expect(machine.GetAnnotations()).To(Equal(tc.annotations))
 
@@ -0,0 +1,204 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/conditions"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// MaxConcurrentDrainsAnnotation is set on a MachineSet to limit the number of its machines
+	// which drain their node at the same time while being deleted. It is copied to the machines
+	// the MachineSet creates, which are limited by the value they carry
+	MaxConcurrentDrainsAnnotation = "machine.openshift.io/max-concurrent-drains"
+
+	// MachineDrainPendingReason is the reason of the Drained condition of machines waiting for
+	// other machines of their MachineSet, or of their availability zone, to finish draining
+	MachineDrainPendingReason = "DrainPending"
+
+	drainPendingRequeueAfter = 20 * time.Second
+)
+
+// drainSlots tracks the machines holding a drain slot, by owning MachineSet or availability zone.
+// A drain spans several reconciles, as evictions are retried until they succeed,
+// so a machine keeps its slot until its node is drained.
+type drainSlots struct {
+	lock    sync.Mutex
+	holders map[types.NamespacedName]sets.String
+}
+
+// tryAcquire takes a drain slot of the owner for the machine, unless limit slots are taken already.
+// Acquiring a slot the machine holds already always succeeds.
+func (s *drainSlots) tryAcquire(owner types.NamespacedName, machineName string, limit int) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.holders == nil {
+		s.holders = map[types.NamespacedName]sets.String{}
+	}
+	holders, ok := s.holders[owner]
+	if !ok {
+		holders = sets.NewString()
+		s.holders[owner] = holders
+	}
+	if holders.Has(machineName) {
+		return true
+	}
+	if holders.Len() >= limit {
+		return false
+	}
+	holders.Insert(machineName)
+	return true
+}
+
+// release frees the drain slot of the owner held by the machine, if any.
+func (s *drainSlots) release(owner types.NamespacedName, machineName string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	holders, ok := s.holders[owner]
+	if !ok {
+		return
+	}
+	holders.Delete(machineName)
+	if holders.Len() == 0 {
+		delete(s.holders, owner)
+	}
+}
+
+// list returns the machines holding a drain slot of the owner.
+func (s *drainSlots) list(owner types.NamespacedName) []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.holders[owner].List()
+}
+
+// drainSlotZone returns the availability zone of the machine, if known, namespaced by the machine namespace.
+func drainSlotZone(m *machinev1.Machine) (types.NamespacedName, bool) {
+	zone := m.Labels[MachineAZLabelName]
+	if zone == "" {
+		return types.NamespacedName{}, false
+	}
+	return types.NamespacedName{Namespace: m.Namespace, Name: zone}, true
+}
+
+// drainSlotOwner returns the MachineSet owning the machine, if any.
+func drainSlotOwner(m *machinev1.Machine) (types.NamespacedName, bool) {
+	ref := metav1.GetControllerOf(m)
+	if ref == nil || ref.Kind != "MachineSet" {
+		return types.NamespacedName{}, false
+	}
+	return types.NamespacedName{Namespace: m.Namespace, Name: ref.Name}, true
+}
+
+// acquireDrainSlot returns whether the machine may drain its node now, or else whether it waits for
+// its "zone" or its "MachineSet". Machines of an availability zone wait until one of the zone's drain slots
+// is free, when the controller limits the concurrent drains per zone. Machines of a MachineSet carrying
+// the max-concurrent-drains annotation wait until one of the MachineSet's drain slots is free.
+// Machines without a zone or an owning MachineSet, or without a limit, are never held back.
+func (r *ReconcileMachine) acquireDrainSlot(ctx context.Context, m *machinev1.Machine) (bool, string, error) {
+	zone, zoned := drainSlotZone(m)
+	if zoned && r.maxConcurrentDrainsPerZone > 0 {
+		acquired, err := r.tryAcquireDrainSlot(ctx, &r.zoneDrainSlots, zone, m, r.maxConcurrentDrainsPerZone)
+		if err != nil || !acquired {
+			return false, fmt.Sprintf("zone %q", zone.Name), err
+		}
+	}
+
+	acquired, err := r.acquireMachineSetDrainSlot(ctx, m)
+	if err != nil || !acquired {
+		// Let other machines of the zone drain meanwhile
+		if zoned {
+			r.zoneDrainSlots.release(zone, m.GetName())
+		}
+		return false, "MachineSet", err
+	}
+	return true, "", nil
+}
+
+// acquireMachineSetDrainSlot returns whether the machine holds a drain slot of its MachineSet,
+// or is not limited by it. The limit is read from the machine, as the MachineSet may be deleted
+// along with its machines. An invalid limit lets a single machine drain at a time.
+func (r *ReconcileMachine) acquireMachineSetDrainSlot(ctx context.Context, m *machinev1.Machine) (bool, error) {
+	owner, ok := drainSlotOwner(m)
+	if !ok {
+		return true, nil
+	}
+
+	value, ok := m.Annotations[MaxConcurrentDrainsAnnotation]
+	if !ok {
+		return true, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 {
+		klog.Warningf("%v: invalid %s annotation %q: must be a positive integer, draining a single machine of MachineSet %q at a time", m.GetName(), MaxConcurrentDrainsAnnotation, value, owner.Name)
+		limit = 1
+	}
+
+	return r.tryAcquireDrainSlot(ctx, &r.drainSlots, owner, m, limit)
+}
+
+// tryAcquireDrainSlot takes one of the limit drain slots of the owner for the machine.
+func (r *ReconcileMachine) tryAcquireDrainSlot(ctx context.Context, slots *drainSlots, owner types.NamespacedName, m *machinev1.Machine, limit int) (bool, error) {
+	if slots.tryAcquire(owner, m.GetName(), limit) {
+		return true, nil
+	}
+
+	// Slots are only released by the machine holding them once its node is drained.
+	// Free the slots of machines which stopped draining in the meantime, for example because they
+	// were deleted or are no longer linked to a node, before giving up.
+	for _, holder := range slots.list(owner) {
+		releasable, err := r.drainSlotReleasable(ctx, types.NamespacedName{Namespace: owner.Namespace, Name: holder})
+		if err != nil {
+			return false, err
+		}
+		if releasable {
+			klog.V(3).Infof("%v: releasing stale drain slot of machine %q", m.GetName(), holder)
+			slots.release(owner, holder)
+		}
+	}
+
+	return slots.tryAcquire(owner, m.GetName(), limit), nil
+}
+
+// drainSlotReleasable returns whether the machine holding a drain slot is no longer draining.
+func (r *ReconcileMachine) drainSlotReleasable(ctx context.Context, key types.NamespacedName) (bool, error) {
+	holder := &machinev1.Machine{}
+	if err := r.Client.Get(ctx, key, holder); err != nil {
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	if holder.DeletionTimestamp.IsZero() || holder.Status.NodeRef == nil {
+		return true, nil
+	}
+	if _, exists := holder.Annotations[ExcludeNodeDrainingAnnotation]; exists {
+		return true, nil
+	}
+	drained := conditions.Get(holder, machinev1.MachineDrained)
+	return drained != nil && drained.Status == corev1.ConditionTrue, nil
+}
+
+// releaseDrainSlot frees the drain slots held by the machine, if any.
+func (r *ReconcileMachine) releaseDrainSlot(m *machinev1.Machine) {
+	if owner, ok := drainSlotOwner(m); ok {
+		r.drainSlots.release(owner, m.GetName())
+	}
+	if zone, ok := drainSlotZone(m); ok {
+		r.zoneDrainSlots.release(zone, m.GetName())
+	}
+}
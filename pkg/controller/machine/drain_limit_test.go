@@ -0,0 +1,471 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/conditions"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestDrainSlots(t *testing.T) {
+	owner := types.NamespacedName{Namespace: "default", Name: "machineset"}
+	slots := drainSlots{}
+
+	if !slots.tryAcquire(owner, "a", 2) {
+		t.Errorf("expected machine a to acquire a free slot")
+	}
+	if !slots.tryAcquire(owner, "a", 2) {
+		t.Errorf("expected machine a to keep its slot")
+	}
+	if !slots.tryAcquire(owner, "b", 2) {
+		t.Errorf("expected machine b to acquire a free slot")
+	}
+	if slots.tryAcquire(owner, "c", 2) {
+		t.Errorf("expected machine c not to acquire a slot while all slots are taken")
+	}
+	if !slots.tryAcquire(types.NamespacedName{Namespace: "default", Name: "other"}, "c", 2) {
+		t.Errorf("expected the slots of another MachineSet to be independent")
+	}
+
+	slots.release(owner, "a")
+	if !slots.tryAcquire(owner, "c", 2) {
+		t.Errorf("expected machine c to acquire the released slot")
+	}
+
+	slots.release(owner, "unknown")
+	if got := slots.list(owner); len(got) != 2 {
+		t.Errorf("expected 2 slots to be held, got: %v", got)
+	}
+}
+
+func TestReconcileDrainLimit(t *testing.T) {
+	machinev1.AddToScheme(scheme.Scheme)
+
+	ms := &machinev1.MachineSet{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "MachineSet",
+			APIVersion: machinev1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "machineset",
+			Namespace: "default",
+			UID:       "machineset-uid",
+		},
+	}
+
+	// The MachineSet is deleted along with its machines, which carry the limit copied from it.
+	objects := []runtime.Object{}
+	var requests []reconcile.Request
+	for i := 0; i < 3; i++ {
+		deletionTimestamp := metav1.Now()
+		m := &machinev1.Machine{
+			TypeMeta: metav1.TypeMeta{
+				Kind: "Machine",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              fmt.Sprintf("machine-%d", i),
+				Namespace:         "default",
+				DeletionTimestamp: &deletionTimestamp,
+				Finalizers:        []string{machinev1.MachineFinalizer},
+				Labels: map[string]string{
+					machinev1.MachineClusterIDLabel: "testcluster",
+				},
+				Annotations: map[string]string{
+					MaxConcurrentDrainsAnnotation: "1",
+				},
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(ms, machinev1.SchemeGroupVersion.WithKind("MachineSet")),
+				},
+			},
+			Spec: machinev1.MachineSpec{
+				ProviderSpec: machinev1.ProviderSpec{
+					Value: &runtime.RawExtension{
+						Raw: []byte("{}"),
+					},
+				},
+			},
+			Status: machinev1.MachineStatus{
+				NodeRef: &corev1.ObjectReference{Name: fmt.Sprintf("node-%d", i)},
+				Phase:   pointer.StringPtr(phaseRunning),
+			},
+		}
+		objects = append(objects, m)
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(m)})
+	}
+
+	// Every drain needs two attempts: the first one times out evicting pods.
+	drainAttempts := map[string]int{}
+	draining := map[string]bool{}
+	act := newTestActuator()
+	r := &ReconcileMachine{
+		Client:        fake.NewFakeClientWithScheme(scheme.Scheme, objects...),
+		scheme:        scheme.Scheme,
+		eventRecorder: record.NewFakeRecorder(32),
+		actuator:      act,
+	}
+	r.drainNodeFunc = func(_ context.Context, m *machinev1.Machine) error {
+		draining[m.Name] = true
+		for name := range draining {
+			if name != m.Name {
+				t.Errorf("machine %s started draining while machine %s is still draining", m.Name, name)
+			}
+		}
+
+		drainAttempts[m.Name]++
+		if drainAttempts[m.Name] < 2 {
+			return &RequeueAfterError{RequeueAfter: 20 * time.Second}
+		}
+		delete(draining, m.Name)
+		return nil
+	}
+
+	for round := 0; round < 10; round++ {
+		for _, request := range requests {
+			if _, err := r.Reconcile(context.TODO(), request); err != nil {
+				t.Fatalf("unexpected error reconciling %s: %v", request.Name, err)
+			}
+		}
+	}
+
+	for _, request := range requests {
+		if drainAttempts[request.Name] != 2 {
+			t.Errorf("expected machine %s to be drained in 2 attempts, got %d", request.Name, drainAttempts[request.Name])
+		}
+
+		m := &machinev1.Machine{}
+		if err := r.Client.Get(context.TODO(), request.NamespacedName, m); !apierrors.IsNotFound(err) {
+			t.Errorf("expected the deletion of machine %s to complete, got finalizers: %v, error: %v", request.Name, m.Finalizers, err)
+		}
+	}
+	if act.DeleteCallCount != 3 {
+		t.Errorf("expected all 3 instances to be deleted, got %d deletions", act.DeleteCallCount)
+	}
+	if held := r.drainSlots.list(types.NamespacedName{Namespace: "default", Name: ms.Name}); len(held) != 0 {
+		t.Errorf("expected all drain slots to be released, got: %v", held)
+	}
+}
+
+func TestReconcileDrainPending(t *testing.T) {
+	machinev1.AddToScheme(scheme.Scheme)
+
+	ms := &machinev1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "machineset",
+			Namespace: "default",
+		},
+	}
+	deletionTimestamp := metav1.Now()
+	m := &machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "machine",
+			Namespace:         "default",
+			DeletionTimestamp: &deletionTimestamp,
+			Finalizers:        []string{machinev1.MachineFinalizer},
+			Labels: map[string]string{
+				machinev1.MachineClusterIDLabel: "testcluster",
+			},
+			Annotations: map[string]string{
+				MaxConcurrentDrainsAnnotation: "1",
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(ms, machinev1.SchemeGroupVersion.WithKind("MachineSet")),
+			},
+		},
+		Spec: machinev1.MachineSpec{
+			ProviderSpec: machinev1.ProviderSpec{
+				Value: &runtime.RawExtension{
+					Raw: []byte("{}"),
+				},
+			},
+		},
+		Status: machinev1.MachineStatus{
+			NodeRef: &corev1.ObjectReference{Name: "node"},
+		},
+	}
+	drainingMachine := m.DeepCopy()
+	drainingMachine.Name = "draining"
+
+	r := &ReconcileMachine{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme, ms, m, drainingMachine),
+		scheme:   scheme.Scheme,
+		actuator: newTestActuator(),
+		drainNodeFunc: func(context.Context, *machinev1.Machine) error {
+			t.Errorf("expected the machine not to be drained while the drain slot is taken")
+			return nil
+		},
+	}
+	owner := types.NamespacedName{Namespace: "default", Name: ms.Name}
+	r.drainSlots.tryAcquire(owner, drainingMachine.Name, 1)
+
+	result, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: client.ObjectKeyFromObject(m)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.RequeueAfter != drainPendingRequeueAfter {
+		t.Errorf("expected a requeue after %v, got: %v", drainPendingRequeueAfter, result)
+	}
+
+	got := &machinev1.Machine{}
+	if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(m), got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	drained := conditions.Get(got, machinev1.MachineDrained)
+	if drained == nil || drained.Status != corev1.ConditionFalse || drained.Reason != MachineDrainPendingReason {
+		t.Errorf("expected a Drained condition with reason %s, got: %+v", MachineDrainPendingReason, drained)
+	}
+	if len(got.Finalizers) == 0 {
+		t.Errorf("expected the deletion to wait for the drain")
+	}
+
+	// Once the draining machine is gone, its stale slot is released.
+	drainingMachine.Finalizers = nil
+	if err := r.Client.Update(context.TODO(), drainingMachine); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r.drainNodeFunc = func(context.Context, *machinev1.Machine) error {
+		return nil
+	}
+	r.eventRecorder = record.NewFakeRecorder(32)
+	if _, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: client.ObjectKeyFromObject(m)}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(m), got); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the deletion to complete once the stale drain slot is released, got finalizers: %v, error: %v", got.Finalizers, err)
+	}
+}
+
+func TestReconcileZoneDrainLimit(t *testing.T) {
+	machinev1.AddToScheme(scheme.Scheme)
+
+	zones := []string{"zone-a", "zone-a", "zone-b", "zone-b"}
+	objects := []runtime.Object{}
+	var requests []reconcile.Request
+	for i, zone := range zones {
+		deletionTimestamp := metav1.Now()
+		m := &machinev1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              fmt.Sprintf("machine-%d", i),
+				Namespace:         "default",
+				DeletionTimestamp: &deletionTimestamp,
+				Finalizers:        []string{machinev1.MachineFinalizer},
+				Labels: map[string]string{
+					machinev1.MachineClusterIDLabel: "testcluster",
+					MachineAZLabelName:              zone,
+				},
+			},
+			Spec: machinev1.MachineSpec{
+				ProviderSpec: machinev1.ProviderSpec{
+					Value: &runtime.RawExtension{
+						Raw: []byte("{}"),
+					},
+				},
+			},
+			Status: machinev1.MachineStatus{
+				NodeRef: &corev1.ObjectReference{Name: fmt.Sprintf("node-%d", i)},
+				Phase:   pointer.StringPtr(phaseRunning),
+			},
+		}
+		objects = append(objects, m)
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(m)})
+	}
+
+	// Every drain needs two attempts: the first one times out evicting pods.
+	drainAttempts := map[string]int{}
+	draining := map[string]string{}
+	var parallel bool
+	act := newTestActuator()
+	r := &ReconcileMachine{
+		Client:                     fake.NewFakeClientWithScheme(scheme.Scheme, objects...),
+		scheme:                     scheme.Scheme,
+		eventRecorder:              record.NewFakeRecorder(32),
+		actuator:                   act,
+		maxConcurrentDrainsPerZone: 1,
+	}
+	r.drainNodeFunc = func(_ context.Context, m *machinev1.Machine) error {
+		zone := m.Labels[MachineAZLabelName]
+		draining[m.Name] = zone
+		for name, otherZone := range draining {
+			if name == m.Name {
+				continue
+			}
+			if otherZone == zone {
+				t.Errorf("machine %s started draining while machine %s of zone %s is still draining", m.Name, name, zone)
+			} else {
+				parallel = true
+			}
+		}
+
+		drainAttempts[m.Name]++
+		if drainAttempts[m.Name] < 2 {
+			return &RequeueAfterError{RequeueAfter: 20 * time.Second}
+		}
+		delete(draining, m.Name)
+		return nil
+	}
+
+	// The first reconcile round leaves the second machine of each zone pending.
+	for round := 0; round < 10; round++ {
+		for _, request := range requests {
+			if _, err := r.Reconcile(context.TODO(), request); err != nil {
+				t.Fatalf("unexpected error reconciling %s: %v", request.Name, err)
+			}
+			if round > 0 || request.Name != "machine-1" {
+				continue
+			}
+			m := &machinev1.Machine{}
+			if err := r.Client.Get(context.TODO(), request.NamespacedName, m); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			drained := conditions.Get(m, machinev1.MachineDrained)
+			if drained == nil || drained.Reason != MachineDrainPendingReason || drained.Message != `Waiting for other machines of the zone "zone-a" to finish draining` {
+				t.Errorf("expected machine %s to wait for its zone, got: %+v", request.Name, drained)
+			}
+		}
+	}
+
+	if !parallel {
+		t.Errorf("expected machines of different zones to drain in parallel")
+	}
+	for _, request := range requests {
+		if drainAttempts[request.Name] != 2 {
+			t.Errorf("expected machine %s to be drained in 2 attempts, got %d", request.Name, drainAttempts[request.Name])
+		}
+	}
+	if act.DeleteCallCount != int64(len(zones)) {
+		t.Errorf("expected all %d instances to be deleted, got %d deletions", len(zones), act.DeleteCallCount)
+	}
+	for _, zone := range []string{"zone-a", "zone-b"} {
+		if held := r.zoneDrainSlots.list(types.NamespacedName{Namespace: "default", Name: zone}); len(held) != 0 {
+			t.Errorf("expected all drain slots of zone %s to be released, got: %v", zone, held)
+		}
+	}
+}
+
+func TestAcquireDrainSlotReleasesZoneSlot(t *testing.T) {
+	machinev1.AddToScheme(scheme.Scheme)
+
+	ms := &machinev1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "machineset",
+			Namespace: "default",
+		},
+	}
+	deletionTimestamp := metav1.Now()
+	m := &machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "machine",
+			Namespace:         "default",
+			DeletionTimestamp: &deletionTimestamp,
+			Labels:            map[string]string{MachineAZLabelName: "zone-a"},
+			Annotations:       map[string]string{MaxConcurrentDrainsAnnotation: "1"},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(ms, machinev1.SchemeGroupVersion.WithKind("MachineSet")),
+			},
+		},
+		Status: machinev1.MachineStatus{
+			NodeRef: &corev1.ObjectReference{Name: "node"},
+		},
+	}
+	drainingMachine := m.DeepCopy()
+	drainingMachine.Name = "draining"
+	drainingMachine.Labels = map[string]string{MachineAZLabelName: "zone-b"}
+
+	r := &ReconcileMachine{
+		Client:                     fake.NewFakeClientWithScheme(scheme.Scheme, ms, m, drainingMachine),
+		maxConcurrentDrainsPerZone: 1,
+	}
+	r.drainSlots.tryAcquire(types.NamespacedName{Namespace: "default", Name: ms.Name}, drainingMachine.Name, 1)
+
+	acquired, waitingFor, err := r.acquireDrainSlot(context.TODO(), m)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if acquired || waitingFor != "MachineSet" {
+		t.Errorf("expected the machine to wait for its MachineSet, got acquired: %v, waiting for: %q", acquired, waitingFor)
+	}
+	if held := r.zoneDrainSlots.list(types.NamespacedName{Namespace: "default", Name: "zone-a"}); len(held) != 0 {
+		t.Errorf("expected the zone drain slot to be released while waiting for the MachineSet, got: %v", held)
+	}
+}
+
+func TestAcquireMachineSetDrainSlotLimit(t *testing.T) {
+	owner := metav1.OwnerReference{APIVersion: machinev1.SchemeGroupVersion.String(), Kind: "MachineSet", Name: "machineset", UID: "machineset-uid", Controller: pointer.BoolPtr(true)}
+
+	testCases := []struct {
+		name             string
+		annotations      map[string]string
+		expectedAcquired bool
+	}{
+		{
+			name:             "without a limit",
+			expectedAcquired: true,
+		},
+		{
+			name:             "with a free slot",
+			annotations:      map[string]string{MaxConcurrentDrainsAnnotation: "2"},
+			expectedAcquired: true,
+		},
+		{
+			name:             "with all slots taken",
+			annotations:      map[string]string{MaxConcurrentDrainsAnnotation: "1"},
+			expectedAcquired: false,
+		},
+		{
+			name:             "with an invalid limit",
+			annotations:      map[string]string{MaxConcurrentDrainsAnnotation: "many"},
+			expectedAcquired: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			deletionTimestamp := metav1.Now()
+			draining := &machinev1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "draining",
+					Namespace:         "default",
+					DeletionTimestamp: &deletionTimestamp,
+					OwnerReferences:   []metav1.OwnerReference{owner},
+				},
+				Status: machinev1.MachineStatus{
+					NodeRef: &corev1.ObjectReference{Name: "draining"},
+				},
+			}
+			m := &machinev1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "machine",
+					Namespace:         "default",
+					DeletionTimestamp: &deletionTimestamp,
+					Annotations:       tc.annotations,
+					OwnerReferences:   []metav1.OwnerReference{owner},
+				},
+			}
+
+			// The MachineSet no longer exists.
+			r := &ReconcileMachine{Client: fake.NewFakeClientWithScheme(scheme.Scheme, draining, m)}
+			r.drainSlots.tryAcquire(types.NamespacedName{Namespace: "default", Name: owner.Name}, draining.Name, 1)
+
+			acquired, err := r.acquireMachineSetDrainSlot(context.TODO(), m)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if acquired != tc.expectedAcquired {
+				t.Errorf("expected acquired: %v, got: %v", tc.expectedAcquired, acquired)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/conditions"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	"k8s.io/kubectl/pkg/drain"
+)
+
+const (
+	// MachineBlockedByPDBReason is the reason of the Drainable condition of machines whose drain
+	// is blocked by PodDisruptionBudgets which allow no disruption
+	MachineBlockedByPDBReason = "BlockedByPDB"
+
+	// maxReportedBlockedPods bounds the number of pods listed in the Drainable condition message
+	maxReportedBlockedPods = 10
+)
+
+// blockedPod is a pod whose eviction is blocked by a PodDisruptionBudget.
+type blockedPod struct {
+	namespace, name, pdb string
+}
+
+func (p blockedPod) String() string {
+	return fmt.Sprintf("%s/%s (PodDisruptionBudget %s)", p.namespace, p.name, p.pdb)
+}
+
+// pdbBlockedError is returned when draining a node fails as PodDisruptionBudgets block the eviction of its pods.
+type pdbBlockedError struct {
+	pods []blockedPod
+	err  error
+}
+
+func (e *pdbBlockedError) Error() string {
+	return fmt.Sprintf("%s: %v", e.message(), e.err)
+}
+
+func (e *pdbBlockedError) Unwrap() error {
+	return e.err
+}
+
+// message lists the blocked pods, up to maxReportedBlockedPods of them.
+func (e *pdbBlockedError) message() string {
+	var pods []string
+	for i, pod := range e.pods {
+		if i == maxReportedBlockedPods {
+			pods = append(pods, fmt.Sprintf("and %d more", len(e.pods)-maxReportedBlockedPods))
+			break
+		}
+		pods = append(pods, pod.String())
+	}
+	return fmt.Sprintf("Drain blocked by PodDisruptionBudgets allowing no disruption, evicting pods: %s", strings.Join(pods, ", "))
+}
+
+// pdbBlockedPods returns the pods whose eviction is blocked by a PodDisruptionBudget allowing no disruption.
+func pdbBlockedPods(ctx context.Context, kubeClient kubernetes.Interface, pods []corev1.Pod) ([]blockedPod, error) {
+	byNamespace := map[string][]corev1.Pod{}
+	var namespaces []string
+	for _, pod := range pods {
+		if _, ok := byNamespace[pod.Namespace]; !ok {
+			namespaces = append(namespaces, pod.Namespace)
+		}
+		byNamespace[pod.Namespace] = append(byNamespace[pod.Namespace], pod)
+	}
+
+	var blocked []blockedPod
+	for _, namespace := range namespaces {
+		pdbs, err := kubeClient.PolicyV1().PodDisruptionBudgets(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("unable to list PodDisruptionBudgets in namespace %q: %v", namespace, err)
+		}
+		for _, pod := range byNamespace[namespace] {
+			for _, pdb := range pdbs.Items {
+				if pdb.Status.DisruptionsAllowed > 0 {
+					continue
+				}
+				selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+				if err != nil || !selector.Matches(labels.Set(pod.Labels)) {
+					continue
+				}
+				blocked = append(blocked, blockedPod{namespace: namespace, name: pod.Name, pdb: pdb.Name})
+				break
+			}
+		}
+	}
+	return blocked, nil
+}
+
+// diagnoseDrainFailure wraps the error of a failed drain into a pdbBlockedError when PodDisruptionBudgets
+// block the eviction of the remaining pods of the node.
+func diagnoseDrainFailure(ctx context.Context, drainer *drain.Helper, node *corev1.Node, drainErr error) error {
+	podList, errs := drainer.GetPodsForDeletion(node.Name)
+	if len(errs) > 0 {
+		klog.Warningf("unable to list the pods remaining on node %q: %v", node.Name, errs)
+		return drainErr
+	}
+	blocked, err := pdbBlockedPods(ctx, drainer.Client, podList.Pods())
+	if err != nil {
+		klog.Warningf("unable to check the PodDisruptionBudgets of the pods remaining on node %q: %v", node.Name, err)
+		return drainErr
+	}
+	if len(blocked) == 0 {
+		return drainErr
+	}
+	return &pdbBlockedError{pods: blocked, err: drainErr}
+}
+
+// reportDrainBlockedByPDB marks the Drainable condition of the machine as blocked by PodDisruptionBudgets,
+// recording an event unless originalConditions report the same pods already.
+func (r *ReconcileMachine) reportDrainBlockedByPDB(m *machinev1.Machine, blockedErr *pdbBlockedError, originalConditions machinev1.Conditions) {
+	message := blockedErr.message()
+	conditions.Set(m, conditions.FalseCondition(
+		machinev1.MachineDrainable,
+		MachineBlockedByPDBReason,
+		machinev1.ConditionSeverityWarning,
+		"%s", message,
+	))
+
+	for _, condition := range originalConditions {
+		if condition.Type == machinev1.MachineDrainable && condition.Reason == MachineBlockedByPDBReason && condition.Message == message {
+			return
+		}
+	}
+	r.eventRecorder.Event(m, corev1.EventTypeWarning, "DrainBlockedByPDB", message)
+}
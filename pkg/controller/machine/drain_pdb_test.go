@@ -0,0 +1,200 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/conditions"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestPDBBlockedPods(t *testing.T) {
+	pod := func(namespace, name, app string) corev1.Pod {
+		return corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: map[string]string{"app": app}}}
+	}
+	pdb := func(namespace, name, app string, disruptionsAllowed int32) *policyv1.PodDisruptionBudget {
+		return &policyv1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec: policyv1.PodDisruptionBudgetSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": app}},
+			},
+			Status: policyv1.PodDisruptionBudgetStatus{DisruptionsAllowed: disruptionsAllowed},
+		}
+	}
+
+	kubeClient := fake.NewSimpleClientset(
+		pdb("db", "db-pdb", "db", 0),
+		pdb("web", "web-pdb", "web", 1),
+		pdb("other", "db-pdb", "db", 0),
+	)
+	pods := []corev1.Pod{
+		pod("db", "db-0", "db"),
+		pod("db", "cache-0", "cache"),
+		pod("web", "web-0", "web"),
+		pod("db", "db-1", "db"),
+	}
+
+	blocked, err := pdbBlockedPods(context.Background(), kubeClient, pods)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []blockedPod{
+		{namespace: "db", name: "db-0", pdb: "db-pdb"},
+		{namespace: "db", name: "db-1", pdb: "db-pdb"},
+	}
+	if !reflect.DeepEqual(blocked, expected) {
+		t.Errorf("expected blocked pods %v, got: %v", expected, blocked)
+	}
+}
+
+func TestPDBBlockedErrorMessage(t *testing.T) {
+	var pods []blockedPod
+	for i := 0; i < maxReportedBlockedPods+2; i++ {
+		pods = append(pods, blockedPod{namespace: "db", name: fmt.Sprintf("db-%d", i), pdb: "db-pdb"})
+	}
+
+	message := (&pdbBlockedError{pods: pods[:1]}).message()
+	if expected := "Drain blocked by PodDisruptionBudgets allowing no disruption, evicting pods: db/db-0 (PodDisruptionBudget db-pdb)"; message != expected {
+		t.Errorf("expected message %q, got: %q", expected, message)
+	}
+	if message := (&pdbBlockedError{pods: pods}).message(); !strings.HasSuffix(message, "db/db-9 (PodDisruptionBudget db-pdb), and 2 more") {
+		t.Errorf("expected the message to list %d pods, got: %q", maxReportedBlockedPods, message)
+	}
+}
+
+func TestReconcileDrainBlockedByPDB(t *testing.T) {
+	machinev1.AddToScheme(scheme.Scheme)
+
+	now := time.Now().Truncate(time.Second)
+	blockedErr := &pdbBlockedError{
+		pods: []blockedPod{{namespace: "db", name: "db-0", pdb: "db-pdb"}},
+		err:  &RequeueAfterError{RequeueAfter: 20 * time.Second},
+	}
+
+	testCases := []struct {
+		name              string
+		drainErr          error
+		reported          bool
+		expectedReason    string
+		expectedEvent     bool
+		expectedRequeue   time.Duration
+		expectedCondition corev1.ConditionStatus
+	}{
+		{
+			name:              "drain blocked by a PodDisruptionBudget",
+			drainErr:          blockedErr,
+			expectedReason:    MachineBlockedByPDBReason,
+			expectedEvent:     true,
+			expectedRequeue:   20 * time.Second,
+			expectedCondition: corev1.ConditionFalse,
+		},
+		{
+			name:              "drain blocked by a PodDisruptionBudget reported already",
+			drainErr:          blockedErr,
+			reported:          true,
+			expectedReason:    MachineBlockedByPDBReason,
+			expectedRequeue:   20 * time.Second,
+			expectedCondition: corev1.ConditionFalse,
+		},
+		{
+			name:              "drain completed after being blocked",
+			reported:          true,
+			expectedCondition: corev1.ConditionTrue,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			deletionTimestamp := metav1.NewTime(now.Add(-time.Minute))
+			m := &machinev1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "machine",
+					Namespace:         "default",
+					DeletionTimestamp: &deletionTimestamp,
+					Finalizers:        []string{machinev1.MachineFinalizer},
+					Labels: map[string]string{
+						machinev1.MachineClusterIDLabel: "testcluster",
+					},
+				},
+				Spec: machinev1.MachineSpec{
+					ProviderSpec: machinev1.ProviderSpec{
+						Value: &runtime.RawExtension{
+							Raw: []byte("{}"),
+						},
+					},
+					// Keep the machine after the drain so that its conditions can be inspected
+					LifecycleHooks: machinev1.LifecycleHooks{
+						PreTerminate: []machinev1.LifecycleHook{{Name: "inspect", Owner: "machine-api-tests"}},
+					},
+				},
+				Status: machinev1.MachineStatus{
+					NodeRef: &corev1.ObjectReference{Name: "node"},
+				},
+			}
+			if tc.reported {
+				m.Status.Conditions = machinev1.Conditions{*conditions.FalseCondition(
+					machinev1.MachineDrainable,
+					MachineBlockedByPDBReason,
+					machinev1.ConditionSeverityWarning,
+					"%s", blockedErr.message(),
+				)}
+			}
+
+			recorder := record.NewFakeRecorder(8)
+			r := &ReconcileMachine{
+				Client:        fakeclient.NewFakeClientWithScheme(scheme.Scheme, m),
+				scheme:        scheme.Scheme,
+				eventRecorder: recorder,
+				actuator:      newTestActuator(),
+				drainNodeFunc: func(context.Context, *machinev1.Machine) error {
+					return tc.drainErr
+				},
+				nowFunc: func() time.Time { return now },
+			}
+
+			result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(m)})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.RequeueAfter != tc.expectedRequeue {
+				t.Errorf("expected to requeue after %v, got: %v", tc.expectedRequeue, result.RequeueAfter)
+			}
+
+			var gotEvent bool
+			for len(recorder.Events) > 0 {
+				if strings.Contains(<-recorder.Events, "DrainBlockedByPDB") {
+					gotEvent = true
+				}
+			}
+			if gotEvent != tc.expectedEvent {
+				t.Errorf("expected a DrainBlockedByPDB event: %v, got: %v", tc.expectedEvent, gotEvent)
+			}
+
+			got := &machinev1.Machine{}
+			if err := r.Client.Get(ctx, client.ObjectKeyFromObject(m), got); err != nil {
+				t.Fatal(err)
+			}
+			condition := conditions.Get(got, machinev1.MachineDrainable)
+			if condition == nil || condition.Status != tc.expectedCondition || condition.Reason != tc.expectedReason {
+				t.Errorf("expected the Drainable condition to be %s with reason %q, got: %+v", tc.expectedCondition, tc.expectedReason, condition)
+			}
+			if tc.expectedReason == MachineBlockedByPDBReason && condition.Message != blockedErr.message() {
+				t.Errorf("expected the Drainable condition message to list the blocked pods, got: %q", condition.Message)
+			}
+		})
+	}
+}
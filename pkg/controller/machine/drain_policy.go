@@ -0,0 +1,91 @@
+package machine
+
+import (
+	"strconv"
+	"time"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/conditions"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// DrainTimeoutAnnotation is set on a Machine, or on its MachineSet which copies it to the machines it creates,
+	// to bound how long draining its node may take, eg. "10m". Once the timeout expires the Drained condition
+	// reports it, and the drain is force-completed if DrainForceAnnotation is true
+	DrainTimeoutAnnotation = "machine.openshift.io/drain-timeout"
+
+	// DrainForceAnnotation is set to true on a Machine, or on its MachineSet which copies it to the machines it
+	// creates, to carry on with the deletion without waiting for the remaining pods to be evicted once the drain
+	// timeout expires
+	DrainForceAnnotation = "machine.openshift.io/drain-force"
+
+	// MachineDrainTimedOutReason is the reason of the Drained condition of machines whose node
+	// could not be drained within the drain timeout
+	MachineDrainTimedOutReason = "DrainTimedOut"
+
+	// MachineDrainForcedReason is the reason of the Drained condition of machines whose drain
+	// was force-completed once the drain timeout expired
+	MachineDrainForcedReason = "DrainForced"
+)
+
+// drainPolicy overrides how the node of a machine is drained.
+type drainPolicy struct {
+	timeout time.Duration
+	force   bool
+}
+
+// drainPolicyOf returns the drain policy of the machine. The annotations of its MachineSet are copied to
+// the machine when it is created, so that the policy holds once the MachineSet is deleted. Invalid annotations
+// are ignored.
+func drainPolicyOf(m *machinev1.Machine) drainPolicy {
+	var policy drainPolicy
+	if value, ok := m.Annotations[DrainTimeoutAnnotation]; ok {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			klog.Warningf("%v: ignoring invalid %s annotation %q: must be a positive duration", m.GetName(), DrainTimeoutAnnotation, value)
+		} else {
+			policy.timeout = timeout
+		}
+	}
+	if value, ok := m.Annotations[DrainForceAnnotation]; ok {
+		force, err := strconv.ParseBool(value)
+		if err != nil {
+			klog.Warningf("%v: ignoring invalid %s annotation %q: must be a boolean", m.GetName(), DrainForceAnnotation, value)
+		} else {
+			policy.force = force
+		}
+	}
+	return policy
+}
+
+// drainStartedAt returns when the drain of the machine started: once it was deleted and its pre-drain hooks,
+// if any, were removed or timed out. Once the drain is blocked by PodDisruptionBudgets, the Drainable condition
+// no longer tells when the hooks were removed, so the drain counts from the deletion.
+func drainStartedAt(m *machinev1.Machine) time.Time {
+	started := m.DeletionTimestamp.Time
+	if drainable := conditions.Get(m, machinev1.MachineDrainable); drainable != nil && drainable.Reason != MachineBlockedByPDBReason && drainable.LastTransitionTime.After(started) {
+		started = drainable.LastTransitionTime.Time
+	}
+	return started
+}
+
+// drainTimedOut returns whether the drain of the machine has been running for longer than the timeout of the policy.
+func (r *ReconcileMachine) drainTimedOut(m *machinev1.Machine, policy drainPolicy) bool {
+	return policy.timeout > 0 && r.now().Sub(drainStartedAt(m)) >= policy.timeout
+}
+
+// reportDrainTimeout marks the Drained condition of the machine as timed out or forced, recording an event
+// unless originalConditions report it already.
+func (r *ReconcileMachine) reportDrainTimeout(m *machinev1.Machine, policy drainPolicy, drainErr error, originalConditions machinev1.Conditions) {
+	reason, severity, message := MachineDrainTimedOutReason, machinev1.ConditionSeverityError, "Could not drain machine within the drain timeout of %v: %v"
+	if policy.force {
+		reason, severity, message = MachineDrainForcedReason, machinev1.ConditionSeverityWarning, "Drain force-completed after the drain timeout of %v: %v"
+	}
+	conditions.Set(m, conditions.FalseCondition(machinev1.MachineDrained, reason, severity, message, policy.timeout, drainErr))
+
+	if !hasConditionReason(originalConditions, machinev1.MachineDrained, reason) {
+		r.eventRecorder.Eventf(m, corev1.EventTypeWarning, reason, message, policy.timeout, drainErr)
+	}
+}
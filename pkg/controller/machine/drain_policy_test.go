@@ -0,0 +1,194 @@
+package machine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/conditions"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcileDrainPolicy(t *testing.T) {
+	machinev1.AddToScheme(scheme.Scheme)
+
+	now := time.Now().Truncate(time.Second)
+
+	testCases := []struct {
+		name                 string
+		annotations          map[string]string
+		machineSetAnnotation map[string]string
+		deletedAgo           time.Duration
+		reportedReason       string
+		expectedDeleted      bool
+		expectedReason       string
+		expectedEvent        string
+	}{
+		{
+			name:           "drain failing without a timeout",
+			deletedAgo:     time.Hour,
+			expectedReason: machinev1.MachineDrainError,
+		},
+		{
+			name:           "drain failing before the timeout",
+			annotations:    map[string]string{DrainTimeoutAnnotation: "10m", DrainForceAnnotation: "true"},
+			deletedAgo:     time.Minute,
+			expectedReason: machinev1.MachineDrainError,
+		},
+		{
+			name:           "drain failing after the timeout",
+			annotations:    map[string]string{DrainTimeoutAnnotation: "10m"},
+			deletedAgo:     time.Hour,
+			expectedReason: MachineDrainTimedOutReason,
+			expectedEvent:  MachineDrainTimedOutReason,
+		},
+		{
+			name:           "drain failing after a timeout reported already",
+			annotations:    map[string]string{DrainTimeoutAnnotation: "10m"},
+			deletedAgo:     time.Hour,
+			reportedReason: MachineDrainTimedOutReason,
+			expectedReason: MachineDrainTimedOutReason,
+		},
+		{
+			name:            "drain failing after the timeout with force",
+			annotations:     map[string]string{DrainTimeoutAnnotation: "10m", DrainForceAnnotation: "true"},
+			deletedAgo:      time.Hour,
+			expectedDeleted: true,
+			expectedEvent:   MachineDrainForcedReason,
+		},
+		{
+			// The MachineSet annotations are copied to the machines it creates, and are not read from the MachineSet
+			name:                 "drain failing after the timeout with force set on the MachineSet only",
+			machineSetAnnotation: map[string]string{DrainTimeoutAnnotation: "10m", DrainForceAnnotation: "true"},
+			deletedAgo:           time.Hour,
+			expectedReason:       machinev1.MachineDrainError,
+		},
+		{
+			name:           "drain failing with an invalid timeout",
+			annotations:    map[string]string{DrainTimeoutAnnotation: "ten minutes", DrainForceAnnotation: "true"},
+			deletedAgo:     time.Hour,
+			expectedReason: machinev1.MachineDrainError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ms := &machinev1.MachineSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "machineset",
+					Namespace:   "default",
+					Annotations: tc.machineSetAnnotation,
+				},
+			}
+			deletionTimestamp := metav1.NewTime(now.Add(-tc.deletedAgo))
+			m := &machinev1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "machine",
+					Namespace:         "default",
+					Annotations:       tc.annotations,
+					DeletionTimestamp: &deletionTimestamp,
+					Finalizers:        []string{machinev1.MachineFinalizer},
+					Labels: map[string]string{
+						machinev1.MachineClusterIDLabel: "testcluster",
+					},
+					OwnerReferences: []metav1.OwnerReference{{
+						APIVersion: machinev1.SchemeGroupVersion.String(),
+						Kind:       "MachineSet",
+						Name:       ms.Name,
+						Controller: func() *bool { b := true; return &b }(),
+					}},
+				},
+				Spec: machinev1.MachineSpec{
+					ProviderSpec: machinev1.ProviderSpec{
+						Value: &runtime.RawExtension{
+							Raw: []byte("{}"),
+						},
+					},
+				},
+				Status: machinev1.MachineStatus{
+					NodeRef: &corev1.ObjectReference{Name: "node"},
+					// Without pre-drain hooks, machines are drainable from their creation
+					Conditions: machinev1.Conditions{{
+						Type:               machinev1.MachineDrainable,
+						Status:             corev1.ConditionTrue,
+						LastTransitionTime: metav1.NewTime(now.Add(-24 * time.Hour)),
+					}},
+				},
+			}
+			if tc.reportedReason != "" {
+				m.Status.Conditions = append(m.Status.Conditions, machinev1.Condition{
+					Type:   machinev1.MachineDrained,
+					Status: corev1.ConditionFalse,
+					Reason: tc.reportedReason,
+				})
+			}
+
+			act := newTestActuator()
+			recorder := record.NewFakeRecorder(8)
+			r := &ReconcileMachine{
+				Client:        fake.NewFakeClientWithScheme(scheme.Scheme, m, ms),
+				scheme:        scheme.Scheme,
+				eventRecorder: recorder,
+				actuator:      act,
+				drainNodeFunc: func(context.Context, *machinev1.Machine) error {
+					return errors.New("cannot evict pod as it would violate the pod's disruption budget")
+				},
+				nowFunc: func() time.Time { return now },
+			}
+
+			if _, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(m)}); err != nil && tc.expectedDeleted {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if deleted := act.DeleteCallCount > 0; deleted != tc.expectedDeleted {
+				t.Errorf("expected deleted: %v, got: %v", tc.expectedDeleted, deleted)
+			}
+
+			var events []string
+			for len(recorder.Events) > 0 {
+				events = append(events, <-recorder.Events)
+			}
+			var gotEvent bool
+			for _, event := range events {
+				if tc.expectedEvent != "" && strings.Contains(event, " "+tc.expectedEvent+" ") {
+					gotEvent = true
+				}
+				if tc.expectedEvent == "" && (strings.Contains(event, MachineDrainTimedOutReason) || strings.Contains(event, MachineDrainForcedReason)) {
+					t.Errorf("expected no drain timeout event, got: %q", event)
+				}
+			}
+			if tc.expectedEvent != "" && !gotEvent {
+				t.Errorf("expected a %s event, got: %q", tc.expectedEvent, events)
+			}
+
+			if tc.expectedReason == "" {
+				return
+			}
+			got := &machinev1.Machine{}
+			if err := r.Client.Get(ctx, client.ObjectKeyFromObject(m), got); err != nil {
+				t.Fatal(err)
+			}
+			condition := conditions.Get(got, machinev1.MachineDrained)
+			if tc.expectedReason == machinev1.MachineDrainError {
+				// Drain errors are retried without being persisted
+				if condition != nil && condition.Reason != tc.reportedReason {
+					t.Errorf("expected the Drained condition not to be updated, got: %+v", condition)
+				}
+				return
+			}
+			// The timeout is persisted as the drain keeps being retried
+			if condition == nil || condition.Reason != tc.expectedReason {
+				t.Errorf("expected the Drained condition reason to be %s, got: %+v", tc.expectedReason, condition)
+			}
+		})
+	}
+}
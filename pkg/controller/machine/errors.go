@@ -21,51 +21,31 @@ import (
 	"time"
 
 	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/machineapierrors"
 )
 
-// A more descriptive kind of error that represents an error condition that
-// should be set in the Machine.Status. The "Reason" field is meant for short,
-// enum-style constants meant to be interpreted by machines. The "Message"
-// field is meant to be read by humans.
-type MachineError struct {
-	Reason  machinev1.MachineStatusError
-	Message string
-}
-
-func (e *MachineError) Error() string {
-	return e.Message
-}
+// MachineError is an error condition that should be set in the Machine.Status.
+// Actuators classify the failures of the cloud provider with the errors of the machineapierrors package.
+type MachineError = machineapierrors.MachineError
 
 // Some error builders for ease of use. They set the appropriate "Reason"
 // value, and all arguments are Printf-style varargs fed into Sprintf to
 // construct the Message.
 
 func InvalidMachineConfiguration(msg string, args ...interface{}) *MachineError {
-	return &MachineError{
-		Reason:  machinev1.InvalidConfigurationMachineError,
-		Message: fmt.Sprintf(msg, args...),
-	}
+	return machineapierrors.InvalidConfiguration(msg, args...)
 }
 
 func CreateMachine(msg string, args ...interface{}) *MachineError {
-	return &MachineError{
-		Reason:  machinev1.CreateMachineError,
-		Message: fmt.Sprintf(msg, args...),
-	}
+	return machineapierrors.New(machinev1.CreateMachineError, msg, args...)
 }
 
 func UpdateMachine(msg string, args ...interface{}) *MachineError {
-	return &MachineError{
-		Reason:  machinev1.UpdateMachineError,
-		Message: fmt.Sprintf(msg, args...),
-	}
+	return machineapierrors.New(machinev1.UpdateMachineError, msg, args...)
 }
 
 func DeleteMachine(msg string, args ...interface{}) *MachineError {
-	return &MachineError{
-		Reason:  machinev1.DeleteMachineError,
-		Message: fmt.Sprintf(msg, args...),
-	}
+	return machineapierrors.New(machinev1.DeleteMachineError, msg, args...)
 }
 
 // RequeueAfterError represents that an actuator managed object should be
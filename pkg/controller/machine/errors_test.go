@@ -0,0 +1,102 @@
+package machine
+
+import (
+	"context"
+	"testing"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/machineapierrors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcileCreateErrors(t *testing.T) {
+	machinev1.AddToScheme(scheme.Scheme)
+
+	testCases := []struct {
+		name                 string
+		createErr            error
+		expectedPhase        string
+		expectedErrorReason  machinev1.MachineStatusError
+		expectedErrorMessage string
+	}{
+		{
+			name:                 "with an invalid configuration error",
+			createErr:            machineapierrors.InvalidConfiguration("unknown instance type"),
+			expectedPhase:        phaseFailed,
+			expectedErrorReason:  machinev1.InvalidConfigurationMachineError,
+			expectedErrorMessage: "unknown instance type",
+		},
+		{
+			name:          "with an insufficient resources error",
+			createErr:     machineapierrors.InsufficientResources("instance limit exceeded"),
+			expectedPhase: phaseProvisioning,
+		},
+		{
+			name:          "with an unauthorized error",
+			createErr:     machineapierrors.Unauthorized("credentials rejected"),
+			expectedPhase: phaseProvisioning,
+		},
+		{
+			name:          "with a transient error",
+			createErr:     CreateMachine("instance failed to start"),
+			expectedPhase: phaseProvisioning,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := &machinev1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "machine",
+					Namespace:  "default",
+					Finalizers: []string{machinev1.MachineFinalizer},
+					Labels: map[string]string{
+						machinev1.MachineClusterIDLabel: "testcluster",
+					},
+				},
+				Spec: machinev1.MachineSpec{
+					ProviderSpec: machinev1.ProviderSpec{
+						Value: &runtime.RawExtension{
+							Raw: []byte("{}"),
+						},
+					},
+				},
+				Status: machinev1.MachineStatus{
+					Phase: pointer.StringPtr(phaseProvisioning),
+				},
+			}
+
+			r := &ReconcileMachine{
+				Client:        fake.NewFakeClientWithScheme(scheme.Scheme, m),
+				scheme:        scheme.Scheme,
+				eventRecorder: record.NewFakeRecorder(32),
+				actuator:      &lastOperationActuator{createErr: tc.createErr},
+			}
+
+			if _, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: client.ObjectKeyFromObject(m)}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := &machinev1.Machine{}
+			if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(m), got); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if phase := pointer.StringPtrDerefOr(got.Status.Phase, ""); phase != tc.expectedPhase {
+				t.Errorf("expected phase %q, got: %q", tc.expectedPhase, phase)
+			}
+			if reason := pointer.StringPtrDerefOr((*string)(got.Status.ErrorReason), ""); reason != string(tc.expectedErrorReason) {
+				t.Errorf("expected error reason %q, got: %q", tc.expectedErrorReason, reason)
+			}
+			if message := pointer.StringPtrDerefOr(got.Status.ErrorMessage, ""); message != tc.expectedErrorMessage {
+				t.Errorf("expected error message %q, got: %q", tc.expectedErrorMessage, message)
+			}
+		})
+	}
+}
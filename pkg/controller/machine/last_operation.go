@@ -0,0 +1,94 @@
+package machine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Types of the actuator operations recorded in the machine status.lastOperation.
+const (
+	lastOperationTypeCreate = "Create"
+	lastOperationTypeUpdate = "Update"
+	lastOperationTypeDelete = "Delete"
+)
+
+// States of the actuator operations recorded in the machine status.lastOperation.
+const (
+	// lastOperationStateRequested is set when the actuator accepted the operation,
+	// which completes asynchronously on the provider, e.g. the termination of an instance.
+	lastOperationStateRequested = "Requested"
+	// lastOperationStateProcessing is set when the actuator asked for the operation to be retried later.
+	lastOperationStateProcessing = "Processing"
+	lastOperationStateFailed     = "Failed"
+	lastOperationStateSuccessful = "Successful"
+)
+
+// lastOperationErrorReasons are the reasons recorded for failures which are not classified by the actuator.
+var lastOperationErrorReasons = map[string]machinev1.MachineStatusError{
+	lastOperationTypeCreate: machinev1.CreateMachineError,
+	lastOperationTypeUpdate: machinev1.UpdateMachineError,
+	lastOperationTypeDelete: machinev1.DeleteMachineError,
+}
+
+// recordActuatorOperation records the outcome of an actuator call in the machine status.lastOperation.
+// successState is recorded when the call succeeded.
+func (r *ReconcileMachine) recordActuatorOperation(ctx context.Context, machine *machinev1.Machine, operationType string, err error, successState, successDescription string) {
+	var requeueAfterError *RequeueAfterError
+	switch {
+	case err == nil:
+		r.recordLastOperation(ctx, machine, operationType, successState, successDescription)
+	case errors.As(err, &requeueAfterError):
+		r.recordLastOperation(ctx, machine, operationType, lastOperationStateProcessing, fmt.Sprintf("%s in progress, retrying in %s", operationType, requeueAfterError.RequeueAfter))
+	default:
+		reason := lastOperationErrorReasons[operationType]
+		var machineError *MachineError
+		if errors.As(err, &machineError) {
+			reason = machineError.Reason
+		}
+		r.recordLastOperation(ctx, machine, operationType, lastOperationStateFailed, fmt.Sprintf("%s failed: %s: %v", operationType, reason, err))
+	}
+}
+
+// recordLastOperation sets the machine status.lastOperation, unless it is recorded already.
+// The lastOperation is patched on its own, so that it is recorded even when a later status update fails.
+// Failing to record it does not fail the reconcile.
+func (r *ReconcileMachine) recordLastOperation(ctx context.Context, machine *machinev1.Machine, operationType, state, description string) {
+	if lastOperation := machine.Status.LastOperation; lastOperation != nil &&
+		pointer.StringPtrDerefOr(lastOperation.Type, "") == operationType &&
+		pointer.StringPtrDerefOr(lastOperation.State, "") == state &&
+		pointer.StringPtrDerefOr(lastOperation.Description, "") == description {
+		return
+	}
+
+	now := metav1.NewTime(r.now())
+	lastOperation := &machinev1.LastOperation{
+		Type:        pointer.StringPtr(operationType),
+		State:       pointer.StringPtr(state),
+		Description: pointer.StringPtr(description),
+		LastUpdated: &now,
+	}
+
+	// Patch a copy, so that the other local changes to the machine status are kept for the next status update.
+	patched := machine.DeepCopy()
+	baseToPatch := client.MergeFrom(patched.DeepCopy())
+	patched.Status.LastOperation = lastOperation
+	err := retry.OnError(retry.DefaultBackoff, func(err error) bool { return !apierrors.IsNotFound(err) }, func() error {
+		return r.Client.Status().Patch(ctx, patched, baseToPatch)
+	})
+	if err != nil {
+		klog.Errorf("%v: failed to record last operation %s %s: %v", machine.GetName(), operationType, state, err)
+		return
+	}
+
+	machine.Status.LastOperation = lastOperation
+	machine.ResourceVersion = patched.ResourceVersion
+}
@@ -0,0 +1,186 @@
+package machine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ Actuator = &lastOperationActuator{}
+
+// lastOperationActuator is an actuator returning the configured errors.
+type lastOperationActuator struct {
+	createErr error
+	updateErr error
+	deleteErr error
+	exists    bool
+}
+
+func (a *lastOperationActuator) Create(context.Context, *machinev1.Machine) error {
+	return a.createErr
+}
+
+func (a *lastOperationActuator) Update(context.Context, *machinev1.Machine) error {
+	return a.updateErr
+}
+
+func (a *lastOperationActuator) Delete(context.Context, *machinev1.Machine) error {
+	return a.deleteErr
+}
+
+func (a *lastOperationActuator) Exists(context.Context, *machinev1.Machine) (bool, error) {
+	return a.exists, nil
+}
+
+func TestReconcileLastOperation(t *testing.T) {
+	machinev1.AddToScheme(scheme.Scheme)
+
+	m := &machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "machine",
+			Namespace:  "default",
+			Finalizers: []string{machinev1.MachineFinalizer},
+			Labels: map[string]string{
+				machinev1.MachineClusterIDLabel: "testcluster",
+			},
+		},
+		Spec: machinev1.MachineSpec{
+			ProviderSpec: machinev1.ProviderSpec{
+				Value: &runtime.RawExtension{
+					Raw: []byte("{}"),
+				},
+			},
+		},
+		Status: machinev1.MachineStatus{
+			Phase: pointer.StringPtr(phaseProvisioning),
+		},
+	}
+
+	now := time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+	actuator := &lastOperationActuator{}
+	r := &ReconcileMachine{
+		Client:        fake.NewFakeClientWithScheme(scheme.Scheme, m),
+		scheme:        scheme.Scheme,
+		eventRecorder: record.NewFakeRecorder(32),
+		actuator:      actuator,
+		nowFunc:       func() time.Time { return now },
+	}
+
+	reconcileMachine := func() error {
+		_, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: client.ObjectKeyFromObject(m)})
+		return err
+	}
+
+	expectLastOperation := func(operationType, state, description string, lastUpdated time.Time) {
+		t.Helper()
+		got := &machinev1.Machine{}
+		if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(m), got); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		lastOperation := got.Status.LastOperation
+		if lastOperation == nil || lastOperation.LastUpdated == nil {
+			t.Fatalf("expected the last operation to be recorded, got: %+v", lastOperation)
+		}
+		if pointer.StringPtrDerefOr(lastOperation.Type, "") != operationType ||
+			pointer.StringPtrDerefOr(lastOperation.State, "") != state ||
+			pointer.StringPtrDerefOr(lastOperation.Description, "") != description ||
+			!lastOperation.LastUpdated.Time.Equal(lastUpdated) {
+			t.Errorf("expected last operation %s %s %q at %v, got: %s %s %q at %v", operationType, state, description, lastUpdated,
+				*lastOperation.Type, *lastOperation.State, *lastOperation.Description, lastOperation.LastUpdated.Time)
+		}
+	}
+
+	// The create fails, the failure is recorded even though the status is not updated otherwise.
+	actuator.createErr = errors.New("quota exceeded")
+	if result, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: client.ObjectKeyFromObject(m)}); err != nil || result.RequeueAfter == 0 {
+		t.Fatalf("expected the failed create to be retried after a delay, got: %+v, %v", result, err)
+	}
+	expectLastOperation(lastOperationTypeCreate, lastOperationStateFailed, "Create failed: CreateError: quota exceeded", now)
+
+	// A failure classified by the actuator is recorded with its reason.
+	now = now.Add(time.Minute)
+	actuator.createErr = InvalidMachineConfiguration("unknown instance type")
+	if err := reconcileMachine(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectLastOperation(lastOperationTypeCreate, lastOperationStateFailed, "Create failed: InvalidConfiguration: unknown instance type", now)
+
+	// Failed machines are not reconciled, start over from provisioning.
+	got := &machinev1.Machine{}
+	if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(m), got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got.Status.Phase = pointer.StringPtr(phaseProvisioning)
+	if err := r.Client.Status().Update(context.TODO(), got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The actuator asks to be requeued.
+	now = now.Add(time.Minute)
+	actuator.createErr = &RequeueAfterError{RequeueAfter: 20 * time.Second}
+	if err := reconcileMachine(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectLastOperation(lastOperationTypeCreate, lastOperationStateProcessing, "Create in progress, retrying in 20s", now)
+
+	// The retry succeeds.
+	now = now.Add(time.Minute)
+	createdAt := now
+	actuator.createErr = nil
+	if err := reconcileMachine(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectLastOperation(lastOperationTypeCreate, lastOperationStateSuccessful, "Created the instance", createdAt)
+
+	// The instance is updated on the following reconciles, an unchanged operation is not recorded again.
+	actuator.exists = true
+	now = now.Add(time.Minute)
+	updatedAt := now
+	if err := reconcileMachine(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectLastOperation(lastOperationTypeUpdate, lastOperationStateSuccessful, "Updated the instance", updatedAt)
+
+	now = now.Add(time.Minute)
+	if err := reconcileMachine(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectLastOperation(lastOperationTypeUpdate, lastOperationStateSuccessful, "Updated the instance", updatedAt)
+
+	now = now.Add(time.Minute)
+	actuator.updateErr = UpdateMachine("instance not reachable")
+	if err := reconcileMachine(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectLastOperation(lastOperationTypeUpdate, lastOperationStateFailed, "Update failed: UpdateError: instance not reachable", now)
+
+	// Deletion is requested, then completes once the instance is gone.
+	if err := r.Client.Delete(context.TODO(), got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now = now.Add(time.Minute)
+	if err := reconcileMachine(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectLastOperation(lastOperationTypeDelete, lastOperationStateRequested, "Requested the termination of the instance", now)
+
+	actuator.exists = false
+	if err := reconcileMachine(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(m), got); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the machine to be deleted, got: %v", err)
+	}
+}
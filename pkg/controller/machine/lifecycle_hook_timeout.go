@@ -0,0 +1,160 @@
+package machine
+
+import (
+	"strconv"
+	"time"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/conditions"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// LifecycleHookTimeoutAnnotation is set on a Machine to bound, in seconds, how long its pre-drain hooks,
+	// and then its pre-terminate hooks, may block its deletion
+	LifecycleHookTimeoutAnnotation = "machine.openshift.io/lifecycle-hook-timeout-seconds"
+
+	// LifecycleHookTimeoutActionAnnotation is set on a Machine to choose what happens once its lifecycle hooks
+	// time out: LifecycleHookTimeoutActionBlock, the default, or LifecycleHookTimeoutActionProceed
+	LifecycleHookTimeoutActionAnnotation = "machine.openshift.io/lifecycle-hook-timeout-action"
+
+	// LifecycleHookTimeoutActionBlock keeps the deletion blocked by timed out lifecycle hooks
+	LifecycleHookTimeoutActionBlock = "Block"
+
+	// LifecycleHookTimeoutActionProceed carries on with the deletion regardless of timed out lifecycle hooks
+	LifecycleHookTimeoutActionProceed = "Proceed"
+
+	// MachineHookTimedOutReason is the reason of the Drainable and Terminable conditions of machines
+	// whose lifecycle hooks blocked their deletion for longer than their timeout
+	MachineHookTimedOutReason = "HookTimedOut"
+)
+
+// lifecycleHookTimeout is the timeout of the lifecycle hooks of a machine.
+type lifecycleHookTimeout struct {
+	timeout time.Duration
+	proceed bool
+}
+
+// lifecycleHookTimeoutOf returns the lifecycle hook timeout set on the machine, if any.
+// Invalid annotations are ignored, leaving the hooks to block the deletion until they are removed.
+func lifecycleHookTimeoutOf(m *machinev1.Machine) (lifecycleHookTimeout, bool) {
+	value, ok := m.Annotations[LifecycleHookTimeoutAnnotation]
+	if !ok {
+		return lifecycleHookTimeout{}, false
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 1 {
+		klog.Warningf("%v: ignoring invalid %s annotation %q: must be a positive integer", m.GetName(), LifecycleHookTimeoutAnnotation, value)
+		return lifecycleHookTimeout{}, false
+	}
+
+	timeout := lifecycleHookTimeout{timeout: time.Duration(seconds) * time.Second}
+	switch action := m.Annotations[LifecycleHookTimeoutActionAnnotation]; action {
+	case "", LifecycleHookTimeoutActionBlock:
+	case LifecycleHookTimeoutActionProceed:
+		timeout.proceed = true
+	default:
+		klog.Warningf("%v: ignoring invalid %s annotation %q: must be %s or %s", m.GetName(), LifecycleHookTimeoutActionAnnotation, action, LifecycleHookTimeoutActionBlock, LifecycleHookTimeoutActionProceed)
+	}
+	return timeout, true
+}
+
+// lifecycleHooksBlockedSince returns when the hooks blocking the operation of the condition type started
+// blocking the deletion of the machine: the deletion for the pre-drain hooks, and the end of the drain,
+// if any, including a forced one, for the pre-terminate hooks.
+func lifecycleHooksBlockedSince(m *machinev1.Machine, conditionType machinev1.ConditionType) time.Time {
+	since := m.DeletionTimestamp.Time
+	if conditionType == machinev1.MachineTerminable {
+		drained := conditions.Get(m, machinev1.MachineDrained)
+		if drained != nil && (drained.Status == corev1.ConditionTrue || drained.Reason == MachineDrainForcedReason) && drained.LastTransitionTime.After(since) {
+			since = drained.LastTransitionTime.Time
+		}
+	}
+	return since
+}
+
+// lifecycleHooksRemaining returns how long the hooks blocking the operation of the condition type may still
+// block the deletion of the machine, and whether the machine sets a lifecycle hook timeout at all.
+func lifecycleHooksRemaining(m *machinev1.Machine, conditionType machinev1.ConditionType, now time.Time) (time.Duration, lifecycleHookTimeout, bool) {
+	if m.DeletionTimestamp.IsZero() {
+		return 0, lifecycleHookTimeout{}, false
+	}
+	timeout, ok := lifecycleHookTimeoutOf(m)
+	if !ok {
+		return 0, lifecycleHookTimeout{}, false
+	}
+	return lifecycleHooksBlockedSince(m, conditionType).Add(timeout.timeout).Sub(now), timeout, true
+}
+
+// setLifecycleHookTimeoutConditions marks the Drainable and Terminable conditions of the machine
+// as timed out once their hooks blocked the deletion for longer than the timeout.
+// It must be called after setLifecycleHookConditions.
+func setLifecycleHookTimeoutConditions(m *machinev1.Machine, now time.Time) {
+	for conditionType, hooks := range map[machinev1.ConditionType][]machinev1.LifecycleHook{
+		machinev1.MachineDrainable:  m.Spec.LifecycleHooks.PreDrain,
+		machinev1.MachineTerminable: m.Spec.LifecycleHooks.PreTerminate,
+	} {
+		if len(hooks) == 0 {
+			continue
+		}
+		remaining, timeout, ok := lifecycleHooksRemaining(m, conditionType, now)
+		if !ok || remaining > 0 {
+			continue
+		}
+
+		if timeout.proceed {
+			conditions.Set(m, conditions.FalseCondition(
+				conditionType,
+				MachineHookTimedOutReason,
+				machinev1.ConditionSeverityWarning,
+				"Proceeding after the lifecycle hook timeout of %v despite: %+v", timeout.timeout, hooks,
+			))
+		} else {
+			conditions.Set(m, conditions.FalseCondition(
+				conditionType,
+				MachineHookTimedOutReason,
+				machinev1.ConditionSeverityError,
+				"Blocked for longer than the lifecycle hook timeout of %v by: %+v", timeout.timeout, hooks,
+			))
+		}
+	}
+}
+
+// lifecycleHooksBlock returns whether the hooks blocking the operation of the condition type still block
+// the deletion of the machine, and when to reconcile the machine again to check their timeout.
+// An event is recorded when the hooks time out, unless originalConditions report the timeout already.
+func (r *ReconcileMachine) lifecycleHooksBlock(m *machinev1.Machine, conditionType machinev1.ConditionType, hooks []machinev1.LifecycleHook, originalConditions machinev1.Conditions) (bool, reconcile.Result) {
+	remaining, timeout, ok := lifecycleHooksRemaining(m, conditionType, r.now())
+	if !ok {
+		return true, reconcile.Result{}
+	}
+	if remaining > 0 {
+		return true, reconcile.Result{RequeueAfter: remaining}
+	}
+
+	if !lifecycleHookTimeoutReported(originalConditions, conditionType) {
+		action := LifecycleHookTimeoutActionBlock
+		if timeout.proceed {
+			action = LifecycleHookTimeoutActionProceed
+		}
+		r.eventRecorder.Eventf(m, corev1.EventTypeWarning, "LifecycleHookTimedOut", "%s operation blocked for longer than %v by lifecycle hooks %+v: %s", conditionType, timeout.timeout, hooks, action)
+	}
+	return !timeout.proceed, reconcile.Result{}
+}
+
+// lifecycleHookTimeoutReported returns whether the condition of the type reports timed out hooks.
+func lifecycleHookTimeoutReported(machineConditions machinev1.Conditions, conditionType machinev1.ConditionType) bool {
+	return hasConditionReason(machineConditions, conditionType, MachineHookTimedOutReason)
+}
+
+// hasConditionReason returns whether the condition of the type has the reason.
+func hasConditionReason(machineConditions machinev1.Conditions, conditionType machinev1.ConditionType, reason string) bool {
+	for _, condition := range machineConditions {
+		if condition.Type == conditionType {
+			return condition.Reason == reason
+		}
+	}
+	return false
+}
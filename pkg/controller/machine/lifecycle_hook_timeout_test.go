@@ -0,0 +1,222 @@
+package machine
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/conditions"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcileLifecycleHookTimeout(t *testing.T) {
+	machinev1.AddToScheme(scheme.Scheme)
+
+	now := time.Now().Truncate(time.Second)
+	hook := machinev1.LifecycleHook{Name: "protect", Owner: "machine-api-tests"}
+
+	testCases := []struct {
+		name              string
+		annotations       map[string]string
+		preDrain          bool
+		preTerminate      bool
+		deletedAgo        time.Duration
+		reportedTimeout   bool
+		expectedDrained   bool
+		expectedDeleted   bool
+		expectedRequeue   time.Duration
+		expectedReason    string
+		expectedCondition machinev1.ConditionType
+		expectedEvent     bool
+	}{
+		{
+			name:              "pre-drain hook without a timeout",
+			preDrain:          true,
+			deletedAgo:        time.Hour,
+			expectedReason:    machinev1.MachineHookPresent,
+			expectedCondition: machinev1.MachineDrainable,
+		},
+		{
+			name:              "pre-drain hook before the timeout",
+			annotations:       map[string]string{LifecycleHookTimeoutAnnotation: "600"},
+			preDrain:          true,
+			deletedAgo:        time.Minute,
+			expectedRequeue:   9 * time.Minute,
+			expectedReason:    machinev1.MachineHookPresent,
+			expectedCondition: machinev1.MachineDrainable,
+		},
+		{
+			name:              "pre-drain hook after the timeout",
+			annotations:       map[string]string{LifecycleHookTimeoutAnnotation: "600"},
+			preDrain:          true,
+			deletedAgo:        time.Hour,
+			expectedReason:    MachineHookTimedOutReason,
+			expectedCondition: machinev1.MachineDrainable,
+			expectedEvent:     true,
+		},
+		{
+			name:              "pre-drain hook after a timeout reported already",
+			annotations:       map[string]string{LifecycleHookTimeoutAnnotation: "600"},
+			preDrain:          true,
+			deletedAgo:        time.Hour,
+			reportedTimeout:   true,
+			expectedReason:    MachineHookTimedOutReason,
+			expectedCondition: machinev1.MachineDrainable,
+		},
+		{
+			name:            "pre-drain hook after the timeout with the proceed action",
+			annotations:     map[string]string{LifecycleHookTimeoutAnnotation: "600", LifecycleHookTimeoutActionAnnotation: LifecycleHookTimeoutActionProceed},
+			preDrain:        true,
+			deletedAgo:      time.Hour,
+			expectedDrained: true,
+			expectedDeleted: true,
+			expectedEvent:   true,
+		},
+		{
+			name:            "pre-terminate hook after the timeout with the proceed action",
+			annotations:     map[string]string{LifecycleHookTimeoutAnnotation: "600", LifecycleHookTimeoutActionAnnotation: LifecycleHookTimeoutActionProceed},
+			preTerminate:    true,
+			deletedAgo:      time.Hour,
+			expectedDrained: true,
+			expectedDeleted: true,
+			expectedEvent:   true,
+		},
+		{
+			name:              "pre-terminate hook after an invalid timeout",
+			annotations:       map[string]string{LifecycleHookTimeoutAnnotation: "ten minutes"},
+			preTerminate:      true,
+			deletedAgo:        time.Hour,
+			expectedDrained:   true,
+			expectedReason:    machinev1.MachineHookPresent,
+			expectedCondition: machinev1.MachineTerminable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			deletionTimestamp := metav1.NewTime(now.Add(-tc.deletedAgo))
+			m := &machinev1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "machine",
+					Namespace:         "default",
+					Annotations:       tc.annotations,
+					DeletionTimestamp: &deletionTimestamp,
+					Finalizers:        []string{machinev1.MachineFinalizer},
+					Labels: map[string]string{
+						machinev1.MachineClusterIDLabel: "testcluster",
+					},
+				},
+				Spec: machinev1.MachineSpec{
+					ProviderSpec: machinev1.ProviderSpec{
+						Value: &runtime.RawExtension{
+							Raw: []byte("{}"),
+						},
+					},
+				},
+				Status: machinev1.MachineStatus{
+					NodeRef: &corev1.ObjectReference{Name: "node"},
+				},
+			}
+			if tc.preDrain {
+				m.Spec.LifecycleHooks.PreDrain = []machinev1.LifecycleHook{hook}
+			}
+			if tc.preTerminate {
+				m.Spec.LifecycleHooks.PreTerminate = []machinev1.LifecycleHook{hook}
+				// The pre-terminate hooks time out from the end of the drain, which does not change when draining again.
+				m.Status.Conditions = machinev1.Conditions{{
+					Type:               machinev1.MachineDrained,
+					Status:             corev1.ConditionTrue,
+					LastTransitionTime: deletionTimestamp,
+				}}
+			}
+			if tc.reportedTimeout {
+				m.Status.Conditions = machinev1.Conditions{{
+					Type:   machinev1.MachineDrainable,
+					Status: corev1.ConditionFalse,
+					Reason: MachineHookTimedOutReason,
+				}}
+			}
+
+			act := newTestActuator()
+			recorder := record.NewFakeRecorder(8)
+			drained := false
+			r := &ReconcileMachine{
+				Client:        fake.NewFakeClientWithScheme(scheme.Scheme, m),
+				scheme:        scheme.Scheme,
+				eventRecorder: recorder,
+				actuator:      act,
+				drainNodeFunc: func(context.Context, *machinev1.Machine) error {
+					drained = true
+					return nil
+				},
+				nowFunc: func() time.Time { return now },
+			}
+
+			result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(m)})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.RequeueAfter != tc.expectedRequeue {
+				t.Errorf("expected to requeue after %v, got: %v", tc.expectedRequeue, result.RequeueAfter)
+			}
+			if drained != tc.expectedDrained {
+				t.Errorf("expected drained: %v, got: %v", tc.expectedDrained, drained)
+			}
+			if deleted := act.DeleteCallCount > 0; deleted != tc.expectedDeleted {
+				t.Errorf("expected deleted: %v, got: %v", tc.expectedDeleted, deleted)
+			}
+
+			var event string
+			select {
+			case event = <-recorder.Events:
+			default:
+			}
+			if gotEvent := strings.Contains(event, "LifecycleHookTimedOut"); gotEvent != tc.expectedEvent {
+				t.Errorf("expected a timeout event: %v, got: %q", tc.expectedEvent, event)
+			}
+
+			if tc.expectedReason == "" {
+				return
+			}
+			got := &machinev1.Machine{}
+			if err := r.Client.Get(ctx, client.ObjectKeyFromObject(m), got); err != nil {
+				t.Fatal(err)
+			}
+			condition := conditions.Get(got, tc.expectedCondition)
+			if condition == nil || condition.Reason != tc.expectedReason {
+				t.Errorf("expected the %s condition reason to be %s, got: %+v", tc.expectedCondition, tc.expectedReason, condition)
+			}
+		})
+	}
+}
+
+func TestLifecycleHooksBlockedSince(t *testing.T) {
+	deletionTimestamp := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	drainedAt := metav1.NewTime(deletionTimestamp.Add(10 * time.Minute))
+	m := &machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &deletionTimestamp},
+		Status: machinev1.MachineStatus{
+			Conditions: machinev1.Conditions{{
+				Type:               machinev1.MachineDrained,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: drainedAt,
+			}},
+		},
+	}
+
+	if since := lifecycleHooksBlockedSince(m, machinev1.MachineDrainable); !since.Equal(deletionTimestamp.Time) {
+		t.Errorf("expected the pre-drain hooks to block since the deletion, got: %v", since)
+	}
+	if since := lifecycleHooksBlockedSince(m, machinev1.MachineTerminable); !since.Equal(drainedAt.Time) {
+		t.Errorf("expected the pre-terminate hooks to block since the drain, got: %v", since)
+	}
+}
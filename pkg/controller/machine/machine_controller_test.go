@@ -61,8 +61,8 @@ func TestReconcile(t *testing.T) {
 	c = mgr.GetClient()
 
 	a := newTestActuator()
-	recFn := newReconciler(mgr, a)
-	if err := add(mgr, recFn); err != nil {
+	recFn := newReconciler(mgr, a, Options{})
+	if err := add(mgr, recFn, Options{}); err != nil {
 		t.Fatalf("error adding controller to manager: %v", err)
 	}
 
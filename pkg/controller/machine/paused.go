@@ -0,0 +1,54 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/annotations"
+	"github.com/openshift/machine-api-operator/pkg/util/conditions"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// MachinePausedCondition is true on machines whose reconciliation is paused by the
+	// machine.openshift.io/paused annotation
+	MachinePausedCondition machinev1.ConditionType = "Paused"
+
+	// MachinePausedReason is the reason of the Paused condition of paused machines
+	MachinePausedReason = "PausedByAnnotation"
+)
+
+// reconcilePaused sets the Paused condition of the machine from its annotation, and returns whether its
+// reconciliation, including the drain of its node, is paused.
+func (r *ReconcileMachine) reconcilePaused(ctx context.Context, m *machinev1.Machine) (bool, error) {
+	originalConditions := m.Status.Conditions.DeepCopy()
+
+	paused := annotations.IsMachinePaused(m)
+	switch {
+	case paused:
+		conditions.Set(m, &machinev1.Condition{
+			Type:    MachinePausedCondition,
+			Status:  corev1.ConditionTrue,
+			Reason:  MachinePausedReason,
+			Message: fmt.Sprintf("Reconciliation is paused by the %s annotation", annotations.MachinePausedAnnotation),
+		})
+	case conditions.Get(m, MachinePausedCondition) != nil:
+		conditions.Set(m, &machinev1.Condition{
+			Type:   MachinePausedCondition,
+			Status: corev1.ConditionFalse,
+		})
+	}
+
+	if reflect.DeepEqual(originalConditions, m.Status.Conditions) {
+		return paused, nil
+	}
+	baseMachine := m.DeepCopy()
+	baseMachine.Status.Conditions = originalConditions
+	if err := r.Client.Status().Patch(ctx, m, client.MergeFrom(baseMachine)); err != nil {
+		return paused, fmt.Errorf("failed to update the paused condition: %w", err)
+	}
+	return paused, nil
+}
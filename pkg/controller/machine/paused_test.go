@@ -0,0 +1,84 @@
+package machine
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/annotations"
+	"github.com/openshift/machine-api-operator/pkg/util/conditions"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcilePaused(t *testing.T) {
+	g := NewWithT(t)
+	machinev1.AddToScheme(scheme.Scheme)
+
+	m := &machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "machine",
+			Namespace:  "default",
+			Finalizers: []string{machinev1.MachineFinalizer},
+			Labels: map[string]string{
+				machinev1.MachineClusterIDLabel: "testcluster",
+			},
+			Annotations: map[string]string{
+				annotations.MachinePausedAnnotation: "",
+			},
+		},
+		Spec: machinev1.MachineSpec{
+			ProviderSpec: machinev1.ProviderSpec{
+				Value: &runtime.RawExtension{
+					Raw: []byte("{}"),
+				},
+			},
+		},
+		Status: machinev1.MachineStatus{
+			Phase: pointer.StringPtr(phaseProvisioning),
+		},
+	}
+
+	r := &ReconcileMachine{
+		Client:        fake.NewFakeClientWithScheme(scheme.Scheme, m),
+		scheme:        scheme.Scheme,
+		eventRecorder: record.NewFakeRecorder(32),
+		actuator:      &lastOperationActuator{createErr: InvalidMachineConfiguration("unknown instance type")},
+	}
+
+	reconcileMachine := func() *machinev1.Machine {
+		result, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: client.ObjectKeyFromObject(m)})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Requeue).To(BeFalse())
+
+		got := &machinev1.Machine{}
+		g.Expect(r.Client.Get(context.TODO(), client.ObjectKeyFromObject(m), got)).To(Succeed())
+		return got
+	}
+
+	// The paused machine is not reconciled, so the create error does not fail it.
+	got := reconcileMachine()
+	g.Expect(got.Status.Phase).To(Equal(pointer.StringPtr(phaseProvisioning)))
+	paused := conditions.Get(got, MachinePausedCondition)
+	g.Expect(paused).ToNot(BeNil())
+	g.Expect(paused.Status).To(Equal(corev1.ConditionTrue))
+	g.Expect(paused.Reason).To(Equal(MachinePausedReason))
+
+	// Reconciliation resumes once the annotation is removed.
+	delete(got.Annotations, annotations.MachinePausedAnnotation)
+	g.Expect(r.Client.Update(context.TODO(), got)).To(Succeed())
+
+	got = reconcileMachine()
+	g.Expect(got.Status.Phase).To(Equal(pointer.StringPtr(phaseFailed)))
+	paused = conditions.Get(got, MachinePausedCondition)
+	g.Expect(paused).ToNot(BeNil())
+	g.Expect(paused.Status).To(Equal(corev1.ConditionFalse))
+}
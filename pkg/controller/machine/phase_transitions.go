@@ -0,0 +1,49 @@
+package machine
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/metrics"
+	"github.com/openshift/machine-api-operator/pkg/util/machines"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// PhaseTransitionsAnnotation records when a Machine last entered each of its phases.
+	PhaseTransitionsAnnotation = machines.PhaseTransitionsAnnotation
+)
+
+// patchPhaseTransition records that the machine entered the phase now.
+func (r *ReconcileMachine) patchPhaseTransition(ctx context.Context, m *machinev1.Machine, phase string, now time.Time) error {
+	transitions := machines.PhaseTransitions(m)
+	transitions[phase] = metav1.NewTime(now)
+	value, err := json.Marshal(transitions)
+	if err != nil {
+		return err
+	}
+
+	baseToPatch := client.MergeFrom(m.DeepCopy())
+	if m.Annotations == nil {
+		m.Annotations = map[string]string{}
+	}
+	m.Annotations[PhaseTransitionsAnnotation] = string(value)
+	return r.Client.Patch(ctx, m, baseToPatch)
+}
+
+// observePhaseTransition updates the phase transition metrics of the machine which entered the phase now,
+// leaving previousPhase which it entered at previousEnteredAt, when known.
+func observePhaseTransition(m *machinev1.Machine, previousPhase, phase string, previousEnteredAt time.Time, previousKnown bool, now time.Time) {
+	// Deleting would always end up in the infinite bucket
+	if phase != phaseDeleting {
+		timeElapsed := now.Sub(m.GetCreationTimestamp().Time).Seconds()
+		metrics.MachinePhaseTransitionSeconds.With(map[string]string{"phase": phase}).Observe(timeElapsed)
+	}
+	if previousPhase != "" && previousKnown {
+		duration := now.Sub(previousEnteredAt).Seconds()
+		metrics.MachinePhaseDurationSeconds.With(map[string]string{"from": previousPhase, "to": phase}).Observe(duration)
+	}
+}
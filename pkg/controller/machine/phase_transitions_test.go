@@ -0,0 +1,142 @@
+package machine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/metrics"
+	"github.com/openshift/machine-api-operator/pkg/util/machines"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func phaseDurationSampleCount(t *testing.T, from, to string) uint64 {
+	metric := &dto.Metric{}
+	observer := metrics.MachinePhaseDurationSeconds.With(map[string]string{"from": from, "to": to})
+	if err := observer.(prometheus.Metric).Write(metric); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return metric.GetHistogram().GetSampleCount()
+}
+
+func TestUpdateStatusPhaseTransitions(t *testing.T) {
+	machinev1.AddToScheme(scheme.Scheme)
+
+	created := time.Now().Add(-time.Hour).Truncate(time.Second)
+	m := &machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "phase-transitions",
+			Namespace:         "default",
+			CreationTimestamp: metav1.NewTime(created),
+		},
+	}
+
+	now := created
+	r := &ReconcileMachine{
+		Client:  fake.NewFakeClientWithScheme(scheme.Scheme, m),
+		scheme:  scheme.Scheme,
+		nowFunc: func() time.Time { return now },
+	}
+
+	provisionedCount := phaseDurationSampleCount(t, phaseProvisioning, phaseProvisioned)
+	runningCount := phaseDurationSampleCount(t, phaseProvisioned, phaseRunning)
+
+	transitions := []struct {
+		phase string
+		after time.Duration
+	}{
+		{phase: phaseProvisioning, after: 5 * time.Second},
+		{phase: phaseProvisioned, after: 90 * time.Second},
+		// Updating the status without changing the phase is not a transition
+		{phase: phaseProvisioned, after: 30 * time.Second},
+		{phase: phaseRunning, after: 2 * time.Minute},
+	}
+	for _, transition := range transitions {
+		now = now.Add(transition.after)
+		if err := r.updateStatus(context.TODO(), m, transition.phase, nil, m.Status.Conditions.DeepCopy()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	got := &machinev1.Machine{}
+	if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(m), got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]time.Time{
+		phaseProvisioning: created.Add(5 * time.Second),
+		phaseProvisioned:  created.Add(95 * time.Second),
+		phaseRunning:      created.Add(245 * time.Second),
+	}
+	recorded := machines.PhaseTransitions(got)
+	if len(recorded) != len(expected) {
+		t.Errorf("expected %d phase transitions, got: %v", len(expected), recorded)
+	}
+	for phase, enteredAt := range expected {
+		if !recorded[phase].Time.Equal(enteredAt) {
+			t.Errorf("expected the machine to enter phase %s at %v, got: %v", phase, enteredAt, recorded[phase])
+		}
+	}
+
+	if count := phaseDurationSampleCount(t, phaseProvisioning, phaseProvisioned); count != provisionedCount+1 {
+		t.Errorf("expected 1 observation of the Provisioning to Provisioned transition, got: %d", count-provisionedCount)
+	}
+	if count := phaseDurationSampleCount(t, phaseProvisioned, phaseRunning); count != runningCount+1 {
+		t.Errorf("expected 1 observation of the Provisioned to Running transition, got: %d", count-runningCount)
+	}
+}
+
+func TestUpdateStatusPhaseTransitionsAfterRestart(t *testing.T) {
+	machinev1.AddToScheme(scheme.Scheme)
+
+	created := time.Now().Add(-time.Hour).Truncate(time.Second)
+	m := &machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "phase-transitions-after-restart",
+			Namespace:         "default",
+			CreationTimestamp: metav1.NewTime(created),
+			Annotations: map[string]string{
+				PhaseTransitionsAnnotation: `{"Provisioned":"` + created.Add(4*time.Minute).UTC().Format(time.RFC3339) + `"}`,
+			},
+		},
+		Status: machinev1.MachineStatus{Phase: pointer.StringPtr(phaseProvisioned)},
+	}
+	unrecorded := &machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "phase-transitions-unrecorded",
+			Namespace:         "default",
+			CreationTimestamp: metav1.NewTime(created),
+		},
+		Status: machinev1.MachineStatus{Phase: pointer.StringPtr(phaseProvisioned)},
+	}
+	now := created.Add(10 * time.Minute)
+	r := &ReconcileMachine{
+		Client:  fake.NewFakeClientWithScheme(scheme.Scheme, m, unrecorded),
+		scheme:  scheme.Scheme,
+		nowFunc: func() time.Time { return now },
+	}
+
+	// A new controller reads when the machine entered the Provisioned phase from the machine.
+	count := phaseDurationSampleCount(t, phaseProvisioned, phaseRunning)
+	if err := r.updateStatus(context.TODO(), m, phaseRunning, nil, m.Status.Conditions.DeepCopy()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := phaseDurationSampleCount(t, phaseProvisioned, phaseRunning); got != count+1 {
+		t.Errorf("expected 1 observation of the Provisioned to Running transition, got: %d", got-count)
+	}
+
+	// The duration of a phase entered before the transitions were recorded is unknown.
+	count = phaseDurationSampleCount(t, phaseProvisioned, phaseRunning)
+	if err := r.updateStatus(context.TODO(), unrecorded, phaseRunning, nil, unrecorded.Status.Conditions.DeepCopy()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := phaseDurationSampleCount(t, phaseProvisioned, phaseRunning); got != count {
+		t.Errorf("expected no observation of the Provisioned to Running transition, got: %d", got-count)
+	}
+}
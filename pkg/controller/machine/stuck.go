@@ -0,0 +1,161 @@
+package machine
+
+import (
+	"fmt"
+	"time"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/metrics"
+	"github.com/openshift/machine-api-operator/pkg/util"
+	"github.com/openshift/machine-api-operator/pkg/util/conditions"
+	"github.com/openshift/machine-api-operator/pkg/util/machines"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// MachineDegradedCondition is true on machines stuck in the Provisioning or Deleting phase
+	// for longer than the thresholds of the machine controller
+	MachineDegradedCondition machinev1.ConditionType = "Degraded"
+
+	// DefaultStuckProvisioningThreshold is how long a machine may be provisioning before it is stuck
+	DefaultStuckProvisioningThreshold = 30 * time.Minute
+
+	// DefaultStuckDeletingThreshold is how long a machine may be deleting before it is stuck
+	DefaultStuckDeletingThreshold = time.Hour
+)
+
+// Reasons of the Degraded condition of stuck machines, telling what they wait for.
+const (
+	StuckWaitingForInstanceCreationReason    = "WaitingForInstanceCreation"
+	StuckInstanceCreationFailingReason       = "InstanceCreationFailing"
+	StuckWaitingForPreDrainHookReason        = "WaitingForPreDrainHook"
+	StuckWaitingForDrainReason               = "WaitingForDrain"
+	StuckWaitingForPreTerminateHookReason    = "WaitingForPreTerminateHook"
+	StuckWaitingForInstanceTerminationReason = "WaitingForInstanceTermination"
+)
+
+// stuckThresholdOrDefault returns the stuck threshold configured by the controller options,
+// the default one when unset, or zero when disabled by a negative threshold.
+func stuckThresholdOrDefault(threshold, defaultThreshold time.Duration) time.Duration {
+	switch {
+	case threshold < 0:
+		return 0
+	case threshold == 0:
+		return defaultThreshold
+	}
+	return threshold
+}
+
+// stuckThreshold returns since when the machine is in the phase and how long it may stay in it.
+// It returns false when the machine cannot get stuck in the phase.
+func (r *ReconcileMachine) stuckThreshold(m *machinev1.Machine, phase string) (time.Time, time.Duration, bool) {
+	switch phase {
+	case phaseProvisioning:
+		enteredAt, _ := machines.PhaseEnteredAt(m, phaseProvisioning)
+		return enteredAt, r.stuckProvisioningThreshold, r.stuckProvisioningThreshold > 0
+	case phaseDeleting:
+		if m.DeletionTimestamp.IsZero() || !util.Contains(m.Finalizers, machinev1.MachineFinalizer) {
+			return time.Time{}, 0, false
+		}
+		return m.DeletionTimestamp.Time, r.stuckDeletingThreshold, r.stuckDeletingThreshold > 0
+	}
+	return time.Time{}, 0, false
+}
+
+// stuckReason returns what the machine waits for to leave the phase.
+func stuckReason(m *machinev1.Machine, phase string) string {
+	if phase == phaseProvisioning {
+		if lastOperation := m.Status.LastOperation; lastOperation != nil &&
+			pointer.StringPtrDerefOr(lastOperation.Type, "") == lastOperationTypeCreate &&
+			pointer.StringPtrDerefOr(lastOperation.State, "") == lastOperationStateFailed {
+			return StuckInstanceCreationFailingReason
+		}
+		return StuckWaitingForInstanceCreationReason
+	}
+
+	drainable := conditions.Get(m, machinev1.MachineDrainable)
+	drained := conditions.Get(m, machinev1.MachineDrained)
+	_, excluded := m.Annotations[ExcludeNodeDrainingAnnotation]
+	switch {
+	case drainable != nil && drainable.Reason == MachineBlockedByPDBReason:
+		return MachineBlockedByPDBReason
+	case len(m.Spec.LifecycleHooks.PreDrain) > 0 && (drainable == nil || drainable.Status != corev1.ConditionTrue):
+		return StuckWaitingForPreDrainHookReason
+	case m.Status.NodeRef != nil && !excluded && (drained == nil || drained.Status != corev1.ConditionTrue):
+		return StuckWaitingForDrainReason
+	case len(m.Spec.LifecycleHooks.PreTerminate) > 0:
+		return StuckWaitingForPreTerminateHookReason
+	}
+	return StuckWaitingForInstanceTerminationReason
+}
+
+// setStuckCondition sets the Degraded condition of machines stuck in the phase, and clears it once they are no longer stuck.
+func (r *ReconcileMachine) setStuckCondition(m *machinev1.Machine, phase string) {
+	since, threshold, ok := r.stuckThreshold(m, phase)
+	if ok && r.now().Sub(since) >= threshold {
+		reason := stuckReason(m, phase)
+		conditions.Set(m, &machinev1.Condition{
+			Type:     MachineDegradedCondition,
+			Status:   corev1.ConditionTrue,
+			Severity: machinev1.ConditionSeverityWarning,
+			Reason:   reason,
+			Message:  fmt.Sprintf("Machine stuck in phase %s for more than %v", phase, threshold),
+		})
+		return
+	}
+
+	if conditions.Get(m, MachineDegradedCondition) != nil {
+		conditions.Set(m, &machinev1.Condition{
+			Type:   MachineDegradedCondition,
+			Status: corev1.ConditionFalse,
+		})
+	}
+}
+
+// observeStuck counts the machine as stuck in the phase once it becomes stuck, or stuck for another reason,
+// since originalConditions.
+func observeStuck(m *machinev1.Machine, phase string, originalConditions machinev1.Conditions) {
+	degraded := conditions.Get(m, MachineDegradedCondition)
+	if degraded == nil || degraded.Status != corev1.ConditionTrue {
+		return
+	}
+	for _, condition := range originalConditions {
+		if condition.Type == MachineDegradedCondition && condition.Status == corev1.ConditionTrue && condition.Reason == degraded.Reason {
+			return
+		}
+	}
+	klog.Warningf("%v: machine stuck in phase %q: %s", m.GetName(), phase, degraded.Reason)
+	metrics.MachineStuckTotal.With(map[string]string{"phase": phase, "reason": degraded.Reason}).Inc()
+}
+
+// requeueBeforeStuck requeues the reconcile of a machine which may get stuck in its phase no later than
+// when it gets stuck, so that it is flagged even when nothing else triggers a reconcile.
+func (r *ReconcileMachine) requeueBeforeStuck(m *machinev1.Machine, result reconcile.Result) reconcile.Result {
+	since, threshold, ok := r.stuckThreshold(m, pointer.StringPtrDerefOr(m.Status.Phase, ""))
+	if !ok || (result.Requeue && result.RequeueAfter == 0) {
+		return result
+	}
+	stuckIn := since.Add(threshold).Sub(r.now())
+	if stuckIn > 0 && (result.RequeueAfter == 0 || stuckIn < result.RequeueAfter) {
+		result.RequeueAfter = stuckIn
+	}
+	return result
+}
+
+// stuckConditionChanged returns whether the Degraded condition of the machine differs from originalConditions.
+func stuckConditionChanged(m *machinev1.Machine, originalConditions machinev1.Conditions) bool {
+	var original *machinev1.Condition
+	for i := range originalConditions {
+		if originalConditions[i].Type == MachineDegradedCondition {
+			original = &originalConditions[i]
+		}
+	}
+	current := conditions.Get(m, MachineDegradedCondition)
+	if original == nil || current == nil {
+		return original != current
+	}
+	return original.Status != current.Status || original.Reason != current.Reason || original.Message != current.Message
+}
@@ -0,0 +1,253 @@
+package machine
+
+import (
+	"testing"
+	"time"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/metrics"
+	"github.com/openshift/machine-api-operator/pkg/util/conditions"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func stuckCount(t *testing.T, phase, reason string) float64 {
+	metric := &dto.Metric{}
+	if err := metrics.MachineStuckTotal.With(map[string]string{"phase": phase, "reason": reason}).(prometheus.Metric).Write(metric); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return metric.GetCounter().GetValue()
+}
+
+func TestStuckReason(t *testing.T) {
+	hook := machinev1.LifecycleHook{Name: "hook", Owner: "owner"}
+
+	testCases := []struct {
+		name     string
+		phase    string
+		machine  machinev1.Machine
+		expected string
+	}{
+		{
+			name:     "provisioning",
+			phase:    phaseProvisioning,
+			expected: StuckWaitingForInstanceCreationReason,
+		},
+		{
+			name:  "provisioning with a failed creation",
+			phase: phaseProvisioning,
+			machine: machinev1.Machine{Status: machinev1.MachineStatus{LastOperation: &machinev1.LastOperation{
+				Type:  pointer.StringPtr(lastOperationTypeCreate),
+				State: pointer.StringPtr(lastOperationStateFailed),
+			}}},
+			expected: StuckInstanceCreationFailingReason,
+		},
+		{
+			name:  "deleting with a pre-drain hook",
+			phase: phaseDeleting,
+			machine: machinev1.Machine{
+				Spec:   machinev1.MachineSpec{LifecycleHooks: machinev1.LifecycleHooks{PreDrain: []machinev1.LifecycleHook{hook}}},
+				Status: machinev1.MachineStatus{NodeRef: &corev1.ObjectReference{Name: "node"}},
+			},
+			expected: StuckWaitingForPreDrainHookReason,
+		},
+		{
+			name:  "deleting blocked by a PodDisruptionBudget",
+			phase: phaseDeleting,
+			machine: machinev1.Machine{Status: machinev1.MachineStatus{
+				NodeRef:    &corev1.ObjectReference{Name: "node"},
+				Conditions: machinev1.Conditions{*conditions.FalseCondition(machinev1.MachineDrainable, MachineBlockedByPDBReason, machinev1.ConditionSeverityWarning, "")},
+			}},
+			expected: MachineBlockedByPDBReason,
+		},
+		{
+			name:     "deleting while draining",
+			phase:    phaseDeleting,
+			machine:  machinev1.Machine{Status: machinev1.MachineStatus{NodeRef: &corev1.ObjectReference{Name: "node"}}},
+			expected: StuckWaitingForDrainReason,
+		},
+		{
+			name:  "deleting with a pre-terminate hook",
+			phase: phaseDeleting,
+			machine: machinev1.Machine{
+				Spec: machinev1.MachineSpec{LifecycleHooks: machinev1.LifecycleHooks{PreTerminate: []machinev1.LifecycleHook{hook}}},
+				Status: machinev1.MachineStatus{
+					NodeRef:    &corev1.ObjectReference{Name: "node"},
+					Conditions: machinev1.Conditions{*conditions.TrueCondition(machinev1.MachineDrained)},
+				},
+			},
+			expected: StuckWaitingForPreTerminateHookReason,
+		},
+		{
+			name:     "deleting without a node",
+			phase:    phaseDeleting,
+			expected: StuckWaitingForInstanceTerminationReason,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := stuckReason(&tc.machine, tc.phase); got != tc.expected {
+				t.Errorf("expected reason %q, got: %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestReconcileStuckDeleting(t *testing.T) {
+	machinev1.AddToScheme(scheme.Scheme)
+
+	now := time.Now().Truncate(time.Second)
+
+	testCases := []struct {
+		name            string
+		deletedAgo      time.Duration
+		expectedStuck   bool
+		expectedRequeue time.Duration
+	}{
+		{
+			name:            "deleting within the threshold",
+			deletedAgo:      10 * time.Minute,
+			expectedRequeue: 50 * time.Minute,
+		},
+		{
+			name:          "deleting for longer than the threshold",
+			deletedAgo:    2 * time.Hour,
+			expectedStuck: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			deletionTimestamp := metav1.NewTime(now.Add(-tc.deletedAgo))
+			m := &machinev1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "machine",
+					Namespace:         "default",
+					DeletionTimestamp: &deletionTimestamp,
+					Finalizers:        []string{machinev1.MachineFinalizer},
+					Labels: map[string]string{
+						machinev1.MachineClusterIDLabel: "testcluster",
+					},
+				},
+				Spec: machinev1.MachineSpec{
+					ProviderSpec: machinev1.ProviderSpec{
+						Value: &runtime.RawExtension{
+							Raw: []byte("{}"),
+						},
+					},
+					LifecycleHooks: machinev1.LifecycleHooks{
+						PreDrain: []machinev1.LifecycleHook{{Name: "backup", Owner: "backup-agent"}},
+					},
+				},
+				Status: machinev1.MachineStatus{
+					NodeRef: &corev1.ObjectReference{Name: "node"},
+				},
+			}
+
+			r := &ReconcileMachine{
+				Client:                 fake.NewFakeClientWithScheme(scheme.Scheme, m),
+				scheme:                 scheme.Scheme,
+				eventRecorder:          record.NewFakeRecorder(8),
+				actuator:               newTestActuator(),
+				nowFunc:                func() time.Time { return now },
+				stuckDeletingThreshold: time.Hour,
+			}
+			count := stuckCount(t, phaseDeleting, StuckWaitingForPreDrainHookReason)
+
+			// Reconciling a stuck machine again does not count it twice
+			for i := 0; i < 2; i++ {
+				result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(m)})
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if result.RequeueAfter != tc.expectedRequeue {
+					t.Errorf("expected to requeue after %v, got: %v", tc.expectedRequeue, result.RequeueAfter)
+				}
+			}
+
+			got := &machinev1.Machine{}
+			if err := r.Client.Get(ctx, client.ObjectKeyFromObject(m), got); err != nil {
+				t.Fatal(err)
+			}
+			degraded := conditions.Get(got, MachineDegradedCondition)
+			if !tc.expectedStuck {
+				if degraded != nil {
+					t.Errorf("expected no Degraded condition, got: %+v", degraded)
+				}
+				if stuckCount(t, phaseDeleting, StuckWaitingForPreDrainHookReason) != count {
+					t.Errorf("expected the machine not to be counted as stuck")
+				}
+				return
+			}
+			if degraded == nil || degraded.Status != corev1.ConditionTrue || degraded.Reason != StuckWaitingForPreDrainHookReason {
+				t.Errorf("expected the Degraded condition to be true with reason %s, got: %+v", StuckWaitingForPreDrainHookReason, degraded)
+			}
+			if got := stuckCount(t, phaseDeleting, StuckWaitingForPreDrainHookReason); got != count+1 {
+				t.Errorf("expected the machine to be counted as stuck once, got: %v", got-count)
+			}
+		})
+	}
+}
+
+func TestReconcileStuckProvisioning(t *testing.T) {
+	machinev1.AddToScheme(scheme.Scheme)
+
+	now := time.Now().Truncate(time.Second)
+	m := &machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "machine",
+			Namespace:         "default",
+			CreationTimestamp: metav1.NewTime(now.Add(-time.Hour)),
+			Finalizers:        []string{machinev1.MachineFinalizer},
+			Labels: map[string]string{
+				machinev1.MachineClusterIDLabel: "testcluster",
+			},
+		},
+		Spec: machinev1.MachineSpec{
+			ProviderSpec: machinev1.ProviderSpec{
+				Value: &runtime.RawExtension{
+					Raw: []byte("{}"),
+				},
+			},
+		},
+		Status: machinev1.MachineStatus{
+			Phase: pointer.StringPtr(phaseProvisioning),
+		},
+	}
+
+	act := newTestActuator()
+	r := &ReconcileMachine{
+		Client:                     fake.NewFakeClientWithScheme(scheme.Scheme, m),
+		scheme:                     scheme.Scheme,
+		eventRecorder:              record.NewFakeRecorder(8),
+		actuator:                   act,
+		nowFunc:                    func() time.Time { return now },
+		stuckProvisioningThreshold: 30 * time.Minute,
+	}
+
+	if _, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(m)}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if act.CreateCallCount != 1 {
+		t.Errorf("expected the creation to be retried, got %d creations", act.CreateCallCount)
+	}
+
+	got := &machinev1.Machine{}
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(m), got); err != nil {
+		t.Fatal(err)
+	}
+	degraded := conditions.Get(got, MachineDegradedCondition)
+	if degraded == nil || degraded.Status != corev1.ConditionTrue || degraded.Reason != StuckWaitingForInstanceCreationReason {
+		t.Errorf("expected the Degraded condition to be true with reason %s, got: %+v", StuckWaitingForInstanceCreationReason, degraded)
+	}
+}
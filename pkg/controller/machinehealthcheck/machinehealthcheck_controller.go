@@ -14,6 +14,7 @@ import (
 	"github.com/openshift/machine-api-operator/pkg/util/annotations"
 	"github.com/openshift/machine-api-operator/pkg/util/conditions"
 	"github.com/openshift/machine-api-operator/pkg/util/external"
+	"github.com/openshift/machine-api-operator/pkg/util/machines"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
@@ -45,9 +46,17 @@ const (
 	remediationStrategyAnnotation = "machine.openshift.io/remediation-strategy"
 	remediationStrategyExternal   = machinev1.RemediationStrategyType("external-baremetal")
 	defaultNodeStartupTimeout     = 10 * time.Minute
-	machineNodeNameIndex          = "machineNodeNameIndex"
+	remediatedByAnnotation        = "machine.openshift.io/remediated-by"
 	controllerName                = "machinehealthcheck-controller"
 
+	// negatedConditionStatusPrefix prefixes the status of an unhealthy condition to match the node condition
+	// when it is not in the status, eg. "!True", see unhealthyConditionSince.
+	negatedConditionStatusPrefix = "!"
+
+	// externalRemediationPollInterval is the interval to check the external remediation requests
+	// of the machines remediated by a MachineHealthCheck.
+	externalRemediationPollInterval = time.Minute
+
 	// Event types
 	// EventRemediationRestricted is emitted in case when machine remediation
 	// is restricted by remediation circuit shorting logic
@@ -64,12 +73,24 @@ const (
 	// EventMachineDeleted is emitted when machine was successfully remediated
 	// by deleting its Machine object
 	EventMachineDeleted string = "MachineDeleted"
+	// EventMachineRemediated is emitted on the MachineHealthCheck when it
+	// remediated a machine by deleting its Machine object
+	EventMachineRemediated string = "MachineRemediated"
 	// EventExternalAnnotationFailed is emitted in case adding external annotation
 	// to a Node object failed
 	EventExternalAnnotationFailed string = "ExternalAnnotationFailed"
 	// EventExternalAnnotationAdded is emitted when external annotation was
 	// successfully added to a Node object
 	EventExternalAnnotationAdded string = "ExternalAnnotationAdded"
+	// EventExternalRemediationRequested is emitted on the MachineHealthCheck when it
+	// created an external remediation request from its remediation template
+	EventExternalRemediationRequested string = "ExternalRemediationRequested"
+	// EventExternalRemediationCompleted is emitted on the MachineHealthCheck when a
+	// machine passed its health check again and its external remediation request was deleted
+	EventExternalRemediationCompleted string = "ExternalRemediationCompleted"
+	// EventRemediationDeferred is emitted on the MachineHealthCheck when the remediation
+	// of an unhealthy machine is deferred as it is outside the remediation schedule
+	EventRemediationDeferred string = "RemediationDeferred"
 	// PausedAnnotation is an annotation that can be applied to MachineHealthCheck objects to prevent the MHC controller
 	// from processing it.
 	// TODO: move this annotation to the openshift/api package
@@ -93,12 +114,8 @@ func Add(mgr manager.Manager, opts manager.Options) error {
 
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(mgr manager.Manager, opts manager.Options) (*ReconcileMachineHealthCheck, error) {
-	if err := mgr.GetCache().IndexField(context.TODO(),
-		&machinev1.Machine{},
-		machineNodeNameIndex,
-		indexMachineByNodeName,
-	); err != nil {
-		return nil, fmt.Errorf("error setting index fields: %v", err)
+	if err := machines.AddIndexes(context.TODO(), mgr.GetCache()); err != nil {
+		return nil, err
 	}
 
 	return &ReconcileMachineHealthCheck{
@@ -109,20 +126,6 @@ func newReconciler(mgr manager.Manager, opts manager.Options) (*ReconcileMachine
 	}, nil
 }
 
-func indexMachineByNodeName(object client.Object) []string {
-	machine, ok := object.(*machinev1.Machine)
-	if !ok {
-		klog.Warningf("Expected a machine for indexing field, got: %T", object)
-		return nil
-	}
-
-	if machine.Status.NodeRef != nil {
-		return []string{machine.Status.NodeRef.Name}
-	}
-
-	return nil
-}
-
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
 func add(mgr manager.Manager, r reconcile.Reconciler, mapMachineToMHC, mapNodeToMHC handler.MapFunc) error {
 	c, err := controller.New(controllerName, mgr, controller.Options{Reconciler: r})
@@ -171,6 +174,7 @@ func (r *ReconcileMachineHealthCheck) Reconcile(ctx context.Context, request rec
 			// Request object not found, could have been deleted after reconcile request.
 			// In the event that this was a deletion, we need to remove the associated metric label
 			metrics.DeleteMachineHealthCheckNodesCovered(request.NamespacedName.Name, request.NamespacedName.Namespace)
+			metrics.DeleteMachineHealthCheckUnhealthyMachines(request.NamespacedName.Name, request.NamespacedName.Namespace)
 			return reconcile.Result{}, nil
 		}
 		klog.Errorf("Reconciling %s: failed to get MHC: %v", request.String(), err)
@@ -208,6 +212,11 @@ func (r *ReconcileMachineHealthCheck) Reconcile(ctx context.Context, request rec
 	mhc.Status.ExpectedMachines = &totalTargets
 	unhealthyCount := totalTargets - healthyCount
 
+	metrics.ObserveMachineHealthCheckUnhealthyMachines(mhc.Name, mhc.Namespace, unhealthyCount)
+	if maxUnhealthy, err := getMaxUnhealthy(mhc); err == nil {
+		metrics.ObserveMachineHealthCheckMaxUnhealthyGap(mhc.Name, mhc.Namespace, maxUnhealthy-unhealthyCount)
+	}
+
 	// check MHC current health against MaxUnhealthy
 	if !isAllowedRemediation(mhc) {
 		klog.Warningf("Reconciling %s: total targets: %v,  maxUnhealthy: %v, unhealthy: %v. Short-circuiting remediation",
@@ -248,6 +257,7 @@ func (r *ReconcileMachineHealthCheck) Reconcile(ctx context.Context, request rec
 			mhc.Spec.MaxUnhealthy,
 		)
 		metrics.ObserveMachineHealthCheckShortCircuitEnabled(mhc.Name, mhc.Namespace)
+		metrics.ObserveMachineHealthCheckRemediations(mhc.Name, mhc.Namespace, metrics.MachineHealthCheckRemediationShortCircuited, len(needRemediationTargets))
 		return reconcile.Result{Requeue: true}, nil
 	}
 	klog.V(3).Infof("Remediations are allowed for %s: total targets: %v,  max unhealthy: %v, unhealthy targets: %v",
@@ -258,12 +268,26 @@ func (r *ReconcileMachineHealthCheck) Reconcile(ctx context.Context, request rec
 	)
 	metrics.ObserveMachineHealthCheckShortCircuitDisabled(mhc.Name, mhc.Namespace)
 
+	needRemediationTargets = skipPausedRemediations(needRemediationTargets)
+	needRemediationTargets = r.skipHookedRemediations(ctx, mhc, needRemediationTargets)
+	now := time.Now()
+	needRemediationTargets, deferredNextChecks := r.scheduleRemediations(ctx, mhc, needRemediationTargets, now)
+	nextCheckTimes = append(nextCheckTimes, deferredNextChecks...)
+	needRemediationTargets, rateLimitedNextChecks := r.rateLimitRemediations(ctx, mhc, needRemediationTargets, now)
+	nextCheckTimes = append(nextCheckTimes, rateLimitedNextChecks...)
+
 	conditions.MarkTrue(mhc, machinev1.RemediationAllowedCondition)
 	if err := r.reconcileStatus(mergeBase, mhc); err != nil {
 		klog.Errorf("Reconciling %s: error patching status: %v", request.String(), err)
 		return reconcile.Result{}, err
 	}
 	errList = append(errList, r.remediate(ctx, needRemediationTargets, mhc)...)
+	// The external remediation requests are not watched, as their kind is only known from the remediation
+	// template: poll them while machines are remediated, so that a request deleted before its machine is
+	// healthy again is recreated.
+	if mhc.Spec.RemediationTemplate != nil && len(needRemediationTargets) > 0 {
+		nextCheckTimes = append(nextCheckTimes, externalRemediationPollInterval)
+	}
 	// deletes External Machine Remediation for healthy machines - indicating remediation was successful
 	r.cleanEMR(ctx, currentHealthy, mhc)
 	// return values
@@ -290,11 +314,13 @@ func (r *ReconcileMachineHealthCheck) remediate(ctx context.Context, needRemedia
 		if m.Spec.RemediationTemplate != nil {
 			if err := r.externalRemediation(ctx, m, t); err != nil {
 				klog.Errorf("Reconciling %s: error external remediating: %v", t.string(), err)
+				metrics.ObserveMachineHealthCheckRemediations(m.Name, m.Namespace, metrics.MachineHealthCheckRemediationFailed, 1)
 				errList = append(errList, err)
 			}
 		} else {
 			if err := r.internalRemediation(t); err != nil {
 				klog.Errorf("Reconciling %s: error remediating: %v", t.string(), err)
+				metrics.ObserveMachineHealthCheckRemediations(m.Name, m.Namespace, metrics.MachineHealthCheckRemediationFailed, 1)
 				errList = append(errList, err)
 			}
 		}
@@ -323,7 +349,17 @@ func (r *ReconcileMachineHealthCheck) cleanEMR(ctx context.Context, currentHealt
 			// Issue a delete for remediation request.
 			if err := r.client.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
 				klog.Errorf("failed to delete %v %q for Machine %q: %v", obj.GroupVersionKind(), obj.GetName(), t.Machine.Name, err)
+				continue
 			}
+			r.recorder.Eventf(
+				m,
+				corev1.EventTypeNormal,
+				EventExternalRemediationCompleted,
+				"Machine %v has been remediated by %v %v",
+				t.string(),
+				obj.GetKind(),
+				obj.GetName(),
+			)
 		}
 	}
 }
@@ -377,6 +413,17 @@ func (r *ReconcileMachineHealthCheck) externalRemediation(ctx context.Context, m
 		conditions.MarkFalse(m, machinev1.ExternalRemediationRequestAvailable, machinev1.ExternalRemediationRequestCreationFailed, machinev1.ConditionSeverityError, err.Error())
 		return fmt.Errorf("error creating remediation request for machine %q in namespace %q: %v", t.Machine.Name, t.Machine.Namespace, err)
 	}
+	r.recorder.Eventf(
+		m,
+		corev1.EventTypeNormal,
+		EventExternalRemediationRequested,
+		"Machine %v remediation requested from %v %v",
+		t.string(),
+		to.GetKind(),
+		to.GetName(),
+	)
+	metrics.ObserveMachineHealthCheckRemediations(m.Name, m.Namespace, metrics.MachineHealthCheckRemediationSucceeded, 1)
+	r.recordRemediation(m, &t.Machine, false)
 	return nil
 }
 
@@ -549,18 +596,14 @@ func (r *ReconcileMachineHealthCheck) getMachinesFromMHC(mhc machinev1.MachineHe
 }
 
 func (r *ReconcileMachineHealthCheck) getMachineFromNode(nodeName string) (*machinev1.Machine, error) {
-	machineList := &machinev1.MachineList{}
-	if err := r.client.List(
-		context.TODO(),
-		machineList,
-		client.MatchingFields{machineNodeNameIndex: nodeName},
-	); err != nil {
-		return nil, fmt.Errorf("failed getting machine list: %v", err)
+	machine, err := machines.MachineByNodeName(context.TODO(), r.client, nodeName)
+	if err != nil {
+		return nil, err
 	}
-	if len(machineList.Items) != 1 {
-		return nil, fmt.Errorf("expecting one machine for node %v, got: %v", nodeName, machineList.Items)
+	if machine == nil {
+		return nil, fmt.Errorf("expecting one machine for node %v, got none", nodeName)
 	}
-	return &machineList.Items[0], nil
+	return machine, nil
 }
 
 func (r *ReconcileMachineHealthCheck) mhcRequestsFromNode(o client.Object) []reconcile.Request {
@@ -663,8 +706,24 @@ func (r *ReconcileMachineHealthCheck) internalRemediation(t target) error {
 		return nil
 	}
 
+	// Record which MachineHealthCheck requested the deletion so that it can be
+	// told apart from deletions requested by users or other controllers.
+	remediatedBy := fmt.Sprintf("%s/%s", t.MHC.Name, time.Now().UTC().Format(time.RFC3339))
+	baseToPatch := client.MergeFrom(machine.DeepCopy())
+	if machine.Annotations == nil {
+		machine.Annotations = map[string]string{}
+	}
+	machine.Annotations[remediatedByAnnotation] = remediatedBy
+	if err := r.client.Patch(context.TODO(), machine, baseToPatch); err != nil {
+		if apimachineryerrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("%s: failed to annotate machine: %v", t.string(), err)
+	}
+
+	reasons := strings.Join(t.unhealthyReasons(), ", ")
 	klog.Infof("%s: deleting", t.string())
-	if err := r.client.Delete(context.TODO(), &t.Machine); err != nil {
+	if err := r.client.Delete(context.TODO(), machine); err != nil {
 		r.recorder.Eventf(
 			&t.Machine,
 			corev1.EventTypeWarning,
@@ -679,10 +738,21 @@ func (r *ReconcileMachineHealthCheck) internalRemediation(t target) error {
 		&t.Machine,
 		corev1.EventTypeNormal,
 		EventMachineDeleted,
-		"Machine %v has been remediated by requesting to delete Machine object",
+		"Machine %v has been remediated by requesting to delete Machine object: %s",
 		t.string(),
+		reasons,
+	)
+	r.recorder.Eventf(
+		&t.MHC,
+		corev1.EventTypeNormal,
+		EventMachineRemediated,
+		"Remediated Machine %v by requesting to delete Machine object: %s",
+		t.Machine.GetName(),
+		reasons,
 	)
 	metrics.ObserveMachineHealthCheckRemediationSuccess(t.MHC.Name, t.MHC.Namespace)
+	metrics.ObserveMachineHealthCheckRemediations(t.MHC.Name, t.MHC.Namespace, metrics.MachineHealthCheckRemediationSucceeded, 1)
+	r.recordRemediation(&t.MHC, &t.Machine, true)
 
 	return nil
 }
@@ -717,6 +787,8 @@ func (t *target) remediationStrategyExternal(r *ReconcileMachineHealthCheck) err
 		"Requesting external remediation of node associated with machine %v",
 		t.string(),
 	)
+	metrics.ObserveMachineHealthCheckRemediations(t.MHC.Name, t.MHC.Namespace, metrics.MachineHealthCheckRemediationSucceeded, 1)
+	r.recordRemediation(&t.MHC, &t.Machine, false)
 	return nil
 }
 
@@ -797,22 +869,22 @@ func (t *target) needsRemediation(timeoutForMachineToHaveNode time.Duration) (bo
 	// check conditions
 	for _, c := range t.MHC.Spec.UnhealthyConditions {
 		now := time.Now()
-		nodeCondition := conditions.GetNodeCondition(t.Node, c.Type)
 
 		// Skip when current node condition is different from the one reported
 		// in the MachineHealthCheck.
-		if nodeCondition == nil || nodeCondition.Status != c.Status {
+		unhealthySince, ok := unhealthyConditionSince(t.Node, c)
+		if !ok {
 			continue
 		}
 
 		// If the condition has been in the unhealthy state for longer than the
 		// timeout, return true with no requeue time.
-		if nodeCondition.LastTransitionTime.Add(c.Timeout.Duration).Before(now) {
+		if unhealthySince.Add(c.Timeout.Duration).Before(now) {
 			klog.V(3).Infof("%s: unhealthy: condition %v in state %v longer than %v", t.string(), c.Type, c.Status, c.Timeout)
 			return true, time.Duration(0), nil
 		}
 
-		durationUnhealthy := now.Sub(nodeCondition.LastTransitionTime.Time)
+		durationUnhealthy := now.Sub(unhealthySince)
 		nextCheck := c.Timeout.Duration - durationUnhealthy + time.Second
 		if nextCheck > 0 {
 			nextCheckTimes = append(nextCheckTimes, nextCheck)
@@ -821,6 +893,58 @@ func (t *target) needsRemediation(timeoutForMachineToHaveNode time.Duration) (bo
 	return false, minDuration(nextCheckTimes), nil
 }
 
+// unhealthyReasons describes the failed health checks of the target.
+func (t *target) unhealthyReasons() []string {
+	if derefStringPointer(t.Machine.Status.Phase) == machinePhaseFailed {
+		return []string{fmt.Sprintf("machine phase is %q", machinePhaseFailed)}
+	}
+	if t.Node == nil {
+		return []string{"machine has no node"}
+	}
+	if t.Node.UID == "" {
+		return []string{"node does not exist"}
+	}
+
+	var reasons []string
+	now := time.Now()
+	for _, c := range t.MHC.Spec.UnhealthyConditions {
+		unhealthySince, ok := unhealthyConditionSince(t.Node, c)
+		if !ok {
+			continue
+		}
+		if unhealthySince.Add(c.Timeout.Duration).Before(now) {
+			reasons = append(reasons, fmt.Sprintf("condition %v in state %v longer than %v", c.Type, c.Status, c.Timeout.Duration))
+		}
+	}
+	if len(reasons) == 0 {
+		return []string{"unknown"}
+	}
+	return reasons
+}
+
+// unhealthyConditionSince returns since when the node matches the unhealthy condition, and whether it matches.
+// A status with the negatedConditionStatusPrefix, eg. "!True", matches conditions in any other status,
+// including Unknown, as well as absent conditions, which are taken to be unhealthy since the node was created.
+func unhealthyConditionSince(node *corev1.Node, c machinev1.UnhealthyCondition) (time.Time, bool) {
+	nodeCondition := conditions.GetNodeCondition(node, c.Type)
+
+	status := string(c.Status)
+	if !strings.HasPrefix(status, negatedConditionStatusPrefix) {
+		if nodeCondition == nil || nodeCondition.Status != c.Status {
+			return time.Time{}, false
+		}
+		return nodeCondition.LastTransitionTime.Time, true
+	}
+
+	if nodeCondition == nil {
+		return node.CreationTimestamp.Time, true
+	}
+	if nodeCondition.Status == corev1.ConditionStatus(strings.TrimPrefix(status, negatedConditionStatusPrefix)) {
+		return time.Time{}, false
+	}
+	return nodeCondition.LastTransitionTime.Time, true
+}
+
 func (t *target) hasControllerOwner() bool {
 	return metav1.GetControllerOf(&t.Machine) != nil
 }
@@ -259,7 +259,7 @@ func TestReconcile(t *testing.T) {
 				result: reconcile.Result{},
 				error:  false,
 			},
-			expectedEvents: []string{EventMachineDeleted},
+			expectedEvents: []string{EventMachineDeleted, EventMachineRemediated},
 			expectedStatus: &machinev1.MachineHealthCheckStatus{
 				ExpectedMachines:    IntPtr(1),
 				CurrentHealthy:      IntPtr(0),
@@ -522,7 +522,7 @@ func TestReconcileExternalRemediationTemplate(t *testing.T) {
 				result: reconcile.Result{},
 				error:  false,
 			},
-			expectedEvents: []string{},
+			expectedEvents: []string{EventExternalRemediationCompleted},
 			expectedStatus: &machinev1.MachineHealthCheckStatus{
 				ExpectedMachines:    IntPtr(1),
 				CurrentHealthy:      IntPtr(1),
@@ -541,10 +541,12 @@ func TestReconcileExternalRemediationTemplate(t *testing.T) {
 			externalRemediationMachine:  nil,
 			externalRemediationTemplate: ermTemplate,
 			expected: expectedReconcile{
-				result: reconcile.Result{},
-				error:  false,
+				result: reconcile.Result{
+					RequeueAfter: externalRemediationPollInterval,
+				},
+				error: false,
 			},
-			expectedEvents: []string{},
+			expectedEvents: []string{EventExternalRemediationRequested},
 			expectedStatus: &machinev1.MachineHealthCheckStatus{
 				ExpectedMachines:    IntPtr(1),
 				CurrentHealthy:      IntPtr(0),
@@ -563,8 +565,10 @@ func TestReconcileExternalRemediationTemplate(t *testing.T) {
 			externalRemediationMachine:  erm,
 			externalRemediationTemplate: ermTemplate,
 			expected: expectedReconcile{
-				result: reconcile.Result{},
-				error:  false,
+				result: reconcile.Result{
+					RequeueAfter: externalRemediationPollInterval,
+				},
+				error: false,
 			},
 			expectedEvents: []string{},
 			expectedStatus: &machinev1.MachineHealthCheckStatus{
@@ -2085,7 +2089,7 @@ func TestRemediate(t *testing.T) {
 			},
 			deletion:       true,
 			expectedError:  false,
-			expectedEvents: []string{EventMachineDeleted},
+			expectedEvents: []string{EventMachineDeleted, EventMachineRemediated},
 		},
 		{
 			testCase: "node master",
@@ -2131,7 +2135,7 @@ func TestRemediate(t *testing.T) {
 			},
 			deletion:       true,
 			expectedError:  false,
-			expectedEvents: []string{EventMachineDeleted},
+			expectedEvents: []string{EventMachineDeleted, EventMachineRemediated},
 		},
 		{
 			testCase: "machine master",
@@ -2163,7 +2167,7 @@ func TestRemediate(t *testing.T) {
 			},
 			deletion:       true,
 			expectedError:  false,
-			expectedEvents: []string{EventMachineDeleted},
+			expectedEvents: []string{EventMachineDeleted, EventMachineRemediated},
 		},
 	}
 
@@ -2197,6 +2201,98 @@ func TestRemediate(t *testing.T) {
 	}
 }
 
+func TestRemediateRecordsRemediationHistory(t *testing.T) {
+	mhc := maotesting.NewMachineHealthCheck("mhc")
+	node := maotesting.NewNode("node", false)
+
+	testCases := []struct {
+		testCase             string
+		machine              *machinev1.Machine
+		expectedRemediatedBy bool
+		expectedEvents       []string
+		expectedReason       string
+	}{
+		{
+			testCase: "remediated machine is annotated",
+			machine: func() *machinev1.Machine {
+				m := maotesting.NewMachine("machine", node.Name)
+				// Keep the machine around after deletion so that it can be inspected
+				m.Finalizers = []string{"machine.machine.openshift.io"}
+				return m
+			}(),
+			expectedRemediatedBy: true,
+			expectedEvents:       []string{EventMachineDeleted, EventMachineRemediated},
+			expectedReason:       "condition Ready in state Unknown longer than 5m0s",
+		},
+		{
+			testCase: "machine without controller owner is not annotated",
+			machine: func() *machinev1.Machine {
+				m := maotesting.NewMachine("machine", node.Name)
+				m.OwnerReferences = nil
+				return m
+			}(),
+			expectedRemediatedBy: false,
+			expectedEvents:       []string{EventSkippedNoController},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(2)
+			r := newFakeReconcilerWithCustomRecorder(recorder, tc.machine, node, mhc)
+			tgt := target{
+				Machine: *tc.machine,
+				Node:    node,
+				MHC:     *mhc,
+			}
+			if err := r.internalRemediation(tgt); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var events []string
+			for len(recorder.Events) > 0 {
+				events = append(events, <-recorder.Events)
+			}
+			if len(events) != len(tc.expectedEvents) {
+				t.Fatalf("Expected events %v, got: %v", tc.expectedEvents, events)
+			}
+			for i, event := range events {
+				if !strings.Contains(event, fmt.Sprintf(" %s ", tc.expectedEvents[i])) {
+					t.Errorf("Expected %v event, got: %v", tc.expectedEvents[i], event)
+				}
+				if tc.expectedReason != "" && !strings.Contains(event, tc.expectedReason) {
+					t.Errorf("Expected event to contain %q, got: %v", tc.expectedReason, event)
+				}
+			}
+
+			machine := &machinev1.Machine{}
+			if err := r.client.Get(context.TODO(), namespacedName(tc.machine), machine); err != nil {
+				t.Fatalf("Unexpected error getting machine: %v", err)
+			}
+			remediatedBy, ok := machine.Annotations[remediatedByAnnotation]
+			if ok != tc.expectedRemediatedBy {
+				t.Fatalf("Expected %s annotation to be present: %v, got: %v", remediatedByAnnotation, tc.expectedRemediatedBy, machine.Annotations)
+			}
+			if !tc.expectedRemediatedBy {
+				if !machine.GetDeletionTimestamp().IsZero() {
+					t.Errorf("Expected machine not to be deleted")
+				}
+				return
+			}
+			if machine.GetDeletionTimestamp().IsZero() {
+				t.Errorf("Expected machine to be deleted")
+			}
+			parts := strings.SplitN(remediatedBy, "/", 2)
+			if len(parts) != 2 || parts[0] != mhc.Name {
+				t.Fatalf("Expected %s annotation to start with %q, got: %q", remediatedByAnnotation, mhc.Name+"/", remediatedBy)
+			}
+			if _, err := time.Parse(time.RFC3339, parts[1]); err != nil {
+				t.Errorf("Expected %s annotation to end with a timestamp: %v", remediatedByAnnotation, err)
+			}
+		})
+	}
+}
+
 func TestReconcileStatus(t *testing.T) {
 	testCases := []struct {
 		testCase            string
@@ -3161,3 +3257,86 @@ func verifyErm(t *testing.T, tc testCase, ctx context.Context, client client.Cli
 		g.Expect(client.Get(ctx, nameSpace, erm)).NotTo(Succeed())
 	}
 }
+
+func TestUnhealthyConditionSince(t *testing.T) {
+	created := metav1.Time{Time: time.Date(1985, 06, 03, 0, 0, 0, 0, time.UTC)}
+	transitioned := metav1.Time{Time: created.Add(time.Hour)}
+	nodeWithReady := func(status corev1.ConditionStatus) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{CreationTimestamp: created},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{
+					{
+						Type:               corev1.NodeReady,
+						Status:             status,
+						LastTransitionTime: transitioned,
+					},
+				},
+			},
+		}
+	}
+
+	testCases := []struct {
+		testCase      string
+		node          *corev1.Node
+		status        corev1.ConditionStatus
+		expectedMatch bool
+		expectedSince time.Time
+	}{
+		{
+			testCase:      "condition in the status",
+			node:          nodeWithReady(corev1.ConditionFalse),
+			status:        corev1.ConditionFalse,
+			expectedMatch: true,
+			expectedSince: transitioned.Time,
+		},
+		{
+			testCase: "condition in another status",
+			node:     nodeWithReady(corev1.ConditionTrue),
+			status:   corev1.ConditionFalse,
+		},
+		{
+			testCase: "absent condition",
+			node:     &corev1.Node{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: created}},
+			status:   corev1.ConditionFalse,
+		},
+		{
+			testCase: "negated status with the condition in the status",
+			node:     nodeWithReady(corev1.ConditionTrue),
+			status:   "!True",
+		},
+		{
+			testCase:      "negated status with the condition False",
+			node:          nodeWithReady(corev1.ConditionFalse),
+			status:        "!True",
+			expectedMatch: true,
+			expectedSince: transitioned.Time,
+		},
+		{
+			testCase:      "negated status with the condition Unknown",
+			node:          nodeWithReady(corev1.ConditionUnknown),
+			status:        "!True",
+			expectedMatch: true,
+			expectedSince: transitioned.Time,
+		},
+		{
+			testCase:      "negated status with an absent condition",
+			node:          &corev1.Node{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: created}},
+			status:        "!True",
+			expectedMatch: true,
+			expectedSince: created.Time,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			since, match := unhealthyConditionSince(tc.node, machinev1.UnhealthyCondition{Type: corev1.NodeReady, Status: tc.status})
+			if match != tc.expectedMatch {
+				t.Errorf("expected match: %v, got: %v", tc.expectedMatch, match)
+			}
+			if !since.Equal(tc.expectedSince) {
+				t.Errorf("expected since: %v, got: %v", tc.expectedSince, since)
+			}
+		})
+	}
+}
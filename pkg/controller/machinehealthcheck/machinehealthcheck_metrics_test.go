@@ -0,0 +1,78 @@
+package machinehealthcheck
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/openshift/machine-api-operator/pkg/metrics"
+	maotesting "github.com/openshift/machine-api-operator/pkg/util/testing"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func gaugeValue(t *testing.T, gauge *prometheus.GaugeVec, labels prometheus.Labels) float64 {
+	metric := &dto.Metric{}
+	if err := gauge.With(labels).Write(metric); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return metric.GetGauge().GetValue()
+}
+
+func remediationsCount(t *testing.T, name, result string) float64 {
+	metric := &dto.Metric{}
+	counter := metrics.MachineHealthCheckRemediationsTotal.With(prometheus.Labels{"name": name, "namespace": namespace, "result": result})
+	if err := counter.Write(metric); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return metric.GetCounter().GetValue()
+}
+
+func TestReconcileObservesUnhealthyMachines(t *testing.T) {
+	g := NewWithT(t)
+
+	node := maotesting.NewNode("unhealthy", false)
+	node.Annotations = map[string]string{
+		machineAnnotationKey: namespace + "/machineUnhealthy",
+	}
+	machine := maotesting.NewMachine("machineUnhealthy", node.Name)
+
+	mhc := maotesting.NewMachineHealthCheck("metricsShortCircuited")
+	zero := intstr.FromInt(0)
+	mhc.Spec.MaxUnhealthy = &zero
+	mhc.Spec.NodeStartupTimeout = &metav1.Duration{Duration: 15 * time.Minute}
+
+	shortCircuited := remediationsCount(t, mhc.Name, metrics.MachineHealthCheckRemediationShortCircuited)
+
+	r := newFakeReconcilerWithCustomRecorder(record.NewFakeRecorder(2), mhc, machine, node)
+	_, err := r.Reconcile(context.Background(), reconcile.Request{
+		NamespacedName: types.NamespacedName{Namespace: mhc.Namespace, Name: mhc.Name},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	labels := prometheus.Labels{"name": mhc.Name, "namespace": namespace}
+	g.Expect(gaugeValue(t, metrics.MachineHealthCheckUnhealthyMachines, labels)).To(Equal(float64(1)))
+	g.Expect(gaugeValue(t, metrics.MachineHealthCheckMaxUnhealthyGap, labels)).To(Equal(float64(-1)))
+	g.Expect(remediationsCount(t, mhc.Name, metrics.MachineHealthCheckRemediationShortCircuited)).To(Equal(shortCircuited + 1))
+}
+
+func TestInternalRemediationObservesRemediations(t *testing.T) {
+	g := NewWithT(t)
+
+	node := maotesting.NewNode("unhealthy", false)
+	machine := maotesting.NewMachine("machineUnhealthy", node.Name)
+	mhc := maotesting.NewMachineHealthCheck("metricsRemediated")
+
+	succeeded := remediationsCount(t, mhc.Name, metrics.MachineHealthCheckRemediationSucceeded)
+
+	r := newFakeReconcilerWithCustomRecorder(record.NewFakeRecorder(2), machine)
+	g.Expect(r.internalRemediation(target{Machine: *machine, Node: node, MHC: *mhc})).To(Succeed())
+
+	g.Expect(remediationsCount(t, mhc.Name, metrics.MachineHealthCheckRemediationSucceeded)).To(Equal(succeeded + 1))
+}
@@ -0,0 +1,94 @@
+package machinehealthcheck
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/conditions"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// remediationBlockingHookOwnersAnnotation is set on a MachineHealthCheck to a comma separated list
+	// of lifecycle hook owners, or "*" for any owner, whose hooks on a machine block its remediation,
+	// eg. backup agents holding a pre-drain hook
+	remediationBlockingHookOwnersAnnotation = "machine.openshift.io/remediation-blocking-hook-owners"
+
+	// anyHookOwner matches the lifecycle hooks of any owner
+	anyHookOwner = "*"
+
+	// RemediationBlockedByHookCondition is set on MachineHealthChecks with blocking hook owners,
+	// true when lifecycle hooks block the remediation of unhealthy machines
+	RemediationBlockedByHookCondition machinev1.ConditionType = "RemediationBlockedByHook"
+
+	// LifecycleHookPresentReason is the reason of the RemediationBlockedByHook condition
+	// when lifecycle hooks block the remediation of unhealthy machines
+	LifecycleHookPresentReason = "LifecycleHookPresent"
+)
+
+// remediationBlockingHookOwnersOf returns the owners of the lifecycle hooks which block remediations
+// of the MachineHealthCheck.
+func remediationBlockingHookOwnersOf(mhc *machinev1.MachineHealthCheck) sets.String {
+	owners := sets.NewString()
+	for _, owner := range strings.Split(mhc.Annotations[remediationBlockingHookOwnersAnnotation], ",") {
+		if owner = strings.TrimSpace(owner); owner != "" {
+			owners.Insert(owner)
+		}
+	}
+	return owners
+}
+
+// blockingLifecycleHooks returns the lifecycle hooks of the machine whose owners are in owners.
+func blockingLifecycleHooks(m *machinev1.Machine, owners sets.String) []machinev1.LifecycleHook {
+	var hooks []machinev1.LifecycleHook
+	for _, machineHooks := range [][]machinev1.LifecycleHook{m.Spec.LifecycleHooks.PreDrain, m.Spec.LifecycleHooks.PreTerminate} {
+		for _, hook := range machineHooks {
+			if owners.Has(anyHookOwner) || owners.Has(hook.Owner) {
+				hooks = append(hooks, hook)
+			}
+		}
+	}
+	return hooks
+}
+
+// skipHookedRemediations returns the targets which may be remediated as they carry no lifecycle hooks
+// of the blocking owners of the MachineHealthCheck.
+// It sets the RemediationBlockedByHook condition of MachineHealthChecks with blocking hook owners.
+func (r *ReconcileMachineHealthCheck) skipHookedRemediations(ctx context.Context, mhc *machinev1.MachineHealthCheck, targets []target) []target {
+	owners := remediationBlockingHookOwnersOf(mhc)
+	if owners.Len() == 0 {
+		return targets
+	}
+
+	var allowed []target
+	var blocked []string
+	for _, t := range targets {
+		hooks := blockingLifecycleHooks(&t.Machine, owners)
+		if len(hooks) == 0 || r.remediationInProgress(ctx, mhc, t) {
+			allowed = append(allowed, t)
+			continue
+		}
+		klog.Infof("%s: skipping remediation, blocked by lifecycle hooks %+v", t.string(), hooks)
+		blocked = append(blocked, fmt.Sprintf("%s %+v", t.Machine.GetName(), hooks))
+	}
+
+	if len(blocked) > 0 {
+		conditions.Set(mhc, &machinev1.Condition{
+			Type:     RemediationBlockedByHookCondition,
+			Status:   corev1.ConditionTrue,
+			Severity: machinev1.ConditionSeverityWarning,
+			Reason:   LifecycleHookPresentReason,
+			Message:  fmt.Sprintf("Remediation of machines blocked by lifecycle hooks: %s", strings.Join(blocked, ", ")),
+		})
+	} else {
+		conditions.Set(mhc, &machinev1.Condition{
+			Type:   RemediationBlockedByHookCondition,
+			Status: corev1.ConditionFalse,
+		})
+	}
+	return allowed
+}
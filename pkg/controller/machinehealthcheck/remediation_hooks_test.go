@@ -0,0 +1,98 @@
+package machinehealthcheck
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/conditions"
+	maotesting "github.com/openshift/machine-api-operator/pkg/util/testing"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestSkipHookedRemediations(t *testing.T) {
+	backup := machinev1.LifecycleHook{Name: "backup", Owner: "backup-agent"}
+	other := machinev1.LifecycleHook{Name: "other", Owner: "other-controller"}
+
+	hooked := maotesting.NewMachine("hooked", "node")
+	hooked.Spec.LifecycleHooks.PreDrain = []machinev1.LifecycleHook{backup}
+	otherHooked := maotesting.NewMachine("other-hooked", "node")
+	otherHooked.Spec.LifecycleHooks.PreTerminate = []machinev1.LifecycleHook{other}
+	targets := []target{
+		{Machine: *hooked},
+		{Machine: *otherHooked},
+		{Machine: *maotesting.NewMachine("unhooked", "node")},
+	}
+
+	testCases := []struct {
+		name              string
+		owners            string
+		expectedMachines  []string
+		expectedCondition *machinev1.Condition
+	}{
+		{
+			name:             "without blocking owners",
+			expectedMachines: []string{"hooked", "other-hooked", "unhooked"},
+		},
+		{
+			name:             "with blocking owners",
+			owners:           "backup-agent, etcd-quorum-guard",
+			expectedMachines: []string{"other-hooked", "unhooked"},
+			expectedCondition: &machinev1.Condition{
+				Type:     RemediationBlockedByHookCondition,
+				Status:   corev1.ConditionTrue,
+				Severity: machinev1.ConditionSeverityWarning,
+				Reason:   LifecycleHookPresentReason,
+				Message:  "Remediation of machines blocked by lifecycle hooks: hooked [{Name:backup Owner:backup-agent}]",
+			},
+		},
+		{
+			name:             "with any owner blocking",
+			owners:           anyHookOwner,
+			expectedMachines: []string{"unhooked"},
+			expectedCondition: &machinev1.Condition{
+				Type:     RemediationBlockedByHookCondition,
+				Status:   corev1.ConditionTrue,
+				Severity: machinev1.ConditionSeverityWarning,
+				Reason:   LifecycleHookPresentReason,
+				Message:  "Remediation of machines blocked by lifecycle hooks: hooked [{Name:backup Owner:backup-agent}], other-hooked [{Name:other Owner:other-controller}]",
+			},
+		},
+		{
+			name:             "with blocking owners without hooks",
+			owners:           "etcd-quorum-guard",
+			expectedMachines: []string{"hooked", "other-hooked", "unhooked"},
+			expectedCondition: &machinev1.Condition{
+				Type:   RemediationBlockedByHookCondition,
+				Status: corev1.ConditionFalse,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mhc := maotesting.NewMachineHealthCheck("mhc")
+			if tc.owners != "" {
+				mhc.Annotations = map[string]string{remediationBlockingHookOwnersAnnotation: tc.owners}
+			}
+			r := newFakeReconciler(mhc)
+
+			var machines []string
+			for _, t := range r.skipHookedRemediations(context.TODO(), mhc, targets) {
+				machines = append(machines, t.Machine.Name)
+			}
+			g.Expect(machines).To(Equal(tc.expectedMachines))
+
+			condition := conditions.Get(mhc, RemediationBlockedByHookCondition)
+			if tc.expectedCondition == nil {
+				g.Expect(condition).To(BeNil())
+				return
+			}
+			g.Expect(condition).ToNot(BeNil())
+			g.Expect(*condition).To(conditions.MatchCondition(*tc.expectedCondition))
+		})
+	}
+}
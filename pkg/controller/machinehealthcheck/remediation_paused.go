@@ -0,0 +1,20 @@
+package machinehealthcheck
+
+import (
+	"github.com/openshift/machine-api-operator/pkg/util/annotations"
+	"k8s.io/klog/v2"
+)
+
+// skipPausedRemediations returns the targets which may be remediated as their machine is not paused
+// by the machine.openshift.io/paused annotation.
+func skipPausedRemediations(targets []target) []target {
+	var allowed []target
+	for _, t := range targets {
+		if annotations.IsMachinePaused(&t.Machine) {
+			klog.Infof("%s: skipping remediation, the machine is paused", t.string())
+			continue
+		}
+		allowed = append(allowed, t)
+	}
+	return allowed
+}
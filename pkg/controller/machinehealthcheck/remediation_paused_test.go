@@ -0,0 +1,26 @@
+package machinehealthcheck
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/openshift/machine-api-operator/pkg/util/annotations"
+	maotesting "github.com/openshift/machine-api-operator/pkg/util/testing"
+)
+
+func TestSkipPausedRemediations(t *testing.T) {
+	g := NewWithT(t)
+
+	paused := maotesting.NewMachine("paused", "node")
+	paused.Annotations = map[string]string{annotations.MachinePausedAnnotation: ""}
+	targets := []target{
+		{Machine: *paused},
+		{Machine: *maotesting.NewMachine("unpaused", "node")},
+	}
+
+	var machines []string
+	for _, t := range skipPausedRemediations(targets) {
+		machines = append(machines, t.Machine.GetName())
+	}
+	g.Expect(machines).To(Equal([]string{"unpaused"}))
+}
@@ -0,0 +1,292 @@
+package machinehealthcheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/conditions"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// maxRemediationsPerHourAnnotation is set on a MachineHealthCheck to bound how many machines it may
+	// remediate within an hour
+	maxRemediationsPerHourAnnotation = "machine.openshift.io/max-remediations-per-hour"
+
+	// remediationBackoffAnnotation is set on a MachineHealthCheck to postpone, in seconds, the next remediation
+	// of a machine it remediated already, doubling the delay with every remediation of the machine.
+	// Machines deleted by the remediation are replaced under a new name by their controller, so their
+	// replacements are backed off as the remediations of the machines of the controller
+	remediationBackoffAnnotation = "machine.openshift.io/remediation-backoff-seconds"
+
+	// remediationHistoryAnnotation records the remediations of a rate limited MachineHealthCheck
+	remediationHistoryAnnotation = "machine.openshift.io/remediation-history"
+
+	// remediationHistoryRetention is how long remediations are kept in the history,
+	// which also bounds the backoff of a machine
+	remediationHistoryRetention = 24 * time.Hour
+
+	// RemediationRateLimitedCondition is set on rate limited MachineHealthChecks,
+	// true when the remediation of unhealthy machines is postponed
+	RemediationRateLimitedCondition machinev1.ConditionType = "RemediationRateLimited"
+
+	// RemediationPostponedReason is the reason of the RemediationRateLimited condition
+	// when the remediation of unhealthy machines is postponed
+	RemediationPostponedReason = "RemediationPostponed"
+)
+
+// remediationRecord is a remediation in the history of a MachineHealthCheck.
+type remediationRecord struct {
+	Machine string `json:"machine"`
+	// Owner is the controller of the machine, set when the remediation deleted the machine for its
+	// controller to replace it.
+	Owner string      `json:"owner,omitempty"`
+	Time  metav1.Time `json:"time"`
+}
+
+// remediationRecordOf returns the record of the remediation of the machine, deleting it when replaced is set.
+func remediationRecordOf(m *machinev1.Machine, replaced bool, now time.Time) remediationRecord {
+	record := remediationRecord{Machine: m.Name, Time: metav1.NewTime(now)}
+	if replaced {
+		record.Owner = controllerOwnerOf(m)
+	}
+	return record
+}
+
+// remediationDeletes returns whether the MachineHealthCheck remediates the machine by deleting it,
+// rather than by an external remediation, see internalRemediation.
+func remediationDeletes(mhc *machinev1.MachineHealthCheck, m *machinev1.Machine) bool {
+	if mhc.Spec.RemediationTemplate != nil {
+		return false
+	}
+	if derefStringPointer(m.Status.Phase) == machinePhaseFailed {
+		return true
+	}
+	return machinev1.RemediationStrategyType(mhc.Annotations[remediationStrategyAnnotation]) != remediationStrategyExternal
+}
+
+// controllerOwnerOf returns the kind and name of the controller of the machine, empty when it has none.
+func controllerOwnerOf(m *machinev1.Machine) string {
+	owner := metav1.GetControllerOf(m)
+	if owner == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s", owner.Kind, owner.Name)
+}
+
+// backsOff returns whether the remediation recorded backs off the remediation of the machine: whether it
+// remediated the machine, or deleted a machine of the same controller which the machine may replace.
+func (record remediationRecord) backsOff(m *machinev1.Machine) bool {
+	if record.Machine == m.Name {
+		return true
+	}
+	return record.Owner != "" && record.Owner == controllerOwnerOf(m)
+}
+
+// remediationRateLimit is the rate limit of the remediations of a MachineHealthCheck.
+type remediationRateLimit struct {
+	maxPerHour int
+	backoff    time.Duration
+}
+
+// remediationRateLimitOf returns the rate limit set on the MachineHealthCheck.
+// Invalid annotations are ignored.
+func remediationRateLimitOf(mhc *machinev1.MachineHealthCheck) remediationRateLimit {
+	var limit remediationRateLimit
+	if value, ok := mhc.Annotations[maxRemediationsPerHourAnnotation]; ok {
+		if maxPerHour, err := strconv.Atoi(value); err != nil || maxPerHour < 1 {
+			klog.Warningf("%s: ignoring invalid %s annotation %q: must be a positive integer", namespacedName(mhc), maxRemediationsPerHourAnnotation, value)
+		} else {
+			limit.maxPerHour = maxPerHour
+		}
+	}
+	if value, ok := mhc.Annotations[remediationBackoffAnnotation]; ok {
+		if seconds, err := strconv.Atoi(value); err != nil || seconds < 1 {
+			klog.Warningf("%s: ignoring invalid %s annotation %q: must be a positive integer", namespacedName(mhc), remediationBackoffAnnotation, value)
+		} else {
+			limit.backoff = time.Duration(seconds) * time.Second
+		}
+	}
+	return limit
+}
+
+func (l remediationRateLimit) enabled() bool {
+	return l.maxPerHour > 0 || l.backoff > 0
+}
+
+// remediationHistoryOf returns the remediations recorded on the MachineHealthCheck within the retention,
+// oldest first. An invalid history is reset.
+func remediationHistoryOf(mhc *machinev1.MachineHealthCheck, now time.Time) []remediationRecord {
+	value, ok := mhc.Annotations[remediationHistoryAnnotation]
+	if !ok {
+		return nil
+	}
+	var records []remediationRecord
+	if err := json.Unmarshal([]byte(value), &records); err != nil {
+		klog.Warningf("%s: resetting invalid %s annotation: %v", namespacedName(mhc), remediationHistoryAnnotation, err)
+		return nil
+	}
+
+	var history []remediationRecord
+	for _, record := range records {
+		if now.Sub(record.Time.Time) < remediationHistoryRetention {
+			history = append(history, record)
+		}
+	}
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].Time.Before(&history[j].Time)
+	})
+	return history
+}
+
+// remediationsWithin returns the number of remediations in the history within the period before now.
+func remediationsWithin(history []remediationRecord, period time.Duration, now time.Time) int {
+	count := 0
+	for _, record := range history {
+		if now.Sub(record.Time.Time) < period {
+			count++
+		}
+	}
+	return count
+}
+
+// postponement returns how long the remediation of the machine must be postponed given the history,
+// zero or less when it may be remediated now.
+func (l remediationRateLimit) postponement(history []remediationRecord, m *machinev1.Machine, now time.Time) time.Duration {
+	var wait time.Duration
+	if l.maxPerHour > 0 {
+		var recent []remediationRecord
+		for _, record := range history {
+			if now.Sub(record.Time.Time) < time.Hour {
+				recent = append(recent, record)
+			}
+		}
+		// The machine may be remediated once enough of the recent remediations are older than an hour
+		if len(recent) >= l.maxPerHour {
+			wait = recent[len(recent)-l.maxPerHour].Time.Add(time.Hour).Sub(now)
+		}
+	}
+
+	if l.backoff > 0 {
+		var remediations int
+		var last time.Time
+		for _, record := range history {
+			if record.backsOff(m) {
+				remediations++
+				last = record.Time.Time
+			}
+		}
+		if remediations > 0 {
+			backoff := remediationHistoryRetention
+			if remediations < 32 && l.backoff<<(remediations-1) < remediationHistoryRetention {
+				backoff = l.backoff << (remediations - 1)
+			}
+			if machineWait := last.Add(backoff).Sub(now); machineWait > wait {
+				wait = machineWait
+			}
+		}
+	}
+	return wait
+}
+
+// remediationInProgress returns whether the remediation of the target started already,
+// in which case remediating it again does not count towards the rate limit.
+func (r *ReconcileMachineHealthCheck) remediationInProgress(ctx context.Context, mhc *machinev1.MachineHealthCheck, t target) bool {
+	if !t.Machine.GetDeletionTimestamp().IsZero() || externalRemediationAnnotationExists(&t.Machine) {
+		return true
+	}
+	if mhc.Spec.RemediationTemplate != nil {
+		exists, err := r.externalRemediationRequestExists(ctx, mhc, t.Machine.Name)
+		return err == nil && exists
+	}
+	return false
+}
+
+// rateLimitRemediations returns the targets which may be remediated now under the rate limit
+// of the MachineHealthCheck, and when to check the postponed targets again.
+// It sets the RemediationRateLimited condition of rate limited MachineHealthChecks.
+func (r *ReconcileMachineHealthCheck) rateLimitRemediations(ctx context.Context, mhc *machinev1.MachineHealthCheck, targets []target, now time.Time) ([]target, []time.Duration) {
+	limit := remediationRateLimitOf(mhc)
+	if !limit.enabled() {
+		return targets, nil
+	}
+
+	history := remediationHistoryOf(mhc, now)
+	lastHour := remediationsWithin(history, time.Hour, now)
+
+	var allowed []target
+	var postponed []string
+	var nextChecks []time.Duration
+	for _, t := range targets {
+		if r.remediationInProgress(ctx, mhc, t) {
+			allowed = append(allowed, t)
+			continue
+		}
+		if wait := limit.postponement(history, &t.Machine, now); wait > 0 {
+			klog.Infof("%s: remediation rate limited, postponing remediation by %v", t.string(), wait)
+			postponed = append(postponed, t.Machine.Name)
+			nextChecks = append(nextChecks, wait)
+			continue
+		}
+		// Count the remediation towards the rate limit of the next targets
+		history = append(history, remediationRecordOf(&t.Machine, remediationDeletes(mhc, &t.Machine), now))
+		allowed = append(allowed, t)
+	}
+
+	if len(postponed) > 0 {
+		conditions.Set(mhc, &machinev1.Condition{
+			Type:     RemediationRateLimitedCondition,
+			Status:   corev1.ConditionTrue,
+			Severity: machinev1.ConditionSeverityWarning,
+			Reason:   RemediationPostponedReason,
+			Message:  fmt.Sprintf("Remediation of machines %v postponed by the rate limit (remediations in the last hour: %v)", postponed, lastHour),
+		})
+	} else {
+		conditions.Set(mhc, &machinev1.Condition{
+			Type:    RemediationRateLimitedCondition,
+			Status:  corev1.ConditionFalse,
+			Message: fmt.Sprintf("Remediations in the last hour: %v", lastHour),
+		})
+	}
+	return allowed, nextChecks
+}
+
+// recordRemediation adds the remediation of the machine to the history of the MachineHealthCheck,
+// if it is rate limited, replaced being set when the remediation deleted the machine.
+// Failing to record it is logged as the remediation started already.
+func (r *ReconcileMachineHealthCheck) recordRemediation(mhc *machinev1.MachineHealthCheck, m *machinev1.Machine, replaced bool) {
+	machineName := m.Name
+	if !remediationRateLimitOf(mhc).enabled() {
+		return
+	}
+
+	current := &machinev1.MachineHealthCheck{}
+	if err := r.client.Get(context.TODO(), namespacedName(mhc), current); err != nil {
+		klog.Errorf("%s: failed to record the remediation of machine %s: %v", namespacedName(mhc), machineName, err)
+		return
+	}
+	baseToPatch := client.MergeFrom(current.DeepCopy())
+
+	now := time.Now()
+	history := append(remediationHistoryOf(current, now), remediationRecordOf(m, replaced, now))
+	raw, err := json.Marshal(history)
+	if err != nil {
+		klog.Errorf("%s: failed to record the remediation of machine %s: %v", namespacedName(mhc), machineName, err)
+		return
+	}
+	if current.Annotations == nil {
+		current.Annotations = map[string]string{}
+	}
+	current.Annotations[remediationHistoryAnnotation] = string(raw)
+	if err := r.client.Patch(context.TODO(), current, baseToPatch); err != nil {
+		klog.Errorf("%s: failed to record the remediation of machine %s: %v", namespacedName(mhc), machineName, err)
+	}
+}
@@ -0,0 +1,222 @@
+package machinehealthcheck
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/conditions"
+	maotesting "github.com/openshift/machine-api-operator/pkg/util/testing"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+func TestRemediationRateLimitPostponement(t *testing.T) {
+	now := time.Date(1985, 06, 03, 12, 0, 0, 0, time.UTC)
+	remediated := func(machine string, ago time.Duration) remediationRecord {
+		return remediationRecord{Machine: machine, Time: metav1.NewTime(now.Add(-ago))}
+	}
+	replaced := func(machine string, ago time.Duration) remediationRecord {
+		return remediationRecord{Machine: machine, Owner: "MachineSet/machineset", Time: metav1.NewTime(now.Add(-ago))}
+	}
+	machine := maotesting.NewMachine("machine", "node")
+	machine.OwnerReferences = []metav1.OwnerReference{{Kind: "MachineSet", Name: "machineset", Controller: pointer.BoolPtr(true)}}
+
+	testCases := []struct {
+		name         string
+		limit        remediationRateLimit
+		history      []remediationRecord
+		expectedWait time.Duration
+	}{
+		{
+			name:  "under the hourly limit",
+			limit: remediationRateLimit{maxPerHour: 2},
+			history: []remediationRecord{
+				remediated("machine-1", 2*time.Hour),
+				remediated("machine-2", 10*time.Minute),
+			},
+		},
+		{
+			name:  "at the hourly limit",
+			limit: remediationRateLimit{maxPerHour: 2},
+			history: []remediationRecord{
+				remediated("machine-1", 40*time.Minute),
+				remediated("machine-2", 10*time.Minute),
+			},
+			expectedWait: 20 * time.Minute,
+		},
+		{
+			name:  "machine never remediated",
+			limit: remediationRateLimit{backoff: time.Minute},
+			history: []remediationRecord{
+				remediated("machine-2", 10*time.Second),
+			},
+		},
+		{
+			name:  "machine remediated once",
+			limit: remediationRateLimit{backoff: 10 * time.Minute},
+			history: []remediationRecord{
+				remediated("machine", 5*time.Minute),
+			},
+			expectedWait: 5 * time.Minute,
+		},
+		{
+			name:  "machine remediated repeatedly",
+			limit: remediationRateLimit{backoff: 10 * time.Minute},
+			history: []remediationRecord{
+				remediated("machine", 2*time.Hour),
+				remediated("machine", time.Hour),
+				remediated("machine", 30*time.Minute),
+			},
+			expectedWait: 10 * time.Minute,
+		},
+		{
+			name:  "backoff bounded by the history retention",
+			limit: remediationRateLimit{backoff: 4 * time.Hour},
+			history: []remediationRecord{
+				remediated("machine", 3*time.Hour),
+				remediated("machine", 2*time.Hour),
+				remediated("machine", time.Hour),
+				remediated("machine", 0),
+			},
+			expectedWait: remediationHistoryRetention,
+		},
+		{
+			name:  "longest of both limits",
+			limit: remediationRateLimit{maxPerHour: 1, backoff: time.Minute},
+			history: []remediationRecord{
+				remediated("machine", 30*time.Minute),
+			},
+			expectedWait: 30 * time.Minute,
+		},
+		{
+			name:  "machine replacing machines deleted by the remediation",
+			limit: remediationRateLimit{backoff: 10 * time.Minute},
+			history: []remediationRecord{
+				replaced("machine-abcde", time.Hour),
+				replaced("machine-fghij", 15*time.Minute),
+			},
+			expectedWait: 5 * time.Minute,
+		},
+		{
+			name:  "machine of a controller whose machines were remediated externally",
+			limit: remediationRateLimit{backoff: 10 * time.Minute},
+			history: []remediationRecord{
+				remediated("machine-abcde", 5*time.Minute),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if wait := tc.limit.postponement(tc.history, machine, now); wait != tc.expectedWait && !(wait <= 0 && tc.expectedWait == 0) {
+				t.Errorf("Expected to postpone the remediation by %v, got: %v", tc.expectedWait, wait)
+			}
+		})
+	}
+}
+
+func TestRemediationRateLimitOf(t *testing.T) {
+	mhc := maotesting.NewMachineHealthCheck("mhc")
+	mhc.Annotations = map[string]string{
+		maxRemediationsPerHourAnnotation: "3",
+		remediationBackoffAnnotation:     "ten minutes",
+	}
+	if limit := remediationRateLimitOf(mhc); limit != (remediationRateLimit{maxPerHour: 3}) {
+		t.Errorf("Expected the invalid backoff to be ignored, got: %+v", limit)
+	}
+
+	mhc.Annotations = nil
+	if limit := remediationRateLimitOf(mhc); limit.enabled() {
+		t.Errorf("Expected no rate limit, got: %+v", limit)
+	}
+}
+
+func TestRateLimitRemediations(t *testing.T) {
+	g := NewWithT(t)
+	// The history is recorded with a precision of a second
+	now := time.Now().Truncate(time.Second)
+
+	history, err := json.Marshal([]remediationRecord{
+		{Machine: "expired", Time: metav1.NewTime(now.Add(-2 * remediationHistoryRetention))},
+		{Machine: "remediated", Time: metav1.NewTime(now.Add(-time.Minute))},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	mhc := maotesting.NewMachineHealthCheck("mhc")
+	mhc.Annotations = map[string]string{
+		maxRemediationsPerHourAnnotation: "2",
+		remediationHistoryAnnotation:     string(history),
+	}
+
+	deleting := maotesting.NewMachine("deleting", "node")
+	deleting.DeletionTimestamp = &metav1.Time{Time: now}
+	targets := []target{
+		{Machine: *maotesting.NewMachine("first", "node")},
+		{Machine: *deleting},
+		{Machine: *maotesting.NewMachine("second", "node")},
+	}
+
+	r := newFakeReconciler(mhc)
+	allowed, nextChecks := r.rateLimitRemediations(context.TODO(), mhc, targets, now)
+
+	var allowedMachines []string
+	for _, t := range allowed {
+		allowedMachines = append(allowedMachines, t.Machine.Name)
+	}
+	g.Expect(allowedMachines).To(Equal([]string{"first", "deleting"}))
+	g.Expect(nextChecks).To(Equal([]time.Duration{59 * time.Minute}))
+
+	condition := conditions.Get(mhc, RemediationRateLimitedCondition)
+	g.Expect(condition).ToNot(BeNil())
+	g.Expect(condition.Status).To(Equal(corev1.ConditionTrue))
+	g.Expect(condition.Reason).To(Equal(RemediationPostponedReason))
+	g.Expect(condition.Message).To(Equal("Remediation of machines [second] postponed by the rate limit (remediations in the last hour: 1)"))
+
+	// Without a rate limit, all targets are remediated and the condition is not set
+	mhc = maotesting.NewMachineHealthCheck("mhc")
+	allowed, nextChecks = r.rateLimitRemediations(context.TODO(), mhc, targets, now)
+	g.Expect(allowed).To(HaveLen(len(targets)))
+	g.Expect(nextChecks).To(BeEmpty())
+	g.Expect(conditions.Get(mhc, RemediationRateLimitedCondition)).To(BeNil())
+}
+
+func TestRecordRemediation(t *testing.T) {
+	g := NewWithT(t)
+
+	mhc := maotesting.NewMachineHealthCheck("mhc")
+	mhc.Annotations = map[string]string{remediationBackoffAnnotation: "60"}
+	r := newFakeReconciler(mhc)
+
+	first := maotesting.NewMachine("first", "node")
+	first.OwnerReferences = []metav1.OwnerReference{{Kind: "MachineSet", Name: "machineset", Controller: pointer.BoolPtr(true)}}
+	r.recordRemediation(mhc, first, true)
+	r.recordRemediation(mhc, maotesting.NewMachine("second", "node"), false)
+
+	recorded := &machinev1.MachineHealthCheck{}
+	g.Expect(r.client.Get(context.TODO(), namespacedName(mhc), recorded)).To(Succeed())
+	history := remediationHistoryOf(recorded, time.Now())
+	g.Expect(history).To(HaveLen(2))
+	g.Expect(history[0].Machine).To(Equal("first"))
+	g.Expect(history[0].Owner).To(Equal("MachineSet/machineset"))
+	g.Expect(history[1].Machine).To(Equal("second"))
+	g.Expect(history[1].Owner).To(BeEmpty())
+
+	// The replacement of the deleted machine, created under a new name, is backed off
+	replacement := first.DeepCopy()
+	replacement.Name = "first-replacement"
+	allowed, nextChecks := r.rateLimitRemediations(context.TODO(), recorded, []target{{Machine: *replacement}}, time.Now())
+	g.Expect(allowed).To(BeEmpty())
+	g.Expect(nextChecks).To(HaveLen(1))
+
+	// The history of MachineHealthChecks which are not rate limited is not recorded
+	unlimited := maotesting.NewMachineHealthCheck("unlimited")
+	r = newFakeReconciler(unlimited)
+	r.recordRemediation(unlimited, first, true)
+	recorded = &machinev1.MachineHealthCheck{}
+	g.Expect(r.client.Get(context.TODO(), namespacedName(unlimited), recorded)).To(Succeed())
+	g.Expect(recorded.Annotations).ToNot(HaveKey(remediationHistoryAnnotation))
+}
@@ -0,0 +1,75 @@
+package machinehealthcheck
+
+import (
+	"context"
+	"time"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/schedule"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// remediationScheduleAnnotation is set on a MachineHealthCheck to a cron-style schedule of the minutes
+// during which it may remediate machines, eg. "* 1-4 * * *" for 01:00 to 04:59 UTC, see the schedule package
+const remediationScheduleAnnotation = "machine.openshift.io/remediation-schedule"
+
+// remediationScheduleOf returns the remediation schedule set on the MachineHealthCheck, if any.
+// An invalid schedule is ignored.
+func remediationScheduleOf(mhc *machinev1.MachineHealthCheck) *schedule.Schedule {
+	value, ok := mhc.Annotations[remediationScheduleAnnotation]
+	if !ok {
+		return nil
+	}
+	s, err := schedule.Parse(value)
+	if err != nil {
+		klog.Warningf("%s: ignoring invalid %s annotation %q: %v", namespacedName(mhc), remediationScheduleAnnotation, value, err)
+		return nil
+	}
+	return s
+}
+
+// scheduleRemediations returns the targets which may be remediated now under the remediation schedule
+// of the MachineHealthCheck, and when the schedule allows remediating the deferred targets.
+// Deferred targets are only reported, by an event.
+func (r *ReconcileMachineHealthCheck) scheduleRemediations(ctx context.Context, mhc *machinev1.MachineHealthCheck, targets []target, now time.Time) ([]target, []time.Duration) {
+	s := remediationScheduleOf(mhc)
+	if s == nil || s.Contains(now) {
+		return targets, nil
+	}
+	next, ok := s.Next(now)
+
+	var allowed []target
+	var nextChecks []time.Duration
+	for _, t := range targets {
+		if r.remediationInProgress(ctx, mhc, t) {
+			allowed = append(allowed, t)
+			continue
+		}
+
+		if !ok {
+			klog.Infof("%s: deferring remediation, the remediation schedule %q never allows it", t.string(), s)
+			r.recorder.Eventf(
+				mhc,
+				corev1.EventTypeWarning,
+				EventRemediationDeferred,
+				"Remediation of Machine %v deferred: the remediation schedule %q never allows it",
+				t.Machine.GetName(),
+				s,
+			)
+			continue
+		}
+		klog.Infof("%s: deferring remediation until %v, outside the remediation schedule %q", t.string(), next, s)
+		r.recorder.Eventf(
+			mhc,
+			corev1.EventTypeNormal,
+			EventRemediationDeferred,
+			"Remediation of Machine %v deferred until %v, outside the remediation schedule %q",
+			t.Machine.GetName(),
+			next.Format(time.RFC3339),
+			s,
+		)
+		nextChecks = append(nextChecks, next.Sub(now))
+	}
+	return allowed, nextChecks
+}
@@ -0,0 +1,82 @@
+package machinehealthcheck
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	maotesting "github.com/openshift/machine-api-operator/pkg/util/testing"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestScheduleRemediations(t *testing.T) {
+	now := time.Date(2021, time.September, 6, 12, 30, 0, 0, time.UTC)
+
+	deleting := maotesting.NewMachine("deleting", "node")
+	deleting.DeletionTimestamp = &metav1.Time{Time: now}
+	targets := []target{
+		{Machine: *maotesting.NewMachine("unhealthy", "node")},
+		{Machine: *deleting},
+	}
+
+	testCases := []struct {
+		name               string
+		schedule           string
+		expectedMachines   []string
+		expectedNextChecks []time.Duration
+		expectedEvents     []string
+	}{
+		{
+			name:             "without a schedule",
+			expectedMachines: []string{"unhealthy", "deleting"},
+		},
+		{
+			name:             "within the schedule",
+			schedule:         "* 12 * * *",
+			expectedMachines: []string{"unhealthy", "deleting"},
+		},
+		{
+			name:               "outside the schedule",
+			schedule:           "* 1-4 * * *",
+			expectedMachines:   []string{"deleting"},
+			expectedNextChecks: []time.Duration{12*time.Hour + 30*time.Minute},
+			expectedEvents:     []string{EventRemediationDeferred},
+		},
+		{
+			name:             "with a schedule which never allows remediation",
+			schedule:         "* * 30 2 *",
+			expectedMachines: []string{"deleting"},
+			expectedEvents:   []string{EventRemediationDeferred},
+		},
+		{
+			name:             "with an invalid schedule",
+			schedule:         "at night",
+			expectedMachines: []string{"unhealthy", "deleting"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mhc := maotesting.NewMachineHealthCheck("mhc")
+			if tc.schedule != "" {
+				mhc.Annotations = map[string]string{remediationScheduleAnnotation: tc.schedule}
+			}
+			recorder := record.NewFakeRecorder(2)
+			r := newFakeReconcilerWithCustomRecorder(recorder, mhc)
+
+			allowed, nextChecks := r.scheduleRemediations(context.TODO(), mhc, targets, now)
+
+			var machines []string
+			for _, t := range allowed {
+				machines = append(machines, t.Machine.Name)
+			}
+			g.Expect(machines).To(Equal(tc.expectedMachines))
+			g.Expect(nextChecks).To(Equal(tc.expectedNextChecks))
+			assertEvents(t, tc.name, tc.expectedEvents, recorder.Events)
+		})
+	}
+}
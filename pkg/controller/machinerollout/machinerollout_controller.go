@@ -0,0 +1,388 @@
+package machinerollout
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/controller/machineset"
+	"github.com/openshift/machine-api-operator/pkg/util/annotations"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// RollingUpdateAnnotation is set to "true" on a MachineSet to replace its machines when its template changes.
+	RollingUpdateAnnotation = "machine.openshift.io/rolling-update"
+
+	// MaxUnavailableAnnotation is set on a MachineSet to the number, or percentage of its replicas, of machines
+	// which may be unavailable during a rolling update, e.g. "1" or "25%". It defaults to 0.
+	MaxUnavailableAnnotation = "machine.openshift.io/rolling-update-max-unavailable"
+
+	// MaxSurgeAnnotation is set on a MachineSet to the number, or percentage of its replicas, of machines
+	// which may be created above its replicas during a rolling update, e.g. "1" or "25%". It defaults to 1.
+	MaxSurgeAnnotation = "machine.openshift.io/rolling-update-max-surge"
+
+	// RolloutTemplateHashAnnotation is set by the controller on a MachineSet to the hash of the template
+	// its machines are rolled out to.
+	RolloutTemplateHashAnnotation = "machine.openshift.io/rolling-update-template-hash"
+
+	// RolloutStateAnnotation is set by the controller on a MachineSet to the state of its rolling update,
+	// RolloutProgressing while machines created from a previous template remain, RolloutComplete otherwise.
+	RolloutStateAnnotation = "machine.openshift.io/rolling-update-state"
+
+	RolloutProgressing = "Progressing"
+	RolloutComplete    = "Complete"
+
+	machineRoleLabel  = "machine.openshift.io/cluster-api-machine-role"
+	nodeMasterLabel   = "node-role.kubernetes.io/master"
+	machineMasterRole = "master"
+
+	// rolloutRetryInterval is the interval to check again whether more machines can be replaced,
+	// while a rolling update is in progress.
+	rolloutRetryInterval = time.Minute
+
+	controllerName = "machinerollout-controller"
+)
+
+var (
+	defaultMaxUnavailable = intstr.FromInt(0)
+	defaultMaxSurge       = intstr.FromInt(1)
+)
+
+// blank assignment to verify that ReconcileMachineRollout implements reconcile.Reconciler
+var _ reconcile.Reconciler = &ReconcileMachineRollout{}
+
+// ReconcileMachineRollout replaces the machines of MachineSets which opt in to rolling updates once their template
+// changes, a few machines at a time.
+//
+// Machines are stamped by the MachineSet controller with the hash of the template they are created from.
+// The machines created from a previous template are marked for replacement, up to the max surge,
+// so that the MachineSet controller creates their replacements, and they are deleted as long as
+// no more than the max unavailable machines of the MachineSet are missing or not ready.
+type ReconcileMachineRollout struct {
+	client   client.Client
+	recorder record.EventRecorder
+}
+
+// Add creates a new machine rollout controller and adds it to the Manager.
+func Add(mgr manager.Manager, opts manager.Options) error {
+	r := &ReconcileMachineRollout{
+		client:   mgr.GetClient(),
+		recorder: mgr.GetEventRecorderFor(controllerName),
+	}
+
+	c, err := controller.New(controllerName, mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	if err := c.Watch(&source.Kind{Type: &machinev1.MachineSet{}}, &handler.EnqueueRequestForObject{}); err != nil {
+		return err
+	}
+	return c.Watch(
+		&source.Kind{Type: &machinev1.Machine{}},
+		&handler.EnqueueRequestForOwner{IsController: true, OwnerType: &machinev1.MachineSet{}},
+	)
+}
+
+// Reconcile replaces the machines of a MachineSet created from a previous template, within its rolling update limits.
+func (r *ReconcileMachineRollout) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	ms := &machinev1.MachineSet{}
+	if err := r.client.Get(ctx, request.NamespacedName, ms); err != nil {
+		if apierrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	if !ms.DeletionTimestamp.IsZero() || isMasterMachineSet(ms) {
+		return reconcile.Result{}, nil
+	}
+
+	machines, err := r.ownedMachines(ctx, ms)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	if ms.Annotations[RollingUpdateAnnotation] != "true" {
+		return reconcile.Result{}, r.stopRollout(ctx, ms, machines)
+	}
+
+	hash, err := machineset.TemplateHash(ms)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to hash the machine template: %w", err)
+	}
+
+	// The machines of a MachineSet which just opted in to rolling updates are up to date,
+	// including those created before machines were stamped with the hash of their template.
+	_, rollingOut := ms.Annotations[RolloutTemplateHashAnnotation]
+	var outdated []*machinev1.Machine
+	for _, m := range machines {
+		if !m.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if _, ok := m.Annotations[machineset.TemplateHashAnnotation]; !ok && !rollingOut {
+			if err := r.patchMachineAnnotation(ctx, m, machineset.TemplateHashAnnotation, hash); err != nil {
+				return reconcile.Result{}, err
+			}
+		}
+		if m.Annotations[machineset.TemplateHashAnnotation] != hash {
+			outdated = append(outdated, m)
+		}
+	}
+
+	state := RolloutComplete
+	if len(outdated) > 0 {
+		state = RolloutProgressing
+	}
+	if err := r.setRolloutState(ctx, ms, hash, state); err != nil {
+		return reconcile.Result{}, err
+	}
+	if len(outdated) == 0 {
+		return reconcile.Result{}, nil
+	}
+
+	// Paused machines are neither replaced nor deleted, the rollout progresses once they are resumed.
+	var replaceable []*machinev1.Machine
+	for _, m := range outdated {
+		if annotations.IsMachinePaused(m) {
+			klog.V(3).Infof("%v: not replacing paused machine %q", ms.GetName(), m.GetName())
+			continue
+		}
+		replaceable = append(replaceable, m)
+	}
+	outdated = replaceable
+
+	replicas := 1
+	if ms.Spec.Replicas != nil {
+		replicas = int(*ms.Spec.Replicas)
+	}
+	maxSurge, maxUnavailable := rollingUpdateLimits(ms, replicas)
+
+	ready := map[string]bool{}
+	readyCount, active, updatedUnavailable := 0, 0, 0
+	for _, m := range machines {
+		if !m.DeletionTimestamp.IsZero() {
+			continue
+		}
+		active++
+		isReady, err := r.isMachineReady(ctx, m)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if isReady {
+			ready[m.Name] = true
+			readyCount++
+		} else if m.Annotations[machineset.TemplateHashAnnotation] == hash {
+			updatedUnavailable++
+		}
+	}
+
+	// Replace the machines which are not ready first, then the oldest ones.
+	sort.SliceStable(outdated, func(i, j int) bool {
+		if ready[outdated[i].Name] != ready[outdated[j].Name] {
+			return !ready[outdated[i].Name]
+		}
+		return outdated[i].CreationTimestamp.Before(&outdated[j].CreationTimestamp)
+	})
+
+	// Mark machines for replacement, so that the MachineSet creates their replacements,
+	// without exceeding its replicas by more than the max surge.
+	replacing := 0
+	for _, m := range outdated {
+		if isBeingReplaced(m) {
+			replacing++
+		}
+	}
+	for _, m := range outdated {
+		if replacing >= maxSurge {
+			break
+		}
+		if isBeingReplaced(m) {
+			continue
+		}
+		if err := r.patchMachineAnnotation(ctx, m, machineset.ReplaceMachineAnnotation, ""); err != nil {
+			return reconcile.Result{}, err
+		}
+		r.recorder.Eventf(m, corev1.EventTypeNormal, "Replacing", "Machine is being replaced by a rolling update of MachineSet %s", ms.GetName())
+		replacing++
+	}
+
+	// Delete machines, the ones which are not ready first, then the ones already replaced, so that no more than
+	// the max unavailable machines are missing or not ready: machines being deleted count as unavailable, and
+	// machines are not deleted while their up to date replacements are not ready.
+	sort.SliceStable(outdated, func(i, j int) bool {
+		if ready[outdated[i].Name] != ready[outdated[j].Name] {
+			return !ready[outdated[i].Name]
+		}
+		return isBeingReplaced(outdated[i]) && !isBeingReplaced(outdated[j])
+	})
+	minAvailable := replicas - maxUnavailable
+	deletable := active - minAvailable - updatedUnavailable
+	readyDeletable := readyCount - minAvailable
+	for _, m := range outdated {
+		if deletable <= 0 {
+			break
+		}
+		if ready[m.Name] {
+			if readyDeletable <= 0 {
+				continue
+			}
+			readyDeletable--
+		}
+		deletable--
+
+		klog.Infof("%v: deleting machine %q created from a previous template", ms.GetName(), m.GetName())
+		if err := r.client.Delete(ctx, m); err != nil && !apierrors.IsNotFound(err) {
+			return reconcile.Result{}, err
+		}
+		r.recorder.Eventf(m, corev1.EventTypeNormal, "Replaced", "Deleted machine created from a previous template of MachineSet %s", ms.GetName())
+	}
+
+	return reconcile.Result{RequeueAfter: rolloutRetryInterval}, nil
+}
+
+// rollingUpdateLimits returns the max surge and max unavailable of the MachineSet with the replicas.
+// Invalid limits are ignored. The max surge is 1 when both are 0, so that the rolling update progresses.
+func rollingUpdateLimits(ms *machinev1.MachineSet, replicas int) (int, int) {
+	maxSurge := scaledLimit(ms, MaxSurgeAnnotation, defaultMaxSurge, replicas, true)
+	maxUnavailable := scaledLimit(ms, MaxUnavailableAnnotation, defaultMaxUnavailable, replicas, false)
+	if maxSurge == 0 && maxUnavailable == 0 {
+		maxSurge = 1
+	}
+	return maxSurge, maxUnavailable
+}
+
+// scaledLimit returns the limit of the annotation, scaled to the replicas when it is a percentage.
+func scaledLimit(ms *machinev1.MachineSet, annotation string, defaultLimit intstr.IntOrString, replicas int, roundUp bool) int {
+	limit := defaultLimit
+	if value, ok := ms.Annotations[annotation]; ok {
+		limit = intstr.Parse(value)
+	}
+	scaled, err := intstr.GetScaledValueFromIntOrPercent(&limit, replicas, roundUp)
+	if err == nil && scaled < 0 {
+		err = fmt.Errorf("negative limit %d", scaled)
+	}
+	if err != nil {
+		klog.Warningf("%v: ignoring invalid %s annotation: %v", ms.GetName(), annotation, err)
+		scaled, _ = intstr.GetScaledValueFromIntOrPercent(&defaultLimit, replicas, roundUp)
+	}
+	return scaled
+}
+
+// setRolloutState records the template hash the machines of the MachineSet are rolled out to and the state
+// of the rolling update, and records an event when a rolling update starts or completes.
+func (r *ReconcileMachineRollout) setRolloutState(ctx context.Context, ms *machinev1.MachineSet, hash, state string) error {
+	previousState := ms.Annotations[RolloutStateAnnotation]
+	if ms.Annotations[RolloutTemplateHashAnnotation] == hash && previousState == state {
+		return nil
+	}
+
+	baseToPatch := client.MergeFrom(ms.DeepCopy())
+	ms.Annotations[RolloutTemplateHashAnnotation] = hash
+	ms.Annotations[RolloutStateAnnotation] = state
+	if err := r.client.Patch(ctx, ms, baseToPatch); err != nil {
+		return err
+	}
+
+	switch {
+	case state == RolloutProgressing && previousState != RolloutProgressing:
+		r.recorder.Event(ms, corev1.EventTypeNormal, "RollingUpdateStarted", "Replacing machines created from a previous template")
+	case state == RolloutComplete && previousState == RolloutProgressing:
+		r.recorder.Event(ms, corev1.EventTypeNormal, "RollingUpdateCompleted", "All machines are created from the current template")
+	}
+	return nil
+}
+
+// stopRollout clears the rolling update state of a MachineSet which does not opt in to rolling updates,
+// and lets the MachineSet count the machines which were being replaced towards its replicas again.
+func (r *ReconcileMachineRollout) stopRollout(ctx context.Context, ms *machinev1.MachineSet, machines []*machinev1.Machine) error {
+	for _, m := range machines {
+		if !isBeingReplaced(m) || !m.DeletionTimestamp.IsZero() {
+			continue
+		}
+		baseToPatch := client.MergeFrom(m.DeepCopy())
+		delete(m.Annotations, machineset.ReplaceMachineAnnotation)
+		if err := r.client.Patch(ctx, m, baseToPatch); err != nil {
+			return err
+		}
+	}
+
+	_, hasHash := ms.Annotations[RolloutTemplateHashAnnotation]
+	_, hasState := ms.Annotations[RolloutStateAnnotation]
+	if !hasHash && !hasState {
+		return nil
+	}
+	baseToPatch := client.MergeFrom(ms.DeepCopy())
+	delete(ms.Annotations, RolloutTemplateHashAnnotation)
+	delete(ms.Annotations, RolloutStateAnnotation)
+	return r.client.Patch(ctx, ms, baseToPatch)
+}
+
+// ownedMachines returns the machines controlled by the MachineSet.
+func (r *ReconcileMachineRollout) ownedMachines(ctx context.Context, ms *machinev1.MachineSet) ([]*machinev1.Machine, error) {
+	machineList := &machinev1.MachineList{}
+	if err := r.client.List(ctx, machineList, client.InNamespace(ms.Namespace)); err != nil {
+		return nil, err
+	}
+
+	var machines []*machinev1.Machine
+	for i := range machineList.Items {
+		ref := metav1.GetControllerOf(&machineList.Items[i])
+		if ref != nil && ref.UID == ms.UID {
+			machines = append(machines, &machineList.Items[i])
+		}
+	}
+	sort.Slice(machines, func(i, j int) bool { return machines[i].Name < machines[j].Name })
+	return machines, nil
+}
+
+// patchMachineAnnotation sets the annotation of the machine.
+func (r *ReconcileMachineRollout) patchMachineAnnotation(ctx context.Context, m *machinev1.Machine, annotation, value string) error {
+	baseToPatch := client.MergeFrom(m.DeepCopy())
+	if m.Annotations == nil {
+		m.Annotations = map[string]string{}
+	}
+	m.Annotations[annotation] = value
+	return r.client.Patch(ctx, m, baseToPatch)
+}
+
+// isMachineReady returns whether the node of the machine is ready. A machine whose node is not found is not ready.
+func (r *ReconcileMachineRollout) isMachineReady(ctx context.Context, m *machinev1.Machine) (bool, error) {
+	if m.Status.NodeRef == nil {
+		return false, nil
+	}
+	node := &corev1.Node{}
+	if err := r.client.Get(ctx, client.ObjectKey{Name: m.Status.NodeRef.Name}, node); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get node %q of machine %q: %w", m.Status.NodeRef.Name, m.GetName(), err)
+	}
+	return machineset.IsNodeReady(node), nil
+}
+
+func isBeingReplaced(m *machinev1.Machine) bool {
+	_, ok := m.Annotations[machineset.ReplaceMachineAnnotation]
+	return ok
+}
+
+func isMasterMachineSet(ms *machinev1.MachineSet) bool {
+	if ms.Spec.Template.Labels[machineRoleLabel] == machineMasterRole {
+		return true
+	}
+	_, ok := ms.Spec.Template.Spec.ObjectMeta.Labels[nodeMasterLabel]
+	return ok
+}
@@ -0,0 +1,392 @@
+package machinerollout
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/controller/machineset"
+	"github.com/openshift/machine-api-operator/pkg/util/annotations"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const namespace = "openshift-machine-api"
+
+var start = time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func init() {
+	// Add types to scheme
+	machinev1.AddToScheme(scheme.Scheme)
+}
+
+func newMachineSet(annotations map[string]string, replicas int32) *machinev1.MachineSet {
+	return &machinev1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "workers",
+			Namespace:   namespace,
+			UID:         "workers-uid",
+			Annotations: annotations,
+		},
+		Spec: machinev1.MachineSetSpec{
+			Replicas: pointer.Int32Ptr(replicas),
+			Template: machinev1.MachineTemplateSpec{
+				ObjectMeta: machinev1.ObjectMeta{Labels: map[string]string{machineRoleLabel: "worker"}},
+			},
+		},
+	}
+}
+
+func templateHash(t *testing.T, ms *machinev1.MachineSet) string {
+	t.Helper()
+	hash, err := machineset.TemplateHash(ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return hash
+}
+
+// newMachine returns a ready machine of the MachineSet created age before start from the template with the hash,
+// and its node. Machines keep a finalizer, so that deleted machines stay around until the test releases them.
+func newMachine(ms *machinev1.MachineSet, name, hash string, age time.Duration) (*machinev1.Machine, *corev1.Node) {
+	m := &machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         namespace,
+			CreationTimestamp: metav1.NewTime(start.Add(-age)),
+			Finalizers:        []string{machinev1.MachineFinalizer},
+			Labels:            map[string]string{machineRoleLabel: "worker"},
+			OwnerReferences:   []metav1.OwnerReference{*metav1.NewControllerRef(ms, machinev1.SchemeGroupVersion.WithKind("MachineSet"))},
+		},
+		Status: machinev1.MachineStatus{
+			NodeRef: &corev1.ObjectReference{Name: name},
+		},
+	}
+	if hash != "" {
+		m.Annotations = map[string]string{machineset.TemplateHashAnnotation: hash}
+	}
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+		},
+	}
+	return m, node
+}
+
+func newReconciler(objects ...runtime.Object) *ReconcileMachineRollout {
+	return &ReconcileMachineRollout{
+		client:   fake.NewFakeClientWithScheme(scheme.Scheme, objects...),
+		recorder: record.NewFakeRecorder(32),
+	}
+}
+
+func reconcileMachineSet(t *testing.T, r *ReconcileMachineRollout, ms *machinev1.MachineSet) reconcile.Result {
+	t.Helper()
+	result, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ms)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return result
+}
+
+func getMachine(t *testing.T, r *ReconcileMachineRollout, name string) *machinev1.Machine {
+	t.Helper()
+	m := &machinev1.Machine{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, m); err != nil {
+		t.Fatalf("unexpected error getting machine %s: %v", name, err)
+	}
+	return m
+}
+
+func getMachineSet(t *testing.T, r *ReconcileMachineRollout, ms *machinev1.MachineSet) *machinev1.MachineSet {
+	t.Helper()
+	got := &machinev1.MachineSet{}
+	if err := r.client.Get(context.TODO(), client.ObjectKeyFromObject(ms), got); err != nil {
+		t.Fatalf("unexpected error getting machine set: %v", err)
+	}
+	return got
+}
+
+func TestReconcileOptIn(t *testing.T) {
+	ms := newMachineSet(map[string]string{RollingUpdateAnnotation: "true"}, 2)
+	// Machines created before machines were stamped with the hash of their template are up to date
+	unstamped, unstampedNode := newMachine(ms, "unstamped", "", time.Hour)
+	stamped, stampedNode := newMachine(ms, "stamped", templateHash(t, ms), time.Hour)
+	r := newReconciler(ms, unstamped, unstampedNode, stamped, stampedNode)
+
+	if result := reconcileMachineSet(t, r, ms); result.RequeueAfter != 0 {
+		t.Errorf("expected no requeue without outdated machines, got: %v", result.RequeueAfter)
+	}
+
+	if hash := getMachine(t, r, "unstamped").Annotations[machineset.TemplateHashAnnotation]; hash != templateHash(t, ms) {
+		t.Errorf("expected machine unstamped to be stamped with the template hash, got: %q", hash)
+	}
+	got := getMachineSet(t, r, ms)
+	if got.Annotations[RolloutStateAnnotation] != RolloutComplete {
+		t.Errorf("expected the rolling update to be complete, got: %q", got.Annotations[RolloutStateAnnotation])
+	}
+	if got.Annotations[RolloutTemplateHashAnnotation] != templateHash(t, ms) {
+		t.Errorf("expected the template hash to be recorded, got: %q", got.Annotations[RolloutTemplateHashAnnotation])
+	}
+}
+
+func TestReconcileMaxSurge(t *testing.T) {
+	ms := newMachineSet(map[string]string{RollingUpdateAnnotation: "true"}, 2)
+	oldHash := templateHash(t, ms)
+	older, olderNode := newMachine(ms, "older", oldHash, 2*time.Hour)
+	old, oldNode := newMachine(ms, "old", oldHash, time.Hour)
+	ms.Spec.Template.Spec.ProviderID = pointer.StringPtr("edited")
+	r := newReconciler(ms, older, olderNode, old, oldNode)
+
+	if result := reconcileMachineSet(t, r, ms); result.RequeueAfter != rolloutRetryInterval {
+		t.Errorf("expected a requeue while the rolling update progresses, got: %v", result.RequeueAfter)
+	}
+
+	// The oldest machine is replaced first, no machine is deleted before its replacement is ready
+	if _, ok := getMachine(t, r, "older").Annotations[machineset.ReplaceMachineAnnotation]; !ok {
+		t.Errorf("expected machine older to be replaced")
+	}
+	if _, ok := getMachine(t, r, "old").Annotations[machineset.ReplaceMachineAnnotation]; ok {
+		t.Errorf("expected a single machine to be replaced at a time")
+	}
+	if getMachine(t, r, "older").DeletionTimestamp != nil {
+		t.Errorf("expected no machine to be deleted before its replacement is ready")
+	}
+	if state := getMachineSet(t, r, ms).Annotations[RolloutStateAnnotation]; state != RolloutProgressing {
+		t.Errorf("expected the rolling update to progress, got: %q", state)
+	}
+
+	// The MachineSet creates the replacement, which is not ready yet
+	replacement, replacementNode := newMachine(ms, "replacement", templateHash(t, ms), 0)
+	replacementNode.Status.Conditions[0].Status = corev1.ConditionFalse
+	if err := r.client.Create(context.TODO(), replacement); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.client.Create(context.TODO(), replacementNode); err != nil {
+		t.Fatal(err)
+	}
+	reconcileMachineSet(t, r, ms)
+	if getMachine(t, r, "older").DeletionTimestamp != nil {
+		t.Errorf("expected no machine to be deleted before its replacement is ready")
+	}
+
+	// Once the replacement is ready, the replaced machine is deleted
+	replacementNode.Status.Conditions[0].Status = corev1.ConditionTrue
+	if err := r.client.Update(context.TODO(), replacementNode); err != nil {
+		t.Fatal(err)
+	}
+	reconcileMachineSet(t, r, ms)
+	if getMachine(t, r, "older").DeletionTimestamp == nil {
+		t.Errorf("expected machine older to be deleted once its replacement is ready")
+	}
+
+	// The next machine is then replaced
+	reconcileMachineSet(t, r, ms)
+	if _, ok := getMachine(t, r, "old").Annotations[machineset.ReplaceMachineAnnotation]; !ok {
+		t.Errorf("expected machine old to be replaced")
+	}
+}
+
+func TestReconcileMaxUnavailable(t *testing.T) {
+	ms := newMachineSet(map[string]string{
+		RollingUpdateAnnotation:  "true",
+		MaxUnavailableAnnotation: "1",
+		MaxSurgeAnnotation:       "0",
+	}, 3)
+	oldHash := templateHash(t, ms)
+	a, aNode := newMachine(ms, "a", oldHash, time.Hour)
+	b, bNode := newMachine(ms, "b", oldHash, time.Hour)
+	c, cNode := newMachine(ms, "c", oldHash, time.Hour)
+	ms.Spec.Template.Spec.ProviderID = pointer.StringPtr("edited")
+	r := newReconciler(ms, a, aNode, b, bNode, c, cNode)
+
+	reconcileMachineSet(t, r, ms)
+
+	deleted := 0
+	for _, name := range []string{"a", "b", "c"} {
+		m := getMachine(t, r, name)
+		if _, ok := m.Annotations[machineset.ReplaceMachineAnnotation]; ok {
+			t.Errorf("expected no machine to be replaced without surge, got machine %s", name)
+		}
+		if m.DeletionTimestamp != nil {
+			deleted++
+		}
+	}
+	if deleted != 1 {
+		t.Errorf("expected a single machine to be deleted, got: %d", deleted)
+	}
+}
+
+func TestReconcileMaxUnavailableNotReady(t *testing.T) {
+	ms := newMachineSet(map[string]string{
+		RollingUpdateAnnotation:  "true",
+		MaxUnavailableAnnotation: "1",
+		MaxSurgeAnnotation:       "0",
+	}, 3)
+	oldHash := templateHash(t, ms)
+	objects := []runtime.Object{ms}
+	for _, name := range []string{"a", "b", "c"} {
+		m, node := newMachine(ms, name, oldHash, time.Hour)
+		node.Status.Conditions[0].Status = corev1.ConditionFalse
+		objects = append(objects, m, node)
+	}
+	ms.Spec.Template.Spec.ProviderID = pointer.StringPtr("edited")
+	r := newReconciler(objects...)
+
+	reconcileMachineSet(t, r, ms)
+
+	// Machines which are not ready are deleted within the max unavailable too
+	deleted := 0
+	for _, name := range []string{"a", "b", "c"} {
+		if getMachine(t, r, name).DeletionTimestamp != nil {
+			deleted++
+		}
+	}
+	if deleted != 1 {
+		t.Errorf("expected a single machine to be deleted, got: %d", deleted)
+	}
+
+	// No other machine is deleted while the deleted one is not replaced
+	reconcileMachineSet(t, r, ms)
+	deleted = 0
+	for _, name := range []string{"a", "b", "c"} {
+		if getMachine(t, r, name).DeletionTimestamp != nil {
+			deleted++
+		}
+	}
+	if deleted != 1 {
+		t.Errorf("expected a single machine to be deleted, got: %d", deleted)
+	}
+}
+
+// nodeGetErrorClient fails to get nodes.
+type nodeGetErrorClient struct {
+	client.Client
+}
+
+func (c nodeGetErrorClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if _, ok := obj.(*corev1.Node); ok {
+		return errors.New("node get error")
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func TestReconcileNodeGetError(t *testing.T) {
+	ms := newMachineSet(map[string]string{
+		RollingUpdateAnnotation:  "true",
+		MaxUnavailableAnnotation: "1",
+		MaxSurgeAnnotation:       "0",
+	}, 1)
+	m, node := newMachine(ms, "machine", templateHash(t, ms), time.Hour)
+	ms.Spec.Template.Spec.ProviderID = pointer.StringPtr("edited")
+	r := newReconciler(ms, m, node)
+	r.client = nodeGetErrorClient{Client: r.client}
+
+	// The machine is not deleted as if its node were not ready, the reconcile is retried instead
+	if _, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ms)}); err == nil {
+		t.Errorf("expected an error when the node cannot be fetched")
+	}
+	if getMachine(t, r, "machine").DeletionTimestamp != nil {
+		t.Errorf("expected the machine not to be deleted")
+	}
+}
+
+func TestReconcilePausedMachines(t *testing.T) {
+	ms := newMachineSet(map[string]string{RollingUpdateAnnotation: "true"}, 2)
+	oldHash := templateHash(t, ms)
+	paused, pausedNode := newMachine(ms, "paused", oldHash, 2*time.Hour)
+	paused.Annotations[annotations.MachinePausedAnnotation] = ""
+	old, oldNode := newMachine(ms, "old", oldHash, time.Hour)
+	ms.Spec.Template.Spec.ProviderID = pointer.StringPtr("edited")
+	r := newReconciler(ms, paused, pausedNode, old, oldNode)
+
+	reconcileMachineSet(t, r, ms)
+
+	// The paused machine is skipped although it is the oldest one
+	if _, ok := getMachine(t, r, "paused").Annotations[machineset.ReplaceMachineAnnotation]; ok {
+		t.Errorf("expected paused machine not to be replaced")
+	}
+	if _, ok := getMachine(t, r, "old").Annotations[machineset.ReplaceMachineAnnotation]; !ok {
+		t.Errorf("expected machine old to be replaced")
+	}
+	if state := getMachineSet(t, r, ms).Annotations[RolloutStateAnnotation]; state != RolloutProgressing {
+		t.Errorf("expected the rolling update to progress, got: %q", state)
+	}
+}
+
+func TestReconcileOptOut(t *testing.T) {
+	ms := newMachineSet(map[string]string{
+		RolloutTemplateHashAnnotation: "previous",
+		RolloutStateAnnotation:        RolloutProgressing,
+	}, 1)
+	m, node := newMachine(ms, "machine", "previous", time.Hour)
+	m.Annotations[machineset.ReplaceMachineAnnotation] = ""
+	r := newReconciler(ms, m, node)
+
+	reconcileMachineSet(t, r, ms)
+
+	if _, ok := getMachine(t, r, "machine").Annotations[machineset.ReplaceMachineAnnotation]; ok {
+		t.Errorf("expected the machine no longer to be replaced")
+	}
+	got := getMachineSet(t, r, ms)
+	for _, annotation := range []string{RolloutTemplateHashAnnotation, RolloutStateAnnotation} {
+		if value, ok := got.Annotations[annotation]; ok {
+			t.Errorf("expected annotation %s to be removed, got: %q", annotation, value)
+		}
+	}
+}
+
+func TestRollingUpdateLimits(t *testing.T) {
+	testCases := []struct {
+		name                   string
+		annotations            map[string]string
+		replicas               int
+		expectedMaxSurge       int
+		expectedMaxUnavailable int
+	}{
+		{
+			name:             "defaults",
+			replicas:         4,
+			expectedMaxSurge: 1,
+		},
+		{
+			name:                   "percentages",
+			annotations:            map[string]string{MaxSurgeAnnotation: "25%", MaxUnavailableAnnotation: "25%"},
+			replicas:               6,
+			expectedMaxSurge:       2,
+			expectedMaxUnavailable: 1,
+		},
+		{
+			name:             "both zero",
+			annotations:      map[string]string{MaxSurgeAnnotation: "0", MaxUnavailableAnnotation: "0"},
+			replicas:         4,
+			expectedMaxSurge: 1,
+		},
+		{
+			name:             "invalid",
+			annotations:      map[string]string{MaxSurgeAnnotation: "many", MaxUnavailableAnnotation: "-1"},
+			replicas:         4,
+			expectedMaxSurge: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			maxSurge, maxUnavailable := rollingUpdateLimits(newMachineSet(tc.annotations, int32(tc.replicas)), tc.replicas)
+			if maxSurge != tc.expectedMaxSurge || maxUnavailable != tc.expectedMaxUnavailable {
+				t.Errorf("expected max surge %d and max unavailable %d, got: %d and %d", tc.expectedMaxSurge, tc.expectedMaxUnavailable, maxSurge, maxUnavailable)
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package machinerotation
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/annotations"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// MaxAgeAnnotation is set on a MachineSet to override the maximum machine age, e.g. "720h" or "30d".
+	// A value of "0" disables rotation for the MachineSet.
+	MaxAgeAnnotation = "machine.openshift.io/max-age"
+
+	// AutoRotateAnnotation is set to "true" on a MachineSet to delete its machines once they are due
+	// for rotation, so that the MachineSet replaces them.
+	AutoRotateAnnotation = "machine.openshift.io/auto-rotate"
+
+	// RotationDueAnnotation is set on machines older than the maximum machine age,
+	// to the time they became due for rotation.
+	RotationDueAnnotation = "machine.openshift.io/rotation-due"
+
+	machineRoleLabel  = "machine.openshift.io/cluster-api-machine-role"
+	nodeMasterLabel   = "node-role.kubernetes.io/master"
+	machineMasterRole = "master"
+
+	// machinePhaseRunning mirrors the Running phase of the machine controller.
+	machinePhaseRunning = "Running"
+
+	// rotationRetryInterval is the interval to check again whether a due machine can be rotated,
+	// while the MachineSet is not ready or another machine is being deleted.
+	rotationRetryInterval = time.Minute
+
+	controllerName = "machinerotation-controller"
+)
+
+// blank assignment to verify that ReconcileMachineRotation implements reconcile.Reconciler
+var _ reconcile.Reconciler = &ReconcileMachineRotation{}
+
+// ReconcileMachineRotation marks machines of MachineSets which are older than the maximum machine age
+// as due for rotation, and deletes them one at a time for MachineSets which opt in to auto-rotation.
+type ReconcileMachineRotation struct {
+	client   client.Client
+	recorder record.EventRecorder
+
+	// maxMachineAge is the maximum machine age for MachineSets without the max-age annotation.
+	// Zero disables rotation for those MachineSets.
+	maxMachineAge time.Duration
+
+	// nowFunc is used to mock time in testing. It should be nil in production.
+	nowFunc func() time.Time
+}
+
+// AddWithMaxMachineAge creates a new machine rotation controller and adds it to the Manager.
+// maxMachineAge applies to MachineSets without the max-age annotation, zero disables it.
+func AddWithMaxMachineAge(mgr manager.Manager, opts manager.Options, maxMachineAge time.Duration) error {
+	r := &ReconcileMachineRotation{
+		client:        mgr.GetClient(),
+		recorder:      mgr.GetEventRecorderFor(controllerName),
+		maxMachineAge: maxMachineAge,
+	}
+
+	c, err := controller.New(controllerName, mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	if err := c.Watch(&source.Kind{Type: &machinev1.MachineSet{}}, &handler.EnqueueRequestForObject{}); err != nil {
+		return err
+	}
+	return c.Watch(
+		&source.Kind{Type: &machinev1.Machine{}},
+		&handler.EnqueueRequestForOwner{IsController: true, OwnerType: &machinev1.MachineSet{}},
+	)
+}
+
+// Reconcile marks the machines of a MachineSet which are due for rotation and rotates one of them when allowed.
+func (r *ReconcileMachineRotation) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	ms := &machinev1.MachineSet{}
+	if err := r.client.Get(ctx, request.NamespacedName, ms); err != nil {
+		if apierrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	if !ms.DeletionTimestamp.IsZero() || isMasterMachineSet(ms) {
+		return reconcile.Result{}, nil
+	}
+
+	maxAge, err := r.maxAge(ms)
+	if err != nil {
+		klog.Warningf("%v: ignoring invalid %s annotation: %v", ms.GetName(), MaxAgeAnnotation, err)
+		maxAge = r.maxMachineAge
+	}
+	if maxAge <= 0 {
+		return reconcile.Result{}, nil
+	}
+
+	machines, err := r.ownedMachines(ctx, ms)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	now := r.now()
+	var due []*machinev1.Machine
+	var nextDue time.Duration
+	deleting := false
+	running := int32(0)
+	for _, m := range machines {
+		if !m.DeletionTimestamp.IsZero() {
+			deleting = true
+			continue
+		}
+		if isRunningMachine(m) {
+			running++
+		}
+		// Paused machines are not rotated.
+		if isMasterMachine(m) || annotations.IsMachinePaused(m) {
+			continue
+		}
+
+		dueAt := m.CreationTimestamp.Add(maxAge)
+		if now.Before(dueAt) {
+			if until := dueAt.Sub(now); nextDue == 0 || until < nextDue {
+				nextDue = until
+			}
+			continue
+		}
+
+		if err := r.markRotationDue(ctx, m, dueAt, maxAge); err != nil {
+			return reconcile.Result{}, err
+		}
+		due = append(due, m)
+	}
+
+	result := reconcile.Result{RequeueAfter: nextDue}
+	if len(due) == 0 || ms.Annotations[AutoRotateAnnotation] != "true" {
+		return result, nil
+	}
+
+	// Rotate a single machine at a time, and only while the MachineSet is at full strength,
+	// so that the replacement of the previous machine is ready before the next one is deleted.
+	// The MachineSet status is updated asynchronously and may still count the previous machine as ready
+	// once it is gone, so the running machines are counted as well.
+	if deleting {
+		klog.V(3).Infof("%v: not rotating machines while a machine is being deleted", ms.GetName())
+		return reconcile.Result{RequeueAfter: rotationRetryInterval}, nil
+	}
+	desired := int32(1)
+	if ms.Spec.Replicas != nil {
+		desired = *ms.Spec.Replicas
+	}
+	if ms.Status.ReadyReplicas < desired || running < desired {
+		klog.V(3).Infof("%v: not rotating machines with %d of %d replicas ready and %d running", ms.GetName(), ms.Status.ReadyReplicas, desired, running)
+		return reconcile.Result{RequeueAfter: rotationRetryInterval}, nil
+	}
+
+	oldest := due[0]
+	for _, m := range due[1:] {
+		if m.CreationTimestamp.Before(&oldest.CreationTimestamp) {
+			oldest = m
+		}
+	}
+
+	klog.Infof("%v: rotating machine %q, which is older than %s", ms.GetName(), oldest.GetName(), maxAge)
+	if err := r.client.Delete(ctx, oldest); err != nil && !apierrors.IsNotFound(err) {
+		return reconcile.Result{}, err
+	}
+	r.recorder.Eventf(oldest, corev1.EventTypeNormal, "Rotated", "Deleted machine older than the maximum machine age %s so that MachineSet %s replaces it", maxAge, ms.GetName())
+	return reconcile.Result{RequeueAfter: rotationRetryInterval}, nil
+}
+
+// maxAge returns the maximum machine age of the MachineSet.
+func (r *ReconcileMachineRotation) maxAge(ms *machinev1.MachineSet) (time.Duration, error) {
+	value, ok := ms.Annotations[MaxAgeAnnotation]
+	if !ok {
+		return r.maxMachineAge, nil
+	}
+	return ParseMaxAge(value)
+}
+
+// ParseMaxAge parses a maximum machine age. Besides Go durations, whole days are accepted, e.g. "30d".
+func ParseMaxAge(value string) (time.Duration, error) {
+	if days := strings.TrimSuffix(value, "d"); days != value {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid maximum machine age %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	age, err := time.ParseDuration(value)
+	if err != nil || age < 0 {
+		return 0, fmt.Errorf("invalid maximum machine age %q", value)
+	}
+	return age, nil
+}
+
+// ownedMachines returns the machines controlled by the MachineSet.
+func (r *ReconcileMachineRotation) ownedMachines(ctx context.Context, ms *machinev1.MachineSet) ([]*machinev1.Machine, error) {
+	machineList := &machinev1.MachineList{}
+	if err := r.client.List(ctx, machineList, client.InNamespace(ms.Namespace)); err != nil {
+		return nil, err
+	}
+
+	var machines []*machinev1.Machine
+	for i := range machineList.Items {
+		ref := metav1.GetControllerOf(&machineList.Items[i])
+		if ref != nil && ref.UID == ms.UID {
+			machines = append(machines, &machineList.Items[i])
+		}
+	}
+	sort.Slice(machines, func(i, j int) bool { return machines[i].Name < machines[j].Name })
+	return machines, nil
+}
+
+// markRotationDue annotates the machine as due for rotation and records an event, unless it is annotated already.
+func (r *ReconcileMachineRotation) markRotationDue(ctx context.Context, m *machinev1.Machine, dueAt time.Time, maxAge time.Duration) error {
+	if _, ok := m.Annotations[RotationDueAnnotation]; ok {
+		return nil
+	}
+
+	baseToPatch := client.MergeFrom(m.DeepCopy())
+	if m.Annotations == nil {
+		m.Annotations = map[string]string{}
+	}
+	m.Annotations[RotationDueAnnotation] = dueAt.UTC().Format(time.RFC3339)
+	if err := r.client.Patch(ctx, m, baseToPatch); err != nil {
+		return err
+	}
+
+	r.recorder.Eventf(m, corev1.EventTypeNormal, "RotationDue", "Machine is older than the maximum machine age %s", maxAge)
+	return nil
+}
+
+// now is used to get the current time. If the reconciler nowFunc is no nil this will be used instead of time.Now().
+func (r *ReconcileMachineRotation) now() time.Time {
+	if r.nowFunc != nil {
+		return r.nowFunc()
+	}
+	return time.Now()
+}
+
+func isMasterMachineSet(ms *machinev1.MachineSet) bool {
+	if ms.Spec.Template.Labels[machineRoleLabel] == machineMasterRole {
+		return true
+	}
+	_, ok := ms.Spec.Template.Spec.ObjectMeta.Labels[nodeMasterLabel]
+	return ok
+}
+
+func isMasterMachine(m *machinev1.Machine) bool {
+	if m.Labels[machineRoleLabel] == machineMasterRole {
+		return true
+	}
+	_, ok := m.Spec.ObjectMeta.Labels[nodeMasterLabel]
+	return ok
+}
+
+// isRunningMachine returns whether the machine runs a node, as machines only enter the Running phase
+// once their node joined the cluster.
+func isRunningMachine(m *machinev1.Machine) bool {
+	return m.Status.Phase != nil && *m.Status.Phase == machinePhaseRunning && m.Status.NodeRef != nil
+}
@@ -0,0 +1,338 @@
+package machinerotation
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/annotations"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const namespace = "openshift-machine-api"
+
+var start = time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func init() {
+	// Add types to scheme
+	machinev1.AddToScheme(scheme.Scheme)
+}
+
+func newMachineSet(annotations map[string]string, replicas int32) *machinev1.MachineSet {
+	return &machinev1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "workers",
+			Namespace:   namespace,
+			UID:         "workers-uid",
+			Annotations: annotations,
+		},
+		Spec: machinev1.MachineSetSpec{
+			Replicas: pointer.Int32Ptr(replicas),
+		},
+		Status: machinev1.MachineSetStatus{
+			ReadyReplicas: replicas,
+		},
+	}
+}
+
+// newMachine returns a running machine of the MachineSet created age before start.
+// Machines keep a finalizer, so that deleted machines stay around until the test releases them.
+func newMachine(ms *machinev1.MachineSet, name string, age time.Duration) *machinev1.Machine {
+	return &machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         namespace,
+			CreationTimestamp: metav1.NewTime(start.Add(-age)),
+			Finalizers:        []string{machinev1.MachineFinalizer},
+			Labels:            map[string]string{machineRoleLabel: "worker"},
+			OwnerReferences:   []metav1.OwnerReference{*metav1.NewControllerRef(ms, machinev1.SchemeGroupVersion.WithKind("MachineSet"))},
+		},
+		Status: machinev1.MachineStatus{
+			Phase:   pointer.StringPtr(machinePhaseRunning),
+			NodeRef: &corev1.ObjectReference{Name: name},
+		},
+	}
+}
+
+func newReconciler(maxMachineAge time.Duration, now *time.Time, objects ...runtime.Object) *ReconcileMachineRotation {
+	return &ReconcileMachineRotation{
+		client:        fake.NewFakeClientWithScheme(scheme.Scheme, objects...),
+		recorder:      record.NewFakeRecorder(32),
+		maxMachineAge: maxMachineAge,
+		nowFunc:       func() time.Time { return *now },
+	}
+}
+
+func reconcileMachineSet(t *testing.T, r *ReconcileMachineRotation, ms *machinev1.MachineSet) reconcile.Result {
+	t.Helper()
+	result, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ms)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return result
+}
+
+func getMachine(t *testing.T, r *ReconcileMachineRotation, name string) *machinev1.Machine {
+	t.Helper()
+	m := &machinev1.Machine{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, m); err != nil {
+		t.Fatalf("unexpected error getting machine %s: %v", name, err)
+	}
+	return m
+}
+
+func TestReconcileAnnotationOnly(t *testing.T) {
+	now := start
+	ms := newMachineSet(nil, 2)
+	old := newMachine(ms, "old", 10*24*time.Hour)
+	young := newMachine(ms, "young", 2*24*time.Hour)
+	r := newReconciler(7*24*time.Hour, &now, ms, old, young)
+
+	result := reconcileMachineSet(t, r, ms)
+	if expected := 5 * 24 * time.Hour; result.RequeueAfter != expected {
+		t.Errorf("expected a requeue when the next machine is due in %v, got: %v", expected, result.RequeueAfter)
+	}
+
+	if due := getMachine(t, r, "old").Annotations[RotationDueAnnotation]; due != start.Add(-3*24*time.Hour).Format(time.RFC3339) {
+		t.Errorf("expected machine old to be due since 3 days, got: %q", due)
+	}
+	if due, ok := getMachine(t, r, "young").Annotations[RotationDueAnnotation]; ok {
+		t.Errorf("expected machine young not to be due, got: %q", due)
+	}
+	if getMachine(t, r, "old").DeletionTimestamp != nil {
+		t.Errorf("expected no machine to be deleted without auto-rotation")
+	}
+
+	events := r.recorder.(*record.FakeRecorder).Events
+	if len(events) != 1 {
+		t.Errorf("expected a single RotationDue event, got %d events", len(events))
+	}
+
+	// Machines are only marked once.
+	reconcileMachineSet(t, r, ms)
+	if len(events) != 1 {
+		t.Errorf("expected no further events, got %d events", len(events))
+	}
+
+	// After 5 more days, the young machine is due as well.
+	now = start.Add(5 * 24 * time.Hour)
+	reconcileMachineSet(t, r, ms)
+	if _, ok := getMachine(t, r, "young").Annotations[RotationDueAnnotation]; !ok {
+		t.Errorf("expected machine young to be due")
+	}
+}
+
+func TestReconcileMaxAgeAnnotation(t *testing.T) {
+	now := start
+	ms := newMachineSet(map[string]string{MaxAgeAnnotation: "1d"}, 1)
+	m := newMachine(ms, "machine", 36*time.Hour)
+	r := newReconciler(0, &now, ms, m)
+
+	reconcileMachineSet(t, r, ms)
+	if _, ok := getMachine(t, r, "machine").Annotations[RotationDueAnnotation]; !ok {
+		t.Errorf("expected the max-age annotation to mark the machine as due")
+	}
+
+	disabled := newMachineSet(map[string]string{MaxAgeAnnotation: "0"}, 1)
+	m = newMachine(disabled, "machine", 36*time.Hour)
+	r = newReconciler(time.Hour, &now, disabled, m)
+
+	reconcileMachineSet(t, r, disabled)
+	if _, ok := getMachine(t, r, "machine").Annotations[RotationDueAnnotation]; ok {
+		t.Errorf("expected a max-age of 0 to disable rotation")
+	}
+}
+
+func TestReconcileAutoRotation(t *testing.T) {
+	now := start
+	ms := newMachineSet(map[string]string{AutoRotateAnnotation: "true"}, 3)
+	objects := []runtime.Object{ms}
+	for i := 0; i < 3; i++ {
+		objects = append(objects, newMachine(ms, fmt.Sprintf("machine-%d", i), time.Duration(10+i)*24*time.Hour))
+	}
+	r := newReconciler(7*24*time.Hour, &now, objects...)
+
+	deleted := func() []string {
+		var names []string
+		for i := 0; i < 3; i++ {
+			m := &machinev1.Machine{}
+			err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: fmt.Sprintf("machine-%d", i)}, m)
+			if apierrors.IsNotFound(err) || (err == nil && m.DeletionTimestamp != nil) {
+				names = append(names, fmt.Sprintf("machine-%d", i))
+			}
+		}
+		return names
+	}
+
+	// releaseMachine completes the deletion of a machine and replaces it with a new, provisioning one.
+	replacements := 0
+	releaseMachine := func(name string) {
+		m := getMachine(t, r, name)
+		m.Finalizers = nil
+		if err := r.client.Update(context.TODO(), m); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		replacement := newMachine(ms, fmt.Sprintf("replacement-%d", replacements), 0)
+		replacement.CreationTimestamp = metav1.NewTime(now)
+		replacement.Status = machinev1.MachineStatus{Phase: pointer.StringPtr("Provisioning")}
+		if err := r.client.Create(context.TODO(), replacement); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		replacements++
+	}
+	// runMachine moves a replacement to the Running phase once its node joined the cluster.
+	runMachine := func(name string) {
+		m := getMachine(t, r, name)
+		m.Status = machinev1.MachineStatus{Phase: pointer.StringPtr(machinePhaseRunning), NodeRef: &corev1.ObjectReference{Name: name}}
+		if err := r.client.Status().Update(context.TODO(), m); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// The oldest machine is rotated first.
+	reconcileMachineSet(t, r, ms)
+	if got := deleted(); len(got) != 1 || got[0] != "machine-2" {
+		t.Fatalf("expected machine-2 to be rotated, got: %v", got)
+	}
+
+	// No other machine is rotated while the previous one is being deleted.
+	reconcileMachineSet(t, r, ms)
+	if got := deleted(); len(got) != 1 {
+		t.Fatalf("expected a single machine to be rotated at a time, got: %v", got)
+	}
+
+	// Nor while its replacement is provisioning, even though the MachineSet status still counts
+	// the rotated machine as ready.
+	releaseMachine("machine-2")
+	result := reconcileMachineSet(t, r, ms)
+	if got := deleted(); len(got) != 1 {
+		t.Fatalf("expected no rotation while the replacement is provisioning, got: %v", got)
+	}
+	if result.RequeueAfter != rotationRetryInterval {
+		t.Errorf("expected a retry after %v, got: %v", rotationRetryInterval, result.RequeueAfter)
+	}
+
+	// Nor while the MachineSet does not count its replacement as ready yet.
+	runMachine("replacement-0")
+	ms = &machinev1.MachineSet{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: "workers"}, ms); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ms.Status.ReadyReplicas = 2
+	if err := r.client.Status().Update(context.TODO(), ms); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	result = reconcileMachineSet(t, r, ms)
+	if got := deleted(); len(got) != 1 {
+		t.Fatalf("expected no rotation while the MachineSet is not ready, got: %v", got)
+	}
+	if result.RequeueAfter != rotationRetryInterval {
+		t.Errorf("expected a retry after %v, got: %v", rotationRetryInterval, result.RequeueAfter)
+	}
+
+	// Once the replacement is ready, the next oldest machine is rotated.
+	ms.Status.ReadyReplicas = 3
+	if err := r.client.Status().Update(context.TODO(), ms); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	reconcileMachineSet(t, r, ms)
+	if got := deleted(); len(got) != 2 || got[0] != "machine-1" {
+		t.Fatalf("expected machine-1 to be rotated next, got: %v", got)
+	}
+
+	releaseMachine("machine-1")
+	runMachine("replacement-1")
+	reconcileMachineSet(t, r, ms)
+	releaseMachine("machine-0")
+	reconcileMachineSet(t, r, ms)
+	if got := deleted(); len(got) != 3 {
+		t.Fatalf("expected all machines to be rotated eventually, got: %v", got)
+	}
+	for i := 0; i < replacements; i++ {
+		if getMachine(t, r, fmt.Sprintf("replacement-%d", i)).DeletionTimestamp != nil {
+			t.Errorf("expected replacement-%d not to be rotated", i)
+		}
+	}
+}
+
+func TestReconcileMasterExclusion(t *testing.T) {
+	now := start
+
+	masters := newMachineSet(map[string]string{AutoRotateAnnotation: "true"}, 1)
+	masters.Spec.Template.Labels = map[string]string{machineRoleLabel: machineMasterRole}
+	master := newMachine(masters, "master", 30*24*time.Hour)
+	master.Labels[machineRoleLabel] = machineMasterRole
+
+	r := newReconciler(7*24*time.Hour, &now, masters, master)
+	reconcileMachineSet(t, r, masters)
+	m := getMachine(t, r, "master")
+	if _, ok := m.Annotations[RotationDueAnnotation]; ok || m.DeletionTimestamp != nil {
+		t.Errorf("expected a master MachineSet never to be rotated")
+	}
+
+	// Master machines are excluded even when their MachineSet does not look like a master one.
+	workers := newMachineSet(map[string]string{AutoRotateAnnotation: "true"}, 1)
+	master = newMachine(workers, "master", 30*24*time.Hour)
+	master.Spec.ObjectMeta.Labels = map[string]string{nodeMasterLabel: ""}
+
+	r = newReconciler(7*24*time.Hour, &now, workers, master)
+	reconcileMachineSet(t, r, workers)
+	m = getMachine(t, r, "master")
+	if _, ok := m.Annotations[RotationDueAnnotation]; ok || m.DeletionTimestamp != nil {
+		t.Errorf("expected a master machine never to be rotated")
+	}
+}
+
+func TestReconcilePausedExclusion(t *testing.T) {
+	now := start
+
+	ms := newMachineSet(map[string]string{AutoRotateAnnotation: "true"}, 1)
+	paused := newMachine(ms, "paused", 30*24*time.Hour)
+	paused.Annotations = map[string]string{annotations.MachinePausedAnnotation: ""}
+
+	r := newReconciler(7*24*time.Hour, &now, ms, paused)
+	reconcileMachineSet(t, r, ms)
+	m := getMachine(t, r, "paused")
+	if _, ok := m.Annotations[RotationDueAnnotation]; ok || m.DeletionTimestamp != nil {
+		t.Errorf("expected a paused machine not to be rotated")
+	}
+}
+
+func TestParseMaxAge(t *testing.T) {
+	testCases := []struct {
+		value       string
+		expected    time.Duration
+		expectError bool
+	}{
+		{value: "720h", expected: 720 * time.Hour},
+		{value: "30d", expected: 30 * 24 * time.Hour},
+		{value: "0", expected: 0},
+		{value: "-1h", expectError: true},
+		{value: "xd", expectError: true},
+		{value: "month", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			age, err := ParseMaxAge(tc.value)
+			if (err != nil) != tc.expectError {
+				t.Fatalf("expected error: %v, got: %v", tc.expectError, err)
+			}
+			if age != tc.expected {
+				t.Errorf("expected %v, got: %v", tc.expected, age)
+			}
+		})
+	}
+}
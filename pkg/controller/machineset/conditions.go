@@ -0,0 +1,173 @@
+package machineset
+
+import (
+	"fmt"
+	"strings"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/conditions"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/pointer"
+)
+
+const (
+	// ReplicasReadyCondition is true when all the replicas of the MachineSet are ready
+	ReplicasReadyCondition machinev1.ConditionType = "ReplicasReady"
+
+	// MachinesCreatedCondition is true when the instances of all the machines of the MachineSet are created
+	MachinesCreatedCondition machinev1.ConditionType = "MachinesCreated"
+
+	// ResourceLimitExceededCondition is true when the provider lacks the resources, eg. quota,
+	// to create the instances of machines of the MachineSet
+	ResourceLimitExceededCondition machinev1.ConditionType = "ResourceLimitExceeded"
+
+	// MachinesNotReadyReason is the reason of the ReplicasReady condition when replicas are not ready
+	MachinesNotReadyReason = "MachinesNotReady"
+
+	// MachinesProvisioningReason is the reason of the MachinesCreated condition when instances are being created
+	MachinesProvisioningReason = "MachinesProvisioning"
+
+	// MachineCreationFailedReason is the reason of the MachinesCreated condition when instances failed to be created
+	MachineCreationFailedReason = "MachineCreationFailed"
+
+	// maxReportedMachines bounds the number of machines listed in condition messages
+	maxReportedMachines = 5
+
+	machinePhaseProvisioning = "Provisioning"
+	machinePhaseFailed       = "Failed"
+)
+
+// machineSetConditions gives access to the conditions of a MachineSet, which are not part of its API type.
+type machineSetConditions struct {
+	*machinev1.MachineSet
+	conditions machinev1.Conditions
+}
+
+func (m *machineSetConditions) GetConditions() machinev1.Conditions {
+	return m.conditions
+}
+
+func (m *machineSetConditions) SetConditions(conditions machinev1.Conditions) {
+	m.conditions = conditions
+}
+
+// machineCreationError returns the reason and message of the error preventing the creation of the instance
+// of the machine, if any.
+func machineCreationError(m *machinev1.Machine) (string, string, bool) {
+	if pointer.StringPtrDerefOr(m.Status.Phase, "") == machinePhaseFailed && m.Spec.ProviderID == nil {
+		var reason string
+		if m.Status.ErrorReason != nil {
+			reason = string(*m.Status.ErrorReason)
+		}
+		return reason, pointer.StringPtrDerefOr(m.Status.ErrorMessage, ""), true
+	}
+
+	lastOperation := m.Status.LastOperation
+	if lastOperation == nil || pointer.StringPtrDerefOr(lastOperation.Type, "") != "Create" || pointer.StringPtrDerefOr(lastOperation.State, "") != "Failed" {
+		return "", "", false
+	}
+	// The machine controller describes failed operations as "<type> failed: <reason>: <error>"
+	description := pointer.StringPtrDerefOr(lastOperation.Description, "")
+	if parts := strings.SplitN(strings.TrimPrefix(description, "Create failed: "), ": ", 2); len(parts) == 2 {
+		return parts[0], parts[1], true
+	}
+	return "", description, true
+}
+
+// listMachines joins the descriptions of up to maxReportedMachines machines.
+func listMachines(machines []string) string {
+	if len(machines) > maxReportedMachines {
+		return fmt.Sprintf("%s, and %d more", strings.Join(machines[:maxReportedMachines], ", "), len(machines)-maxReportedMachines)
+	}
+	return strings.Join(machines, ", ")
+}
+
+// calculateConditions returns the conditions of the MachineSet summarizing the health of its machines,
+// updated from its previous conditions.
+func (c *ReconcileMachineSet) calculateConditions(ms *machinev1.MachineSet, filteredMachines []*machinev1.Machine, previous machinev1.Conditions) machinev1.Conditions {
+	var replicas int
+	if ms.Spec.Replicas != nil {
+		replicas = int(*ms.Spec.Replicas)
+	}
+
+	var notReady, notCreated, creationFailed, resourceLimited []string
+	for _, machine := range filteredMachines {
+		phase := pointer.StringPtrDerefOr(machine.Status.Phase, "")
+		reason, message, failed := machineCreationError(machine)
+		switch {
+		case failed:
+			creationFailed = append(creationFailed, fmt.Sprintf("%s: %s", machine.Name, message))
+			if reason == string(machinev1.InsufficientResourcesMachineError) {
+				resourceLimited = append(resourceLimited, fmt.Sprintf("%s: %s", machine.Name, message))
+			}
+		case phase == "" || phase == machinePhaseProvisioning:
+			notCreated = append(notCreated, machine.Name)
+		}
+
+		if node, err := c.getMachineNode(machine); err == nil && IsNodeReady(node) {
+			continue
+		}
+		if phase == "" {
+			phase = "Pending"
+		}
+		switch {
+		case phase == machinePhaseFailed:
+			notReady = append(notReady, fmt.Sprintf("%s (%s: %s)", machine.Name, phase, pointer.StringPtrDerefOr(machine.Status.ErrorMessage, "")))
+		case failed:
+			notReady = append(notReady, fmt.Sprintf("%s (%s: %s)", machine.Name, phase, message))
+		default:
+			notReady = append(notReady, fmt.Sprintf("%s (%s)", machine.Name, phase))
+		}
+	}
+	ready := len(filteredMachines) - len(notReady)
+
+	obj := &machineSetConditions{MachineSet: ms, conditions: previous.DeepCopy()}
+	if ready >= replicas && len(filteredMachines) == replicas {
+		conditions.MarkTrue(obj, ReplicasReadyCondition)
+	} else {
+		message := fmt.Sprintf("%d of %d replicas ready", ready, replicas)
+		if len(notReady) > 0 {
+			message = fmt.Sprintf("%s, machines not ready: %s", message, listMachines(notReady))
+		}
+		conditions.MarkFalse(obj, ReplicasReadyCondition, MachinesNotReadyReason, machinev1.ConditionSeverityWarning, "%s", message)
+	}
+
+	switch {
+	case len(creationFailed) > 0:
+		conditions.MarkFalse(obj, MachinesCreatedCondition, MachineCreationFailedReason, machinev1.ConditionSeverityError,
+			"Failed to create instances of machines: %s", listMachines(creationFailed))
+	case len(notCreated) > 0:
+		conditions.MarkFalse(obj, MachinesCreatedCondition, MachinesProvisioningReason, machinev1.ConditionSeverityInfo,
+			"Waiting for instances of machines to be created: %s", listMachines(notCreated))
+	default:
+		conditions.MarkTrue(obj, MachinesCreatedCondition)
+	}
+
+	if len(resourceLimited) > 0 {
+		conditions.Set(obj, &machinev1.Condition{
+			Type:     ResourceLimitExceededCondition,
+			Status:   corev1.ConditionTrue,
+			Severity: machinev1.ConditionSeverityError,
+			Reason:   string(machinev1.InsufficientResourcesMachineError),
+			Message:  fmt.Sprintf("Insufficient resources to create instances of machines: %s", listMachines(resourceLimited)),
+		})
+	} else {
+		conditions.Set(obj, &machinev1.Condition{
+			Type:   ResourceLimitExceededCondition,
+			Status: corev1.ConditionFalse,
+		})
+	}
+
+	return obj.GetConditions()
+}
+
+// machineSetObject returns an unstructured MachineSet, to read and write its status which is not part
+// of its API type.
+func machineSetObject(ms *machinev1.MachineSet) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(machinev1.SchemeGroupVersion.WithKind("MachineSet"))
+	u.SetNamespace(ms.Namespace)
+	u.SetName(ms.Name)
+	return u
+}
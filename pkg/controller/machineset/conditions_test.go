@@ -0,0 +1,197 @@
+package machineset
+
+import (
+	"testing"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/conditions"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestMachineCreationError(t *testing.T) {
+	testCases := []struct {
+		name            string
+		machine         machinev1.Machine
+		expectedFailed  bool
+		expectedReason  string
+		expectedMessage string
+	}{
+		{
+			name: "provisioning",
+			machine: machinev1.Machine{Status: machinev1.MachineStatus{
+				Phase: pointer.StringPtr(machinePhaseProvisioning),
+			}},
+		},
+		{
+			name: "failing to create the instance",
+			machine: machinev1.Machine{Status: machinev1.MachineStatus{
+				Phase: pointer.StringPtr(machinePhaseProvisioning),
+				LastOperation: &machinev1.LastOperation{
+					Type:        pointer.StringPtr("Create"),
+					State:       pointer.StringPtr("Failed"),
+					Description: pointer.StringPtr("Create failed: InsufficientResources: quota exceeded"),
+				},
+			}},
+			expectedFailed:  true,
+			expectedReason:  string(machinev1.InsufficientResourcesMachineError),
+			expectedMessage: "quota exceeded",
+		},
+		{
+			name: "failed without an instance",
+			machine: machinev1.Machine{Status: machinev1.MachineStatus{
+				Phase:        pointer.StringPtr(machinePhaseFailed),
+				ErrorReason:  (*machinev1.MachineStatusError)(pointer.StringPtr(string(machinev1.InvalidConfigurationMachineError))),
+				ErrorMessage: pointer.StringPtr("invalid instance type"),
+			}},
+			expectedFailed:  true,
+			expectedReason:  string(machinev1.InvalidConfigurationMachineError),
+			expectedMessage: "invalid instance type",
+		},
+		{
+			name: "failed with an instance",
+			machine: machinev1.Machine{
+				Spec: machinev1.MachineSpec{ProviderID: pointer.StringPtr("provider://instance")},
+				Status: machinev1.MachineStatus{
+					Phase: pointer.StringPtr(machinePhaseFailed),
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reason, message, failed := machineCreationError(&tc.machine)
+			if failed != tc.expectedFailed || reason != tc.expectedReason || message != tc.expectedMessage {
+				t.Errorf("expected (%q, %q, %v), got: (%q, %q, %v)", tc.expectedReason, tc.expectedMessage, tc.expectedFailed, reason, message, failed)
+			}
+		})
+	}
+}
+
+func TestCalculateConditions(t *testing.T) {
+	readyNode := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "ready"},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+		},
+	}
+	runningMachine := func(name string) *machinev1.Machine {
+		return &machinev1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       machinev1.MachineSpec{ProviderID: pointer.StringPtr("provider://" + name)},
+			Status: machinev1.MachineStatus{
+				Phase:   pointer.StringPtr("Running"),
+				NodeRef: &corev1.ObjectReference{Name: readyNode.Name},
+			},
+		}
+	}
+	provisioningMachine := &machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "provisioning"},
+		Status: machinev1.MachineStatus{
+			Phase: pointer.StringPtr(machinePhaseProvisioning),
+		},
+	}
+	quotaExceededMachine := &machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "quota-exceeded"},
+		Status: machinev1.MachineStatus{
+			Phase: pointer.StringPtr(machinePhaseProvisioning),
+			LastOperation: &machinev1.LastOperation{
+				Type:        pointer.StringPtr("Create"),
+				State:       pointer.StringPtr("Failed"),
+				Description: pointer.StringPtr("Create failed: InsufficientResources: quota exceeded"),
+			},
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		replicas int32
+		machines []*machinev1.Machine
+		expected map[machinev1.ConditionType]*machinev1.Condition
+	}{
+		{
+			name:     "all replicas ready",
+			replicas: 2,
+			machines: []*machinev1.Machine{runningMachine("a"), runningMachine("b")},
+			expected: map[machinev1.ConditionType]*machinev1.Condition{
+				ReplicasReadyCondition:         conditions.TrueCondition(ReplicasReadyCondition),
+				MachinesCreatedCondition:       conditions.TrueCondition(MachinesCreatedCondition),
+				ResourceLimitExceededCondition: {Type: ResourceLimitExceededCondition, Status: corev1.ConditionFalse},
+			},
+		},
+		{
+			name:     "replica provisioning",
+			replicas: 2,
+			machines: []*machinev1.Machine{runningMachine("a"), provisioningMachine},
+			expected: map[machinev1.ConditionType]*machinev1.Condition{
+				ReplicasReadyCondition: conditions.FalseCondition(ReplicasReadyCondition, MachinesNotReadyReason, machinev1.ConditionSeverityWarning,
+					"1 of 2 replicas ready, machines not ready: provisioning (Provisioning)"),
+				MachinesCreatedCondition: conditions.FalseCondition(MachinesCreatedCondition, MachinesProvisioningReason, machinev1.ConditionSeverityInfo,
+					"Waiting for instances of machines to be created: provisioning"),
+				ResourceLimitExceededCondition: {Type: ResourceLimitExceededCondition, Status: corev1.ConditionFalse},
+			},
+		},
+		{
+			name:     "replica lacking resources",
+			replicas: 2,
+			machines: []*machinev1.Machine{runningMachine("a"), quotaExceededMachine},
+			expected: map[machinev1.ConditionType]*machinev1.Condition{
+				ReplicasReadyCondition: conditions.FalseCondition(ReplicasReadyCondition, MachinesNotReadyReason, machinev1.ConditionSeverityWarning,
+					"1 of 2 replicas ready, machines not ready: quota-exceeded (Provisioning: quota exceeded)"),
+				MachinesCreatedCondition: conditions.FalseCondition(MachinesCreatedCondition, MachineCreationFailedReason, machinev1.ConditionSeverityError,
+					"Failed to create instances of machines: quota-exceeded: quota exceeded"),
+				ResourceLimitExceededCondition: {
+					Type:     ResourceLimitExceededCondition,
+					Status:   corev1.ConditionTrue,
+					Severity: machinev1.ConditionSeverityError,
+					Reason:   string(machinev1.InsufficientResourcesMachineError),
+					Message:  "Insufficient resources to create instances of machines: quota-exceeded: quota exceeded",
+				},
+			},
+		},
+		{
+			name:     "missing replicas",
+			replicas: 3,
+			machines: []*machinev1.Machine{runningMachine("a")},
+			expected: map[machinev1.ConditionType]*machinev1.Condition{
+				ReplicasReadyCondition: conditions.FalseCondition(ReplicasReadyCondition, MachinesNotReadyReason, machinev1.ConditionSeverityWarning,
+					"1 of 3 replicas ready"),
+				MachinesCreatedCondition:       conditions.TrueCondition(MachinesCreatedCondition),
+				ResourceLimitExceededCondition: {Type: ResourceLimitExceededCondition, Status: corev1.ConditionFalse},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &ReconcileMachineSet{
+				Client: fake.NewFakeClientWithScheme(scheme.Scheme, readyNode),
+				scheme: scheme.Scheme,
+			}
+			ms := &machinev1.MachineSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "machineset"},
+				Spec:       machinev1.MachineSetSpec{Replicas: pointer.Int32Ptr(tc.replicas)},
+			}
+
+			got := &machineSetConditions{MachineSet: ms, conditions: r.calculateConditions(ms, tc.machines, nil)}
+			if len(got.GetConditions()) != len(tc.expected) {
+				t.Errorf("expected %d conditions, got: %+v", len(tc.expected), got.GetConditions())
+			}
+			for conditionType, expected := range tc.expected {
+				condition := conditions.Get(got, conditionType)
+				if condition == nil {
+					t.Errorf("expected condition %s, got none", conditionType)
+					continue
+				}
+				if condition.Status != expected.Status || condition.Severity != expected.Severity ||
+					condition.Reason != expected.Reason || condition.Message != expected.Message {
+					t.Errorf("expected condition %+v, got: %+v", expected, condition)
+				}
+			}
+		})
+	}
+}
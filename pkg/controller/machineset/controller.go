@@ -27,6 +27,7 @@ import (
 
 	machinev1 "github.com/openshift/api/machine/v1beta1"
 	"github.com/openshift/machine-api-operator/pkg/util"
+	"github.com/openshift/machine-api-operator/pkg/util/annotations"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -108,6 +109,9 @@ type ReconcileMachineSet struct {
 	client.Client
 	scheme   *runtime.Scheme
 	recorder record.EventRecorder
+
+	// extendedStatuses are the statuses of the MachineSets which are not part of their API type.
+	extendedStatuses extendedStatusCache
 }
 
 func (r *ReconcileMachineSet) MachineToMachineSets(o client.Object) []reconcile.Request {
@@ -152,6 +156,7 @@ func (r *ReconcileMachineSet) Reconcile(ctx context.Context, request reconcile.R
 		if apierrors.IsNotFound(err) {
 			// Object not found, return.  Created objects are automatically garbage collected.
 			// For additional cleanup logic use finalizers.
+			r.extendedStatuses.delete(request.NamespacedName)
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
@@ -224,13 +229,38 @@ func (r *ReconcileMachineSet) reconcile(ctx context.Context, machineSet *machine
 		filteredMachines = append(filteredMachines, machineSetMachines[machineName])
 	}
 
+	// The conditions and the number of recreated failed machines are not part of the MachineSet API type.
+	previousStatus, err := r.getMachineSetExtendedStatus(ctx, machineSet)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to get machine set status: %w", err)
+	}
+	filteredMachines, recreations, err := r.recreateFailedMachines(machineSet, filteredMachines, previousStatus.FailedMachineRecreations)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to recreate failed machines: %w", err)
+	}
+	// The recreations are persisted before the failed machines are deleted.
+	previousStatus.FailedMachineRecreations = recreations
+
+	if err := r.syncNodeMetadata(ctx, machineSet, filteredMachines); err != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to sync node labels and taints: %w", err)
+	}
+
 	syncErr := r.syncReplicas(machineSet, filteredMachines)
 
 	ms := machineSet.DeepCopy()
 	newStatus := r.calculateStatus(ms, filteredMachines)
+	extendedStatus := machineSetExtendedStatus{
+		Conditions:               r.calculateConditions(ms, filteredMachines, previousStatus.Conditions),
+		FailedMachineRecreations: recreations,
+	}
+
+	// The budget of recreations is restored once the replicas are all ready again.
+	if machineSet.Spec.Replicas != nil && newStatus.Replicas == *machineSet.Spec.Replicas && newStatus.ReadyReplicas == newStatus.Replicas {
+		extendedStatus.FailedMachineRecreations = 0
+	}
 
 	// Always updates status as machines come up or die.
-	updatedMS, err := updateMachineSetStatus(r.Client, machineSet, newStatus)
+	updatedMS, err := r.updateMachineSetStatus(ctx, machineSet, newStatus, previousStatus, extendedStatus)
 	if err != nil {
 		if syncErr != nil {
 			return reconcile.Result{}, fmt.Errorf("failed to sync machines: %v. failed to update machine set status: %w", syncErr, err)
@@ -270,6 +300,16 @@ func (r *ReconcileMachineSet) syncReplicas(ms *machinev1.MachineSet, machines []
 		return fmt.Errorf("the Replicas field in Spec for machineset %v is nil, this should not be allowed", ms.Name)
 	}
 
+	// Machines being replaced by a rolling update do not count towards the replicas,
+	// so that their replacements are created before they are deleted.
+	var replicas []*machinev1.Machine
+	for _, machine := range machines {
+		if !isBeingReplaced(machine) {
+			replicas = append(replicas, machine)
+		}
+	}
+	machines = replicas
+
 	diff := len(machines) - int(*(ms.Spec.Replicas))
 
 	if diff < 0 {
@@ -307,8 +347,17 @@ func (r *ReconcileMachineSet) syncReplicas(ms *machinev1.MachineSet, machines []
 			return err
 		}
 		klog.Infof("Found %s delete policy", ms.Spec.DeletePolicy)
-		// Choose which Machines to delete.
-		machinesToDelete := getMachinesToDeletePrioritized(machines, diff, deletePriorityFunc)
+		// Choose which Machines to delete, paused machines are never deleted.
+		var candidates []*machinev1.Machine
+		for _, machine := range machines {
+			if annotations.IsMachinePaused(machine) {
+				klog.Infof("%v: not deleting paused machine %s", ms.Name, machine.Name)
+				continue
+			}
+			candidates = append(candidates, machine)
+		}
+		machinesToDelete := getMachinesToDeletePrioritized(candidates, diff, deletePriorityFunc)
+		diff = len(machinesToDelete)
 
 		// TODO: Add cap to limit concurrent delete calls.
 		errCh := make(chan error, diff)
@@ -352,10 +401,20 @@ func (r *ReconcileMachineSet) createMachine(machineSet *machinev1.MachineSet) *m
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			Labels:      machineSet.Spec.Template.ObjectMeta.Labels,
-			Annotations: machineSet.Spec.Template.ObjectMeta.Annotations,
+			Annotations: map[string]string{},
 		},
 		Spec: machineSet.Spec.Template.Spec,
 	}
+	for key, value := range machineSet.Spec.Template.ObjectMeta.Annotations {
+		machine.Annotations[key] = value
+	}
+	copyDrainAnnotations(machineSet, machine)
+	recordAppliedNodeMetadata(machineSet, machine)
+	if hash, err := TemplateHash(machineSet); err == nil {
+		machine.Annotations[TemplateHashAnnotation] = hash
+	} else {
+		klog.Warningf("Unable to hash the machine template of MachineSet %q: %v", machineSet.Name, err)
+	}
 	machine.ObjectMeta.GenerateName = fmt.Sprintf("%s-", machineSet.Name)
 	machine.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(machineSet, controllerKind)}
 	machine.Namespace = machineSet.Namespace
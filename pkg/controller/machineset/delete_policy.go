@@ -29,8 +29,11 @@ type deletePriority float64
 
 const (
 
-	// DeleteNodeAnnotation marks nodes that will be given priority for deletion
+	// DeleteMachineAnnotation marks machines that will be given priority for deletion
 	// when a machineset scales down. This annotation is given top priority on all delete policies.
+	DeleteMachineAnnotation = "machine.openshift.io/delete-machine"
+
+	// DeleteNodeAnnotation is the former name of DeleteMachineAnnotation, which is still honored.
 	DeleteNodeAnnotation = "machine.openshift.io/cluster-api-delete-machine"
 
 	mustDelete    deletePriority = 100.0
@@ -44,12 +47,17 @@ const (
 
 type deletePriorityFunc func(machine *machinev1.Machine) deletePriority
 
+// isMarkedForDeletion returns whether the machine is annotated to be deleted first on scale down.
+func isMarkedForDeletion(machine *machinev1.Machine) bool {
+	return machine.ObjectMeta.Annotations[DeleteMachineAnnotation] != "" || machine.ObjectMeta.Annotations[DeleteNodeAnnotation] != ""
+}
+
 // maps the creation timestamp onto the 0-100 priority range
 func oldestDeletePriority(machine *machinev1.Machine) deletePriority {
 	if machine.DeletionTimestamp != nil && !machine.DeletionTimestamp.IsZero() {
 		return mustDelete
 	}
-	if machine.ObjectMeta.Annotations != nil && machine.ObjectMeta.Annotations[DeleteNodeAnnotation] != "" {
+	if isMarkedForDeletion(machine) {
 		return mustDelete
 	}
 	if machine.Status.ErrorReason != nil || machine.Status.ErrorMessage != nil {
@@ -69,7 +77,7 @@ func newestDeletePriority(machine *machinev1.Machine) deletePriority {
 	if machine.DeletionTimestamp != nil && !machine.DeletionTimestamp.IsZero() {
 		return mustDelete
 	}
-	if machine.ObjectMeta.Annotations != nil && machine.ObjectMeta.Annotations[DeleteNodeAnnotation] != "" {
+	if isMarkedForDeletion(machine) {
 		return mustDelete
 	}
 	if machine.Status.ErrorReason != nil || machine.Status.ErrorMessage != nil {
@@ -82,7 +90,7 @@ func randomDeletePolicy(machine *machinev1.Machine) deletePriority {
 	if machine.DeletionTimestamp != nil && !machine.DeletionTimestamp.IsZero() {
 		return mustDelete
 	}
-	if machine.ObjectMeta.Annotations != nil && machine.ObjectMeta.Annotations[DeleteNodeAnnotation] != "" {
+	if isMarkedForDeletion(machine) {
 		return betterDelete
 	}
 	if machine.Status.ErrorReason != nil || machine.Status.ErrorMessage != nil {
@@ -117,11 +125,23 @@ func getMachinesToDeletePrioritized(filteredMachines []*machinev1.Machine, diff
 		machines: filteredMachines,
 		priority: fun,
 	}
-	sort.Sort(sortable)
+	sort.Stable(sortable)
 
 	return sortable.machines[:diff]
 }
 
+// getDeletePriorityFunc returns the delete priority function of the delete policy of the machineset.
+//
+// When a machineset scales down, whatever its delete policy, the machines to delete first are
+// the machines which are already being deleted, the machines annotated with DeleteMachineAnnotation
+// or DeleteNodeAnnotation, and the machines which failed, i.e. which have an error reason or message.
+// The Random policy, which is the default, ranks the machines being deleted above the annotated and failed ones.
+// The remaining machines are then ordered by the delete policy:
+//   - Random: machines without a node first, then the others
+//   - Newest: the most recently created machines first
+//   - Oldest: the least recently created machines first
+//
+// Machines of equal priority are deleted in the order they are listed.
 func getDeletePriorityFunc(ms *machinev1.MachineSet) (deletePriorityFunc, error) {
 	// Map the Spec.DeletePolicy value to the appropriate delete priority function
 	switch msdp := machinev1.MachineSetDeletePolicy(ms.Spec.DeletePolicy); msdp {
@@ -31,6 +31,7 @@ func TestMachineToDelete(t *testing.T) {
 	mustDeleteMachine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &now}}
 	betterDeleteMachine := &machinev1.Machine{Status: machinev1.MachineStatus{ErrorMessage: &msg}}
 	deleteMeMachine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{DeleteNodeAnnotation: "yes"}}}
+	markedMachine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{DeleteMachineAnnotation: "yes"}}}
 	runningMachine := &machinev1.Machine{Status: machinev1.MachineStatus{NodeRef: &corev1.ObjectReference{}}}
 	notYetRunningMachine := &machinev1.Machine{}
 
@@ -151,6 +152,18 @@ func TestMachineToDelete(t *testing.T) {
 				deleteMeMachine,
 			},
 		},
+		{
+			desc: "func=randomDeletePolicy, annotated with delete-machine, diff=1",
+			diff: 1,
+			machines: []*machinev1.Machine{
+				runningMachine,
+				notYetRunningMachine,
+				markedMachine,
+			},
+			expect: []*machinev1.Machine{
+				markedMachine,
+			},
+		},
 		{
 			desc: "func=randomDeletePolicy, delete non-running hosts first",
 			diff: 3,
@@ -186,6 +199,7 @@ func TestMachineNewestDelete(t *testing.T) {
 	old := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(currentTime.Time.AddDate(0, 0, -10))}}
 	oldest := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(currentTime.Time.AddDate(0, 0, -10))}}
 	annotatedMachine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{DeleteNodeAnnotation: "yes"}, CreationTimestamp: metav1.NewTime(currentTime.Time.AddDate(0, 0, -10))}}
+	markedMachine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{DeleteMachineAnnotation: "yes"}, CreationTimestamp: metav1.NewTime(currentTime.Time.AddDate(0, 0, -5))}}
 	unhealthyMachine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(currentTime.Time.AddDate(0, 0, -10))}, Status: machinev1.MachineStatus{ErrorReason: &statusError}}
 
 	tests := []struct {
@@ -226,6 +240,14 @@ func TestMachineNewestDelete(t *testing.T) {
 			},
 			expect: []*machinev1.Machine{annotatedMachine},
 		},
+		{
+			desc: "func=newestDeletePriority, diff=1 (annotated with delete-machine)",
+			diff: 1,
+			machines: []*machinev1.Machine{
+				new, oldest, old, newest, markedMachine,
+			},
+			expect: []*machinev1.Machine{markedMachine},
+		},
 		{
 			desc: "func=newestDeletePriority, diff=1 (unhealthy)",
 			diff: 1,
@@ -254,6 +276,7 @@ func TestMachineOldestDelete(t *testing.T) {
 	old := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(currentTime.Time.AddDate(0, 0, -10))}}
 	oldest := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(currentTime.Time.AddDate(0, 0, -10))}}
 	annotatedMachine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{DeleteNodeAnnotation: "yes"}, CreationTimestamp: metav1.NewTime(currentTime.Time.AddDate(0, 0, -10))}}
+	markedMachine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{DeleteMachineAnnotation: "yes"}, CreationTimestamp: metav1.NewTime(currentTime.Time.AddDate(0, 0, -5))}}
 	unhealthyMachine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(currentTime.Time.AddDate(0, 0, -10))}, Status: machinev1.MachineStatus{ErrorReason: &statusError}}
 
 	tests := []struct {
@@ -302,6 +325,14 @@ func TestMachineOldestDelete(t *testing.T) {
 			},
 			expect: []*machinev1.Machine{annotatedMachine},
 		},
+		{
+			desc: "func=oldestDeletePriority, diff=1 (annotated with delete-machine)",
+			diff: 1,
+			machines: []*machinev1.Machine{
+				empty, new, oldest, old, newest, markedMachine,
+			},
+			expect: []*machinev1.Machine{markedMachine},
+		},
 		{
 			desc: "func=oldestDeletePriority, diff=1 (unhealthy)",
 			diff: 1,
@@ -0,0 +1,29 @@
+package machineset
+
+import (
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/controller/machine"
+)
+
+// drainAnnotations are the MachineSet annotations setting how the nodes of its machines are drained.
+// They are copied to the machines the MachineSet creates, so that the machine controller reads them
+// from the machine, including once the MachineSet is deleted and its machines are deleted with it.
+var drainAnnotations = []string{
+	machine.MaxConcurrentDrainsAnnotation,
+	machine.DrainTimeoutAnnotation,
+	machine.DrainForceAnnotation,
+}
+
+// copyDrainAnnotations copies the drain annotations of the MachineSet to the machine.
+// The annotations of the machine template take precedence.
+func copyDrainAnnotations(ms *machinev1.MachineSet, m *machinev1.Machine) {
+	for _, key := range drainAnnotations {
+		value, ok := ms.Annotations[key]
+		if !ok {
+			continue
+		}
+		if _, set := m.Annotations[key]; !set {
+			m.Annotations[key] = value
+		}
+	}
+}
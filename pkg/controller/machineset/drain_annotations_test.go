@@ -0,0 +1,51 @@
+package machineset
+
+import (
+	"testing"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/controller/machine"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestCreateMachineCopiesDrainAnnotations(t *testing.T) {
+	ms := &machinev1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "machineset",
+			Namespace: "default",
+			Annotations: map[string]string{
+				machine.MaxConcurrentDrainsAnnotation: "2",
+				machine.DrainTimeoutAnnotation:        "10m",
+				machine.DrainForceAnnotation:          "true",
+				"example.com/other":                   "value",
+			},
+		},
+	}
+
+	r := &ReconcileMachineSet{scheme: scheme.Scheme, recorder: record.NewFakeRecorder(32)}
+	m := r.createMachine(ms)
+	for key, value := range map[string]string{
+		machine.MaxConcurrentDrainsAnnotation: "2",
+		machine.DrainTimeoutAnnotation:        "10m",
+		machine.DrainForceAnnotation:          "true",
+	} {
+		if got := m.Annotations[key]; got != value {
+			t.Errorf("expected the %s annotation of the MachineSet to be copied, got: %q", key, got)
+		}
+	}
+	if _, ok := m.Annotations["example.com/other"]; ok {
+		t.Errorf("expected other MachineSet annotations not to be copied")
+	}
+
+	// The annotations of the template take precedence
+	ms.Spec.Template.Annotations = map[string]string{machine.MaxConcurrentDrainsAnnotation: "1", machine.DrainForceAnnotation: "false"}
+	m = r.createMachine(ms)
+	if value := m.Annotations[machine.MaxConcurrentDrainsAnnotation]; value != "1" {
+		t.Errorf("expected the max concurrent drains of the template, got: %q", value)
+	}
+	if value := m.Annotations[machine.DrainForceAnnotation]; value != "false" {
+		t.Errorf("expected the drain force of the template, got: %q", value)
+	}
+}
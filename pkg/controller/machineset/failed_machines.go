@@ -0,0 +1,123 @@
+package machineset
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/annotations"
+	"github.com/openshift/machine-api-operator/pkg/util/machineapierrors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// RecreateFailedMachinesAnnotation is set on a MachineSet to opt in to the deletion and replacement of its
+	// machines failed with a terminal error, eg. an instance limit exceeded at the time, which may be cleared later.
+	// Its value is the number of machines recreated before the replicas of the MachineSet are all ready again.
+	RecreateFailedMachinesAnnotation = "machine.openshift.io/recreate-failed-machines"
+
+	// RecreatingFailedMachineReason is the reason of the event recorded when a failed machine is deleted to be replaced
+	RecreatingFailedMachineReason = "RecreatingFailedMachine"
+
+	// FailedMachineRecreationsExhaustedReason is the reason of the event recorded when a failed machine is not
+	// replaced, as the MachineSet recreated as many failed machines as its annotation allows
+	FailedMachineRecreationsExhaustedReason = "FailedMachineRecreationsExhausted"
+)
+
+// failedMachineRecreationsBudget returns how many failed machines the MachineSet may recreate,
+// or false unless it opted in.
+func failedMachineRecreationsBudget(ms *machinev1.MachineSet) (int32, bool) {
+	value, ok := ms.Annotations[RecreateFailedMachinesAnnotation]
+	if !ok {
+		return 0, false
+	}
+	budget, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || budget < 1 {
+		klog.Warningf("%v: ignoring invalid %s annotation %q: must be a positive integer", ms.Name, RecreateFailedMachinesAnnotation, value)
+		return 0, false
+	}
+	return int32(budget), true
+}
+
+// hasFailedTerminally returns true when the machine is in the Failed phase because of a terminal error of
+// the cloud provider, which the machine controller does not retry.
+func hasFailedTerminally(m *machinev1.Machine) bool {
+	return pointer.StringPtrDerefOr(m.Status.Phase, "") == machinePhaseFailed &&
+		m.Status.ErrorReason != nil && machineapierrors.IsTerminalReason(*m.Status.ErrorReason)
+}
+
+// recreateFailedMachines deletes the machines failed with a terminal error of a MachineSet which opted in, except the
+// paused ones, so that they are replaced, until the MachineSet spent its budget of recreations. It returns the machines which are not
+// deleted and the number of recreations since the replicas of the MachineSet were last all ready.
+// The recreations are persisted before any machine is deleted, so that a later failure to write the status of the MachineSet
+// cannot restore the budget they spent.
+func (r *ReconcileMachineSet) recreateFailedMachines(ms *machinev1.MachineSet, machines []*machinev1.Machine, recreations int32) ([]*machinev1.Machine, int32, error) {
+	budget, ok := failedMachineRecreationsBudget(ms)
+	if !ok {
+		return machines, recreations, nil
+	}
+
+	var remaining, failed []*machinev1.Machine
+	for _, machine := range machines {
+		if !hasFailedTerminally(machine) || annotations.IsMachinePaused(machine) {
+			remaining = append(remaining, machine)
+			continue
+		}
+		if recreations+int32(len(failed)) >= budget {
+			r.recorder.Eventf(ms, corev1.EventTypeWarning, FailedMachineRecreationsExhaustedReason,
+				"Not recreating failed machine %s: %d of %d recreations spent", machine.Name, recreations+int32(len(failed)), budget)
+			remaining = append(remaining, machine)
+			continue
+		}
+		failed = append(failed, machine)
+	}
+	if len(failed) == 0 {
+		return remaining, recreations, nil
+	}
+
+	// A machine which fails to be deleted spends its recreation all the same, rather than risk recreating more
+	// machines than the budget allows.
+	if err := r.patchFailedMachineRecreations(ms, recreations+int32(len(failed))); err != nil {
+		return machines, recreations, fmt.Errorf("failed to update failed machine recreations: %w", err)
+	}
+
+	var deleted []*machinev1.Machine
+	for i, machine := range failed {
+		recreation := recreations + int32(i) + 1
+		klog.Infof("%v: deleting machine %s failed with %s to replace it, recreation %d of %d",
+			ms.Name, machine.Name, *machine.Status.ErrorReason, recreation, budget)
+		if err := r.Client.Delete(context.Background(), machine); err != nil && !apierrors.IsNotFound(err) {
+			remaining = append(remaining, failed[i:]...)
+			return remaining, recreations + int32(len(failed)), fmt.Errorf("failed to delete failed machine %q: %w", machine.Name, err)
+		}
+		deleted = append(deleted, machine)
+		r.recorder.Eventf(ms, corev1.EventTypeNormal, RecreatingFailedMachineReason,
+			"Deleted machine %s failed with %s: %s, recreation %d of %d",
+			machine.Name, *machine.Status.ErrorReason, pointer.StringPtrDerefOr(machine.Status.ErrorMessage, ""), recreation, budget)
+	}
+
+	// Wait for the deletions to be observed, so that the machines are not recreated twice.
+	return remaining, recreations + int32(len(failed)), r.waitForMachineDeletion(deleted)
+}
+
+// patchFailedMachineRecreations sets the number of failed machines recreated by the MachineSet.
+func (r *ReconcileMachineSet) patchFailedMachineRecreations(ms *machinev1.MachineSet, recreations int32) error {
+	data, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{
+			"failedMachineRecreations": recreations,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	// The cached status is stale whether the patch succeeds or not.
+	r.extendedStatuses.delete(client.ObjectKeyFromObject(ms))
+	return r.Client.Status().Patch(context.Background(), machineSetObject(ms), client.RawPatch(types.MergePatchType, data))
+}
@@ -0,0 +1,247 @@
+package machineset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/machineapierrors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func failedMachine(name string, reason machinev1.MachineStatusError) *machinev1.Machine {
+	return &machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Status: machinev1.MachineStatus{
+			Phase:        pointer.StringPtr(machinePhaseFailed),
+			ErrorReason:  &reason,
+			ErrorMessage: pointer.StringPtr("failed"),
+		},
+	}
+}
+
+func TestFailedMachineRecreationsBudget(t *testing.T) {
+	testCases := []struct {
+		name           string
+		annotations    map[string]string
+		expectedBudget int32
+		expectedOk     bool
+	}{
+		{
+			name: "without annotation",
+		},
+		{
+			name:           "with a budget",
+			annotations:    map[string]string{RecreateFailedMachinesAnnotation: "3"},
+			expectedBudget: 3,
+			expectedOk:     true,
+		},
+		{
+			name:        "with a zero budget",
+			annotations: map[string]string{RecreateFailedMachinesAnnotation: "0"},
+		},
+		{
+			name:        "with an invalid budget",
+			annotations: map[string]string{RecreateFailedMachinesAnnotation: "true"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ms := &machinev1.MachineSet{ObjectMeta: metav1.ObjectMeta{Name: "machineset", Annotations: tc.annotations}}
+			budget, ok := failedMachineRecreationsBudget(ms)
+			if budget != tc.expectedBudget || ok != tc.expectedOk {
+				t.Errorf("expected budget %d, %v, got: %d, %v", tc.expectedBudget, tc.expectedOk, budget, ok)
+			}
+		})
+	}
+}
+
+func TestHasFailedTerminally(t *testing.T) {
+	if !hasFailedTerminally(failedMachine("invalid", machinev1.InvalidConfigurationMachineError)) {
+		t.Errorf("expected a machine failed with an invalid configuration to have failed terminally")
+	}
+	if hasFailedTerminally(failedMachine("insufficient", machinev1.InsufficientResourcesMachineError)) {
+		t.Errorf("expected a machine failed with insufficient resources not to have failed terminally")
+	}
+	if hasFailedTerminally(failedMachine("unauthorized", machineapierrors.UnauthorizedMachineError)) {
+		t.Errorf("expected a machine failed with unauthorized credentials not to have failed terminally")
+	}
+	if hasFailedTerminally(failedMachine("create", machinev1.CreateMachineError)) {
+		t.Errorf("expected a machine failed with a create error not to have failed terminally")
+	}
+	running := &machinev1.Machine{Status: machinev1.MachineStatus{Phase: pointer.StringPtr("Running")}}
+	if hasFailedTerminally(running) {
+		t.Errorf("expected a running machine not to have failed terminally")
+	}
+}
+
+func TestRecreateFailedMachines(t *testing.T) {
+	machinev1.AddToScheme(scheme.Scheme)
+
+	testCases := []struct {
+		name                string
+		annotations         map[string]string
+		recreations         int32
+		failDelete          string
+		expectedRemaining   []string
+		expectedRecreations int32
+		expectedError       bool
+	}{
+		{
+			name:                "without annotation",
+			expectedRemaining:   []string{"running", "invalid", "misconfigured", "unauthorized", "create"},
+			expectedRecreations: 0,
+		},
+		{
+			name:                "with enough budget",
+			annotations:         map[string]string{RecreateFailedMachinesAnnotation: "3"},
+			expectedRemaining:   []string{"running", "unauthorized", "create"},
+			expectedRecreations: 2,
+		},
+		{
+			name:                "with budget left for one recreation",
+			annotations:         map[string]string{RecreateFailedMachinesAnnotation: "3"},
+			recreations:         2,
+			expectedRemaining:   []string{"running", "misconfigured", "unauthorized", "create"},
+			expectedRecreations: 3,
+		},
+		{
+			name:                "with the budget spent",
+			annotations:         map[string]string{RecreateFailedMachinesAnnotation: "3"},
+			recreations:         3,
+			expectedRemaining:   []string{"running", "invalid", "misconfigured", "unauthorized", "create"},
+			expectedRecreations: 3,
+		},
+		{
+			name:                "failing to delete a machine",
+			annotations:         map[string]string{RecreateFailedMachinesAnnotation: "3"},
+			failDelete:          "misconfigured",
+			expectedRemaining:   []string{"running", "unauthorized", "create", "misconfigured"},
+			expectedRecreations: 2,
+			expectedError:       true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			machines := []*machinev1.Machine{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "running", Namespace: "default"},
+					Status:     machinev1.MachineStatus{Phase: pointer.StringPtr("Running")},
+				},
+				failedMachine("invalid", machinev1.InvalidConfigurationMachineError),
+				failedMachine("misconfigured", machinev1.InvalidConfigurationMachineError),
+				failedMachine("unauthorized", machineapierrors.UnauthorizedMachineError),
+				failedMachine("create", machinev1.CreateMachineError),
+			}
+			var objects []runtime.Object
+			for _, m := range machines {
+				objects = append(objects, m.DeepCopy())
+			}
+
+			ms := &machinev1.MachineSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "machineset", Namespace: "default", Annotations: tc.annotations},
+			}
+			objects = append(objects, ms.DeepCopy())
+
+			c := &recordingClient{Client: fake.NewFakeClientWithScheme(scheme.Scheme, objects...), failDelete: tc.failDelete}
+			r := &ReconcileMachineSet{
+				Client:   c,
+				scheme:   scheme.Scheme,
+				recorder: record.NewFakeRecorder(8),
+			}
+
+			remaining, recreations, err := r.recreateFailedMachines(ms, machines, tc.recreations)
+			if (err != nil) != tc.expectedError {
+				t.Fatalf("expected error: %v, got: %v", tc.expectedError, err)
+			}
+			if recreations != tc.expectedRecreations {
+				t.Errorf("expected %d recreations, got: %d", tc.expectedRecreations, recreations)
+			}
+
+			// The recreations are persisted before any machine is deleted.
+			if recreations != tc.recreations {
+				expectedPatch := fmt.Sprintf(`{"status":{"failedMachineRecreations":%d}}`, tc.expectedRecreations)
+				if len(c.calls) == 0 || c.calls[0] != "patch "+expectedPatch {
+					t.Errorf("expected the recreations to be patched first with %s, got: %v", expectedPatch, c.calls)
+				}
+			} else if len(c.calls) > 0 {
+				t.Errorf("expected no calls, got: %v", c.calls)
+			}
+
+			var remainingNames []string
+			for _, m := range remaining {
+				remainingNames = append(remainingNames, m.Name)
+			}
+			if len(remainingNames) != len(tc.expectedRemaining) {
+				t.Fatalf("expected remaining machines %v, got: %v", tc.expectedRemaining, remainingNames)
+			}
+			for i := range remainingNames {
+				if remainingNames[i] != tc.expectedRemaining[i] {
+					t.Errorf("expected remaining machines %v, got: %v", tc.expectedRemaining, remainingNames)
+				}
+			}
+
+			// The machines which are not remaining are deleted.
+			for _, m := range machines {
+				err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(m), &machinev1.Machine{})
+				deleted := apierrors.IsNotFound(err)
+				wantDeleted := true
+				for _, name := range tc.expectedRemaining {
+					if name == m.Name {
+						wantDeleted = false
+					}
+				}
+				if deleted != wantDeleted {
+					t.Errorf("expected machine %s to be deleted: %v, got: %v", m.Name, wantDeleted, err)
+				}
+			}
+		})
+	}
+}
+
+// recordingClient records the status patches and the deletions, and fails the deletion of failDelete.
+type recordingClient struct {
+	client.Client
+	failDelete string
+	calls      []string
+}
+
+func (c *recordingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	c.calls = append(c.calls, "delete "+obj.GetName())
+	if obj.GetName() == c.failDelete {
+		return errors.New("failed to delete")
+	}
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *recordingClient) Status() client.StatusWriter {
+	return &recordingStatusWriter{c}
+}
+
+type recordingStatusWriter struct {
+	c *recordingClient
+}
+
+func (w *recordingStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return w.c.Client.Status().Update(ctx, obj, opts...)
+}
+
+func (w *recordingStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	data, err := patch.Data(obj)
+	if err != nil {
+		return err
+	}
+	w.c.calls = append(w.c.calls, "patch "+string(data))
+	return w.c.Client.Status().Patch(ctx, obj, patch, opts...)
+}
@@ -0,0 +1,83 @@
+package machineset
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/controller/nodelink"
+	"github.com/openshift/machine-api-operator/pkg/util/annotations"
+	"github.com/openshift/machine-api-operator/pkg/util/nodemetadata"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// appliedNodeLabelsAnnotation and appliedNodeTaintsAnnotation record on a machine the node labels and taints
+	// last copied from the template of its MachineSet, so that the ones removed from the template are removed
+	// from the machine, and in turn from its node by the nodelink controller.
+	appliedNodeLabelsAnnotation = "machine.openshift.io/machineset-applied-labels"
+	appliedNodeTaintsAnnotation = "machine.openshift.io/machineset-applied-taints"
+)
+
+// syncNodeMetadata updates the node labels and taints of the machines to the ones of the template of the MachineSet,
+// so that changing the template reaches the nodes of existing machines. Paused machines are left as they are.
+func (r *ReconcileMachineSet) syncNodeMetadata(ctx context.Context, ms *machinev1.MachineSet, machines []*machinev1.Machine) error {
+	for _, machine := range machines {
+		if annotations.IsMachinePaused(machine) {
+			continue
+		}
+		baseToPatch := client.MergeFrom(machine.DeepCopy())
+		if !syncMachineNodeMetadata(ms, machine) {
+			continue
+		}
+		klog.V(3).Infof("%v: updating the node labels and taints of machine %q", ms.Name, machine.Name)
+		if err := r.Client.Patch(ctx, machine, baseToPatch); err != nil {
+			return fmt.Errorf("failed to update the node labels and taints of machine %q: %w", machine.Name, err)
+		}
+	}
+	return nil
+}
+
+// syncMachineNodeMetadata copies the node labels and taints and the node conflict policy of the template of
+// the MachineSet to the machine, and returns whether the machine changed.
+func syncMachineNodeMetadata(ms *machinev1.MachineSet, machine *machinev1.Machine) bool {
+	original := machine.DeepCopy()
+	if machine.Annotations == nil {
+		machine.Annotations = map[string]string{}
+	}
+	template := ms.Spec.Template
+
+	lastLabels, err := nodemetadata.LastAppliedLabels(machine.Annotations, appliedNodeLabelsAnnotation)
+	if err != nil {
+		klog.Warningf("%v: ignoring the node labels last copied to machine %q: %v", ms.Name, machine.Name, err)
+	}
+	machine.Spec.Labels = nodemetadata.SyncLabels(machine.Spec.Labels, template.Spec.Labels, lastLabels, nodemetadata.DesiredWins)
+
+	lastTaints, err := nodemetadata.LastAppliedTaints(machine.Annotations, appliedNodeTaintsAnnotation)
+	if err != nil {
+		klog.Warningf("%v: ignoring the node taints last copied to machine %q: %v", ms.Name, machine.Name, err)
+	}
+	machine.Spec.Taints = nodemetadata.SyncTaints(machine.Spec.Taints, template.Spec.Taints, lastTaints, nodemetadata.DesiredWins)
+
+	recordAppliedNodeMetadata(ms, machine)
+	if policy, ok := template.Annotations[nodelink.NodeConflictPolicyAnnotation]; ok {
+		machine.Annotations[nodelink.NodeConflictPolicyAnnotation] = policy
+	}
+
+	if len(machine.Annotations) == 0 && len(original.Annotations) == 0 {
+		machine.Annotations = original.Annotations
+	}
+	return !reflect.DeepEqual(original, machine)
+}
+
+// recordAppliedNodeMetadata records on the machine the node labels and taints of the template of the MachineSet.
+func recordAppliedNodeMetadata(ms *machinev1.MachineSet, machine *machinev1.Machine) {
+	if err := nodemetadata.SetLastAppliedLabels(machine.Annotations, appliedNodeLabelsAnnotation, ms.Spec.Template.Spec.Labels); err != nil {
+		klog.Warningf("%v: unable to record the node labels copied to machine %q: %v", ms.Name, machine.Name, err)
+	}
+	if err := nodemetadata.SetLastAppliedTaints(machine.Annotations, appliedNodeTaintsAnnotation, ms.Spec.Template.Spec.Taints); err != nil {
+		klog.Warningf("%v: unable to record the node taints copied to machine %q: %v", ms.Name, machine.Name, err)
+	}
+}
@@ -0,0 +1,80 @@
+package machineset
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/controller/nodelink"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSyncNodeMetadata(t *testing.T) {
+	machinev1.AddToScheme(scheme.Scheme)
+
+	ms := &machinev1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "machineset", Namespace: "default", UID: "machineset-uid"},
+		Spec: machinev1.MachineSetSpec{
+			Template: machinev1.MachineTemplateSpec{
+				ObjectMeta: machinev1.ObjectMeta{
+					Annotations: map[string]string{nodelink.NodeConflictPolicyAnnotation: nodelink.NodeConflictPolicyNode},
+				},
+				Spec: machinev1.MachineSpec{
+					ObjectMeta: machinev1.ObjectMeta{
+						Labels: map[string]string{"node-role.kubernetes.io/infra": "", "example.com/zone": "a"},
+					},
+					Taints: []corev1.Taint{{Key: "example.com/dedicated", Value: "infra", Effect: corev1.TaintEffectNoSchedule}},
+				},
+			},
+		},
+	}
+
+	r := &ReconcileMachineSet{scheme: scheme.Scheme, recorder: record.NewFakeRecorder(32)}
+	created := r.createMachine(ms)
+	created.Name = "created"
+
+	// The template changed since the machine was created, the label added to the machine is kept.
+	ms.Spec.Template.Spec.Labels = map[string]string{"node-role.kubernetes.io/infra": "", "example.com/zone": "b"}
+	ms.Spec.Template.Spec.Taints = nil
+	created.Spec.Labels = map[string]string{"node-role.kubernetes.io/infra": "", "example.com/zone": "a", "example.com/own": "label"}
+
+	unchanged := r.createMachine(ms)
+	unchanged.Name = "unchanged"
+
+	r.Client = fake.NewFakeClientWithScheme(scheme.Scheme, created, unchanged)
+	machines := []*machinev1.Machine{created.DeepCopy(), unchanged.DeepCopy()}
+	if err := r.syncNodeMetadata(context.TODO(), ms, machines); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, name := range []string{created.Name, unchanged.Name} {
+		machine := &machinev1.Machine{}
+		if err := r.Client.Get(context.TODO(), client.ObjectKey{Namespace: "default", Name: name}, machine); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expectedLabels := map[string]string{"node-role.kubernetes.io/infra": "", "example.com/zone": "b"}
+		if name == created.Name {
+			expectedLabels["example.com/own"] = "label"
+		}
+		if !reflect.DeepEqual(machine.Spec.Labels, expectedLabels) {
+			t.Errorf("%s: expected labels %v, got: %v", name, expectedLabels, machine.Spec.Labels)
+		}
+		if len(machine.Spec.Taints) != 0 {
+			t.Errorf("%s: expected the taints to be removed, got: %v", name, machine.Spec.Taints)
+		}
+		if policy := machine.Annotations[nodelink.NodeConflictPolicyAnnotation]; policy != nodelink.NodeConflictPolicyNode {
+			t.Errorf("%s: expected node conflict policy %q, got: %q", name, nodelink.NodeConflictPolicyNode, policy)
+		}
+	}
+
+	if syncMachineNodeMetadata(ms, machines[1]) {
+		t.Errorf("expected an up to date machine not to change")
+	}
+}
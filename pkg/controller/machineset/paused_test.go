@@ -0,0 +1,67 @@
+package machineset
+
+import (
+	"context"
+	"testing"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/annotations"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPausedMachinesAreNotDeleted(t *testing.T) {
+	machinev1.AddToScheme(scheme.Scheme)
+
+	pausedAnnotations := map[string]string{
+		annotations.MachinePausedAnnotation: "",
+		DeleteMachineAnnotation:             "true",
+	}
+	ms := &machinev1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "machineset",
+			Namespace:   "default",
+			Annotations: map[string]string{RecreateFailedMachinesAnnotation: "3"},
+		},
+		Spec: machinev1.MachineSetSpec{Replicas: pointer.Int32Ptr(1)},
+	}
+	paused := &machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "paused", Namespace: "default", Annotations: pausedAnnotations},
+	}
+	pausedFailed := failedMachine("paused-failed", machinev1.InvalidConfigurationMachineError)
+	pausedFailed.Annotations = pausedAnnotations
+	running := &machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "running", Namespace: "default"},
+	}
+
+	r := &ReconcileMachineSet{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme, paused.DeepCopy(), pausedFailed.DeepCopy(), running.DeepCopy()),
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(32),
+	}
+
+	remaining, recreations, err := r.recreateFailedMachines(ms, []*machinev1.Machine{paused, pausedFailed, running}, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(remaining) != 3 || recreations != 0 {
+		t.Errorf("expected the paused failed machine not to be recreated, got %d remaining machines and %d recreations", len(remaining), recreations)
+	}
+
+	// Scaling down to a single replica deletes the running machine only, although the paused ones are marked for deletion.
+	if err := r.syncReplicas(ms, remaining); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, m := range []*machinev1.Machine{paused, pausedFailed, running} {
+		err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(m), &machinev1.Machine{})
+		deleted := apierrors.IsNotFound(err)
+		if wantDeleted := m.Name == running.Name; deleted != wantDeleted {
+			t.Errorf("expected machine %s to be deleted: %v, got: %v", m.Name, wantDeleted, err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package machineset
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+)
+
+const (
+	// TemplateHashAnnotation is set on the machines created by a MachineSet to the hash of the template
+	// they were created from, so that machines created from a previous template can be told apart.
+	TemplateHashAnnotation = "machine.openshift.io/template-hash"
+
+	// ReplaceMachineAnnotation marks machines which are being replaced by a rolling update of their MachineSet.
+	// They do not count towards the replicas of the MachineSet, which creates their replacements,
+	// and they are deleted once enough replacements are ready.
+	ReplaceMachineAnnotation = "machine.openshift.io/rolling-update-replace"
+)
+
+// TemplateHash returns the hash of the machine template of the MachineSet.
+func TemplateHash(ms *machinev1.MachineSet) (string, error) {
+	data, err := json.Marshal(ms.Spec.Template)
+	if err != nil {
+		return "", err
+	}
+	hasher := fnv.New32a()
+	hasher.Write(data)
+	return fmt.Sprintf("%08x", hasher.Sum32()), nil
+}
+
+// isBeingReplaced returns whether the machine is being replaced by a rolling update.
+func isBeingReplaced(machine *machinev1.Machine) bool {
+	_, ok := machine.Annotations[ReplaceMachineAnnotation]
+	return ok
+}
@@ -0,0 +1,106 @@
+package machineset
+
+import (
+	"context"
+	"testing"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSyncReplicasReplacesMachines(t *testing.T) {
+	machinev1.AddToScheme(scheme.Scheme)
+
+	ms := &machinev1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "machineset", Namespace: "default", UID: "machineset-uid"},
+		Spec: machinev1.MachineSetSpec{
+			Replicas: pointer.Int32Ptr(2),
+			Template: machinev1.MachineTemplateSpec{
+				ObjectMeta: machinev1.ObjectMeta{
+					Labels:      map[string]string{"foo": "bar"},
+					Annotations: map[string]string{"template": "annotation"},
+				},
+			},
+		},
+	}
+	newMachine := func(name string, annotations map[string]string) *machinev1.Machine {
+		return &machinev1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name,
+				Namespace:       "default",
+				Labels:          map[string]string{"foo": "bar"},
+				Annotations:     annotations,
+				OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(ms, controllerKind)},
+			},
+		}
+	}
+	replaced := newMachine("replaced", map[string]string{ReplaceMachineAnnotation: ""})
+	current := newMachine("current", nil)
+
+	r := &ReconcileMachineSet{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme, ms, replaced, current),
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(32),
+	}
+	if err := r.syncReplicas(ms, []*machinev1.Machine{replaced, current}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	machines := &machinev1.MachineList{}
+	if err := r.Client.List(context.TODO(), machines, client.InNamespace("default")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(machines.Items) != 3 {
+		t.Fatalf("expected a replacement of the replaced machine to be created, got %d machines", len(machines.Items))
+	}
+
+	hash, err := TemplateHash(ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, m := range machines.Items {
+		if m.Name == replaced.Name || m.Name == current.Name {
+			continue
+		}
+		if m.Annotations[TemplateHashAnnotation] != hash {
+			t.Errorf("expected the replacement to be stamped with template hash %q, got: %q", hash, m.Annotations[TemplateHashAnnotation])
+		}
+		if m.Annotations["template"] != "annotation" {
+			t.Errorf("expected the replacement to have the annotations of the template, got: %v", m.Annotations)
+		}
+	}
+	if _, ok := ms.Spec.Template.Annotations[TemplateHashAnnotation]; ok {
+		t.Errorf("expected the template annotations to be left unchanged, got: %v", ms.Spec.Template.Annotations)
+	}
+}
+
+func TestTemplateHash(t *testing.T) {
+	ms := &machinev1.MachineSet{
+		Spec: machinev1.MachineSetSpec{
+			Template: machinev1.MachineTemplateSpec{
+				ObjectMeta: machinev1.ObjectMeta{Labels: map[string]string{"foo": "bar"}},
+			},
+		},
+	}
+	hash, err := TemplateHash(ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	scaled := ms.DeepCopy()
+	scaled.Spec.Replicas = pointer.Int32Ptr(3)
+	if scaledHash, _ := TemplateHash(scaled); scaledHash != hash {
+		t.Errorf("expected scaling not to change the template hash %q, got: %q", hash, scaledHash)
+	}
+
+	edited := ms.DeepCopy()
+	edited.Spec.Template.Labels["foo"] = "baz"
+	if editedHash, _ := TemplateHash(edited); editedHash == hash {
+		t.Errorf("expected editing the template to change its hash %q", hash)
+	}
+}
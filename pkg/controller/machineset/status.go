@@ -18,22 +18,22 @@ package machineset
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"reflect"
+	"sync"
 
 	machinev1 "github.com/openshift/api/machine/v1beta1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-const (
-	// The number of times we retry updating a MachineSet's status.
-	statusUpdateRetries = 1
-)
-
 func (c *ReconcileMachineSet) calculateStatus(ms *machinev1.MachineSet, filteredMachines []*machinev1.Machine) machinev1.MachineSetStatus {
 	newStatus := ms.Status
 	// Count the number of machines that have labels matching the labels of the machine
@@ -69,8 +69,87 @@ func (c *ReconcileMachineSet) calculateStatus(ms *machinev1.MachineSet, filtered
 	return newStatus
 }
 
-// updateMachineSetStatus attempts to update the Status.Replicas of the given MachineSet, with a single GET/PUT retry.
-func updateMachineSetStatus(c client.Client, ms *machinev1.MachineSet, newStatus machinev1.MachineSetStatus) (*machinev1.MachineSet, error) {
+// machineSetExtendedStatus is the status of a MachineSet which is not part of its API type. The API server keeps
+// it, but it is dropped when the MachineSet is decoded, so it is read from an unstructured MachineSet.
+type machineSetExtendedStatus struct {
+	Conditions               machinev1.Conditions `json:"conditions,omitempty"`
+	FailedMachineRecreations int32                `json:"failedMachineRecreations,omitempty"`
+}
+
+// extendedStatusCache remembers the extended status of MachineSets by the resourceVersion it was read or written
+// at, so that it is only read from the API server once the MachineSet changed.
+type extendedStatusCache struct {
+	lock     sync.Mutex
+	statuses map[types.NamespacedName]cachedExtendedStatus
+}
+
+type cachedExtendedStatus struct {
+	resourceVersion string
+	status          machineSetExtendedStatus
+}
+
+func (c *extendedStatusCache) get(ms *machinev1.MachineSet) (machineSetExtendedStatus, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	cached, ok := c.statuses[client.ObjectKeyFromObject(ms)]
+	if !ok || cached.resourceVersion != ms.ResourceVersion {
+		return machineSetExtendedStatus{}, false
+	}
+	return *cached.status.DeepCopy(), true
+}
+
+func (c *extendedStatusCache) set(ms *machinev1.MachineSet, resourceVersion string, status machineSetExtendedStatus) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.statuses == nil {
+		c.statuses = map[types.NamespacedName]cachedExtendedStatus{}
+	}
+	c.statuses[client.ObjectKeyFromObject(ms)] = cachedExtendedStatus{resourceVersion: resourceVersion, status: *status.DeepCopy()}
+}
+
+func (c *extendedStatusCache) delete(key types.NamespacedName) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.statuses, key)
+}
+
+// DeepCopy returns a copy of the extended status.
+func (in *machineSetExtendedStatus) DeepCopy() *machineSetExtendedStatus {
+	return &machineSetExtendedStatus{
+		Conditions:               in.Conditions.DeepCopy(),
+		FailedMachineRecreations: in.FailedMachineRecreations,
+	}
+}
+
+// getMachineSetExtendedStatus returns the extended status of the MachineSet, read from the API server unless
+// the MachineSet did not change since it was last read or written.
+func (c *ReconcileMachineSet) getMachineSetExtendedStatus(ctx context.Context, ms *machinev1.MachineSet) (machineSetExtendedStatus, error) {
+	if status, ok := c.extendedStatuses.get(ms); ok {
+		return status, nil
+	}
+
+	u := machineSetObject(ms)
+	if err := c.Client.Get(ctx, client.ObjectKeyFromObject(u), u); err != nil {
+		return machineSetExtendedStatus{}, err
+	}
+	status := machineSetExtendedStatus{}
+	if raw, found, _ := unstructured.NestedFieldNoCopy(u.Object, "status"); found {
+		data, err := json.Marshal(raw)
+		if err != nil {
+			return machineSetExtendedStatus{}, err
+		}
+		if err := json.Unmarshal(data, &status); err != nil {
+			klog.Warningf("%v: ignoring invalid status: %v", ms.Name, err)
+			status = machineSetExtendedStatus{}
+		}
+	}
+	c.extendedStatuses.set(ms, u.GetResourceVersion(), status)
+	return status, nil
+}
+
+// updateMachineSetStatus writes the status of the MachineSet, with the status which is not part of its API type,
+// in a single merge patch so that neither drops the other. It returns the patched MachineSet.
+func (c *ReconcileMachineSet) updateMachineSetStatus(ctx context.Context, ms *machinev1.MachineSet, newStatus machinev1.MachineSetStatus, previous, extended machineSetExtendedStatus) (*machinev1.MachineSet, error) {
 	// This is the steady state. It happens when the MachineSet doesn't have any expectations, since
 	// we do a periodic relist every 30s. If the generations differ but the replicas are
 	// the same, a caller might've resized to the same replica count.
@@ -78,47 +157,53 @@ func updateMachineSetStatus(c client.Client, ms *machinev1.MachineSet, newStatus
 		ms.Status.FullyLabeledReplicas == newStatus.FullyLabeledReplicas &&
 		ms.Status.ReadyReplicas == newStatus.ReadyReplicas &&
 		ms.Status.AvailableReplicas == newStatus.AvailableReplicas &&
-		ms.Generation == ms.Status.ObservedGeneration {
+		ms.Generation == ms.Status.ObservedGeneration &&
+		reflect.DeepEqual(previous, extended) {
 		return ms, nil
 	}
 
 	// Save the generation number we acted on, otherwise we might wrongfully indicate
 	// that we've seen a spec update when we retry.
-	// TODO: This can clobber an update if we allow multiple agents to write to the
-	// same status.
 	newStatus.ObservedGeneration = ms.Generation
 
-	var getErr, updateErr error
-	for i := 0; ; i++ {
-		var replicas int32
-		if ms.Spec.Replicas != nil {
-			replicas = *ms.Spec.Replicas
-		}
-		klog.V(4).Infof(fmt.Sprintf("Updating status for %v: %s/%s, ", ms.Kind, ms.Namespace, ms.Name) +
-			fmt.Sprintf("replicas %d->%d (need %d), ", ms.Status.Replicas, newStatus.Replicas, replicas) +
-			fmt.Sprintf("fullyLabeledReplicas %d->%d, ", ms.Status.FullyLabeledReplicas, newStatus.FullyLabeledReplicas) +
-			fmt.Sprintf("readyReplicas %d->%d, ", ms.Status.ReadyReplicas, newStatus.ReadyReplicas) +
-			fmt.Sprintf("availableReplicas %d->%d, ", ms.Status.AvailableReplicas, newStatus.AvailableReplicas) +
-			fmt.Sprintf("sequence No: %v->%v", ms.Status.ObservedGeneration, newStatus.ObservedGeneration))
-
-		ms.Status = newStatus
-		updateErr = c.Status().Update(context.Background(), ms)
-		if updateErr == nil {
-			return ms, nil
-		}
-		// Stop retrying if we exceed statusUpdateRetries - the machineSet will be requeued with a rate limit.
-		if i >= statusUpdateRetries {
-			break
-		}
-		// Update the MachineSet with the latest resource version for the next poll
-		if getErr = c.Get(context.Background(), client.ObjectKey{Namespace: ms.Namespace, Name: ms.Name}, ms); getErr != nil {
-			// If the GET fails we can't trust status.Replicas anymore. This error
-			// is bound to be more interesting than the update failure.
-			return nil, getErr
-		}
+	var replicas int32
+	if ms.Spec.Replicas != nil {
+		replicas = *ms.Spec.Replicas
+	}
+	klog.V(4).Infof(fmt.Sprintf("Updating status for %v: %s/%s, ", ms.Kind, ms.Namespace, ms.Name) +
+		fmt.Sprintf("replicas %d->%d (need %d), ", ms.Status.Replicas, newStatus.Replicas, replicas) +
+		fmt.Sprintf("fullyLabeledReplicas %d->%d, ", ms.Status.FullyLabeledReplicas, newStatus.FullyLabeledReplicas) +
+		fmt.Sprintf("readyReplicas %d->%d, ", ms.Status.ReadyReplicas, newStatus.ReadyReplicas) +
+		fmt.Sprintf("availableReplicas %d->%d, ", ms.Status.AvailableReplicas, newStatus.AvailableReplicas) +
+		fmt.Sprintf("sequence No: %v->%v", ms.Status.ObservedGeneration, newStatus.ObservedGeneration))
+
+	// The counts are always written, as a merge patch would not reset an omitted zero count.
+	data, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{
+			"replicas":                 newStatus.Replicas,
+			"fullyLabeledReplicas":     newStatus.FullyLabeledReplicas,
+			"readyReplicas":            newStatus.ReadyReplicas,
+			"availableReplicas":        newStatus.AvailableReplicas,
+			"observedGeneration":       newStatus.ObservedGeneration,
+			"conditions":               extended.Conditions,
+			"failedMachineRecreations": extended.FailedMachineRecreations,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	u := machineSetObject(ms)
+	if err := c.Client.Status().Patch(ctx, u, client.RawPatch(types.MergePatchType, data)); err != nil {
+		// The MachineSet may have changed, read the extended status again.
+		c.extendedStatuses.delete(client.ObjectKeyFromObject(ms))
+		return nil, err
 	}
 
-	return nil, updateErr
+	updatedMS := ms.DeepCopy()
+	updatedMS.Status = newStatus
+	updatedMS.ResourceVersion = u.GetResourceVersion()
+	c.extendedStatuses.set(ms, u.GetResourceVersion(), extended)
+	return updatedMS, nil
 }
 
 func (c *ReconcileMachineSet) getMachineNode(machine *machinev1.Machine) (*corev1.Node, error) {
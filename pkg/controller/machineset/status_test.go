@@ -0,0 +1,109 @@
+package machineset
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/conditions"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestUpdateMachineSetStatus(t *testing.T) {
+	ms := &machinev1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "machineset", Namespace: "default", Generation: 2},
+		Spec:       machinev1.MachineSetSpec{Replicas: pointer.Int32Ptr(1)},
+	}
+	u := machineSetObject(ms)
+	if err := unstructured.SetNestedField(u.Object, int64(1), "spec", "replicas"); err != nil {
+		t.Fatal(err)
+	}
+
+	// MachineSets are stored as unstructured objects, the same way as the API server stores the status
+	// which is not part of their API type
+	c := fake.NewFakeClientWithScheme(runtime.NewScheme(), u)
+	r := &ReconcileMachineSet{Client: c}
+
+	if err := c.Get(context.TODO(), client.ObjectKeyFromObject(u), u); err != nil {
+		t.Fatal(err)
+	}
+	ms.ResourceVersion = u.GetResourceVersion()
+
+	previous, err := r.getMachineSetExtendedStatus(context.TODO(), ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(previous, machineSetExtendedStatus{}) {
+		t.Fatalf("expected no status, got: %+v", previous)
+	}
+
+	extended := machineSetExtendedStatus{
+		Conditions: machinev1.Conditions{
+			*conditions.TrueCondition(ReplicasReadyCondition),
+			*conditions.FalseCondition(MachinesCreatedCondition, MachinesProvisioningReason, machinev1.ConditionSeverityInfo, "provisioning"),
+		},
+		FailedMachineRecreations: 2,
+	}
+	newStatus := machinev1.MachineSetStatus{Replicas: 1, FullyLabeledReplicas: 1, ReadyReplicas: 1, AvailableReplicas: 1}
+	updatedMS, err := r.updateMachineSetStatus(context.TODO(), ms, newStatus, previous, extended)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updatedMS.ResourceVersion == ms.ResourceVersion || updatedMS.Status.ObservedGeneration != 2 {
+		t.Errorf("expected the patched machine set, got: %+v", updatedMS)
+	}
+
+	// The status is written once, and read back by a new controller.
+	got, err := (&ReconcileMachineSet{Client: c}).getMachineSetExtendedStatus(context.TODO(), updatedMS)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.FailedMachineRecreations != 2 || len(got.Conditions) != len(extended.Conditions) {
+		t.Fatalf("expected status %+v, got: %+v", extended, got)
+	}
+	for i := range extended.Conditions {
+		if got.Conditions[i].Type != extended.Conditions[i].Type || got.Conditions[i].Status != extended.Conditions[i].Status ||
+			got.Conditions[i].Reason != extended.Conditions[i].Reason || got.Conditions[i].Message != extended.Conditions[i].Message ||
+			!got.Conditions[i].LastTransitionTime.Equal(&extended.Conditions[i].LastTransitionTime) {
+			t.Errorf("expected condition %+v, got: %+v", extended.Conditions[i], got.Conditions[i])
+		}
+	}
+	if err := c.Get(context.TODO(), client.ObjectKeyFromObject(u), u); err != nil {
+		t.Fatal(err)
+	}
+	if readyReplicas, _, _ := unstructured.NestedInt64(u.Object, "status", "readyReplicas"); readyReplicas != 1 {
+		t.Errorf("expected 1 ready replica, got: %d", readyReplicas)
+	}
+
+	// The controller reads the status it wrote from its cache until the machine set changes.
+	if cached, ok := r.extendedStatuses.get(updatedMS); !ok || cached.FailedMachineRecreations != 2 {
+		t.Errorf("expected the status to be cached, got: %+v, %v", cached, ok)
+	}
+	if _, ok := r.extendedStatuses.get(ms); ok {
+		t.Errorf("expected no status cached for the previous resource version")
+	}
+
+	// Zero counts are written, as a merge patch would not reset omitted counts.
+	extended.FailedMachineRecreations = 0
+	if _, err := r.updateMachineSetStatus(context.TODO(), updatedMS, machinev1.MachineSetStatus{Replicas: 1}, got, extended); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := c.Get(context.TODO(), client.ObjectKeyFromObject(u), u); err != nil {
+		t.Fatal(err)
+	}
+	if readyReplicas, _, _ := unstructured.NestedInt64(u.Object, "status", "readyReplicas"); readyReplicas != 0 {
+		t.Errorf("expected no ready replicas, got: %d", readyReplicas)
+	}
+	if recreations, _, _ := unstructured.NestedInt64(u.Object, "status", "failedMachineRecreations"); recreations != 0 {
+		t.Errorf("expected no recreations, got: %d", recreations)
+	}
+	if _, found, _ := unstructured.NestedSlice(u.Object, "status", "conditions"); !found {
+		t.Errorf("expected the conditions to be kept")
+	}
+}
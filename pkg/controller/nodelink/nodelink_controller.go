@@ -6,6 +6,8 @@ import (
 	"reflect"
 
 	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/machines"
+	"github.com/openshift/machine-api-operator/pkg/util/nodemetadata"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -21,9 +23,21 @@ import (
 const (
 	machineAnnotationKey   = "machine.openshift.io/machine"
 	machineInternalIPIndex = "machineInternalIPIndex"
-	machineProviderIDIndex = "machineProviderIDIndex"
 	nodeInternalIPIndex    = "nodeInternalIPIndex"
 	nodeProviderIDIndex    = "nodeProviderIDIndex"
+
+	// lastAppliedLabelsAnnotation and lastAppliedTaintsAnnotation record on a node the labels and taints
+	// last copied from its machine, so that the ones removed from the machine are removed from the node.
+	lastAppliedLabelsAnnotation = "machine.openshift.io/last-applied-labels"
+	lastAppliedTaintsAnnotation = "machine.openshift.io/last-applied-taints"
+
+	// NodeConflictPolicyAnnotation is set on a machine to tell whether its labels and taints overwrite
+	// the ones of its node which were changed by others, "Machine", or not, "Node".
+	// By default, the labels of the machine overwrite the ones of the node, but the taints of the node are kept.
+	NodeConflictPolicyAnnotation = "machine.openshift.io/node-conflict-policy"
+
+	NodeConflictPolicyMachine = "Machine"
+	NodeConflictPolicyNode    = "Node"
 )
 
 // blank assignment to verify that ReconcileNodeLink implements reconcile.Reconciler
@@ -55,7 +69,7 @@ func indexNodeByProviderID(object client.Object) []string {
 	if node, ok := object.(*corev1.Node); ok {
 		if node.Spec.ProviderID != "" {
 			klog.V(3).Infof("Adding providerID %q for node %q to indexer", node.Spec.ProviderID, node.GetName())
-			return []string{node.Spec.ProviderID}
+			return []string{machines.NormalizeProviderID(node.Spec.ProviderID)}
 		}
 		return nil
 	}
@@ -63,20 +77,6 @@ func indexNodeByProviderID(object client.Object) []string {
 	return nil
 }
 
-func indexMachineByProvider(object client.Object) []string {
-	if machine, ok := object.(*machinev1.Machine); ok {
-		if machine.Spec.ProviderID != nil {
-			if *machine.Spec.ProviderID != "" {
-				klog.V(3).Infof("Adding providerID %q for machine %q to indexer", *machine.Spec.ProviderID, machine.GetName())
-				return []string{*machine.Spec.ProviderID}
-			}
-		}
-		return nil
-	}
-	klog.Warningf("Expected a machine for indexing field, got: %T", object)
-	return nil
-}
-
 func indexNodeByInternalIP(object client.Object) []string {
 	node, ok := object.(*corev1.Node)
 	if !ok {
@@ -124,12 +124,8 @@ func newReconciler(mgr manager.Manager) (*ReconcileNodeLink, error) {
 		return nil, fmt.Errorf("error setting index fields: %v", err)
 	}
 
-	if err := mgr.GetCache().IndexField(context.TODO(),
-		&machinev1.Machine{},
-		machineProviderIDIndex,
-		indexMachineByProvider,
-	); err != nil {
-		return nil, fmt.Errorf("error setting index fields: %v", err)
+	if err := machines.AddIndexes(context.TODO(), mgr.GetCache()); err != nil {
+		return nil, err
 	}
 
 	if err := mgr.GetCache().IndexField(context.TODO(),
@@ -231,16 +227,8 @@ func (r *ReconcileNodeLink) Reconcile(ctx context.Context, request reconcile.Req
 	}
 	modNode.Annotations[machineAnnotationKey] = fmt.Sprintf("%s/%s", machine.GetNamespace(), machine.GetName())
 
-	if modNode.Labels == nil {
-		modNode.Labels = map[string]string{}
-	}
-
-	for k, v := range machine.Spec.Labels {
-		klog.V(4).Infof("Copying label %s = %s", k, v)
-		modNode.Labels[k] = v
-	}
-
-	addTaintsToNode(modNode, machine)
+	syncLabelsToNode(modNode, machine)
+	syncTaintsToNode(modNode, machine)
 
 	if !reflect.DeepEqual(node, modNode) {
 		klog.V(3).Infof("Node %q has changed, updating", modNode.GetName())
@@ -353,7 +341,7 @@ func (r *ReconcileNodeLink) findNodeFromMachineByProviderID(machine *machinev1.M
 		return nil, nil
 	}
 
-	nodes, err := r.listNodesByFieldFunc(nodeProviderIDIndex, *machine.Spec.ProviderID)
+	nodes, err := r.listNodesByFieldFunc(nodeProviderIDIndex, machines.NormalizeProviderID(*machine.Spec.ProviderID))
 	if err != nil {
 		return nil, fmt.Errorf("failed getting node list: %v", err)
 	}
@@ -428,7 +416,7 @@ func (r *ReconcileNodeLink) findMachineFromNodeByProviderID(node *corev1.Node) (
 		return nil, nil
 	}
 
-	machines, err := r.listMachinesByFieldFunc(machineProviderIDIndex, node.Spec.ProviderID)
+	machines, err := r.listMachinesByFieldFunc(machines.ProviderIDIndex, machines.NormalizeProviderID(node.Spec.ProviderID))
 	if err != nil {
 		return nil, fmt.Errorf("failed getting node list: %v", err)
 	}
@@ -478,23 +466,58 @@ func (r *ReconcileNodeLink) findMachineFromNodeByIP(node *corev1.Node) (*machine
 	return nil, nil
 }
 
-// addTaintsToNode adds taints from machine object to the node object
+// conflictPolicy returns the conflict policy of the machine for its node, or the default one when unset.
+func conflictPolicy(machine *machinev1.Machine, defaultPolicy nodemetadata.ConflictPolicy) nodemetadata.ConflictPolicy {
+	switch policy := machine.Annotations[NodeConflictPolicyAnnotation]; policy {
+	case NodeConflictPolicyMachine:
+		return nodemetadata.DesiredWins
+	case NodeConflictPolicyNode:
+		return nodemetadata.CurrentWins
+	case "":
+	default:
+		klog.Warningf("Ignoring invalid %s annotation %q of machine %q", NodeConflictPolicyAnnotation, policy, machine.GetName())
+	}
+	return defaultPolicy
+}
+
+// syncLabelsToNode copies the labels from the machine spec to the node, and removes the ones
+// previously copied which the machine no longer has.
+func syncLabelsToNode(node *corev1.Node, machine *machinev1.Machine) {
+	if node.Annotations == nil {
+		node.Annotations = map[string]string{}
+	}
+	lastApplied, err := nodemetadata.LastAppliedLabels(node.Annotations, lastAppliedLabelsAnnotation)
+	if err != nil {
+		klog.Warningf("Ignoring the labels last copied to node %q: %v", node.GetName(), err)
+	}
+
+	klog.V(4).Infof("Syncing labels %v from machine %q to node %q", machine.Spec.Labels, machine.GetName(), node.GetName())
+	node.Labels = nodemetadata.SyncLabels(node.Labels, machine.Spec.Labels, lastApplied, conflictPolicy(machine, nodemetadata.DesiredWins))
+
+	if err := nodemetadata.SetLastAppliedLabels(node.Annotations, lastAppliedLabelsAnnotation, machine.Spec.Labels); err != nil {
+		klog.Warningf("Unable to record the labels copied to node %q: %v", node.GetName(), err)
+	}
+}
+
+// syncTaintsToNode copies the taints from the machine spec to the node, and removes the ones
+// previously copied which the machine no longer has.
 // Taints are to be an authoritative list on the machine spec per cluster-api comments.
-// However, we believe many components can directly taint a node and there is no direct source of truth that should enforce a single writer of taints
-func addTaintsToNode(node *corev1.Node, machine *machinev1.Machine) {
-	for _, mTaint := range machine.Spec.Taints {
-		klog.V(4).Infof("Adding taint %v from machine %q to node %q", mTaint, machine.GetName(), node.GetName())
-		alreadyPresent := false
-		for _, nTaint := range node.Spec.Taints {
-			if nTaint.Key == mTaint.Key && nTaint.Effect == mTaint.Effect {
-				klog.V(4).Infof("Skipping to add machine taint, %v, to the node. Node already has a taint with same key and effect", mTaint)
-				alreadyPresent = true
-				break
-			}
-		}
-		if !alreadyPresent {
-			node.Spec.Taints = append(node.Spec.Taints, mTaint)
-		}
+// However, we believe many components can directly taint a node and there is no direct source of truth that should enforce a single writer of taints,
+// so the taints of the node which were not copied from the machine are kept by default.
+func syncTaintsToNode(node *corev1.Node, machine *machinev1.Machine) {
+	if node.Annotations == nil {
+		node.Annotations = map[string]string{}
+	}
+	lastApplied, err := nodemetadata.LastAppliedTaints(node.Annotations, lastAppliedTaintsAnnotation)
+	if err != nil {
+		klog.Warningf("Ignoring the taints last copied to node %q: %v", node.GetName(), err)
+	}
+
+	klog.V(4).Infof("Syncing taints %v from machine %q to node %q", machine.Spec.Taints, machine.GetName(), node.GetName())
+	node.Spec.Taints = nodemetadata.SyncTaints(node.Spec.Taints, machine.Spec.Taints, lastApplied, conflictPolicy(machine, nodemetadata.CurrentWins))
+
+	if err := nodemetadata.SetLastAppliedTaints(node.Annotations, lastAppliedTaintsAnnotation, machine.Spec.Taints); err != nil {
+		klog.Warningf("Unable to record the taints copied to node %q: %v", node.GetName(), err)
 	}
 }
 
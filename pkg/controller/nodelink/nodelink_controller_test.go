@@ -10,6 +10,7 @@ import (
 	"time"
 
 	machinev1 "github.com/openshift/api/machine/v1beta1"
+	machinesutil "github.com/openshift/machine-api-operator/pkg/util/machines"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -146,7 +147,7 @@ func newFakeReconciler(client client.Client, machine *machinev1.Machine, node *c
 func (r *fakeReconciler) buildFakeNodeIndexer(nodes ...corev1.Node) {
 	for i := range nodes {
 		if nodes[i].Spec.ProviderID != "" {
-			r.fakeNodeIndexer[nodes[i].Spec.ProviderID] = nodes[i]
+			r.fakeNodeIndexer[machinesutil.NormalizeProviderID(nodes[i].Spec.ProviderID)] = nodes[i]
 		}
 		for j := range nodes[i].Status.Addresses {
 			r.fakeNodeIndexer[nodes[i].Status.Addresses[j].Address] = nodes[i]
@@ -157,7 +158,7 @@ func (r *fakeReconciler) buildFakeNodeIndexer(nodes ...corev1.Node) {
 func (r *fakeReconciler) buildFakeMachineIndexer(machines ...machinev1.Machine) {
 	for i := range machines {
 		if machines[i].Spec.ProviderID != nil {
-			r.fakeMachineIndexer[*machines[i].Spec.ProviderID] = machines[i]
+			r.fakeMachineIndexer[machinesutil.NormalizeProviderID(*machines[i].Spec.ProviderID)] = machines[i]
 		}
 		for j := range machines[i].Status.Addresses {
 			r.fakeMachineIndexer[machines[i].Status.Addresses[j].Address] = machines[i]
@@ -186,6 +187,11 @@ func TestFindMachineFromNodeByProviderID(t *testing.T) {
 			node:     node("noMatchingProviderID", "differentProviderID", nil, nil),
 			expected: nil,
 		},
+		{
+			machine:  machine("normalizedProviderID", "openstack:///0d1ea0b6-7e2d-4b59-9a7c-3c4e3bd0c1e7", nil, nil, nil),
+			node:     node("normalizedProviderID", "openstack://RegionOne/0d1ea0b6-7e2d-4b59-9a7c-3c4e3bd0c1e7", nil, nil),
+			expected: machine("normalizedProviderID", "openstack:///0d1ea0b6-7e2d-4b59-9a7c-3c4e3bd0c1e7", nil, nil, nil),
+		},
 	}
 	for _, tc := range testCases {
 		r := newFakeReconciler(fake.NewFakeClientWithScheme(scheme.Scheme, tc.machine), tc.machine, tc.node)
@@ -362,7 +368,7 @@ func TestFindNodeFromMachineByIP(t *testing.T) {
 	}
 }
 
-func TestAddTaintsToNode(t *testing.T) {
+func TestSyncTaintsToNode(t *testing.T) {
 	testCases := []struct {
 		description             string
 		nodeTaints              []corev1.Taint
@@ -399,7 +405,7 @@ func TestAddTaintsToNode(t *testing.T) {
 	for _, test := range testCases {
 		machine := machine("", "", nil, test.machineTaints, nil)
 		node := node("", "", nil, test.nodeTaints)
-		addTaintsToNode(node, machine)
+		syncTaintsToNode(node, machine)
 		if !reflect.DeepEqual(node.Spec.Taints, test.expectedFinalNodeTaints) {
 			t.Errorf("Test case: %s. Expected: %v, got: %v", test.description, test.expectedFinalNodeTaints, node.Spec.Taints)
 		}
@@ -568,54 +574,153 @@ func TestReconcile(t *testing.T) {
 	}
 }
 
-func TestIndexNodeByProviderID(t *testing.T) {
+func TestReconcileSyncsLabelsAndTaints(t *testing.T) {
 	testCases := []struct {
-		object   client.Object
-		expected []string
+		name           string
+		policy         string
+		expectedLabels map[string]string
+		expectedTaints []corev1.Taint
 	}{
 		{
-			object:   node("noProviderID", "", nil, nil),
-			expected: nil,
+			name: "default conflict policy",
+			expectedLabels: map[string]string{
+				"example.com/changed":  "machine-v2",
+				"example.com/conflict": "machine",
+				"example.com/node":     "node",
+			},
+			expectedTaints: []corev1.Taint{
+				{Key: "example.com/node", Value: "node", Effect: corev1.TaintEffectNoExecute},
+				{Key: "example.com/changed", Value: "machine-v2", Effect: corev1.TaintEffectNoSchedule},
+				{Key: "example.com/conflict", Value: "node", Effect: corev1.TaintEffectNoSchedule},
+			},
 		},
 		{
-			object:   machine("wrongObject", "test", nil, nil, nil),
-			expected: nil,
+			name:   "machine conflict policy",
+			policy: NodeConflictPolicyMachine,
+			expectedLabels: map[string]string{
+				"example.com/changed":  "machine-v2",
+				"example.com/conflict": "machine",
+				"example.com/node":     "node",
+			},
+			expectedTaints: []corev1.Taint{
+				{Key: "example.com/node", Value: "node", Effect: corev1.TaintEffectNoExecute},
+				{Key: "example.com/changed", Value: "machine-v2", Effect: corev1.TaintEffectNoSchedule},
+				{Key: "example.com/conflict", Value: "machine", Effect: corev1.TaintEffectNoSchedule},
+			},
 		},
 		{
-			object:   node("withProviderID", "test", nil, nil),
-			expected: []string{"test"},
+			name:   "node conflict policy",
+			policy: NodeConflictPolicyNode,
+			expectedLabels: map[string]string{
+				"example.com/changed":  "machine-v2",
+				"example.com/conflict": "node",
+				"example.com/node":     "node",
+			},
+			expectedTaints: []corev1.Taint{
+				{Key: "example.com/node", Value: "node", Effect: corev1.TaintEffectNoExecute},
+				{Key: "example.com/changed", Value: "machine-v2", Effect: corev1.TaintEffectNoSchedule},
+				{Key: "example.com/conflict", Value: "node", Effect: corev1.TaintEffectNoSchedule},
+			},
 		},
 	}
 
 	for _, tc := range testCases {
-		got := indexNodeByProviderID(tc.object)
-		if !reflect.DeepEqual(got, tc.expected) {
-			t.Errorf("Expected: %v, got: %v", tc.expected, got)
-		}
+		t.Run(tc.name, func(t *testing.T) {
+			m := machine("syncMetadata", "syncMetadata", nil, []corev1.Taint{
+				{Key: "example.com/changed", Value: "machine-v1", Effect: corev1.TaintEffectNoSchedule},
+				{Key: "example.com/conflict", Value: "machine", Effect: corev1.TaintEffectNoSchedule},
+				{Key: "example.com/removed", Value: "machine", Effect: corev1.TaintEffectNoSchedule},
+			}, nil)
+			m.Spec.Labels = map[string]string{
+				"example.com/changed":  "machine-v1",
+				"example.com/conflict": "machine",
+				"example.com/removed":  "machine",
+			}
+			if tc.policy != "" {
+				m.Annotations = map[string]string{NodeConflictPolicyAnnotation: tc.policy}
+			}
+			n := node("syncMetadata", "syncMetadata", nil, []corev1.Taint{
+				{Key: "example.com/node", Value: "node", Effect: corev1.TaintEffectNoExecute},
+			})
+			n.Labels = map[string]string{"example.com/node": "node"}
+
+			r := newFakeReconciler(fake.NewFakeClientWithScheme(scheme.Scheme, n, m), m, n)
+			request := reconcile.Request{NamespacedName: client.ObjectKey{Name: n.Name}}
+			if _, err := r.Reconcile(ctx, request); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// Day-2 changes of the machine, while others change the node
+			freshMachine := &machinev1.Machine{}
+			if err := r.client.Get(ctx, client.ObjectKeyFromObject(m), freshMachine); err != nil {
+				t.Fatalf("unexpected error getting machine: %v", err)
+			}
+			freshMachine.Spec.Labels = map[string]string{
+				"example.com/changed":  "machine-v2",
+				"example.com/conflict": "machine",
+			}
+			freshMachine.Spec.Taints = []corev1.Taint{
+				{Key: "example.com/changed", Value: "machine-v2", Effect: corev1.TaintEffectNoSchedule},
+				{Key: "example.com/conflict", Value: "machine", Effect: corev1.TaintEffectNoSchedule},
+			}
+			if err := r.client.Update(ctx, freshMachine); err != nil {
+				t.Fatalf("unexpected error updating machine: %v", err)
+			}
+			r.buildFakeMachineIndexer(*freshMachine)
+
+			freshNode := &corev1.Node{}
+			if err := r.client.Get(ctx, client.ObjectKey{Name: n.GetName()}, freshNode); err != nil {
+				t.Fatalf("unexpected error getting node: %v", err)
+			}
+			freshNode.Labels["example.com/conflict"] = "node"
+			for i := range freshNode.Spec.Taints {
+				if freshNode.Spec.Taints[i].Key == "example.com/conflict" {
+					freshNode.Spec.Taints[i].Value = "node"
+				}
+			}
+			if err := r.client.Update(ctx, freshNode); err != nil {
+				t.Fatalf("unexpected error updating node: %v", err)
+			}
+
+			if _, err := r.Reconcile(ctx, request); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			syncedNode := &corev1.Node{}
+			if err := r.client.Get(ctx, client.ObjectKey{Name: n.GetName()}, syncedNode); err != nil {
+				t.Fatalf("unexpected error getting node: %v", err)
+			}
+			if !reflect.DeepEqual(syncedNode.Labels, tc.expectedLabels) {
+				t.Errorf("expected labels: %v, got: %v", tc.expectedLabels, syncedNode.Labels)
+			}
+			if !reflect.DeepEqual(syncedNode.Spec.Taints, tc.expectedTaints) {
+				t.Errorf("expected taints: %v, got: %v", tc.expectedTaints, syncedNode.Spec.Taints)
+			}
+		})
 	}
 }
 
-func TestIndexMachineByProvider(t *testing.T) {
+func TestIndexNodeByProviderID(t *testing.T) {
 	testCases := []struct {
 		object   client.Object
 		expected []string
 	}{
 		{
-			object:   machine("noProviderID", "", nil, nil, nil),
+			object:   node("noProviderID", "", nil, nil),
 			expected: nil,
 		},
 		{
-			object:   node("wrongObject", "test", nil, nil),
+			object:   machine("wrongObject", "test", nil, nil, nil),
 			expected: nil,
 		},
 		{
-			object:   machine("withProviderID", "test", nil, nil, nil),
+			object:   node("withProviderID", "test", nil, nil),
 			expected: []string{"test"},
 		},
 	}
 
 	for _, tc := range testCases {
-		got := indexMachineByProvider(tc.object)
+		got := indexNodeByProviderID(tc.object)
 		if !reflect.DeepEqual(got, tc.expected) {
 			t.Errorf("Expected: %v, got: %v", tc.expected, got)
 		}
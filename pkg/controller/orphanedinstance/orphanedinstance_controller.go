@@ -0,0 +1,211 @@
+package orphanedinstance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/metrics"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimachineryutilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// DefaultInterval is how often the instances of the cluster are compared against its machines.
+	DefaultInterval = 10 * time.Minute
+
+	// DefaultGracePeriod is how long an instance stays orphaned before it is deleted.
+	DefaultGracePeriod = time.Hour
+
+	// OrphanedInstanceReason is the reason of the event recorded when an instance is found without a machine
+	OrphanedInstanceReason = "OrphanedInstance"
+
+	// OrphanedInstanceDeletedReason is the reason of the event recorded when an orphaned instance is deleted
+	OrphanedInstanceDeletedReason = "OrphanedInstanceDeleted"
+
+	infrastructureName = "cluster"
+
+	controllerName = "orphanedinstance-controller"
+)
+
+// Instance is a cloud instance tagged with the cluster ID.
+type Instance struct {
+	// ProviderID is the provider ID of the instance, in the format of the provider ID of machines.
+	ProviderID string
+	// Name is the name of the instance, which is the name of its machine when it was created by the machine controller.
+	Name string
+	// Location identifies where the provider finds the instance, eg. its vCenter. It is opaque to the controller.
+	Location string
+}
+
+// InstanceProvider lists and deletes the instances of a cloud provider.
+type InstanceProvider interface {
+	// ListInstances returns the instances tagged with the cluster ID. The machines tell the provider
+	// where to look for instances, and with which credentials.
+	ListInstances(ctx context.Context, clusterID string, machines []machinev1.Machine) ([]Instance, error)
+	// DeleteInstance deletes an instance returned by the last call to ListInstances.
+	DeleteInstance(ctx context.Context, instance Instance) error
+}
+
+// Options are the options of the orphaned instance controller.
+type Options struct {
+	// Provider is the name of the cloud provider, which labels the metrics.
+	Provider string
+	// Interval is how often the instances of the cluster are compared against its machines.
+	// Zero defaults to DefaultInterval.
+	Interval time.Duration
+	// GracePeriod is how long an instance stays orphaned before it is deleted, so that instances whose
+	// machine is being created or deleted are never deleted. Zero defaults to DefaultGracePeriod.
+	GracePeriod time.Duration
+	// Delete enables the deletion of orphaned instances, which are only reported otherwise.
+	Delete bool
+}
+
+// blank assignment to verify that ReconcileOrphanedInstances implements reconcile.Reconciler
+var _ reconcile.Reconciler = &ReconcileOrphanedInstances{}
+
+// ReconcileOrphanedInstances periodically lists the instances tagged with the cluster ID and reports the ones
+// without a machine, eg. leaked by a machine deleted while its instance was being created. It deletes them
+// once they stayed orphaned for the grace period, when enabled.
+type ReconcileOrphanedInstances struct {
+	client   client.Client
+	recorder record.EventRecorder
+	provider InstanceProvider
+	opts     Options
+
+	// orphanedSince records when the orphaned instances were first found, by provider ID.
+	orphanedSince map[string]time.Time
+
+	// nowFunc is used to mock time in testing. It should be nil in production.
+	nowFunc func() time.Time
+}
+
+// Add creates a new orphaned instance controller for the instances of the provider and adds it to the Manager.
+func Add(mgr manager.Manager, provider InstanceProvider, opts Options) error {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.GracePeriod <= 0 {
+		opts.GracePeriod = DefaultGracePeriod
+	}
+	r := &ReconcileOrphanedInstances{
+		client:        mgr.GetClient(),
+		recorder:      mgr.GetEventRecorderFor(controllerName),
+		provider:      provider,
+		opts:          opts,
+		orphanedSince: map[string]time.Time{},
+	}
+
+	c, err := controller.New(controllerName, mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	// The Infrastructure carries the cluster ID, it is then reconciled every interval.
+	return c.Watch(
+		&source.Kind{Type: &osconfigv1.Infrastructure{}},
+		&handler.EnqueueRequestForObject{},
+		predicate.NewPredicateFuncs(func(o client.Object) bool { return o.GetName() == infrastructureName }),
+	)
+}
+
+// Reconcile compares the instances tagged with the cluster ID against the machines, and reports or deletes
+// the orphaned ones.
+func (r *ReconcileOrphanedInstances) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	infra := &osconfigv1.Infrastructure{}
+	if err := r.client.Get(ctx, request.NamespacedName, infra); err != nil {
+		if apierrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	clusterID := infra.Status.InfrastructureName
+	if clusterID == "" {
+		klog.Warningf("%v: no cluster ID, not looking for orphaned instances", infra.GetName())
+		return reconcile.Result{RequeueAfter: r.opts.Interval}, nil
+	}
+
+	machines := &machinev1.MachineList{}
+	if err := r.client.List(ctx, machines); err != nil {
+		return reconcile.Result{}, err
+	}
+	instances, err := r.provider.ListInstances(ctx, clusterID, machines.Items)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to list the instances of cluster %s: %w", clusterID, err)
+	}
+
+	orphans := orphanedInstances(instances, machines.Items)
+	metrics.OrphanedInstances.WithLabelValues(r.opts.Provider).Set(float64(len(orphans)))
+
+	now := r.now()
+	orphanedSince := map[string]time.Time{}
+	var errList []error
+	for _, instance := range orphans {
+		since, ok := r.orphanedSince[instance.ProviderID]
+		if !ok {
+			since = now
+			klog.Infof("Found instance %s (%s) tagged with cluster ID %s without a machine", instance.Name, instance.ProviderID, clusterID)
+			r.recorder.Eventf(infra, corev1.EventTypeWarning, OrphanedInstanceReason,
+				"Instance %s (%s) tagged with cluster ID %s has no machine", instance.Name, instance.ProviderID, clusterID)
+		}
+		orphanedSince[instance.ProviderID] = since
+
+		if !r.opts.Delete || now.Sub(since) < r.opts.GracePeriod {
+			continue
+		}
+		klog.Infof("Deleting instance %s (%s), orphaned since %s", instance.Name, instance.ProviderID, since.Format(time.RFC3339))
+		if err := r.provider.DeleteInstance(ctx, instance); err != nil {
+			errList = append(errList, fmt.Errorf("failed to delete orphaned instance %s: %w", instance.Name, err))
+			continue
+		}
+		delete(orphanedSince, instance.ProviderID)
+		metrics.OrphanedInstancesDeletedTotal.WithLabelValues(r.opts.Provider).Inc()
+		r.recorder.Eventf(infra, corev1.EventTypeNormal, OrphanedInstanceDeletedReason,
+			"Deleted instance %s (%s), orphaned since %s", instance.Name, instance.ProviderID, since.Format(time.RFC3339))
+	}
+	r.orphanedSince = orphanedSince
+
+	return reconcile.Result{RequeueAfter: r.opts.Interval}, apimachineryutilerrors.NewAggregate(errList)
+}
+
+// orphanedInstances returns the instances which match no machine, neither by provider ID nor by name.
+func orphanedInstances(instances []Instance, machines []machinev1.Machine) []Instance {
+	providerIDs := sets.NewString()
+	names := sets.NewString()
+	for _, m := range machines {
+		if providerID := pointer.StringPtrDerefOr(m.Spec.ProviderID, ""); providerID != "" {
+			providerIDs.Insert(providerID)
+		}
+		names.Insert(m.GetName())
+	}
+
+	var orphans []Instance
+	for _, instance := range instances {
+		if providerIDs.Has(instance.ProviderID) || names.Has(instance.Name) {
+			continue
+		}
+		orphans = append(orphans, instance)
+	}
+	return orphans
+}
+
+func (r *ReconcileOrphanedInstances) now() time.Time {
+	if r.nowFunc != nil {
+		return r.nowFunc()
+	}
+	return time.Now()
+}
@@ -0,0 +1,175 @@
+package orphanedinstance
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/metrics"
+	dto "github.com/prometheus/client_model/go"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var start = time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+var infrastructureRequest = reconcile.Request{NamespacedName: types.NamespacedName{Name: infrastructureName}}
+
+func init() {
+	// Add types to scheme
+	machinev1.AddToScheme(scheme.Scheme)
+	osconfigv1.AddToScheme(scheme.Scheme)
+}
+
+// fakeProvider lists its instances and records the instances deleted.
+type fakeProvider struct {
+	instances []Instance
+	deleted   []string
+}
+
+func (p *fakeProvider) ListInstances(_ context.Context, clusterID string, _ []machinev1.Machine) ([]Instance, error) {
+	if clusterID != "cluster-id" {
+		return nil, nil
+	}
+	return p.instances, nil
+}
+
+func (p *fakeProvider) DeleteInstance(_ context.Context, instance Instance) error {
+	p.deleted = append(p.deleted, instance.Name)
+	var remaining []Instance
+	for _, i := range p.instances {
+		if i.ProviderID != instance.ProviderID {
+			remaining = append(remaining, i)
+		}
+	}
+	p.instances = remaining
+	return nil
+}
+
+func newReconciler(provider *fakeProvider, opts Options, now *time.Time, objects ...runtime.Object) *ReconcileOrphanedInstances {
+	infra := &osconfigv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{Name: infrastructureName},
+		Status:     osconfigv1.InfrastructureStatus{InfrastructureName: "cluster-id"},
+	}
+	return &ReconcileOrphanedInstances{
+		client:        fake.NewFakeClientWithScheme(scheme.Scheme, append(objects, infra)...),
+		recorder:      record.NewFakeRecorder(32),
+		provider:      provider,
+		opts:          opts,
+		orphanedSince: map[string]time.Time{},
+		nowFunc:       func() time.Time { return *now },
+	}
+}
+
+func newMachine(name, providerID string) *machinev1.Machine {
+	m := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "openshift-machine-api"}}
+	if providerID != "" {
+		m.Spec.ProviderID = pointer.StringPtr(providerID)
+	}
+	return m
+}
+
+func orphanedInstancesGauge(t *testing.T, provider string) float64 {
+	t.Helper()
+	m := &dto.Metric{}
+	if err := metrics.OrphanedInstances.WithLabelValues(provider).Write(m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetGauge().GetValue()
+}
+
+func TestOrphanedInstances(t *testing.T) {
+	instances := []Instance{
+		{ProviderID: "test://1", Name: "by-provider-id"},
+		{ProviderID: "test://2", Name: "by-name"},
+		{ProviderID: "test://3", Name: "orphan"},
+	}
+	machines := []machinev1.Machine{
+		*newMachine("machine", "test://1"),
+		*newMachine("by-name", ""),
+	}
+
+	orphans := orphanedInstances(instances, machines)
+	if len(orphans) != 1 || orphans[0].Name != "orphan" {
+		t.Errorf("expected instance orphan to be orphaned, got: %v", orphans)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	testCases := []struct {
+		name            string
+		delete          bool
+		elapsed         time.Duration
+		expectedDeleted []string
+		expectedOrphans float64
+	}{
+		{
+			name:            "reporting orphaned instances",
+			elapsed:         2 * DefaultGracePeriod,
+			expectedOrphans: 1,
+		},
+		{
+			name:            "deleting orphaned instances before the grace period",
+			delete:          true,
+			elapsed:         DefaultGracePeriod / 2,
+			expectedOrphans: 1,
+		},
+		{
+			name:            "deleting orphaned instances after the grace period",
+			delete:          true,
+			elapsed:         DefaultGracePeriod,
+			expectedDeleted: []string{"orphan"},
+			expectedOrphans: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			now := start
+			provider := &fakeProvider{instances: []Instance{
+				{ProviderID: "test://1", Name: "machine"},
+				{ProviderID: "test://2", Name: "orphan"},
+			}}
+			opts := Options{Provider: "test", Interval: DefaultInterval, GracePeriod: DefaultGracePeriod, Delete: tc.delete}
+			r := newReconciler(provider, opts, &now, newMachine("machine", "test://1"))
+
+			result, err := r.Reconcile(context.TODO(), infrastructureRequest)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.RequeueAfter != DefaultInterval {
+				t.Errorf("expected a requeue after %v, got: %v", DefaultInterval, result.RequeueAfter)
+			}
+			if len(provider.deleted) != 0 {
+				t.Errorf("expected no instance to be deleted when first found orphaned, got: %v", provider.deleted)
+			}
+			if got := orphanedInstancesGauge(t, "test"); got != 1 {
+				t.Errorf("expected 1 orphaned instance, got: %v", got)
+			}
+
+			now = now.Add(tc.elapsed)
+			if _, err := r.Reconcile(context.TODO(), infrastructureRequest); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(provider.deleted) != len(tc.expectedDeleted) {
+				t.Fatalf("expected instances %v to be deleted, got: %v", tc.expectedDeleted, provider.deleted)
+			}
+
+			// Once deleted, the instance is no longer orphaned.
+			if _, err := r.Reconcile(context.TODO(), infrastructureRequest); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := orphanedInstancesGauge(t, "test"); got != tc.expectedOrphans {
+				t.Errorf("expected %v orphaned instances, got: %v", tc.expectedOrphans, got)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ package vsphere
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	machinev1 "github.com/openshift/api/machine/v1beta1"
@@ -31,6 +32,9 @@ type Actuator struct {
 	apiReader     runtimeclient.Reader
 	eventRecorder record.EventRecorder
 	TaskIDCache   map[string]string
+
+	// taskIDCacheLock guards TaskIDCache as machines may be reconciled concurrently.
+	taskIDCacheLock sync.Mutex
 }
 
 // ActuatorParams holds parameter information for Actuator.
@@ -51,6 +55,25 @@ func NewActuator(params ActuatorParams) *Actuator {
 	}
 }
 
+func (a *Actuator) getTaskID(machineName string) (string, bool) {
+	a.taskIDCacheLock.Lock()
+	defer a.taskIDCacheLock.Unlock()
+	val, ok := a.TaskIDCache[machineName]
+	return val, ok
+}
+
+func (a *Actuator) setTaskID(machineName, taskID string) {
+	a.taskIDCacheLock.Lock()
+	defer a.taskIDCacheLock.Unlock()
+	a.TaskIDCache[machineName] = taskID
+}
+
+func (a *Actuator) deleteTaskID(machineName string) {
+	a.taskIDCacheLock.Lock()
+	defer a.taskIDCacheLock.Unlock()
+	delete(a.TaskIDCache, machineName)
+}
+
 // Set corresponding event based on error. It also returns the original error
 // for convenience, so callers can do "return handleMachineError(...)".
 func (a *Actuator) handleMachineError(machine *machinev1.Machine, err error, eventAction string) error {
@@ -78,7 +101,7 @@ func (a *Actuator) Create(ctx context.Context, machine *machinev1.Machine) error
 
 	// Ensure we're not reconciling a stale machine by checking our task-id.
 	// This is a workaround for a cache race condition.
-	if val, ok := a.TaskIDCache[machine.Name]; ok {
+	if val, ok := a.getTaskID(machine.Name); ok {
 		if val != scope.providerStatus.TaskRef {
 			klog.Errorf("%s: machine object missing expected provider task ID, requeue", machine.GetName())
 			return &machinecontroller.RequeueAfterError{RequeueAfter: requeueAfterSeconds * time.Second}
@@ -89,7 +112,7 @@ func (a *Actuator) Create(ctx context.Context, machine *machinev1.Machine) error
 	err = newReconciler(scope).create()
 	// save the taskRef in our cache in case of any error with patch.
 	if scope.providerStatus.TaskRef != "" {
-		a.TaskIDCache[machine.Name] = scope.providerStatus.TaskRef
+		a.setTaskID(machine.Name, scope.providerStatus.TaskRef)
 	}
 	if err != nil {
 		fmtErr := fmt.Errorf(reconcilerFailFmt, machine.GetName(), createEventAction, err)
@@ -122,7 +145,7 @@ func (a *Actuator) Exists(ctx context.Context, machine *machinev1.Machine) (bool
 func (a *Actuator) Update(ctx context.Context, machine *machinev1.Machine) error {
 	klog.Infof("%s: actuator updating machine", machine.GetName())
 	// Cleanup TaskIDCache so we don't continually grow
-	delete(a.TaskIDCache, machine.Name)
+	a.deleteTaskID(machine.Name)
 
 	scope, err := newMachineScope(machineScopeParams{
 		Context:   ctx,
@@ -162,7 +185,7 @@ func (a *Actuator) Delete(ctx context.Context, machine *machinev1.Machine) error
 	klog.Infof("%s: actuator deleting machine", machine.GetName())
 	// Cleanup TaskIDCache so we don't continually grow
 	// Cleanup here as well in case Update() was never successfully called.
-	delete(a.TaskIDCache, machine.Name)
+	a.deleteTaskID(machine.Name)
 
 	scope, err := newMachineScope(machineScopeParams{
 		Context:   ctx,
@@ -0,0 +1,178 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/controller/orphanedinstance"
+	"github.com/openshift/machine-api-operator/pkg/controller/vsphere/session"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/pointer"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// blank assignment to verify that InstanceProvider implements orphanedinstance.InstanceProvider
+var _ orphanedinstance.InstanceProvider = &InstanceProvider{}
+
+// InstanceProvider lists and deletes the VMs tagged with the cluster ID in the vCenters of the machines,
+// for the orphaned instance controller.
+type InstanceProvider struct {
+	client    runtimeclient.Client
+	apiReader runtimeclient.Reader
+
+	// sessions are the sessions of the vCenters of the instances last listed, by server.
+	sessions map[string]*session.Session
+}
+
+// NewInstanceProvider returns a new vSphere instance provider.
+func NewInstanceProvider(client runtimeclient.Client, apiReader runtimeclient.Reader) *InstanceProvider {
+	return &InstanceProvider{
+		client:    client,
+		apiReader: apiReader,
+	}
+}
+
+// ListInstances returns the VMs tagged with the cluster ID in the vCenters of the workspaces of the machines,
+// logging in with the credentials of the first machine of each vCenter. VM templates are not listed.
+func (p *InstanceProvider) ListInstances(ctx context.Context, clusterID string, machines []machinev1.Machine) ([]orphanedinstance.Instance, error) {
+	vSphereConfig, err := getVSphereConfig(p.apiReader)
+	if err != nil {
+		klog.Errorf("Failed to fetch vSphere config: %v", err)
+	}
+
+	sessions := map[string]*session.Session{}
+	var instances []orphanedinstance.Instance
+	for _, machine := range machines {
+		providerSpec, err := ProviderSpecFromRawExtension(machine.Spec.ProviderSpec.Value)
+		if err != nil || providerSpec.Workspace == nil {
+			continue
+		}
+		server := providerSpec.Workspace.Server
+		if _, ok := sessions[server]; ok {
+			continue
+		}
+
+		user, password, err := getCredentialsSecret(p.client, machine.GetNamespace(), *providerSpec)
+		if err != nil {
+			return nil, fmt.Errorf("%v: error getting credentials: %w", machine.GetName(), err)
+		}
+		s, err := session.GetOrCreate(ctx,
+			fmt.Sprintf("%s:%s", server, getPortFromConfig(vSphereConfig)), providerSpec.Workspace.Datacenter,
+			user, password, getInsecureFlagFromConfig(vSphereConfig))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create vSphere session: %w", err)
+		}
+		sessions[server] = s
+
+		vms, err := taggedVMs(ctx, s, clusterID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the vms of %s: %w", server, err)
+		}
+		for _, vm := range vms {
+			if vm.Config == nil || vm.Config.Template {
+				continue
+			}
+			providerID, err := convertUUIDToProviderID(vm.Config.Uuid)
+			if err != nil {
+				klog.Warningf("Ignoring vm %s with invalid uuid %q: %v", vm.Name, vm.Config.Uuid, err)
+				continue
+			}
+			instances = append(instances, orphanedinstance.Instance{
+				ProviderID: providerID,
+				Name:       vm.Name,
+				Location:   server,
+			})
+		}
+	}
+	p.sessions = sessions
+	return instances, nil
+}
+
+// DeleteInstance powers off and destroys the VM of the instance.
+func (p *InstanceProvider) DeleteInstance(ctx context.Context, instance orphanedinstance.Instance) error {
+	s, ok := p.sessions[instance.Location]
+	if !ok {
+		return fmt.Errorf("no session for vCenter %s", instance.Location)
+	}
+
+	uuid := strings.TrimPrefix(instance.ProviderID, providerIDPrefix)
+	ref, err := object.NewSearchIndex(s.Client.Client).FindByUuid(ctx, nil, uuid, true, pointer.BoolPtr(false))
+	if err != nil {
+		return fmt.Errorf("error finding vm by uuid %q: %w", uuid, err)
+	}
+	if ref == nil {
+		klog.Infof("%v: vm does not exist", instance.Name)
+		return nil
+	}
+	vm := object.NewVirtualMachine(s.Client.Client, ref.Reference())
+
+	powerState, err := vm.PowerState(ctx)
+	if err != nil {
+		return err
+	}
+	if powerState != types.VirtualMachinePowerStatePoweredOff {
+		task, err := vm.PowerOff(ctx)
+		if err != nil {
+			return fmt.Errorf("%v: failed to power off vm: %w", instance.Name, err)
+		}
+		if err := task.Wait(ctx); err != nil {
+			return fmt.Errorf("%v: failed to power off vm: %w", instance.Name, err)
+		}
+	}
+
+	task, err := vm.Destroy(ctx)
+	if err != nil {
+		return fmt.Errorf("%v: failed to destroy vm: %w", instance.Name, err)
+	}
+	if err := task.Wait(ctx); err != nil {
+		return fmt.Errorf("%v: failed to destroy vm: %w", instance.Name, err)
+	}
+	return nil
+}
+
+// taggedVMs returns the VMs the tags named after the cluster ID are attached to. It returns no VM when
+// there is no such tag, eg. on UPI clusters.
+func taggedVMs(ctx context.Context, s *session.Session, clusterID string) ([]mo.VirtualMachine, error) {
+	var refs []types.ManagedObjectReference
+	if err := s.WithRestClient(ctx, func(c *rest.Client) error {
+		m := tags.NewManager(c)
+		clusterTags, err := m.GetTags(ctx)
+		if err != nil {
+			return err
+		}
+		for _, tag := range clusterTags {
+			if tag.Name != clusterID {
+				continue
+			}
+			objects, err := m.ListAttachedObjects(ctx, tag.ID)
+			if err != nil {
+				return err
+			}
+			for _, o := range objects {
+				if ref := o.Reference(); ref.Type == "VirtualMachine" {
+					refs = append(refs, ref)
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if len(refs) == 0 {
+		return nil, nil
+	}
+
+	var vms []mo.VirtualMachine
+	if err := property.DefaultCollector(s.Client.Client).Retrieve(ctx, refs, []string{"name", "config.uuid", "config.template"}, &vms); err != nil {
+		return nil, err
+	}
+	return vms, nil
+}
@@ -0,0 +1,124 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	configv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/vapi/tags"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestInstanceProvider(t *testing.T) {
+	g := NewWithT(t)
+
+	model, session, server := initSimulator(t, func(m *simulator.Model) { m.Machine = 4 })
+	defer model.Remove()
+	defer server.Close()
+	host, port, err := net.SplitHostPort(server.URL.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	password, _ := server.URL.User.Password()
+	namespace := "test"
+	clusterID := "CLUSTERID"
+
+	// Tag all the vms but the last one with the cluster ID, and make the first one a template.
+	var vms []*simulator.VirtualMachine
+	for _, o := range simulator.Map.All("VirtualMachine") {
+		vms = append(vms, o.(*simulator.VirtualMachine))
+	}
+	g.Expect(len(vms)).To(BeNumerically(">=", 4))
+	vms[0].Config.Template = true
+	g.Expect(session.WithRestClient(context.TODO(), func(c *rest.Client) error {
+		m := tags.NewManager(c)
+		categoryID, err := m.CreateCategory(context.TODO(), &tags.Category{
+			AssociableTypes: []string{"VirtualMachine"},
+			Cardinality:     "SINGLE",
+			Name:            "CLUSTERID_CATEGORY",
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := m.CreateTag(context.TODO(), &tags.Tag{CategoryID: categoryID, Name: clusterID}); err != nil {
+			return err
+		}
+		for _, vm := range vms[:3] {
+			if err := m.AttachTag(context.TODO(), clusterID, vm.Reference()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})).To(Succeed())
+
+	credentialsSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: namespace},
+		Data: map[string][]byte{
+			fmt.Sprintf("%s.username", host): []byte(server.URL.User.Username()),
+			fmt.Sprintf("%s.password", host): []byte(password),
+		},
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "testName", Namespace: openshiftConfigNamespace},
+		Data:       map[string]string{"testKey": fmt.Sprintf(testConfigFmt, port)},
+	}
+	infra := &configv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{Name: globalInfrastuctureName},
+		Spec: configv1.InfrastructureSpec{
+			CloudConfig: configv1.ConfigMapFileReference{Name: "testName", Key: "testKey"},
+		},
+	}
+	raw, err := RawExtensionFromProviderSpec(&machinev1.VSphereMachineProviderSpec{
+		CredentialsSecret: &corev1.LocalObjectReference{Name: "test"},
+		Workspace:         &machinev1.Workspace{Server: host},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	machine := machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: namespace},
+		Spec:       machinev1.MachineSpec{ProviderSpec: machinev1.ProviderSpec{Value: raw}},
+	}
+
+	machinev1.AddToScheme(scheme.Scheme)
+	client := fake.NewFakeClientWithScheme(scheme.Scheme, credentialsSecret, configMap, infra)
+	p := NewInstanceProvider(client, client)
+
+	// Without machines, there is no vCenter to look for instances in.
+	instances, err := p.ListInstances(context.TODO(), clusterID, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(instances).To(BeEmpty())
+
+	// The tagged vms are listed, but the template.
+	instances, err = p.ListInstances(context.TODO(), clusterID, []machinev1.Machine{machine})
+	g.Expect(err).ToNot(HaveOccurred())
+	var names []string
+	for _, instance := range instances {
+		names = append(names, instance.Name)
+		g.Expect(instance.Location).To(Equal(host))
+	}
+	g.Expect(names).To(ConsistOf(vms[1].Name, vms[2].Name))
+
+	// Deleting an instance destroys its vm.
+	g.Expect(p.DeleteInstance(context.TODO(), instances[0])).To(Succeed())
+	uuid := instances[0].ProviderID[len(providerIDPrefix):]
+	ref, err := object.NewSearchIndex(session.Client.Client).FindByUuid(context.TODO(), nil, uuid, true, pointer.BoolPtr(false))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ref).To(BeNil())
+
+	// The cluster ID tag does not exist on UPI clusters.
+	instances, err = p.ListInstances(context.TODO(), "UPI", []machinev1.Machine{machine})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(instances).To(BeEmpty())
+}
@@ -6,9 +6,8 @@ import (
 	"fmt"
 
 	machinev1 "github.com/openshift/api/machine/v1beta1"
-	machineapierros "github.com/openshift/machine-api-operator/pkg/controller/machine"
-	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
 	"github.com/openshift/machine-api-operator/pkg/controller/vsphere/session"
+	"github.com/openshift/machine-api-operator/pkg/util/machineapierrors"
 	apicorev1 "k8s.io/api/core/v1"
 	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/klog/v2"
@@ -59,12 +58,12 @@ func newMachineScope(params machineScopeParams) (*machineScope, error) {
 
 	providerSpec, err := ProviderSpecFromRawExtension(params.machine.Spec.ProviderSpec.Value)
 	if err != nil {
-		return nil, machineapierros.InvalidMachineConfiguration("failed to get machine config: %v", err)
+		return nil, machineapierrors.InvalidConfiguration("failed to get machine config: %v", err)
 	}
 
 	providerStatus, err := ProviderStatusFromRawExtension(params.machine.Status.ProviderStatus)
 	if err != nil {
-		return nil, machineapierros.InvalidMachineConfiguration("failed to get machine provider status: %v", err.Error())
+		return nil, machineapierrors.InvalidConfiguration("failed to get machine provider status: %v", err.Error())
 	}
 
 	user, password, err := getCredentialsSecret(params.client, params.machine.GetNamespace(), *providerSpec)
@@ -102,7 +101,7 @@ func (s *machineScope) PatchMachine() error {
 
 	providerStatus, err := RawExtensionFromProviderStatus(s.providerStatus)
 	if err != nil {
-		return machineapierros.InvalidMachineConfiguration("failed to get machine provider status: %v", err.Error())
+		return machineapierrors.InvalidConfiguration("failed to get machine provider status: %v", err.Error())
 	}
 	s.machine.Status.ProviderStatus = providerStatus
 
@@ -175,7 +174,7 @@ func (s *machineScope) checkNodeReachable() (bool, error) {
 // provider spec, if one is set.
 func (s *machineScope) GetUserData() ([]byte, error) {
 	if s.providerSpec == nil || s.providerSpec.UserDataSecret == nil {
-		return nil, machinecontroller.InvalidMachineConfiguration("user data secret is missing in provider spec")
+		return nil, machineapierrors.InvalidConfiguration("user data secret is missing in provider spec")
 	}
 
 	userDataSecret := &apicorev1.Secret{}
@@ -223,7 +222,7 @@ func getCredentialsSecret(client runtimeclient.Client, namespace string, spec ma
 		&credentialsSecret); err != nil {
 
 		if apimachineryerrors.IsNotFound(err) {
-			return "", "", machineapierros.InvalidMachineConfiguration("credentials secret %v/%v not found: %v", namespace, spec.CredentialsSecret.Name, err.Error())
+			return "", "", machineapierrors.InvalidConfiguration("credentials secret %v/%v not found: %v", namespace, spec.CredentialsSecret.Name, err.Error())
 		}
 		return "", "", fmt.Errorf("error getting credentials secret %v/%v: %v", namespace, spec.CredentialsSecret.Name, err)
 	}
@@ -238,12 +237,12 @@ func getCredentialsSecret(client runtimeclient.Client, namespace string, spec ma
 
 	user, exists := credentialsSecret.Data[credentialsSecretUser]
 	if !exists {
-		return "", "", machineapierros.InvalidMachineConfiguration("secret %v/%v does not have %q field set", namespace, spec.CredentialsSecret.Name, credentialsSecretUser)
+		return "", "", machineapierrors.InvalidConfiguration("secret %v/%v does not have %q field set", namespace, spec.CredentialsSecret.Name, credentialsSecretUser)
 	}
 
 	password, exists := credentialsSecret.Data[credentialsSecretPassword]
 	if !exists {
-		return "", "", machineapierros.InvalidMachineConfiguration("secret %v/%v does not have %q field set", namespace, spec.CredentialsSecret.Name, credentialsSecretPassword)
+		return "", "", machineapierrors.InvalidConfiguration("secret %v/%v does not have %q field set", namespace, spec.CredentialsSecret.Name, credentialsSecretPassword)
 	}
 
 	return string(user), string(password), nil
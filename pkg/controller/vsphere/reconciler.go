@@ -6,15 +6,16 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	apimachineryutilerrors "k8s.io/apimachinery/pkg/util/errors"
 
 	"github.com/google/uuid"
 	machinev1 "github.com/openshift/api/machine/v1beta1"
-	machineapierros "github.com/openshift/machine-api-operator/pkg/controller/machine"
 	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
 	"github.com/openshift/machine-api-operator/pkg/controller/vsphere/session"
 	"github.com/openshift/machine-api-operator/pkg/metrics"
+	"github.com/openshift/machine-api-operator/pkg/util/machineapierrors"
 	"github.com/vmware/govmomi/find"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/property"
@@ -121,7 +122,7 @@ func (r *Reconciler) create() error {
 			if statusError != nil {
 				return fmt.Errorf("Failed to set provider status: %w", statusError)
 			}
-			return machineapierros.CreateMachine(err.Error())
+			return machinecontroller.CreateMachine(err.Error())
 		} else {
 			return fmt.Errorf("Failed to check task status: %w", err)
 		}
@@ -363,6 +364,12 @@ func (r *Reconciler) reconcileMachineWithCloudState(vm *virtualMachine, taskRef
 		return err
 	}
 
+	klog.V(3).Infof("%v: reconciling boot time annotation", r.machine.GetName())
+	if err := r.reconcileBootTimeAnnotation(vm); err != nil {
+		// Not treating this is as a fatal error, the annotation is informational only.
+		klog.Errorf("Failed to reconcile boot time annotation: %v", err)
+	}
+
 	return setProviderStatus(taskRef, conditionSuccess(), r.machineScope, vm)
 }
 
@@ -482,9 +489,32 @@ func (r *Reconciler) reconcilePowerStateAnnontation(vm *virtualMachine) error {
 	return nil
 }
 
+// reconcileBootTimeAnnotation records the time the VM was last booted on the machine.
+// The annotation is left untouched while the VM is not running as vSphere does not report a boot time then.
+func (r *Reconciler) reconcileBootTimeAnnotation(vm *virtualMachine) error {
+	if vm == nil {
+		return errors.New("provided VM is nil")
+	}
+
+	bootTime, err := vm.getBootTime()
+	if err != nil {
+		return err
+	}
+	if bootTime == nil {
+		return nil
+	}
+
+	if r.machine.Annotations == nil {
+		r.machine.Annotations = map[string]string{}
+	}
+	r.machine.Annotations[machinecontroller.MachineInstanceBootTimeAnnotationName] = bootTime.UTC().Format(time.RFC3339)
+
+	return nil
+}
+
 func validateMachine(machine machinev1.Machine) error {
 	if machine.Labels[machinev1.MachineClusterIDLabel] == "" {
-		return machinecontroller.InvalidMachineConfiguration("%v: missing %q label", machine.GetName(), machinev1.MachineClusterIDLabel)
+		return machineapierrors.InvalidConfiguration("%v: missing %q label", machine.GetName(), machinev1.MachineClusterIDLabel)
 	}
 
 	return nil
@@ -695,7 +725,7 @@ func getDiskSpec(s *machineScope, devices object.VirtualDeviceList) (types.BaseV
 	disk := disks[0].(*types.VirtualDisk)
 	cloneCapacityKB := int64(s.providerSpec.DiskGiB) * 1024 * 1024
 	if disk.CapacityInKB > cloneCapacityKB {
-		return nil, machineapierros.InvalidMachineConfiguration(
+		return nil, machineapierrors.InvalidConfiguration(
 			"can't resize template disk down, initial capacity is larger: %dKiB > %dKiB",
 			disk.CapacityInKB, cloneCapacityKB)
 	}
@@ -816,12 +846,12 @@ func setProviderStatus(taskRef string, condition machinev1.VSphereMachineProvide
 func handleVSphereError(multipleFoundMsg, notFoundMsg string, defaultError, vsphereError error) error {
 	var multipleFoundError *find.MultipleFoundError
 	if errors.As(vsphereError, &multipleFoundError) {
-		return machinecontroller.InvalidMachineConfiguration(multipleFoundMsg)
+		return machineapierrors.InvalidConfiguration(multipleFoundMsg)
 	}
 
 	var notFoundError *find.NotFoundError
 	if errors.As(vsphereError, &notFoundError) {
-		return machinecontroller.InvalidMachineConfiguration(notFoundMsg)
+		return machineapierrors.InvalidConfiguration(notFoundMsg)
 	}
 
 	return defaultError
@@ -932,6 +962,14 @@ func (vm *virtualMachine) getPowerState() (types.VirtualMachinePowerState, error
 	}
 }
 
+func (vm *virtualMachine) getBootTime() (*time.Time, error) {
+	var o mo.VirtualMachine
+	if err := vm.Obj.Properties(vm.Context, vm.Ref, []string{"runtime.bootTime"}, &o); err != nil {
+		return nil, err
+	}
+	return o.Runtime.BootTime, nil
+}
+
 // reconcileTags ensures that the required tags are present on the virtual machine, eg the Cluster ID
 // that is used by the installer on cluster deletion to ensure ther are no leaked resources.
 func (vm *virtualMachine) reconcileTags(ctx context.Context, session *session.Session, machine *machinev1.Machine) error {
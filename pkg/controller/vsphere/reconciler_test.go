@@ -23,6 +23,7 @@ import (
 	"net"
 	"reflect"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
 	configv1 "github.com/openshift/api/config/v1"
@@ -2155,4 +2156,85 @@ func TestReconcilePowerStateAnnontation(t *testing.T) {
 	}
 }
 
+func TestReconcileBootTimeAnnotation(t *testing.T) {
+	model, session, server := initSimulator(t)
+	defer model.Remove()
+	defer server.Close()
+
+	simulatorVM := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine)
+	managedObjRef := simulatorVM.VirtualMachine.Reference()
+	vm := &virtualMachine{
+		Context: context.Background(),
+		Obj:     object.NewVirtualMachine(session.Client.Client, managedObjRef),
+		Ref:     managedObjRef,
+	}
+
+	bootTime := time.Date(2022, time.January, 10, 12, 30, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name               string
+		vm                 *virtualMachine
+		bootTime           *time.Time
+		annotations        map[string]string
+		expectedAnnotation string
+		expectedError      bool
+	}{
+		{
+			name:               "Sets the annotation from the VM boot time",
+			vm:                 vm,
+			bootTime:           &bootTime,
+			expectedAnnotation: "2022-01-10T12:30:00Z",
+		},
+		{
+			name:     "Updates a stale annotation",
+			vm:       vm,
+			bootTime: &bootTime,
+			annotations: map[string]string{
+				machinecontroller.MachineInstanceBootTimeAnnotationName: "2021-01-01T00:00:00Z",
+			},
+			expectedAnnotation: "2022-01-10T12:30:00Z",
+		},
+		{
+			name:     "Keeps the annotation when the VM reports no boot time",
+			vm:       vm,
+			bootTime: nil,
+			annotations: map[string]string{
+				machinecontroller.MachineInstanceBootTimeAnnotationName: "2021-01-01T00:00:00Z",
+			},
+			expectedAnnotation: "2021-01-01T00:00:00Z",
+		},
+		{
+			name:          "Error on nil VM",
+			vm:            nil,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			simulatorVM.Runtime.BootTime = tc.bootTime
+
+			r := &Reconciler{
+				machineScope: &machineScope{
+					machine: &machinev1.Machine{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: tc.annotations,
+						},
+					},
+				},
+			}
+
+			err := r.reconcileBootTimeAnnotation(tc.vm)
+			if tc.expectedError != (err != nil) {
+				t.Errorf("Expected error: %v, got: %v", tc.expectedError, err)
+			}
+
+			actualBootTime := r.machine.Annotations[machinecontroller.MachineInstanceBootTimeAnnotationName]
+			if actualBootTime != tc.expectedAnnotation {
+				t.Errorf("Expected boot time annotation: %q, got: %q", tc.expectedAnnotation, actualBootTime)
+			}
+		})
+	}
+}
+
 // See https://github.com/vmware/govmomi/blob/master/simulator/example_extend_test.go#L33:6 for extending behaviour example
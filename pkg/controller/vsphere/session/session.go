@@ -28,6 +28,7 @@ import (
 	"github.com/vmware/govmomi/vim25/types"
 
 	"github.com/google/uuid"
+	"github.com/openshift/machine-api-operator/pkg/util/machineapierrors"
 	"github.com/vmware/govmomi"
 	"github.com/vmware/govmomi/find"
 	"github.com/vmware/govmomi/object"
@@ -92,6 +93,9 @@ func GetOrCreate(
 	// Set up user agent before login for being able to track mapi component in vcenter sessions list
 	client.UserAgent = "machineAPIvSphereProvider"
 	if err := client.Login(ctx, url.UserPassword(username, password)); err != nil {
+		if isInvalidLogin(err) {
+			return nil, machineapierrors.Unauthorized("unable to login to vCenter: %v", err)
+		}
 		return nil, fmt.Errorf("unable to login to vCenter: %w", err)
 	}
 
@@ -205,3 +209,15 @@ func (s *Session) WithRestClient(ctx context.Context, f func(c *rest.Client) err
 
 	return f(c)
 }
+
+// isInvalidLogin returns true when vCenter rejected the credentials of a login.
+func isInvalidLogin(err error) bool {
+	if !soap.IsSoapFault(err) {
+		return false
+	}
+	switch soap.ToSoapFault(err).VimFault().(type) {
+	case types.InvalidLogin, *types.InvalidLogin:
+		return true
+	}
+	return false
+}
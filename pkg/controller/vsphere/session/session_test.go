@@ -21,6 +21,7 @@ import (
 	"crypto/tls"
 	"testing"
 
+	"github.com/openshift/machine-api-operator/pkg/util/machineapierrors"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/simulator"
 )
@@ -244,3 +245,22 @@ func TestGetTask(t *testing.T) {
 		})
 	}
 }
+
+func TestGetOrCreateInvalidLogin(t *testing.T) {
+	model := simulator.VPX()
+	model.Host = 0
+	if err := model.Create(); err != nil {
+		t.Fatal(err)
+	}
+	defer model.Remove()
+	model.Service.TLS = new(tls.Config)
+
+	server := model.Service.NewServer()
+	defer server.Close()
+
+	// The simulator rejects the logins without a password.
+	_, err := GetOrCreate(context.TODO(), server.URL.Host, "", server.URL.User.Username(), "", true)
+	if !machineapierrors.HasReason(err, machineapierrors.UnauthorizedMachineError) {
+		t.Errorf("expected an unauthorized error, got: %v", err)
+	}
+}
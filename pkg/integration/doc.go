@@ -0,0 +1,4 @@
+// Package integration tests the machine API webhooks together with the MachineSet and machine controllers.
+// The components run in a single manager against envtest, with a fake AWS actuator in place of a cloud provider,
+// so that regressions in their interaction, such as defaulted specs the actuator cannot decode, are caught.
+package integration
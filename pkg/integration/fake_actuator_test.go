@@ -0,0 +1,131 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ machinecontroller.Actuator = &fakeActuator{}
+
+// fakeActuator is an AWS actuator backed by an in-memory list of instances.
+// It decodes the providerSpec as strictly as a real actuator, so that machines the webhooks admit
+// but a provider cannot act on fail to be created.
+type fakeActuator struct {
+	lock sync.Mutex
+
+	// instances holds the providerSpec of the instance of each machine.
+	instances map[types.NamespacedName]*machinev1.AWSMachineProviderConfig
+
+	// createError, when set, is called before an instance is created and fails the creation
+	// when it returns an error.
+	createError func(*machinev1.Machine, *machinev1.AWSMachineProviderConfig) error
+}
+
+func newFakeActuator() *fakeActuator {
+	return &fakeActuator{
+		instances: map[types.NamespacedName]*machinev1.AWSMachineProviderConfig{},
+	}
+}
+
+// decodeProviderSpec decodes the providerSpec of the machine, rejecting unknown fields.
+func decodeProviderSpec(m *machinev1.Machine) (*machinev1.AWSMachineProviderConfig, error) {
+	if m.Spec.ProviderSpec.Value == nil {
+		return nil, machinecontroller.InvalidMachineConfiguration("providerSpec is missing")
+	}
+
+	providerSpec := &machinev1.AWSMachineProviderConfig{}
+	decoder := json.NewDecoder(bytes.NewReader(m.Spec.ProviderSpec.Value.Raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(providerSpec); err != nil {
+		return nil, machinecontroller.InvalidMachineConfiguration("failed to decode providerSpec: %v", err)
+	}
+
+	switch {
+	case providerSpec.AMI.ID == nil:
+		return nil, machinecontroller.InvalidMachineConfiguration("providerSpec.ami.id is missing")
+	case providerSpec.InstanceType == "":
+		return nil, machinecontroller.InvalidMachineConfiguration("providerSpec.instanceType is missing")
+	case providerSpec.Placement.Region == "":
+		return nil, machinecontroller.InvalidMachineConfiguration("providerSpec.placement.region is missing")
+	case providerSpec.UserDataSecret == nil || providerSpec.CredentialsSecret == nil:
+		return nil, machinecontroller.InvalidMachineConfiguration("providerSpec secrets are missing")
+	}
+	return providerSpec, nil
+}
+
+func (a *fakeActuator) Create(_ context.Context, m *machinev1.Machine) error {
+	providerSpec, err := decodeProviderSpec(m)
+	if err != nil {
+		return err
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.createError != nil {
+		if err := a.createError(m, providerSpec); err != nil {
+			return err
+		}
+	}
+	a.instances[types.NamespacedName{Namespace: m.Namespace, Name: m.Name}] = providerSpec
+	return nil
+}
+
+func (a *fakeActuator) Exists(_ context.Context, m *machinev1.Machine) (bool, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	_, ok := a.instances[types.NamespacedName{Namespace: m.Namespace, Name: m.Name}]
+	return ok, nil
+}
+
+// Update reports the address of the instance, as a real actuator does once the instance is running.
+func (a *fakeActuator) Update(_ context.Context, m *machinev1.Machine) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if _, ok := a.instances[types.NamespacedName{Namespace: m.Namespace, Name: m.Name}]; !ok {
+		return fmt.Errorf("instance of machine %s/%s not found", m.Namespace, m.Name)
+	}
+	m.Status.Addresses = []corev1.NodeAddress{{Type: corev1.NodeInternalDNS, Address: m.Name}}
+	return nil
+}
+
+func (a *fakeActuator) Delete(_ context.Context, m *machinev1.Machine) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	delete(a.instances, types.NamespacedName{Namespace: m.Namespace, Name: m.Name})
+	return nil
+}
+
+// instance returns the providerSpec the instance of the machine was created with, or nil.
+func (a *fakeActuator) instance(namespace, name string) *machinev1.AWSMachineProviderConfig {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.instances[types.NamespacedName{Namespace: namespace, Name: name}]
+}
+
+// instanceCount returns the number of instances of machines in the namespace.
+func (a *fakeActuator) instanceCount(namespace string) int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	count := 0
+	for name := range a.instances {
+		if name.Namespace == namespace {
+			count++
+		}
+	}
+	return count
+}
+
+// setCreateError programs the creation failures of the actuator.
+func (a *fakeActuator) setCreateError(createError func(*machinev1.Machine, *machinev1.AWSMachineProviderConfig) error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.createError = createError
+}
@@ -0,0 +1,185 @@
+package integration
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	"github.com/openshift/machine-api-operator/pkg/controller/machineset"
+	mapiwebhooks "github.com/openshift/machine-api-operator/pkg/webhooks"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+	"k8s.io/klog/v2/klogr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/envtest/printer"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+func init() {
+	klog.InitFlags(nil)
+	logf.SetLogger(klogr.New())
+
+	// Register required object kinds with global scheme.
+	_ = machinev1.AddToScheme(scheme.Scheme)
+	_ = osconfigv1.AddToScheme(scheme.Scheme)
+}
+
+const (
+	timeout = time.Second * 30
+
+	clusterID = "integration"
+	awsRegion = "us-east-1"
+)
+
+var (
+	cfg          *rest.Config
+	k8sClient    client.Client
+	testEnv      *envtest.Environment
+	actuator     *fakeActuator
+	ctx          = context.Background()
+	mgrCtxCancel context.CancelFunc
+	mgrStopped   chan struct{}
+)
+
+func TestIntegration(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecsWithDefaultAndCustomReporters(t,
+		"Machine API Integration Suite",
+		[]Reporter{printer.NewlineReporter{}})
+}
+
+var _ = BeforeSuite(func(done Done) {
+	By("bootstrapping test environment")
+	testEnv = &envtest.Environment{
+		CRDDirectoryPaths: []string{
+			filepath.Join("..", "..", "install"),
+			filepath.Join("..", "..", "vendor", "github.com", "openshift", "api", "config", "v1"),
+		},
+		WebhookInstallOptions: envtest.WebhookInstallOptions{
+			MutatingWebhooks:   []client.Object{mapiwebhooks.NewMutatingWebhookConfiguration()},
+			ValidatingWebhooks: []client.Object{mapiwebhooks.NewValidatingWebhookConfiguration()},
+		},
+	}
+
+	var err error
+	cfg, err = testEnv.Start()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cfg).ToNot(BeNil())
+
+	k8sClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
+	Expect(err).ToNot(HaveOccurred())
+
+	By("creating the cluster configuration")
+	createClusterConfig()
+
+	// The webhooks read the cluster configuration with their own client.
+	ctrl.GetConfig = func() (*rest.Config, error) {
+		return cfg, nil
+	}
+
+	By("setting up the manager")
+	mgr, err := manager.New(cfg, manager.Options{
+		MetricsBindAddress: "0",
+		Host:               testEnv.WebhookInstallOptions.LocalServingHost,
+		Port:               testEnv.WebhookInstallOptions.LocalServingPort,
+		CertDir:            testEnv.WebhookInstallOptions.LocalServingCertDir,
+	})
+	Expect(err).ToNot(HaveOccurred())
+
+	registerWebhooks(mgr)
+
+	actuator = newFakeActuator()
+	Expect(machineset.Add(mgr, manager.Options{})).To(Succeed())
+	Expect(machinecontroller.AddWithActuator(mgr, actuator)).To(Succeed())
+
+	var mgrCtx context.Context
+	mgrCtx, mgrCtxCancel = context.WithCancel(ctx)
+	mgrStopped = make(chan struct{})
+
+	By("starting the manager")
+	go func() {
+		defer GinkgoRecover()
+		defer close(mgrStopped)
+
+		Expect(mgr.Start(mgrCtx)).To(Succeed())
+	}()
+
+	By("waiting for the webhook server")
+	address := net.JoinHostPort(testEnv.WebhookInstallOptions.LocalServingHost, fmt.Sprint(testEnv.WebhookInstallOptions.LocalServingPort))
+	Eventually(func() error {
+		conn, err := tls.Dial("tcp", address, &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}, timeout).Should(Succeed())
+
+	close(done)
+}, 120)
+
+var _ = AfterSuite(func() {
+	By("stopping the manager")
+	if mgrCtxCancel != nil {
+		mgrCtxCancel()
+		Eventually(mgrStopped, timeout).Should(BeClosed())
+	}
+
+	By("tearing down the test environment")
+	Expect(testEnv.Stop()).To(Succeed())
+})
+
+// createClusterConfig creates the Infrastructure and DNS of an AWS cluster.
+func createClusterConfig() {
+	infra := &osconfigv1.Infrastructure{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}
+	Expect(k8sClient.Create(ctx, infra)).To(Succeed())
+	infra.Status = osconfigv1.InfrastructureStatus{
+		InfrastructureName: clusterID,
+		PlatformStatus: &osconfigv1.PlatformStatus{
+			Type: osconfigv1.AWSPlatformType,
+			AWS:  &osconfigv1.AWSPlatformStatus{Region: awsRegion},
+		},
+	}
+	Expect(k8sClient.Status().Update(ctx, infra)).To(Succeed())
+
+	dns := &osconfigv1.DNS{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		Spec: osconfigv1.DNSSpec{
+			PublicZone: &osconfigv1.DNSZone{ID: "public"},
+		},
+	}
+	Expect(k8sClient.Create(ctx, dns)).To(Succeed())
+}
+
+// registerWebhooks registers the machine and MachineSet webhooks as the machineset controller does.
+func registerWebhooks(mgr manager.Manager) {
+	machineDefaulter, err := mapiwebhooks.NewMachineDefaulter()
+	Expect(err).ToNot(HaveOccurred())
+	machineValidator, err := mapiwebhooks.NewMachineValidator(mgr.GetClient(), mapiwebhooks.ValidationModeEnforce, false)
+	Expect(err).ToNot(HaveOccurred())
+	machineSetDefaulter, err := mapiwebhooks.NewMachineSetDefaulter()
+	Expect(err).ToNot(HaveOccurred())
+	machineSetValidator, err := mapiwebhooks.NewMachineSetValidator(mgr.GetClient(), mapiwebhooks.ValidationModeEnforce, false)
+	Expect(err).ToNot(HaveOccurred())
+
+	server := mgr.GetWebhookServer()
+	server.Register(mapiwebhooks.DefaultMachineMutatingHookPath, &webhook.Admission{Handler: machineDefaulter})
+	server.Register(mapiwebhooks.DefaultMachineValidatingHookPath, &webhook.Admission{Handler: machineValidator})
+	server.Register(mapiwebhooks.DefaultMachineSetMutatingHookPath, &webhook.Admission{Handler: machineSetDefaulter})
+	server.Register(mapiwebhooks.DefaultMachineSetValidatingHookPath, &webhook.Admission{Handler: machineSetValidator})
+}
@@ -0,0 +1,234 @@
+package integration
+
+import (
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Machine API", func() {
+	var namespace *corev1.Namespace
+
+	BeforeEach(func() {
+		namespace = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "integration-"}}
+		Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+		actuator.setCreateError(nil)
+	})
+
+	AfterEach(func() {
+		Expect(k8sClient.DeleteAllOf(ctx, &machinev1.MachineSet{}, client.InNamespace(namespace.Name))).To(Succeed())
+		Expect(k8sClient.DeleteAllOf(ctx, &machinev1.Machine{}, client.InNamespace(namespace.Name))).To(Succeed())
+		Eventually(func() ([]machinev1.Machine, error) {
+			return listMachines(namespace.Name)
+		}, timeout).Should(BeEmpty())
+		Expect(k8sClient.Delete(ctx, namespace)).To(Succeed())
+	})
+
+	It("provisions the machines of a MachineSet from a defaulted template", func() {
+		ms := newMachineSet(namespace.Name, 2, awsProviderSpec(nil))
+		Expect(k8sClient.Create(ctx, ms)).To(Succeed())
+
+		By("waiting for the machines to be provisioned")
+		Eventually(func() ([]string, error) {
+			return machinePhases(namespace.Name)
+		}, timeout).Should(ConsistOf("Provisioned", "Provisioned"))
+
+		By("checking the instances were created with the defaulted providerSpec")
+		machines, err := listMachines(namespace.Name)
+		Expect(err).ToNot(HaveOccurred())
+		for _, m := range machines {
+			instance := actuator.instance(m.Namespace, m.Name)
+			Expect(instance).ToNot(BeNil())
+			Expect(instance.InstanceType).ToNot(BeEmpty())
+			Expect(instance.Placement.Region).To(Equal(awsRegion))
+			Expect(instance.CredentialsSecret).ToNot(BeNil())
+			Expect(instance.UserDataSecret).ToNot(BeNil())
+			Expect(m.Labels).To(HaveKeyWithValue(machinev1.MachineClusterIDLabel, clusterID))
+		}
+	})
+
+	It("scales a MachineSet up and down", func() {
+		ms := newMachineSet(namespace.Name, 1, awsProviderSpec(nil))
+		Expect(k8sClient.Create(ctx, ms)).To(Succeed())
+		Eventually(func() ([]string, error) {
+			return machinePhases(namespace.Name)
+		}, timeout).Should(ConsistOf("Provisioned"))
+
+		By("scaling up to 3 replicas")
+		setReplicas(ms, 3)
+		Eventually(func() ([]string, error) {
+			return machinePhases(namespace.Name)
+		}, timeout).Should(ConsistOf("Provisioned", "Provisioned", "Provisioned"))
+		Expect(actuator.instanceCount(namespace.Name)).To(Equal(3))
+
+		By("scaling down to 0 replicas")
+		setReplicas(ms, 0)
+		Eventually(func() ([]machinev1.Machine, error) {
+			return listMachines(namespace.Name)
+		}, timeout).Should(BeEmpty())
+		Expect(actuator.instanceCount(namespace.Name)).To(BeZero())
+	})
+
+	It("rejects a MachineSet template the webhook can tell is broken", func() {
+		ms := newMachineSet(namespace.Name, 1, awsProviderSpec(nil))
+		Expect(k8sClient.Create(ctx, ms)).To(Succeed())
+
+		By("removing the AMI from the template")
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(ms), ms)).To(Succeed())
+		ms.Spec.Template.Spec.ProviderSpec = awsProviderSpec(func(p *machinev1.AWSMachineProviderConfig) {
+			p.AMI = machinev1.AWSResourceReference{}
+		})
+		err := k8sClient.Update(ctx, ms)
+		Expect(apierrors.IsForbidden(err) || apierrors.IsInvalid(err)).To(BeTrue(), "expected the update to be denied, got: %v", err)
+		Expect(err.Error()).To(ContainSubstring("providerSpec.ami"))
+	})
+
+	It("fails the machines of a MachineSet template the provider cannot act on", func() {
+		actuator.setCreateError(func(_ *machinev1.Machine, p *machinev1.AWSMachineProviderConfig) error {
+			if p.InstanceType == "broken.large" {
+				return machinecontroller.InvalidMachineConfiguration("instance type %s is not offered", p.InstanceType)
+			}
+			return nil
+		})
+
+		ms := newMachineSet(namespace.Name, 1, awsProviderSpec(nil))
+		Expect(k8sClient.Create(ctx, ms)).To(Succeed())
+		Eventually(func() ([]string, error) {
+			return machinePhases(namespace.Name)
+		}, timeout).Should(ConsistOf("Provisioned"))
+
+		By("breaking the template and scaling up")
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(ms), ms)).To(Succeed())
+		ms.Spec.Template.Spec.ProviderSpec = awsProviderSpec(func(p *machinev1.AWSMachineProviderConfig) {
+			p.InstanceType = "broken.large"
+		})
+		ms.Spec.Replicas = pointer.Int32Ptr(2)
+		Expect(k8sClient.Update(ctx, ms)).To(Succeed())
+
+		By("waiting for the new machine to fail")
+		Eventually(func() ([]string, error) {
+			return machinePhases(namespace.Name)
+		}, timeout).Should(ConsistOf("Provisioned", "Failed"))
+
+		machines, err := listMachines(namespace.Name)
+		Expect(err).ToNot(HaveOccurred())
+		for _, m := range machines {
+			if pointer.StringDeref(m.Status.Phase, "") != "Failed" {
+				continue
+			}
+			Expect(m.Status.ErrorReason).ToNot(BeNil())
+			Expect(*m.Status.ErrorReason).To(Equal(machinev1.InvalidConfigurationMachineError))
+			Expect(pointer.StringDeref(m.Status.ErrorMessage, "")).To(ContainSubstring("broken.large"))
+			Expect(actuator.instance(m.Namespace, m.Name)).To(BeNil())
+		}
+	})
+
+	It("does not delete a machine until its pre-terminate hook is removed", func() {
+		m := &machinev1.Machine{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "hooked-", Namespace: namespace.Name},
+			Spec: machinev1.MachineSpec{
+				ProviderSpec: awsProviderSpec(nil),
+				LifecycleHooks: machinev1.LifecycleHooks{
+					PreTerminate: []machinev1.LifecycleHook{{Name: "integration", Owner: "integration-test"}},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, m)).To(Succeed())
+		Eventually(func() ([]string, error) {
+			return machinePhases(namespace.Name)
+		}, timeout).Should(ConsistOf("Provisioned"))
+
+		By("deleting the machine")
+		Expect(k8sClient.Delete(ctx, m)).To(Succeed())
+		Eventually(func() ([]string, error) {
+			return machinePhases(namespace.Name)
+		}, timeout).Should(ConsistOf("Deleting"))
+		Consistently(func() bool {
+			return actuator.instance(m.Namespace, m.Name) != nil
+		}, 2*time.Second).Should(BeTrue(), "expected the instance to be kept while the hook is set")
+
+		By("removing the hook")
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(m), m)).To(Succeed())
+		m.Spec.LifecycleHooks.PreTerminate = nil
+		Expect(k8sClient.Update(ctx, m)).To(Succeed())
+
+		Eventually(func() bool {
+			err := k8sClient.Get(ctx, client.ObjectKeyFromObject(m), &machinev1.Machine{})
+			return apierrors.IsNotFound(err)
+		}, timeout).Should(BeTrue())
+		Expect(actuator.instance(m.Namespace, m.Name)).To(BeNil())
+	})
+})
+
+// awsProviderSpec returns a minimal AWS providerSpec, relying on the webhooks for the other fields.
+func awsProviderSpec(modify func(*machinev1.AWSMachineProviderConfig)) machinev1.ProviderSpec {
+	providerSpec := &machinev1.AWSMachineProviderConfig{
+		AMI: machinev1.AWSResourceReference{ID: pointer.StringPtr("ami-integration")},
+	}
+	if modify != nil {
+		modify(providerSpec)
+	}
+
+	raw, err := json.Marshal(providerSpec)
+	Expect(err).ToNot(HaveOccurred())
+	return machinev1.ProviderSpec{Value: &runtime.RawExtension{Raw: raw}}
+}
+
+func newMachineSet(namespace string, replicas int32, providerSpec machinev1.ProviderSpec) *machinev1.MachineSet {
+	labels := map[string]string{"integration": "workers"}
+	return &machinev1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "workers", Namespace: namespace},
+		Spec: machinev1.MachineSetSpec{
+			Replicas: pointer.Int32Ptr(replicas),
+			Selector: metav1.LabelSelector{MatchLabels: labels},
+			Template: machinev1.MachineTemplateSpec{
+				ObjectMeta: machinev1.ObjectMeta{Labels: labels},
+				Spec: machinev1.MachineSpec{
+					ProviderSpec: providerSpec,
+				},
+			},
+		},
+	}
+}
+
+func setReplicas(ms *machinev1.MachineSet, replicas int32) {
+	Eventually(func() error {
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(ms), ms); err != nil {
+			return err
+		}
+		ms.Spec.Replicas = pointer.Int32Ptr(replicas)
+		return k8sClient.Update(ctx, ms)
+	}, timeout).Should(Succeed())
+}
+
+func listMachines(namespace string) ([]machinev1.Machine, error) {
+	machines := &machinev1.MachineList{}
+	if err := k8sClient.List(ctx, machines, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	return machines.Items, nil
+}
+
+// machinePhases returns the phases of the machines in the namespace.
+func machinePhases(namespace string) ([]string, error) {
+	machines, err := listMachines(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	phases := []string{}
+	for _, m := range machines {
+		phases = append(phases, pointer.StringDeref(m.Status.Phase, ""))
+	}
+	return phases, nil
+}
@@ -22,6 +22,12 @@ import (
 
 const (
 	DefaultHealthCheckMetricsAddress = ":8083"
+
+	// MachineHealthCheckRemediationSucceeded, MachineHealthCheckRemediationFailed and MachineHealthCheckRemediationShortCircuited
+	// are the results of the remediations of unhealthy machines.
+	MachineHealthCheckRemediationSucceeded      = "success"
+	MachineHealthCheckRemediationFailed         = "failed"
+	MachineHealthCheckRemediationShortCircuited = "short_circuited"
 )
 
 var (
@@ -41,6 +47,30 @@ var (
 		}, []string{"name", "namespace"},
 	)
 
+	// MachineHealthCheckUnhealthyMachines is a Prometheus metric, which reports the number of unhealthy or not started machines covered by MachineHealthChecks
+	MachineHealthCheckUnhealthyMachines = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "mapi_machinehealthcheck_unhealthy_machines",
+			Help: "Number of unhealthy or not started machines covered by MachineHealthChecks",
+		}, []string{"name", "namespace"},
+	)
+
+	// MachineHealthCheckMaxUnhealthyGap is a Prometheus metric, which reports how many more machines may go unhealthy before the MachineHealthCheck short-circuits
+	MachineHealthCheckMaxUnhealthyGap = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "mapi_machinehealthcheck_max_unhealthy_gap",
+			Help: "Difference between maxUnhealthy and the number of unhealthy machines of MachineHealthChecks, negative when short-circuited",
+		}, []string{"name", "namespace"},
+	)
+
+	// MachineHealthCheckRemediationsTotal is a Prometheus metric, which reports the number of remediations of unhealthy machines by result
+	MachineHealthCheckRemediationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mapi_machinehealthcheck_remediations_total",
+			Help: "Number of remediations of unhealthy machines by MachineHealthChecks, by result: success, failed or short_circuited",
+		}, []string{"name", "namespace", "result"},
+	)
+
 	// MachineHealthCheckShortCircuit is a Prometheus metric, which reports when the named MachineHealthCheck is currently short-circuited (0=no, 1=yes)
 	MachineHealthCheckShortCircuit = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -55,6 +85,9 @@ func InitializeMachineHealthCheckMetrics() {
 		MachineHealthCheckNodesCovered,
 		MachineHealthCheckRemediationSuccessTotal,
 		MachineHealthCheckShortCircuit,
+		MachineHealthCheckUnhealthyMachines,
+		MachineHealthCheckMaxUnhealthyGap,
+		MachineHealthCheckRemediationsTotal,
 	)
 }
 
@@ -65,6 +98,16 @@ func DeleteMachineHealthCheckNodesCovered(name string, namespace string) {
 	})
 }
 
+// DeleteMachineHealthCheckUnhealthyMachines removes the unhealthy machines and max unhealthy gap of a deleted MachineHealthCheck.
+func DeleteMachineHealthCheckUnhealthyMachines(name string, namespace string) {
+	labels := prometheus.Labels{
+		"name":      name,
+		"namespace": namespace,
+	}
+	MachineHealthCheckUnhealthyMachines.Delete(labels)
+	MachineHealthCheckMaxUnhealthyGap.Delete(labels)
+}
+
 func ObserveMachineHealthCheckNodesCovered(name string, namespace string, count int) {
 	MachineHealthCheckNodesCovered.With(prometheus.Labels{
 		"name":      name,
@@ -92,3 +135,25 @@ func ObserveMachineHealthCheckShortCircuitEnabled(name string, namespace string)
 		"namespace": namespace,
 	}).Set(1)
 }
+
+func ObserveMachineHealthCheckUnhealthyMachines(name string, namespace string, count int) {
+	MachineHealthCheckUnhealthyMachines.With(prometheus.Labels{
+		"name":      name,
+		"namespace": namespace,
+	}).Set(float64(count))
+}
+
+func ObserveMachineHealthCheckMaxUnhealthyGap(name string, namespace string, gap int) {
+	MachineHealthCheckMaxUnhealthyGap.With(prometheus.Labels{
+		"name":      name,
+		"namespace": namespace,
+	}).Set(float64(gap))
+}
+
+func ObserveMachineHealthCheckRemediations(name string, namespace string, result string, count int) {
+	MachineHealthCheckRemediationsTotal.With(prometheus.Labels{
+		"name":      name,
+		"namespace": namespace,
+		"result":    result,
+	}).Add(float64(count))
+}
@@ -1,9 +1,12 @@
 package metrics
 
 import (
+	"time"
+
 	machinev1 "github.com/openshift/api/machine/v1beta1"
 	machineinformers "github.com/openshift/client-go/machine/informers/externalversions/machine/v1beta1"
 	machinelisters "github.com/openshift/client-go/machine/listers/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/machines"
 	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/klog/v2"
@@ -22,7 +25,9 @@ var (
 	// MachineSetCountDesc Count of machineset object count at the apiserver
 	MachineSetCountDesc = prometheus.NewDesc("mapi_machineset_items", "Count of machinesets at the apiserver", nil, nil)
 	// MachineInfoDesc is a metric about machine object info in the cluster
-	MachineInfoDesc = prometheus.NewDesc("mapi_machine_created_timestamp_seconds", "Timestamp of the mapi managed Machine creation time", []string{"name", "namespace", "spec_provider_id", "node", "api_version", "phase"}, nil)
+	MachineInfoDesc = prometheus.NewDesc("mapi_machine_created_timestamp_seconds", "Timestamp of the mapi managed Machine creation time", []string{"name", "namespace", "spec_provider_id", "node", "api_version", "phase", "failure_domain"}, nil)
+	// MachinePhaseSecondsDesc is a metric about the time machine objects spent in their current phase
+	MachinePhaseSecondsDesc = prometheus.NewDesc("mapi_machine_phase_seconds", "Number of seconds the mapi managed Machine has spent in its current phase", []string{"name", "namespace", "phase", "failure_domain"}, nil)
 	// MachineSetInfoDesc is a metric about machine object info in the cluster
 	MachineSetInfoDesc = prometheus.NewDesc("mapi_machineset_created_timestamp_seconds", "Timestamp of the mapi managed Machineset creation time", []string{"name", "namespace", "api_version"}, nil)
 
@@ -73,11 +78,68 @@ var (
 			Buckets: []float64{5, 10, 20, 30, 60, 90, 120, 180, 240, 300, 360, 480, 600},
 		}, []string{"phase"},
 	)
+
+	// MachinePhaseDurationSeconds is a metric to capture the time a Machine spent in a phase before transitioning to the next one
+	MachinePhaseDurationSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "mapi_machine_phase_duration_seconds",
+			Help:    "Number of seconds a Machine spent in a phase before transitioning to the next phase.",
+			Buckets: []float64{5, 10, 20, 30, 60, 90, 120, 180, 240, 300, 360, 480, 600, 900, 1200, 1800, 3600},
+		}, []string{"from", "to"},
+	)
+
+	// CloudAPIRequestDurationSeconds is a metric to capture the duration of the calls of the machine controller to the actuator,
+	// which are mostly spent calling the cloud provider API
+	CloudAPIRequestDurationSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "mapi_cloud_api_request_duration_seconds",
+			Help:    "Number of seconds the machine controller spent calling the cloud provider API through the actuator.",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+		}, []string{"provider", "operation"},
+	)
+
+	// CloudAPIErrorsTotal is a metric to count the failed calls of the machine controller to the actuator, by error code
+	CloudAPIErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mapi_cloud_api_errors_total",
+			Help: "Number of times a call of the machine controller to the cloud provider API through the actuator has failed.",
+		}, []string{"provider", "operation", "code"},
+	)
+
+	// MachineStuckTotal is a metric to count the Machines getting stuck in a phase, by what they wait for
+	MachineStuckTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mapi_machine_stuck_total",
+			Help: "Number of times a Machine got stuck in a phase for longer than the machine controller threshold.",
+		}, []string{"phase", "reason"},
+	)
+
+	// OrphanedInstances is a metric about the cloud instances tagged with the cluster ID which have no Machine
+	OrphanedInstances = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "mapi_orphaned_instances",
+			Help: "Number of cloud instances tagged with the cluster ID which have no corresponding Machine.",
+		}, []string{"provider"},
+	)
+
+	// OrphanedInstancesDeletedTotal is a metric to count the orphaned cloud instances deleted by the machine controller
+	OrphanedInstancesDeletedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mapi_orphaned_instances_deleted_total",
+			Help: "Number of cloud instances without a corresponding Machine deleted by the machine controller.",
+		}, []string{"provider"},
+	)
 )
 
 func init() {
 	prometheus.MustRegister(MachineCollectorUp)
 	metrics.Registry.MustRegister(MachinePhaseTransitionSeconds)
+	metrics.Registry.MustRegister(MachinePhaseDurationSeconds)
+	metrics.Registry.MustRegister(MachineStuckTotal)
+	metrics.Registry.MustRegister(CloudAPIRequestDurationSeconds)
+	metrics.Registry.MustRegister(CloudAPIErrorsTotal)
+	metrics.Registry.MustRegister(OrphanedInstances)
+	metrics.Registry.MustRegister(OrphanedInstancesDeletedTotal)
 	metrics.Registry.MustRegister(
 		failedInstanceCreateCount,
 		failedInstanceUpdateCount,
@@ -90,6 +152,7 @@ type MachineCollector struct {
 	machineLister    machinelisters.MachineLister
 	machineSetLister machinelisters.MachineSetLister
 	namespace        string
+	now              func() time.Time
 }
 
 // MachineLabels is the group of labels that are applied to the machine metrics
@@ -104,6 +167,7 @@ func NewMachineCollector(machineInformer machineinformers.MachineInformer, machi
 		machineLister:    machineInformer.Lister(),
 		machineSetLister: machinesetInformer.Lister(),
 		namespace:        namespace,
+		now:              time.Now,
 	}
 }
 
@@ -117,6 +181,7 @@ func (mc *MachineCollector) Collect(ch chan<- prometheus.Metric) {
 func (mc MachineCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- MachineCountDesc
 	ch <- MachineSetCountDesc
+	ch <- MachinePhaseSecondsDesc
 }
 
 // Collect implements the prometheus.Collector interface.
@@ -128,6 +193,7 @@ func (mc MachineCollector) collectMachineMetrics(ch chan<- prometheus.Metric) {
 	}
 	MachineCollectorUp.With(prometheus.Labels{"kind": "mapi_machine_items"}).Set(float64(1))
 
+	now := mc.now()
 	for _, machine := range machineList {
 		nodeName := ""
 		if machine.Status.NodeRef != nil {
@@ -147,7 +213,21 @@ func (mc MachineCollector) collectMachineMetrics(ch chan<- prometheus.Metric) {
 				nodeName,
 				machine.TypeMeta.APIVersion,
 				phase,
+				machines.FailureDomain(machine),
 			)
+			// Only the phases whose start is known are reported, which excludes the phases entered before the
+			// machine controller recorded the phase transitions, until the machine changes phase.
+			if enteredAt, ok := machines.PhaseEnteredAt(machine, phase); ok {
+				ch <- prometheus.MustNewConstMetric(
+					MachinePhaseSecondsDesc,
+					prometheus.GaugeValue,
+					now.Sub(enteredAt).Seconds(),
+					machine.ObjectMeta.Name,
+					machine.ObjectMeta.Namespace,
+					phase,
+					machines.FailureDomain(machine),
+				)
+			}
 		}
 	}
 
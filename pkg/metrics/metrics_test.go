@@ -1,6 +1,17 @@
 package metrics
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	machinelisters "github.com/openshift/client-go/machine/listers/machine/v1beta1"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
 
 func TestStringPointerDeref(t *testing.T) {
 	value := "test"
@@ -23,3 +34,113 @@ func TestStringPointerDeref(t *testing.T) {
 		}
 	}
 }
+
+func TestCollectMachineMetrics(t *testing.T) {
+	created := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+	now := created.Add(time.Hour)
+	running := "Running"
+	provisioning := "Provisioning"
+	deleting := "Deleting"
+	failed := "Failed"
+	deleted := metav1.NewTime(created.Add(45 * time.Minute))
+	transitions := func(phases string) map[string]string {
+		return map[string]string{"machine.openshift.io/phase-transitions": phases}
+	}
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, machine := range []*machinev1.Machine{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "running",
+				Namespace:         "openshift-machine-api",
+				CreationTimestamp: metav1.NewTime(created),
+				Labels:            map[string]string{"machine.openshift.io/zone": "us-east-1a"},
+				Annotations:       transitions(`{"Provisioning":"2021-06-01T10:00:05Z","Provisioned":"2021-06-01T10:01:00Z","Running":"2021-06-01T10:05:00Z"}`),
+			},
+			Status: machinev1.MachineStatus{Phase: &running},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "running-unrecorded",
+				Namespace:         "openshift-machine-api",
+				CreationTimestamp: metav1.NewTime(created),
+			},
+			Status: machinev1.MachineStatus{Phase: &running},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "failed",
+				Namespace:         "openshift-machine-api",
+				CreationTimestamp: metav1.NewTime(created),
+				Annotations:       transitions(`{"Provisioning":"2021-06-01T10:00:05Z","Failed":"2021-06-01T10:30:00Z"}`),
+			},
+			Status: machinev1.MachineStatus{Phase: &failed},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "deleting",
+				Namespace:         "openshift-machine-api",
+				CreationTimestamp: metav1.NewTime(created),
+				DeletionTimestamp: &deleted,
+				Labels:            map[string]string{"machine.openshift.io/zone": "us-east-1b"},
+			},
+			Status: machinev1.MachineStatus{Phase: &deleting},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "provisioning",
+				Namespace:         "openshift-machine-api",
+				CreationTimestamp: metav1.NewTime(created),
+			},
+			Status: machinev1.MachineStatus{Phase: &provisioning},
+		},
+	} {
+		if err := indexer.Add(machine); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	mc := &MachineCollector{
+		machineLister: machinelisters.NewMachineLister(indexer),
+		namespace:     "openshift-machine-api",
+		now:           func() time.Time { return now },
+	}
+
+	ch := make(chan prometheus.Metric, 20)
+	mc.collectMachineMetrics(ch)
+	close(ch)
+
+	type phaseSeconds struct {
+		failureDomain string
+		seconds       float64
+	}
+	got := map[string]phaseSeconds{}
+	for metric := range ch {
+		if metric.Desc() != MachinePhaseSecondsDesc {
+			continue
+		}
+		m := &dto.Metric{}
+		if err := metric.Write(m); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		labels := map[string]string{}
+		for _, label := range m.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		got[labels["name"]] = phaseSeconds{failureDomain: labels["failure_domain"], seconds: m.GetGauge().GetValue()}
+	}
+
+	expected := map[string]phaseSeconds{
+		// The machine has been running since 5 minutes after its creation.
+		"running": {failureDomain: "us-east-1a", seconds: 55 * 60},
+		// The machine failed 30 minutes after its creation.
+		"failed": {seconds: 30 * 60},
+		// The machine was deleted 45 minutes after its creation, without a recorded transition to Deleting.
+		"deleting": {failureDomain: "us-east-1b", seconds: 15 * 60},
+		// The machine has been provisioning since its creation, in no known failure domain yet.
+		"provisioning": {seconds: 60 * 60},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Got: %v, expected: %v", got, expected)
+	}
+}
@@ -0,0 +1,209 @@
+/*
+   Copyright 2022 The Machine API Operator authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package metrics
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// WebhookCacheResourceAgeDesc is a metric about the time since the webhook last refreshed a cached cluster input
+	WebhookCacheResourceAgeDesc = prometheus.NewDesc("mapi_webhook_cache_resource_age_seconds", "Seconds since the webhook last successfully refreshed a cached cluster input", []string{"resource"}, nil)
+
+	// WebhookCacheRefreshFailuresTotal is a Prometheus metric, which reports the number of failed refreshes of a cached cluster input
+	WebhookCacheRefreshFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mapi_webhook_cache_refresh_failures_total",
+			Help: "Number of times the webhook failed to refresh a cached cluster input",
+		}, []string{"resource"},
+	)
+
+	// WebhookSecretsCacheLookupsTotal is a Prometheus metric, which reports the number of secrets read by the webhook, by where they were served from
+	WebhookSecretsCacheLookupsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mapi_webhook_secrets_cache_lookups_total",
+			Help: "Number of secrets read by the webhook, by result: hit when served from the cache, negative_hit when the cache remembered the secret does not exist, and miss when read from the API server",
+		}, []string{"result"},
+	)
+
+	// WebhookAuditDenialsTotal is a Prometheus metric, which reports the number of requests admitted in audit mode which would have been denied
+	WebhookAuditDenialsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mapi_webhook_audit_denials_total",
+			Help: "Number of validation errors which would have denied a request had the webhook not been in audit mode",
+		}, []string{"rule"},
+	)
+
+	// WebhookAdmissionDecisionsTotal is a Prometheus metric, which reports the number of validated requests by decision
+	WebhookAdmissionDecisionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mapi_webhook_admission_decisions_total",
+			Help: "Number of requests validated by the webhook, by resource, platform and decision: allowed, denied, or audited when an invalid request is admitted in audit mode",
+		}, []string{"resource", "platform", "decision"},
+	)
+
+	// WebhookAdmissionDenialsTotal is a Prometheus metric, which reports the number of validation errors denying requests
+	WebhookAdmissionDenialsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mapi_webhook_admission_denials_total",
+			Help: "Number of validation errors which denied a request, by resource, platform and validation rule",
+		}, []string{"resource", "platform", "rule"},
+	)
+
+	// WebhookAdmissionWarningsTotal is a Prometheus metric, which reports the number of warnings returned with admission responses
+	WebhookAdmissionWarningsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mapi_webhook_admission_warnings_total",
+			Help: "Number of warnings returned by the webhook with its admission responses, by resource and platform",
+		}, []string{"resource", "platform"},
+	)
+
+	// WebhookBuildInfo is a Prometheus metric with a constant '1' value, labeled by the webhook build
+	// and the hash of the rule set it currently validates with
+	WebhookBuildInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "mapi_webhook_build_info",
+			Help: "A metric with a constant '1' value labeled by the webhook version, commit and the hash of its active rule set",
+		}, []string{"version", "commit", "rule_set_hash"},
+	)
+
+	// webhookBuildInfoLabels are the labels of the WebhookBuildInfo series currently reported.
+	webhookBuildInfoLabels     prometheus.Labels
+	webhookBuildInfoLabelsLock sync.Mutex
+
+	// WebhookCacheCollector reports the age of every cached cluster input observed by the webhook.
+	WebhookCacheCollector = &webhookCacheCollector{
+		lastRefresh: map[string]time.Time{},
+		now:         time.Now,
+	}
+)
+
+func InitializeWebhookMetrics() {
+	metrics.Registry.MustRegister(
+		WebhookCacheRefreshFailuresTotal,
+		WebhookCacheCollector,
+		WebhookSecretsCacheLookupsTotal,
+		WebhookAuditDenialsTotal,
+		WebhookAdmissionDecisionsTotal,
+		WebhookAdmissionDenialsTotal,
+		WebhookAdmissionWarningsTotal,
+		WebhookBuildInfo,
+	)
+}
+
+// webhookCacheCollector is implementing prometheus.Collector interface.
+// The age is computed at collection time so that it keeps growing while refreshes are failing.
+type webhookCacheCollector struct {
+	lock        sync.Mutex
+	lastRefresh map[string]time.Time
+	now         func() time.Time
+}
+
+// Describe implements the prometheus.Collector interface.
+func (wc *webhookCacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- WebhookCacheResourceAgeDesc
+}
+
+// Collect implements the prometheus.Collector interface.
+func (wc *webhookCacheCollector) Collect(ch chan<- prometheus.Metric) {
+	wc.lock.Lock()
+	defer wc.lock.Unlock()
+
+	now := wc.now()
+	for resource, lastRefresh := range wc.lastRefresh {
+		ch <- prometheus.MustNewConstMetric(
+			WebhookCacheResourceAgeDesc,
+			prometheus.GaugeValue,
+			now.Sub(lastRefresh).Seconds(),
+			resource,
+		)
+	}
+}
+
+func ObserveWebhookCacheRefresh(resource string) {
+	WebhookCacheCollector.lock.Lock()
+	defer WebhookCacheCollector.lock.Unlock()
+
+	WebhookCacheCollector.lastRefresh[resource] = WebhookCacheCollector.now()
+}
+
+func ObserveWebhookCacheRefreshFailure(resource string) {
+	WebhookCacheRefreshFailuresTotal.With(prometheus.Labels{
+		"resource": resource,
+	}).Inc()
+}
+
+func ObserveWebhookSecretsCacheLookup(result string) {
+	WebhookSecretsCacheLookupsTotal.With(prometheus.Labels{
+		"result": result,
+	}).Inc()
+}
+
+func ObserveWebhookAuditDenial(rule string) {
+	WebhookAuditDenialsTotal.With(prometheus.Labels{
+		"rule": rule,
+	}).Inc()
+}
+
+func ObserveWebhookAdmissionDecision(resource, platform, decision string) {
+	WebhookAdmissionDecisionsTotal.With(prometheus.Labels{
+		"resource": resource,
+		"platform": platform,
+		"decision": decision,
+	}).Inc()
+}
+
+func ObserveWebhookAdmissionDenial(resource, platform, rule string) {
+	WebhookAdmissionDenialsTotal.With(prometheus.Labels{
+		"resource": resource,
+		"platform": platform,
+		"rule":     rule,
+	}).Inc()
+}
+
+func ObserveWebhookAdmissionWarnings(resource, platform string, warnings int) {
+	if warnings == 0 {
+		return
+	}
+	WebhookAdmissionWarningsTotal.With(prometheus.Labels{
+		"resource": resource,
+		"platform": platform,
+	}).Add(float64(warnings))
+}
+
+// ObserveWebhookBuildInfo reports the webhook build and active rule set, replacing the previously reported ones.
+func ObserveWebhookBuildInfo(version, commit, ruleSetHash string) {
+	webhookBuildInfoLabelsLock.Lock()
+	defer webhookBuildInfoLabelsLock.Unlock()
+
+	labels := prometheus.Labels{
+		"version":       version,
+		"commit":        commit,
+		"rule_set_hash": ruleSetHash,
+	}
+	if reflect.DeepEqual(labels, webhookBuildInfoLabels) {
+		return
+	}
+
+	WebhookBuildInfo.Reset()
+	WebhookBuildInfo.With(labels).Set(1)
+	webhookBuildInfoLabels = labels
+}
@@ -0,0 +1,178 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+)
+
+// component is a part of the machine API managed by the operator. Each component reports its own
+// <component>Degraded condition on the ClusterOperator, so that the Degraded condition tells which
+// components fail.
+type component string
+
+const (
+	componentMachineController            component = "MachineController"
+	componentMachineSetController         component = "MachineSetController"
+	componentMachineHealthCheckController component = "MachineHealthCheckController"
+	componentWebhooks                     component = "Webhooks"
+	componentTerminationHandler           component = "TerminationHandler"
+)
+
+// components are all the components, in the order of their conditions.
+var components = []component{
+	componentMachineController,
+	componentMachineSetController,
+	componentMachineHealthCheckController,
+	componentWebhooks,
+	componentTerminationHandler,
+}
+
+// controllersComponents are the components running in the machine-api-controllers Deployment.
+// The machineset-controller container serves the webhooks.
+var controllersComponents = []component{
+	componentMachineController,
+	componentMachineSetController,
+	componentMachineHealthCheckController,
+	componentWebhooks,
+}
+
+// containerComponents maps the containers of the machine-api-controllers Deployment to their components.
+var containerComponents = map[string][]component{
+	"machine-controller":              {componentMachineController},
+	"nodelink-controller":             {componentMachineController},
+	"kube-rbac-proxy-machine-mtrc":    {componentMachineController},
+	"machineset-controller":           {componentMachineSetController, componentWebhooks},
+	"kube-rbac-proxy-machineset-mtrc": {componentMachineSetController},
+	"machine-healthcheck-controller":  {componentMachineHealthCheckController},
+	"kube-rbac-proxy-mhc-mtrc":        {componentMachineHealthCheckController},
+}
+
+// containerFailureReasons are the waiting reasons of containers which do not recover without a change,
+// reported as the reason of the Degraded condition of their components.
+var containerFailureReasons = sets.NewString("CrashLoopBackOff", "ImagePullBackOff", "CreateContainerConfigError", "InvalidImageName")
+
+// conditionType returns the type of the Degraded condition of the component.
+func (c component) conditionType() osconfigv1.ClusterStatusConditionType {
+	return osconfigv1.ClusterStatusConditionType(string(c) + string(osconfigv1.OperatorDegraded))
+}
+
+// componentError is an error degrading components, with the reason of their Degraded conditions.
+type componentError struct {
+	components []component
+	reason     StatusReason
+	err        error
+}
+
+func (e *componentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *componentError) Unwrap() error {
+	return e.err
+}
+
+// newComponentError returns an error degrading the components for the reason.
+func newComponentError(reason StatusReason, err error, components ...component) error {
+	return &componentError{components: components, reason: reason, err: err}
+}
+
+// componentConditions returns the Degraded conditions of all components for the errors, and the reason of
+// the Degraded condition of the operator: the components and their reasons, eg. Webhooks_SyncingFailed,
+// separated by "::". Aggregates are flattened, errors which are not component errors degrade no component.
+func componentConditions(err error) ([]osconfigv1.ClusterOperatorStatusCondition, string) {
+	reasons := map[component]sets.String{}
+	messages := map[component][]string{}
+	for _, err := range flatten(err) {
+		var componentErr *componentError
+		if !errors.As(err, &componentErr) {
+			continue
+		}
+		for _, c := range componentErr.components {
+			if reasons[c] == nil {
+				reasons[c] = sets.NewString()
+			}
+			reasons[c].Insert(string(componentErr.reason))
+			messages[c] = append(messages[c], err.Error())
+		}
+	}
+
+	var conditions []osconfigv1.ClusterOperatorStatusCondition
+	var operatorReasons []string
+	for _, c := range components {
+		if reasons[c] == nil {
+			conditions = append(conditions, newClusterOperatorStatusCondition(c.conditionType(), osconfigv1.ConditionFalse, string(ReasonAsExpected), ""))
+			continue
+		}
+		reason := strings.Join(reasons[c].List(), "::")
+		conditions = append(conditions, newClusterOperatorStatusCondition(c.conditionType(), osconfigv1.ConditionTrue, reason, strings.Join(messages[c], ", ")))
+		for _, r := range reasons[c].List() {
+			operatorReasons = append(operatorReasons, fmt.Sprintf("%s_%s", c, r))
+		}
+	}
+	return conditions, strings.Join(operatorReasons, "::")
+}
+
+// flatten returns the errors of nested aggregates.
+func flatten(err error) []error {
+	if err == nil {
+		return nil
+	}
+	var aggregate utilerrors.Aggregate
+	if !errors.As(err, &aggregate) {
+		return []error{err}
+	}
+	var errs []error
+	for _, err := range aggregate.Errors() {
+		errs = append(errs, flatten(err)...)
+	}
+	return errs
+}
+
+// checkContainersStatus returns an error degrading the components of the containers of the pods of the Deployment
+// which wait for a reason they do not recover from without a change, eg. CrashLoopBackOff.
+func (optr *Operator) checkContainersStatus(d *appsv1.Deployment) error {
+	selector, err := metav1.LabelSelectorAsSelector(d.Spec.Selector)
+	if err != nil {
+		return err
+	}
+	pods, err := optr.kubeClient.CoreV1().Pods(d.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		// The status of the containers only tells which components fail, the rollout is waited for regardless.
+		klog.Warningf("Unable to list the pods of deployment %s: %v", d.Name, err)
+		return nil
+	}
+
+	failures := map[string]string{}
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			if waiting := status.State.Waiting; waiting != nil && containerFailureReasons.Has(waiting.Reason) {
+				failures[status.Name] = waiting.Reason
+			}
+		}
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+
+	var errs []error
+	containers := make([]string, 0, len(failures))
+	for name := range failures {
+		containers = append(containers, name)
+	}
+	sort.Strings(containers)
+	for _, name := range containers {
+		errs = append(errs, newComponentError(StatusReason(failures[name]),
+			fmt.Errorf("container %s of deployment %s is in %s", name, d.Name, failures[name]), containerComponents[name]...))
+	}
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,193 @@
+package operator
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	openshiftv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/library-go/pkg/config/clusteroperator/v1helpers"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+func TestComponentConditions(t *testing.T) {
+	testCases := []struct {
+		name               string
+		err                error
+		expectedDegraded   map[component]string
+		expectedReason     string
+		expectedMessageFor map[component]string
+	}{
+		{
+			name: "without error",
+		},
+		{
+			name: "with an error of no component",
+			err:  errors.New("failed"),
+		},
+		{
+			name: "with errors of several components",
+			err: utilerrors.NewAggregate([]error{
+				newComponentError(ReasonSyncFailed, errors.New("webhooks failed"), componentWebhooks),
+				utilerrors.NewAggregate([]error{
+					newComponentError(ReasonRolloutFailed, errors.New("termination handler failed"), componentTerminationHandler),
+					newComponentError(ReasonRolloutFailed, errors.New("controllers failed"), componentMachineController, componentWebhooks),
+				}),
+			}),
+			expectedDegraded: map[component]string{
+				componentMachineController:  "RolloutFailed",
+				componentWebhooks:           "RolloutFailed::SyncingFailed",
+				componentTerminationHandler: "RolloutFailed",
+			},
+			expectedReason: "MachineController_RolloutFailed::Webhooks_RolloutFailed::Webhooks_SyncingFailed::TerminationHandler_RolloutFailed",
+			expectedMessageFor: map[component]string{
+				componentWebhooks: "webhooks failed, controllers failed",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			conditions, reason := componentConditions(tc.err)
+			g.Expect(reason).To(Equal(tc.expectedReason))
+			g.Expect(conditions).To(HaveLen(len(components)))
+
+			for _, c := range components {
+				condition := v1helpers.FindStatusCondition(conditions, c.conditionType())
+				g.Expect(condition).ToNot(BeNil())
+
+				if expectedReason, ok := tc.expectedDegraded[c]; ok {
+					g.Expect(condition.Status).To(Equal(openshiftv1.ConditionTrue), string(c))
+					g.Expect(condition.Reason).To(Equal(expectedReason), string(c))
+				} else {
+					g.Expect(condition.Status).To(Equal(openshiftv1.ConditionFalse), string(c))
+					g.Expect(condition.Reason).To(Equal(string(ReasonAsExpected)), string(c))
+				}
+				if expectedMessage, ok := tc.expectedMessageFor[c]; ok {
+					g.Expect(condition.Message).To(Equal(expectedMessage), string(c))
+				}
+			}
+		})
+	}
+}
+
+func TestCheckContainersStatus(t *testing.T) {
+	config := &OperatorConfig{TargetNamespace: targetNamespace}
+	deployment := newDeployment(config, nil)
+
+	pod := func(name string, statuses ...corev1.ContainerStatus) runtime.Object {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: targetNamespace,
+				Labels:    deployment.Spec.Selector.MatchLabels,
+			},
+			Status: corev1.PodStatus{ContainerStatuses: statuses},
+		}
+	}
+	waiting := func(name, reason string) corev1.ContainerStatus {
+		return corev1.ContainerStatus{
+			Name:  name,
+			State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason}},
+		}
+	}
+	running := func(name string) corev1.ContainerStatus {
+		return corev1.ContainerStatus{
+			Name:  name,
+			State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+		}
+	}
+
+	testCases := []struct {
+		name             string
+		pods             []runtime.Object
+		expectedDegraded map[component]string
+	}{
+		{
+			name: "with running containers",
+			pods: []runtime.Object{
+				pod("controllers", running("machine-controller"), running("machineset-controller")),
+			},
+		},
+		{
+			name: "with a container starting",
+			pods: []runtime.Object{
+				pod("controllers", waiting("machine-controller", "ContainerCreating")),
+			},
+		},
+		{
+			name: "with crash looping containers",
+			pods: []runtime.Object{
+				pod("controllers", running("machine-controller"), waiting("machineset-controller", "CrashLoopBackOff")),
+				pod("controllers-2", waiting("machine-healthcheck-controller", "ImagePullBackOff")),
+			},
+			expectedDegraded: map[component]string{
+				componentMachineSetController:         "CrashLoopBackOff",
+				componentWebhooks:                     "CrashLoopBackOff",
+				componentMachineHealthCheckController: "ImagePullBackOff",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			optr := newFakeOperator(tc.pods, nil, make(<-chan struct{}))
+			err := optr.checkContainersStatus(deployment)
+			if len(tc.expectedDegraded) == 0 {
+				g.Expect(err).ToNot(HaveOccurred())
+				return
+			}
+			g.Expect(err).To(HaveOccurred())
+
+			conditions, _ := componentConditions(err)
+			for _, c := range components {
+				condition := v1helpers.FindStatusCondition(conditions, c.conditionType())
+				if expectedReason, ok := tc.expectedDegraded[c]; ok {
+					g.Expect(condition.Status).To(Equal(openshiftv1.ConditionTrue), string(c))
+					g.Expect(condition.Reason).To(Equal(expectedReason), string(c))
+				} else {
+					g.Expect(condition.Status).To(Equal(openshiftv1.ConditionFalse), string(c))
+				}
+			}
+		})
+	}
+}
+
+func TestStatusDegradedComponents(t *testing.T) {
+	g := NewWithT(t)
+
+	optr := newFakeOperator(nil, nil, make(<-chan struct{}))
+	syncErr := utilerrors.NewAggregate([]error{
+		newComponentError(ReasonSyncFailed, errors.New("Error syncing termination handler: failed"), componentTerminationHandler),
+	})
+	g.Expect(optr.statusDegraded(syncErr)).To(Succeed())
+
+	co, err := optr.getClusterOperator()
+	g.Expect(err).ToNot(HaveOccurred())
+
+	degraded := v1helpers.FindStatusCondition(co.Status.Conditions, openshiftv1.OperatorDegraded)
+	g.Expect(degraded.Status).To(Equal(openshiftv1.ConditionTrue))
+	g.Expect(degraded.Reason).To(Equal("TerminationHandler_SyncingFailed"))
+
+	terminationHandlerDegraded := v1helpers.FindStatusCondition(co.Status.Conditions, componentTerminationHandler.conditionType())
+	g.Expect(terminationHandlerDegraded.Status).To(Equal(openshiftv1.ConditionTrue))
+	g.Expect(terminationHandlerDegraded.Reason).To(Equal(string(ReasonSyncFailed)))
+	g.Expect(terminationHandlerDegraded.Message).To(Equal("Error syncing termination handler: failed"))
+
+	webhooksDegraded := v1helpers.FindStatusCondition(co.Status.Conditions, componentWebhooks.conditionType())
+	g.Expect(webhooksDegraded.Status).To(Equal(openshiftv1.ConditionFalse))
+
+	// Once available, no component is degraded.
+	g.Expect(optr.statusAvailable("available")).To(Succeed())
+	co, err = optr.getClusterOperator()
+	g.Expect(err).ToNot(HaveOccurred())
+	terminationHandlerDegraded = v1helpers.FindStatusCondition(co.Status.Conditions, componentTerminationHandler.conditionType())
+	g.Expect(terminationHandlerDegraded.Status).To(Equal(openshiftv1.ConditionFalse))
+}
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 
 	configv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/machine-api-operator/pkg/util"
 )
 
 const (
@@ -21,8 +22,15 @@ type Provider string
 // OperatorConfig contains configuration for MAO
 type OperatorConfig struct {
 	TargetNamespace string `json:"targetNamespace"`
+	PlatformType    configv1.PlatformType
 	Controllers     Controllers
 	Proxy           *configv1.Proxy
+	// ControllersReplicas is the number of machine-api-controllers replicas,
+	// which serve the machine API webhooks.
+	ControllersReplicas int32
+	// MachineControllerConcurrency are the concurrency options of the machine controller, only passed on
+	// to the machine controllers built from this repository.
+	MachineControllerConcurrency util.ConcurrencyOptions
 }
 
 type Controllers struct {
@@ -59,6 +67,16 @@ func getProviderFromInfrastructure(infra *configv1.Infrastructure) (configv1.Pla
 	return "", fmt.Errorf("no platform provider found on install config")
 }
 
+// getControllersReplicasFromInfrastructure returns the number of machine-api-controllers replicas.
+// The controllers run on the control plane, so a single replica is used when the control plane
+// is a single node, and two otherwise so that the webhooks stay available while a pod restarts.
+func getControllersReplicasFromInfrastructure(infra *configv1.Infrastructure) int32 {
+	if infra.Status.ControlPlaneTopology == configv1.SingleReplicaTopologyMode {
+		return 1
+	}
+	return 2
+}
+
 func getImagesFromJSONFile(filePath string) (*Images, error) {
 	data, err := ioutil.ReadFile(filepath.Clean(filePath))
 	if err != nil {
@@ -102,16 +120,13 @@ func getProviderControllerFromImages(platform configv1.PlatformType, images Imag
 }
 
 // getTerminationHandlerFromImages returns the image to use for the Termination Handler DaemonSet
-// based on the platform provided.
+// based on the platform provided. The termination handler watches the termination notices of
+// AWS spot, GCP preemptible and Azure spot instances.
 // Defaults to NoOp if not supported by the platform.
 func getTerminationHandlerFromImages(platform configv1.PlatformType, images Images) (string, error) {
 	switch platform {
-	case configv1.AWSPlatformType:
-		return images.ClusterAPIControllerAWS, nil
-	case configv1.GCPPlatformType:
-		return images.ClusterAPIControllerGCP, nil
-	case configv1.AzurePlatformType:
-		return images.ClusterAPIControllerAzure, nil
+	case configv1.AWSPlatformType, configv1.GCPPlatformType, configv1.AzurePlatformType:
+		return images.MachineAPIOperator, nil
 	default:
 		return clusterAPIControllerNoOp, nil
 	}
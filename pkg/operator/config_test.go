@@ -251,7 +251,7 @@ func TestGetTerminationHandlerFromImages(t *testing.T) {
 		expectedImage string
 	}{{
 		provider:      configv1.AWSPlatformType,
-		expectedImage: expectedAWSImage,
+		expectedImage: expectedMachineAPIOperatorImage,
 	},
 		{
 			provider:      configv1.LibvirtPlatformType,
@@ -267,11 +267,11 @@ func TestGetTerminationHandlerFromImages(t *testing.T) {
 		},
 		{
 			provider:      configv1.AzurePlatformType,
-			expectedImage: expectedAzureImage,
+			expectedImage: expectedMachineAPIOperatorImage,
 		},
 		{
 			provider:      configv1.GCPPlatformType,
-			expectedImage: expectedGCPImage,
+			expectedImage: expectedMachineAPIOperatorImage,
 		},
 		{
 			provider:      kubemarkPlatform,
@@ -0,0 +1,103 @@
+package operator
+
+import (
+	"context"
+
+	configv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	mapiwebhooks "github.com/openshift/machine-api-operator/pkg/webhooks"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+)
+
+// controlPlaneNodeLabel selects the control plane nodes, which the webhooks run on.
+const controlPlaneNodeLabel = "node-role.kubernetes.io/master"
+
+// syncEffectiveMachineDefaults publishes the values the defaulting webhooks set on unset providerSpec fields,
+// with the defaults overrides ConfigMap applied. The webhooks run on the control plane nodes, so they default
+// for the architecture of these nodes.
+// The published defaults are left as is while the overrides fail to parse, which is reported by
+// syncWebhookPolicySnapshot, or while no control plane node reports its architecture.
+func (optr *Operator) syncEffectiveMachineDefaults(config *OperatorConfig) error {
+	overrides, err := optr.kubeClient.CoreV1().ConfigMaps(config.TargetNamespace).Get(context.TODO(), mapiwebhooks.DefaultsOverridesConfigMapName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		overrides = nil
+	} else if err != nil {
+		return err
+	}
+
+	arch, err := optr.controlPlaneArchitecture()
+	if err != nil {
+		return err
+	}
+	if arch == "" {
+		klog.V(3).Infof("Not publishing effective machine defaults: no control plane node reports its architecture")
+		return nil
+	}
+
+	effectiveDefaults, err := newEffectiveMachineDefaults(config.TargetNamespace, config.PlatformType, arch, overrides)
+	if err != nil {
+		klog.V(3).Infof("Not publishing effective machine defaults: %v", err)
+		return nil
+	}
+
+	_, _, err = resourceapply.ApplyConfigMap(context.TODO(), optr.kubeClient.CoreV1(),
+		events.NewLoggingEventRecorder(optr.name), effectiveDefaults)
+	return err
+}
+
+// controlPlaneArchitecture returns the architecture of the control plane nodes, from their kubernetes.io/arch
+// label. The architecture of most nodes is returned when they differ, the first one in order on a tie, and
+// none when no node has the label.
+func (optr *Operator) controlPlaneArchitecture() (string, error) {
+	nodes, err := optr.kubeClient.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{LabelSelector: controlPlaneNodeLabel})
+	if err != nil {
+		return "", err
+	}
+
+	counts := map[string]int{}
+	for _, node := range nodes.Items {
+		if arch := node.Labels[corev1.LabelArchStable]; arch != "" {
+			counts[arch]++
+		}
+	}
+
+	arch := ""
+	for _, candidate := range sets.StringKeySet(counts).List() {
+		if counts[candidate] > counts[arch] {
+			arch = candidate
+		}
+	}
+	return arch, nil
+}
+
+// newEffectiveMachineDefaults returns the effective machine defaults ConfigMap for the platform and architecture.
+// overrides may be nil.
+func newEffectiveMachineDefaults(namespace string, platform configv1.PlatformType, arch string, overrides *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+	defaults, err := mapiwebhooks.EffectiveMachineDefaults(platform, arch, overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	generation := ""
+	if overrides != nil {
+		generation = overrides.ResourceVersion
+	}
+
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      mapiwebhooks.EffectiveDefaultsConfigMapName,
+			Namespace: namespace,
+			Annotations: map[string]string{
+				mapiwebhooks.EffectiveDefaultsGenerationAnnotation:   generation,
+				mapiwebhooks.EffectiveDefaultsPlatformAnnotation:     string(platform),
+				mapiwebhooks.EffectiveDefaultsArchitectureAnnotation: arch,
+			},
+		},
+		Data: defaults,
+	}, nil
+}
@@ -0,0 +1,176 @@
+package operator
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	openshiftv1 "github.com/openshift/api/config/v1"
+	mapiwebhooks "github.com/openshift/machine-api-operator/pkg/webhooks"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kruntime "k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestSyncEffectiveMachineDefaults(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	controlPlaneNode := func(name, arch string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{controlPlaneNodeLabel: "", corev1.LabelArchStable: arch},
+			},
+		}
+	}
+	worker := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "worker",
+			Labels: map[string]string{corev1.LabelArchStable: "amd64"},
+		},
+	}
+	optr := newFakeOperator([]kruntime.Object{controlPlaneNode("master-0", "arm64"), controlPlaneNode("master-1", "arm64"), controlPlaneNode("master-2", "amd64"), worker}, nil, stopCh)
+	config := &OperatorConfig{
+		TargetNamespace: targetNamespace,
+		PlatformType:    openshiftv1.GCPPlatformType,
+	}
+
+	getEffectiveDefaults := func() *corev1.ConfigMap {
+		if err := optr.syncEffectiveMachineDefaults(config); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		effectiveDefaults, err := optr.kubeClient.CoreV1().ConfigMaps(targetNamespace).Get(context.Background(), mapiwebhooks.EffectiveDefaultsConfigMapName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("failed to get effective machine defaults: %v", err)
+		}
+		return effectiveDefaults
+	}
+
+	builtin, err := mapiwebhooks.EffectiveMachineDefaults(openshiftv1.GCPPlatformType, "arm64", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	effectiveDefaults := getEffectiveDefaults()
+	if !reflect.DeepEqual(effectiveDefaults.Data, builtin) {
+		t.Errorf("expected the built-in defaults %v, got: %v", builtin, effectiveDefaults.Data)
+	}
+	if generation := effectiveDefaults.Annotations[mapiwebhooks.EffectiveDefaultsGenerationAnnotation]; generation != "" {
+		t.Errorf("expected no generation without overrides, got: %q", generation)
+	}
+	if platform := effectiveDefaults.Annotations[mapiwebhooks.EffectiveDefaultsPlatformAnnotation]; platform != string(openshiftv1.GCPPlatformType) {
+		t.Errorf("expected platform %q, got: %q", openshiftv1.GCPPlatformType, platform)
+	}
+	if arch := effectiveDefaults.Annotations[mapiwebhooks.EffectiveDefaultsArchitectureAnnotation]; arch != "arm64" {
+		t.Errorf("expected the architecture of most control plane nodes %q, got: %q", "arm64", arch)
+	}
+
+	overrides := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            mapiwebhooks.DefaultsOverridesConfigMapName,
+			Namespace:       targetNamespace,
+			ResourceVersion: "1",
+		},
+		Data: map[string]string{"machineType": "n2-standard-4"},
+	}
+	overrides, err = optr.kubeClient.CoreV1().ConfigMaps(targetNamespace).Create(context.Background(), overrides, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("failed to create defaults overrides: %v", err)
+	}
+
+	effectiveDefaults = getEffectiveDefaults()
+	if machineType := effectiveDefaults.Data["machineType"]; machineType != "n2-standard-4" {
+		t.Errorf("expected the overridden machine type n2-standard-4, got: %q", machineType)
+	}
+	if generation := effectiveDefaults.Annotations[mapiwebhooks.EffectiveDefaultsGenerationAnnotation]; generation != "1" {
+		t.Errorf("expected generation %q, got: %q", "1", generation)
+	}
+
+	overrides.Data["machineType"] = "n2-standard-8"
+	overrides.ResourceVersion = "2"
+	if _, err := optr.kubeClient.CoreV1().ConfigMaps(targetNamespace).Update(context.Background(), overrides, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update defaults overrides: %v", err)
+	}
+
+	effectiveDefaults = getEffectiveDefaults()
+	if machineType := effectiveDefaults.Data["machineType"]; machineType != "n2-standard-8" {
+		t.Errorf("expected the updated machine type n2-standard-8, got: %q", machineType)
+	}
+	if generation := effectiveDefaults.Annotations[mapiwebhooks.EffectiveDefaultsGenerationAnnotation]; generation != "2" {
+		t.Errorf("expected generation %q, got: %q", "2", generation)
+	}
+
+	overrides.Data["disks.sizeGb"] = "large"
+	overrides.ResourceVersion = "3"
+	if _, err := optr.kubeClient.CoreV1().ConfigMaps(targetNamespace).Update(context.Background(), overrides, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update defaults overrides: %v", err)
+	}
+
+	effectiveDefaults = getEffectiveDefaults()
+	if generation := effectiveDefaults.Annotations[mapiwebhooks.EffectiveDefaultsGenerationAnnotation]; generation != "2" {
+		t.Errorf("expected invalid overrides to leave generation %q published, got: %q", "2", generation)
+	}
+}
+
+func TestIsWebhookPolicyConfigMap(t *testing.T) {
+	testCases := []struct {
+		name     string
+		obj      kruntime.Object
+		expected bool
+	}{
+		{
+			name:     "defaults overrides",
+			obj:      &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: mapiwebhooks.DefaultsOverridesConfigMapName}},
+			expected: true,
+		},
+		{
+			name:     "validation policy",
+			obj:      &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: webhookPolicyConfigMapName}},
+			expected: true,
+		},
+		{
+			name:     "effective machine defaults",
+			obj:      &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: mapiwebhooks.EffectiveDefaultsConfigMapName}},
+			expected: false,
+		},
+		{
+			name:     "secret",
+			obj:      &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: mapiwebhooks.DefaultsOverridesConfigMapName}},
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isWebhookPolicyConfigMap(tc.obj); got != tc.expected {
+				t.Errorf("expected %v, got: %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestSyncEffectiveMachineDefaultsWithoutArchitecture(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	worker := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "worker",
+			Labels: map[string]string{corev1.LabelArchStable: "amd64"},
+		},
+	}
+	optr := newFakeOperator([]kruntime.Object{worker}, nil, stopCh)
+	config := &OperatorConfig{
+		TargetNamespace: targetNamespace,
+		PlatformType:    openshiftv1.GCPPlatformType,
+	}
+
+	if err := optr.syncEffectiveMachineDefaults(config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := optr.kubeClient.CoreV1().ConfigMaps(targetNamespace).Get(context.Background(), mapiwebhooks.EffectiveDefaultsConfigMapName, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected no effective machine defaults without the control plane architecture, got: %v", err)
+	}
+}
@@ -11,13 +11,17 @@ import (
 	osclientset "github.com/openshift/client-go/config/clientset/versioned"
 	configinformersv1 "github.com/openshift/client-go/config/informers/externalversions/config/v1"
 	configlistersv1 "github.com/openshift/client-go/config/listers/config/v1"
+	"github.com/openshift/machine-api-operator/pkg/util"
+	mapiwebhooks "github.com/openshift/machine-api-operator/pkg/webhooks"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/dynamic"
 	admissioninformersv1 "k8s.io/client-go/informers/admissionregistration/v1"
 	appsinformersv1 "k8s.io/client-go/informers/apps/v1"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
 	admissionlisterv1 "k8s.io/client-go/listers/admissionregistration/v1"
 	appslisterv1 "k8s.io/client-go/listers/apps/v1"
@@ -45,6 +49,17 @@ type Operator struct {
 	imagesFile string
 	config     string
 
+	// controllersReplicas overrides the number of machine-api-controllers replicas when set.
+	controllersReplicas int32
+	// manageWebhookCerts is set when the operator manages the webhook certificates,
+	// rather than the service CA operator.
+	manageWebhookCerts bool
+	// webhookFailurePolicies are the failure policies of the machine API webhooks by platform,
+	// Ignore on the platforms without one.
+	webhookFailurePolicies map[osconfigv1.PlatformType]admissionregistrationv1.FailurePolicyType
+	// machineControllerConcurrency are the concurrency options passed on to the machine controller.
+	machineControllerConcurrency util.ConcurrencyOptions
+
 	kubeClient    kubernetes.Interface
 	osClient      osclientset.Interface
 	dynamicClient dynamic.Interface
@@ -83,6 +98,10 @@ func New(
 	imagesFile string,
 
 	config string,
+	controllersReplicas int32,
+	manageWebhookCerts bool,
+	webhookFailurePolicies map[osconfigv1.PlatformType]admissionregistrationv1.FailurePolicyType,
+	machineControllerConcurrency util.ConcurrencyOptions,
 
 	deployInformer appsinformersv1.DeploymentInformer,
 	daemonsetInformer appsinformersv1.DaemonSetInformer,
@@ -90,6 +109,7 @@ func New(
 	validatingWebhookInformer admissioninformersv1.ValidatingWebhookConfigurationInformer,
 	mutatingWebhookInformer admissioninformersv1.MutatingWebhookConfigurationInformer,
 	proxyInformer configinformersv1.ProxyInformer,
+	configMapInformer coreinformersv1.ConfigMapInformer,
 	kubeClient kubernetes.Interface,
 	osClient osclientset.Interface,
 	dynamicClient dynamic.Interface,
@@ -120,8 +140,16 @@ func New(
 	validatingWebhookInformer.Informer().AddEventHandler(optr.eventHandlerSingleton(isMachineWebhook))
 	mutatingWebhookInformer.Informer().AddEventHandler(optr.eventHandlerSingleton(isMachineWebhook))
 	featureGateInformer.Informer().AddEventHandler(optr.eventHandler())
+	configMapInformer.Informer().AddEventHandler(optr.eventHandlerSingleton(isWebhookPolicyConfigMap))
+	configMapInformer.Informer().AddEventHandler(optr.eventHandlerSingleton(isTrustedCAConfigMap))
+	// Proxy changes are rolled out to the workloads of the operator.
+	proxyInformer.Informer().AddEventHandler(optr.eventHandler())
 
 	optr.config = config
+	optr.controllersReplicas = controllersReplicas
+	optr.manageWebhookCerts = manageWebhookCerts
+	optr.webhookFailurePolicies = webhookFailurePolicies
+	optr.machineControllerConcurrency = machineControllerConcurrency
 	optr.syncHandler = optr.sync
 
 	optr.deployLister = deployInformer.Lister()
@@ -272,6 +300,24 @@ func isMachineWebhook(obj interface{}) bool {
 	return false
 }
 
+// isWebhookPolicyConfigMap returns true for the ConfigMaps configuring the webhooks,
+// which the webhook policy snapshot and the effective machine defaults are generated from.
+func isWebhookPolicyConfigMap(obj interface{}) bool {
+	configMap, ok := obj.(*corev1.ConfigMap)
+	if !ok {
+		return false
+	}
+
+	_, ok = mapiwebhooks.PolicyConfigMaps[configMap.Name]
+	return ok
+}
+
+// isTrustedCAConfigMap returns true for the ConfigMap with the trusted CA bundle of the cluster-wide Proxy.
+func isTrustedCAConfigMap(obj interface{}) bool {
+	configMap, ok := obj.(*corev1.ConfigMap)
+	return ok && configMap.Name == externalTrustBundleConfigMapName
+}
+
 func (optr *Operator) worker() {
 	for optr.processNextWorkItem() {
 	}
@@ -373,9 +419,17 @@ func (optr *Operator) maoConfigFromInfrastructure() (*OperatorConfig, error) {
 		return nil, err
 	}
 
+	controllersReplicas := optr.controllersReplicas
+	if controllersReplicas <= 0 {
+		controllersReplicas = getControllersReplicasFromInfrastructure(infra)
+	}
+
 	return &OperatorConfig{
-		TargetNamespace: optr.namespace,
-		Proxy:           clusterWideProxy,
+		TargetNamespace:              optr.namespace,
+		PlatformType:                 provider,
+		Proxy:                        clusterWideProxy,
+		ControllersReplicas:          controllersReplicas,
+		MachineControllerConcurrency: optr.machineControllerConcurrency,
 		Controllers: Controllers{
 			Provider:           providerControllerImage,
 			MachineSet:         machineAPIOperatorImage,
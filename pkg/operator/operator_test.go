@@ -196,6 +196,10 @@ func TestOperatorSync_NoOp(t *testing.T) {
 					openshiftv1.OperatorDegraded:    openshiftv1.ConditionFalse,
 					openshiftv1.OperatorUpgradeable: openshiftv1.ConditionTrue,
 				}
+				// No component is degraded.
+				for _, c := range components {
+					expectedConditions[c.conditionType()] = openshiftv1.ConditionFalse
+				}
 
 			} else {
 				// If this wasn't a no-op, we expect to be progressing towards
@@ -311,14 +315,16 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 			infra:    infra,
 			proxy:    proxy,
 			expectedConfig: &OperatorConfig{
-				TargetNamespace: targetNamespace,
-				Proxy:           proxy,
+				TargetNamespace:     targetNamespace,
+				PlatformType:        openshiftv1.AWSPlatformType,
+				Proxy:               proxy,
+				ControllersReplicas: 2,
 				Controllers: Controllers{
 					Provider:           images.ClusterAPIControllerAWS,
 					MachineSet:         images.MachineAPIOperator,
 					NodeLink:           images.MachineAPIOperator,
 					MachineHealthCheck: images.MachineAPIOperator,
-					TerminationHandler: images.ClusterAPIControllerAWS,
+					TerminationHandler: images.MachineAPIOperator,
 					KubeRBACProxy:      images.KubeRBACProxy,
 				},
 			},
@@ -329,8 +335,10 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 			infra:    infra,
 			proxy:    proxy,
 			expectedConfig: &OperatorConfig{
-				TargetNamespace: targetNamespace,
-				Proxy:           proxy,
+				TargetNamespace:     targetNamespace,
+				PlatformType:        openshiftv1.LibvirtPlatformType,
+				Proxy:               proxy,
+				ControllersReplicas: 2,
 				Controllers: Controllers{
 					Provider:           images.ClusterAPIControllerLibvirt,
 					MachineSet:         images.MachineAPIOperator,
@@ -347,8 +355,10 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 			infra:    infra,
 			proxy:    proxy,
 			expectedConfig: &OperatorConfig{
-				TargetNamespace: targetNamespace,
-				Proxy:           proxy,
+				TargetNamespace:     targetNamespace,
+				PlatformType:        openshiftv1.OpenStackPlatformType,
+				Proxy:               proxy,
+				ControllersReplicas: 2,
 				Controllers: Controllers{
 					Provider:           images.ClusterAPIControllerOpenStack,
 					MachineSet:         images.MachineAPIOperator,
@@ -365,14 +375,16 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 			infra:    infra,
 			proxy:    proxy,
 			expectedConfig: &OperatorConfig{
-				TargetNamespace: targetNamespace,
-				Proxy:           proxy,
+				TargetNamespace:     targetNamespace,
+				PlatformType:        openshiftv1.AzurePlatformType,
+				Proxy:               proxy,
+				ControllersReplicas: 2,
 				Controllers: Controllers{
 					Provider:           images.ClusterAPIControllerAzure,
 					MachineSet:         images.MachineAPIOperator,
 					NodeLink:           images.MachineAPIOperator,
 					MachineHealthCheck: images.MachineAPIOperator,
-					TerminationHandler: images.ClusterAPIControllerAzure,
+					TerminationHandler: images.MachineAPIOperator,
 					KubeRBACProxy:      images.KubeRBACProxy,
 				},
 			},
@@ -383,8 +395,10 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 			infra:    infra,
 			proxy:    proxy,
 			expectedConfig: &OperatorConfig{
-				TargetNamespace: targetNamespace,
-				Proxy:           proxy,
+				TargetNamespace:     targetNamespace,
+				PlatformType:        openshiftv1.BareMetalPlatformType,
+				Proxy:               proxy,
+				ControllersReplicas: 2,
 				Controllers: Controllers{
 					Provider:           images.ClusterAPIControllerBareMetal,
 					MachineSet:         images.MachineAPIOperator,
@@ -401,14 +415,16 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 			infra:    infra,
 			proxy:    proxy,
 			expectedConfig: &OperatorConfig{
-				TargetNamespace: targetNamespace,
-				Proxy:           proxy,
+				TargetNamespace:     targetNamespace,
+				PlatformType:        openshiftv1.GCPPlatformType,
+				Proxy:               proxy,
+				ControllersReplicas: 2,
 				Controllers: Controllers{
 					Provider:           images.ClusterAPIControllerGCP,
 					MachineSet:         images.MachineAPIOperator,
 					NodeLink:           images.MachineAPIOperator,
 					MachineHealthCheck: images.MachineAPIOperator,
-					TerminationHandler: images.ClusterAPIControllerGCP,
+					TerminationHandler: images.MachineAPIOperator,
 					KubeRBACProxy:      images.KubeRBACProxy,
 				},
 			},
@@ -419,8 +435,10 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 			infra:    infra,
 			proxy:    proxy,
 			expectedConfig: &OperatorConfig{
-				TargetNamespace: targetNamespace,
-				Proxy:           proxy,
+				TargetNamespace:     targetNamespace,
+				PlatformType:        kubemarkPlatform,
+				Proxy:               proxy,
+				ControllersReplicas: 2,
 				Controllers: Controllers{
 					Provider:           clusterAPIControllerKubemark,
 					MachineSet:         images.MachineAPIOperator,
@@ -437,8 +455,10 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 			infra:    infra,
 			proxy:    proxy,
 			expectedConfig: &OperatorConfig{
-				TargetNamespace: targetNamespace,
-				Proxy:           proxy,
+				TargetNamespace:     targetNamespace,
+				PlatformType:        openshiftv1.VSpherePlatformType,
+				Proxy:               proxy,
+				ControllersReplicas: 2,
 				Controllers: Controllers{
 					Provider:           images.ClusterAPIControllerVSphere,
 					MachineSet:         images.MachineAPIOperator,
@@ -455,8 +475,10 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 			infra:    infra,
 			proxy:    proxy,
 			expectedConfig: &OperatorConfig{
-				TargetNamespace: targetNamespace,
-				Proxy:           proxy,
+				TargetNamespace:     targetNamespace,
+				PlatformType:        openshiftv1.OvirtPlatformType,
+				Proxy:               proxy,
+				ControllersReplicas: 2,
 				Controllers: Controllers{
 					Provider:           images.ClusterAPIControllerOvirt,
 					MachineSet:         images.MachineAPIOperator,
@@ -473,8 +495,10 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 			infra:    infra,
 			proxy:    proxy,
 			expectedConfig: &OperatorConfig{
-				TargetNamespace: targetNamespace,
-				Proxy:           proxy,
+				TargetNamespace:     targetNamespace,
+				PlatformType:        openshiftv1.NonePlatformType,
+				Proxy:               proxy,
+				ControllersReplicas: 2,
 				Controllers: Controllers{
 					Provider:           clusterAPIControllerNoOp,
 					MachineSet:         images.MachineAPIOperator,
@@ -491,8 +515,10 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 			infra:    infra,
 			proxy:    proxy,
 			expectedConfig: &OperatorConfig{
-				TargetNamespace: targetNamespace,
-				Proxy:           proxy,
+				TargetNamespace:     targetNamespace,
+				PlatformType:        "bad-platform",
+				Proxy:               proxy,
+				ControllersReplicas: 2,
 				Controllers: Controllers{
 					Provider:           clusterAPIControllerNoOp,
 					MachineSet:         images.MachineAPIOperator,
@@ -575,3 +601,51 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 		})
 	}
 }
+
+func TestMAOConfigControllersReplicas(t *testing.T) {
+	testCases := []struct {
+		name                string
+		topology            openshiftv1.TopologyMode
+		controllersReplicas int32
+		expectedReplicas    int32
+	}{
+		{
+			name:             "highly available control plane",
+			topology:         openshiftv1.HighlyAvailableTopologyMode,
+			expectedReplicas: 2,
+		},
+		{
+			name:             "single node control plane",
+			topology:         openshiftv1.SingleReplicaTopologyMode,
+			expectedReplicas: 1,
+		},
+		{
+			name:                "replicas set explicitly",
+			topology:            openshiftv1.SingleReplicaTopologyMode,
+			controllersReplicas: 3,
+			expectedReplicas:    3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			infra := &openshiftv1.Infrastructure{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+				Status: openshiftv1.InfrastructureStatus{
+					PlatformStatus:       &openshiftv1.PlatformStatus{Type: openshiftv1.AWSPlatformType},
+					ControlPlaneTopology: tc.topology,
+				},
+			}
+			proxy := &openshiftv1.Proxy{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}
+
+			optr := newFakeOperator(nil, []runtime.Object{infra, proxy}, make(<-chan struct{}))
+			optr.controllersReplicas = tc.controllersReplicas
+
+			config, err := optr.maoConfigFromInfrastructure()
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(config.ControllersReplicas).To(Equal(tc.expectedReplicas))
+		})
+	}
+}
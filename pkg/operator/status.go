@@ -21,10 +21,11 @@ type StatusReason string
 
 // The default set of status change reasons.
 const (
-	ReasonAsExpected   StatusReason = "AsExpected"
-	ReasonInitializing StatusReason = "Initializing"
-	ReasonSyncing      StatusReason = "SyncingResources"
-	ReasonSyncFailed   StatusReason = "SyncingFailed"
+	ReasonAsExpected    StatusReason = "AsExpected"
+	ReasonInitializing  StatusReason = "Initializing"
+	ReasonSyncing       StatusReason = "SyncingResources"
+	ReasonSyncFailed    StatusReason = "SyncingFailed"
+	ReasonRolloutFailed StatusReason = "RolloutFailed"
 )
 
 const (
@@ -88,6 +89,8 @@ func (optr *Operator) statusAvailable(message string) error {
 		newClusterOperatorStatusCondition(osconfigv1.OperatorDegraded, osconfigv1.ConditionFalse, string(ReasonAsExpected), ""),
 		operatorUpgradeable,
 	}
+	componentConds, _ := componentConditions(nil)
+	conds = append(conds, componentConds...)
 
 	co, err := optr.getOrCreateClusterOperator()
 	if err != nil {
@@ -103,32 +106,40 @@ func (optr *Operator) statusAvailable(message string) error {
 // statusDegraded sets the Degraded condition to True, with the given reason and
 // message, and sets the upgradeable condition.  It does not modify any existing
 // Available or Progressing conditions.
-func (optr *Operator) statusDegraded(error string) error {
+// The components degraded by the error report their own Degraded condition, and the reason of the
+// Degraded condition names them.
+func (optr *Operator) statusDegraded(err error) error {
 	desiredVersions := optr.operandVersions
-	currentVersions, err := optr.getCurrentVersions()
-	if err != nil {
-		klog.Errorf("Error getting current versions: %v", err)
-		return err
+	currentVersions, getErr := optr.getCurrentVersions()
+	if getErr != nil {
+		klog.Errorf("Error getting current versions: %v", getErr)
+		return getErr
 	}
 
 	var message string
 	if !reflect.DeepEqual(desiredVersions, currentVersions) {
-		message = fmt.Sprintf("Failed when progressing towards %s because %s", optr.printOperandVersions(), error)
+		message = fmt.Sprintf("Failed when progressing towards %s because %s", optr.printOperandVersions(), err)
 	} else {
-		message = fmt.Sprintf("Failed to resync for %s because %s", optr.printOperandVersions(), error)
+		message = fmt.Sprintf("Failed to resync for %s because %s", optr.printOperandVersions(), err)
+	}
+
+	componentConds, reason := componentConditions(err)
+	if reason == "" {
+		reason = string(ReasonSyncFailed)
 	}
 
 	conds := []osconfigv1.ClusterOperatorStatusCondition{
 		newClusterOperatorStatusCondition(osconfigv1.OperatorDegraded, osconfigv1.ConditionTrue,
-			string(ReasonSyncFailed), message),
+			reason, message),
 		operatorUpgradeable,
 	}
+	conds = append(conds, componentConds...)
 
-	co, err := optr.getOrCreateClusterOperator()
-	if err != nil {
-		return err
+	co, getErr := optr.getOrCreateClusterOperator()
+	if getErr != nil {
+		return getErr
 	}
-	optr.eventRecorder.Eventf(co, v1.EventTypeWarning, "Status degraded", error)
+	optr.eventRecorder.Eventf(co, v1.EventTypeWarning, "Status degraded", err.Error())
 	klog.V(2).Info("Syncing status: degraded")
 	return optr.syncStatus(co, conds)
 }
@@ -3,8 +3,10 @@ package operator
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
+	configv1 "github.com/openshift/api/config/v1"
 	"github.com/openshift/library-go/pkg/operator/events"
 	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
 	"github.com/openshift/library-go/pkg/operator/resource/resourcehash"
@@ -13,8 +15,10 @@ import (
 	"github.com/openshift/machine-api-operator/pkg/metrics"
 	"github.com/openshift/machine-api-operator/pkg/util/conditions"
 	mapiwebhooks "github.com/openshift/machine-api-operator/pkg/webhooks"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -29,6 +33,7 @@ const (
 	checkStatusRequeuePeriod            = 5 * time.Second
 	deploymentMinimumAvailabilityTime   = 3 * time.Minute
 	machineAPITerminationHandler        = "machine-api-termination-handler"
+	machineAPIControllers               = "machine-api-controllers"
 	machineExposeMetricsPort            = 8441
 	machineSetExposeMetricsPort         = 8442
 	machineHealthCheckExposeMetricsPort = 8444
@@ -38,6 +43,8 @@ const (
 	kubeRBACConfigName                  = "config"
 	certStoreName                       = "machine-api-controllers-tls"
 	externalTrustBundleConfigMapName    = "mao-trusted-ca"
+	trustedCAVolumeName                 = "trusted-ca"
+	trustedCAMountPath                  = "/etc/pki/ca-trust/extracted/pem"
 	hostKubeConfigPath                  = "/var/lib/kubelet/kubeconfig"
 	hostKubePKIPath                     = "/var/lib/kubelet/pki"
 	operatorStatusNoOpMessage           = "Cluster Machine API Operator is in NoOp mode"
@@ -70,24 +77,36 @@ func (optr *Operator) syncAll(config *OperatorConfig) (reconcile.Result, error)
 
 	errors := []error{}
 	// Sync webhook configuration
-	if err := optr.syncWebhookConfiguration(); err != nil {
-		errors = append(errors, fmt.Errorf("Error syncing machine API webhook configurations: %w", err))
+	if err := optr.syncWebhookConfiguration(config); err != nil {
+		errors = append(errors, newComponentError(ReasonSyncFailed, fmt.Errorf("Error syncing machine API webhook configurations: %w", err), componentWebhooks))
+	}
+
+	if err := optr.syncWebhookPolicySnapshot(config); err != nil {
+		errors = append(errors, newComponentError(ReasonSyncFailed, fmt.Errorf("Error syncing machine API webhook policy: %w", err), componentWebhooks))
+	}
+
+	if err := optr.syncEffectiveMachineDefaults(config); err != nil {
+		errors = append(errors, newComponentError(ReasonSyncFailed, fmt.Errorf("Error syncing effective machine defaults: %w", err), componentWebhooks))
 	}
 
 	if err := optr.syncClusterAPIController(config); err != nil {
-		errors = append(errors, fmt.Errorf("Error syncing machine-api-controller: %w", err))
+		errors = append(errors, newComponentError(ReasonSyncFailed, fmt.Errorf("Error syncing machine-api-controller: %w", err), controllersComponents...))
+	}
+
+	if err := optr.syncClusterAPIControllerPodDisruptionBudget(config); err != nil {
+		errors = append(errors, newComponentError(ReasonSyncFailed, fmt.Errorf("Error syncing machine-api-controller pod disruption budget: %w", err), componentWebhooks))
 	}
 
 	// Sync Termination Handler DaemonSet if supported
 	if config.Controllers.TerminationHandler != clusterAPIControllerNoOp {
 		if err := optr.syncTerminationHandler(config); err != nil {
-			errors = append(errors, fmt.Errorf("Error syncing termination handler: %w", err))
+			errors = append(errors, newComponentError(ReasonSyncFailed, fmt.Errorf("Error syncing termination handler: %w", err), componentTerminationHandler))
 		}
 	}
 
 	if len(errors) > 0 {
 		err := utilerrors.NewAggregate(errors)
-		if err := optr.statusDegraded(err.Error()); err != nil {
+		if err := optr.statusDegraded(err); err != nil {
 			// Just log the error here.  We still want to
 			// return the outer error.
 			klog.Errorf("Error syncing ClusterOperatorStatus: %v", err)
@@ -98,7 +117,7 @@ func (optr *Operator) syncAll(config *OperatorConfig) (reconcile.Result, error)
 
 	result, err := optr.checkRolloutStatus(config)
 	if err != nil {
-		if err := optr.statusDegraded(err.Error()); err != nil {
+		if err := optr.statusDegraded(err); err != nil {
 			// Just log the error here.  We still want to
 			// return the outer error.
 			klog.Errorf("Error syncing ClusterOperatorStatus: %v", err)
@@ -123,11 +142,16 @@ func (optr *Operator) syncAll(config *OperatorConfig) (reconcile.Result, error)
 
 func (optr *Operator) checkRolloutStatus(config *OperatorConfig) (reconcile.Result, error) {
 	// Check for machine-controllers deployment
-	result, err := optr.checkDeploymentRolloutStatus(newDeployment(config, nil))
+	controllersDeployment := newDeployment(config, nil)
+	result, err := optr.checkDeploymentRolloutStatus(controllersDeployment)
 	if err != nil {
-		return reconcile.Result{}, err
+		return reconcile.Result{}, newComponentError(ReasonRolloutFailed, err, controllersComponents...)
 	}
 	if result.Requeue || result.RequeueAfter > 0 {
+		// Tell which controllers keep the deployment from rolling out.
+		if err := optr.checkContainersStatus(controllersDeployment); err != nil {
+			return reconcile.Result{}, err
+		}
 		return result, nil
 	}
 
@@ -135,7 +159,7 @@ func (optr *Operator) checkRolloutStatus(config *OperatorConfig) (reconcile.Resu
 		// Check for termination handler
 		result, err := optr.checkDaemonSetRolloutStatus(newTerminationDaemonSet(config))
 		if err != nil {
-			return reconcile.Result{}, err
+			return reconcile.Result{}, newComponentError(ReasonRolloutFailed, err, componentTerminationHandler)
 		}
 		if result.Requeue || result.RequeueAfter > 0 {
 			return result, nil
@@ -148,13 +172,9 @@ func (optr *Operator) checkRolloutStatus(config *OperatorConfig) (reconcile.Resu
 func (optr *Operator) syncClusterAPIController(config *OperatorConfig) error {
 	controllersDeployment := newDeployment(config, nil)
 
-	// we watch some resources so that our deployment will redeploy without explicitly and carefully ordered resource creation
-	inputHashes, err := resourcehash.MultipleObjectHashStringMapForObjectReferences(
-		optr.kubeClient,
-		resourcehash.NewObjectRef().ForConfigMap().InNamespace(config.TargetNamespace).Named(externalTrustBundleConfigMapName),
-	)
+	inputHashes, err := optr.dependencyHashes(config)
 	if err != nil {
-		return fmt.Errorf("invalid dependency reference: %q", err)
+		return err
 	}
 	ensureDependecyAnnotations(inputHashes, controllersDeployment)
 
@@ -171,8 +191,32 @@ func (optr *Operator) syncClusterAPIController(config *OperatorConfig) error {
 	return nil
 }
 
+// syncClusterAPIControllerPodDisruptionBudget keeps a replica of the machine-api-controllers, and so of the
+// machine API webhooks, available during voluntary disruptions.
+// Single replica deployments have no budget, as it would block draining their node.
+func (optr *Operator) syncClusterAPIControllerPodDisruptionBudget(config *OperatorConfig) error {
+	pdb := newPodDisruptionBudget(config)
+	if controllersReplicas(config) < 2 {
+		err := optr.kubeClient.PolicyV1().PodDisruptionBudgets(pdb.Namespace).Delete(context.TODO(), pdb.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+
+	_, _, err := resourceapply.ApplyPodDisruptionBudget(context.TODO(), optr.kubeClient.PolicyV1(),
+		events.NewLoggingEventRecorder(optr.name), pdb)
+	return err
+}
+
 func (optr *Operator) syncTerminationHandler(config *OperatorConfig) error {
 	terminationDaemonSet := newTerminationDaemonSet(config)
+
+	inputHashes, err := optr.dependencyHashes(config)
+	if err != nil {
+		return err
+	}
+	ensureTemplateDependencyAnnotations(inputHashes, &terminationDaemonSet.ObjectMeta, &terminationDaemonSet.Spec.Template)
 	expectedGeneration := resourcemerge.ExpectedDaemonSetGeneration(terminationDaemonSet, optr.generations)
 	ds, updated, err := resourceapply.ApplyDaemonSet(context.TODO(), optr.kubeClient.AppsV1(),
 		events.NewLoggingEventRecorder(optr.name), terminationDaemonSet, expectedGeneration)
@@ -185,19 +229,38 @@ func (optr *Operator) syncTerminationHandler(config *OperatorConfig) error {
 	return nil
 }
 
-func (optr *Operator) syncWebhookConfiguration() error {
-	if err := optr.syncValidatingWebhook(); err != nil {
+func (optr *Operator) syncWebhookConfiguration(config *OperatorConfig) error {
+	// Without a CA bundle, the CA bundle is injected by the service CA operator.
+	var caBundle []byte
+	if optr.manageWebhookCerts {
+		var err error
+		if caBundle, err = optr.syncWebhookCertificates(); err != nil {
+			return fmt.Errorf("failed to sync webhook certificates: %w", err)
+		}
+	}
+
+	failurePolicy := optr.webhookFailurePolicy(config)
+	if err := optr.syncValidatingWebhook(caBundle, failurePolicy); err != nil {
 		return err
 	}
 
-	return optr.syncMutatingWebhook()
+	return optr.syncMutatingWebhook(caBundle, failurePolicy)
 }
 
-func (optr *Operator) syncValidatingWebhook() error {
-	expectedGeneration := resourcemerge.ExpectedValidatingWebhooksConfiguration(mapiwebhooks.NewValidatingWebhookConfiguration().Name, optr.generations)
+func (optr *Operator) syncValidatingWebhook(caBundle []byte, failurePolicy admissionregistrationv1.FailurePolicyType) error {
+	webhookConfiguration := mapiwebhooks.NewValidatingWebhookConfiguration()
+	webhookConfiguration.Annotations[webhookFailurePolicyAnnotation] = string(failurePolicy)
+	if len(caBundle) > 0 {
+		webhookConfiguration.Annotations[webhookCABundleHashAnnotation] = webhookCABundleHash(caBundle)
+	}
+	for i := range webhookConfiguration.Webhooks {
+		webhookConfiguration.Webhooks[i].ClientConfig.CABundle = caBundle
+		webhookConfiguration.Webhooks[i].FailurePolicy = &failurePolicy
+	}
+	expectedGeneration := resourcemerge.ExpectedValidatingWebhooksConfiguration(webhookConfiguration.Name, optr.generations)
 	validatingWebhook, updated, err := resourceapply.ApplyValidatingWebhookConfiguration(context.TODO(), optr.kubeClient.AdmissionregistrationV1(),
 		events.NewLoggingEventRecorder(optr.name),
-		mapiwebhooks.NewValidatingWebhookConfiguration(), expectedGeneration)
+		webhookConfiguration, expectedGeneration)
 	if err != nil {
 		return err
 	}
@@ -208,11 +271,20 @@ func (optr *Operator) syncValidatingWebhook() error {
 	return nil
 }
 
-func (optr *Operator) syncMutatingWebhook() error {
-	expectedGeneration := resourcemerge.ExpectedMutatingWebhooksConfiguration(mapiwebhooks.NewMutatingWebhookConfiguration().Name, optr.generations)
+func (optr *Operator) syncMutatingWebhook(caBundle []byte, failurePolicy admissionregistrationv1.FailurePolicyType) error {
+	webhookConfiguration := mapiwebhooks.NewMutatingWebhookConfiguration()
+	webhookConfiguration.Annotations[webhookFailurePolicyAnnotation] = string(failurePolicy)
+	if len(caBundle) > 0 {
+		webhookConfiguration.Annotations[webhookCABundleHashAnnotation] = webhookCABundleHash(caBundle)
+	}
+	for i := range webhookConfiguration.Webhooks {
+		webhookConfiguration.Webhooks[i].ClientConfig.CABundle = caBundle
+		webhookConfiguration.Webhooks[i].FailurePolicy = &failurePolicy
+	}
+	expectedGeneration := resourcemerge.ExpectedMutatingWebhooksConfiguration(webhookConfiguration.Name, optr.generations)
 	validatingWebhook, updated, err := resourceapply.ApplyMutatingWebhookConfiguration(context.TODO(), optr.kubeClient.AdmissionregistrationV1(),
 		events.NewLoggingEventRecorder(optr.name),
-		mapiwebhooks.NewMutatingWebhookConfiguration(), expectedGeneration)
+		webhookConfiguration, expectedGeneration)
 	if err != nil {
 		return err
 	}
@@ -283,13 +355,44 @@ func (optr *Operator) checkDaemonSetRolloutStatus(resource *appsv1.DaemonSet) (r
 	return reconcile.Result{}, nil
 }
 
+// controllersReplicas returns the number of machine-api-controllers replicas, at least 1.
+func controllersReplicas(config *OperatorConfig) int32 {
+	if config.ControllersReplicas < 1 {
+		return 1
+	}
+	return config.ControllersReplicas
+}
+
+// newPodAntiAffinity prefers scheduling the pods with the labels on different nodes.
+func newPodAntiAffinity(labels map[string]string) *corev1.Affinity {
+	return &corev1.Affinity{
+		PodAntiAffinity: &corev1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+				{
+					Weight: 100,
+					PodAffinityTerm: corev1.PodAffinityTerm{
+						LabelSelector: &metav1.LabelSelector{
+							MatchLabels: labels,
+						},
+						TopologyKey: corev1.LabelHostname,
+					},
+				},
+			},
+		},
+	}
+}
+
 func newDeployment(config *OperatorConfig, features map[string]bool) *appsv1.Deployment {
-	replicas := int32(1)
+	replicas := controllersReplicas(config)
 	template := newPodTemplateSpec(config, features)
+	if replicas > 1 {
+		// Spread the replicas across control plane nodes, so that losing a node does not take down all webhook servers.
+		template.Spec.Affinity = newPodAntiAffinity(template.Labels)
+	}
 
 	return &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "machine-api-controllers",
+			Name:      machineAPIControllers,
 			Namespace: config.TargetNamespace,
 			Annotations: map[string]string{
 				maoOwnedAnnotation: "",
@@ -312,6 +415,28 @@ func newDeployment(config *OperatorConfig, features map[string]bool) *appsv1.Dep
 	}
 }
 
+func newPodDisruptionBudget(config *OperatorConfig) *policyv1.PodDisruptionBudget {
+	minAvailable := intstr.FromInt(1)
+	return &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      machineAPIControllers,
+			Namespace: config.TargetNamespace,
+			Annotations: map[string]string{
+				maoOwnedAnnotation: "",
+			},
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MinAvailable: &minAvailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"api":     "clusterapi",
+					"k8s-app": "controller",
+				},
+			},
+		},
+	}
+}
+
 // List of the volumes needed by newKubeProxyContainer
 func newRBACConfigVolumes() []corev1.Volume {
 	var readOnly int32 = 420
@@ -336,21 +461,36 @@ func newRBACConfigVolumes() []corev1.Volume {
 				},
 			},
 		},
-		{
-			Name: "trusted-ca",
-			VolumeSource: corev1.VolumeSource{
-				ConfigMap: &corev1.ConfigMapVolumeSource{
-					Items: []corev1.KeyToPath{{Key: "ca-bundle.crt", Path: "tls-ca-bundle.pem"}},
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: externalTrustBundleConfigMapName,
-					},
-					Optional: pointer.BoolPtr(true),
+		newTrustedCAVolume(),
+	}
+}
+
+// newTrustedCAVolume returns the volume of the trusted CA bundle, with the custom CAs of the cluster-wide
+// Proxy injected in the mao-trusted-ca ConfigMap.
+func newTrustedCAVolume() corev1.Volume {
+	return corev1.Volume{
+		Name: trustedCAVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				Items: []corev1.KeyToPath{{Key: "ca-bundle.crt", Path: "tls-ca-bundle.pem"}},
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: externalTrustBundleConfigMapName,
 				},
+				Optional: pointer.BoolPtr(true),
 			},
 		},
 	}
 }
 
+// newTrustedCAVolumeMount returns the mount of the trusted CA bundle replacing the system CA bundle.
+func newTrustedCAVolumeMount() corev1.VolumeMount {
+	return corev1.VolumeMount{
+		MountPath: trustedCAMountPath,
+		Name:      trustedCAVolumeName,
+		ReadOnly:  true,
+	}
+}
+
 func newPodTemplateSpec(config *OperatorConfig, features map[string]bool) *corev1.PodTemplateSpec {
 	containers := newContainers(config, features)
 	proxyContainers := newKubeProxyContainers(config.Controllers.KubeRBACProxy)
@@ -435,6 +575,8 @@ func newPodTemplateSpec(config *OperatorConfig, features map[string]bool) *corev
 	}
 }
 
+// getProxyArgs returns the proxy environment variables of the effective cluster-wide Proxy configuration,
+// as observed in its status.
 func getProxyArgs(config *OperatorConfig) []corev1.EnvVar {
 	var envVars []corev1.EnvVar
 
@@ -444,13 +586,13 @@ func getProxyArgs(config *OperatorConfig) []corev1.EnvVar {
 	if config.Proxy.Status.HTTPProxy != "" {
 		envVars = append(envVars, corev1.EnvVar{
 			Name:  "HTTP_PROXY",
-			Value: config.Proxy.Spec.HTTPProxy,
+			Value: config.Proxy.Status.HTTPProxy,
 		})
 	}
 	if config.Proxy.Status.HTTPSProxy != "" {
 		envVars = append(envVars, corev1.EnvVar{
 			Name:  "HTTPS_PROXY",
-			Value: config.Proxy.Spec.HTTPSProxy,
+			Value: config.Proxy.Status.HTTPSProxy,
 		})
 	}
 	if config.Proxy.Status.NoProxy != "" {
@@ -476,6 +618,23 @@ func newContainers(config *OperatorConfig, features map[string]bool) []corev1.Co
 		"--leader-elect-lease-duration=120s",
 		fmt.Sprintf("--namespace=%s", config.TargetNamespace),
 	}
+	// The renew deadline and retry period flags are set only on the controllers built from this repository,
+	// the machine controllers of other providers may not define them yet. The same goes for the concurrency flags.
+	leaderElectionArgs := append([]string{}, args...)
+	leaderElectionArgs = append(leaderElectionArgs,
+		"--leader-elect-renew-deadline=90s",
+		"--leader-elect-retry-period=20s",
+	)
+	machineControllerArgs := args
+	if config.PlatformType == configv1.VSpherePlatformType {
+		machineControllerArgs = append(append([]string{}, leaderElectionArgs...), config.MachineControllerConcurrency.Args()...)
+	}
+
+	machineSetArgs := append([]string{}, leaderElectionArgs...)
+	machineSetArgs = append(machineSetArgs,
+		fmt.Sprintf("--tls-min-version=%s", mapiwebhooks.DefaultTLSMinVersion),
+		fmt.Sprintf("--tls-cipher-suites=%s", strings.Join(mapiwebhooks.DefaultTLSCipherSuites, ",")),
+	)
 
 	proxyEnvArgs := getProxyArgs(config)
 
@@ -484,7 +643,7 @@ func newContainers(config *OperatorConfig, features map[string]bool) []corev1.Co
 			Name:      "machineset-controller",
 			Image:     config.Controllers.MachineSet,
 			Command:   []string{"/machineset-controller"},
-			Args:      args,
+			Args:      machineSetArgs,
 			Resources: resources,
 			Env:       proxyEnvArgs,
 			Ports: []corev1.ContainerPort{
@@ -519,13 +678,14 @@ func newContainers(config *OperatorConfig, features map[string]bool) []corev1.Co
 					Name:      "cert",
 					ReadOnly:  true,
 				},
+				newTrustedCAVolumeMount(),
 			},
 		},
 		{
 			Name:      "machine-controller",
 			Image:     config.Controllers.Provider,
 			Command:   []string{"/machine-controller-manager"},
-			Args:      args,
+			Args:      machineControllerArgs,
 			Resources: resources,
 			Env: append(proxyEnvArgs, corev1.EnvVar{
 				Name: "NODE_NAME",
@@ -556,11 +716,7 @@ func newContainers(config *OperatorConfig, features map[string]bool) []corev1.Co
 				},
 			},
 			VolumeMounts: []corev1.VolumeMount{
-				{
-					MountPath: "/etc/pki/ca-trust/extracted/pem",
-					Name:      "trusted-ca",
-					ReadOnly:  true,
-				},
+				newTrustedCAVolumeMount(),
 				{
 					MountPath: "/var/run/secrets/openshift/serviceaccount",
 					Name:      "bound-sa-token",
@@ -572,17 +728,23 @@ func newContainers(config *OperatorConfig, features map[string]bool) []corev1.Co
 			Name:      "nodelink-controller",
 			Image:     config.Controllers.NodeLink,
 			Command:   []string{"/nodelink-controller"},
-			Args:      args,
+			Args:      leaderElectionArgs,
 			Env:       proxyEnvArgs,
 			Resources: resources,
+			VolumeMounts: []corev1.VolumeMount{
+				newTrustedCAVolumeMount(),
+			},
 		},
 		{
 			Name:      "machine-healthcheck-controller",
 			Image:     config.Controllers.MachineHealthCheck,
 			Command:   []string{"/machine-healthcheck"},
-			Args:      args,
+			Args:      leaderElectionArgs,
 			Env:       proxyEnvArgs,
 			Resources: resources,
+			VolumeMounts: []corev1.VolumeMount{
+				newTrustedCAVolumeMount(),
+			},
 			Ports: []corev1.ContainerPort{
 				{
 					Name:          "healthz",
@@ -727,6 +889,7 @@ func newTerminationPodTemplateSpec(config *OperatorConfig) *corev1.PodTemplateSp
 						},
 					},
 				},
+				newTrustedCAVolume(),
 			},
 		},
 	}
@@ -743,7 +906,7 @@ func newTerminationContainers(config *OperatorConfig) []corev1.Container {
 		"--logtostderr=true",
 		"--v=3",
 		"--node-name=$(NODE_NAME)",
-		fmt.Sprintf("--namespace=%s", config.TargetNamespace),
+		fmt.Sprintf("--platform=%s", config.PlatformType),
 		"--poll-interval-seconds=5",
 	}
 
@@ -778,6 +941,7 @@ func newTerminationContainers(config *OperatorConfig) []corev1.Container {
 					MountPath: hostKubePKIPath,
 					ReadOnly:  true,
 				},
+				newTrustedCAVolumeMount(),
 			},
 		},
 	}
@@ -786,15 +950,44 @@ func newTerminationContainers(config *OperatorConfig) []corev1.Container {
 // ensureDependecyAnnotations uses inputHash map of external dependencies to force new generation of the deployment
 // triggering the Kubernetes rollout as defined when the inputHash changes by adding it annotation to the deployment object.
 func ensureDependecyAnnotations(inputHashes map[string]string, deployment *appsv1.Deployment) {
+	ensureTemplateDependencyAnnotations(inputHashes, &deployment.ObjectMeta, &deployment.Spec.Template)
+}
+
+// ensureTemplateDependencyAnnotations adds the inputHash map of external dependencies to the annotations of
+// a workload and of its pod template, so that the pods are rolled out when a dependency changes.
+func ensureTemplateDependencyAnnotations(inputHashes map[string]string, meta *metav1.ObjectMeta, template *corev1.PodTemplateSpec) {
+	if len(inputHashes) == 0 {
+		return
+	}
+	// The annotations may be shared with other templates, eg. commonPodTemplateAnnotations.
+	meta.Annotations = copyAnnotations(meta.Annotations)
+	template.Annotations = copyAnnotations(template.Annotations)
 	for k, v := range inputHashes {
 		annotationKey := fmt.Sprintf("operator.openshift.io/dep-%s", k)
-		if deployment.Annotations == nil {
-			deployment.Annotations = map[string]string{}
-		}
-		if deployment.Spec.Template.Annotations == nil {
-			deployment.Spec.Template.Annotations = map[string]string{}
-		}
-		deployment.Annotations[annotationKey] = v
-		deployment.Spec.Template.Annotations[annotationKey] = v
+		meta.Annotations[annotationKey] = v
+		template.Annotations[annotationKey] = v
+	}
+}
+
+func copyAnnotations(annotations map[string]string) map[string]string {
+	copied := make(map[string]string, len(annotations))
+	for k, v := range annotations {
+		copied[k] = v
+	}
+	return copied
+}
+
+// dependencyHashes returns the hashes of the external dependencies of the workloads of the operator:
+// the trusted CA bundle, so that the workloads are rolled out when the custom CAs of the cluster-wide
+// Proxy change. Changes of the Proxy itself change the environment of the workloads, rolling them out.
+func (optr *Operator) dependencyHashes(config *OperatorConfig) (map[string]string, error) {
+	// we watch some resources so that our deployment will redeploy without explicitly and carefully ordered resource creation
+	inputHashes, err := resourcehash.MultipleObjectHashStringMapForObjectReferences(
+		optr.kubeClient,
+		resourcehash.NewObjectRef().ForConfigMap().InNamespace(config.TargetNamespace).Named(externalTrustBundleConfigMapName),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dependency reference: %q", err)
 	}
+	return inputHashes, nil
 }
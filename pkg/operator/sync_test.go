@@ -1,15 +1,23 @@
 package operator
 
 import (
+	"context"
+	"strings"
 	"testing"
 	"time"
 
+	. "github.com/onsi/gomega"
+	openshiftv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/machine-api-operator/pkg/util"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/diff"
+	"k8s.io/apimachinery/pkg/util/sets"
+	fakekube "k8s.io/client-go/kubernetes/fake"
 )
 
 func TestCheckDeploymentRolloutStatus(t *testing.T) {
@@ -255,3 +263,295 @@ func Test_ensureDependecyAnnotations(t *testing.T) {
 		})
 	}
 }
+
+func TestNewContainersWebhookTLSArgs(t *testing.T) {
+	config := &OperatorConfig{
+		TargetNamespace: "openshift-machine-api",
+		Controllers: Controllers{
+			Provider:   "provider-image",
+			MachineSet: "machineset-image",
+		},
+	}
+
+	tlsArgs := []string{
+		"--tls-min-version=VersionTLS12",
+		"--tls-cipher-suites=TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
+	}
+
+	for _, container := range newContainers(config, map[string]bool{}) {
+		hasTLSArgs := true
+		for _, arg := range tlsArgs {
+			if !sets.NewString(container.Args...).Has(arg) {
+				hasTLSArgs = false
+			}
+		}
+
+		if container.Name == "machineset-controller" && !hasTLSArgs {
+			t.Errorf("expected the machineset-controller args to contain %q, got: %q", tlsArgs, container.Args)
+		}
+		if container.Name != "machineset-controller" && sets.NewString(container.Args...).HasAny(tlsArgs...) {
+			t.Errorf("expected the %s args not to contain webhook TLS args, got: %q", container.Name, container.Args)
+		}
+	}
+}
+
+func TestNewContainersLeaderElectionArgs(t *testing.T) {
+	leaderElectionArgs := []string{
+		"--leader-elect=true",
+		"--leader-elect-lease-duration=120s",
+		"--leader-elect-renew-deadline=90s",
+		"--leader-elect-retry-period=20s",
+	}
+
+	for _, platform := range []openshiftv1.PlatformType{openshiftv1.AWSPlatformType, openshiftv1.VSpherePlatformType} {
+		t.Run(string(platform), func(t *testing.T) {
+			g := NewWithT(t)
+
+			config := &OperatorConfig{
+				TargetNamespace: targetNamespace,
+				PlatformType:    platform,
+				Controllers: Controllers{
+					Provider:   "provider-image",
+					MachineSet: "machineset-image",
+				},
+			}
+
+			for _, container := range newContainers(config, map[string]bool{}) {
+				if container.Name == "machine-controller" && platform != openshiftv1.VSpherePlatformType {
+					// Other providers' machine controllers may not define the renew deadline and retry period flags.
+					g.Expect(container.Args).To(ContainElements(leaderElectionArgs[:2]), container.Name)
+					for _, arg := range leaderElectionArgs[2:] {
+						g.Expect(container.Args).ToNot(ContainElement(arg), container.Name)
+					}
+					continue
+				}
+				g.Expect(container.Args).To(ContainElements(leaderElectionArgs), container.Name)
+			}
+		})
+	}
+}
+
+func TestNewTerminationContainersPlatformArg(t *testing.T) {
+	for _, platform := range []openshiftv1.PlatformType{openshiftv1.AWSPlatformType, openshiftv1.GCPPlatformType, openshiftv1.AzurePlatformType} {
+		t.Run(string(platform), func(t *testing.T) {
+			g := NewWithT(t)
+
+			config := &OperatorConfig{
+				TargetNamespace: targetNamespace,
+				PlatformType:    platform,
+				Controllers:     Controllers{TerminationHandler: "machine-api-operator-image"},
+			}
+
+			containers := newTerminationContainers(config)
+			g.Expect(containers).To(HaveLen(1))
+			g.Expect(containers[0].Image).To(Equal("machine-api-operator-image"))
+			g.Expect(containers[0].Command).To(Equal([]string{"/termination-handler"}))
+			g.Expect(containers[0].Args).To(ContainElement("--platform=" + string(platform)))
+		})
+	}
+}
+
+func TestNewDeploymentReplicas(t *testing.T) {
+	testCases := []struct {
+		name                string
+		controllersReplicas int32
+		expectedReplicas    int32
+		expectAntiAffinity  bool
+	}{
+		{
+			name:                "multi node",
+			controllersReplicas: 2,
+			expectedReplicas:    2,
+			expectAntiAffinity:  true,
+		},
+		{
+			name:                "single node",
+			controllersReplicas: 1,
+			expectedReplicas:    1,
+		},
+		{
+			name:             "replicas not set",
+			expectedReplicas: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			config := &OperatorConfig{
+				TargetNamespace:     targetNamespace,
+				ControllersReplicas: tc.controllersReplicas,
+			}
+			deployment := newDeployment(config, nil)
+			g.Expect(deployment.Spec.Replicas).To(Equal(&tc.expectedReplicas))
+
+			if !tc.expectAntiAffinity {
+				g.Expect(deployment.Spec.Template.Spec.Affinity).To(BeNil())
+				return
+			}
+			g.Expect(deployment.Spec.Template.Spec.Affinity).ToNot(BeNil())
+			g.Expect(deployment.Spec.Template.Spec.Affinity.PodAntiAffinity).ToNot(BeNil())
+			terms := deployment.Spec.Template.Spec.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution
+			g.Expect(terms).To(HaveLen(1))
+			g.Expect(terms[0].PodAffinityTerm.TopologyKey).To(Equal(corev1.LabelHostname))
+			g.Expect(terms[0].PodAffinityTerm.LabelSelector.MatchLabels).To(Equal(deployment.Spec.Selector.MatchLabels))
+		})
+	}
+}
+
+func TestSyncClusterAPIControllerPodDisruptionBudget(t *testing.T) {
+	g := NewWithT(t)
+
+	kubeClient := fakekube.NewSimpleClientset()
+	optr := &Operator{kubeClient: kubeClient}
+	config := &OperatorConfig{
+		TargetNamespace:     targetNamespace,
+		ControllersReplicas: 2,
+	}
+
+	g.Expect(optr.syncClusterAPIControllerPodDisruptionBudget(config)).To(Succeed())
+	pdb, err := kubeClient.PolicyV1().PodDisruptionBudgets(targetNamespace).Get(context.TODO(), deploymentName, metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(pdb.Spec.MinAvailable.IntValue()).To(Equal(1))
+	g.Expect(pdb.Spec.Selector.MatchLabels).To(Equal(newDeployment(config, nil).Spec.Selector.MatchLabels))
+
+	// A single replica must not be protected by a budget, which would block draining its node.
+	config.ControllersReplicas = 1
+	g.Expect(optr.syncClusterAPIControllerPodDisruptionBudget(config)).To(Succeed())
+	_, err = kubeClient.PolicyV1().PodDisruptionBudgets(targetNamespace).Get(context.TODO(), deploymentName, metav1.GetOptions{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "expected the budget to be removed, got: %v", err)
+
+	g.Expect(optr.syncClusterAPIControllerPodDisruptionBudget(config)).To(Succeed())
+}
+
+func TestGetProxyArgs(t *testing.T) {
+	g := NewWithT(t)
+
+	config := &OperatorConfig{
+		Proxy: &openshiftv1.Proxy{
+			// The spec is not yet validated, the status holds the effective configuration.
+			Spec: openshiftv1.ProxySpec{
+				HTTPProxy:  "http://invalid",
+				HTTPSProxy: "https://invalid",
+			},
+			Status: openshiftv1.ProxyStatus{
+				HTTPProxy:  "http://proxy:3128",
+				HTTPSProxy: "https://proxy:3129",
+				NoProxy:    ".cluster.local,169.254.169.254",
+			},
+		},
+	}
+	g.Expect(getProxyArgs(config)).To(Equal([]corev1.EnvVar{
+		{Name: "HTTP_PROXY", Value: "http://proxy:3128"},
+		{Name: "HTTPS_PROXY", Value: "https://proxy:3129"},
+		{Name: "NO_PROXY", Value: ".cluster.local,169.254.169.254"},
+	}))
+
+	g.Expect(getProxyArgs(&OperatorConfig{})).To(BeEmpty())
+}
+
+func TestTrustedCAMounts(t *testing.T) {
+	config := &OperatorConfig{
+		TargetNamespace: targetNamespace,
+		Proxy: &openshiftv1.Proxy{
+			Status: openshiftv1.ProxyStatus{HTTPSProxy: "https://proxy:3129"},
+		},
+	}
+
+	templates := map[string]*corev1.PodTemplateSpec{
+		machineAPIControllers:        &newDeployment(config, nil).Spec.Template,
+		machineAPITerminationHandler: &newTerminationDaemonSet(config).Spec.Template,
+	}
+	for name, template := range templates {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			g.Expect(template.Spec.Volumes).To(ContainElement(newTrustedCAVolume()))
+			for _, container := range template.Spec.Containers {
+				// The metrics proxies only talk to the API server.
+				if strings.HasPrefix(container.Name, "kube-rbac-proxy") {
+					continue
+				}
+				g.Expect(container.VolumeMounts).To(ContainElement(newTrustedCAVolumeMount()), container.Name)
+				g.Expect(container.Env).To(ContainElement(corev1.EnvVar{Name: "HTTPS_PROXY", Value: "https://proxy:3129"}), container.Name)
+			}
+		})
+	}
+}
+
+func TestSyncRollsOutTrustedCAChanges(t *testing.T) {
+	g := NewWithT(t)
+
+	trustedCA := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: externalTrustBundleConfigMapName, Namespace: targetNamespace},
+		Data:       map[string]string{"ca-bundle.crt": "ca-1"},
+	}
+	kubeClient := fakekube.NewSimpleClientset(trustedCA)
+	optr := &Operator{kubeClient: kubeClient}
+	config := &OperatorConfig{TargetNamespace: targetNamespace}
+
+	templateAnnotations := func() (map[string]string, map[string]string) {
+		deployment, err := kubeClient.AppsV1().Deployments(targetNamespace).Get(context.TODO(), machineAPIControllers, metav1.GetOptions{})
+		g.Expect(err).ToNot(HaveOccurred())
+		daemonSet, err := kubeClient.AppsV1().DaemonSets(targetNamespace).Get(context.TODO(), machineAPITerminationHandler, metav1.GetOptions{})
+		g.Expect(err).ToNot(HaveOccurred())
+		return deployment.Spec.Template.Annotations, daemonSet.Spec.Template.Annotations
+	}
+
+	g.Expect(optr.syncClusterAPIController(config)).To(Succeed())
+	g.Expect(optr.syncTerminationHandler(config)).To(Succeed())
+	deploymentAnnotations, daemonSetAnnotations := templateAnnotations()
+	g.Expect(deploymentAnnotations).To(HaveKey("operator.openshift.io/dep-test-namespace.mao-trusted-ca.configmap"))
+	g.Expect(daemonSetAnnotations).To(Equal(deploymentAnnotations))
+
+	// The annotations shared by the templates are left untouched.
+	g.Expect(commonPodTemplateAnnotations).To(HaveLen(1))
+
+	trustedCA.Data["ca-bundle.crt"] = "ca-2"
+	_, err := kubeClient.CoreV1().ConfigMaps(targetNamespace).Update(context.TODO(), trustedCA, metav1.UpdateOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(optr.syncClusterAPIController(config)).To(Succeed())
+	g.Expect(optr.syncTerminationHandler(config)).To(Succeed())
+	newDeploymentAnnotations, newDaemonSetAnnotations := templateAnnotations()
+	g.Expect(newDeploymentAnnotations).ToNot(Equal(deploymentAnnotations))
+	g.Expect(newDaemonSetAnnotations).To(Equal(newDeploymentAnnotations))
+}
+
+func TestNewContainersConcurrencyArgs(t *testing.T) {
+	concurrencyArgs := []string{
+		"--max-concurrent-reconciles=5",
+		"--max-concurrent-drains-per-zone=2",
+	}
+
+	for _, platform := range []openshiftv1.PlatformType{openshiftv1.AWSPlatformType, openshiftv1.VSpherePlatformType} {
+		t.Run(string(platform), func(t *testing.T) {
+			g := NewWithT(t)
+
+			config := &OperatorConfig{
+				TargetNamespace: targetNamespace,
+				PlatformType:    platform,
+				Controllers: Controllers{
+					Provider:   "provider-image",
+					MachineSet: "machineset-image",
+				},
+				MachineControllerConcurrency: util.ConcurrencyOptions{
+					MaxConcurrentReconciles:    5,
+					MaxConcurrentDrainsPerZone: 2,
+				},
+			}
+
+			for _, container := range newContainers(config, map[string]bool{}) {
+				if container.Name == "machine-controller" && platform == openshiftv1.VSpherePlatformType {
+					g.Expect(container.Args).To(ContainElements(concurrencyArgs), container.Name)
+					continue
+				}
+				// Other providers' machine controllers may not define the concurrency flags.
+				for _, arg := range concurrencyArgs {
+					g.Expect(container.Args).ToNot(ContainElement(arg), container.Name)
+				}
+			}
+		})
+	}
+}
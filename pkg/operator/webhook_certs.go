@@ -0,0 +1,302 @@
+package operator
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// webhookServiceName is the Service serving the machine API webhooks.
+	webhookServiceName = "machine-api-operator-webhook"
+	// webhookServingCertSecretName is the Secret with the serving certificate of the webhooks,
+	// mounted in the machine-api-controllers Deployment.
+	webhookServingCertSecretName = "machine-api-operator-webhook-cert"
+	// webhookCASecretName is the Secret with the CA signing the serving certificate of the webhooks,
+	// and the CA bundle trusted by the webhook configurations.
+	webhookCASecretName = "machine-api-operator-webhook-ca"
+	// webhookCABundleKey is the key of the CA bundle in webhookCASecretName.
+	webhookCABundleKey = "ca-bundle.crt"
+	// webhookCABundleHashAnnotation records the hash of the CA bundle of the webhook configurations.
+	// The webhook configurations are only rewritten when their metadata or generation change, so the annotation
+	// rolls out a rotated CA bundle.
+	webhookCABundleHashAnnotation = "machine.openshift.io/webhook-ca-bundle-hash"
+
+	webhookCAValidity          = 2 * 365 * 24 * time.Hour
+	webhookServingCertValidity = 365 * 24 * time.Hour
+)
+
+// timeNow is the clock of the certificate validity checks, replaced in tests.
+var timeNow = time.Now
+
+// syncWebhookCertificates generates the CA and the serving certificate of the webhooks, rotates them
+// once a fifth of their validity remains, and returns the CA bundle to trust in the webhook configurations.
+// This replaces the service CA operator, so it must only be used where the service CA operator does not run.
+// The CA bundle keeps the previous CA until it expires, so that the webhooks are trusted until they serve
+// the serving certificate signed by the new CA.
+func (optr *Operator) syncWebhookCertificates() ([]byte, error) {
+	caSecret, err := optr.kubeClient.CoreV1().Secrets(optr.namespace).Get(context.TODO(), webhookCASecretName, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	if apierrors.IsNotFound(err) {
+		caSecret = nil
+	}
+
+	ca, caKey, caBundle, rotated, err := ensureWebhookCA(caSecret)
+	if err != nil {
+		return nil, err
+	}
+	if rotated {
+		klog.Infof("Rotating the machine API webhooks CA, valid until %s", ca.NotAfter.UTC().Format(time.RFC3339))
+		if err := optr.applyTLSSecret(webhookCASecretName, ca, caKey, map[string][]byte{webhookCABundleKey: caBundle}); err != nil {
+			return nil, err
+		}
+	}
+
+	servingSecret, err := optr.kubeClient.CoreV1().Secrets(optr.namespace).Get(context.TODO(), webhookServingCertSecretName, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	if apierrors.IsNotFound(err) || webhookServingCertNeedsRotation(servingSecret, ca, optr.namespace) {
+		cert, key, err := newWebhookServingCert(ca, caKey, optr.namespace)
+		if err != nil {
+			return nil, err
+		}
+		klog.Infof("Rotating the machine API webhooks serving certificate, valid until %s", cert.NotAfter.UTC().Format(time.RFC3339))
+		if err := optr.applyTLSSecret(webhookServingCertSecretName, cert, key, nil); err != nil {
+			return nil, err
+		}
+	}
+	return caBundle, nil
+}
+
+// applyTLSSecret creates or updates a kubernetes.io/tls Secret with the certificate and key, and extra data.
+func (optr *Operator) applyTLSSecret(name string, cert *x509.Certificate, key *rsa.PrivateKey, extra map[string][]byte) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: optr.namespace,
+			Annotations: map[string]string{
+				maoOwnedAnnotation: "",
+			},
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}),
+			corev1.TLSPrivateKeyKey: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		},
+	}
+	for k, v := range extra {
+		secret.Data[k] = v
+	}
+
+	secrets := optr.kubeClient.CoreV1().Secrets(optr.namespace)
+	existing, err := secrets.Get(context.TODO(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = secrets.Create(context.TODO(), secret, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	// The type of a Secret is immutable, a Secret of another type is replaced.
+	if existing.Type != secret.Type {
+		if err := secrets.Delete(context.TODO(), name, metav1.DeleteOptions{}); err != nil {
+			return err
+		}
+		_, err = secrets.Create(context.TODO(), secret, metav1.CreateOptions{})
+		return err
+	}
+	existing = existing.DeepCopy()
+	existing.Data = secret.Data
+	if existing.Annotations == nil {
+		existing.Annotations = map[string]string{}
+	}
+	existing.Annotations[maoOwnedAnnotation] = ""
+	_, err = secrets.Update(context.TODO(), existing, metav1.UpdateOptions{})
+	return err
+}
+
+// ensureWebhookCA returns the CA of the Secret, or a new CA when the Secret has none or its CA is to be rotated,
+// along with the CA bundle to trust.
+func ensureWebhookCA(secret *corev1.Secret) (*x509.Certificate, *rsa.PrivateKey, []byte, bool, error) {
+	var previous []*x509.Certificate
+	if secret != nil {
+		ca, key, err := parseTLSSecret(secret)
+		if err == nil && !needsRotation(ca) {
+			bundle := secret.Data[webhookCABundleKey]
+			if len(bundle) == 0 {
+				bundle = secret.Data[corev1.TLSCertKey]
+			}
+			return ca, key, bundle, false, nil
+		}
+		if err != nil {
+			klog.Warningf("Replacing the invalid machine API webhooks CA: %v", err)
+		}
+		previous = parseCertificates(secret.Data[webhookCABundleKey])
+	}
+
+	ca, key, err := newWebhookCA()
+	if err != nil {
+		return nil, nil, nil, false, err
+	}
+	bundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})
+	for _, cert := range previous {
+		if timeNow().Before(cert.NotAfter) && !cert.Equal(ca) {
+			bundle = append(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+		}
+	}
+	return ca, key, bundle, true, nil
+}
+
+// webhookServingCertNeedsRotation returns true when the serving certificate of the Secret does not parse,
+// is not signed by the CA, is not valid for the webhook Service, or is to be rotated.
+func webhookServingCertNeedsRotation(secret *corev1.Secret, ca *x509.Certificate, namespace string) bool {
+	cert, _, err := parseTLSSecret(secret)
+	if err != nil {
+		return true
+	}
+	if cert.CheckSignatureFrom(ca) != nil {
+		return true
+	}
+	for _, name := range webhookServiceDNSNames(namespace) {
+		if cert.VerifyHostname(name) != nil {
+			return true
+		}
+	}
+	return needsRotation(cert)
+}
+
+// needsRotation returns true once less than a fifth of the validity of the certificate remains.
+func needsRotation(cert *x509.Certificate) bool {
+	validity := cert.NotAfter.Sub(cert.NotBefore)
+	return timeNow().After(cert.NotAfter.Add(-validity / 5))
+}
+
+func webhookServiceDNSNames(namespace string) []string {
+	return []string{
+		fmt.Sprintf("%s.%s.svc", webhookServiceName, namespace),
+		fmt.Sprintf("%s.%s.svc.cluster.local", webhookServiceName, namespace),
+	}
+}
+
+func newWebhookCA() (*x509.Certificate, *rsa.PrivateKey, error) {
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: fmt.Sprintf("machine-api-webhook-ca@%d", timeNow().Unix())},
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		BasicConstraintsValid: true,
+	}
+	return newCertificate(template, nil, nil, webhookCAValidity)
+}
+
+func newWebhookServingCert(ca *x509.Certificate, caKey *rsa.PrivateKey, namespace string) (*x509.Certificate, *rsa.PrivateKey, error) {
+	dnsNames := webhookServiceDNSNames(namespace)
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:    dnsNames,
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	return newCertificate(template, ca, caKey, webhookServingCertValidity)
+}
+
+// newCertificate signs the template with the parent, or self-signs it when there is no parent.
+func newCertificate(template, parent *x509.Certificate, parentKey *rsa.PrivateKey, validity time.Duration) (*x509.Certificate, *rsa.PrivateKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	template.SerialNumber = serial
+	// Allow for clock skew between the operator and the API servers.
+	template.NotBefore = timeNow().Add(-time.Hour)
+	template.NotAfter = timeNow().Add(validity)
+
+	var signer crypto.Signer = key
+	if parent == nil {
+		parent = template
+	} else {
+		signer = parentKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), signer)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+// parseTLSSecret returns the certificate and the RSA key of a kubernetes.io/tls Secret.
+func parseTLSSecret(secret *corev1.Secret) (*x509.Certificate, *rsa.PrivateKey, error) {
+	certs := parseCertificates(secret.Data[corev1.TLSCertKey])
+	if len(certs) == 0 {
+		return nil, nil, fmt.Errorf("secret %s has no certificate", secret.Name)
+	}
+	block, _ := pem.Decode(secret.Data[corev1.TLSPrivateKeyKey])
+	if block == nil {
+		return nil, nil, fmt.Errorf("secret %s has no private key", secret.Name)
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("secret %s has an invalid private key: %w", secret.Name, err)
+	}
+	if !bytes.Equal(x509.MarshalPKCS1PublicKey(&key.PublicKey), marshalPublicKey(certs[0])) {
+		return nil, nil, fmt.Errorf("secret %s has a private key which does not match its certificate", secret.Name)
+	}
+	return certs[0], key, nil
+}
+
+func marshalPublicKey(cert *x509.Certificate) []byte {
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil
+	}
+	return x509.MarshalPKCS1PublicKey(key)
+}
+
+// parseCertificates returns the certificates of a PEM bundle, skipping the blocks which do not parse.
+func parseCertificates(data []byte) []*x509.Certificate {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+			certs = append(certs, cert)
+		}
+	}
+}
+
+// webhookCABundleHash returns the hex encoded SHA-256 hash of the CA bundle.
+func webhookCABundleHash(caBundle []byte) string {
+	hash := sha256.Sum256(caBundle)
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,113 @@
+package operator
+
+import (
+	"context"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	mapiwebhooks "github.com/openshift/machine-api-operator/pkg/webhooks"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+)
+
+func getWebhookCertificates(g *WithT, optr *Operator) (ca, serving *x509.Certificate, caBundle []*x509.Certificate) {
+	caSecret, err := optr.kubeClient.CoreV1().Secrets(targetNamespace).Get(context.TODO(), webhookCASecretName, metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	ca, _, err = parseTLSSecret(caSecret)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	servingSecret, err := optr.kubeClient.CoreV1().Secrets(targetNamespace).Get(context.TODO(), webhookServingCertSecretName, metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(servingSecret.Type).To(Equal(corev1.SecretTypeTLS))
+	serving, _, err = parseTLSSecret(servingSecret)
+	g.Expect(err).ToNot(HaveOccurred())
+	return ca, serving, parseCertificates(caSecret.Data[webhookCABundleKey])
+}
+
+func TestSyncWebhookCertificates(t *testing.T) {
+	g := NewWithT(t)
+	defer func() { timeNow = time.Now }()
+	start := time.Now()
+	timeNow = func() time.Time { return start }
+
+	// A Secret of another type, eg. left by the service CA operator, is replaced.
+	kubeClient := fakekube.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: webhookServingCertSecretName, Namespace: targetNamespace},
+		Type:       corev1.SecretTypeOpaque,
+	})
+	optr := &Operator{kubeClient: kubeClient, namespace: targetNamespace, manageWebhookCerts: true}
+
+	g.Expect(optr.syncWebhookConfiguration(&OperatorConfig{})).To(Succeed())
+	ca, serving, caBundle := getWebhookCertificates(g, optr)
+	g.Expect(serving.CheckSignatureFrom(ca)).To(Succeed())
+	g.Expect(serving.VerifyHostname(webhookServiceName + "." + targetNamespace + ".svc")).To(Succeed())
+	g.Expect(caBundle).To(HaveLen(1))
+
+	validating, err := kubeClient.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.TODO(), mapiwebhooks.NewValidatingWebhookConfiguration().Name, metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	for _, webhook := range validating.Webhooks {
+		g.Expect(parseCertificates(webhook.ClientConfig.CABundle)).To(ConsistOf(caBundle))
+	}
+	mutating, err := kubeClient.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(context.TODO(), mapiwebhooks.NewMutatingWebhookConfiguration().Name, metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	for _, webhook := range mutating.Webhooks {
+		g.Expect(parseCertificates(webhook.ClientConfig.CABundle)).To(ConsistOf(caBundle))
+	}
+
+	// Nothing is rotated while the certificates are valid.
+	timeNow = func() time.Time { return start.Add(30 * 24 * time.Hour) }
+	g.Expect(optr.syncWebhookConfiguration(&OperatorConfig{})).To(Succeed())
+	sameCA, sameServing, _ := getWebhookCertificates(g, optr)
+	g.Expect(sameCA.Equal(ca)).To(BeTrue())
+	g.Expect(sameServing.Equal(serving)).To(BeTrue())
+
+	// The serving certificate is rotated before it expires.
+	timeNow = func() time.Time { return start.Add(webhookServingCertValidity - 30*24*time.Hour) }
+	g.Expect(optr.syncWebhookConfiguration(&OperatorConfig{})).To(Succeed())
+	sameCA, rotatedServing, _ := getWebhookCertificates(g, optr)
+	g.Expect(sameCA.Equal(ca)).To(BeTrue())
+	g.Expect(rotatedServing.Equal(serving)).To(BeFalse())
+	g.Expect(rotatedServing.CheckSignatureFrom(ca)).To(Succeed())
+
+	// The CA is rotated before it expires, and the previous CA is trusted until it expires.
+	timeNow = func() time.Time { return start.Add(webhookCAValidity - 30*24*time.Hour) }
+	g.Expect(optr.syncWebhookConfiguration(&OperatorConfig{})).To(Succeed())
+	rotatedCA, rotatedServing, caBundle := getWebhookCertificates(g, optr)
+	g.Expect(rotatedCA.Equal(ca)).To(BeFalse())
+	g.Expect(rotatedServing.CheckSignatureFrom(rotatedCA)).To(Succeed())
+	g.Expect(caBundle).To(HaveLen(2))
+	g.Expect(caBundle[1].Equal(ca)).To(BeTrue())
+
+	// The rotated CA bundle is written to the webhook configurations.
+	validating, err = kubeClient.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.TODO(), mapiwebhooks.NewValidatingWebhookConfiguration().Name, metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	for _, webhook := range validating.Webhooks {
+		g.Expect(parseCertificates(webhook.ClientConfig.CABundle)).To(ConsistOf(caBundle))
+	}
+	mutating, err = kubeClient.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(context.TODO(), mapiwebhooks.NewMutatingWebhookConfiguration().Name, metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	for _, webhook := range mutating.Webhooks {
+		g.Expect(parseCertificates(webhook.ClientConfig.CABundle)).To(ConsistOf(caBundle))
+	}
+}
+
+func TestSyncWebhookConfigurationWithoutManagedCertificates(t *testing.T) {
+	g := NewWithT(t)
+	kubeClient := fakekube.NewSimpleClientset()
+	optr := &Operator{kubeClient: kubeClient, namespace: targetNamespace}
+
+	g.Expect(optr.syncWebhookConfiguration(&OperatorConfig{})).To(Succeed())
+
+	secrets, err := kubeClient.CoreV1().Secrets(targetNamespace).List(context.TODO(), metav1.ListOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(secrets.Items).To(BeEmpty())
+	validating, err := kubeClient.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.TODO(), mapiwebhooks.NewValidatingWebhookConfiguration().Name, metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(validating.Annotations).To(HaveKeyWithValue("service.beta.openshift.io/inject-cabundle", "true"))
+	for _, webhook := range validating.Webhooks {
+		g.Expect(webhook.ClientConfig.CABundle).To(BeEmpty())
+	}
+}
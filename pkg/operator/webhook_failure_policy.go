@@ -0,0 +1,52 @@
+package operator
+
+import (
+	"fmt"
+
+	configv1 "github.com/openshift/api/config/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/klog/v2"
+)
+
+// webhookFailurePolicyAnnotation records the failure policy of the machine API webhook configurations.
+// The webhook configurations are only rewritten when their metadata or generation change, so the annotation
+// rolls out a change of failure policy.
+const webhookFailurePolicyAnnotation = "machine.openshift.io/webhook-failure-policy"
+
+// defaultWebhookFailurePolicy does not block the machine lifecycle when the webhooks are unavailable,
+// eg. while bootstrapping a cluster.
+const defaultWebhookFailurePolicy = admissionregistrationv1.Ignore
+
+// ParseWebhookFailurePolicies returns the failure policies of the machine API webhooks by platform,
+// from a map of platform names to Fail or Ignore.
+func ParseWebhookFailurePolicies(policies map[string]string) (map[configv1.PlatformType]admissionregistrationv1.FailurePolicyType, error) {
+	parsed := make(map[configv1.PlatformType]admissionregistrationv1.FailurePolicyType, len(policies))
+	for platform, policy := range policies {
+		if platform == "" {
+			return nil, fmt.Errorf("invalid webhook failure policy %q: missing platform", policy)
+		}
+		switch p := admissionregistrationv1.FailurePolicyType(policy); p {
+		case admissionregistrationv1.Fail, admissionregistrationv1.Ignore:
+			parsed[configv1.PlatformType(platform)] = p
+		default:
+			return nil, fmt.Errorf("invalid webhook failure policy %q for platform %s: must be %s or %s",
+				policy, platform, admissionregistrationv1.Fail, admissionregistrationv1.Ignore)
+		}
+	}
+	return parsed, nil
+}
+
+// webhookFailurePolicy returns the failure policy of the machine API webhooks on the cluster platform.
+// Requests are only rejected when the webhooks are unavailable with several machine-api-controllers replicas,
+// as a single replica being rescheduled would otherwise block machine changes across the cluster.
+func (optr *Operator) webhookFailurePolicy(config *OperatorConfig) admissionregistrationv1.FailurePolicyType {
+	policy, ok := optr.webhookFailurePolicies[config.PlatformType]
+	if !ok {
+		return defaultWebhookFailurePolicy
+	}
+	if policy == admissionregistrationv1.Fail && controllersReplicas(config) < 2 {
+		klog.Warningf("Ignoring the %s webhook failure policy of platform %s with a single machine-api-controllers replica", policy, config.PlatformType)
+		return defaultWebhookFailurePolicy
+	}
+	return policy
+}
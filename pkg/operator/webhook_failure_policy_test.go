@@ -0,0 +1,155 @@
+package operator
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	configv1 "github.com/openshift/api/config/v1"
+	mapiwebhooks "github.com/openshift/machine-api-operator/pkg/webhooks"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseWebhookFailurePolicies(t *testing.T) {
+	testCases := []struct {
+		name          string
+		policies      map[string]string
+		expected      map[configv1.PlatformType]admissionregistrationv1.FailurePolicyType
+		expectedError string
+	}{
+		{
+			name:     "no policies",
+			expected: map[configv1.PlatformType]admissionregistrationv1.FailurePolicyType{},
+		},
+		{
+			name:     "policies by platform",
+			policies: map[string]string{"AWS": "Fail", "VSphere": "Ignore"},
+			expected: map[configv1.PlatformType]admissionregistrationv1.FailurePolicyType{
+				configv1.AWSPlatformType:     admissionregistrationv1.Fail,
+				configv1.VSpherePlatformType: admissionregistrationv1.Ignore,
+			},
+		},
+		{
+			name:          "invalid policy",
+			policies:      map[string]string{"AWS": "fail"},
+			expectedError: `invalid webhook failure policy "fail" for platform AWS: must be Fail or Ignore`,
+		},
+		{
+			name:          "missing platform",
+			policies:      map[string]string{"": "Fail"},
+			expectedError: `invalid webhook failure policy "Fail": missing platform`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			policies, err := ParseWebhookFailurePolicies(tc.policies)
+			if tc.expectedError != "" {
+				g.Expect(err).To(MatchError(tc.expectedError))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(policies).To(Equal(tc.expected))
+		})
+	}
+}
+
+func TestSyncWebhookConfigurationFailurePolicy(t *testing.T) {
+	policies := map[configv1.PlatformType]admissionregistrationv1.FailurePolicyType{
+		configv1.AWSPlatformType:     admissionregistrationv1.Fail,
+		configv1.VSpherePlatformType: admissionregistrationv1.Ignore,
+	}
+
+	testCases := []struct {
+		name                string
+		platform            configv1.PlatformType
+		controllersReplicas int32
+		expectedPolicy      admissionregistrationv1.FailurePolicyType
+	}{
+		{
+			name:                "fail with several replicas",
+			platform:            configv1.AWSPlatformType,
+			controllersReplicas: 2,
+			expectedPolicy:      admissionregistrationv1.Fail,
+		},
+		{
+			name:                "fail with a single replica",
+			platform:            configv1.AWSPlatformType,
+			controllersReplicas: 1,
+			expectedPolicy:      admissionregistrationv1.Ignore,
+		},
+		{
+			name:                "ignore",
+			platform:            configv1.VSpherePlatformType,
+			controllersReplicas: 2,
+			expectedPolicy:      admissionregistrationv1.Ignore,
+		},
+		{
+			name:                "platform without a policy",
+			platform:            configv1.GCPPlatformType,
+			controllersReplicas: 2,
+			expectedPolicy:      admissionregistrationv1.Ignore,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			kubeClient := fakekube.NewSimpleClientset()
+			optr := &Operator{kubeClient: kubeClient, namespace: targetNamespace, webhookFailurePolicies: policies}
+			config := &OperatorConfig{
+				TargetNamespace:     targetNamespace,
+				PlatformType:        tc.platform,
+				ControllersReplicas: tc.controllersReplicas,
+			}
+			g.Expect(optr.syncWebhookConfiguration(config)).To(Succeed())
+
+			validating, err := kubeClient.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.TODO(), mapiwebhooks.NewValidatingWebhookConfiguration().Name, metav1.GetOptions{})
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(validating.Annotations).To(HaveKeyWithValue(webhookFailurePolicyAnnotation, string(tc.expectedPolicy)))
+			for _, webhook := range validating.Webhooks {
+				g.Expect(webhook.FailurePolicy).To(Equal(&tc.expectedPolicy), webhook.Name)
+			}
+			mutating, err := kubeClient.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(context.TODO(), mapiwebhooks.NewMutatingWebhookConfiguration().Name, metav1.GetOptions{})
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(mutating.Annotations).To(HaveKeyWithValue(webhookFailurePolicyAnnotation, string(tc.expectedPolicy)))
+			for _, webhook := range mutating.Webhooks {
+				g.Expect(webhook.FailurePolicy).To(Equal(&tc.expectedPolicy), webhook.Name)
+			}
+		})
+	}
+}
+
+func TestSyncWebhookConfigurationFailurePolicyChange(t *testing.T) {
+	g := NewWithT(t)
+
+	kubeClient := fakekube.NewSimpleClientset()
+	optr := &Operator{
+		kubeClient: kubeClient,
+		namespace:  targetNamespace,
+		webhookFailurePolicies: map[configv1.PlatformType]admissionregistrationv1.FailurePolicyType{
+			configv1.AWSPlatformType: admissionregistrationv1.Fail,
+		},
+	}
+	config := &OperatorConfig{
+		TargetNamespace:     targetNamespace,
+		PlatformType:        configv1.AWSPlatformType,
+		ControllersReplicas: 2,
+	}
+	g.Expect(optr.syncWebhookConfiguration(config)).To(Succeed())
+
+	// Scaling down to a single replica rolls the webhooks back to Ignore.
+	config.ControllersReplicas = 1
+	g.Expect(optr.syncWebhookConfiguration(config)).To(Succeed())
+
+	validating, err := kubeClient.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.TODO(), mapiwebhooks.NewValidatingWebhookConfiguration().Name, metav1.GetOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	for _, webhook := range validating.Webhooks {
+		g.Expect(*webhook.FailurePolicy).To(Equal(admissionregistrationv1.Ignore), webhook.Name)
+	}
+}
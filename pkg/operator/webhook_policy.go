@@ -0,0 +1,99 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	"github.com/openshift/library-go/pkg/operator/resource/resourcehash"
+	mapiwebhooks "github.com/openshift/machine-api-operator/pkg/webhooks"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// webhookPolicySnapshotName is the ConfigMap aggregating the webhook policy ConfigMaps,
+	// so that the webhook policy can be exported and restored in one piece.
+	webhookPolicySnapshotName = "webhook-policy-snapshot"
+	// webhookPolicySnapshotHashAnnotation is the hash of the snapshot data.
+	webhookPolicySnapshotHashAnnotation = "machine.openshift.io/webhook-policy-snapshot-hash"
+	// webhookPolicySnapshotVersionAnnotation is the version of the snapshot format.
+	// Each key of the snapshot is the name of a policy ConfigMap holding its data as YAML.
+	webhookPolicySnapshotVersionAnnotation = "machine.openshift.io/webhook-policy-snapshot-version"
+	webhookPolicySnapshotVersion           = "v1"
+)
+
+// syncWebhookPolicySnapshot checks that the webhook policy ConfigMaps parse and aggregates them
+// into the webhook policy snapshot. The snapshot is left as is while any policy fails to parse.
+func (optr *Operator) syncWebhookPolicySnapshot(config *OperatorConfig) error {
+	names := make([]string, 0, len(mapiwebhooks.PolicyConfigMaps))
+	for name := range mapiwebhooks.PolicyConfigMaps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var policies []*corev1.ConfigMap
+	var errs []error
+	for _, name := range names {
+		policy, err := optr.kubeClient.CoreV1().ConfigMaps(config.TargetNamespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := mapiwebhooks.PolicyConfigMaps[name](policy); err != nil {
+			errs = append(errs, fmt.Errorf("webhook policy ConfigMap %s/%s does not parse: %w", config.TargetNamespace, name, err))
+			continue
+		}
+		policies = append(policies, policy)
+	}
+	if len(errs) > 0 {
+		return utilerrors.NewAggregate(errs)
+	}
+
+	snapshot, err := newWebhookPolicySnapshot(config.TargetNamespace, policies)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = resourceapply.ApplyConfigMap(context.TODO(), optr.kubeClient.CoreV1(),
+		events.NewLoggingEventRecorder(optr.name), snapshot)
+	return err
+}
+
+// newWebhookPolicySnapshot returns the webhook policy snapshot of the given policy ConfigMaps.
+func newWebhookPolicySnapshot(namespace string, policies []*corev1.ConfigMap) (*corev1.ConfigMap, error) {
+	snapshot := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      webhookPolicySnapshotName,
+			Namespace: namespace,
+			Annotations: map[string]string{
+				webhookPolicySnapshotVersionAnnotation: webhookPolicySnapshotVersion,
+			},
+		},
+		Data: map[string]string{},
+	}
+
+	for _, policy := range policies {
+		data, err := yaml.Marshal(policy.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal webhook policy ConfigMap %s: %w", policy.Name, err)
+		}
+		snapshot.Data[policy.Name] = string(data)
+	}
+
+	hash, err := resourcehash.GetConfigMapHash(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.Annotations[webhookPolicySnapshotHashAnnotation] = hash
+
+	return snapshot, nil
+}
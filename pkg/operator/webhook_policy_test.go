@@ -0,0 +1,121 @@
+package operator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	openshiftv1 "github.com/openshift/api/config/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const webhookPolicyConfigMapName = "machine-api-webhook-policy"
+
+func newWebhookPolicyConfigMap(data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      webhookPolicyConfigMapName,
+			Namespace: targetNamespace,
+		},
+		Data: data,
+	}
+}
+
+func TestSyncWebhookPolicySnapshot(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	policy := newWebhookPolicyConfigMap(map[string]string{"validationMode": "audit"})
+	optr := newFakeOperator([]runtime.Object{policy}, nil, stopCh)
+	config := &OperatorConfig{TargetNamespace: targetNamespace}
+
+	if err := optr.syncWebhookPolicySnapshot(config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	snapshot, err := optr.kubeClient.CoreV1().ConfigMaps(targetNamespace).Get(context.Background(), webhookPolicySnapshotName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get webhook policy snapshot: %v", err)
+	}
+
+	if expected := "validationMode: audit\n"; snapshot.Data[webhookPolicyConfigMapName] != expected {
+		t.Errorf("expected snapshot of %s to be %q, got: %q", webhookPolicyConfigMapName, expected, snapshot.Data[webhookPolicyConfigMapName])
+	}
+	if version := snapshot.Annotations[webhookPolicySnapshotVersionAnnotation]; version != webhookPolicySnapshotVersion {
+		t.Errorf("expected snapshot version %q, got: %q", webhookPolicySnapshotVersion, version)
+	}
+	if snapshot.Annotations[webhookPolicySnapshotHashAnnotation] == "" {
+		t.Errorf("expected snapshot to have a hash")
+	}
+}
+
+func TestWebhookPolicySnapshotHash(t *testing.T) {
+	policy := newWebhookPolicyConfigMap(map[string]string{"validationMode": "audit"})
+
+	first, err := newWebhookPolicySnapshot(targetNamespace, []*corev1.ConfigMap{policy})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := newWebhookPolicySnapshot(targetNamespace, []*corev1.ConfigMap{policy.DeepCopy()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first.Annotations[webhookPolicySnapshotHashAnnotation] != second.Annotations[webhookPolicySnapshotHashAnnotation] {
+		t.Errorf("expected the hash of identical policies to be stable, got: %q and %q",
+			first.Annotations[webhookPolicySnapshotHashAnnotation], second.Annotations[webhookPolicySnapshotHashAnnotation])
+	}
+
+	changed := newWebhookPolicyConfigMap(map[string]string{"validationMode": "enforce"})
+	third, err := newWebhookPolicySnapshot(targetNamespace, []*corev1.ConfigMap{changed})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first.Annotations[webhookPolicySnapshotHashAnnotation] == third.Annotations[webhookPolicySnapshotHashAnnotation] {
+		t.Errorf("expected the hash to change when a policy changes")
+	}
+}
+
+func TestSyncWebhookPolicySnapshotDegraded(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	policy := newWebhookPolicyConfigMap(map[string]string{"validationMode": "{audit"})
+	optr := newFakeOperator([]runtime.Object{policy}, nil, stopCh)
+	config := &OperatorConfig{
+		TargetNamespace: targetNamespace,
+		Controllers: Controllers{
+			Provider:   "provider-image",
+			MachineSet: "machineset-image",
+		},
+	}
+
+	if _, err := optr.syncAll(config); err == nil {
+		t.Fatal("expected sync to fail on a malformed webhook policy")
+	}
+
+	co, err := optr.osClient.ConfigV1().ClusterOperators().Get(context.Background(), clusterOperatorName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get clusteroperator: %v", err)
+	}
+
+	degraded := false
+	for _, c := range co.Status.Conditions {
+		if c.Type != openshiftv1.OperatorDegraded {
+			continue
+		}
+		degraded = c.Status == openshiftv1.ConditionTrue
+		if !strings.Contains(c.Message, "webhook policy ConfigMap test-namespace/machine-api-webhook-policy does not parse") {
+			t.Errorf("expected the degraded message to name the malformed policy, got: %q", c.Message)
+		}
+	}
+	if !degraded {
+		t.Errorf("expected the operator to be degraded, got conditions: %v", co.Status.Conditions)
+	}
+
+	if _, err := optr.kubeClient.CoreV1().ConfigMaps(targetNamespace).Get(context.Background(), webhookPolicySnapshotName, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected no snapshot to be written while a policy does not parse, got: %v", err)
+	}
+}
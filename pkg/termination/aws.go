@@ -0,0 +1,75 @@
+package termination
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+const (
+	awsMetadataEndpoint = "http://169.254.169.254"
+
+	awsTokenPath          = "/latest/api/token"
+	awsInstanceActionPath = "/latest/meta-data/spot/instance-action"
+
+	awsTokenTTLHeader = "X-aws-ec2-metadata-token-ttl-seconds"
+	awsTokenHeader    = "X-aws-ec2-metadata-token"
+	awsTokenTTL       = "300"
+)
+
+// awsNoticeWatcher watches the spot instance interruption notice of the EC2 instance metadata service.
+// The instance-action is only published once the instance is scheduled for interruption.
+type awsNoticeWatcher struct {
+	endpoint   string
+	httpClient *http.Client
+}
+
+func newAWSNoticeWatcher(endpoint string, httpClient *http.Client) *awsNoticeWatcher {
+	return &awsNoticeWatcher{endpoint: endpoint, httpClient: httpClient}
+}
+
+func (w *awsNoticeWatcher) TerminationNoticed(ctx context.Context) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.endpoint+awsInstanceActionPath, nil)
+	if err != nil {
+		return false, err
+	}
+	token, err := w.token(ctx)
+	if err != nil {
+		return false, err
+	}
+	if token != "" {
+		req.Header.Set(awsTokenHeader, token)
+	}
+
+	code, _, err := getMetadata(w.httpClient, req)
+	if err != nil {
+		return false, err
+	}
+	switch code {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected status code %d from %s", code, req.URL)
+	}
+}
+
+// token returns a session token of the instance metadata service (IMDSv2). No token is returned when the
+// instance metadata service does not issue tokens, in which case requests are sent without (IMDSv1).
+func (w *awsNoticeWatcher) token(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, w.endpoint+awsTokenPath, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set(awsTokenTTLHeader, awsTokenTTL)
+
+	code, body, err := getMetadata(w.httpClient, req)
+	if err != nil {
+		return "", err
+	}
+	if code != http.StatusOK {
+		return "", nil
+	}
+	return body, nil
+}
@@ -0,0 +1,92 @@
+package termination
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	azureMetadataEndpoint = "http://169.254.169.254"
+
+	azureScheduledEventsPath = "/metadata/scheduledevents?api-version=2020-07-01"
+	azureComputeNamePath     = "/metadata/instance/compute/name?api-version=2020-09-01&format=text"
+
+	azureMetadataHeader = "Metadata"
+
+	// azurePreemptEventType is the type of the scheduled event of the eviction of a spot virtual machine.
+	azurePreemptEventType = "Preempt"
+)
+
+// azureScheduledEvents is the document of the Scheduled Events service.
+type azureScheduledEvents struct {
+	Events []azureScheduledEvent `json:"Events"`
+}
+
+type azureScheduledEvent struct {
+	EventType string   `json:"EventType"`
+	Resources []string `json:"Resources"`
+}
+
+// azureNoticeWatcher watches the Scheduled Events of the Azure instance metadata service for the eviction of
+// the spot virtual machine. Events are published for all the virtual machines of the availability set or
+// scale set, only those naming the virtual machine are considered.
+type azureNoticeWatcher struct {
+	endpoint   string
+	httpClient *http.Client
+	vmName     string
+}
+
+func newAzureNoticeWatcher(endpoint string, httpClient *http.Client) *azureNoticeWatcher {
+	return &azureNoticeWatcher{endpoint: endpoint, httpClient: httpClient}
+}
+
+func (w *azureNoticeWatcher) TerminationNoticed(ctx context.Context) (bool, error) {
+	if w.vmName == "" {
+		vmName, err := w.get(ctx, azureComputeNamePath)
+		if err != nil {
+			return false, fmt.Errorf("error fetching the virtual machine name: %w", err)
+		}
+		w.vmName = strings.TrimSpace(vmName)
+	}
+
+	body, err := w.get(ctx, azureScheduledEventsPath)
+	if err != nil {
+		return false, err
+	}
+	events := &azureScheduledEvents{}
+	if err := json.Unmarshal([]byte(body), events); err != nil {
+		return false, fmt.Errorf("error decoding the scheduled events: %w", err)
+	}
+
+	for _, event := range events.Events {
+		if event.EventType != azurePreemptEventType {
+			continue
+		}
+		for _, resource := range event.Resources {
+			if strings.EqualFold(resource, w.vmName) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (w *azureNoticeWatcher) get(ctx context.Context, path string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.endpoint+path, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set(azureMetadataHeader, "true")
+
+	code, body, err := getMetadata(w.httpClient, req)
+	if err != nil {
+		return "", err
+	}
+	if code != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code %d from %s", code, req.URL)
+	}
+	return body, nil
+}
@@ -0,0 +1,45 @@
+package termination
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	gcpMetadataEndpoint = "http://metadata.google.internal"
+
+	gcpPreemptedPath = "/computeMetadata/v1/instance/preempted"
+
+	gcpMetadataFlavorHeader = "Metadata-Flavor"
+	gcpMetadataFlavor       = "Google"
+)
+
+// gcpNoticeWatcher watches the preemption signal of the GCE metadata server, which turns TRUE once the
+// preemptible instance is being preempted.
+type gcpNoticeWatcher struct {
+	endpoint   string
+	httpClient *http.Client
+}
+
+func newGCPNoticeWatcher(endpoint string, httpClient *http.Client) *gcpNoticeWatcher {
+	return &gcpNoticeWatcher{endpoint: endpoint, httpClient: httpClient}
+}
+
+func (w *gcpNoticeWatcher) TerminationNoticed(ctx context.Context) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.endpoint+gcpPreemptedPath, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set(gcpMetadataFlavorHeader, gcpMetadataFlavor)
+
+	code, body, err := getMetadata(w.httpClient, req)
+	if err != nil {
+		return false, err
+	}
+	if code != http.StatusOK {
+		return false, fmt.Errorf("unexpected status code %d from %s", code, req.URL)
+	}
+	return strings.TrimSpace(body) == "TRUE", nil
+}
@@ -0,0 +1,106 @@
+package termination
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// NodeTerminatingConditionType is the type of the node condition set once the instance of the node
+	// is being terminated. The machine-api-termination-handler MachineHealthCheck deletes the machines
+	// of the nodes with this condition, so that they are drained before the instance goes away.
+	NodeTerminatingConditionType corev1.NodeConditionType = "Terminating"
+
+	// TerminationRequestedReason is the reason of the Terminating condition set by the handler.
+	TerminationRequestedReason = "TerminationRequested"
+)
+
+// Handler watches the termination notice of the instance of its node and marks the node as terminating
+// once the cloud provider notices the termination.
+type Handler struct {
+	client       client.Client
+	watcher      NoticeWatcher
+	nodeName     string
+	pollInterval time.Duration
+}
+
+// NewHandler returns a handler polling the termination notice of the watcher for the node every poll interval.
+func NewHandler(c client.Client, watcher NoticeWatcher, nodeName string, pollInterval time.Duration) *Handler {
+	return &Handler{
+		client:       c,
+		watcher:      watcher,
+		nodeName:     nodeName,
+		pollInterval: pollInterval,
+	}
+}
+
+// Run polls the termination notice until the termination is noticed, then marks the node as terminating.
+// It returns without error when the context is cancelled first.
+func (h *Handler) Run(ctx context.Context) error {
+	klog.Infof("Polling the termination notice of node %s every %v", h.nodeName, h.pollInterval)
+
+	err := wait.PollImmediateUntil(h.pollInterval, func() (bool, error) {
+		noticed, err := h.watcher.TerminationNoticed(ctx)
+		if err != nil {
+			// The metadata service may be briefly unavailable, keep polling.
+			klog.Errorf("Unable to check the termination notice of node %s: %v", h.nodeName, err)
+			return false, nil
+		}
+		return noticed, nil
+	}, ctx.Done())
+	if errors.Is(err, wait.ErrWaitTimeout) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	klog.Infof("Instance of node %s is being terminated, marking the node for deletion", h.nodeName)
+	return h.markNodeAsTerminating(ctx)
+}
+
+// markNodeAsTerminating sets the Terminating condition of the node, unless already set.
+func (h *Handler) markNodeAsTerminating(ctx context.Context) error {
+	node := &corev1.Node{}
+	if err := h.client.Get(ctx, client.ObjectKey{Name: h.nodeName}, node); err != nil {
+		return fmt.Errorf("error fetching node %s: %w", h.nodeName, err)
+	}
+
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == NodeTerminatingConditionType && condition.Status == corev1.ConditionTrue {
+			return nil
+		}
+	}
+
+	baseToPatch := client.MergeFrom(node.DeepCopy())
+	now := metav1.Now()
+	terminating := corev1.NodeCondition{
+		Type:               NodeTerminatingConditionType,
+		Status:             corev1.ConditionTrue,
+		Reason:             TerminationRequestedReason,
+		Message:            "The cloud provider has marked this instance for termination",
+		LastHeartbeatTime:  now,
+		LastTransitionTime: now,
+	}
+
+	conditions := []corev1.NodeCondition{terminating}
+	for _, condition := range node.Status.Conditions {
+		if condition.Type != NodeTerminatingConditionType {
+			conditions = append(conditions, condition)
+		}
+	}
+	node.Status.Conditions = conditions
+
+	if err := h.client.Status().Patch(ctx, node, baseToPatch); err != nil {
+		return fmt.Errorf("error marking node %s as terminating: %w", h.nodeName, err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package termination
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeNoticeWatcher notices the termination after the given number of polls, failing the first poll.
+type fakeNoticeWatcher struct {
+	polls        int
+	noticedAfter int
+}
+
+func (w *fakeNoticeWatcher) TerminationNoticed(_ context.Context) (bool, error) {
+	w.polls++
+	if w.polls == 1 {
+		return false, errors.New("metadata service unavailable")
+	}
+	return w.polls > w.noticedAfter, nil
+}
+
+func TestHandlerRun(t *testing.T) {
+	g := NewWithT(t)
+
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node"},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+			},
+		},
+	}
+	c := fake.NewClientBuilder().WithObjects(node).Build()
+
+	watcher := &fakeNoticeWatcher{noticedAfter: 2}
+	handler := NewHandler(c, watcher, "node", time.Millisecond)
+	g.Expect(handler.Run(context.Background())).To(Succeed())
+	g.Expect(watcher.polls).To(Equal(3))
+
+	g.Expect(c.Get(context.Background(), client.ObjectKey{Name: "node"}, node)).To(Succeed())
+	g.Expect(node.Status.Conditions).To(HaveLen(2))
+	var terminating *corev1.NodeCondition
+	for i := range node.Status.Conditions {
+		if node.Status.Conditions[i].Type == NodeTerminatingConditionType {
+			terminating = &node.Status.Conditions[i]
+		}
+	}
+	g.Expect(terminating).ToNot(BeNil())
+	g.Expect(terminating.Status).To(Equal(corev1.ConditionTrue))
+	g.Expect(terminating.Reason).To(Equal(TerminationRequestedReason))
+
+	// Marking the node again does not change it.
+	resourceVersion := node.ResourceVersion
+	g.Expect(handler.markNodeAsTerminating(context.Background())).To(Succeed())
+	g.Expect(c.Get(context.Background(), client.ObjectKey{Name: "node"}, node)).To(Succeed())
+	g.Expect(node.ResourceVersion).To(Equal(resourceVersion))
+}
+
+func TestHandlerRunStopped(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fake.NewClientBuilder().Build()
+	handler := NewHandler(c, &fakeNoticeWatcher{noticedAfter: 1000}, "node", time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	g.Expect(handler.Run(ctx)).To(Succeed())
+}
@@ -0,0 +1,58 @@
+package termination
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+)
+
+// NoticeWatcher checks the termination notice the cloud provider publishes for the instance it runs on.
+type NoticeWatcher interface {
+	// TerminationNoticed returns whether the cloud provider has scheduled the termination of the instance.
+	TerminationNoticed(ctx context.Context) (bool, error)
+}
+
+// NewNoticeWatcher returns the termination notice watcher of the platform, querying the metadata service
+// at the endpoint. An empty endpoint uses the metadata service of the platform.
+func NewNoticeWatcher(platform configv1.PlatformType, endpoint string, httpClient *http.Client) (NoticeWatcher, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	switch platform {
+	case configv1.AWSPlatformType:
+		return newAWSNoticeWatcher(endpointOrDefault(endpoint, awsMetadataEndpoint), httpClient), nil
+	case configv1.GCPPlatformType:
+		return newGCPNoticeWatcher(endpointOrDefault(endpoint, gcpMetadataEndpoint), httpClient), nil
+	case configv1.AzurePlatformType:
+		return newAzureNoticeWatcher(endpointOrDefault(endpoint, azureMetadataEndpoint), httpClient), nil
+	default:
+		return nil, fmt.Errorf("termination notices are not supported on platform %q", platform)
+	}
+}
+
+func endpointOrDefault(endpoint, defaultEndpoint string) string {
+	if endpoint == "" {
+		return defaultEndpoint
+	}
+	return strings.TrimSuffix(endpoint, "/")
+}
+
+// getMetadata sends the request to the metadata service and returns the status code and body of the response.
+func getMetadata(httpClient *http.Client, req *http.Request) (int, string, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("error requesting %s: %w", req.URL, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, "", fmt.Errorf("error reading the response of %s: %w", req.URL, err)
+	}
+	return resp.StatusCode, string(body), nil
+}
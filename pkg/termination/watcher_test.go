@@ -0,0 +1,197 @@
+package termination
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	configv1 "github.com/openshift/api/config/v1"
+)
+
+func TestAWSNoticeWatcher(t *testing.T) {
+	testCases := []struct {
+		name            string
+		issueToken      bool
+		instanceAction  int
+		expectedNoticed bool
+		expectedError   bool
+	}{
+		{
+			name:           "without interruption notice",
+			issueToken:     true,
+			instanceAction: http.StatusNotFound,
+		},
+		{
+			name:            "with an interruption notice",
+			issueToken:      true,
+			instanceAction:  http.StatusOK,
+			expectedNoticed: true,
+		},
+		{
+			name:            "with an interruption notice without session token",
+			instanceAction:  http.StatusOK,
+			expectedNoticed: true,
+		},
+		{
+			name:           "with an unexpected status code",
+			issueToken:     true,
+			instanceAction: http.StatusInternalServerError,
+			expectedError:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case awsTokenPath:
+					if !tc.issueToken {
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					w.Write([]byte("token"))
+				case awsInstanceActionPath:
+					expectedToken := ""
+					if tc.issueToken {
+						expectedToken = "token"
+					}
+					if r.Header.Get(awsTokenHeader) != expectedToken {
+						w.WriteHeader(http.StatusUnauthorized)
+						return
+					}
+					w.WriteHeader(tc.instanceAction)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			watcher, err := NewNoticeWatcher(configv1.AWSPlatformType, server.URL, nil)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			noticed, err := watcher.TerminationNoticed(context.Background())
+			if tc.expectedError {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(noticed).To(Equal(tc.expectedNoticed))
+		})
+	}
+}
+
+func TestGCPNoticeWatcher(t *testing.T) {
+	testCases := []struct {
+		name            string
+		preempted       string
+		expectedNoticed bool
+	}{
+		{
+			name:      "without preemption",
+			preempted: "FALSE",
+		},
+		{
+			name:            "with preemption",
+			preempted:       "TRUE",
+			expectedNoticed: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != gcpPreemptedPath || r.Header.Get(gcpMetadataFlavorHeader) != gcpMetadataFlavor {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.Write([]byte(tc.preempted))
+			}))
+			defer server.Close()
+
+			watcher, err := NewNoticeWatcher(configv1.GCPPlatformType, server.URL, nil)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			noticed, err := watcher.TerminationNoticed(context.Background())
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(noticed).To(Equal(tc.expectedNoticed))
+		})
+	}
+}
+
+func TestAzureNoticeWatcher(t *testing.T) {
+	testCases := []struct {
+		name            string
+		events          string
+		expectedNoticed bool
+		expectedError   bool
+	}{
+		{
+			name:   "without scheduled events",
+			events: `{"DocumentIncarnation": 1, "Events": []}`,
+		},
+		{
+			name:   "with a preemption of another virtual machine",
+			events: `{"DocumentIncarnation": 2, "Events": [{"EventId": "1", "EventType": "Preempt", "Resources": ["other-vm"]}]}`,
+		},
+		{
+			name:   "with another event of the virtual machine",
+			events: `{"DocumentIncarnation": 2, "Events": [{"EventId": "1", "EventType": "Reboot", "Resources": ["spot-vm"]}]}`,
+		},
+		{
+			name:            "with a preemption of the virtual machine",
+			events:          `{"DocumentIncarnation": 3, "Events": [{"EventId": "1", "EventType": "Preempt", "Resources": ["other-vm", "SPOT-VM"]}]}`,
+			expectedNoticed: true,
+		},
+		{
+			name:          "with invalid scheduled events",
+			events:        `{`,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get(azureMetadataHeader) != "true" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				switch r.URL.RequestURI() {
+				case azureComputeNamePath:
+					w.Write([]byte("spot-vm"))
+				case azureScheduledEventsPath:
+					w.Write([]byte(tc.events))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			watcher, err := NewNoticeWatcher(configv1.AzurePlatformType, server.URL, nil)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			noticed, err := watcher.TerminationNoticed(context.Background())
+			if tc.expectedError {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(noticed).To(Equal(tc.expectedNoticed))
+		})
+	}
+}
+
+func TestNewNoticeWatcherUnsupportedPlatform(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := NewNoticeWatcher(configv1.VSpherePlatformType, "", nil)
+	g.Expect(err).To(HaveOccurred())
+}
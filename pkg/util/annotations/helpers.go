@@ -2,6 +2,8 @@
 package annotations
 
 import (
+	"strings"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -10,8 +12,32 @@ const (
 	// from processing it.
 	// TODO: move this annotation to the openshift/api package
 	PausedAnnotation = "cluster.x-k8s.io/paused"
+
+	// MachinePausedAnnotation is an annotation that can be applied to Machine objects to freeze their automation:
+	// the machine controller does not reconcile or drain them, MachineSets do not delete or replace them,
+	// and MachineHealthChecks do not remediate them.
+	MachinePausedAnnotation = "machine.openshift.io/paused"
 )
 
+// NodeManagedAnnotationPrefixes are the prefixes of Node annotations which are owned by the kubelet,
+// CSI drivers or other node components. Setting these from a Machine would fight their owner.
+var NodeManagedAnnotationPrefixes = []string{
+	"volumes.kubernetes.io/",
+	"csi.volume.kubernetes.io/",
+	"node.alpha.kubernetes.io/ttl",
+	"machineconfiguration.openshift.io/",
+}
+
+// IsNodeManaged returns true if the Node annotation key is owned by a node component.
+func IsNodeManaged(key string) bool {
+	for _, prefix := range NodeManagedAnnotationPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 // IsPaused returns true if the Cluster is paused or the object has the `paused` annotation.
 func IsPaused(o metav1.Object) bool {
 	return HasPausedAnnotation(o)
@@ -22,6 +48,11 @@ func HasPausedAnnotation(o metav1.Object) bool {
 	return hasAnnotation(o, PausedAnnotation)
 }
 
+// IsMachinePaused returns true if the Machine has the `machine.openshift.io/paused` annotation.
+func IsMachinePaused(o metav1.Object) bool {
+	return hasAnnotation(o, MachinePausedAnnotation)
+}
+
 // hasAnnotation returns true if the object has the specified annotation.
 func hasAnnotation(o metav1.Object, annotation string) bool {
 	annotations := o.GetAnnotations()
@@ -0,0 +1,58 @@
+package util
+
+import (
+	"flag"
+	"fmt"
+)
+
+const (
+	maxConcurrentReconcilesFlag    = "max-concurrent-reconciles"
+	maxConcurrentDrainsPerZoneFlag = "max-concurrent-drains-per-zone"
+)
+
+// ConcurrencyOptions are the concurrency options of the machine controller commands, which the operator
+// passes on to the machine controllers it deploys.
+type ConcurrencyOptions struct {
+	MaxConcurrentReconciles    int
+	MaxConcurrentDrainsPerZone int
+}
+
+// AddConcurrencyFlags defines the concurrency flags in the flag set and returns their options.
+func AddConcurrencyFlags(fs *flag.FlagSet) *ConcurrencyOptions {
+	o := &ConcurrencyOptions{}
+	fs.IntVar(&o.MaxConcurrentReconciles,
+		maxConcurrentReconcilesFlag,
+		1,
+		"The number of machines reconciled in parallel. Drains of machines in different availability zones proceed in parallel when greater than 1.",
+	)
+	fs.IntVar(&o.MaxConcurrentDrainsPerZone,
+		maxConcurrentDrainsPerZoneFlag,
+		0,
+		"The maximum number of machines of an availability zone draining their node at the same time. Zero means no limit.",
+	)
+	return o
+}
+
+// Validate returns an error when the options are out of range.
+func (o *ConcurrencyOptions) Validate() error {
+	if o.MaxConcurrentReconciles < 0 {
+		return fmt.Errorf("max concurrent reconciles %d must not be negative", o.MaxConcurrentReconciles)
+	}
+	if o.MaxConcurrentDrainsPerZone < 0 {
+		return fmt.Errorf("max concurrent drains per zone %d must not be negative", o.MaxConcurrentDrainsPerZone)
+	}
+	return nil
+}
+
+// Args returns the flags setting the options, omitting the ones left unset so that the machine controllers
+// keep their defaults.
+func (o *ConcurrencyOptions) Args() []string {
+	var args []string
+	if o.MaxConcurrentReconciles > 0 {
+		args = append(args, fmt.Sprintf("--%s=%d", maxConcurrentReconcilesFlag, o.MaxConcurrentReconciles))
+	}
+	if o.MaxConcurrentDrainsPerZone > 0 {
+		args = append(args, fmt.Sprintf("--%s=%d", maxConcurrentDrainsPerZoneFlag, o.MaxConcurrentDrainsPerZone))
+	}
+	return args
+}
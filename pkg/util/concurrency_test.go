@@ -0,0 +1,73 @@
+package util
+
+import (
+	"flag"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestConcurrencyFlags(t *testing.T) {
+	testCases := []struct {
+		name          string
+		args          []string
+		expected      ConcurrencyOptions
+		expectedArgs  []string
+		expectedError string
+	}{
+		{
+			name:         "defaults",
+			expected:     ConcurrencyOptions{MaxConcurrentReconciles: 1},
+			expectedArgs: []string{"--max-concurrent-reconciles=1"},
+		},
+		{
+			name:     "all flags",
+			args:     []string{"--max-concurrent-reconciles=5", "--max-concurrent-drains-per-zone=2"},
+			expected: ConcurrencyOptions{MaxConcurrentReconciles: 5, MaxConcurrentDrainsPerZone: 2},
+			expectedArgs: []string{
+				"--max-concurrent-reconciles=5",
+				"--max-concurrent-drains-per-zone=2",
+			},
+		},
+		{
+			name:          "negative max concurrent reconciles",
+			args:          []string{"--max-concurrent-reconciles=-1"},
+			expectedError: "max concurrent reconciles -1 must not be negative",
+		},
+		{
+			name:          "negative max concurrent drains per zone",
+			args:          []string{"--max-concurrent-drains-per-zone=-1"},
+			expectedError: "max concurrent drains per zone -1 must not be negative",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			fs := flag.NewFlagSet(tc.name, flag.ContinueOnError)
+			o := AddConcurrencyFlags(fs)
+			g.Expect(fs.Parse(tc.args)).To(Succeed())
+
+			if tc.expectedError != "" {
+				g.Expect(o.Validate()).To(MatchError(tc.expectedError))
+				return
+			}
+			g.Expect(o.Validate()).To(Succeed())
+			g.Expect(*o).To(Equal(tc.expected))
+			g.Expect(o.Args()).To(Equal(tc.expectedArgs))
+
+			// The machine controllers parse the args back to the same options.
+			fs = flag.NewFlagSet(tc.name, flag.ContinueOnError)
+			parsed := AddConcurrencyFlags(fs)
+			g.Expect(fs.Parse(o.Args())).To(Succeed())
+			g.Expect(*parsed).To(Equal(*o))
+		})
+	}
+}
+
+func TestConcurrencyOptionsArgsUnset(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect((&ConcurrencyOptions{}).Args()).To(BeEmpty())
+}
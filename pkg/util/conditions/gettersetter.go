@@ -145,6 +145,8 @@ func getWrapperObject(from interface{}) GetterSetter {
 		return &MachineWrapper{obj}
 	case *machinev1.MachineHealthCheck:
 		return &MachineHealthCheckWrapper{obj}
+	case GetterSetter:
+		return obj
 	default:
 		panic("type is not supported as conditions getter or setter")
 	}
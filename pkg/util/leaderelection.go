@@ -0,0 +1,99 @@
+package util
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// ResourceLock is the default type of the leader election lock. Locking both the ConfigMap and the Lease
+// lets controllers still locking the ConfigMap only, eg. during an upgrade, take part in the election.
+const ResourceLock = resourcelock.ConfigMapsLeasesResourceLock
+
+var resourceLocks = sets.NewString(
+	resourcelock.EndpointsResourceLock,
+	resourcelock.ConfigMapsResourceLock,
+	resourcelock.LeasesResourceLock,
+	resourcelock.EndpointsLeasesResourceLock,
+	resourcelock.ConfigMapsLeasesResourceLock,
+)
+
+// LeaderElectionOptions are the leader election options of the controller commands, so that a single one
+// of their replicas is active while the others wait to take over.
+type LeaderElectionOptions struct {
+	LeaderElect       bool
+	ResourceNamespace string
+	ResourceLock      string
+	LeaseDuration     time.Duration
+	RenewDeadline     time.Duration
+	RetryPeriod       time.Duration
+}
+
+// AddLeaderElectionFlags defines the leader election flags in the flag set and returns their options.
+func AddLeaderElectionFlags(fs *flag.FlagSet) *LeaderElectionOptions {
+	o := &LeaderElectionOptions{}
+	fs.BoolVar(&o.LeaderElect,
+		"leader-elect",
+		false,
+		"Start a leader election client and gain leadership before executing the main loop. Enable this when running replicated components for high availability.",
+	)
+	fs.StringVar(&o.ResourceNamespace,
+		"leader-elect-resource-namespace",
+		"",
+		"The namespace of resource object that is used for locking during leader election. If unspecified and running in cluster, defaults to the service account namespace for the controller. Required for leader-election outside of a cluster.",
+	)
+	fs.StringVar(&o.ResourceLock,
+		"leader-elect-resource-lock",
+		ResourceLock,
+		fmt.Sprintf("The type of resource object that is used for locking during leader election. One of %q.", resourceLocks.List()),
+	)
+	fs.DurationVar(&o.LeaseDuration,
+		"leader-elect-lease-duration",
+		LeaseDuration,
+		"The duration that non-leader candidates will wait after observing a leadership renewal until attempting to acquire leadership of a led but unrenewed leader slot. This is effectively the maximum duration that a leader can be stopped before it is replaced by another candidate. This is only applicable if leader election is enabled.",
+	)
+	fs.DurationVar(&o.RenewDeadline,
+		"leader-elect-renew-deadline",
+		RenewDeadline,
+		"The interval between attempts by the acting master to renew a leadership slot before it stops leading. This must be less than the lease duration. This is only applicable if leader election is enabled.",
+	)
+	fs.DurationVar(&o.RetryPeriod,
+		"leader-elect-retry-period",
+		RetryPeriod,
+		"The duration the clients should wait between attempting acquisition and renewal of a leadership. This is only applicable if leader election is enabled.",
+	)
+	return o
+}
+
+// Validate returns an error when the leader election would fail to start with the options.
+func (o *LeaderElectionOptions) Validate() error {
+	if !o.LeaderElect {
+		return nil
+	}
+	if !resourceLocks.Has(o.ResourceLock) {
+		return fmt.Errorf("invalid resource lock %q: must be one of %q", o.ResourceLock, resourceLocks.List())
+	}
+	if o.LeaseDuration <= o.RenewDeadline {
+		return fmt.Errorf("lease duration %s must be greater than renew deadline %s", o.LeaseDuration, o.RenewDeadline)
+	}
+	if o.RenewDeadline <= time.Duration(leaderelection.JitterFactor*float64(o.RetryPeriod)) {
+		return fmt.Errorf("renew deadline %s must be greater than %v times retry period %s", o.RenewDeadline, leaderelection.JitterFactor, o.RetryPeriod)
+	}
+	return nil
+}
+
+// ApplyTo sets the leader election options of the manager options, electing the leader with the lock named id.
+func (o *LeaderElectionOptions) ApplyTo(opts *manager.Options, id string) {
+	opts.LeaderElection = o.LeaderElect
+	opts.LeaderElectionNamespace = o.ResourceNamespace
+	opts.LeaderElectionResourceLock = o.ResourceLock
+	opts.LeaderElectionID = id
+	opts.LeaseDuration = TimeDuration(o.LeaseDuration)
+	opts.RenewDeadline = TimeDuration(o.RenewDeadline)
+	opts.RetryPeriod = TimeDuration(o.RetryPeriod)
+}
@@ -0,0 +1,113 @@
+package util
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+func TestLeaderElectionFlags(t *testing.T) {
+	testCases := []struct {
+		name          string
+		args          []string
+		expected      LeaderElectionOptions
+		expectedError string
+	}{
+		{
+			name: "defaults",
+			expected: LeaderElectionOptions{
+				ResourceLock:  ResourceLock,
+				LeaseDuration: LeaseDuration,
+				RenewDeadline: RenewDeadline,
+				RetryPeriod:   RetryPeriod,
+			},
+		},
+		{
+			name: "all flags",
+			args: []string{
+				"--leader-elect=true",
+				"--leader-elect-resource-namespace=openshift-machine-api",
+				"--leader-elect-resource-lock=leases",
+				"--leader-elect-lease-duration=120s",
+				"--leader-elect-renew-deadline=90s",
+				"--leader-elect-retry-period=20s",
+			},
+			expected: LeaderElectionOptions{
+				LeaderElect:       true,
+				ResourceNamespace: "openshift-machine-api",
+				ResourceLock:      "leases",
+				LeaseDuration:     120 * time.Second,
+				RenewDeadline:     90 * time.Second,
+				RetryPeriod:       20 * time.Second,
+			},
+		},
+		{
+			name:          "invalid resource lock",
+			args:          []string{"--leader-elect=true", "--leader-elect-resource-lock=secrets"},
+			expectedError: `invalid resource lock "secrets": must be one of ["configmaps" "configmapsleases" "endpoints" "endpointsleases" "leases"]`,
+		},
+		{
+			name:          "renew deadline not less than lease duration",
+			args:          []string{"--leader-elect=true", "--leader-elect-lease-duration=90s", "--leader-elect-renew-deadline=90s"},
+			expectedError: "lease duration 1m30s must be greater than renew deadline 1m30s",
+		},
+		{
+			name:          "retry period too long for renew deadline",
+			args:          []string{"--leader-elect=true", "--leader-elect-retry-period=90s"},
+			expectedError: "renew deadline 1m47s must be greater than 1.2 times retry period 1m30s",
+		},
+		{
+			name: "invalid options without leader election",
+			args: []string{"--leader-elect-resource-lock=secrets"},
+			expected: LeaderElectionOptions{
+				ResourceLock:  "secrets",
+				LeaseDuration: LeaseDuration,
+				RenewDeadline: RenewDeadline,
+				RetryPeriod:   RetryPeriod,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			fs := flag.NewFlagSet(tc.name, flag.ContinueOnError)
+			o := AddLeaderElectionFlags(fs)
+			g.Expect(fs.Parse(tc.args)).To(Succeed())
+
+			if tc.expectedError != "" {
+				g.Expect(o.Validate()).To(MatchError(tc.expectedError))
+				return
+			}
+			g.Expect(o.Validate()).To(Succeed())
+			g.Expect(*o).To(Equal(tc.expected))
+		})
+	}
+}
+
+func TestLeaderElectionOptionsApplyTo(t *testing.T) {
+	g := NewWithT(t)
+
+	o := &LeaderElectionOptions{
+		LeaderElect:       true,
+		ResourceNamespace: "openshift-machine-api",
+		ResourceLock:      "leases",
+		LeaseDuration:     120 * time.Second,
+		RenewDeadline:     90 * time.Second,
+		RetryPeriod:       20 * time.Second,
+	}
+	opts := manager.Options{}
+	o.ApplyTo(&opts, "cluster-api-provider-machineset-leader")
+
+	g.Expect(opts.LeaderElection).To(BeTrue())
+	g.Expect(opts.LeaderElectionNamespace).To(Equal("openshift-machine-api"))
+	g.Expect(opts.LeaderElectionResourceLock).To(Equal("leases"))
+	g.Expect(opts.LeaderElectionID).To(Equal("cluster-api-provider-machineset-leader"))
+	g.Expect(opts.LeaseDuration).To(Equal(TimeDuration(120 * time.Second)))
+	g.Expect(opts.RenewDeadline).To(Equal(TimeDuration(90 * time.Second)))
+	g.Expect(opts.RetryPeriod).To(Equal(TimeDuration(20 * time.Second)))
+}
@@ -0,0 +1,90 @@
+// Package machineapierrors defines the errors actuators return to classify the failures of the cloud provider,
+// which the machine controller records in the ErrorReason and ErrorMessage of the machine status.
+package machineapierrors
+
+import (
+	"errors"
+	"fmt"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+)
+
+// UnauthorizedMachineError indicates that the credentials of the cloud provider were rejected, or do not
+// allow managing the instance of the machine.
+const UnauthorizedMachineError machinev1.MachineStatusError = "Unauthorized"
+
+// terminalReasons are the reasons of the errors which fail the creation of machines. Retrying cannot
+// fix them until the configuration of the machine is changed. Unauthorized and insufficient resources
+// errors are retried with backoff instead, as rotated credentials and released quota fix them without
+// any change to the machine.
+var terminalReasons = map[machinev1.MachineStatusError]bool{
+	machinev1.InvalidConfigurationMachineError: true,
+}
+
+// A more descriptive kind of error that represents an error condition that
+// should be set in the Machine.Status. The "Reason" field is meant for short,
+// enum-style constants meant to be interpreted by machines. The "Message"
+// field is meant to be read by humans.
+type MachineError struct {
+	Reason  machinev1.MachineStatusError
+	Message string
+}
+
+func (e *MachineError) Error() string {
+	return e.Message
+}
+
+// New returns an error of the reason. The arguments are Printf-style varargs fed into Sprintf to
+// construct the message.
+func New(reason machinev1.MachineStatusError, msg string, args ...interface{}) *MachineError {
+	return &MachineError{
+		Reason:  reason,
+		Message: fmt.Sprintf(msg, args...),
+	}
+}
+
+// InvalidConfiguration returns an error for a machine whose provider spec cannot be satisfied,
+// eg. an unknown instance type or a missing credentials secret.
+func InvalidConfiguration(msg string, args ...interface{}) *MachineError {
+	return New(machinev1.InvalidConfigurationMachineError, msg, args...)
+}
+
+// InsufficientResources returns an error for a machine whose instance cannot be created because
+// the cloud provider account is out of quota or capacity.
+func InsufficientResources(msg string, args ...interface{}) *MachineError {
+	return New(machinev1.InsufficientResourcesMachineError, msg, args...)
+}
+
+// Unauthorized returns an error for a machine whose instance cannot be managed with the credentials
+// of the cloud provider.
+func Unauthorized(msg string, args ...interface{}) *MachineError {
+	return New(UnauthorizedMachineError, msg, args...)
+}
+
+// ReasonOf returns the reason of the machine error err wraps, if any.
+func ReasonOf(err error) (machinev1.MachineStatusError, bool) {
+	var machineError *MachineError
+	if errors.As(err, &machineError) {
+		return machineError.Reason, true
+	}
+	return "", false
+}
+
+// HasReason returns true when err wraps a machine error of the reason.
+func HasReason(err error, reason machinev1.MachineStatusError) bool {
+	r, ok := ReasonOf(err)
+	return ok && r == reason
+}
+
+// IsTerminal returns true when err wraps a machine error that retrying cannot fix, and which fails
+// the machine when creating its instance.
+func IsTerminal(err error) bool {
+	reason, ok := ReasonOf(err)
+	return ok && IsTerminalReason(reason)
+}
+
+// IsTerminalReason returns true when the reason, eg. the ErrorReason of a failed machine, is the reason
+// of a terminal error.
+func IsTerminalReason(reason machinev1.MachineStatusError) bool {
+	return terminalReasons[reason]
+}
@@ -0,0 +1,73 @@
+package machineapierrors
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+)
+
+func TestClassification(t *testing.T) {
+	testCases := []struct {
+		name             string
+		err              error
+		expectedReason   machinev1.MachineStatusError
+		expectedTerminal bool
+	}{
+		{
+			name:             "with an invalid configuration error",
+			err:              InvalidConfiguration("unknown instance type %q", "m5.huge"),
+			expectedReason:   machinev1.InvalidConfigurationMachineError,
+			expectedTerminal: true,
+		},
+		{
+			name:             "with a wrapped insufficient resources error",
+			err:              fmt.Errorf("failed to create instance: %w", InsufficientResources("instance limit exceeded")),
+			expectedReason:   machinev1.InsufficientResourcesMachineError,
+			expectedTerminal: false,
+		},
+		{
+			name:             "with an unauthorized error",
+			err:              Unauthorized("credentials rejected"),
+			expectedReason:   UnauthorizedMachineError,
+			expectedTerminal: false,
+		},
+		{
+			name:             "with a create error",
+			err:              New(machinev1.CreateMachineError, "instance failed to start"),
+			expectedReason:   machinev1.CreateMachineError,
+			expectedTerminal: false,
+		},
+		{
+			name:             "with an unclassified error",
+			err:              errors.New("connection reset"),
+			expectedTerminal: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reason, ok := ReasonOf(tc.err)
+			if ok != (tc.expectedReason != "") || reason != tc.expectedReason {
+				t.Errorf("expected reason %q, got: %q", tc.expectedReason, reason)
+			}
+			if tc.expectedReason != "" && !HasReason(tc.err, tc.expectedReason) {
+				t.Errorf("expected the error to have reason %q", tc.expectedReason)
+			}
+			if terminal := IsTerminal(tc.err); terminal != tc.expectedTerminal {
+				t.Errorf("expected terminal to be %v, got: %v", tc.expectedTerminal, terminal)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	err := InsufficientResources("quota of %d instances exceeded", 10)
+	if err.Error() != "quota of 10 instances exceeded" {
+		t.Errorf("unexpected message: %q", err.Error())
+	}
+	if err.Reason != machinev1.InsufficientResourcesMachineError {
+		t.Errorf("unexpected reason: %q", err.Reason)
+	}
+}
@@ -0,0 +1,105 @@
+package machines
+
+import (
+	"context"
+	"fmt"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// NodeNameIndex indexes machines by the name of the node in their status.nodeRef.
+	NodeNameIndex = "machineNodeNameIndex"
+	// ProviderIDIndex indexes machines by their normalized spec.providerID.
+	ProviderIDIndex = "machineProviderIDIndex"
+)
+
+// AddIndexes registers the machine indexes with the indexer, usually the manager's cache,
+// so that machines can be looked up by node without listing every machine.
+func AddIndexes(ctx context.Context, indexer client.FieldIndexer) error {
+	if err := indexer.IndexField(ctx, &machinev1.Machine{}, NodeNameIndex, IndexByNodeName); err != nil {
+		return fmt.Errorf("error setting index field %s: %v", NodeNameIndex, err)
+	}
+	if err := indexer.IndexField(ctx, &machinev1.Machine{}, ProviderIDIndex, IndexByProviderID); err != nil {
+		return fmt.Errorf("error setting index field %s: %v", ProviderIDIndex, err)
+	}
+	return nil
+}
+
+// IndexByNodeName returns the NodeNameIndex keys of a machine.
+func IndexByNodeName(object client.Object) []string {
+	machine, ok := object.(*machinev1.Machine)
+	if !ok {
+		klog.Warningf("Expected a machine for indexing field, got: %T", object)
+		return nil
+	}
+
+	if machine.Status.NodeRef != nil && machine.Status.NodeRef.Name != "" {
+		return []string{machine.Status.NodeRef.Name}
+	}
+	return nil
+}
+
+// IndexByProviderID returns the ProviderIDIndex keys of a machine.
+func IndexByProviderID(object client.Object) []string {
+	machine, ok := object.(*machinev1.Machine)
+	if !ok {
+		klog.Warningf("Expected a machine for indexing field, got: %T", object)
+		return nil
+	}
+
+	if machine.Spec.ProviderID != nil && *machine.Spec.ProviderID != "" {
+		return []string{NormalizeProviderID(*machine.Spec.ProviderID)}
+	}
+	return nil
+}
+
+// MachineByNodeName returns the machine whose status.nodeRef names the node, or nil if there is none.
+func MachineByNodeName(ctx context.Context, c client.Reader, nodeName string) (*machinev1.Machine, error) {
+	return machineByIndex(ctx, c, NodeNameIndex, nodeName, IndexByNodeName)
+}
+
+// MachineByProviderID returns the machine with the providerID, or nil if there is none.
+func MachineByProviderID(ctx context.Context, c client.Reader, providerID string) (*machinev1.Machine, error) {
+	return machineByIndex(ctx, c, ProviderIDIndex, NormalizeProviderID(providerID), IndexByProviderID)
+}
+
+// machineByIndex looks up the machine with the index key.
+// When the index is not available, eg. because it was not registered with the cache backing the reader,
+// all machines are listed instead. The results are always matched against the key, as readers not
+// backed by a cache may ignore field selectors.
+func machineByIndex(ctx context.Context, c client.Reader, index, key string, indexFunc client.IndexerFunc) (*machinev1.Machine, error) {
+	if key == "" {
+		return nil, nil
+	}
+
+	machineList := &machinev1.MachineList{}
+	if err := c.List(ctx, machineList, client.MatchingFields{index: key}); err != nil {
+		klog.V(3).Infof("Unable to list machines by %s, listing all machines: %v", index, err)
+		machineList = &machinev1.MachineList{}
+		if err := c.List(ctx, machineList); err != nil {
+			return nil, fmt.Errorf("failed getting machine list: %v", err)
+		}
+	}
+
+	var matches []*machinev1.Machine
+	for i := range machineList.Items {
+		for _, value := range indexFunc(&machineList.Items[i]) {
+			if value == key {
+				matches = append(matches, &machineList.Items[i])
+				break
+			}
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, nil
+	case 1:
+		return matches[0].DeepCopy(), nil
+	default:
+		return nil, fmt.Errorf("expected 1 machine for %s %q, got %d", index, key, len(matches))
+	}
+}
@@ -0,0 +1,318 @@
+package machines
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func init() {
+	if err := machinev1.AddToScheme(scheme.Scheme); err != nil {
+		panic(err)
+	}
+}
+
+// noIndexReader fails every list by field, as a cache without the index does.
+type noIndexReader struct {
+	client.Reader
+}
+
+func (r *noIndexReader) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	for _, opt := range opts {
+		if _, ok := opt.(client.MatchingFields); ok {
+			return errors.New("index does not exist")
+		}
+	}
+	return r.Reader.List(ctx, list, opts...)
+}
+
+func newMachine(name, nodeName, providerID string) *machinev1.Machine {
+	machine := &machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "openshift-machine-api",
+		},
+	}
+	if nodeName != "" {
+		machine.Status.NodeRef = &corev1.ObjectReference{Name: nodeName}
+	}
+	if providerID != "" {
+		machine.Spec.ProviderID = pointer.StringPtr(providerID)
+	}
+	return machine
+}
+
+func TestIndexByNodeName(t *testing.T) {
+	testCases := []struct {
+		name     string
+		object   client.Object
+		expected []string
+	}{
+		{
+			name:     "with a nodeRef",
+			object:   newMachine("machine", "node", ""),
+			expected: []string{"node"},
+		},
+		{
+			name:     "without a nodeRef",
+			object:   newMachine("machine", "", ""),
+			expected: nil,
+		},
+		{
+			name:     "with a node",
+			object:   &corev1.Node{},
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IndexByNodeName(tc.object); !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected: %v, got: %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestIndexByProviderID(t *testing.T) {
+	testCases := []struct {
+		name     string
+		object   client.Object
+		expected []string
+	}{
+		{
+			name:     "with a providerID",
+			object:   newMachine("machine", "", "aws:///us-east-1a/i-0123456789"),
+			expected: []string{"aws:///us-east-1a/i-0123456789"},
+		},
+		{
+			name:     "with a mixed case providerID",
+			object:   newMachine("machine", "", "azure:///subscriptions/id/resourceGroups/RG/providers/Microsoft.Compute/virtualMachines/vm"),
+			expected: []string{"azure:///subscriptions/id/resourcegroups/rg/providers/microsoft.compute/virtualmachines/vm"},
+		},
+		{
+			name:     "without a providerID",
+			object:   newMachine("machine", "", ""),
+			expected: nil,
+		},
+		{
+			name:     "with a node",
+			object:   &corev1.Node{Spec: corev1.NodeSpec{ProviderID: "aws:///us-east-1a/i-0123456789"}},
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IndexByProviderID(tc.object); !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected: %v, got: %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestMachineLookup(t *testing.T) {
+	machineA := newMachine("a", "node-a", "aws:///us-east-1a/i-a")
+	machineB := newMachine("b", "node-b", "azure:///subscriptions/id/resourceGroups/RG/providers/Microsoft.Compute/virtualMachines/b")
+	duplicate := newMachine("duplicate", "node-a", "")
+	unlinked := newMachine("unlinked", "", "")
+
+	testCases := []struct {
+		name            string
+		objects         []runtime.Object
+		withoutIndex    bool
+		lookup          func(context.Context, client.Reader) (*machinev1.Machine, error)
+		expectedMachine string
+		expectedError   bool
+	}{
+		{
+			name:    "by node name",
+			objects: []runtime.Object{machineA, machineB, unlinked},
+			lookup: func(ctx context.Context, c client.Reader) (*machinev1.Machine, error) {
+				return MachineByNodeName(ctx, c, "node-b")
+			},
+			expectedMachine: "b",
+		},
+		{
+			name:    "by node name without a match",
+			objects: []runtime.Object{machineA, machineB, unlinked},
+			lookup: func(ctx context.Context, c client.Reader) (*machinev1.Machine, error) {
+				return MachineByNodeName(ctx, c, "node-c")
+			},
+		},
+		{
+			name:    "by node name with several matches",
+			objects: []runtime.Object{machineA, duplicate},
+			lookup: func(ctx context.Context, c client.Reader) (*machinev1.Machine, error) {
+				return MachineByNodeName(ctx, c, "node-a")
+			},
+			expectedError: true,
+		},
+		{
+			name:    "by providerID",
+			objects: []runtime.Object{machineA, machineB, unlinked},
+			lookup: func(ctx context.Context, c client.Reader) (*machinev1.Machine, error) {
+				return MachineByProviderID(ctx, c, "aws:///us-east-1a/i-a")
+			},
+			expectedMachine: "a",
+		},
+		{
+			name:    "by providerID with different case",
+			objects: []runtime.Object{machineA, machineB, unlinked},
+			lookup: func(ctx context.Context, c client.Reader) (*machinev1.Machine, error) {
+				return MachineByProviderID(ctx, c, "azure:///subscriptions/id/resourcegroups/rg/providers/Microsoft.Compute/virtualMachines/b")
+			},
+			expectedMachine: "b",
+		},
+		{
+			name:    "by empty providerID",
+			objects: []runtime.Object{machineA, machineB, unlinked},
+			lookup: func(ctx context.Context, c client.Reader) (*machinev1.Machine, error) {
+				return MachineByProviderID(ctx, c, "")
+			},
+		},
+		{
+			name:         "by node name without the index",
+			objects:      []runtime.Object{machineA, machineB, unlinked},
+			withoutIndex: true,
+			lookup: func(ctx context.Context, c client.Reader) (*machinev1.Machine, error) {
+				return MachineByNodeName(ctx, c, "node-a")
+			},
+			expectedMachine: "a",
+		},
+		{
+			name:         "by providerID without the index",
+			objects:      []runtime.Object{machineA, machineB, unlinked},
+			withoutIndex: true,
+			lookup: func(ctx context.Context, c client.Reader) (*machinev1.Machine, error) {
+				return MachineByProviderID(ctx, c, "aws:///us-east-1a/i-a")
+			},
+			expectedMachine: "a",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var c client.Reader = fake.NewFakeClientWithScheme(scheme.Scheme, tc.objects...)
+			if tc.withoutIndex {
+				c = &noIndexReader{Reader: c}
+			}
+
+			machine, err := tc.lookup(context.Background(), c)
+			if (err != nil) != tc.expectedError {
+				t.Fatalf("expected error: %v, got: %v", tc.expectedError, err)
+			}
+
+			var got string
+			if machine != nil {
+				got = machine.Name
+			}
+			if got != tc.expectedMachine {
+				t.Errorf("expected machine %q, got: %q", tc.expectedMachine, got)
+			}
+		})
+	}
+}
+
+// newBenchmarkIndexer returns an informer indexer, as used by the controller-runtime cache,
+// holding count machines with the machine indexes.
+func newBenchmarkIndexer(b *testing.B, count int) cache.Indexer {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{
+		NodeNameIndex: func(obj interface{}) ([]string, error) {
+			return IndexByNodeName(obj.(client.Object)), nil
+		},
+		ProviderIDIndex: func(obj interface{}) ([]string, error) {
+			return IndexByProviderID(obj.(client.Object)), nil
+		},
+	})
+
+	for i := 0; i < count; i++ {
+		machine := newMachine(fmt.Sprintf("machine-%d", i), fmt.Sprintf("node-%d", i), fmt.Sprintf("aws:///us-east-1a/i-%d", i))
+		if err := indexer.Add(machine); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return indexer
+}
+
+func BenchmarkMachineByNodeName(b *testing.B) {
+	const count = 5000
+	indexer := newBenchmarkIndexer(b, count)
+
+	b.Run("scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			nodeName := fmt.Sprintf("node-%d", i%count)
+			var found *machinev1.Machine
+			for _, obj := range indexer.List() {
+				machine := obj.(*machinev1.Machine)
+				if machine.Status.NodeRef != nil && machine.Status.NodeRef.Name == nodeName {
+					found = machine
+					break
+				}
+			}
+			if found == nil {
+				b.Fatalf("no machine found for node %s", nodeName)
+			}
+		}
+	})
+
+	b.Run("index", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			nodeName := fmt.Sprintf("node-%d", i%count)
+			objs, err := indexer.ByIndex(NodeNameIndex, nodeName)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(objs) != 1 {
+				b.Fatalf("expected 1 machine for node %s, got %d", nodeName, len(objs))
+			}
+		}
+	})
+}
+
+func BenchmarkMachineByProviderID(b *testing.B) {
+	const count = 5000
+	indexer := newBenchmarkIndexer(b, count)
+
+	b.Run("scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			providerID := NormalizeProviderID(fmt.Sprintf("aws:///us-east-1a/i-%d", i%count))
+			var found *machinev1.Machine
+			for _, obj := range indexer.List() {
+				machine := obj.(*machinev1.Machine)
+				if machine.Spec.ProviderID != nil && NormalizeProviderID(*machine.Spec.ProviderID) == providerID {
+					found = machine
+					break
+				}
+			}
+			if found == nil {
+				b.Fatalf("no machine found for providerID %s", providerID)
+			}
+		}
+	})
+
+	b.Run("index", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			providerID := NormalizeProviderID(fmt.Sprintf("aws:///us-east-1a/i-%d", i%count))
+			objs, err := indexer.ByIndex(ProviderIDIndex, providerID)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(objs) != 1 {
+				b.Fatalf("expected 1 machine for providerID %s, got %d", providerID, len(objs))
+			}
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package machines
+
+import (
+	"encoding/json"
+	"time"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// PhaseTransitionsAnnotation records when a Machine last entered each of its phases, as a JSON object
+	// mapping phases to timestamps, eg. {"Provisioning":"2021-06-01T10:00:00Z","Provisioned":"2021-06-01T10:01:30Z"}
+	PhaseTransitionsAnnotation = "machine.openshift.io/phase-transitions"
+
+	// phaseProvisioning and phaseDeleting mirror the phases of the machine controller.
+	phaseProvisioning = "Provisioning"
+	phaseDeleting     = "Deleting"
+
+	// zoneLabel is the label of the availability zone of a Machine, set by the machine controller.
+	zoneLabel = "machine.openshift.io/zone"
+)
+
+// PhaseTransitions returns when the machine last entered each of its phases, as recorded in the
+// PhaseTransitionsAnnotation. An invalid annotation is ignored.
+func PhaseTransitions(m *machinev1.Machine) map[string]metav1.Time {
+	transitions := map[string]metav1.Time{}
+	value, ok := m.Annotations[PhaseTransitionsAnnotation]
+	if !ok {
+		return transitions
+	}
+	if err := json.Unmarshal([]byte(value), &transitions); err != nil {
+		klog.Warningf("%v: ignoring invalid %s annotation %q: %v", m.GetName(), PhaseTransitionsAnnotation, value, err)
+		return map[string]metav1.Time{}
+	}
+	return transitions
+}
+
+// PhaseEnteredAt returns when the machine entered the phase, and whether it is known. The time is read from
+// the PhaseTransitionsAnnotation, or for the phases entered before it was recorded, from the creation of the
+// Machine for Provisioning and from its deletion for Deleting.
+func PhaseEnteredAt(m *machinev1.Machine, phase string) (time.Time, bool) {
+	if enteredAt, ok := PhaseTransitions(m)[phase]; ok {
+		return enteredAt.Time, true
+	}
+	switch phase {
+	case phaseProvisioning:
+		return m.GetCreationTimestamp().Time, true
+	case phaseDeleting:
+		if deletionTimestamp := m.GetDeletionTimestamp(); deletionTimestamp != nil {
+			return deletionTimestamp.Time, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// FailureDomain returns the failure domain of the machine, its availability zone, or an empty string
+// when it is not known yet.
+func FailureDomain(m *machinev1.Machine) string {
+	return m.Labels[zoneLabel]
+}
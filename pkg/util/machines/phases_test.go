@@ -0,0 +1,82 @@
+package machines
+
+import (
+	"testing"
+	"time"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPhaseEnteredAt(t *testing.T) {
+	created := metav1.NewTime(time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC))
+	deleted := metav1.NewTime(created.Add(time.Hour))
+
+	testCases := []struct {
+		name              string
+		phase             string
+		deletionTimestamp *metav1.Time
+		transitions       string
+		expected          time.Time
+		expectedKnown     bool
+	}{
+		{
+			name:          "provisioning",
+			phase:         "Provisioning",
+			expected:      created.Time,
+			expectedKnown: true,
+		},
+		{
+			name:              "deleting",
+			phase:             "Deleting",
+			deletionTimestamp: &deleted,
+			expected:          deleted.Time,
+			expectedKnown:     true,
+		},
+		{
+			name:  "deleting without a deletion timestamp",
+			phase: "Deleting",
+		},
+		{
+			name:  "running",
+			phase: "Running",
+		},
+		{
+			name:          "running with recorded transitions",
+			phase:         "Running",
+			transitions:   `{"Provisioning":"2021-06-01T10:00:05Z","Running":"2021-06-01T10:05:00Z"}`,
+			expected:      created.Add(5 * time.Minute),
+			expectedKnown: true,
+		},
+		{
+			name:          "provisioning with recorded transitions",
+			phase:         "Provisioning",
+			transitions:   `{"Provisioning":"2021-06-01T10:00:05Z"}`,
+			expected:      created.Add(5 * time.Second),
+			expectedKnown: true,
+		},
+		{
+			name:        "running with invalid transitions",
+			phase:       "Running",
+			transitions: `{"Running":`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := &machinev1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					CreationTimestamp: created,
+					DeletionTimestamp: tc.deletionTimestamp,
+				},
+			}
+			if tc.transitions != "" {
+				m.Annotations = map[string]string{PhaseTransitionsAnnotation: tc.transitions}
+			}
+			got, known := PhaseEnteredAt(m, tc.phase)
+			if known != tc.expectedKnown || !got.Equal(tc.expected) {
+				t.Errorf("expected %v (%v), got: %v (%v)", tc.expected, tc.expectedKnown, got, known)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package machines
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// ProviderIDNormalizer normalizes the providerIDs of a platform, so that the providerID a node reports
+// and the one of its machine match although the platform formats them differently.
+type ProviderIDNormalizer interface {
+	// Normalize returns the providerID in the form used as index key.
+	// The providerID is trimmed and lower-cased already.
+	Normalize(providerID string) string
+}
+
+// ProviderIDNormalizerFunc adapts a function to a ProviderIDNormalizer.
+type ProviderIDNormalizerFunc func(providerID string) string
+
+// Normalize calls f(providerID).
+func (f ProviderIDNormalizerFunc) Normalize(providerID string) string {
+	return f(providerID)
+}
+
+var (
+	providerIDNormalizersLock sync.RWMutex
+	providerIDNormalizers     = map[string]ProviderIDNormalizer{
+		"vsphere":   ProviderIDNormalizerFunc(normalizeVSphereProviderID),
+		"openstack": ProviderIDNormalizerFunc(normalizeOpenStackProviderID),
+	}
+)
+
+// RegisterProviderIDNormalizer registers the normalizer of the providerIDs with the scheme, eg. "vsphere"
+// for "vsphere://<uuid>", replacing the one registered before, if any.
+// Normalizers are used to build index keys, so they should be registered before the indexes are populated.
+func RegisterProviderIDNormalizer(scheme string, normalizer ProviderIDNormalizer) {
+	providerIDNormalizersLock.Lock()
+	defer providerIDNormalizersLock.Unlock()
+	providerIDNormalizers[strings.ToLower(scheme)] = normalizer
+}
+
+// NormalizeProviderID returns the providerID in the form used as index key.
+// Some platforms report the providerID of the node and the machine with different case,
+// eg. the resource group of Azure providerIDs, or in different formats, which are reconciled
+// by the normalizer registered for the scheme of the providerID.
+func NormalizeProviderID(providerID string) string {
+	providerID = strings.ToLower(strings.TrimSpace(providerID))
+
+	scheme := strings.SplitN(providerID, "://", 2)[0]
+	providerIDNormalizersLock.RLock()
+	normalizer, ok := providerIDNormalizers[scheme]
+	providerIDNormalizersLock.RUnlock()
+	if !ok || scheme == providerID {
+		return providerID
+	}
+	return normalizer.Normalize(providerID)
+}
+
+// normalizeVSphereProviderID formats the UUID of vSphere providerIDs canonically,
+// as it may be reported with or without dashes or braces.
+func normalizeVSphereProviderID(providerID string) string {
+	const prefix = "vsphere://"
+	id, err := uuid.Parse(strings.TrimPrefix(providerID, prefix))
+	if err != nil {
+		return providerID
+	}
+	return prefix + id.String()
+}
+
+// normalizeOpenStackProviderID removes the region from OpenStack providerIDs,
+// "openstack://<region>/<id>", as only some components report it.
+func normalizeOpenStackProviderID(providerID string) string {
+	const prefix = "openstack://"
+	path := strings.TrimPrefix(providerID, prefix)
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		path = path[i+1:]
+	}
+	return prefix + "/" + path
+}
@@ -0,0 +1,66 @@
+package machines
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeProviderID(t *testing.T) {
+	RegisterProviderIDNormalizer("Example", ProviderIDNormalizerFunc(func(providerID string) string {
+		return strings.TrimSuffix(providerID, "/")
+	}))
+
+	testCases := []struct {
+		providerID string
+		expected   string
+	}{
+		{
+			providerID: " aws:///us-east-1a/i-0123456789 ",
+			expected:   "aws:///us-east-1a/i-0123456789",
+		},
+		{
+			providerID: "azure:///subscriptions/id/resourceGroups/RG/providers/Microsoft.Compute/virtualMachines/vm",
+			expected:   "azure:///subscriptions/id/resourcegroups/rg/providers/microsoft.compute/virtualmachines/vm",
+		},
+		{
+			providerID: "vsphere://4237A3C4-2A1C-D7D2-5B8E-6F3C7E1E5A0B",
+			expected:   "vsphere://4237a3c4-2a1c-d7d2-5b8e-6f3c7e1e5a0b",
+		},
+		{
+			providerID: "vsphere://4237a3c42a1cd7d25b8e6f3c7e1e5a0b",
+			expected:   "vsphere://4237a3c4-2a1c-d7d2-5b8e-6f3c7e1e5a0b",
+		},
+		{
+			providerID: "vsphere://not-a-uuid",
+			expected:   "vsphere://not-a-uuid",
+		},
+		{
+			providerID: "openstack:///0d1ea0b6-7e2d-4b59-9a7c-3c4e3bd0c1e7",
+			expected:   "openstack:///0d1ea0b6-7e2d-4b59-9a7c-3c4e3bd0c1e7",
+		},
+		{
+			providerID: "openstack://RegionOne/0d1ea0b6-7e2d-4b59-9a7c-3c4e3bd0c1e7",
+			expected:   "openstack:///0d1ea0b6-7e2d-4b59-9a7c-3c4e3bd0c1e7",
+		},
+		{
+			providerID: "example://instance/",
+			expected:   "example://instance",
+		},
+		{
+			providerID: "openstack",
+			expected:   "openstack",
+		},
+		{
+			providerID: "",
+			expected:   "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.providerID, func(t *testing.T) {
+			if got := NormalizeProviderID(tc.providerID); got != tc.expected {
+				t.Errorf("expected: %q, got: %q", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+// Package nodemetadata keeps labels and taints in sync with the ones desired by another object,
+// eg. the labels and taints of a Node with the ones of its Machine.
+//
+// The labels and taints applied by the last sync are recorded, so that removing a label or taint
+// from the desired ones removes it, and that labels and taints which were changed by others since
+// can be told apart from the ones which were applied. Such conflicting labels and taints are
+// overwritten or kept depending on the conflict policy.
+package nodemetadata
+
+import (
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ConflictPolicy tells which of the desired and current values wins when a label or taint
+// was changed by others since the last sync.
+type ConflictPolicy string
+
+const (
+	// DesiredWins overwrites the conflicting labels and taints with the desired ones.
+	DesiredWins ConflictPolicy = "Desired"
+	// CurrentWins keeps the conflicting labels and taints.
+	CurrentWins ConflictPolicy = "Current"
+)
+
+// SyncLabels returns the current labels updated with the desired ones, given the last applied labels.
+// Labels which were last applied and are no longer desired are removed, unless they were changed since.
+func SyncLabels(current, desired, lastApplied map[string]string, policy ConflictPolicy) map[string]string {
+	synced := map[string]string{}
+	for k, v := range current {
+		synced[k] = v
+	}
+
+	for k, v := range lastApplied {
+		if _, ok := desired[k]; !ok && synced[k] == v {
+			delete(synced, k)
+		}
+	}
+
+	for k, v := range desired {
+		currentValue, ok := synced[k]
+		if ok && currentValue != v && policy == CurrentWins {
+			if lastAppliedValue, applied := lastApplied[k]; !applied || lastAppliedValue != currentValue {
+				continue
+			}
+		}
+		synced[k] = v
+	}
+	if len(synced) == 0 && len(current) == 0 {
+		return current
+	}
+	return synced
+}
+
+// SyncTaints returns the current taints updated with the desired ones, given the last applied taints.
+// Taints are identified by their key and effect. Taints which were last applied and are no longer desired
+// are removed, unless they were changed since.
+func SyncTaints(current, desired, lastApplied []corev1.Taint, policy ConflictPolicy) []corev1.Taint {
+	synced := make([]corev1.Taint, 0, len(current))
+	for _, taint := range current {
+		if applied := findTaint(lastApplied, taint); applied != nil && applied.Value == taint.Value && findTaint(desired, taint) == nil {
+			continue
+		}
+		synced = append(synced, taint)
+	}
+
+	for _, taint := range desired {
+		currentTaint := findTaint(synced, taint)
+		switch {
+		case currentTaint == nil:
+			synced = append(synced, taint)
+		case currentTaint.Value == taint.Value:
+		case policy == CurrentWins:
+			if applied := findTaint(lastApplied, taint); applied != nil && applied.Value == currentTaint.Value {
+				currentTaint.Value = taint.Value
+			}
+		default:
+			currentTaint.Value = taint.Value
+		}
+	}
+	if len(synced) == 0 && len(current) == 0 {
+		return current
+	}
+	return synced
+}
+
+// findTaint returns the taint of taints with the key and effect of the taint, if any.
+func findTaint(taints []corev1.Taint, taint corev1.Taint) *corev1.Taint {
+	for i := range taints {
+		if taints[i].Key == taint.Key && taints[i].Effect == taint.Effect {
+			return &taints[i]
+		}
+	}
+	return nil
+}
+
+// LastAppliedLabels returns the labels recorded in the annotation.
+func LastAppliedLabels(annotations map[string]string, annotation string) (map[string]string, error) {
+	labels := map[string]string{}
+	if value, ok := annotations[annotation]; ok {
+		if err := json.Unmarshal([]byte(value), &labels); err != nil {
+			return nil, fmt.Errorf("invalid %s annotation %q: %w", annotation, value, err)
+		}
+	}
+	return labels, nil
+}
+
+// LastAppliedTaints returns the taints recorded in the annotation.
+func LastAppliedTaints(annotations map[string]string, annotation string) ([]corev1.Taint, error) {
+	var taints []corev1.Taint
+	if value, ok := annotations[annotation]; ok {
+		if err := json.Unmarshal([]byte(value), &taints); err != nil {
+			return nil, fmt.Errorf("invalid %s annotation %q: %w", annotation, value, err)
+		}
+	}
+	return taints, nil
+}
+
+// SetLastAppliedLabels records the labels in the annotation, or removes it when there are none.
+func SetLastAppliedLabels(annotations map[string]string, annotation string, labels map[string]string) error {
+	if len(labels) == 0 {
+		delete(annotations, annotation)
+		return nil
+	}
+	return setLastApplied(annotations, annotation, labels)
+}
+
+// SetLastAppliedTaints records the taints in the annotation, or removes it when there are none.
+func SetLastAppliedTaints(annotations map[string]string, annotation string, taints []corev1.Taint) error {
+	if len(taints) == 0 {
+		delete(annotations, annotation)
+		return nil
+	}
+	return setLastApplied(annotations, annotation, taints)
+}
+
+func setLastApplied(annotations map[string]string, annotation string, applied interface{}) error {
+	value, err := json.Marshal(applied)
+	if err != nil {
+		return err
+	}
+	annotations[annotation] = string(value)
+	return nil
+}
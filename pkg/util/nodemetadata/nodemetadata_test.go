@@ -0,0 +1,187 @@
+package nodemetadata
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestSyncLabels(t *testing.T) {
+	testCases := []struct {
+		name        string
+		current     map[string]string
+		desired     map[string]string
+		lastApplied map[string]string
+		policy      ConflictPolicy
+		expected    map[string]string
+	}{
+		{
+			name:     "nothing to sync",
+			expected: nil,
+		},
+		{
+			name:     "adds the desired labels",
+			current:  map[string]string{"other": "a"},
+			desired:  map[string]string{"foo": "a"},
+			policy:   DesiredWins,
+			expected: map[string]string{"other": "a", "foo": "a"},
+		},
+		{
+			name:        "updates the applied labels",
+			current:     map[string]string{"foo": "a"},
+			desired:     map[string]string{"foo": "b"},
+			lastApplied: map[string]string{"foo": "a"},
+			policy:      CurrentWins,
+			expected:    map[string]string{"foo": "b"},
+		},
+		{
+			name:        "removes the labels no longer desired",
+			current:     map[string]string{"foo": "a", "other": "a"},
+			lastApplied: map[string]string{"foo": "a"},
+			policy:      DesiredWins,
+			expected:    map[string]string{"other": "a"},
+		},
+		{
+			name:        "keeps the labels no longer desired which were changed since",
+			current:     map[string]string{"foo": "b"},
+			lastApplied: map[string]string{"foo": "a"},
+			policy:      DesiredWins,
+			expected:    map[string]string{"foo": "b"},
+		},
+		{
+			name:        "overwrites the conflicting labels when the desired ones win",
+			current:     map[string]string{"foo": "b"},
+			desired:     map[string]string{"foo": "c"},
+			lastApplied: map[string]string{"foo": "a"},
+			policy:      DesiredWins,
+			expected:    map[string]string{"foo": "c"},
+		},
+		{
+			name:        "keeps the conflicting labels when the current ones win",
+			current:     map[string]string{"foo": "b", "bar": "b"},
+			desired:     map[string]string{"foo": "c", "bar": "c"},
+			lastApplied: map[string]string{"foo": "a"},
+			policy:      CurrentWins,
+			expected:    map[string]string{"foo": "b", "bar": "b"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			synced := SyncLabels(tc.current, tc.desired, tc.lastApplied, tc.policy)
+			if !reflect.DeepEqual(synced, tc.expected) {
+				t.Errorf("expected labels: %v, got: %v", tc.expected, synced)
+			}
+		})
+	}
+}
+
+func TestSyncTaints(t *testing.T) {
+	taint := func(key, value string) corev1.Taint {
+		return corev1.Taint{Key: key, Value: value, Effect: corev1.TaintEffectNoSchedule}
+	}
+
+	testCases := []struct {
+		name        string
+		current     []corev1.Taint
+		desired     []corev1.Taint
+		lastApplied []corev1.Taint
+		policy      ConflictPolicy
+		expected    []corev1.Taint
+	}{
+		{
+			name:     "nothing to sync",
+			expected: nil,
+		},
+		{
+			name:     "adds the desired taints",
+			current:  []corev1.Taint{taint("other", "a")},
+			desired:  []corev1.Taint{taint("foo", "a")},
+			policy:   CurrentWins,
+			expected: []corev1.Taint{taint("other", "a"), taint("foo", "a")},
+		},
+		{
+			name:     "adds the desired taints with another effect",
+			current:  []corev1.Taint{taint("foo", "a")},
+			desired:  []corev1.Taint{{Key: "foo", Value: "a", Effect: corev1.TaintEffectNoExecute}},
+			policy:   CurrentWins,
+			expected: []corev1.Taint{taint("foo", "a"), {Key: "foo", Value: "a", Effect: corev1.TaintEffectNoExecute}},
+		},
+		{
+			name:        "updates the applied taints",
+			current:     []corev1.Taint{taint("foo", "a")},
+			desired:     []corev1.Taint{taint("foo", "b")},
+			lastApplied: []corev1.Taint{taint("foo", "a")},
+			policy:      CurrentWins,
+			expected:    []corev1.Taint{taint("foo", "b")},
+		},
+		{
+			name:        "removes the taints no longer desired",
+			current:     []corev1.Taint{taint("foo", "a"), taint("other", "a")},
+			lastApplied: []corev1.Taint{taint("foo", "a")},
+			policy:      CurrentWins,
+			expected:    []corev1.Taint{taint("other", "a")},
+		},
+		{
+			name:        "keeps the taints no longer desired which were changed since",
+			current:     []corev1.Taint{taint("foo", "b")},
+			lastApplied: []corev1.Taint{taint("foo", "a")},
+			policy:      DesiredWins,
+			expected:    []corev1.Taint{taint("foo", "b")},
+		},
+		{
+			name:        "overwrites the conflicting taints when the desired ones win",
+			current:     []corev1.Taint{taint("foo", "b")},
+			desired:     []corev1.Taint{taint("foo", "c")},
+			lastApplied: []corev1.Taint{taint("foo", "a")},
+			policy:      DesiredWins,
+			expected:    []corev1.Taint{taint("foo", "c")},
+		},
+		{
+			name:        "keeps the conflicting taints when the current ones win",
+			current:     []corev1.Taint{taint("foo", "b"), taint("bar", "b")},
+			desired:     []corev1.Taint{taint("foo", "c"), taint("bar", "c")},
+			lastApplied: []corev1.Taint{taint("foo", "a")},
+			policy:      CurrentWins,
+			expected:    []corev1.Taint{taint("foo", "b"), taint("bar", "b")},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			synced := SyncTaints(tc.current, tc.desired, tc.lastApplied, tc.policy)
+			if !reflect.DeepEqual(synced, tc.expected) {
+				t.Errorf("expected taints: %v, got: %v", tc.expected, synced)
+			}
+		})
+	}
+}
+
+func TestLastApplied(t *testing.T) {
+	const annotation = "example.com/last-applied"
+	annotations := map[string]string{}
+
+	if err := SetLastAppliedTaints(annotations, annotation, []corev1.Taint{{Key: "foo", Effect: corev1.TaintEffectNoSchedule}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	taints, err := LastAppliedTaints(annotations, annotation)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []corev1.Taint{{Key: "foo", Effect: corev1.TaintEffectNoSchedule}}; !reflect.DeepEqual(taints, expected) {
+		t.Errorf("expected taints: %v, got: %v", expected, taints)
+	}
+
+	if err := SetLastAppliedLabels(annotations, annotation, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := annotations[annotation]; ok {
+		t.Errorf("expected the annotation to be removed, got: %v", annotations)
+	}
+
+	annotations[annotation] = "invalid"
+	if _, err := LastAppliedLabels(annotations, annotation); err == nil {
+		t.Errorf("expected an error for an invalid annotation")
+	}
+}
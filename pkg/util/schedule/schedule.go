@@ -0,0 +1,193 @@
+// Package schedule parses cron-style schedules, https://en.wikipedia.org/wiki/Cron, describing
+// the minutes during which something is allowed, eg. "* 1-4 * * 1-5" for 01:00 to 04:59 on weekdays.
+//
+// A schedule has five fields: minute (0-59), hour (0-23), day of month (1-31), month (1-12)
+// and day of week (0-7, where both 0 and 7 are Sunday). Each field is "*", a value, a range "a-b",
+// any of which may be followed by a step "/n", or a comma separated list of those.
+// As with cron, when both the day of month and the day of week are restricted, a day matches either.
+// Schedules are evaluated in UTC, unless prefixed with a time zone, eg. "CRON_TZ=Europe/Brussels * 1-4 * * *".
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const timeZonePrefix = "CRON_TZ="
+
+// maxLookahead bounds the search for the next minute of a schedule, as schedules such as "* * 31 2 *"
+// never match.
+const maxLookahead = 4 * 366 * 24 * time.Hour
+
+// Schedule is a parsed cron-style schedule.
+type Schedule struct {
+	expr     string
+	location *time.Location
+
+	minutes, hours, daysOfMonth, months, daysOfWeek uint64
+	anyDayOfMonth, anyDayOfWeek                     bool
+}
+
+// field is the range of values of a schedule field.
+type field struct {
+	name     string
+	min, max int
+}
+
+var (
+	minuteField     = field{name: "minute", min: 0, max: 59}
+	hourField       = field{name: "hour", min: 0, max: 23}
+	dayOfMonthField = field{name: "day of month", min: 1, max: 31}
+	monthField      = field{name: "month", min: 1, max: 12}
+	dayOfWeekField  = field{name: "day of week", min: 0, max: 7}
+)
+
+// Parse parses a cron-style schedule.
+func Parse(expr string) (*Schedule, error) {
+	s := &Schedule{expr: expr, location: time.UTC}
+
+	spec := strings.TrimSpace(expr)
+	if strings.HasPrefix(spec, timeZonePrefix) {
+		fields := strings.SplitN(spec, " ", 2)
+		location, err := time.LoadLocation(strings.TrimPrefix(fields[0], timeZonePrefix))
+		if err != nil {
+			return nil, fmt.Errorf("invalid time zone: %w", err)
+		}
+		s.location = location
+		spec = ""
+		if len(fields) == 2 {
+			spec = fields[1]
+		}
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields, minute, hour, day of month, month and day of week, got %d", len(fields))
+	}
+
+	var err error
+	if s.minutes, _, err = parseField(fields[0], minuteField); err != nil {
+		return nil, err
+	}
+	if s.hours, _, err = parseField(fields[1], hourField); err != nil {
+		return nil, err
+	}
+	if s.daysOfMonth, s.anyDayOfMonth, err = parseField(fields[2], dayOfMonthField); err != nil {
+		return nil, err
+	}
+	if s.months, _, err = parseField(fields[3], monthField); err != nil {
+		return nil, err
+	}
+	if s.daysOfWeek, s.anyDayOfWeek, err = parseField(fields[4], dayOfWeekField); err != nil {
+		return nil, err
+	}
+	// Sunday is both 0 and 7
+	if s.daysOfWeek&(1<<7) != 0 {
+		s.daysOfWeek |= 1
+	}
+	return s, nil
+}
+
+// parseField returns the values of the field as a bit set, and whether the field is "*".
+func parseField(value string, f field) (uint64, bool, error) {
+	var bits uint64
+	for _, part := range strings.Split(value, ",") {
+		rangeSpec, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			rangeSpec = part[:i]
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return 0, false, fmt.Errorf("invalid %s %q: step must be a positive integer", f.name, part)
+			}
+		}
+
+		low, high := f.min, f.max
+		switch {
+		case rangeSpec == "*":
+		case strings.Contains(rangeSpec, "-"):
+			bounds := strings.SplitN(rangeSpec, "-", 2)
+			var err error
+			if low, err = parseValue(bounds[0], f); err != nil {
+				return 0, false, err
+			}
+			if high, err = parseValue(bounds[1], f); err != nil {
+				return 0, false, err
+			}
+			if low > high {
+				return 0, false, fmt.Errorf("invalid %s range %q: start is after end", f.name, rangeSpec)
+			}
+		default:
+			var err error
+			if low, err = parseValue(rangeSpec, f); err != nil {
+				return 0, false, err
+			}
+			// A single value with a step runs to the end of the range, as with cron
+			if step == 1 {
+				high = low
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, value == "*", nil
+}
+
+func parseValue(value string, f field) (int, error) {
+	v, err := strconv.Atoi(value)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s %q: must be an integer between %d and %d", f.name, value, f.min, f.max)
+	}
+	return v, nil
+}
+
+// String returns the schedule as parsed.
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// Contains returns whether the minute of t is in the schedule.
+func (s *Schedule) Contains(t time.Time) bool {
+	t = t.In(s.location)
+	return s.minutes&(1<<uint(t.Minute())) != 0 && s.hours&(1<<uint(t.Hour())) != 0 && s.dayMatches(t)
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	if s.months&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	dayOfMonth := s.daysOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeek := s.daysOfWeek&(1<<uint(t.Weekday())) != 0
+	if s.anyDayOfMonth || s.anyDayOfWeek {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
+
+// Next returns the first time in the schedule at or after t, which is t itself or the start of a later minute,
+// and false when the schedule never matches.
+func (s *Schedule) Next(t time.Time) (time.Time, bool) {
+	t = t.In(s.location)
+	end := t.Add(maxLookahead)
+
+	if s.Contains(t) {
+		return t, true
+	}
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for t.Before(end) {
+		switch {
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.location)
+		case s.hours&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, s.location).Add(time.Hour)
+		case s.minutes&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,122 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		expr          string
+		expectedError string
+	}{
+		{expr: "* * * * *"},
+		{expr: "0,30 1-4 * * 1-5"},
+		{expr: "*/15 22/2 1 */3 7"},
+		{expr: "CRON_TZ=UTC * 1-4 * * *"},
+		{expr: "* * * *", expectedError: "expected 5 fields, minute, hour, day of month, month and day of week, got 4"},
+		{expr: "60 * * * *", expectedError: `invalid minute "60": must be an integer between 0 and 59`},
+		{expr: "* 4-1 * * *", expectedError: `invalid hour range "4-1": start is after end`},
+		{expr: "* * 0 * *", expectedError: `invalid day of month "0": must be an integer between 1 and 31`},
+		{expr: "* * * */0 *", expectedError: `invalid month "*/0": step must be a positive integer`},
+		{expr: "* * * * mon", expectedError: `invalid day of week "mon": must be an integer between 0 and 7`},
+		{expr: "CRON_TZ=Nowhere/Land * * * * *", expectedError: "invalid time zone: unknown time zone Nowhere/Land"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expr, func(t *testing.T) {
+			_, err := Parse(tc.expr)
+			var errString string
+			if err != nil {
+				errString = err.Error()
+			}
+			if errString != tc.expectedError {
+				t.Errorf("expected error: %q, got: %q", tc.expectedError, errString)
+			}
+		})
+	}
+}
+
+func TestContainsAndNext(t *testing.T) {
+	// Monday
+	monday := time.Date(2021, time.September, 6, 12, 30, 15, 0, time.UTC)
+
+	testCases := []struct {
+		name             string
+		expr             string
+		at               time.Time
+		expectedContains bool
+		expectedNext     time.Time
+		expectedNever    bool
+	}{
+		{
+			name:             "every minute",
+			expr:             "* * * * *",
+			at:               monday,
+			expectedContains: true,
+			expectedNext:     monday,
+		},
+		{
+			name:         "later the same day",
+			expr:         "* 22-23 * * *",
+			at:           monday,
+			expectedNext: time.Date(2021, time.September, 6, 22, 0, 0, 0, time.UTC),
+		},
+		{
+			name:         "the next day",
+			expr:         "0,30 1-4 * * *",
+			at:           monday,
+			expectedNext: time.Date(2021, time.September, 7, 1, 0, 0, 0, time.UTC),
+		},
+		{
+			name:         "on the weekend, with Sunday as 7",
+			expr:         "* 1-4 * * 6-7",
+			at:           monday,
+			expectedNext: time.Date(2021, time.September, 11, 1, 0, 0, 0, time.UTC),
+		},
+		{
+			name:         "on either the day of month or the day of week",
+			expr:         "0 0 15 * 3",
+			at:           monday,
+			expectedNext: time.Date(2021, time.September, 8, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:         "in another month",
+			expr:         "0 0 1 1 *",
+			at:           monday,
+			expectedNext: time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:             "in a time zone",
+			expr:             "CRON_TZ=Asia/Kolkata * 18 * * *",
+			at:               monday,
+			expectedContains: true,
+			expectedNext:     monday,
+		},
+		{
+			name:          "never",
+			expr:          "* * 31 2 *",
+			at:            monday,
+			expectedNever: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := Parse(tc.expr)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if contains := s.Contains(tc.at); contains != tc.expectedContains {
+				t.Errorf("expected %v to be in the schedule: %v, got: %v", tc.at, tc.expectedContains, contains)
+			}
+			next, ok := s.Next(tc.at)
+			if ok == tc.expectedNever {
+				t.Fatalf("expected the schedule to never match: %v, got: %v", tc.expectedNever, !ok)
+			}
+			if ok && !next.Equal(tc.expectedNext) {
+				t.Errorf("expected the next time in the schedule to be %v, got: %v", tc.expectedNext, next)
+			}
+		})
+	}
+}
@@ -12,6 +12,10 @@ var (
 	// with the calculated version at build time.
 	Raw = "v0.0.0-was-not-built-properly"
 
+	// Commit is the git commit the binary was built from. This will be replaced
+	// at build time.
+	Commit = "unknown"
+
 	// Version is semver representation of the version.
 	Version = semver.MustParse(strings.TrimLeft(Raw, "v"))
 
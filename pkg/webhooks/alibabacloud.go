@@ -0,0 +1,144 @@
+package webhooks
+
+import (
+	"fmt"
+	"strings"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// Alibaba Cloud resource reference types.
+	alibabaCloudReferenceTypeID   = "ID"
+	alibabaCloudReferenceTypeName = "Name"
+	alibabaCloudReferenceTypeTags = "Tags"
+)
+
+// alibabaCloudProviderSpec is the subset of AlibabaCloudMachineProviderConfig the webhooks validate.
+// It models the region and zone of the ECS instance, its instance type and image, and the vSwitch and
+// security groups it is attached to.
+type alibabaCloudProviderSpec struct {
+	InstanceType      string                          `json:"instanceType,omitempty"`
+	ImageID           string                          `json:"imageId,omitempty"`
+	RegionID          string                          `json:"regionId,omitempty"`
+	ZoneID            string                          `json:"zoneId,omitempty"`
+	VSwitch           alibabaCloudResourceReference   `json:"vSwitch,omitempty"`
+	SecurityGroups    []alibabaCloudResourceReference `json:"securityGroups,omitempty"`
+	UserDataSecret    *corev1.LocalObjectReference    `json:"userDataSecret,omitempty"`
+	CredentialsSecret *corev1.LocalObjectReference    `json:"credentialsSecret,omitempty"`
+}
+
+// alibabaCloudResourceReference references an Alibaba Cloud resource by ID, by name or by tags.
+type alibabaCloudResourceReference struct {
+	Type string               `json:"type,omitempty"`
+	ID   *string              `json:"id,omitempty"`
+	Name *string              `json:"name,omitempty"`
+	Tags *[]map[string]string `json:"tags,omitempty"`
+}
+
+// defaultAlibabaCloud defaults the instance type, the region of the cluster and the secrets of the
+// Alibaba Cloud providerSpec.
+func defaultAlibabaCloud(m *machinev1.Machine, config *admissionConfig) (bool, []string, utilerrors.Aggregate) {
+	klog.V(3).Infof("Defaulting Alibaba Cloud providerSpec")
+
+	defaults := rawProviderSpecSecretDefaults(config, osconfigv1.AlibabaCloudPlatformType)
+	defaults["instanceType"] = config.defaultValue(osconfigv1.AlibabaCloudPlatformType, defaultsInstanceTypeKey)
+	if config.platformStatus != nil && config.platformStatus.AlibabaCloud != nil && config.platformStatus.AlibabaCloud.Region != "" {
+		defaults["regionId"] = config.platformStatus.AlibabaCloud.Region
+	}
+
+	if err := defaultRawProviderSpec(m, defaults); err != nil {
+		return false, []string{}, utilerrors.NewAggregate([]error{err})
+	}
+	return true, []string{}, nil
+}
+
+func validateAlibabaCloud(m *machinev1.Machine, config *admissionConfig) (bool, []string, utilerrors.Aggregate) {
+	klog.V(3).Infof("Validating Alibaba Cloud providerSpec")
+
+	var errs []error
+	var warnings []string
+	providerSpec := new(alibabaCloudProviderSpec)
+	if err := unmarshalInto(m, providerSpec); err != nil {
+		errs = append(errs, err)
+		return false, warnings, utilerrors.NewAggregate(errs)
+	}
+
+	if providerSpec.InstanceType == "" {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "instanceType"), "instanceType must be provided"))
+	}
+	if providerSpec.ImageID == "" {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "imageId"), "imageId must be provided"))
+	}
+
+	if providerSpec.RegionID == "" {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "regionId"), "regionId must be provided"))
+	} else if config.platformStatus != nil && config.platformStatus.Type == osconfigv1.AlibabaCloudPlatformType &&
+		config.platformStatus.AlibabaCloud != nil && config.platformStatus.AlibabaCloud.Region != "" &&
+		providerSpec.RegionID != config.platformStatus.AlibabaCloud.Region {
+		warnings = append(warnings, fmt.Sprintf("providerSpec.regionId: %s differs from the region of the cluster (%s): the instance may not reach the cluster network", providerSpec.RegionID, config.platformStatus.AlibabaCloud.Region))
+	}
+
+	// Alibaba Cloud zones are named after their region, eg. cn-hangzhou-i or us-east-1a.
+	if providerSpec.ZoneID == "" {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "zoneId"), "zoneId must be provided"))
+	} else if providerSpec.RegionID != "" && !strings.HasPrefix(providerSpec.ZoneID, providerSpec.RegionID) {
+		errs = append(errs, field.Invalid(field.NewPath("providerSpec", "zoneId"), providerSpec.ZoneID, fmt.Sprintf("zoneId must be in the region %s", providerSpec.RegionID)))
+	}
+
+	errs = append(errs, validateAlibabaCloudResourceReference(providerSpec.VSwitch, field.NewPath("providerSpec", "vSwitch"))...)
+
+	if len(providerSpec.SecurityGroups) == 0 {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "securityGroups"), "at least 1 security group must be provided"))
+	}
+	for i, securityGroup := range providerSpec.SecurityGroups {
+		errs = append(errs, validateAlibabaCloudResourceReference(securityGroup, field.NewPath("providerSpec", "securityGroups").Index(i))...)
+	}
+
+	if providerSpec.UserDataSecret == nil {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "userDataSecret"), "userDataSecret must be provided"))
+	} else if providerSpec.UserDataSecret.Name == "" {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "userDataSecret", "name"), "name must be provided"))
+	}
+
+	if providerSpec.CredentialsSecret == nil {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "credentialsSecret"), "credentialsSecret must be provided"))
+	} else if providerSpec.CredentialsSecret.Name == "" {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "credentialsSecret", "name"), "name must be provided"))
+	} else {
+		warnings = append(warnings, validateCredentialsSecret(config.secretsReader(), providerSpec.CredentialsSecret.Name, m.GetNamespace())...)
+	}
+
+	if len(errs) > 0 {
+		return false, warnings, utilerrors.NewAggregate(errs)
+	}
+	return true, warnings, nil
+}
+
+// validateAlibabaCloudResourceReference checks that the reference sets the ID, name or tags its type refers to.
+func validateAlibabaCloudResourceReference(reference alibabaCloudResourceReference, fldPath *field.Path) []error {
+	switch reference.Type {
+	case alibabaCloudReferenceTypeID:
+		if reference.ID == nil || *reference.ID == "" {
+			return []error{field.Required(fldPath.Child("id"), "id must be provided when type is ID")}
+		}
+	case alibabaCloudReferenceTypeName:
+		if reference.Name == nil || *reference.Name == "" {
+			return []error{field.Required(fldPath.Child("name"), "name must be provided when type is Name")}
+		}
+	case alibabaCloudReferenceTypeTags:
+		if reference.Tags == nil || len(*reference.Tags) == 0 {
+			return []error{field.Required(fldPath.Child("tags"), "tags must be provided when type is Tags")}
+		}
+	case "":
+		return []error{field.Required(fldPath.Child("type"), "type must be provided")}
+	default:
+		return []error{field.NotSupported(fldPath.Child("type"), reference.Type, []string{alibabaCloudReferenceTypeID, alibabaCloudReferenceTypeName, alibabaCloudReferenceTypeTags})}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package webhooks
+
+import (
+	"reflect"
+	"testing"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	yaml "sigs.k8s.io/yaml"
+)
+
+func TestDefaultAlibabaCloud(t *testing.T) {
+	platformStatus := &osconfigv1.PlatformStatus{
+		Type:         osconfigv1.AlibabaCloudPlatformType,
+		AlibabaCloud: &osconfigv1.AlibabaCloudPlatformStatus{Region: "cn-hangzhou"},
+	}
+
+	testCases := []struct {
+		testCase             string
+		providerSpec         string
+		expectedProviderSpec map[string]interface{}
+	}{
+		{
+			testCase:     "it defaults defaultable fields",
+			providerSpec: `{"imageId": "rhcos"}`,
+			expectedProviderSpec: map[string]interface{}{
+				"imageId":           "rhcos",
+				"instanceType":      defaultAlibabaCloudInstanceType,
+				"regionId":          "cn-hangzhou",
+				"userDataSecret":    map[string]interface{}{"name": defaultUserDataSecret},
+				"credentialsSecret": map[string]interface{}{"name": defaultAlibabaCloudCredentialsSecret},
+			},
+		},
+		{
+			testCase:     "it does not override the instance type and region",
+			providerSpec: `{"instanceType": "ecs.g6.xlarge", "regionId": "us-east-1"}`,
+			expectedProviderSpec: map[string]interface{}{
+				"instanceType":      "ecs.g6.xlarge",
+				"regionId":          "us-east-1",
+				"userDataSecret":    map[string]interface{}{"name": defaultUserDataSecret},
+				"credentialsSecret": map[string]interface{}{"name": defaultAlibabaCloudCredentialsSecret},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			m := &machinev1.Machine{
+				Spec: machinev1.MachineSpec{
+					ProviderSpec: machinev1.ProviderSpec{Value: &kruntime.RawExtension{Raw: []byte(tc.providerSpec)}},
+				},
+			}
+
+			ok, _, err := defaultAlibabaCloud(m, &admissionConfig{platformStatus: platformStatus})
+			if !ok || err != nil {
+				t.Fatalf("expected the providerSpec to be defaulted, got: %v", err)
+			}
+
+			providerSpec := map[string]interface{}{}
+			if err := yaml.Unmarshal(m.Spec.ProviderSpec.Value.Raw, &providerSpec); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(providerSpec, tc.expectedProviderSpec) {
+				t.Errorf("expected: %+v, got: %+v", tc.expectedProviderSpec, providerSpec)
+			}
+		})
+	}
+}
+
+func TestValidateAlibabaCloud(t *testing.T) {
+	namespace := "openshift-machine-api"
+	credentialsSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: defaultAlibabaCloudCredentialsSecret, Namespace: namespace},
+	}
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, credentialsSecret)
+
+	platformStatus := &osconfigv1.PlatformStatus{
+		Type:         osconfigv1.AlibabaCloudPlatformType,
+		AlibabaCloud: &osconfigv1.AlibabaCloudPlatformStatus{Region: "cn-hangzhou"},
+	}
+
+	validProviderSpec := func(modify func(*alibabaCloudProviderSpec)) *alibabaCloudProviderSpec {
+		providerSpec := &alibabaCloudProviderSpec{
+			InstanceType: defaultAlibabaCloudInstanceType,
+			ImageID:      "m-bp1bzoedvv8c4ocb1ipw",
+			RegionID:     "cn-hangzhou",
+			ZoneID:       "cn-hangzhou-i",
+			VSwitch:      alibabaCloudResourceReference{Type: alibabaCloudReferenceTypeID, ID: pointer.StringPtr("vsw-bp1ud3krcmo7scbtjqf8t")},
+			SecurityGroups: []alibabaCloudResourceReference{
+				{Type: alibabaCloudReferenceTypeTags, Tags: &[]map[string]string{{"Key": "Name", "Value": "cluster-sg-worker"}}},
+			},
+			UserDataSecret:    &corev1.LocalObjectReference{Name: defaultUserDataSecret},
+			CredentialsSecret: &corev1.LocalObjectReference{Name: defaultAlibabaCloudCredentialsSecret},
+		}
+		if modify != nil {
+			modify(providerSpec)
+		}
+		return providerSpec
+	}
+
+	testCases := []struct {
+		testCase         string
+		providerSpec     *alibabaCloudProviderSpec
+		expectedOk       bool
+		expectedError    string
+		expectedWarnings []string
+	}{
+		{
+			testCase:     "with a valid providerSpec",
+			providerSpec: validProviderSpec(nil),
+			expectedOk:   true,
+		},
+		{
+			testCase:      "with an empty providerSpec",
+			providerSpec:  &alibabaCloudProviderSpec{},
+			expectedOk:    false,
+			expectedError: "[providerSpec.instanceType: Required value: instanceType must be provided, providerSpec.imageId: Required value: imageId must be provided, providerSpec.regionId: Required value: regionId must be provided, providerSpec.zoneId: Required value: zoneId must be provided, providerSpec.vSwitch.type: Required value: type must be provided, providerSpec.securityGroups: Required value: at least 1 security group must be provided, providerSpec.userDataSecret: Required value: userDataSecret must be provided, providerSpec.credentialsSecret: Required value: credentialsSecret must be provided]",
+		},
+		{
+			testCase: "with a zone outside of the region",
+			providerSpec: validProviderSpec(func(p *alibabaCloudProviderSpec) {
+				p.ZoneID = "cn-shanghai-b"
+			}),
+			expectedOk:    false,
+			expectedError: "providerSpec.zoneId: Invalid value: \"cn-shanghai-b\": zoneId must be in the region cn-hangzhou",
+		},
+		{
+			testCase: "with another region than the cluster",
+			providerSpec: validProviderSpec(func(p *alibabaCloudProviderSpec) {
+				p.RegionID = "us-east-1"
+				p.ZoneID = "us-east-1a"
+			}),
+			expectedOk: true,
+			expectedWarnings: []string{
+				"providerSpec.regionId: us-east-1 differs from the region of the cluster (cn-hangzhou): the instance may not reach the cluster network",
+			},
+		},
+		{
+			testCase: "with references that do not match their type",
+			providerSpec: validProviderSpec(func(p *alibabaCloudProviderSpec) {
+				p.VSwitch = alibabaCloudResourceReference{Type: alibabaCloudReferenceTypeName, ID: pointer.StringPtr("vsw-bp1ud3krcmo7scbtjqf8t")}
+				p.SecurityGroups = []alibabaCloudResourceReference{
+					{Type: alibabaCloudReferenceTypeTags},
+					{Type: "ARN"},
+				}
+			}),
+			expectedOk:    false,
+			expectedError: "[providerSpec.vSwitch.name: Required value: name must be provided when type is Name, providerSpec.securityGroups[0].tags: Required value: tags must be provided when type is Tags, providerSpec.securityGroups[1].type: Unsupported value: \"ARN\": supported values: \"ID\", \"Name\", \"Tags\"]",
+		},
+		{
+			testCase: "with a credentials secret that does not exist",
+			providerSpec: validProviderSpec(func(p *alibabaCloudProviderSpec) {
+				p.CredentialsSecret = &corev1.LocalObjectReference{Name: "missing"}
+			}),
+			expectedOk: true,
+			expectedWarnings: []string{
+				"providerSpec.credentialsSecret: Invalid value: \"missing\": not found. Expected CredentialsSecret to exist",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			rawBytes, err := yaml.Marshal(tc.providerSpec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := &machinev1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace},
+				Spec: machinev1.MachineSpec{
+					ProviderSpec: machinev1.ProviderSpec{Value: &kruntime.RawExtension{Raw: rawBytes}},
+				},
+			}
+
+			ok, warnings, err := validateAlibabaCloud(m, &admissionConfig{client: c, platformStatus: platformStatus})
+			if ok != tc.expectedOk {
+				t.Errorf("expected: %v, got: %v", tc.expectedOk, ok)
+			}
+			if err == nil {
+				if tc.expectedError != "" {
+					t.Errorf("expected: %q, got: %v", tc.expectedError, err)
+				}
+			} else {
+				if err.Error() != tc.expectedError {
+					t.Errorf("expected: %q, got: %q", tc.expectedError, err.Error())
+				}
+			}
+			if !reflect.DeepEqual(warnings, tc.expectedWarnings) {
+				t.Errorf("expected: %q, got: %q", tc.expectedWarnings, warnings)
+			}
+		})
+	}
+}
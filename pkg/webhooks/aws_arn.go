@@ -0,0 +1,15 @@
+package webhooks
+
+import "strings"
+
+// awsARNRegion returns the region component of an ARN, for example "us-east-1" for
+// "arn:aws:ec2:us-east-1::image/ami-0123456789abcdef0".
+// ok is false when arn is not an ARN. Global resources have an ARN with an empty region.
+func awsARNRegion(arn string) (region string, ok bool) {
+	// arn:partition:service:region:account-id:resource
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[1] == "" || parts[2] == "" {
+		return "", false
+	}
+	return parts[3], true
+}
@@ -0,0 +1,51 @@
+package webhooks
+
+import "testing"
+
+func TestAWSARNRegion(t *testing.T) {
+	testCases := []struct {
+		arn            string
+		expectedRegion string
+		expectedOk     bool
+	}{
+		{
+			arn:            "arn:aws:ec2:us-east-1::image/ami-0123456789abcdef0",
+			expectedRegion: "us-east-1",
+			expectedOk:     true,
+		},
+		{
+			arn:            "arn:aws-us-gov:ec2:us-gov-west-1:123456789012:image/ami-0123456789abcdef0",
+			expectedRegion: "us-gov-west-1",
+			expectedOk:     true,
+		},
+		{
+			arn:            "arn:aws:iam::123456789012:instance-profile/worker",
+			expectedRegion: "",
+			expectedOk:     true,
+		},
+		{
+			arn:        "arn",
+			expectedOk: false,
+		},
+		{
+			arn:        "ami-0123456789abcdef0",
+			expectedOk: false,
+		},
+		{
+			arn:        "arn:aws:ec2:us-east-1",
+			expectedOk: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.arn, func(t *testing.T) {
+			region, ok := awsARNRegion(tc.arn)
+			if ok != tc.expectedOk {
+				t.Errorf("expected ok: %v, got: %v", tc.expectedOk, ok)
+			}
+			if region != tc.expectedRegion {
+				t.Errorf("expected region %q, got: %q", tc.expectedRegion, region)
+			}
+		})
+	}
+}
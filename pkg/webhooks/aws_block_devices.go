@@ -0,0 +1,81 @@
+package webhooks
+
+import (
+	"fmt"
+	"strings"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// awsIO2BlockExpressMinIOPS is the IOPS above which io2 volumes are provisioned as io2 Block Express.
+const awsIO2BlockExpressMinIOPS = 64000
+
+// awsXenInstanceFamilies are the instance families built on the Xen hypervisor rather than the Nitro system.
+// https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/instance-types.html#ec2-nitro-instances
+var awsXenInstanceFamilies = sets.NewString(
+	"c1",
+	"c3",
+	"c4",
+	"d2",
+	"f1",
+	"g2",
+	"g3",
+	"g3s",
+	"h1",
+	"i2",
+	"i3",
+	"m1",
+	"m2",
+	"m3",
+	"m4",
+	"p2",
+	"p3",
+	"r3",
+	"r4",
+	"t1",
+	"t2",
+	"x1",
+	"x1e",
+)
+
+// awsBootIncompatibleVolumeTypes are the EBS volume types which cannot be used as a root device.
+var awsBootIncompatibleVolumeTypes = sets.NewString("st1", "sc1")
+
+// awsInstanceFamily returns the family of an instance type, eg. m5 for m5.xlarge.
+func awsInstanceFamily(instanceType string) string {
+	return strings.SplitN(instanceType, ".", 2)[0]
+}
+
+// isAWSNitroInstanceType returns true if the instance type is built on the Nitro system.
+// All current generation families are, so families missing from awsXenInstanceFamilies are assumed to be.
+func isAWSNitroInstanceType(instanceType string) bool {
+	return !awsXenInstanceFamilies.Has(awsInstanceFamily(instanceType))
+}
+
+// validateAWSBlockDevices checks the volume types of the block devices against their use and the instance type.
+// The root device is the block device without a device name.
+func validateAWSBlockDevices(providerSpec *machinev1.AWSMachineProviderConfig) ([]string, []error) {
+	var warnings []string
+	var errs []error
+
+	blockDevicesPath := field.NewPath("providerSpec", "blockDevices")
+	for i, blockDevice := range providerSpec.BlockDevices {
+		if blockDevice.EBS == nil || blockDevice.EBS.VolumeType == nil {
+			continue
+		}
+		volumeType := *blockDevice.EBS.VolumeType
+		ebsPath := blockDevicesPath.Index(i).Child("ebs")
+
+		if blockDevice.DeviceName == nil && awsBootIncompatibleVolumeTypes.Has(volumeType) {
+			errs = append(errs, field.Invalid(ebsPath.Child("volumeType"), volumeType, fmt.Sprintf("%s volumes cannot be used as the root device, use one of gp2, gp3, io1, io2 or standard", volumeType)))
+		}
+
+		if volumeType == "io2" && blockDevice.EBS.Iops != nil && *blockDevice.EBS.Iops > awsIO2BlockExpressMinIOPS && providerSpec.InstanceType != "" && !isAWSNitroInstanceType(providerSpec.InstanceType) {
+			warnings = append(warnings, fmt.Sprintf("%s: io2 volumes with more than %d IOPS are io2 Block Express volumes, which only attach to Nitro instances: %s is not a Nitro instance family", ebsPath.Child("iops"), awsIO2BlockExpressMinIOPS, awsInstanceFamily(providerSpec.InstanceType)))
+		}
+	}
+
+	return warnings, errs
+}
@@ -0,0 +1,118 @@
+package webhooks
+
+import (
+	"reflect"
+	"testing"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"k8s.io/utils/pointer"
+)
+
+func TestIsAWSNitroInstanceType(t *testing.T) {
+	testCases := []struct {
+		instanceType string
+		expected     bool
+	}{
+		{instanceType: "m5.xlarge", expected: true},
+		{instanceType: "m6g.large", expected: true},
+		{instanceType: "i3en.large", expected: true},
+		{instanceType: "m4.xlarge", expected: false},
+		{instanceType: "t2.micro", expected: false},
+		{instanceType: "i3.large", expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.instanceType, func(t *testing.T) {
+			if got := isAWSNitroInstanceType(tc.instanceType); got != tc.expected {
+				t.Errorf("expected: %v, got: %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestValidateAWSBlockDevices(t *testing.T) {
+	testCases := []struct {
+		testCase         string
+		instanceType     string
+		blockDevices     []machinev1.BlockDeviceMappingSpec
+		expectedWarnings []string
+		expectedErrors   []string
+	}{
+		{
+			testCase:     "with a gp3 root device",
+			instanceType: "m5.xlarge",
+			blockDevices: []machinev1.BlockDeviceMappingSpec{
+				{EBS: &machinev1.EBSBlockDeviceSpec{VolumeType: pointer.StringPtr("gp3")}},
+			},
+		},
+		{
+			testCase:     "with an st1 root device",
+			instanceType: "m5.xlarge",
+			blockDevices: []machinev1.BlockDeviceMappingSpec{
+				{EBS: &machinev1.EBSBlockDeviceSpec{VolumeType: pointer.StringPtr("st1")}},
+			},
+			expectedErrors: []string{"providerSpec.blockDevices[0].ebs.volumeType: Invalid value: \"st1\": st1 volumes cannot be used as the root device, use one of gp2, gp3, io1, io2 or standard"},
+		},
+		{
+			testCase:     "with an sc1 root device",
+			instanceType: "m5.xlarge",
+			blockDevices: []machinev1.BlockDeviceMappingSpec{
+				{EBS: &machinev1.EBSBlockDeviceSpec{VolumeType: pointer.StringPtr("gp3")}},
+				{EBS: &machinev1.EBSBlockDeviceSpec{VolumeType: pointer.StringPtr("sc1")}},
+			},
+			expectedErrors: []string{"providerSpec.blockDevices[1].ebs.volumeType: Invalid value: \"sc1\": sc1 volumes cannot be used as the root device, use one of gp2, gp3, io1, io2 or standard"},
+		},
+		{
+			testCase:     "with an st1 data device",
+			instanceType: "m5.xlarge",
+			blockDevices: []machinev1.BlockDeviceMappingSpec{
+				{EBS: &machinev1.EBSBlockDeviceSpec{VolumeType: pointer.StringPtr("gp3")}},
+				{DeviceName: pointer.StringPtr("/dev/sdf"), EBS: &machinev1.EBSBlockDeviceSpec{VolumeType: pointer.StringPtr("st1")}},
+			},
+		},
+		{
+			testCase:     "with an io2 Block Express device on a non-Nitro family",
+			instanceType: "m4.xlarge",
+			blockDevices: []machinev1.BlockDeviceMappingSpec{
+				{EBS: &machinev1.EBSBlockDeviceSpec{VolumeType: pointer.StringPtr("io2"), Iops: pointer.Int64Ptr(100000)}},
+			},
+			expectedWarnings: []string{"providerSpec.blockDevices[0].ebs.iops: io2 volumes with more than 64000 IOPS are io2 Block Express volumes, which only attach to Nitro instances: m4 is not a Nitro instance family"},
+		},
+		{
+			testCase:     "with an io2 Block Express device on a Nitro family",
+			instanceType: "r5b.xlarge",
+			blockDevices: []machinev1.BlockDeviceMappingSpec{
+				{EBS: &machinev1.EBSBlockDeviceSpec{VolumeType: pointer.StringPtr("io2"), Iops: pointer.Int64Ptr(100000)}},
+			},
+		},
+		{
+			testCase:     "with an io2 device on a non-Nitro family",
+			instanceType: "m4.xlarge",
+			blockDevices: []machinev1.BlockDeviceMappingSpec{
+				{EBS: &machinev1.EBSBlockDeviceSpec{VolumeType: pointer.StringPtr("io2"), Iops: pointer.Int64Ptr(64000)}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			providerSpec := &machinev1.AWSMachineProviderConfig{
+				InstanceType: tc.instanceType,
+				BlockDevices: tc.blockDevices,
+			}
+
+			warnings, errs := validateAWSBlockDevices(providerSpec)
+			if !reflect.DeepEqual(warnings, tc.expectedWarnings) {
+				t.Errorf("expected warnings: %q, got: %q", tc.expectedWarnings, warnings)
+			}
+
+			var errStrings []string
+			for _, err := range errs {
+				errStrings = append(errStrings, err.Error())
+			}
+			if !reflect.DeepEqual(errStrings, tc.expectedErrors) {
+				t.Errorf("expected errors: %q, got: %q", tc.expectedErrors, errStrings)
+			}
+		})
+	}
+}
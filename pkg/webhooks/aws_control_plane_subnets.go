@@ -0,0 +1,99 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// machineRoleLabel is the label used to record the role of a Machine.
+	machineRoleLabel = "machine.openshift.io/cluster-api-machine-role"
+	// machineRoleMaster is the machineRoleLabel value of control plane Machines.
+	machineRoleMaster = "master"
+)
+
+// validateAWSControlPlaneSubnet warns when a control plane Machine registered with a network load
+// balancer uses a subnet which none of the other control plane Machines use.
+// The webhook cannot inspect the load balancer itself, so the subnets of the sibling Machines are
+// used as the record of which subnets are registered with it. Instances in other subnets fail the
+// load balancer health checks once registered.
+func validateAWSControlPlaneSubnet(m *machinev1.Machine, providerSpec *machinev1.AWSMachineProviderConfig, c client.Client) []string {
+	if c == nil || m.Labels[machineRoleLabel] != machineRoleMaster || !hasAWSNetworkLoadBalancer(providerSpec) {
+		return nil
+	}
+
+	subnet := awsResourceReferenceString(providerSpec.Subnet)
+	if subnet == "" {
+		// A missing subnet is warned about separately.
+		return nil
+	}
+
+	machines := &machinev1.MachineList{}
+	if err := c.List(context.Background(), machines, client.InNamespace(m.GetNamespace()), client.MatchingLabels{machineRoleLabel: machineRoleMaster}); err != nil {
+		klog.Errorf("Unable to list control plane machines to compare subnets: %v", err)
+		return nil
+	}
+
+	siblingSubnets := sets.NewString()
+	for i := range machines.Items {
+		sibling := &machines.Items[i]
+		if sibling.GetName() == m.GetName() || sibling.GetDeletionTimestamp() != nil || sibling.Spec.ProviderSpec.Value == nil {
+			continue
+		}
+
+		siblingProviderSpec := new(machinev1.AWSMachineProviderConfig)
+		if err := unmarshalInto(sibling, siblingProviderSpec); err != nil {
+			klog.V(3).Infof("Skipping subnet comparison with machine %s: %v", sibling.GetName(), err)
+			continue
+		}
+		if siblingSubnet := awsResourceReferenceString(siblingProviderSpec.Subnet); siblingSubnet != "" {
+			siblingSubnets.Insert(siblingSubnet)
+		}
+	}
+
+	if siblingSubnets.Len() == 0 || siblingSubnets.Has(subnet) {
+		return nil
+	}
+
+	return []string{
+		fmt.Sprintf("providerSpec.subnet: %s is not used by any other control plane machine (%s): the machine will fail network load balancer health checks unless the subnet is registered with the load balancer", subnet, strings.Join(siblingSubnets.List(), ", ")),
+	}
+}
+
+// hasAWSNetworkLoadBalancer returns true when the providerSpec registers the instance with a network load balancer.
+func hasAWSNetworkLoadBalancer(providerSpec *machinev1.AWSMachineProviderConfig) bool {
+	for _, lb := range providerSpec.LoadBalancers {
+		if lb.Type == machinev1.NetworkLoadBalancerType {
+			return true
+		}
+	}
+	return false
+}
+
+// awsResourceReferenceString returns a comparable, human readable form of an AWS resource reference,
+// or an empty string when the reference is not set.
+func awsResourceReferenceString(ref machinev1.AWSResourceReference) string {
+	switch {
+	case ref.ID != nil:
+		return *ref.ID
+	case ref.ARN != nil:
+		return *ref.ARN
+	case len(ref.Filters) > 0:
+		filters := make([]string, 0, len(ref.Filters))
+		for _, filter := range ref.Filters {
+			values := append([]string{}, filter.Values...)
+			sort.Strings(values)
+			filters = append(filters, fmt.Sprintf("%s=%s", filter.Name, strings.Join(values, ",")))
+		}
+		sort.Strings(filters)
+		return fmt.Sprintf("filters[%s]", strings.Join(filters, " "))
+	}
+	return ""
+}
@@ -0,0 +1,159 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newAWSControlPlaneMachine(t *testing.T, name, role string, providerSpec *machinev1.AWSMachineProviderConfig) *machinev1.Machine {
+	rawBytes, err := json.Marshal(providerSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "openshift-machine-api",
+			Labels: map[string]string{
+				machineRoleLabel: role,
+			},
+		},
+		Spec: machinev1.MachineSpec{
+			ProviderSpec: machinev1.ProviderSpec{
+				Value: &kruntime.RawExtension{Raw: rawBytes},
+			},
+		},
+	}
+}
+
+func newAWSControlPlaneProviderSpec(subnetID string) *machinev1.AWSMachineProviderConfig {
+	return &machinev1.AWSMachineProviderConfig{
+		Subnet: machinev1.AWSResourceReference{ID: pointer.StringPtr(subnetID)},
+		LoadBalancers: []machinev1.LoadBalancerReference{
+			{Name: "cluster-int", Type: machinev1.NetworkLoadBalancerType},
+			{Name: "cluster-ext", Type: machinev1.NetworkLoadBalancerType},
+		},
+	}
+}
+
+func TestValidateAWSControlPlaneSubnet(t *testing.T) {
+	siblings := []client.Object{
+		newAWSControlPlaneMachine(t, "master-0", machineRoleMaster, newAWSControlPlaneProviderSpec("subnet-a")),
+		newAWSControlPlaneMachine(t, "master-1", machineRoleMaster, newAWSControlPlaneProviderSpec("subnet-b")),
+		newAWSControlPlaneMachine(t, "master-2", machineRoleMaster, newAWSControlPlaneProviderSpec("subnet-c")),
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(siblings...).Build()
+
+	testCases := []struct {
+		testCase         string
+		name             string
+		role             string
+		modifySpec       func(*machinev1.AWSMachineProviderConfig)
+		expectedWarnings []string
+	}{
+		{
+			testCase:         "with a new master in a sibling subnet",
+			name:             "master-3",
+			role:             machineRoleMaster,
+			modifySpec:       func(p *machinev1.AWSMachineProviderConfig) { p.Subnet.ID = pointer.StringPtr("subnet-a") },
+			expectedWarnings: nil,
+		},
+		{
+			testCase: "with a new master in a divergent subnet",
+			name:     "master-3",
+			role:     machineRoleMaster,
+			expectedWarnings: []string{
+				"providerSpec.subnet: subnet-d is not used by any other control plane machine (subnet-a, subnet-b, subnet-c): the machine will fail network load balancer health checks unless the subnet is registered with the load balancer",
+			},
+		},
+		{
+			testCase: "with an existing master moved to a divergent subnet",
+			name:     "master-0",
+			role:     machineRoleMaster,
+			expectedWarnings: []string{
+				"providerSpec.subnet: subnet-d is not used by any other control plane machine (subnet-b, subnet-c): the machine will fail network load balancer health checks unless the subnet is registered with the load balancer",
+			},
+		},
+		{
+			testCase:         "with a worker in a divergent subnet",
+			name:             "worker-0",
+			role:             "worker",
+			expectedWarnings: nil,
+		},
+		{
+			testCase:         "with a new master in a divergent subnet without a network load balancer",
+			name:             "master-3",
+			role:             machineRoleMaster,
+			modifySpec:       func(p *machinev1.AWSMachineProviderConfig) { p.LoadBalancers = nil },
+			expectedWarnings: nil,
+		},
+		{
+			testCase:         "with a new master without a subnet",
+			name:             "master-3",
+			role:             machineRoleMaster,
+			modifySpec:       func(p *machinev1.AWSMachineProviderConfig) { p.Subnet = machinev1.AWSResourceReference{} },
+			expectedWarnings: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			providerSpec := newAWSControlPlaneProviderSpec("subnet-d")
+			if tc.modifySpec != nil {
+				tc.modifySpec(providerSpec)
+			}
+			m := newAWSControlPlaneMachine(t, tc.name, tc.role, providerSpec)
+
+			warnings := validateAWSControlPlaneSubnet(m, providerSpec, c)
+			if !reflect.DeepEqual(warnings, tc.expectedWarnings) {
+				t.Errorf("expected: %q, got: %q", tc.expectedWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestAWSResourceReferenceString(t *testing.T) {
+	testCases := []struct {
+		ref      machinev1.AWSResourceReference
+		expected string
+	}{
+		{
+			ref:      machinev1.AWSResourceReference{},
+			expected: "",
+		},
+		{
+			ref:      machinev1.AWSResourceReference{ID: pointer.StringPtr("subnet-a")},
+			expected: "subnet-a",
+		},
+		{
+			ref:      machinev1.AWSResourceReference{ARN: pointer.StringPtr("arn:aws:ec2:region:account:subnet/subnet-a")},
+			expected: "arn:aws:ec2:region:account:subnet/subnet-a",
+		},
+		{
+			ref: machinev1.AWSResourceReference{
+				Filters: []machinev1.Filter{
+					{Name: "tag:Name", Values: []string{"cluster-private-b", "cluster-private-a"}},
+					{Name: "availability-zone", Values: []string{"us-east-1a"}},
+				},
+			},
+			expected: "filters[availability-zone=us-east-1a tag:Name=cluster-private-a,cluster-private-b]",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			if got := awsResourceReferenceString(tc.ref); got != tc.expected {
+				t.Errorf("expected: %q, got: %q", tc.expected, got)
+			}
+		})
+	}
+}
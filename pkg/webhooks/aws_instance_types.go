@@ -0,0 +1,148 @@
+package webhooks
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	// AWS instance type architectures, as reported by DescribeInstanceTypes.
+	awsArchX86_64 = "x86_64"
+	awsArchARM64  = "arm64"
+
+	// Minimum resources of an OpenShift compute node.
+	// https://docs.openshift.com/container-platform/4.10/installing/installing_aws/installing-aws-customizations.html#installation-minimum-resource-requirements_installing-aws-customizations
+	minAWSNodeVCPUs     = 2
+	minAWSNodeMemoryMiB = 8192
+)
+
+// awsInstanceTypeNameRegexp matches the <family>.<size> names of AWS instance types.
+var awsInstanceTypeNameRegexp = regexp.MustCompile(`^([a-z][a-z0-9-]*)\.([a-z0-9]+)$`)
+
+// awsInstanceSize is the capacity of an AWS instance type.
+type awsInstanceSize struct {
+	vCPUs     int
+	memoryMiB int
+}
+
+// awsInstanceFamilyInfo is an AWS instance family and the sizes it is offered in.
+type awsInstanceFamilyInfo struct {
+	arch  string
+	sizes map[string]awsInstanceSize
+}
+
+// awsInstanceFamilies is an offline catalog of the instance families commonly used for OpenShift nodes.
+// Instance types of other families are not checked.
+// https://aws.amazon.com/ec2/instance-types/
+var awsInstanceFamilies = map[string]awsInstanceFamilyInfo{
+	"t2":  {arch: awsArchX86_64, sizes: awsBurstableSizes(1)},
+	"t3":  {arch: awsArchX86_64, sizes: awsBurstableSizes(2)},
+	"t3a": {arch: awsArchX86_64, sizes: awsBurstableSizes(2)},
+	"t4g": {arch: awsArchARM64, sizes: awsBurstableSizes(2)},
+	"m5":  {arch: awsArchX86_64, sizes: awsSizes(4096, "large", "xlarge", "2xlarge", "4xlarge", "8xlarge", "12xlarge", "16xlarge", "24xlarge", "metal")},
+	"m5a": {arch: awsArchX86_64, sizes: awsSizes(4096, "large", "xlarge", "2xlarge", "4xlarge", "8xlarge", "12xlarge", "16xlarge", "24xlarge")},
+	"m6i": {arch: awsArchX86_64, sizes: awsSizes(4096, "large", "xlarge", "2xlarge", "4xlarge", "8xlarge", "12xlarge", "16xlarge", "24xlarge", "32xlarge", "metal")},
+	"m6g": {arch: awsArchARM64, sizes: awsSizes(4096, "medium", "large", "xlarge", "2xlarge", "4xlarge", "8xlarge", "12xlarge", "16xlarge", "metal")},
+	"c5":  {arch: awsArchX86_64, sizes: awsSizes(2048, "large", "xlarge", "2xlarge", "4xlarge", "9xlarge", "12xlarge", "18xlarge", "24xlarge", "metal")},
+	"c6i": {arch: awsArchX86_64, sizes: awsSizes(2048, "large", "xlarge", "2xlarge", "4xlarge", "8xlarge", "12xlarge", "16xlarge", "24xlarge", "32xlarge", "metal")},
+	"c6g": {arch: awsArchARM64, sizes: awsSizes(2048, "medium", "large", "xlarge", "2xlarge", "4xlarge", "8xlarge", "12xlarge", "16xlarge", "metal")},
+	"r5":  {arch: awsArchX86_64, sizes: awsSizes(8192, "large", "xlarge", "2xlarge", "4xlarge", "8xlarge", "12xlarge", "16xlarge", "24xlarge", "metal")},
+	"r6i": {arch: awsArchX86_64, sizes: awsSizes(8192, "large", "xlarge", "2xlarge", "4xlarge", "8xlarge", "12xlarge", "16xlarge", "24xlarge", "32xlarge", "metal")},
+	"r6g": {arch: awsArchARM64, sizes: awsSizes(8192, "medium", "large", "xlarge", "2xlarge", "4xlarge", "8xlarge", "12xlarge", "16xlarge", "metal")},
+}
+
+// awsSizeVCPUs are the vCPUs of the AWS instance sizes. Metal sizes have as many vCPUs as the largest
+// virtualized size of their family, see awsSizes.
+var awsSizeVCPUs = map[string]int{
+	"medium":   1,
+	"large":    2,
+	"xlarge":   4,
+	"2xlarge":  8,
+	"4xlarge":  16,
+	"8xlarge":  32,
+	"9xlarge":  36,
+	"12xlarge": 48,
+	"16xlarge": 64,
+	"18xlarge": 72,
+	"24xlarge": 96,
+	"32xlarge": 128,
+}
+
+// awsSizes returns the sizes of a fixed performance family with the given memory per vCPU.
+func awsSizes(memoryMiBPerVCPU int, names ...string) map[string]awsInstanceSize {
+	sizes := make(map[string]awsInstanceSize, len(names))
+	largest := 0
+	for _, name := range names {
+		if vCPUs := awsSizeVCPUs[name]; vCPUs > largest {
+			largest = vCPUs
+		}
+	}
+	for _, name := range names {
+		vCPUs, ok := awsSizeVCPUs[name]
+		if name == "metal" || !ok {
+			vCPUs = largest
+		}
+		sizes[name] = awsInstanceSize{vCPUs: vCPUs, memoryMiB: vCPUs * memoryMiBPerVCPU}
+	}
+	return sizes
+}
+
+// awsBurstableSizes returns the sizes of a burstable family, whose smallest sizes have the given vCPUs.
+func awsBurstableSizes(smallVCPUs int) map[string]awsInstanceSize {
+	return map[string]awsInstanceSize{
+		"nano":    {vCPUs: smallVCPUs, memoryMiB: 512},
+		"micro":   {vCPUs: smallVCPUs, memoryMiB: 1024},
+		"small":   {vCPUs: smallVCPUs, memoryMiB: 2048},
+		"medium":  {vCPUs: 2, memoryMiB: 4096},
+		"large":   {vCPUs: 2, memoryMiB: 8192},
+		"xlarge":  {vCPUs: 4, memoryMiB: 16384},
+		"2xlarge": {vCPUs: 8, memoryMiB: 32768},
+	}
+}
+
+// awsAMIArch returns the architecture of the AMI referenced by the providerSpec, as set by its architecture
+// filter. AMIs referenced by ID cannot be looked up offline, so their architecture is not known.
+func awsAMIArch(providerSpec *machinev1.AWSMachineProviderConfig) (string, bool) {
+	for _, filter := range providerSpec.AMI.Filters {
+		if filter.Name == "architecture" && len(filter.Values) == 1 {
+			return filter.Values[0], true
+		}
+	}
+	return "", false
+}
+
+// warnAWSInstanceType checks the instance type of a new Machine against the offline catalog. It warns about
+// malformed names and unknown sizes of known families, which are likely typos, as the catalog may lag behind
+// the sizes AWS offers. It also warns when the instance type is below the minimum resources of a node, or does
+// not match the architecture of the AMI.
+func warnAWSInstanceType(providerSpec *machinev1.AWSMachineProviderConfig) []string {
+	instanceType := providerSpec.InstanceType
+	fldPath := field.NewPath("providerSpec", "instanceType")
+	match := awsInstanceTypeNameRegexp.FindStringSubmatch(instanceType)
+	if match == nil {
+		return []string{fmt.Sprintf("%s: %s is not of the form <family>.<size>, eg. m5.large: the instance may fail to run", fldPath, instanceType)}
+	}
+
+	family, ok := awsInstanceFamilies[match[1]]
+	if !ok {
+		return nil
+	}
+	size, ok := family.sizes[match[2]]
+	if !ok {
+		return []string{fmt.Sprintf("%s: %s is not a known size of the %s instances, offered in sizes: %s: the instance may fail to run", fldPath, instanceType, match[1], strings.Join(sets.StringKeySet(family.sizes).List(), ", "))}
+	}
+
+	var warnings []string
+	if size.vCPUs < minAWSNodeVCPUs || size.memoryMiB < minAWSNodeMemoryMiB {
+		warnings = append(warnings, fmt.Sprintf("%s: %s has %d vCPUs and %d MiB of memory, less than the minimum of a node (%d vCPUs and %d MiB): nodes may not become ready", fldPath, instanceType, size.vCPUs, size.memoryMiB, minAWSNodeVCPUs, minAWSNodeMemoryMiB))
+	}
+	if amiArch, ok := awsAMIArch(providerSpec); ok && family.arch != amiArch {
+		warnings = append(warnings, fmt.Sprintf("%s: %s is an %s instance type but the AMI is an %s image: the instance will fail to boot", fldPath, instanceType, family.arch, amiArch))
+	}
+	return warnings
+}
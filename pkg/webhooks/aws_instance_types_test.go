@@ -0,0 +1,80 @@
+package webhooks
+
+import (
+	"reflect"
+	"testing"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+)
+
+func TestWarnAWSInstanceType(t *testing.T) {
+	testCases := []struct {
+		testCase         string
+		instanceType     string
+		amiFilters       []machinev1.Filter
+		expectedWarnings []string
+	}{
+		{
+			testCase:     "with a known instance type",
+			instanceType: defaultAWSX86InstanceType,
+			amiFilters:   []machinev1.Filter{{Name: "architecture", Values: []string{awsArchX86_64}}},
+		},
+		{
+			testCase:     "with an arm64 instance type and an arm64 AMI",
+			instanceType: defaultAWSARMInstanceType,
+			amiFilters:   []machinev1.Filter{{Name: "architecture", Values: []string{awsArchARM64}}},
+		},
+		{
+			testCase:     "with an instance type of a family missing from the catalog",
+			instanceType: "x2iedn.xlarge",
+		},
+		{
+			testCase:     "with a typo in the size",
+			instanceType: "m5.largee",
+			expectedWarnings: []string{
+				"providerSpec.instanceType: m5.largee is not a known size of the m5 instances, offered in sizes: 12xlarge, 16xlarge, 24xlarge, 2xlarge, 4xlarge, 8xlarge, large, metal, xlarge: the instance may fail to run",
+			},
+		},
+		{
+			testCase:     "with a malformed instance type",
+			instanceType: "m5large",
+			expectedWarnings: []string{
+				"providerSpec.instanceType: m5large is not of the form <family>.<size>, eg. m5.large: the instance may fail to run",
+			},
+		},
+		{
+			testCase:     "with an instance type below the minimum resources of a node",
+			instanceType: "t3.medium",
+			amiFilters:   []machinev1.Filter{{Name: "architecture", Values: []string{awsArchX86_64}}},
+			expectedWarnings: []string{
+				"providerSpec.instanceType: t3.medium has 2 vCPUs and 4096 MiB of memory, less than the minimum of a node (2 vCPUs and 8192 MiB): nodes may not become ready",
+			},
+		},
+		{
+			testCase:     "with an arm64 AMI and an x86_64 instance type",
+			instanceType: "m5.large",
+			amiFilters:   []machinev1.Filter{{Name: "architecture", Values: []string{awsArchARM64}}},
+			expectedWarnings: []string{
+				"providerSpec.instanceType: m5.large is an x86_64 instance type but the AMI is an arm64 image: the instance will fail to boot",
+			},
+		},
+		{
+			testCase:     "with an AMI referenced by ID",
+			instanceType: "m6g.large",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			providerSpec := &machinev1.AWSMachineProviderConfig{
+				InstanceType: tc.instanceType,
+				AMI:          machinev1.AWSResourceReference{Filters: tc.amiFilters},
+			}
+
+			warnings := warnAWSInstanceType(providerSpec)
+			if !reflect.DeepEqual(warnings, tc.expectedWarnings) {
+				t.Errorf("expected: %q, got: %q", tc.expectedWarnings, warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package webhooks
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// awsLocalZonePattern matches Local Zone names, which extend the region with a location
+// and zone number segment, eg. us-west-2-lax-1a. Regular availability zones only append
+// a letter to the region, eg. us-west-2a.
+var awsLocalZonePattern = regexp.MustCompile(`^[a-z]{2}(-gov)?-[a-z]+-[0-9]+-[a-z]+-[0-9]+[a-z]$`)
+
+// awsLocalZoneInstanceFamilies are the instance families offered in Local Zones.
+// https://aws.amazon.com/about-aws/global-infrastructure/localzones/features/
+var awsLocalZoneInstanceFamilies = sets.NewString(
+	"c5",
+	"c5d",
+	"g4dn",
+	"i3en",
+	"m5",
+	"m5d",
+	"r5",
+	"r5d",
+	"t3",
+)
+
+// isAWSLocalZone returns true if the availability zone is a Local Zone.
+func isAWSLocalZone(availabilityZone string) bool {
+	return awsLocalZonePattern.MatchString(availabilityZone)
+}
+
+// validateAWSLocalZone warns about instance types and volume types which Local Zones may not offer.
+func validateAWSLocalZone(providerSpec *machinev1.AWSMachineProviderConfig) []string {
+	if !isAWSLocalZone(providerSpec.Placement.AvailabilityZone) {
+		return nil
+	}
+
+	var warnings []string
+	family := awsInstanceFamily(providerSpec.InstanceType)
+	if providerSpec.InstanceType != "" && !awsLocalZoneInstanceFamilies.Has(family) {
+		warnings = append(warnings, fmt.Sprintf("providerSpec.instanceType: %s instances are not offered in Local Zones: %s is a Local Zone, use one of the %s instance families", family, providerSpec.Placement.AvailabilityZone, strings.Join(awsLocalZoneInstanceFamilies.List(), ", ")))
+	}
+
+	blockDevicesPath := field.NewPath("providerSpec", "blockDevices")
+	for i, blockDevice := range providerSpec.BlockDevices {
+		if blockDevice.EBS == nil || blockDevice.EBS.VolumeType == nil || *blockDevice.EBS.VolumeType != "gp3" {
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("%s: gp3 volumes are not available in all Local Zones: use gp2 if %s does not offer gp3", blockDevicesPath.Index(i).Child("ebs", "volumeType"), providerSpec.Placement.AvailabilityZone))
+	}
+
+	return warnings
+}
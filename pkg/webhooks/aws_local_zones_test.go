@@ -0,0 +1,107 @@
+package webhooks
+
+import (
+	"reflect"
+	"testing"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"k8s.io/utils/pointer"
+)
+
+func TestIsAWSLocalZone(t *testing.T) {
+	testCases := []struct {
+		availabilityZone string
+		expected         bool
+	}{
+		{availabilityZone: "us-west-2-lax-1a", expected: true},
+		{availabilityZone: "us-east-1-bos-1a", expected: true},
+		{availabilityZone: "us-gov-west-1-abc-1a", expected: true},
+		{availabilityZone: "us-west-2a", expected: false},
+		{availabilityZone: "us-gov-west-1a", expected: false},
+		{availabilityZone: "", expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.availabilityZone, func(t *testing.T) {
+			if got := isAWSLocalZone(tc.availabilityZone); got != tc.expected {
+				t.Errorf("expected: %v, got: %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestValidateAWSLocalZone(t *testing.T) {
+	testCases := []struct {
+		testCase         string
+		availabilityZone string
+		instanceType     string
+		volumeType       *string
+		expectedWarnings []string
+	}{
+		{
+			testCase:         "with a supported family in a Local Zone",
+			availabilityZone: "us-west-2-lax-1a",
+			instanceType:     "m5.xlarge",
+			expectedWarnings: nil,
+		},
+		{
+			testCase:         "with an unsupported family in a Local Zone",
+			availabilityZone: "us-west-2-lax-1a",
+			instanceType:     "m6i.xlarge",
+			expectedWarnings: []string{
+				"providerSpec.instanceType: m6i instances are not offered in Local Zones: us-west-2-lax-1a is a Local Zone, use one of the c5, c5d, g4dn, i3en, m5, m5d, r5, r5d, t3 instance families",
+			},
+		},
+		{
+			testCase:         "with an unsupported family in a regular availability zone",
+			availabilityZone: "us-west-2a",
+			instanceType:     "m6i.xlarge",
+			expectedWarnings: nil,
+		},
+		{
+			testCase:         "with a gp3 volume in a Local Zone",
+			availabilityZone: "us-west-2-lax-1a",
+			instanceType:     "m5.xlarge",
+			volumeType:       pointer.StringPtr("gp3"),
+			expectedWarnings: []string{
+				"providerSpec.blockDevices[0].ebs.volumeType: gp3 volumes are not available in all Local Zones: use gp2 if us-west-2-lax-1a does not offer gp3",
+			},
+		},
+		{
+			testCase:         "with a gp2 volume in a Local Zone",
+			availabilityZone: "us-west-2-lax-1a",
+			instanceType:     "m5.xlarge",
+			volumeType:       pointer.StringPtr("gp2"),
+			expectedWarnings: nil,
+		},
+		{
+			testCase:         "with a gp3 volume in a regular availability zone",
+			availabilityZone: "us-west-2a",
+			instanceType:     "m5.xlarge",
+			volumeType:       pointer.StringPtr("gp3"),
+			expectedWarnings: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			providerSpec := &machinev1.AWSMachineProviderConfig{
+				InstanceType: tc.instanceType,
+				Placement: machinev1.Placement{
+					Region:           "us-west-2",
+					AvailabilityZone: tc.availabilityZone,
+				},
+			}
+			if tc.volumeType != nil {
+				providerSpec.BlockDevices = []machinev1.BlockDeviceMappingSpec{
+					{EBS: &machinev1.EBSBlockDeviceSpec{VolumeType: tc.volumeType}},
+				}
+			}
+
+			warnings := validateAWSLocalZone(providerSpec)
+			if !reflect.DeepEqual(warnings, tc.expectedWarnings) {
+				t.Errorf("expected: %q, got: %q", tc.expectedWarnings, warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// terminationHandlerDaemonSetName is the DaemonSet which drains spot instances ahead of their termination.
+	terminationHandlerDaemonSetName = "machine-api-termination-handler"
+
+	// awsPlacementGroupNameField is the providerSpec field of newer AWS providerSpecs naming the placement group
+	// of the instance. It is not modeled by this version of AWSMachineProviderConfig.
+	awsPlacementGroupNameField = "placementGroupName"
+)
+
+// awsSpotMaxPricePattern matches the decimal prices accepted for spot instances, eg. 0.05.
+var awsSpotMaxPricePattern = regexp.MustCompile(`^[0-9]*\.?[0-9]+$`)
+
+// validateAWSSpotMarketOptions checks the maximum price of spot instances and warns about the
+// settings which make spot capacity unlikely, and when spot instances would be terminated without
+// being drained.
+func validateAWSSpotMarketOptions(c client.Client, providerSpec *machinev1.AWSMachineProviderConfig, raw []byte) ([]string, []error) {
+	if providerSpec.SpotMarketOptions == nil {
+		return nil, nil
+	}
+
+	var warnings []string
+	var errs []error
+	spotPath := field.NewPath("providerSpec", "spotMarketOptions")
+
+	// An empty maximum price is the On-Demand price, like an unset one.
+	if maxPrice := providerSpec.SpotMarketOptions.MaxPrice; maxPrice != nil && *maxPrice != "" {
+		if !awsSpotMaxPricePattern.MatchString(*maxPrice) {
+			errs = append(errs, field.Invalid(spotPath.Child("maxPrice"), *maxPrice, "maxPrice must be a decimal number of US dollars per hour, eg. 0.05"))
+		} else if awsSpotMaxPriceIsZero(*maxPrice) {
+			errs = append(errs, field.Invalid(spotPath.Child("maxPrice"), *maxPrice, "maxPrice must be greater than zero, leave it unset to pay at most the On-Demand price"))
+		}
+	}
+
+	if providerSpec.Placement.Tenancy == machinev1.DedicatedTenancy || providerSpec.Placement.Tenancy == machinev1.HostTenancy {
+		warnings = append(warnings, fmt.Sprintf("providerSpec.placement.tenancy: spot instances with %s tenancy may not be available: spot capacity is mostly offered on shared hardware", providerSpec.Placement.Tenancy))
+	}
+
+	fields := map[string]interface{}{}
+	if err := yaml.Unmarshal(raw, &fields); err == nil {
+		if name, ok := fields[awsPlacementGroupNameField].(string); ok && name != "" {
+			warnings = append(warnings, fmt.Sprintf("providerSpec.%s: spot instances in placement group %s are more likely to fail to launch for lack of capacity, and to be interrupted together", awsPlacementGroupNameField, name))
+		}
+	}
+
+	warnings = append(warnings, validateTerminationHandler(c)...)
+	return warnings, errs
+}
+
+// awsSpotMaxPriceIsZero returns true if the decimal price is zero, eg. 0 or 0.00.
+func awsSpotMaxPriceIsZero(price string) bool {
+	for _, r := range price {
+		if r != '0' && r != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// validateTerminationHandler warns when the termination handler DaemonSet is missing. Without it spot
+// machines are not drained when their instance is interrupted, and their workloads are lost.
+func validateTerminationHandler(c client.Client) []string {
+	daemonSet := &appsv1.DaemonSet{}
+	key := client.ObjectKey{Namespace: defaultWebhookServiceNamespace, Name: terminationHandlerDaemonSetName}
+	if err := c.Get(context.Background(), key, daemonSet); err != nil {
+		if apierrors.IsNotFound(err) {
+			return []string{fmt.Sprintf("providerSpec.spotMarketOptions: the %s DaemonSet does not exist in namespace %s: spot machines will not be drained before their instance is interrupted", terminationHandlerDaemonSetName, defaultWebhookServiceNamespace)}
+		}
+		klog.Warningf("Failed to get the %s DaemonSet: %v", terminationHandlerDaemonSetName, err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestValidateAWSSpotMarketOptions(t *testing.T) {
+	terminationHandler := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: terminationHandlerDaemonSetName, Namespace: defaultWebhookServiceNamespace},
+	}
+
+	testCases := []struct {
+		testCase          string
+		spotMarketOptions *machinev1.SpotMarketOptions
+		tenancy           machinev1.InstanceTenancy
+		placementGroup    string
+		objects           []client.Object
+		expectedError     string
+		expectedWarnings  []string
+	}{
+		{
+			testCase: "without spot market options",
+		},
+		{
+			testCase:          "with spot market options",
+			spotMarketOptions: &machinev1.SpotMarketOptions{},
+			objects:           []client.Object{terminationHandler},
+		},
+		{
+			testCase:          "with a decimal max price",
+			spotMarketOptions: &machinev1.SpotMarketOptions{MaxPrice: pointer.StringPtr("0.05")},
+			objects:           []client.Object{terminationHandler},
+		},
+		{
+			testCase:          "with an empty max price",
+			spotMarketOptions: &machinev1.SpotMarketOptions{MaxPrice: pointer.StringPtr("")},
+			objects:           []client.Object{terminationHandler},
+		},
+		{
+			testCase:          "with a max price which is not a decimal",
+			spotMarketOptions: &machinev1.SpotMarketOptions{MaxPrice: pointer.StringPtr("$0.05")},
+			objects:           []client.Object{terminationHandler},
+			expectedError:     "providerSpec.spotMarketOptions.maxPrice: Invalid value: \"$0.05\": maxPrice must be a decimal number of US dollars per hour, eg. 0.05",
+		},
+		{
+			testCase:          "with a max price in scientific notation",
+			spotMarketOptions: &machinev1.SpotMarketOptions{MaxPrice: pointer.StringPtr("5e-2")},
+			objects:           []client.Object{terminationHandler},
+			expectedError:     "providerSpec.spotMarketOptions.maxPrice: Invalid value: \"5e-2\": maxPrice must be a decimal number of US dollars per hour, eg. 0.05",
+		},
+		{
+			testCase:          "with a zero max price",
+			spotMarketOptions: &machinev1.SpotMarketOptions{MaxPrice: pointer.StringPtr("0.00")},
+			objects:           []client.Object{terminationHandler},
+			expectedError:     "providerSpec.spotMarketOptions.maxPrice: Invalid value: \"0.00\": maxPrice must be greater than zero, leave it unset to pay at most the On-Demand price",
+		},
+		{
+			testCase:          "with dedicated tenancy and a placement group",
+			spotMarketOptions: &machinev1.SpotMarketOptions{},
+			tenancy:           machinev1.DedicatedTenancy,
+			placementGroup:    "workers",
+			objects:           []client.Object{terminationHandler},
+			expectedWarnings: []string{
+				"providerSpec.placement.tenancy: spot instances with dedicated tenancy may not be available: spot capacity is mostly offered on shared hardware",
+				"providerSpec.placementGroupName: spot instances in placement group workers are more likely to fail to launch for lack of capacity, and to be interrupted together",
+			},
+		},
+		{
+			testCase:          "without the termination handler",
+			spotMarketOptions: &machinev1.SpotMarketOptions{},
+			expectedWarnings: []string{
+				"providerSpec.spotMarketOptions: the machine-api-termination-handler DaemonSet does not exist in namespace openshift-machine-api: spot machines will not be drained before their instance is interrupted",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(tc.objects...).Build()
+			providerSpec := &machinev1.AWSMachineProviderConfig{
+				SpotMarketOptions: tc.spotMarketOptions,
+				Placement:         machinev1.Placement{Tenancy: tc.tenancy},
+			}
+
+			rawSpec := map[string]interface{}{}
+			rawBytes, err := json.Marshal(providerSpec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(rawBytes, &rawSpec); err != nil {
+				t.Fatal(err)
+			}
+			if tc.placementGroup != "" {
+				rawSpec[awsPlacementGroupNameField] = tc.placementGroup
+			}
+			if rawBytes, err = json.Marshal(rawSpec); err != nil {
+				t.Fatal(err)
+			}
+
+			warnings, errs := validateAWSSpotMarketOptions(c, providerSpec, rawBytes)
+			if err := utilerrors.NewAggregate(errs); err == nil {
+				if tc.expectedError != "" {
+					t.Errorf("expected: %q, got: %v", tc.expectedError, err)
+				}
+			} else if err.Error() != tc.expectedError {
+				t.Errorf("expected: %q, got: %q", tc.expectedError, err.Error())
+			}
+			if !reflect.DeepEqual(warnings, tc.expectedWarnings) {
+				t.Errorf("expected: %q, got: %q", tc.expectedWarnings, warnings)
+			}
+		})
+	}
+}
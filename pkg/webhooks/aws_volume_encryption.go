@@ -0,0 +1,50 @@
+package webhooks
+
+import (
+	"fmt"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// validateAWSVolumeEncryption checks the encryption of the block devices.
+// When the validation policy requires encryption, explicitly unencrypted devices are denied and devices
+// which leave encryption unset are warned about, as they are only encrypted if EBS encryption by default
+// is enabled in the AWS account. Without the policy, only an unencrypted root device is warned about.
+func validateAWSVolumeEncryption(blockDevices []machinev1.BlockDeviceMappingSpec, requireEncryption bool) ([]string, []error) {
+	var warnings []string
+	var errs []error
+
+	blockDevicesPath := field.NewPath("providerSpec", "blockDevices")
+	for i, blockDevice := range blockDevices {
+		encryptedPath := blockDevicesPath.Index(i).Child("ebs", "encrypted")
+		device := awsBlockDeviceDescription(blockDevice)
+
+		var encrypted *bool
+		if blockDevice.EBS != nil {
+			encrypted = blockDevice.EBS.Encrypted
+		}
+
+		switch {
+		case encrypted != nil && *encrypted:
+			continue
+		case encrypted != nil && requireEncryption:
+			errs = append(errs, field.Forbidden(encryptedPath, fmt.Sprintf("%s must be encrypted: the cluster policy requires all EBS volumes to be encrypted", device)))
+		case encrypted != nil && blockDevice.DeviceName == nil:
+			warnings = append(warnings, fmt.Sprintf("%s: %s is not encrypted", encryptedPath, device))
+		case encrypted == nil && requireEncryption:
+			warnings = append(warnings, fmt.Sprintf("%s: encryption of %s is unset: the volume is only encrypted if EBS encryption by default is enabled in the AWS account, which the cluster policy requires, set encrypted to true", encryptedPath, device))
+		}
+	}
+
+	return warnings, errs
+}
+
+// awsBlockDeviceDescription names a block device in validation messages.
+// The root device is the block device without a device name.
+func awsBlockDeviceDescription(blockDevice machinev1.BlockDeviceMappingSpec) string {
+	if blockDevice.DeviceName == nil {
+		return "the root device"
+	}
+	return fmt.Sprintf("device %s", *blockDevice.DeviceName)
+}
@@ -0,0 +1,150 @@
+package webhooks
+
+import (
+	"reflect"
+	"testing"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+func TestValidateAWSVolumeEncryption(t *testing.T) {
+	rootDevice := func(encrypted *bool) machinev1.BlockDeviceMappingSpec {
+		return machinev1.BlockDeviceMappingSpec{EBS: &machinev1.EBSBlockDeviceSpec{Encrypted: encrypted}}
+	}
+	dataDevice := func(encrypted *bool) machinev1.BlockDeviceMappingSpec {
+		return machinev1.BlockDeviceMappingSpec{DeviceName: pointer.StringPtr("/dev/sdb"), EBS: &machinev1.EBSBlockDeviceSpec{Encrypted: encrypted}}
+	}
+
+	testCases := []struct {
+		testCase          string
+		blockDevices      []machinev1.BlockDeviceMappingSpec
+		requireEncryption bool
+		expectedWarnings  []string
+		expectedErrors    []string
+	}{
+		{
+			testCase:     "with encrypted devices",
+			blockDevices: []machinev1.BlockDeviceMappingSpec{rootDevice(pointer.BoolPtr(true)), dataDevice(pointer.BoolPtr(true))},
+		},
+		{
+			testCase:          "with encrypted devices and the policy requiring encryption",
+			blockDevices:      []machinev1.BlockDeviceMappingSpec{rootDevice(pointer.BoolPtr(true)), dataDevice(pointer.BoolPtr(true))},
+			requireEncryption: true,
+		},
+		{
+			testCase:     "with an unencrypted root device",
+			blockDevices: []machinev1.BlockDeviceMappingSpec{rootDevice(pointer.BoolPtr(false))},
+			expectedWarnings: []string{
+				"providerSpec.blockDevices[0].ebs.encrypted: the root device is not encrypted",
+			},
+		},
+		{
+			testCase:          "with an unencrypted root device and the policy requiring encryption",
+			blockDevices:      []machinev1.BlockDeviceMappingSpec{rootDevice(pointer.BoolPtr(false))},
+			requireEncryption: true,
+			expectedErrors: []string{
+				"providerSpec.blockDevices[0].ebs.encrypted: Forbidden: the root device must be encrypted: the cluster policy requires all EBS volumes to be encrypted",
+			},
+		},
+		{
+			testCase:     "with an unencrypted data device",
+			blockDevices: []machinev1.BlockDeviceMappingSpec{rootDevice(pointer.BoolPtr(true)), dataDevice(pointer.BoolPtr(false))},
+		},
+		{
+			testCase:          "with an unencrypted data device and the policy requiring encryption",
+			blockDevices:      []machinev1.BlockDeviceMappingSpec{rootDevice(pointer.BoolPtr(true)), dataDevice(pointer.BoolPtr(false))},
+			requireEncryption: true,
+			expectedErrors: []string{
+				"providerSpec.blockDevices[1].ebs.encrypted: Forbidden: device /dev/sdb must be encrypted: the cluster policy requires all EBS volumes to be encrypted",
+			},
+		},
+		{
+			testCase:     "with unset encryption",
+			blockDevices: []machinev1.BlockDeviceMappingSpec{rootDevice(nil), dataDevice(nil)},
+		},
+		{
+			testCase:          "with unset encryption and the policy requiring encryption",
+			blockDevices:      []machinev1.BlockDeviceMappingSpec{rootDevice(nil), {DeviceName: pointer.StringPtr("/dev/sdb")}},
+			requireEncryption: true,
+			expectedWarnings: []string{
+				"providerSpec.blockDevices[0].ebs.encrypted: encryption of the root device is unset: the volume is only encrypted if EBS encryption by default is enabled in the AWS account, which the cluster policy requires, set encrypted to true",
+				"providerSpec.blockDevices[1].ebs.encrypted: encryption of device /dev/sdb is unset: the volume is only encrypted if EBS encryption by default is enabled in the AWS account, which the cluster policy requires, set encrypted to true",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			warnings, errs := validateAWSVolumeEncryption(tc.blockDevices, tc.requireEncryption)
+			if !reflect.DeepEqual(warnings, tc.expectedWarnings) {
+				t.Errorf("expected warnings: %q, got: %q", tc.expectedWarnings, warnings)
+			}
+
+			var errMsgs []string
+			for _, err := range errs {
+				errMsgs = append(errMsgs, err.Error())
+			}
+			if !reflect.DeepEqual(errMsgs, tc.expectedErrors) {
+				t.Errorf("expected errors: %q, got: %q", tc.expectedErrors, errMsgs)
+			}
+		})
+	}
+}
+
+func TestRequireAWSVolumeEncryptionFromPolicy(t *testing.T) {
+	policy := func(value string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: ValidationPolicyConfigMapName, Namespace: defaultWebhookServiceNamespace},
+			Data:       map[string]string{awsRequireVolumeEncryptionKey: value},
+		}
+	}
+
+	testCases := []struct {
+		testCase string
+		policy   *corev1.ConfigMap
+		expected bool
+		invalid  bool
+	}{
+		{
+			testCase: "without a policy",
+			expected: false,
+		},
+		{
+			testCase: "without the key",
+			policy:   &corev1.ConfigMap{Data: map[string]string{validationPolicyModeKey: string(ValidationModeAudit)}},
+			expected: false,
+		},
+		{
+			testCase: "with encryption required",
+			policy:   policy("true"),
+			expected: true,
+		},
+		{
+			testCase: "with encryption not required",
+			policy:   policy("false"),
+			expected: false,
+		},
+		{
+			testCase: "with an invalid value",
+			policy:   policy("always"),
+			expected: false,
+			invalid:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			if got := requireAWSVolumeEncryptionFromPolicy(tc.policy); got != tc.expected {
+				t.Errorf("expected %v, got: %v", tc.expected, got)
+			}
+			if tc.policy != nil {
+				if err := validateValidationPolicy(tc.policy); (err != nil) != tc.invalid {
+					t.Errorf("expected the policy to be invalid: %v, got error: %v", tc.invalid, err)
+				}
+			}
+		})
+	}
+}
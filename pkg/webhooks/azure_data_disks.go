@@ -0,0 +1,81 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	azureDataDisksField = "dataDisks"
+
+	// azureDataDiskDeletionPolicyDelete deletes the data disk with the machine.
+	azureDataDiskDeletionPolicyDelete = "Delete"
+	// azureDataDiskDeletionPolicyDetach detaches the data disk from the machine and keeps it.
+	azureDataDiskDeletionPolicyDetach = "Detach"
+)
+
+// azureDataDisks returns the data disks of the raw Azure providerSpec. AzureMachineProviderSpec does not model
+// data disks, so they are read from, and written to, the raw providerSpec for the Azure machine controller.
+func azureDataDisks(raw []byte) ([]interface{}, error) {
+	fields := map[string]interface{}{}
+	if err := yaml.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	dataDisks, _ := fields[azureDataDisksField].([]interface{})
+	return dataDisks, nil
+}
+
+// defaultAzureDataDisks sets the data disks of the raw providerSpec, defaulted from the data disks of the
+// original raw providerSpec. The deletion policy of a disk defaults to Delete, so that the disk does not
+// outlive the machine unless asked to.
+func defaultAzureDataDisks(raw, original []byte) ([]byte, error) {
+	dataDisks, err := azureDataDisks(original)
+	if err != nil || len(dataDisks) == 0 {
+		return raw, err
+	}
+
+	for _, disk := range dataDisks {
+		diskFields, ok := disk.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if policy, _ := diskFields["deletionPolicy"].(string); policy == "" {
+			diskFields["deletionPolicy"] = azureDataDiskDeletionPolicyDelete
+		}
+	}
+
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	fields[azureDataDisksField] = dataDisks
+	return json.Marshal(fields)
+}
+
+// validateAzureDataDisks validates the deletion policy of the data disks of the raw providerSpec, and warns
+// about the disks which are detached when the machine is deleted: they are billed until they are deleted.
+func validateAzureDataDisks(raw []byte) ([]string, []error) {
+	dataDisks, err := azureDataDisks(raw)
+	if err != nil {
+		// The providerSpec is validated by the platform rules.
+		return nil, nil
+	}
+
+	var warnings []string
+	var errs []error
+	for i, disk := range dataDisks {
+		diskFields, _ := disk.(map[string]interface{})
+		policyPath := field.NewPath("providerSpec", azureDataDisksField).Index(i).Child("deletionPolicy")
+		switch policy := diskFields["deletionPolicy"]; policy {
+		case nil, "", azureDataDiskDeletionPolicyDelete:
+		case azureDataDiskDeletionPolicyDetach:
+			warnings = append(warnings, fmt.Sprintf("%s: the disk is detached and not deleted when the machine is deleted: it is billed until it is deleted manually", policyPath))
+		default:
+			errs = append(errs, field.NotSupported(policyPath, policy, []string{azureDataDiskDeletionPolicyDelete, azureDataDiskDeletionPolicyDetach}))
+		}
+	}
+	return warnings, errs
+}
@@ -0,0 +1,111 @@
+package webhooks
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	kruntime "k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+func TestDefaultAzureDataDisks(t *testing.T) {
+	testCases := []struct {
+		testCase          string
+		providerSpec      string
+		expectedDataDisks []interface{}
+	}{
+		{
+			testCase:     "without data disks",
+			providerSpec: `{"vmSize":"Standard_D4s_V3"}`,
+		},
+		{
+			testCase:     "with a data disk without deletion policy",
+			providerSpec: `{"dataDisks":[{"nameSuffix":"disk","diskSizeGB":4,"lun":0}]}`,
+			expectedDataDisks: []interface{}{
+				map[string]interface{}{"nameSuffix": "disk", "diskSizeGB": float64(4), "lun": float64(0), "deletionPolicy": "Delete"},
+			},
+		},
+		{
+			testCase:     "with a data disk using the Detach deletion policy",
+			providerSpec: `{"dataDisks":[{"nameSuffix":"disk","diskSizeGB":4,"lun":0,"deletionPolicy":"Detach"}]}`,
+			expectedDataDisks: []interface{}{
+				map[string]interface{}{"nameSuffix": "disk", "diskSizeGB": float64(4), "lun": float64(0), "deletionPolicy": "Detach"},
+			},
+		},
+	}
+
+	platformStatus := &osconfigv1.PlatformStatus{Type: osconfigv1.AzurePlatformType}
+	h := createMachineDefaulter(platformStatus, "clusterID")
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			g := NewWithT(t)
+
+			m := &machinev1.Machine{}
+			m.Spec.ProviderSpec.Value = &kruntime.RawExtension{Raw: []byte(tc.providerSpec)}
+
+			ok, _, errs := h.webhookOperations(m, h.admissionConfig)
+			g.Expect(errs).To(BeNil())
+			g.Expect(ok).To(BeTrue())
+
+			// The defaulted providerSpec keeps the data disks next to the modeled fields.
+			providerSpec := new(machinev1.AzureMachineProviderSpec)
+			g.Expect(unmarshalInto(m, providerSpec)).To(Succeed())
+			g.Expect(providerSpec.VMSize).ToNot(BeEmpty())
+
+			dataDisks, err := azureDataDisks(m.Spec.ProviderSpec.Value.Raw)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(dataDisks).To(Equal(tc.expectedDataDisks))
+		})
+	}
+}
+
+func TestValidateAzureDataDisks(t *testing.T) {
+	testCases := []struct {
+		testCase         string
+		providerSpec     string
+		expectedWarnings []string
+		expectedError    string
+	}{
+		{
+			testCase:     "without data disks",
+			providerSpec: `{"vmSize":"Standard_D4s_V3"}`,
+		},
+		{
+			testCase:     "with a data disk using the Delete deletion policy",
+			providerSpec: `{"dataDisks":[{"nameSuffix":"disk","diskSizeGB":4,"lun":0,"deletionPolicy":"Delete"}]}`,
+		},
+		{
+			testCase:     "with a data disk without deletion policy",
+			providerSpec: `{"dataDisks":[{"nameSuffix":"disk","diskSizeGB":4,"lun":0}]}`,
+		},
+		{
+			testCase:     "with a data disk using the Detach deletion policy",
+			providerSpec: `{"dataDisks":[{"nameSuffix":"disk","diskSizeGB":4,"lun":0,"deletionPolicy":"Delete"},{"nameSuffix":"data","diskSizeGB":4,"lun":1,"deletionPolicy":"Detach"}]}`,
+			expectedWarnings: []string{
+				"providerSpec.dataDisks[1].deletionPolicy: the disk is detached and not deleted when the machine is deleted: it is billed until it is deleted manually",
+			},
+		},
+		{
+			testCase:      "with an unknown deletion policy",
+			providerSpec:  `{"dataDisks":[{"nameSuffix":"disk","diskSizeGB":4,"lun":0,"deletionPolicy":"Keep"}]}`,
+			expectedError: `providerSpec.dataDisks[0].deletionPolicy: Unsupported value: "Keep": supported values: "Delete", "Detach"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			g := NewWithT(t)
+
+			warnings, errs := validateAzureDataDisks([]byte(tc.providerSpec))
+			g.Expect(warnings).To(Equal(tc.expectedWarnings))
+			if tc.expectedError != "" {
+				g.Expect(utilerrors.NewAggregate(errs)).To(MatchError(tc.expectedError))
+				return
+			}
+			g.Expect(errs).To(BeEmpty())
+		})
+	}
+}
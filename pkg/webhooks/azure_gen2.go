@@ -0,0 +1,81 @@
+package webhooks
+
+import (
+	"fmt"
+	"strings"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// azureGen2OnlyVMSizes are the VM sizes which only support Generation 2 images, in lower case.
+// https://docs.microsoft.com/en-us/azure/virtual-machines/generation-2#generation-2-vm-sizes
+var azureGen2OnlyVMSizes = sets.NewString(
+	// Mv2-series
+	"standard_m208ms_v2",
+	"standard_m208s_v2",
+	"standard_m416ms_v2",
+	"standard_m416s_v2",
+	// Msv2 and Mdsv2 medium memory series
+	"standard_m32ms_v2",
+	"standard_m64s_v2",
+	"standard_m64ms_v2",
+	"standard_m128s_v2",
+	"standard_m128ms_v2",
+	"standard_m192is_v2",
+	"standard_m192ims_v2",
+	"standard_m32dms_v2",
+	"standard_m64ds_v2",
+	"standard_m64dms_v2",
+	"standard_m128ds_v2",
+	"standard_m128dms_v2",
+	"standard_m192ids_v2",
+	"standard_m192idms_v2",
+	// DCsv2-series
+	"standard_dc1s_v2",
+	"standard_dc2s_v2",
+	"standard_dc4s_v2",
+	"standard_dc8_v2",
+	// DCsv3 and DCdsv3-series
+	"standard_dc1s_v3",
+	"standard_dc2s_v3",
+	"standard_dc4s_v3",
+	"standard_dc8s_v3",
+	"standard_dc16s_v3",
+	"standard_dc24s_v3",
+	"standard_dc32s_v3",
+	"standard_dc48s_v3",
+	"standard_dc1ds_v3",
+	"standard_dc2ds_v3",
+	"standard_dc4ds_v3",
+	"standard_dc8ds_v3",
+	"standard_dc16ds_v3",
+	"standard_dc24ds_v3",
+	"standard_dc32ds_v3",
+	"standard_dc48ds_v3",
+	// NDasrA100_v4 and NDm_A100_v4-series
+	"standard_nd96asr_v4",
+	"standard_nd96amsr_a100_v4",
+)
+
+// azureGen2SKUMarkers are the markers of Generation 2 marketplace image SKUs,
+// e.g. 20_04-lts-gen2 or 2019-datacenter-gensecond.
+var azureGen2SKUMarkers = []string{"gen2", "gensecond", "-g2"}
+
+// validateAzureGen2Image warns when a Generation 2 only VM size is combined with a marketplace image SKU
+// which does not look like a Generation 2 image. Such VMs fail to deploy.
+// Images referenced by resourceID are skipped, as their generation cannot be told from the reference.
+func validateAzureGen2Image(vmSize string, image machinev1.Image) []string {
+	if image.ResourceID != "" || image.SKU == "" || !azureGen2OnlyVMSizes.Has(strings.ToLower(vmSize)) {
+		return nil
+	}
+
+	sku := strings.ToLower(image.SKU)
+	for _, marker := range azureGen2SKUMarkers {
+		if strings.Contains(sku, marker) {
+			return nil
+		}
+	}
+
+	return []string{fmt.Sprintf("providerSpec.image.sku: VM size %q only supports Generation 2 images, but image SKU %q does not look like a Generation 2 image: the VM will fail to deploy unless a Generation 2 SKU, typically suffixed with -gen2, is used", vmSize, image.SKU)}
+}
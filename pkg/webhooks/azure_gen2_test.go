@@ -0,0 +1,67 @@
+package webhooks
+
+import (
+	"testing"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+)
+
+func TestValidateAzureGen2Image(t *testing.T) {
+	marketplaceImage := func(sku string) machinev1.Image {
+		return machinev1.Image{Publisher: "publisher", Offer: "offer", SKU: sku, Version: "latest"}
+	}
+
+	testCases := []struct {
+		testCase        string
+		vmSize          string
+		image           machinev1.Image
+		expectedWarning bool
+	}{
+		{
+			testCase: "with a VM size supporting Gen1 images",
+			vmSize:   "Standard_D4s_v3",
+			image:    marketplaceImage("8"),
+		},
+		{
+			testCase:        "with a Gen2 only VM size and a Gen1 SKU",
+			vmSize:          "Standard_DC4s_v3",
+			image:           marketplaceImage("2019-datacenter"),
+			expectedWarning: true,
+		},
+		{
+			testCase:        "with a Gen2 only VM size in a different case",
+			vmSize:          "standard_m416s_v2",
+			image:           marketplaceImage("8"),
+			expectedWarning: true,
+		},
+		{
+			testCase: "with a -gen2 SKU",
+			vmSize:   "Standard_DC4s_v3",
+			image:    marketplaceImage("20_04-lts-gen2"),
+		},
+		{
+			testCase: "with a gensecond SKU",
+			vmSize:   "Standard_DC4s_v3",
+			image:    marketplaceImage("2019-datacenter-gensecond"),
+		},
+		{
+			testCase: "with a -g2 SKU",
+			vmSize:   "Standard_DC4s_v3",
+			image:    marketplaceImage("2022-datacenter-g2"),
+		},
+		{
+			testCase: "with a gallery image",
+			vmSize:   "Standard_DC4s_v3",
+			image:    machinev1.Image{ResourceID: "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/galleries/gallery/images/image/versions/1.0.0"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			warnings := validateAzureGen2Image(tc.vmSize, tc.image)
+			if tc.expectedWarning != (len(warnings) == 1) {
+				t.Errorf("expected warning: %v, got: %q", tc.expectedWarning, warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	// AzureVMSKUsConfigMapName is the ConfigMap in the webhook namespace caching the VM sizes available to
+	// the subscription of the cluster. Its keys are Azure locations, each holding a JSON object which maps
+	// the VM sizes available in the location to the availability zones they are available in.
+	// It is maintained by the Azure VM SKU controller while the validation policy enables
+	// the validation of Azure VM sizes.
+	AzureVMSKUsConfigMapName = "machine-api-azure-vm-skus"
+	// AzureVMSKUsRefreshedAnnotation is the time the SKUs cached in the AzureVMSKUsConfigMapName ConfigMap
+	// were listed, in RFC 3339 format.
+	AzureVMSKUsRefreshedAnnotation = "machine.openshift.io/azure-vm-skus-refreshed"
+)
+
+// AzureVMSKUs maps the VM sizes available in an Azure location to the availability zones they are available in.
+// Sizes which are available in the location but in none of its zones have no zones.
+type AzureVMSKUs map[string][]string
+
+// ParseAzureVMSKUs returns the VM SKUs cached in the AzureVMSKUsConfigMapName ConfigMap, keyed by location.
+func ParseAzureVMSKUs(configMap *corev1.ConfigMap) (map[string]AzureVMSKUs, error) {
+	skus := make(map[string]AzureVMSKUs, len(configMap.Data))
+	for location, value := range configMap.Data {
+		locationSKUs := AzureVMSKUs{}
+		if err := json.Unmarshal([]byte(value), &locationSKUs); err != nil {
+			return nil, fmt.Errorf("%s: %w", location, err)
+		}
+		skus[location] = locationSKUs
+	}
+	return skus, nil
+}
+
+// validateAzureVMSizeAvailability checks that the VM size is available in the location and zone of the
+// providerSpec, according to the cached SKUs. Locations missing from the cache are not checked.
+func validateAzureVMSizeAvailability(providerSpec *machinev1.AzureMachineProviderSpec, skus map[string]AzureVMSKUs) ([]string, []error) {
+	if providerSpec.VMSize == "" || providerSpec.Location == "" {
+		return nil, nil
+	}
+
+	locationSKUs, ok := skus[providerSpec.Location]
+	if !ok {
+		return []string{fmt.Sprintf("providerSpec.vmSize: the VM sizes available in %s are not cached in ConfigMap %s: the availability of %s was not checked", providerSpec.Location, AzureVMSKUsConfigMapName, providerSpec.VMSize)}, nil
+	}
+
+	zones, ok := locationSKUs[providerSpec.VMSize]
+	if !ok {
+		return nil, []error{field.Invalid(field.NewPath("providerSpec", "vmSize"), providerSpec.VMSize, fmt.Sprintf("vmSize is not available in location %s", providerSpec.Location))}
+	}
+
+	if providerSpec.Zone != nil && *providerSpec.Zone != "" && !sets.NewString(zones...).Has(*providerSpec.Zone) {
+		return nil, []error{field.Invalid(field.NewPath("providerSpec", "zone"), *providerSpec.Zone, fmt.Sprintf("vmSize %s is not available in this zone of location %s, available zones: %v", providerSpec.VMSize, providerSpec.Location, zones))}
+	}
+	return nil, nil
+}
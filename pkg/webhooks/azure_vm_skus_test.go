@@ -0,0 +1,185 @@
+package webhooks
+
+import (
+	"reflect"
+	"testing"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestParseAzureVMSKUs(t *testing.T) {
+	testCases := []struct {
+		testCase      string
+		data          map[string]string
+		expectedSKUs  map[string]AzureVMSKUs
+		expectedError string
+	}{
+		{
+			testCase: "with SKUs for several locations",
+			data: map[string]string{
+				"eastus":  `{"Standard_D4s_v3": ["1", "2", "3"], "Standard_NC6": []}`,
+				"westus2": `{"Standard_D4s_v3": ["1"]}`,
+			},
+			expectedSKUs: map[string]AzureVMSKUs{
+				"eastus":  {"Standard_D4s_v3": {"1", "2", "3"}, "Standard_NC6": {}},
+				"westus2": {"Standard_D4s_v3": {"1"}},
+			},
+		},
+		{
+			testCase:      "with a location which is not a JSON object",
+			data:          map[string]string{"eastus": `["Standard_D4s_v3"]`},
+			expectedError: "eastus: json: cannot unmarshal array into Go value of type webhooks.AzureVMSKUs",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			skus, err := ParseAzureVMSKUs(&corev1.ConfigMap{Data: tc.data})
+			if err == nil {
+				if tc.expectedError != "" {
+					t.Errorf("expected: %q, got: %v", tc.expectedError, err)
+				}
+			} else if err.Error() != tc.expectedError {
+				t.Errorf("expected: %q, got: %q", tc.expectedError, err.Error())
+			}
+			if err == nil && !reflect.DeepEqual(skus, tc.expectedSKUs) {
+				t.Errorf("expected: %v, got: %v", tc.expectedSKUs, skus)
+			}
+		})
+	}
+}
+
+func TestValidateAzureVMSizeAvailability(t *testing.T) {
+	skus := map[string]AzureVMSKUs{
+		"eastus": {
+			"Standard_D4s_v3": {"1", "2", "3"},
+			"Standard_NC6":    {},
+		},
+	}
+
+	testCases := []struct {
+		testCase         string
+		vmSize           string
+		location         string
+		zone             *string
+		expectedError    string
+		expectedWarnings []string
+	}{
+		{
+			testCase: "with a VM size available in the location and zone",
+			vmSize:   "Standard_D4s_v3",
+			location: "eastus",
+			zone:     pointer.StringPtr("2"),
+		},
+		{
+			testCase: "with a VM size available in the location without a zone",
+			vmSize:   "Standard_NC6",
+			location: "eastus",
+		},
+		{
+			testCase:      "with a VM size not available in the location",
+			vmSize:        "Standard_D4s_v33",
+			location:      "eastus",
+			expectedError: "providerSpec.vmSize: Invalid value: \"Standard_D4s_v33\": vmSize is not available in location eastus",
+		},
+		{
+			testCase:      "with a VM size not available in the zone",
+			vmSize:        "Standard_NC6",
+			location:      "eastus",
+			zone:          pointer.StringPtr("1"),
+			expectedError: "providerSpec.zone: Invalid value: \"1\": vmSize Standard_NC6 is not available in this zone of location eastus, available zones: []",
+		},
+		{
+			testCase: "with a location missing from the cache",
+			vmSize:   "Standard_D4s_v3",
+			location: "westus2",
+			expectedWarnings: []string{
+				"providerSpec.vmSize: the VM sizes available in westus2 are not cached in ConfigMap machine-api-azure-vm-skus: the availability of Standard_D4s_v3 was not checked",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			providerSpec := &machinev1.AzureMachineProviderSpec{
+				VMSize:   tc.vmSize,
+				Location: tc.location,
+				Zone:     tc.zone,
+			}
+
+			warnings, errs := validateAzureVMSizeAvailability(providerSpec, skus)
+			if err := utilerrors.NewAggregate(errs); err == nil {
+				if tc.expectedError != "" {
+					t.Errorf("expected: %q, got: %v", tc.expectedError, err)
+				}
+			} else if err.Error() != tc.expectedError {
+				t.Errorf("expected: %q, got: %q", tc.expectedError, err.Error())
+			}
+			if !reflect.DeepEqual(warnings, tc.expectedWarnings) {
+				t.Errorf("expected: %q, got: %q", tc.expectedWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestCurrentConfigAzureVMSKUs(t *testing.T) {
+	infra := plainInfra.DeepCopy()
+	infra.Name = clusterConfigName
+	infra.Status.PlatformStatus.Type = osconfigv1.AzurePlatformType
+	skus := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: AzureVMSKUsConfigMapName, Namespace: defaultWebhookServiceNamespace},
+		Data:       map[string]string{"eastus": `{"Standard_D4s_v3": ["1", "2", "3"]}`},
+	}
+	policy := func(value string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: ValidationPolicyConfigMapName, Namespace: defaultWebhookServiceNamespace},
+			Data:       map[string]string{azureValidateVMSizeAvailabilityKey: value},
+		}
+	}
+
+	testCases := []struct {
+		testCase     string
+		objects      []client.Object
+		expectedSKUs map[string]AzureVMSKUs
+	}{
+		{
+			testCase: "without a policy",
+			objects:  []client.Object{infra, skus},
+		},
+		{
+			testCase: "with the validation disabled",
+			objects:  []client.Object{infra, skus, policy("false")},
+		},
+		{
+			testCase: "with the validation enabled but no cached SKUs",
+			objects:  []client.Object{infra, policy("true")},
+		},
+		{
+			testCase: "with the validation enabled",
+			objects:  []client.Object{infra, skus, policy("true")},
+			expectedSKUs: map[string]AzureVMSKUs{
+				"eastus": {"Standard_D4s_v3": {"1", "2", "3"}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(tc.objects...).Build()
+			h := createMachineValidator(infra, reader, &osconfigv1.DNS{})
+			h.inputs = newClusterInputsCache(reader)
+
+			if got := h.currentConfig().azureVMSKUs; !reflect.DeepEqual(got, tc.expectedSKUs) {
+				t.Errorf("expected: %v, got: %v", tc.expectedSKUs, got)
+			}
+		})
+	}
+}
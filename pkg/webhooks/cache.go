@@ -0,0 +1,261 @@
+package webhooks
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/machine-api-operator/pkg/metrics"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	kruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	cacheResourceInfrastructure = "infrastructure"
+	cacheResourceDNS            = "dns"
+	cacheResourceSecrets        = "secrets"
+	cacheResourcePolicy         = "validation-policy"
+	cacheResourceFailureDomains = "vsphere-failure-domains"
+	cacheResourceDefaults       = "defaults-overrides"
+	cacheResourceAzureVMSKUs    = "azure-vm-skus"
+
+	// defaultClusterInputsRefreshInterval is how long a cached cluster input is served before it is fetched again.
+	defaultClusterInputsRefreshInterval = 30 * time.Second
+
+	clusterConfigName = "cluster"
+)
+
+// clusterInputsCache serves the cluster scoped inputs backing admissionConfig.
+// Each input is fetched from the API server at most once per refreshInterval.
+// When a refresh fails the last known copy keeps being served, so the failure
+// and the growing age of the copy are reported via metrics.
+type clusterInputsCache struct {
+	reader          client.Reader
+	refreshInterval time.Duration
+
+	lock        sync.Mutex
+	infra       *osconfigv1.Infrastructure
+	dns         *osconfigv1.DNS
+	policy      *corev1.ConfigMap
+	defaults    *corev1.ConfigMap
+	azureVMSKUs *corev1.ConfigMap
+	lastAttempt map[string]time.Time
+
+	// vSphereFailureDomains are read separately from infra, see vSphereFailureDomain.
+	vSphereFailureDomains []vSphereFailureDomain
+}
+
+// secretVersions are the resource versions of the secrets read by the validators.
+var secretVersions = &resourceVersions{versions: map[client.ObjectKey]string{}}
+
+// resourceVersions records the resource versions of the objects of a cluster input read from the API server,
+// so that the age of the input is only reset when one of them is created or changed, not on every read.
+type resourceVersions struct {
+	lock     sync.Mutex
+	versions map[client.ObjectKey]string
+}
+
+// observe records the resource version of obj and reports the refresh of resource if it is new.
+func (r *resourceVersions) observe(resource string, obj client.Object) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	key := client.ObjectKeyFromObject(obj)
+	if version, ok := r.versions[key]; ok && version == obj.GetResourceVersion() {
+		return
+	}
+	r.versions[key] = obj.GetResourceVersion()
+	metrics.ObserveWebhookCacheRefresh(resource)
+}
+
+func newClusterInputsCache(reader client.Reader) *clusterInputsCache {
+	return &clusterInputsCache{
+		reader:          reader,
+		refreshInterval: defaultClusterInputsRefreshInterval,
+		lastAttempt:     map[string]time.Time{},
+	}
+}
+
+// newClusterInputsReader returns an uncached client able to read the config.openshift.io resources
+// and the validation policy ConfigMap.
+func newClusterInputsReader() (client.Reader, error) {
+	cfg, err := ctrl.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := kruntime.NewScheme()
+	if err := osconfigv1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+
+	return client.New(cfg, client.Options{Scheme: scheme})
+}
+
+func (c *clusterInputsCache) getInfrastructure(ctx context.Context) (*osconfigv1.Infrastructure, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.infra != nil && !c.needsRefresh(cacheResourceInfrastructure) {
+		return c.infra.DeepCopy(), nil
+	}
+
+	infra := &osconfigv1.Infrastructure{}
+	if err := c.refresh(ctx, cacheResourceInfrastructure, client.ObjectKey{Name: clusterConfigName}, infra); err != nil {
+		if c.infra == nil {
+			return nil, err
+		}
+		return c.infra.DeepCopy(), nil
+	}
+
+	c.infra = infra
+	return c.infra.DeepCopy(), nil
+}
+
+func (c *clusterInputsCache) getDNS(ctx context.Context) (*osconfigv1.DNS, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.dns != nil && !c.needsRefresh(cacheResourceDNS) {
+		return c.dns.DeepCopy(), nil
+	}
+
+	dns := &osconfigv1.DNS{}
+	if err := c.refresh(ctx, cacheResourceDNS, client.ObjectKey{Name: clusterConfigName}, dns); err != nil {
+		if c.dns == nil {
+			return nil, err
+		}
+		return c.dns.DeepCopy(), nil
+	}
+
+	c.dns = dns
+	return c.dns.DeepCopy(), nil
+}
+
+// getValidationPolicy returns the validation policy ConfigMap, or nil if it does not exist.
+func (c *clusterInputsCache) getValidationPolicy(ctx context.Context) (*corev1.ConfigMap, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.needsRefresh(cacheResourcePolicy) {
+		return c.policy.DeepCopy(), nil
+	}
+
+	policy := &corev1.ConfigMap{}
+	key := client.ObjectKey{Namespace: defaultWebhookServiceNamespace, Name: ValidationPolicyConfigMapName}
+	if err := c.refresh(ctx, cacheResourcePolicy, key, policy); err != nil {
+		if apierrors.IsNotFound(err) {
+			c.policy = nil
+			return nil, nil
+		}
+		return c.policy.DeepCopy(), err
+	}
+
+	c.policy = policy
+	return c.policy.DeepCopy(), nil
+}
+
+// getDefaultsOverrides returns the defaults overrides ConfigMap, or nil if it does not exist.
+func (c *clusterInputsCache) getDefaultsOverrides(ctx context.Context) (*corev1.ConfigMap, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.needsRefresh(cacheResourceDefaults) {
+		return c.defaults.DeepCopy(), nil
+	}
+
+	defaults := &corev1.ConfigMap{}
+	key := client.ObjectKey{Namespace: defaultWebhookServiceNamespace, Name: DefaultsOverridesConfigMapName}
+	if err := c.refresh(ctx, cacheResourceDefaults, key, defaults); err != nil {
+		if apierrors.IsNotFound(err) {
+			c.defaults = nil
+			return nil, nil
+		}
+		return c.defaults.DeepCopy(), err
+	}
+
+	c.defaults = defaults
+	return c.defaults.DeepCopy(), nil
+}
+
+// getAzureVMSKUs returns the Azure VM SKUs ConfigMap, or nil if it does not exist.
+func (c *clusterInputsCache) getAzureVMSKUs(ctx context.Context) (*corev1.ConfigMap, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.needsRefresh(cacheResourceAzureVMSKUs) {
+		return c.azureVMSKUs.DeepCopy(), nil
+	}
+
+	skus := &corev1.ConfigMap{}
+	key := client.ObjectKey{Namespace: defaultWebhookServiceNamespace, Name: AzureVMSKUsConfigMapName}
+	if err := c.refresh(ctx, cacheResourceAzureVMSKUs, key, skus); err != nil {
+		if apierrors.IsNotFound(err) {
+			c.azureVMSKUs = nil
+			return nil, nil
+		}
+		return c.azureVMSKUs.DeepCopy(), err
+	}
+
+	c.azureVMSKUs = skus
+	return c.azureVMSKUs.DeepCopy(), nil
+}
+
+// getVSphereFailureDomains returns the vSphere failure domains defined in the Infrastructure spec.
+func (c *clusterInputsCache) getVSphereFailureDomains(ctx context.Context) ([]vSphereFailureDomain, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.needsRefresh(cacheResourceFailureDomains) {
+		return c.vSphereFailureDomains, nil
+	}
+
+	infra := &unstructured.Unstructured{}
+	infra.SetGroupVersionKind(osconfigv1.GroupVersion.WithKind("Infrastructure"))
+	if err := c.refresh(ctx, cacheResourceFailureDomains, client.ObjectKey{Name: clusterConfigName}, infra); err != nil {
+		return c.vSphereFailureDomains, err
+	}
+
+	failureDomains, err := vSphereFailureDomainsFromInfrastructure(infra)
+	if err != nil {
+		return c.vSphereFailureDomains, err
+	}
+
+	c.vSphereFailureDomains = failureDomains
+	return c.vSphereFailureDomains, nil
+}
+
+func (c *clusterInputsCache) needsRefresh(resource string) bool {
+	lastAttempt, ok := c.lastAttempt[resource]
+	return !ok || time.Since(lastAttempt) >= c.refreshInterval
+}
+
+// refresh fetches the named cluster input into obj and records the outcome.
+// Attempts are recorded whether or not they succeed so that a failing API server
+// is not queried on every admission request. An input which does not exist is
+// still a successful refresh.
+func (c *clusterInputsCache) refresh(ctx context.Context, resource string, key client.ObjectKey, obj client.Object) error {
+	c.lastAttempt[resource] = time.Now()
+
+	if err := c.reader.Get(ctx, key, obj); err != nil {
+		if apierrors.IsNotFound(err) {
+			metrics.ObserveWebhookCacheRefresh(resource)
+			return err
+		}
+		klog.Warningf("Failed to refresh cached %s: %v", resource, err)
+		metrics.ObserveWebhookCacheRefreshFailure(resource)
+		return err
+	}
+
+	metrics.ObserveWebhookCacheRefresh(resource)
+	return nil
+}
@@ -0,0 +1,139 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	osconfigv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/machine-api-operator/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// failingReader wraps a reader and fails every request once fail is set.
+type failingReader struct {
+	client.Reader
+	fail bool
+}
+
+func (r *failingReader) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if r.fail {
+		return errors.New("watch dropped")
+	}
+	return r.Reader.Get(ctx, key, obj)
+}
+
+// webhookCacheMetric returns the value of the named webhook cache metric for the given resource.
+func webhookCacheMetric(g *WithT, name, resource string) (float64, bool) {
+	registry := prometheus.NewRegistry()
+	g.Expect(registry.Register(metrics.WebhookCacheCollector)).To(Succeed())
+	g.Expect(registry.Register(metrics.WebhookCacheRefreshFailuresTotal)).To(Succeed())
+
+	families, err := registry.Gather()
+	g.Expect(err).ToNot(HaveOccurred())
+
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "resource" && label.GetValue() == resource {
+					if metric.GetCounter() != nil {
+						return metric.GetCounter().GetValue(), true
+					}
+					return metric.GetGauge().GetValue(), true
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+func TestClusterInputsCache(t *testing.T) {
+	g := NewWithT(t)
+
+	infra := plainInfra.DeepCopy()
+	infra.ObjectMeta = metav1.ObjectMeta{Name: clusterConfigName}
+	infra.Status.InfrastructureName = "clusterID"
+	reader := &failingReader{
+		Reader: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(infra).Build(),
+	}
+
+	cache := newClusterInputsCache(reader)
+	cache.refreshInterval = 0
+
+	got, err := cache.getInfrastructure(context.Background())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(got.Status.InfrastructureName).To(Equal("clusterID"))
+
+	_, err = cache.getDNS(context.Background())
+	g.Expect(err).To(HaveOccurred(), "DNS was never created so the first refresh should fail")
+
+	failuresBefore, _ := webhookCacheMetric(g, "mapi_webhook_cache_refresh_failures_total", cacheResourceInfrastructure)
+	ageBefore, ok := webhookCacheMetric(g, "mapi_webhook_cache_resource_age_seconds", cacheResourceInfrastructure)
+	g.Expect(ok).To(BeTrue())
+
+	reader.fail = true
+	time.Sleep(10 * time.Millisecond)
+
+	got, err = cache.getInfrastructure(context.Background())
+	g.Expect(err).ToNot(HaveOccurred(), "the last known Infrastructure should be served on failure")
+	g.Expect(got.Status.InfrastructureName).To(Equal("clusterID"))
+
+	failuresAfter, _ := webhookCacheMetric(g, "mapi_webhook_cache_refresh_failures_total", cacheResourceInfrastructure)
+	g.Expect(failuresAfter).To(Equal(failuresBefore + 1))
+	ageAfter, ok := webhookCacheMetric(g, "mapi_webhook_cache_resource_age_seconds", cacheResourceInfrastructure)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(ageAfter).To(BeNumerically(">", ageBefore))
+}
+
+func TestClusterInputsCacheRefreshInterval(t *testing.T) {
+	g := NewWithT(t)
+
+	dns := &osconfigv1.DNS{ObjectMeta: metav1.ObjectMeta{Name: clusterConfigName}}
+	reader := &failingReader{
+		Reader: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(dns).Build(),
+	}
+	cache := newClusterInputsCache(reader)
+
+	_, err := cache.getDNS(context.Background())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	failuresBefore, _ := webhookCacheMetric(g, "mapi_webhook_cache_refresh_failures_total", cacheResourceDNS)
+	reader.fail = true
+
+	_, err = cache.getDNS(context.Background())
+	g.Expect(err).ToNot(HaveOccurred())
+	failuresAfter, _ := webhookCacheMetric(g, "mapi_webhook_cache_refresh_failures_total", cacheResourceDNS)
+	g.Expect(failuresAfter).To(Equal(failuresBefore), "no refresh should happen within the refresh interval")
+}
+
+func TestResourceVersionsObserve(t *testing.T) {
+	g := NewWithT(t)
+
+	const resource = "test-secrets"
+	versions := &resourceVersions{versions: map[client.ObjectKey]string{}}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "secret", Namespace: "namespace", ResourceVersion: "1"}}
+
+	versions.observe(resource, secret)
+	created, ok := webhookCacheMetric(g, "mapi_webhook_cache_resource_age_seconds", resource)
+	g.Expect(ok).To(BeTrue())
+
+	time.Sleep(10 * time.Millisecond)
+	versions.observe(resource, secret)
+	unchanged, _ := webhookCacheMetric(g, "mapi_webhook_cache_resource_age_seconds", resource)
+	g.Expect(unchanged).To(BeNumerically(">", created), "reading an unchanged secret should not reset the age")
+
+	secret.ResourceVersion = "2"
+	versions.observe(resource, secret)
+	changed, _ := webhookCacheMetric(g, "mapi_webhook_cache_resource_age_seconds", resource)
+	g.Expect(changed).To(BeNumerically("<", unchanged), "changing the secret should reset the age")
+}
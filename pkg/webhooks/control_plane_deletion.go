@@ -0,0 +1,115 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// allowControlPlaneDeletionAnnotation is set to true on a control plane Machine to allow its deletion
+	// even though it leaves fewer control plane machines than the quorum.
+	allowControlPlaneDeletionAnnotation = "machine.openshift.io/allow-control-plane-deletion"
+
+	// defaultControlPlaneQuorum is the number of control plane machines which must remain after a deletion,
+	// the etcd quorum of a three member control plane.
+	defaultControlPlaneQuorum = 2
+)
+
+// parseControlPlaneQuorum returns the control plane quorum set in the policy ConfigMap,
+// or defaultControlPlaneQuorum when it is not set. A quorum of 0 disables the safeguard.
+func parseControlPlaneQuorum(policy *corev1.ConfigMap) (int, error) {
+	if policy == nil {
+		return defaultControlPlaneQuorum, nil
+	}
+	value, ok := policy.Data[controlPlaneQuorumKey]
+	if !ok {
+		return defaultControlPlaneQuorum, nil
+	}
+
+	quorum, err := strconv.Atoi(value)
+	if err != nil || quorum < 0 {
+		return defaultControlPlaneQuorum, fmt.Errorf("%s: invalid value %q: expected a non-negative integer", controlPlaneQuorumKey, value)
+	}
+	return quorum, nil
+}
+
+// currentControlPlaneQuorum returns the control plane quorum for a single admission request.
+func (a *admissionHandler) currentControlPlaneQuorum() int {
+	if a.inputs == nil {
+		return defaultControlPlaneQuorum
+	}
+
+	policy, err := a.inputs.getValidationPolicy(context.Background())
+	if err != nil {
+		klog.Errorf("Unable to refresh the validation policy, using the last known value: %v", err)
+	}
+	quorum, err := parseControlPlaneQuorum(policy)
+	if err != nil {
+		klog.Errorf("Ignoring the control plane quorum in ConfigMap %s/%s: %v", policy.GetNamespace(), policy.GetName(), err)
+	}
+	return quorum
+}
+
+// isControlPlaneMember returns whether the Machine counts towards the control plane quorum:
+// a control plane Machine which is not being deleted and is linked to its Node.
+func isControlPlaneMember(m *machinev1.Machine) bool {
+	return m.Labels[machineRoleLabel] == machineRoleMaster && m.DeletionTimestamp.IsZero() && m.Status.NodeRef != nil
+}
+
+// validateControlPlaneDeletion denies the deletion of a control plane Machine which would leave fewer
+// control plane machines than the quorum, as losing the etcd quorum makes the cluster unrecoverable
+// without a backup. Machines which are not members of the control plane, eg. because they are deleted
+// already or never joined the cluster, may always be deleted, as may machines with the
+// allowControlPlaneDeletionAnnotation.
+func validateControlPlaneDeletion(c client.Client, m *machinev1.Machine, quorum int) ([]string, []error) {
+	if c == nil || quorum == 0 || !isControlPlaneMember(m) {
+		return nil, nil
+	}
+
+	machines := &machinev1.MachineList{}
+	if err := c.List(context.Background(), machines, client.InNamespace(m.GetNamespace()), client.MatchingLabels{machineRoleLabel: machineRoleMaster}); err != nil {
+		return nil, []error{field.InternalError(field.NewPath("metadata", "name"), fmt.Errorf("unable to count the control plane machines: %w", err))}
+	}
+
+	remaining := 0
+	for i := range machines.Items {
+		if machines.Items[i].GetUID() != m.GetUID() && isControlPlaneMember(&machines.Items[i]) {
+			remaining++
+		}
+	}
+	if remaining >= quorum {
+		return nil, nil
+	}
+
+	message := fmt.Sprintf("deleting control plane machine %s leaves %d control plane machines, fewer than the quorum of %d, which risks losing the etcd quorum", m.GetName(), remaining, quorum)
+	if m.Annotations[allowControlPlaneDeletionAnnotation] == "true" {
+		return []string{fmt.Sprintf("%s: allowed by the %s annotation", message, allowControlPlaneDeletionAnnotation)}, nil
+	}
+	return nil, []error{field.Forbidden(field.NewPath("metadata", "name"), fmt.Sprintf("%s: set the %s annotation to true to allow it", message, allowControlPlaneDeletionAnnotation))}
+}
+
+// handleDelete validates the deletion of the Machine, which is the old object of the request.
+func (h *machineValidatorHandler) handleDelete(req admission.Request) admission.Response {
+	m := &machinev1.Machine{}
+	if err := h.decoder.DecodeRaw(req.OldObject, m); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	klog.V(3).Infof("Validate webhook called for the deletion of Machine: %s", m.GetName())
+
+	warnings, errs := validateControlPlaneDeletion(h.client, m, h.currentControlPlaneQuorum())
+	if len(errs) > 0 {
+		return validationResponse(admissionResourceMachine, h.admissionPlatform(m), h.currentValidationMode(), false, warnings, utilerrors.NewAggregate(safetyDenials(errs...)), "Machine deletion valid")
+	}
+	return validationResponse(admissionResourceMachine, h.admissionPlatform(m), h.currentValidationMode(), true, warnings, nil, "Machine deletion valid")
+}
@@ -0,0 +1,198 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func controlPlaneMachine(name string, member bool) *machinev1.Machine {
+	m := &machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "openshift-machine-api",
+			UID:       types.UID(name + "-uid"),
+			Labels:    map[string]string{machineRoleLabel: machineRoleMaster},
+		},
+		Status: machinev1.MachineStatus{NodeRef: &corev1.ObjectReference{Name: name}},
+	}
+	if !member {
+		now := metav1.Now()
+		m.DeletionTimestamp = &now
+		m.Finalizers = []string{machinev1.MachineFinalizer}
+	}
+	return m
+}
+
+func TestValidateControlPlaneDeletion(t *testing.T) {
+	worker := controlPlaneMachine("worker", true)
+	worker.Labels[machineRoleLabel] = "worker"
+
+	testCases := []struct {
+		testCase         string
+		machine          *machinev1.Machine
+		others           []client.Object
+		quorum           int
+		expectedError    string
+		expectedWarnings []string
+	}{
+		{
+			testCase: "with enough remaining control plane machines",
+			machine:  controlPlaneMachine("master-0", true),
+			others:   []client.Object{controlPlaneMachine("master-1", true), controlPlaneMachine("master-2", true)},
+			quorum:   defaultControlPlaneQuorum,
+		},
+		{
+			testCase:      "with another control plane machine being deleted",
+			machine:       controlPlaneMachine("master-0", true),
+			others:        []client.Object{controlPlaneMachine("master-1", false), controlPlaneMachine("master-2", true), worker},
+			quorum:        defaultControlPlaneQuorum,
+			expectedError: "metadata.name: Forbidden: deleting control plane machine master-0 leaves 1 control plane machines, fewer than the quorum of 2, which risks losing the etcd quorum: set the machine.openshift.io/allow-control-plane-deletion annotation to true to allow it",
+		},
+		{
+			testCase: "with the override annotation",
+			machine: func() *machinev1.Machine {
+				m := controlPlaneMachine("master-0", true)
+				m.Annotations = map[string]string{allowControlPlaneDeletionAnnotation: "true"}
+				return m
+			}(),
+			others: []client.Object{controlPlaneMachine("master-1", true)},
+			quorum: 3,
+			expectedWarnings: []string{
+				"deleting control plane machine master-0 leaves 1 control plane machines, fewer than the quorum of 3, which risks losing the etcd quorum: allowed by the machine.openshift.io/allow-control-plane-deletion annotation",
+			},
+		},
+		{
+			testCase: "with a control plane machine being deleted already",
+			machine:  controlPlaneMachine("master-0", false),
+			quorum:   defaultControlPlaneQuorum,
+		},
+		{
+			testCase: "with a control plane machine without a node",
+			machine: func() *machinev1.Machine {
+				m := controlPlaneMachine("master-3", true)
+				m.Status.NodeRef = nil
+				return m
+			}(),
+			quorum: defaultControlPlaneQuorum,
+		},
+		{
+			testCase: "with a worker machine",
+			machine:  worker,
+			quorum:   defaultControlPlaneQuorum,
+		},
+		{
+			testCase: "with the safeguard disabled",
+			machine:  controlPlaneMachine("master-0", true),
+			quorum:   0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(append(tc.others, tc.machine)...).Build()
+
+			warnings, errs := validateControlPlaneDeletion(c, tc.machine, tc.quorum)
+
+			var errString string
+			if len(errs) > 0 {
+				errString = utilerrors.NewAggregate(errs).Error()
+			}
+			if errString != tc.expectedError {
+				t.Errorf("expected error: %q, got: %q", tc.expectedError, errString)
+			}
+			if !reflect.DeepEqual(warnings, tc.expectedWarnings) {
+				t.Errorf("expected warnings: %q, got: %q", tc.expectedWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestParseControlPlaneQuorum(t *testing.T) {
+	testCases := []struct {
+		value          string
+		expectedQuorum int
+		expectedError  string
+	}{
+		{value: "3", expectedQuorum: 3},
+		{value: "0", expectedQuorum: 0},
+		{value: "-1", expectedQuorum: defaultControlPlaneQuorum, expectedError: `controlPlane.quorum: invalid value "-1": expected a non-negative integer`},
+		{value: "three", expectedQuorum: defaultControlPlaneQuorum, expectedError: `controlPlane.quorum: invalid value "three": expected a non-negative integer`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			policy := &corev1.ConfigMap{Data: map[string]string{controlPlaneQuorumKey: tc.value}}
+			quorum, err := parseControlPlaneQuorum(policy)
+			if quorum != tc.expectedQuorum {
+				t.Errorf("expected quorum %d, got: %d", tc.expectedQuorum, quorum)
+			}
+
+			var errString string
+			if err != nil {
+				errString = err.Error()
+			}
+			if errString != tc.expectedError {
+				t.Errorf("expected error: %q, got: %q", tc.expectedError, errString)
+			}
+			if err := validateValidationPolicy(policy); (err != nil) != (tc.expectedError != "") {
+				t.Errorf("expected the policy to be invalid: %v, got: %v", tc.expectedError != "", err)
+			}
+		})
+	}
+
+	if quorum, err := parseControlPlaneQuorum(nil); quorum != defaultControlPlaneQuorum || err != nil {
+		t.Errorf("expected the default quorum without a policy, got: %d, %v", quorum, err)
+	}
+}
+
+func TestValidateMachineDeletion(t *testing.T) {
+	g := NewWithT(t)
+
+	master0, master1 := controlPlaneMachine("master-0", true), controlPlaneMachine("master-1", false)
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(master0, master1, controlPlaneMachine("master-2", true)).Build()
+
+	h := createMachineValidator(plainInfra, c, plainDNS)
+	decoder, err := admission.NewDecoder(scheme.Scheme)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(h.InjectDecoder(decoder)).To(Succeed())
+
+	request := func(m *machinev1.Machine) admission.Request {
+		rawMachine, err := json.Marshal(m)
+		g.Expect(err).ToNot(HaveOccurred())
+		return admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Delete,
+				OldObject: kruntime.RawExtension{Raw: rawMachine},
+			},
+		}
+	}
+
+	resp := h.Handle(context.Background(), request(master0))
+	g.Expect(resp.Allowed).To(BeFalse())
+	g.Expect(string(resp.Result.Reason)).To(ContainSubstring("fewer than the quorum of 2"))
+
+	// Audit mode does not lift the quorum check.
+	h.validationMode = ValidationModeAudit
+	resp = h.Handle(context.Background(), request(master0))
+	g.Expect(resp.Allowed).To(BeFalse())
+	g.Expect(string(resp.Result.Reason)).To(ContainSubstring("fewer than the quorum of 2"))
+
+	// Deleting a machine which is being deleted already does not change the quorum.
+	resp = h.Handle(context.Background(), request(master1))
+	g.Expect(resp.Allowed).To(BeTrue())
+}
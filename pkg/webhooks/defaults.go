@@ -0,0 +1,157 @@
+package webhooks
+
+import (
+	"fmt"
+	"strconv"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// DefaultsOverridesConfigMapName is the ConfigMap in the webhook namespace which overrides
+	// the values set by the defaulting webhooks. Its keys are the machine defaults keys below.
+	// Changes take effect without a restart.
+	DefaultsOverridesConfigMapName = "machine-api-webhook-defaults"
+
+	// EffectiveDefaultsConfigMapName is the ConfigMap in the webhook namespace where the operator
+	// publishes the values set by the defaulting webhooks, with the overrides applied.
+	EffectiveDefaultsConfigMapName = "effective-machine-defaults"
+	// EffectiveDefaultsGenerationAnnotation is the resourceVersion of the overrides ConfigMap the
+	// effective defaults were generated from, empty when there are no overrides. The effective defaults
+	// are stale when it differs from the current resourceVersion of the overrides ConfigMap.
+	EffectiveDefaultsGenerationAnnotation = "machine.openshift.io/effective-defaults-generation"
+	// EffectiveDefaultsPlatformAnnotation and EffectiveDefaultsArchitectureAnnotation record
+	// the platform and architecture the effective defaults apply to.
+	EffectiveDefaultsPlatformAnnotation     = "machine.openshift.io/effective-defaults-platform"
+	EffectiveDefaultsArchitectureAnnotation = "machine.openshift.io/effective-defaults-architecture"
+
+	// Machine defaults keys, named after the providerSpec field they default.
+	defaultsInstanceTypeKey               = "instanceType"
+	defaultsVMSizeKey                     = "vmSize"
+	defaultsMachineTypeKey                = "machineType"
+	defaultsUserDataSecretKey             = "userDataSecret"
+	defaultsCredentialsSecretKey          = "credentialsSecret"
+	defaultsCredentialsSecretNamespaceKey = "credentialsSecret.namespace"
+	defaultsDiskSizeGBKey                 = "disks.sizeGb"
+	defaultsDiskTypeKey                   = "disks.type"
+	defaultsDiskImageKey                  = "disks.image"
+)
+
+// defaultsKeys are the keys of the machine defaults of any platform.
+var defaultsKeys = sets.NewString(
+	defaultsInstanceTypeKey,
+	defaultsVMSizeKey,
+	defaultsMachineTypeKey,
+	defaultsUserDataSecretKey,
+	defaultsCredentialsSecretKey,
+	defaultsCredentialsSecretNamespaceKey,
+	defaultsDiskSizeGBKey,
+	defaultsDiskTypeKey,
+	defaultsDiskImageKey,
+)
+
+// builtinMachineDefaults returns the values the defaulting webhooks set on unset providerSpec fields
+// on the platform and architecture, keyed by machine defaults key.
+func builtinMachineDefaults(platform osconfigv1.PlatformType, arch string) map[string]string {
+	switch platform {
+	case osconfigv1.AWSPlatformType:
+		instanceType := defaultAWSX86InstanceType
+		if arch == "arm64" {
+			instanceType = defaultAWSARMInstanceType
+		}
+		return map[string]string{
+			defaultsInstanceTypeKey:      instanceType,
+			defaultsUserDataSecretKey:    defaultUserDataSecret,
+			defaultsCredentialsSecretKey: defaultAWSCredentialsSecret,
+		}
+	case osconfigv1.AzurePlatformType:
+		return map[string]string{
+			defaultsVMSizeKey:                     defaultAzureVMSize,
+			defaultsUserDataSecretKey:             defaultUserDataSecret,
+			defaultsCredentialsSecretKey:          defaultAzureCredentialsSecret,
+			defaultsCredentialsSecretNamespaceKey: defaultSecretNamespace,
+		}
+	case osconfigv1.GCPPlatformType:
+		return map[string]string{
+			defaultsMachineTypeKey:       defaultGCPMachineType,
+			defaultsUserDataSecretKey:    defaultUserDataSecret,
+			defaultsCredentialsSecretKey: defaultGCPCredentialsSecret,
+			defaultsDiskSizeGBKey:        strconv.Itoa(defaultGCPDiskSizeGb),
+			defaultsDiskTypeKey:          defaultGCPDiskType,
+			defaultsDiskImageKey:         defaultGCPDiskImage,
+		}
+	case osconfigv1.VSpherePlatformType:
+		return map[string]string{
+			defaultsUserDataSecretKey:    defaultUserDataSecret,
+			defaultsCredentialsSecretKey: defaultVSphereCredentialsSecret,
+		}
+	case nutanixPlatformType:
+		return map[string]string{
+			defaultsUserDataSecretKey:    defaultUserDataSecret,
+			defaultsCredentialsSecretKey: defaultNutanixCredentialsSecret,
+		}
+	case osconfigv1.IBMCloudPlatformType:
+		return map[string]string{
+			defaultsUserDataSecretKey:    defaultUserDataSecret,
+			defaultsCredentialsSecretKey: defaultIBMCloudCredentialsSecret,
+		}
+	case osconfigv1.AlibabaCloudPlatformType:
+		return map[string]string{
+			defaultsInstanceTypeKey:      defaultAlibabaCloudInstanceType,
+			defaultsUserDataSecretKey:    defaultUserDataSecret,
+			defaultsCredentialsSecretKey: defaultAlibabaCloudCredentialsSecret,
+		}
+	default:
+		return map[string]string{}
+	}
+}
+
+// EffectiveMachineDefaults returns the values the defaulting webhooks set on unset providerSpec fields
+// on the platform and architecture, with the overrides ConfigMap applied. Overrides of fields which are
+// not defaulted on the platform are ignored. overrides may be nil.
+func EffectiveMachineDefaults(platform osconfigv1.PlatformType, arch string, overrides *corev1.ConfigMap) (map[string]string, error) {
+	defaults := builtinMachineDefaults(platform, arch)
+	if overrides == nil {
+		return defaults, nil
+	}
+
+	if err := validateDefaultsOverrides(overrides); err != nil {
+		return nil, err
+	}
+	for key, value := range overrides.Data {
+		if _, ok := defaults[key]; ok {
+			defaults[key] = value
+		}
+	}
+	return defaults, nil
+}
+
+// validateDefaultsOverrides checks that the defaults overrides ConfigMap only sets known keys to valid values.
+func validateDefaultsOverrides(overrides *corev1.ConfigMap) error {
+	for _, key := range sets.StringKeySet(overrides.Data).List() {
+		value := overrides.Data[key]
+		if !defaultsKeys.Has(key) {
+			return fmt.Errorf("unknown key %q: expected one of %v", key, defaultsKeys.List())
+		}
+		if value == "" {
+			return fmt.Errorf("%s: must not be empty", key)
+		}
+		if key == defaultsDiskSizeGBKey {
+			if size, err := strconv.ParseInt(value, 10, 64); err != nil || size <= 0 {
+				return fmt.Errorf("%s: expected a positive integer, got %q", key, value)
+			}
+		}
+	}
+	return nil
+}
+
+// defaultValue returns the default of the machine defaults key.
+// The built-in defaults are used when the config carries no defaults.
+func (c *admissionConfig) defaultValue(platform osconfigv1.PlatformType, key string) string {
+	if value, ok := c.defaults[key]; ok {
+		return value
+	}
+	return builtinMachineDefaults(platform, c.arch)[key]
+}
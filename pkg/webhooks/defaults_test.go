@@ -0,0 +1,174 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"runtime"
+	"testing"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newDefaultsOverrides(data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      DefaultsOverridesConfigMapName,
+			Namespace: defaultWebhookServiceNamespace,
+		},
+		Data: data,
+	}
+}
+
+func TestEffectiveMachineDefaults(t *testing.T) {
+	testCases := []struct {
+		testCase         string
+		platform         osconfigv1.PlatformType
+		arch             string
+		overrides        *corev1.ConfigMap
+		expectedDefaults map[string]string
+		expectedError    bool
+	}{
+		{
+			testCase: "AWS on amd64",
+			platform: osconfigv1.AWSPlatformType,
+			arch:     "amd64",
+			expectedDefaults: map[string]string{
+				"instanceType":      "m5.large",
+				"userDataSecret":    "worker-user-data",
+				"credentialsSecret": "aws-cloud-credentials",
+			},
+		},
+		{
+			testCase: "AWS on arm64",
+			platform: osconfigv1.AWSPlatformType,
+			arch:     "arm64",
+			expectedDefaults: map[string]string{
+				"instanceType":      "m6g.large",
+				"userDataSecret":    "worker-user-data",
+				"credentialsSecret": "aws-cloud-credentials",
+			},
+		},
+		{
+			testCase:  "AWS with an instance type override",
+			platform:  osconfigv1.AWSPlatformType,
+			arch:      "amd64",
+			overrides: newDefaultsOverrides(map[string]string{"instanceType": "m5.xlarge"}),
+			expectedDefaults: map[string]string{
+				"instanceType":      "m5.xlarge",
+				"userDataSecret":    "worker-user-data",
+				"credentialsSecret": "aws-cloud-credentials",
+			},
+		},
+		{
+			testCase:  "AWS with an override of a field not defaulted on AWS",
+			platform:  osconfigv1.AWSPlatformType,
+			arch:      "amd64",
+			overrides: newDefaultsOverrides(map[string]string{"vmSize": "Standard_D8s_v3"}),
+			expectedDefaults: map[string]string{
+				"instanceType":      "m5.large",
+				"userDataSecret":    "worker-user-data",
+				"credentialsSecret": "aws-cloud-credentials",
+			},
+		},
+		{
+			testCase:  "GCP with disk overrides",
+			platform:  osconfigv1.GCPPlatformType,
+			arch:      "amd64",
+			overrides: newDefaultsOverrides(map[string]string{"disks.sizeGb": "256", "disks.type": "pd-ssd"}),
+			expectedDefaults: map[string]string{
+				"machineType":       "n1-standard-4",
+				"userDataSecret":    "worker-user-data",
+				"credentialsSecret": "gcp-cloud-credentials",
+				"disks.sizeGb":      "256",
+				"disks.type":        "pd-ssd",
+				"disks.image":       defaultGCPDiskImage,
+			},
+		},
+		{
+			testCase:      "with an unknown override",
+			platform:      osconfigv1.AWSPlatformType,
+			arch:          "amd64",
+			overrides:     newDefaultsOverrides(map[string]string{"instanceSize": "m5.xlarge"}),
+			expectedError: true,
+		},
+		{
+			testCase:      "with an invalid disk size override",
+			platform:      osconfigv1.GCPPlatformType,
+			arch:          "amd64",
+			overrides:     newDefaultsOverrides(map[string]string{"disks.sizeGb": "large"}),
+			expectedError: true,
+		},
+		{
+			testCase:         "on a platform without defaulting",
+			platform:         osconfigv1.BareMetalPlatformType,
+			arch:             "amd64",
+			expectedDefaults: map[string]string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			defaults, err := EffectiveMachineDefaults(tc.platform, tc.arch, tc.overrides)
+			if (err != nil) != tc.expectedError {
+				t.Fatalf("expected error: %v, got: %v", tc.expectedError, err)
+			}
+			if !reflect.DeepEqual(defaults, tc.expectedDefaults) {
+				t.Errorf("expected: %v, got: %v", tc.expectedDefaults, defaults)
+			}
+		})
+	}
+}
+
+func TestDefaultingUsesDefaultsOverrides(t *testing.T) {
+	infra := plainInfra.DeepCopy()
+	infra.Name = clusterConfigName
+	infra.Status.InfrastructureName = "clusterID"
+	infra.Status.PlatformStatus.Type = osconfigv1.AWSPlatformType
+	overrides := newDefaultsOverrides(map[string]string{"instanceType": "m5.xlarge"})
+	reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(infra, overrides).Build()
+
+	h := createMachineDefaulter(infra.Status.PlatformStatus, infra.Status.InfrastructureName)
+	h.inputs = newClusterInputsCache(reader)
+	h.inputs.refreshInterval = 0
+
+	defaultInstanceType := func() string {
+		rawBytes, err := json.Marshal(&machinev1.AWSMachineProviderConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := &machinev1.Machine{}
+		m.Spec.ProviderSpec.Value = &kruntime.RawExtension{Raw: rawBytes}
+
+		if ok, _, errs := h.webhookOperations(m, h.currentConfig()); !ok {
+			t.Fatalf("unexpected error: %v", errs)
+		}
+
+		providerSpec := &machinev1.AWSMachineProviderConfig{}
+		if err := json.Unmarshal(m.Spec.ProviderSpec.Value.Raw, providerSpec); err != nil {
+			t.Fatal(err)
+		}
+		return providerSpec.InstanceType
+	}
+
+	if instanceType := defaultInstanceType(); instanceType != "m5.xlarge" {
+		t.Errorf("expected the overridden instance type m5.xlarge, got: %s", instanceType)
+	}
+
+	if err := reader.Delete(context.Background(), overrides); err != nil {
+		t.Fatal(err)
+	}
+	expected := defaultAWSX86InstanceType
+	if runtime.GOARCH == "arm64" {
+		expected = defaultAWSARMInstanceType
+	}
+	if instanceType := defaultInstanceType(); instanceType != expected {
+		t.Errorf("expected the built-in instance type %s once the override is removed, got: %s", expected, instanceType)
+	}
+}
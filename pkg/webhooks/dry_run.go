@@ -0,0 +1,82 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// defaultedFieldsAnnotation lists the JSON paths populated by the defaulting webhooks. It is only set on
+// dry-run requests, so that the defaults can be previewed before creating the object.
+const defaultedFieldsAnnotation = "machine.openshift.io/defaulted-fields"
+
+// defaultingResponse returns the response patching the request object into the defaulted obj.
+// On dry-run requests the JSON paths populated by the defaulting are recorded in the
+// defaultedFieldsAnnotation of the object.
+func defaultingResponse(req admission.Request, obj client.Object, warnings []string) admission.Response {
+	marshaled, err := json.Marshal(obj)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err).WithWarnings(warnings...)
+	}
+
+	resp := admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+	if req.DryRun == nil || !*req.DryRun || !resp.Allowed {
+		return resp.WithWarnings(warnings...)
+	}
+
+	paths := defaultedPaths(resp)
+	if len(paths) == 0 {
+		return resp.WithWarnings(warnings...)
+	}
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[defaultedFieldsAnnotation] = strings.Join(paths, ",")
+	obj.SetAnnotations(annotations)
+
+	marshaled, err = json.Marshal(obj)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err).WithWarnings(warnings...)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled).WithWarnings(warnings...)
+}
+
+// defaultedPaths returns the sorted JSON paths added or replaced by the patches of the response.
+// Added objects are listed by the paths of their fields, leaving out the empty ones.
+// Fields removed by the defaulting are not listed, see removedProviderSpecFieldsAnnotation.
+func defaultedPaths(resp admission.Response) []string {
+	var paths []string
+	for _, patch := range resp.Patches {
+		if patch.Operation == "add" || patch.Operation == "replace" {
+			paths = appendLeafPaths(paths, patch.Path, patch.Value)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// appendLeafPaths appends the JSON paths of the non-empty values nested in value to paths.
+func appendLeafPaths(paths []string, path string, value interface{}) []string {
+	switch v := value.(type) {
+	case nil:
+		return paths
+	case string:
+		if v == "" {
+			return paths
+		}
+	case map[string]interface{}:
+		for key, nested := range v {
+			// Escape the key as a JSON pointer reference token, see RFC 6901.
+			token := strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+			paths = appendLeafPaths(paths, path+"/"+token, nested)
+		}
+		return paths
+	}
+	return append(paths, path)
+}
@@ -0,0 +1,104 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestDefaultedFieldsOnDryRun(t *testing.T) {
+	platformStatus := &osconfigv1.PlatformStatus{
+		Type: osconfigv1.AWSPlatformType,
+		AWS:  &osconfigv1.AWSPlatformStatus{Region: "us-east-1"},
+	}
+
+	rawProviderSpec, err := json.Marshal(map[string]interface{}{
+		"kind":         "AWSMachineProviderConfig",
+		"instanceType": "m5.xlarge",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawMachine, err := json.Marshal(&machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: defaultWebhookServiceNamespace},
+		Spec: machinev1.MachineSpec{
+			ProviderSpec: machinev1.ProviderSpec{Value: &kruntime.RawExtension{Raw: rawProviderSpec}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name               string
+		dryRun             *bool
+		expectedAnnotation bool
+	}{
+		{
+			name: "without dry run",
+		},
+		{
+			name:   "with dry run disabled",
+			dryRun: pointer.BoolPtr(false),
+		},
+		{
+			name:               "with dry run",
+			dryRun:             pointer.BoolPtr(true),
+			expectedAnnotation: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			h := createMachineDefaulter(platformStatus, "clusterID")
+			decoder, err := admission.NewDecoder(scheme.Scheme)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(h.InjectDecoder(decoder)).To(Succeed())
+
+			resp := h.Handle(context.Background(), admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: admissionv1.Create,
+					DryRun:    tc.dryRun,
+					Object:    kruntime.RawExtension{Raw: rawMachine},
+				},
+			})
+			g.Expect(resp.Allowed).To(BeTrue())
+
+			var annotation string
+			for _, patch := range resp.Patches {
+				if patch.Path == "/metadata/annotations" {
+					annotations, ok := patch.Value.(map[string]interface{})
+					g.Expect(ok).To(BeTrue())
+					annotation, _ = annotations[defaultedFieldsAnnotation].(string)
+				}
+			}
+
+			if !tc.expectedAnnotation {
+				g.Expect(annotation).To(BeEmpty())
+				return
+			}
+			paths := strings.Split(annotation, ",")
+			g.Expect(paths).To(ContainElements(
+				"/metadata/labels/machine.openshift.io~1cluster-api-cluster",
+				"/spec/providerSpec/value/credentialsSecret/name",
+				"/spec/providerSpec/value/placement/region",
+				"/spec/providerSpec/value/userDataSecret/name",
+			))
+			g.Expect(paths).ToNot(ContainElement("/spec/providerSpec/value/instanceType"), "fields set in the request are not defaulted")
+			g.Expect(paths).ToNot(ContainElement("/spec/providerSpec/value/ami"), "empty fields are not listed")
+		})
+	}
+}
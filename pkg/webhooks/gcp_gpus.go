@@ -0,0 +1,129 @@
+package webhooks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// gcpBundledGPUMachineFamilies are the machine families with GPUs attached to every machine type,
+// keyed by machine type prefix, with the GPU types they bundle.
+// https://cloud.google.com/compute/docs/gpus#gpus-list
+var gcpBundledGPUMachineFamilies = map[string]gcpBundledGPUMachineFamily{
+	"a2-": {name: "A2", gpuTypes: sets.NewString("nvidia-tesla-a100", "nvidia-a100-80gb")},
+	"g2-": {name: "G2", gpuTypes: sets.NewString("nvidia-l4")},
+}
+
+// gcpBundledGPUMachineFamily is a machine family whose machine types come with GPUs attached.
+type gcpBundledGPUMachineFamily struct {
+	name     string
+	gpuTypes sets.String
+}
+
+// gcpAttachableGPUs are the GPU types which can be attached to N1 machine types, with the maximum
+// vCPUs of the machine type for each supported GPU count.
+// https://cloud.google.com/compute/docs/gpus#nvidia_gpus_for_compute_workloads
+var gcpAttachableGPUs = map[string]map[int32]int{
+	"nvidia-tesla-k80":      {1: 8, 2: 16, 4: 32, 8: 64},
+	"nvidia-tesla-p4":       {1: 24, 2: 48, 4: 96},
+	"nvidia-tesla-p4-vws":   {1: 24, 2: 48, 4: 96},
+	"nvidia-tesla-t4":       {1: 48, 2: 48, 4: 96},
+	"nvidia-tesla-t4-vws":   {1: 48, 2: 48, 4: 96},
+	"nvidia-tesla-p100":     {1: 16, 2: 32, 4: 96},
+	"nvidia-tesla-p100-vws": {1: 16, 2: 32, 4: 96},
+	"nvidia-tesla-v100":     {1: 12, 2: 24, 4: 48, 8: 96},
+}
+
+// gcpBundledGPUMachineFamilyOf returns the bundled GPU machine family of the machine type, if it is one.
+func gcpBundledGPUMachineFamilyOf(machineType string) (gcpBundledGPUMachineFamily, bool) {
+	for prefix, family := range gcpBundledGPUMachineFamilies {
+		if strings.HasPrefix(machineType, prefix) {
+			return family, true
+		}
+	}
+	return gcpBundledGPUMachineFamily{}, false
+}
+
+// gcpN1VCPUs returns the vCPUs of an N1 machine type, eg. 8 for n1-standard-8, n1-custom-8-30720
+// or custom-8-30720, and false for machine types of other families.
+func gcpN1VCPUs(machineType string) (int, bool) {
+	var vCPUs string
+	switch parts := strings.Split(machineType, "-"); {
+	case len(parts) == 3 && parts[0] == "n1" && parts[1] != "custom":
+		vCPUs = parts[2]
+	case len(parts) >= 4 && parts[0] == "n1" && parts[1] == "custom":
+		vCPUs = parts[2]
+	case len(parts) >= 3 && parts[0] == "custom":
+		vCPUs = parts[1]
+	default:
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(vCPUs)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// validateGCPGPUs checks the GPUs against the machine type. GPUs cannot be added to the machine types of
+// the bundled GPU families. GPU types known to the compatibility matrix are only attached to N1 machine
+// types, in the supported counts and up to the vCPUs supported for the count. Unknown GPU types are not
+// checked against the matrix.
+func validateGCPGPUs(guestAccelerators []machinev1.GCPGPUConfig, parentPath *field.Path, machineType string) []error {
+	var errs []error
+	if len(guestAccelerators) > 1 {
+		return append(errs, field.TooMany(parentPath, len(guestAccelerators), 1))
+	} else if len(guestAccelerators) == 0 {
+		return errs
+	}
+
+	accelerator := guestAccelerators[0]
+	if accelerator.Type == "" {
+		errs = append(errs, field.Required(parentPath.Child("Type"), "Type is required"))
+	}
+
+	if family, ok := gcpBundledGPUMachineFamilyOf(machineType); ok {
+		return append(errs, field.Invalid(parentPath, accelerator.Type, fmt.Sprintf("%s machine types have already attached gpus, additional gpus cannot be specified", family.name)))
+	}
+
+	for _, family := range gcpBundledGPUMachineFamilies {
+		if family.gpuTypes.Has(accelerator.Type) {
+			return append(errs, field.Invalid(parentPath.Child("Type"), accelerator.Type, fmt.Sprintf(" %s gpus, are only attached to the %s machine types", accelerator.Type, family.name)))
+		}
+	}
+
+	maxVCPUs, ok := gcpAttachableGPUs[accelerator.Type]
+	if !ok {
+		return errs
+	}
+
+	// A count of 0 is defaulted to 1.
+	count := accelerator.Count
+	if count == 0 {
+		count = defaultGCPGPUCount
+	}
+	limit, ok := maxVCPUs[count]
+	if !ok {
+		counts := []string{}
+		for _, supported := range []int32{1, 2, 4, 8} {
+			if _, ok := maxVCPUs[supported]; ok {
+				counts = append(counts, strconv.Itoa(int(supported)))
+			}
+		}
+		errs = append(errs, field.NotSupported(parentPath.Child("Count"), accelerator.Count, counts))
+	}
+
+	vCPUs, ok := gcpN1VCPUs(machineType)
+	if !ok {
+		return append(errs, field.Invalid(parentPath.Child("Type"), accelerator.Type, fmt.Sprintf("%s gpus can only be attached to N1 machine types, not %s", accelerator.Type, machineType)))
+	}
+	if limit != 0 && vCPUs > limit {
+		errs = append(errs, field.Invalid(field.NewPath("providerSpec", "machineType"), machineType, fmt.Sprintf("machine types with %d %s gpus can have at most %d vCPUs", count, accelerator.Type, limit)))
+	}
+	return errs
+}
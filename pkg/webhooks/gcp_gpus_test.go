@@ -0,0 +1,110 @@
+package webhooks
+
+import (
+	"testing"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidateGCPGPUs(t *testing.T) {
+	testCases := []struct {
+		testCase      string
+		machineType   string
+		gpus          []machinev1.GCPGPUConfig
+		expectedError string
+	}{
+		{
+			testCase:    "with an attachable gpu on an N1 machine type",
+			machineType: "n1-standard-8",
+			gpus:        []machinev1.GCPGPUConfig{{Type: "nvidia-tesla-t4", Count: 1}},
+		},
+		{
+			testCase:    "with an attachable gpu on an N1 custom machine type",
+			machineType: "n1-custom-8-30720",
+			gpus:        []machinev1.GCPGPUConfig{{Type: "nvidia-tesla-v100", Count: 1}},
+		},
+		{
+			testCase:    "with the count left to be defaulted",
+			machineType: "custom-8-30720",
+			gpus:        []machinev1.GCPGPUConfig{{Type: "nvidia-tesla-p100"}},
+		},
+		{
+			testCase:    "with a bundled gpu machine type",
+			machineType: "g2-standard-8",
+		},
+		{
+			testCase:    "with a gpu type missing from the compatibility matrix",
+			machineType: "n2-standard-8",
+			gpus:        []machinev1.GCPGPUConfig{{Type: "nvidia-h100-80gb", Count: 1}},
+		},
+		{
+			testCase:      "with gpus on a G2 machine type",
+			machineType:   "g2-standard-8",
+			gpus:          []machinev1.GCPGPUConfig{{Type: "nvidia-l4", Count: 1}},
+			expectedError: "providerSpec.gpus: Invalid value: \"nvidia-l4\": G2 machine types have already attached gpus, additional gpus cannot be specified",
+		},
+		{
+			testCase:      "with a bundled gpu type on an N1 machine type",
+			machineType:   "n1-standard-8",
+			gpus:          []machinev1.GCPGPUConfig{{Type: "nvidia-l4", Count: 1}},
+			expectedError: "providerSpec.gpus.Type: Invalid value: \"nvidia-l4\":  nvidia-l4 gpus, are only attached to the G2 machine types",
+		},
+		{
+			testCase:      "with an attachable gpu on another machine family",
+			machineType:   "n2-standard-8",
+			gpus:          []machinev1.GCPGPUConfig{{Type: "nvidia-tesla-t4", Count: 1}},
+			expectedError: "providerSpec.gpus.Type: Invalid value: \"nvidia-tesla-t4\": nvidia-tesla-t4 gpus can only be attached to N1 machine types, not n2-standard-8",
+		},
+		{
+			testCase:      "with an unsupported gpu count",
+			machineType:   "n1-standard-8",
+			gpus:          []machinev1.GCPGPUConfig{{Type: "nvidia-tesla-t4", Count: 3}},
+			expectedError: "providerSpec.gpus.Count: Unsupported value: 3: supported values: \"1\", \"2\", \"4\"",
+		},
+		{
+			testCase:      "with more vCPUs than the gpu count supports",
+			machineType:   "n1-standard-16",
+			gpus:          []machinev1.GCPGPUConfig{{Type: "nvidia-tesla-v100", Count: 1}},
+			expectedError: "providerSpec.machineType: Invalid value: \"n1-standard-16\": machine types with 1 nvidia-tesla-v100 gpus can have at most 12 vCPUs",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			err := utilerrors.NewAggregate(validateGCPGPUs(tc.gpus, field.NewPath("providerSpec", "gpus"), tc.machineType))
+			if err == nil {
+				if tc.expectedError != "" {
+					t.Errorf("expected: %q, got: %v", tc.expectedError, err)
+				}
+			} else if err.Error() != tc.expectedError {
+				t.Errorf("expected: %q, got: %q", tc.expectedError, err.Error())
+			}
+		})
+	}
+}
+
+func TestGCPN1VCPUs(t *testing.T) {
+	testCases := []struct {
+		machineType   string
+		expectedVCPUs int
+		expectedOk    bool
+	}{
+		{machineType: "n1-standard-4", expectedVCPUs: 4, expectedOk: true},
+		{machineType: "n1-highmem-96", expectedVCPUs: 96, expectedOk: true},
+		{machineType: "n1-custom-6-23040", expectedVCPUs: 6, expectedOk: true},
+		{machineType: "custom-6-23040-ext", expectedVCPUs: 6, expectedOk: true},
+		{machineType: "n2-standard-4"},
+		{machineType: "n1-standard"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.machineType, func(t *testing.T) {
+			vCPUs, ok := gcpN1VCPUs(tc.machineType)
+			if vCPUs != tc.expectedVCPUs || ok != tc.expectedOk {
+				t.Errorf("expected: %d, %v, got: %d, %v", tc.expectedVCPUs, tc.expectedOk, vCPUs, ok)
+			}
+		})
+	}
+}
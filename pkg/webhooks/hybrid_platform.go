@@ -0,0 +1,57 @@
+package webhooks
+
+import (
+	"fmt"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	yaml "sigs.k8s.io/yaml"
+)
+
+// providerSpecKindPlatforms maps the providerSpec kinds to the platform whose rules validate them.
+var providerSpecKindPlatforms = map[string]osconfigv1.PlatformType{
+	"AWSMachineProviderConfig":          osconfigv1.AWSPlatformType,
+	"AlibabaCloudMachineProviderConfig": osconfigv1.AlibabaCloudPlatformType,
+	"AzureMachineProviderSpec":          osconfigv1.AzurePlatformType,
+	"GCPMachineProviderSpec":            osconfigv1.GCPPlatformType,
+	"IBMCloudMachineProviderSpec":       osconfigv1.IBMCloudPlatformType,
+	"NutanixMachineProviderConfig":      nutanixPlatformType,
+	"OpenstackProviderSpec":             osconfigv1.OpenStackPlatformType,
+	"PowerVSMachineProviderConfig":      osconfigv1.PowerVSPlatformType,
+	"VSphereMachineProviderSpec":        osconfigv1.VSpherePlatformType,
+}
+
+// providerSpecPlatform returns the platform named by the kind embedded in the providerSpec,
+// or an empty platform when the kind is absent or names no known platform.
+func providerSpecPlatform(m *machinev1.Machine) osconfigv1.PlatformType {
+	if m.Spec.ProviderSpec.Value == nil {
+		return ""
+	}
+
+	typeMeta := metav1.TypeMeta{}
+	if err := yaml.Unmarshal(m.Spec.ProviderSpec.Value.Raw, &typeMeta); err != nil {
+		return ""
+	}
+	return providerSpecKindPlatforms[typeMeta.Kind]
+}
+
+// getHybridMachineValidatorOperation returns a machineAdmissionFn for clusters hosting machines
+// of platforms other than their own, eg. HyperShift management clusters.
+// Machines are validated against the rules of the platform named by their providerSpec kind,
+// falling back to the cluster platform when the kind does not name a known platform.
+func getHybridMachineValidatorOperation(clusterPlatform osconfigv1.PlatformType) machineAdmissionFn {
+	clusterOperation := getMachineValidatorOperation(clusterPlatform)
+
+	return func(m *machinev1.Machine, config *admissionConfig) (bool, []string, utilerrors.Aggregate) {
+		platform := providerSpecPlatform(m)
+		if platform == "" || platform == clusterPlatform {
+			return clusterOperation(m, config)
+		}
+
+		ok, warnings, errs := getMachineValidatorOperation(platform)(m, config)
+		warnings = append(warnings, fmt.Sprintf("providerSpec.kind: validated against the %s platform rules instead of the %s cluster platform rules", platform, clusterPlatform))
+		return ok, warnings, errs
+	}
+}
@@ -0,0 +1,129 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"testing"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestHybridPlatformValidation(t *testing.T) {
+	gcpProviderSpec := &machinev1.GCPMachineProviderSpec{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "machine.openshift.io/v1beta1",
+			Kind:       "GCPMachineProviderSpec",
+		},
+		Region:            "region",
+		Zone:              "region-zone",
+		ProjectID:         "projectID",
+		MachineType:       "machineType",
+		OnHostMaintenance: machinev1.MigrateHostMaintenanceType,
+		NetworkInterfaces: []*machinev1.GCPNetworkInterface{
+			{
+				Network:    "network",
+				Subnetwork: "subnetwork",
+			},
+		},
+		Disks: []*machinev1.GCPDisk{
+			{
+				SizeGB: 16,
+			},
+		},
+		ServiceAccounts: []machinev1.GCPServiceAccount{
+			{
+				Email:  "email",
+				Scopes: []string{"scope"},
+			},
+		},
+		UserDataSecret: &corev1.LocalObjectReference{
+			Name: "name",
+		},
+		CredentialsSecret: &corev1.LocalObjectReference{
+			Name: "name",
+		},
+	}
+	gcpProviderSpecWithoutKind := gcpProviderSpec.DeepCopy()
+	gcpProviderSpecWithoutKind.TypeMeta = metav1.TypeMeta{}
+
+	testCases := []struct {
+		testCase                 string
+		providerSpec             *machinev1.GCPMachineProviderSpec
+		hybridPlatformValidation bool
+		expectedOk               bool
+		expectedPlatformWarning  bool
+	}{
+		{
+			testCase:                 "with a GCP providerSpec on an AWS cluster and hybrid validation enabled",
+			providerSpec:             gcpProviderSpec,
+			hybridPlatformValidation: true,
+			expectedOk:               true,
+			expectedPlatformWarning:  true,
+		},
+		{
+			testCase:                 "with a GCP providerSpec on an AWS cluster and hybrid validation disabled",
+			providerSpec:             gcpProviderSpec,
+			hybridPlatformValidation: false,
+			expectedOk:               false,
+		},
+		{
+			testCase:                 "with a GCP providerSpec without kind on an AWS cluster and hybrid validation enabled",
+			providerSpec:             gcpProviderSpecWithoutKind,
+			hybridPlatformValidation: true,
+			expectedOk:               false,
+		},
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "name",
+			Namespace: "namespace",
+		},
+	}
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, secret)
+	infra := plainInfra.DeepCopy()
+	infra.Status.InfrastructureName = "clusterID"
+	infra.Status.PlatformStatus.Type = osconfigv1.AWSPlatformType
+
+	platformWarning := "providerSpec.kind: validated against the GCP platform rules instead of the AWS cluster platform rules"
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			h := createMachineValidator(infra, c, plainDNS)
+			if tc.hybridPlatformValidation {
+				h.webhookOperations = getHybridMachineValidatorOperation(infra.Status.PlatformStatus.Type)
+			}
+
+			rawBytes, err := json.Marshal(tc.providerSpec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := &machinev1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "namespace",
+				},
+			}
+			m.Spec.ProviderSpec.Value = &kruntime.RawExtension{Raw: rawBytes}
+
+			ok, warnings, errs := h.webhookOperations(m, h.admissionConfig)
+			if ok != tc.expectedOk {
+				t.Errorf("expected: %v, got: %v: %v", tc.expectedOk, ok, errs)
+			}
+
+			hasPlatformWarning := false
+			for _, warning := range warnings {
+				if warning == platformWarning {
+					hasPlatformWarning = true
+				}
+			}
+			if hasPlatformWarning != tc.expectedPlatformWarning {
+				t.Errorf("expected platform warning: %v, got warnings: %q", tc.expectedPlatformWarning, warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package webhooks
+
+import (
+	"fmt"
+	"strings"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+)
+
+// ibmCloudProviderSpec is the subset of the IBM Cloud VPC providerSpec the webhooks validate.
+// It models the VPC placement of the instance, its region, zone, subnet and resource group, and the image
+// and profile it boots.
+type ibmCloudProviderSpec struct {
+	VPC                     string                       `json:"vpc,omitempty"`
+	Image                   string                       `json:"image,omitempty"`
+	Profile                 string                       `json:"profile,omitempty"`
+	Region                  string                       `json:"region,omitempty"`
+	Zone                    string                       `json:"zone,omitempty"`
+	ResourceGroup           string                       `json:"resourceGroup,omitempty"`
+	PrimaryNetworkInterface ibmCloudNetworkInterface     `json:"primaryNetworkInterface,omitempty"`
+	UserDataSecret          *corev1.LocalObjectReference `json:"userDataSecret,omitempty"`
+	CredentialsSecret       *corev1.LocalObjectReference `json:"credentialsSecret,omitempty"`
+}
+
+// ibmCloudNetworkInterface is a network interface of an IBM Cloud VPC instance.
+type ibmCloudNetworkInterface struct {
+	Subnet string `json:"subnet,omitempty"`
+}
+
+// defaultIBMCloud defaults the secrets of the IBM Cloud providerSpec.
+func defaultIBMCloud(m *machinev1.Machine, config *admissionConfig) (bool, []string, utilerrors.Aggregate) {
+	klog.V(3).Infof("Defaulting IBM Cloud providerSpec")
+
+	if err := defaultRawProviderSpec(m, rawProviderSpecSecretDefaults(config, osconfigv1.IBMCloudPlatformType)); err != nil {
+		return false, []string{}, utilerrors.NewAggregate([]error{err})
+	}
+	return true, []string{}, nil
+}
+
+func validateIBMCloud(m *machinev1.Machine, config *admissionConfig) (bool, []string, utilerrors.Aggregate) {
+	klog.V(3).Infof("Validating IBM Cloud providerSpec")
+
+	var errs []error
+	var warnings []string
+	providerSpec := new(ibmCloudProviderSpec)
+	if err := unmarshalInto(m, providerSpec); err != nil {
+		errs = append(errs, err)
+		return false, warnings, utilerrors.NewAggregate(errs)
+	}
+
+	if providerSpec.VPC == "" {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "vpc"), "vpc must be provided"))
+	}
+	if providerSpec.Image == "" {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "image"), "image must be provided"))
+	}
+	if providerSpec.Profile == "" {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "profile"), "profile must be provided"))
+	}
+	if providerSpec.PrimaryNetworkInterface.Subnet == "" {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "primaryNetworkInterface", "subnet"), "subnet must be provided"))
+	}
+
+	regionWarnings, regionErrs := validateIBMCloudRegion(providerSpec, config.platformStatus)
+	warnings = append(warnings, regionWarnings...)
+	errs = append(errs, regionErrs...)
+
+	if providerSpec.ResourceGroup == "" {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "resourceGroup"), "resourceGroup must be provided"))
+	} else if status := ibmCloudPlatformStatus(config.platformStatus); status != nil && status.ResourceGroupName != "" && providerSpec.ResourceGroup != status.ResourceGroupName {
+		warnings = append(warnings, fmt.Sprintf("providerSpec.resourceGroup: %s differs from the resource group of the cluster (%s): the instance will not be cleaned up with the rest of the cluster", providerSpec.ResourceGroup, status.ResourceGroupName))
+	}
+
+	if providerSpec.UserDataSecret == nil {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "userDataSecret"), "userDataSecret must be provided"))
+	} else if providerSpec.UserDataSecret.Name == "" {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "userDataSecret", "name"), "name must be provided"))
+	}
+
+	if providerSpec.CredentialsSecret == nil {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "credentialsSecret"), "credentialsSecret must be provided"))
+	} else if providerSpec.CredentialsSecret.Name == "" {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "credentialsSecret", "name"), "name must be provided"))
+	} else {
+		warnings = append(warnings, validateCredentialsSecret(config.secretsReader(), providerSpec.CredentialsSecret.Name, m.GetNamespace())...)
+	}
+
+	if len(errs) > 0 {
+		return false, warnings, utilerrors.NewAggregate(errs)
+	}
+	return true, warnings, nil
+}
+
+// validateIBMCloudRegion checks that the zone is in the region, IBM Cloud VPC zones being named
+// after their region, eg. us-south-1. It warns when the region is not the region of the cluster.
+func validateIBMCloudRegion(providerSpec *ibmCloudProviderSpec, platformStatus *osconfigv1.PlatformStatus) ([]string, []error) {
+	var warnings []string
+	var errs []error
+
+	if providerSpec.Region == "" {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "region"), "region must be provided"))
+	} else if status := ibmCloudPlatformStatus(platformStatus); status != nil && status.Location != "" && providerSpec.Region != status.Location {
+		warnings = append(warnings, fmt.Sprintf("providerSpec.region: %s differs from the region of the cluster (%s): the instance may not reach the cluster network", providerSpec.Region, status.Location))
+	}
+
+	if providerSpec.Zone == "" {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "zone"), "zone must be provided"))
+	} else if providerSpec.Region != "" && !strings.HasPrefix(providerSpec.Zone, providerSpec.Region+"-") {
+		errs = append(errs, field.Invalid(field.NewPath("providerSpec", "zone"), providerSpec.Zone, fmt.Sprintf("zone must be in the region %s", providerSpec.Region)))
+	}
+	return warnings, errs
+}
+
+// ibmCloudPlatformStatus returns the IBM Cloud platform status of the cluster, or nil on other platforms.
+func ibmCloudPlatformStatus(platformStatus *osconfigv1.PlatformStatus) *osconfigv1.IBMCloudPlatformStatus {
+	if platformStatus == nil || platformStatus.Type != osconfigv1.IBMCloudPlatformType {
+		return nil
+	}
+	return platformStatus.IBMCloud
+}
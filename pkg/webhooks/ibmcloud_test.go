@@ -0,0 +1,159 @@
+package webhooks
+
+import (
+	"reflect"
+	"testing"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	yaml "sigs.k8s.io/yaml"
+)
+
+func TestDefaultIBMCloud(t *testing.T) {
+	m := &machinev1.Machine{
+		Spec: machinev1.MachineSpec{
+			ProviderSpec: machinev1.ProviderSpec{Value: &kruntime.RawExtension{Raw: []byte(`{"profile": "bx2-4x16"}`)}},
+		},
+	}
+
+	ok, _, err := defaultIBMCloud(m, &admissionConfig{})
+	if !ok || err != nil {
+		t.Fatalf("expected the providerSpec to be defaulted, got: %v", err)
+	}
+
+	providerSpec := map[string]interface{}{}
+	if err := yaml.Unmarshal(m.Spec.ProviderSpec.Value.Raw, &providerSpec); err != nil {
+		t.Fatal(err)
+	}
+	expectedProviderSpec := map[string]interface{}{
+		"profile":           "bx2-4x16",
+		"userDataSecret":    map[string]interface{}{"name": defaultUserDataSecret},
+		"credentialsSecret": map[string]interface{}{"name": defaultIBMCloudCredentialsSecret},
+	}
+	if !reflect.DeepEqual(providerSpec, expectedProviderSpec) {
+		t.Errorf("expected: %+v, got: %+v", expectedProviderSpec, providerSpec)
+	}
+}
+
+func TestValidateIBMCloud(t *testing.T) {
+	namespace := "openshift-machine-api"
+	credentialsSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: defaultIBMCloudCredentialsSecret, Namespace: namespace},
+	}
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, credentialsSecret)
+
+	platformStatus := &osconfigv1.PlatformStatus{
+		Type: osconfigv1.IBMCloudPlatformType,
+		IBMCloud: &osconfigv1.IBMCloudPlatformStatus{
+			Location:          "us-south",
+			ResourceGroupName: "cluster-rg",
+			ProviderType:      osconfigv1.IBMCloudProviderTypeVPC,
+		},
+	}
+
+	validProviderSpec := func(modify func(*ibmCloudProviderSpec)) *ibmCloudProviderSpec {
+		providerSpec := &ibmCloudProviderSpec{
+			VPC:                     "cluster-vpc",
+			Image:                   "rhcos",
+			Profile:                 "bx2-4x16",
+			Region:                  "us-south",
+			Zone:                    "us-south-1",
+			ResourceGroup:           "cluster-rg",
+			PrimaryNetworkInterface: ibmCloudNetworkInterface{Subnet: "cluster-subnet-compute-us-south-1"},
+			UserDataSecret:          &corev1.LocalObjectReference{Name: defaultUserDataSecret},
+			CredentialsSecret:       &corev1.LocalObjectReference{Name: defaultIBMCloudCredentialsSecret},
+		}
+		if modify != nil {
+			modify(providerSpec)
+		}
+		return providerSpec
+	}
+
+	testCases := []struct {
+		testCase         string
+		providerSpec     *ibmCloudProviderSpec
+		expectedOk       bool
+		expectedError    string
+		expectedWarnings []string
+	}{
+		{
+			testCase:     "with a valid providerSpec",
+			providerSpec: validProviderSpec(nil),
+			expectedOk:   true,
+		},
+		{
+			testCase:      "with an empty providerSpec",
+			providerSpec:  &ibmCloudProviderSpec{},
+			expectedOk:    false,
+			expectedError: "[providerSpec.vpc: Required value: vpc must be provided, providerSpec.image: Required value: image must be provided, providerSpec.profile: Required value: profile must be provided, providerSpec.primaryNetworkInterface.subnet: Required value: subnet must be provided, providerSpec.region: Required value: region must be provided, providerSpec.zone: Required value: zone must be provided, providerSpec.resourceGroup: Required value: resourceGroup must be provided, providerSpec.userDataSecret: Required value: userDataSecret must be provided, providerSpec.credentialsSecret: Required value: credentialsSecret must be provided]",
+		},
+		{
+			testCase: "with a zone outside of the region",
+			providerSpec: validProviderSpec(func(p *ibmCloudProviderSpec) {
+				p.Zone = "us-east-1"
+			}),
+			expectedOk:    false,
+			expectedError: "providerSpec.zone: Invalid value: \"us-east-1\": zone must be in the region us-south",
+		},
+		{
+			testCase: "with another region and resource group than the cluster",
+			providerSpec: validProviderSpec(func(p *ibmCloudProviderSpec) {
+				p.Region = "eu-de"
+				p.Zone = "eu-de-2"
+				p.ResourceGroup = "other-rg"
+			}),
+			expectedOk: true,
+			expectedWarnings: []string{
+				"providerSpec.region: eu-de differs from the region of the cluster (us-south): the instance may not reach the cluster network",
+				"providerSpec.resourceGroup: other-rg differs from the resource group of the cluster (cluster-rg): the instance will not be cleaned up with the rest of the cluster",
+			},
+		},
+		{
+			testCase: "with a credentials secret that does not exist",
+			providerSpec: validProviderSpec(func(p *ibmCloudProviderSpec) {
+				p.CredentialsSecret = &corev1.LocalObjectReference{Name: "missing"}
+			}),
+			expectedOk: true,
+			expectedWarnings: []string{
+				"providerSpec.credentialsSecret: Invalid value: \"missing\": not found. Expected CredentialsSecret to exist",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			rawBytes, err := yaml.Marshal(tc.providerSpec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := &machinev1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace},
+				Spec: machinev1.MachineSpec{
+					ProviderSpec: machinev1.ProviderSpec{Value: &kruntime.RawExtension{Raw: rawBytes}},
+				},
+			}
+
+			ok, warnings, err := validateIBMCloud(m, &admissionConfig{client: c, platformStatus: platformStatus})
+			if ok != tc.expectedOk {
+				t.Errorf("expected: %v, got: %v", tc.expectedOk, ok)
+			}
+			if err == nil {
+				if tc.expectedError != "" {
+					t.Errorf("expected: %q, got: %v", tc.expectedError, err)
+				}
+			} else {
+				if err.Error() != tc.expectedError {
+					t.Errorf("expected: %q, got: %q", tc.expectedError, err.Error())
+				}
+			}
+			if !reflect.DeepEqual(warnings, tc.expectedWarnings) {
+				t.Errorf("expected: %q, got: %q", tc.expectedWarnings, warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package webhooks
+
+import (
+	"fmt"
+	"strings"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// azureSubscriptionIDKey is the key of the subscription ID in Azure credentials secrets.
+	azureSubscriptionIDKey = "azure_subscription_id"
+
+	gcpDevstorageReadOnlyScope = "https://www.googleapis.com/auth/devstorage.read_only"
+	gcpCloudPlatformScope      = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+// gcpStorageReadScopes are the service account scopes which allow an instance to read images
+// from Cloud Storage in other projects.
+var gcpStorageReadScopes = sets.NewString(
+	gcpDevstorageReadOnlyScope,
+	"https://www.googleapis.com/auth/devstorage.read_write",
+	"https://www.googleapis.com/auth/devstorage.full_control",
+	gcpCloudPlatformScope,
+)
+
+// validateAzureImageAccess warns when the image is taken from a shared image gallery in another subscription
+// than the cluster's, but the machine has no managed identity to read it with.
+// The cluster's subscription is read from the credentials secret. IAM cannot be checked from the webhook,
+// so this only catches the missing prerequisite.
+func validateAzureImageAccess(c client.Reader, providerSpec *machinev1.AzureMachineProviderSpec) []string {
+	if providerSpec.ManagedIdentity != "" || providerSpec.CredentialsSecret == nil {
+		return nil
+	}
+
+	gallerySubscriptionID := azureGallerySubscriptionID(providerSpec.Image.ResourceID)
+	if gallerySubscriptionID == "" {
+		return nil
+	}
+
+	secret, err := getSecret(c, providerSpec.CredentialsSecret.Name, providerSpec.CredentialsSecret.Namespace)
+	if err != nil || secret == nil {
+		// Missing credentials are reported by validateCredentialsSecret.
+		klog.V(3).Infof("Unable to read the cluster subscription from credentials secret %s/%s: %v", providerSpec.CredentialsSecret.Namespace, providerSpec.CredentialsSecret.Name, err)
+		return nil
+	}
+	clusterSubscriptionID := strings.TrimSpace(string(secret.Data[azureSubscriptionIDKey]))
+	if clusterSubscriptionID == "" || strings.EqualFold(clusterSubscriptionID, gallerySubscriptionID) {
+		return nil
+	}
+
+	return []string{fmt.Sprintf("%s: image %q is in a gallery of subscription %q, outside of the cluster's subscription %q, and no managedIdentity is set: the machine may be unable to pull the image on a disconnected cluster, set providerSpec.managedIdentity to an identity with read access to the gallery", field.NewPath("providerSpec", "image", "resourceID"), providerSpec.Image.ResourceID, gallerySubscriptionID, clusterSubscriptionID)}
+}
+
+// azureGallerySubscriptionID returns the subscription of a shared image gallery image resource ID,
+// for example "sub" for "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/galleries/gallery/images/rhcos/versions/1.0.0".
+// An empty string is returned when the resource ID is not a gallery image.
+func azureGallerySubscriptionID(resourceID string) string {
+	parts := strings.Split(strings.Trim(resourceID, "/"), "/")
+	if len(parts) < 2 || !strings.EqualFold(parts[0], "subscriptions") {
+		return ""
+	}
+	for i := 2; i < len(parts)-1; i++ {
+		if strings.EqualFold(parts[i], "galleries") {
+			return parts[1]
+		}
+	}
+	return ""
+}
+
+// validateGCPImageAccess warns when a boot disk image lives outside of the cluster's project, but the
+// service account scopes do not allow the instance to read from Cloud Storage.
+// IAM cannot be checked from the webhook, so this only catches the missing prerequisite.
+func validateGCPImageAccess(providerSpec *machinev1.GCPMachineProviderSpec, clusterProjectID string) []string {
+	var warnings []string
+	disksPath := field.NewPath("providerSpec", "disks")
+	serviceAccountsPath := field.NewPath("providerSpec", "serviceAccounts")
+	for i, disk := range providerSpec.Disks {
+		if disk == nil || !disk.Boot {
+			continue
+		}
+
+		imageProject := gcpImageProject(disk.Image)
+		if imageProject == "" || imageProject == clusterProjectID {
+			continue
+		}
+
+		for j, serviceAccount := range providerSpec.ServiceAccounts {
+			if gcpStorageReadScopes.HasAny(serviceAccount.Scopes...) {
+				continue
+			}
+			warnings = append(warnings, fmt.Sprintf("%s: image %q of %s is in project %q, but the scopes of service account %q do not grant read access to it: add the %s or %s scope", serviceAccountsPath.Index(j).Child("scopes"), disk.Image, disksPath.Index(i), imageProject, serviceAccount.Email, gcpDevstorageReadOnlyScope, gcpCloudPlatformScope))
+		}
+	}
+	return warnings
+}
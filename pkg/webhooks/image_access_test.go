@@ -0,0 +1,177 @@
+package webhooks
+
+import (
+	"reflect"
+	"testing"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestValidateAzureImageAccess(t *testing.T) {
+	const galleryImage = "/subscriptions/gallery-subscription/resourceGroups/rg/providers/Microsoft.Compute/galleries/gallery/images/rhcos/versions/1.0.0"
+
+	testCases := []struct {
+		testCase         string
+		resourceID       string
+		managedIdentity  string
+		subscriptionID   string
+		expectedWarnings []string
+	}{
+		{
+			testCase:         "with a gallery image in the cluster's subscription",
+			resourceID:       galleryImage,
+			subscriptionID:   "gallery-subscription",
+			expectedWarnings: nil,
+		},
+		{
+			testCase:         "with a gallery image in the cluster's subscription with different case",
+			resourceID:       galleryImage,
+			subscriptionID:   "Gallery-Subscription",
+			expectedWarnings: nil,
+		},
+		{
+			testCase:       "with a gallery image in another subscription",
+			resourceID:     galleryImage,
+			subscriptionID: "cluster-subscription",
+			expectedWarnings: []string{
+				"providerSpec.image.resourceID: image \"/subscriptions/gallery-subscription/resourceGroups/rg/providers/Microsoft.Compute/galleries/gallery/images/rhcos/versions/1.0.0\" is in a gallery of subscription \"gallery-subscription\", outside of the cluster's subscription \"cluster-subscription\", and no managedIdentity is set: the machine may be unable to pull the image on a disconnected cluster, set providerSpec.managedIdentity to an identity with read access to the gallery",
+			},
+		},
+		{
+			testCase:         "with a gallery image in another subscription and a managed identity",
+			resourceID:       galleryImage,
+			managedIdentity:  "identity",
+			subscriptionID:   "cluster-subscription",
+			expectedWarnings: nil,
+		},
+		{
+			testCase:         "with a managed image in another subscription",
+			resourceID:       "/subscriptions/image-subscription/resourceGroups/rg/providers/Microsoft.Compute/images/rhcos",
+			subscriptionID:   "cluster-subscription",
+			expectedWarnings: nil,
+		},
+		{
+			testCase:         "with a marketplace image",
+			subscriptionID:   "cluster-subscription",
+			expectedWarnings: nil,
+		},
+		{
+			testCase:         "without a subscription in the credentials",
+			resourceID:       galleryImage,
+			expectedWarnings: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "credentials",
+					Namespace: "namespace",
+				},
+				Data: map[string][]byte{},
+			}
+			if tc.subscriptionID != "" {
+				secret.Data[azureSubscriptionIDKey] = []byte(tc.subscriptionID)
+			}
+			c := fake.NewFakeClientWithScheme(scheme.Scheme, secret)
+
+			providerSpec := &machinev1.AzureMachineProviderSpec{
+				Image:           machinev1.Image{ResourceID: tc.resourceID},
+				ManagedIdentity: tc.managedIdentity,
+				CredentialsSecret: &corev1.SecretReference{
+					Name:      "credentials",
+					Namespace: "namespace",
+				},
+			}
+
+			warnings := validateAzureImageAccess(c, providerSpec)
+			if !reflect.DeepEqual(warnings, tc.expectedWarnings) {
+				t.Errorf("expected: %q, got: %q", tc.expectedWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestValidateGCPImageAccess(t *testing.T) {
+	testCases := []struct {
+		testCase         string
+		image            string
+		boot             bool
+		scopes           []string
+		expectedWarnings []string
+	}{
+		{
+			testCase:         "with an image in another project and the cloud-platform scope",
+			image:            "projects/image-project/global/images/rhcos",
+			boot:             true,
+			scopes:           []string{gcpCloudPlatformScope},
+			expectedWarnings: nil,
+		},
+		{
+			testCase:         "with an image in another project and the devstorage.read_only scope",
+			image:            "projects/image-project/global/images/rhcos",
+			boot:             true,
+			scopes:           []string{"https://www.googleapis.com/auth/compute", gcpDevstorageReadOnlyScope},
+			expectedWarnings: nil,
+		},
+		{
+			testCase: "with an image in another project without a storage scope",
+			image:    "projects/image-project/global/images/rhcos",
+			boot:     true,
+			scopes:   []string{"https://www.googleapis.com/auth/compute"},
+			expectedWarnings: []string{
+				"providerSpec.serviceAccounts[0].scopes: image \"projects/image-project/global/images/rhcos\" of providerSpec.disks[0] is in project \"image-project\", but the scopes of service account \"email\" do not grant read access to it: add the https://www.googleapis.com/auth/devstorage.read_only or https://www.googleapis.com/auth/cloud-platform scope",
+			},
+		},
+		{
+			testCase:         "with an image in the cluster's project without a storage scope",
+			image:            "projects/projectID/global/images/rhcos",
+			boot:             true,
+			scopes:           []string{"https://www.googleapis.com/auth/compute"},
+			expectedWarnings: nil,
+		},
+		{
+			testCase:         "with an image name without a storage scope",
+			image:            "rhcos",
+			boot:             true,
+			scopes:           []string{"https://www.googleapis.com/auth/compute"},
+			expectedWarnings: nil,
+		},
+		{
+			testCase:         "with an image in another project on a non boot disk without a storage scope",
+			image:            "projects/image-project/global/images/rhcos",
+			boot:             false,
+			scopes:           []string{"https://www.googleapis.com/auth/compute"},
+			expectedWarnings: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			providerSpec := &machinev1.GCPMachineProviderSpec{
+				Disks: []*machinev1.GCPDisk{
+					{
+						Boot:  tc.boot,
+						Image: tc.image,
+					},
+				},
+				ServiceAccounts: []machinev1.GCPServiceAccount{
+					{
+						Email:  "email",
+						Scopes: tc.scopes,
+					},
+				},
+			}
+
+			warnings := validateGCPImageAccess(providerSpec, "projectID")
+			if !reflect.DeepEqual(warnings, tc.expectedWarnings) {
+				t.Errorf("expected: %q, got: %q", tc.expectedWarnings, warnings)
+			}
+		})
+	}
+}
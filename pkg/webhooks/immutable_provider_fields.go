@@ -0,0 +1,87 @@
+package webhooks
+
+import (
+	"fmt"
+	"strings"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/yaml"
+)
+
+// allowProviderSpecMutationAnnotation, when set to true on a Machine, allows changes to the providerSpec
+// fields which cannot be applied to the instance of the Machine.
+const allowProviderSpecMutationAnnotation = "machine.openshift.io/allow-provider-spec-mutation"
+
+// immutableProviderSpecFields are the providerSpec fields placing the instance, per platform. Changing them
+// once the instance is created does not move the instance.
+var immutableProviderSpecFields = map[osconfigv1.PlatformType][][]string{
+	osconfigv1.AWSPlatformType:     {{"placement", "region"}, {"placement", "availabilityZone"}},
+	osconfigv1.AzurePlatformType:   {{"location"}},
+	osconfigv1.GCPPlatformType:     {{"zone"}},
+	osconfigv1.VSpherePlatformType: {{"workspace", "datacenter"}},
+}
+
+// machineInstanceCreated returns whether the instance of the Machine has been created.
+func machineInstanceCreated(m *machinev1.Machine) bool {
+	if m.Spec.ProviderID != nil && *m.Spec.ProviderID != "" {
+		return true
+	}
+	if m.Status.Phase == nil {
+		return false
+	}
+	switch *m.Status.Phase {
+	case "Provisioned", "Running":
+		return true
+	}
+	return false
+}
+
+// validateImmutableProviderSpecFields denies changes to the providerSpec fields placing the instance once
+// it is created, as they would silently not be applied. The allowProviderSpecMutationAnnotation allows
+// the changes, with a warning.
+func validateImmutableProviderSpecFields(m, oldM *machinev1.Machine, clusterPlatform osconfigv1.PlatformType) ([]string, []error) {
+	if oldM == nil || isDeleting(oldM) || !machineInstanceCreated(oldM) || !providerSpecChanged(oldM.Spec.ProviderSpec.Value, m.Spec.ProviderSpec.Value) {
+		return nil, nil
+	}
+
+	platform := providerSpecPlatform(oldM)
+	if platform == "" {
+		platform = clusterPlatform
+	}
+	paths, ok := immutableProviderSpecFields[platform]
+	if !ok || m.Spec.ProviderSpec.Value == nil || oldM.Spec.ProviderSpec.Value == nil {
+		return nil, nil
+	}
+
+	oldFields, fields := map[string]interface{}{}, map[string]interface{}{}
+	if err := yaml.Unmarshal(oldM.Spec.ProviderSpec.Value.Raw, &oldFields); err != nil {
+		return nil, nil
+	}
+	if err := yaml.Unmarshal(m.Spec.ProviderSpec.Value.Raw, &fields); err != nil {
+		// The providerSpec is validated by the platform rules.
+		return nil, nil
+	}
+
+	allowed := m.Annotations[allowProviderSpecMutationAnnotation] == "true"
+	var warnings []string
+	var errs []error
+	for _, path := range paths {
+		oldValue, _, _ := unstructured.NestedFieldNoCopy(oldFields, path...)
+		value, _, _ := unstructured.NestedFieldNoCopy(fields, path...)
+		if equality.Semantic.DeepEqual(oldValue, value) {
+			continue
+		}
+
+		fieldPath := field.NewPath("providerSpec", path...)
+		if allowed {
+			warnings = append(warnings, fmt.Sprintf("%s: changed from %v to %v: the change is not applied to the existing instance", fieldPath, oldValue, value))
+			continue
+		}
+		errs = append(errs, field.Forbidden(fieldPath, fmt.Sprintf("%s cannot be changed once the instance is created, the change would not be applied to it: set the %s annotation to true to allow it", strings.Join(path, "."), allowProviderSpecMutationAnnotation)))
+	}
+	return warnings, errs
+}
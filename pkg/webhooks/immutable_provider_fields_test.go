@@ -0,0 +1,158 @@
+package webhooks
+
+import (
+	"reflect"
+	"testing"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kruntime "k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/utils/pointer"
+)
+
+func TestValidateImmutableProviderSpecFields(t *testing.T) {
+	awsProviderSpec := `{"kind":"AWSMachineProviderConfig","instanceType":"m5.large","placement":{"region":"us-east-1","availabilityZone":"us-east-1a"}}`
+	created := machinev1.MachineStatus{Phase: pointer.StringPtr("Running")}
+
+	testCases := []struct {
+		testCase         string
+		clusterPlatform  osconfigv1.PlatformType
+		oldProviderSpec  string
+		providerSpec     string
+		oldStatus        machinev1.MachineStatus
+		providerID       *string
+		annotations      map[string]string
+		deleting         bool
+		expectedError    string
+		expectedWarnings []string
+	}{
+		{
+			testCase:        "with an unchanged providerSpec",
+			clusterPlatform: osconfigv1.AWSPlatformType,
+			oldProviderSpec: awsProviderSpec,
+			providerSpec:    awsProviderSpec,
+			oldStatus:       created,
+		},
+		{
+			testCase:        "with a mutable field changed",
+			clusterPlatform: osconfigv1.AWSPlatformType,
+			oldProviderSpec: awsProviderSpec,
+			providerSpec:    `{"kind":"AWSMachineProviderConfig","instanceType":"m5.xlarge","placement":{"region":"us-east-1","availabilityZone":"us-east-1a"}}`,
+			oldStatus:       created,
+		},
+		{
+			testCase:        "with the AWS region and availability zone changed",
+			clusterPlatform: osconfigv1.AWSPlatformType,
+			oldProviderSpec: awsProviderSpec,
+			providerSpec:    `{"kind":"AWSMachineProviderConfig","instanceType":"m5.large","placement":{"region":"us-west-2","availabilityZone":"us-west-2a"}}`,
+			oldStatus:       created,
+			expectedError:   "[providerSpec.placement.region: Forbidden: placement.region cannot be changed once the instance is created, the change would not be applied to it: set the machine.openshift.io/allow-provider-spec-mutation annotation to true to allow it, providerSpec.placement.availabilityZone: Forbidden: placement.availabilityZone cannot be changed once the instance is created, the change would not be applied to it: set the machine.openshift.io/allow-provider-spec-mutation annotation to true to allow it]",
+		},
+		{
+			testCase:        "with the AWS availability zone changed before the instance is created",
+			clusterPlatform: osconfigv1.AWSPlatformType,
+			oldProviderSpec: awsProviderSpec,
+			providerSpec:    `{"kind":"AWSMachineProviderConfig","instanceType":"m5.large","placement":{"region":"us-east-1","availabilityZone":"us-east-1b"}}`,
+		},
+		{
+			testCase:        "with the AWS availability zone changed on a machine with a provider ID",
+			clusterPlatform: osconfigv1.AWSPlatformType,
+			oldProviderSpec: awsProviderSpec,
+			providerSpec:    `{"kind":"AWSMachineProviderConfig","instanceType":"m5.large","placement":{"region":"us-east-1","availabilityZone":"us-east-1b"}}`,
+			providerID:      pointer.StringPtr("aws:///us-east-1a/i-0123456789"),
+			expectedError:   "providerSpec.placement.availabilityZone: Forbidden: placement.availabilityZone cannot be changed once the instance is created, the change would not be applied to it: set the machine.openshift.io/allow-provider-spec-mutation annotation to true to allow it",
+		},
+		{
+			testCase:        "with the AWS availability zone changed and the opt-out annotation",
+			clusterPlatform: osconfigv1.AWSPlatformType,
+			oldProviderSpec: awsProviderSpec,
+			providerSpec:    `{"kind":"AWSMachineProviderConfig","instanceType":"m5.large","placement":{"region":"us-east-1","availabilityZone":"us-east-1b"}}`,
+			oldStatus:       created,
+			annotations:     map[string]string{allowProviderSpecMutationAnnotation: "true"},
+			expectedWarnings: []string{
+				"providerSpec.placement.availabilityZone: changed from us-east-1a to us-east-1b: the change is not applied to the existing instance",
+			},
+		},
+		{
+			testCase:        "with the AWS availability zone changed on a deleting machine",
+			clusterPlatform: osconfigv1.AWSPlatformType,
+			oldProviderSpec: awsProviderSpec,
+			providerSpec:    `{"kind":"AWSMachineProviderConfig","instanceType":"m5.large","placement":{"region":"us-east-1","availabilityZone":"us-east-1b"}}`,
+			oldStatus:       created,
+			deleting:        true,
+		},
+		{
+			testCase:        "with the Azure location changed",
+			clusterPlatform: osconfigv1.AzurePlatformType,
+			oldProviderSpec: `{"location":"centralus","vmSize":"Standard_D4s_v3"}`,
+			providerSpec:    `{"location":"eastus","vmSize":"Standard_D4s_v3"}`,
+			oldStatus:       created,
+			expectedError:   "providerSpec.location: Forbidden: location cannot be changed once the instance is created, the change would not be applied to it: set the machine.openshift.io/allow-provider-spec-mutation annotation to true to allow it",
+		},
+		{
+			testCase:        "with the GCP zone changed",
+			clusterPlatform: osconfigv1.GCPPlatformType,
+			oldProviderSpec: `{"zone":"us-east1-b"}`,
+			providerSpec:    `{"zone":"us-east1-c"}`,
+			oldStatus:       created,
+			expectedError:   "providerSpec.zone: Forbidden: zone cannot be changed once the instance is created, the change would not be applied to it: set the machine.openshift.io/allow-provider-spec-mutation annotation to true to allow it",
+		},
+		{
+			testCase:        "with the vSphere datacenter removed",
+			clusterPlatform: osconfigv1.VSpherePlatformType,
+			oldProviderSpec: `{"workspace":{"datacenter":"dc1","server":"vcenter"}}`,
+			providerSpec:    `{"workspace":{"server":"vcenter"}}`,
+			oldStatus:       created,
+			expectedError:   "providerSpec.workspace.datacenter: Forbidden: workspace.datacenter cannot be changed once the instance is created, the change would not be applied to it: set the machine.openshift.io/allow-provider-spec-mutation annotation to true to allow it",
+		},
+		{
+			testCase:        "with the providerSpec kind naming another platform than the cluster",
+			clusterPlatform: osconfigv1.GCPPlatformType,
+			oldProviderSpec: awsProviderSpec,
+			providerSpec:    `{"kind":"AWSMachineProviderConfig","instanceType":"m5.large","placement":{"region":"us-east-1","availabilityZone":"us-east-1b"}}`,
+			oldStatus:       created,
+			expectedError:   "providerSpec.placement.availabilityZone: Forbidden: placement.availabilityZone cannot be changed once the instance is created, the change would not be applied to it: set the machine.openshift.io/allow-provider-spec-mutation annotation to true to allow it",
+		},
+		{
+			testCase:        "on a platform without immutable fields",
+			clusterPlatform: osconfigv1.OpenStackPlatformType,
+			oldProviderSpec: `{"availabilityZone":"nova"}`,
+			providerSpec:    `{"availabilityZone":"other"}`,
+			oldStatus:       created,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			oldM := &machinev1.Machine{
+				Spec: machinev1.MachineSpec{
+					ProviderID:   tc.providerID,
+					ProviderSpec: machinev1.ProviderSpec{Value: &kruntime.RawExtension{Raw: []byte(tc.oldProviderSpec)}},
+				},
+				Status: tc.oldStatus,
+			}
+			if tc.deleting {
+				now := metav1.Now()
+				oldM.DeletionTimestamp = &now
+			}
+			m := oldM.DeepCopy()
+			m.Annotations = tc.annotations
+			m.Spec.ProviderSpec.Value = &kruntime.RawExtension{Raw: []byte(tc.providerSpec)}
+
+			warnings, errs := validateImmutableProviderSpecFields(m, oldM, tc.clusterPlatform)
+
+			var errString string
+			if len(errs) > 0 {
+				errString = utilerrors.NewAggregate(errs).Error()
+			}
+			if errString != tc.expectedError {
+				t.Errorf("expected error: %q, got: %q", tc.expectedError, errString)
+			}
+			if !reflect.DeepEqual(warnings, tc.expectedWarnings) {
+				t.Errorf("expected warnings: %q, got: %q", tc.expectedWarnings, warnings)
+			}
+		})
+	}
+}
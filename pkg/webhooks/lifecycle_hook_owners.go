@@ -0,0 +1,101 @@
+package webhooks
+
+import (
+	"errors"
+	"strings"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/lifecyclehooks"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+)
+
+// allowedLifecycleHookOwnersAnnotation is the Machine annotation listing, comma separated, the owners
+// allowed to add lifecycle hooks to the Machine. It may only restrict the owners allowed by
+// lifecycleHooksAllowedOwnersKey further.
+const allowedLifecycleHookOwnersAnnotation = "machine.openshift.io/allowed-lifecycle-hook-owners"
+
+// parseLifecycleHookOwners returns the owners of the comma separated list.
+func parseLifecycleHookOwners(value string) (sets.String, error) {
+	owners := sets.NewString()
+	for _, owner := range strings.Split(value, ",") {
+		owner = strings.TrimSpace(owner)
+		if owner == "" {
+			return nil, errors.New("expected a comma separated list of owners")
+		}
+		owners.Insert(owner)
+	}
+	return owners, nil
+}
+
+// lifecycleHookOwnersFromPolicy returns the lifecycle hook owners allowed by the policy ConfigMap,
+// or nil when the ConfigMap does not exist or does not set a valid list, allowing any owner.
+func lifecycleHookOwnersFromPolicy(policy *corev1.ConfigMap) sets.String {
+	if policy == nil {
+		return nil
+	}
+
+	value, ok := policy.Data[lifecycleHooksAllowedOwnersKey]
+	if !ok {
+		return nil
+	}
+
+	owners, err := parseLifecycleHookOwners(value)
+	if err != nil {
+		klog.Errorf("Ignoring %s in ConfigMap %s/%s: invalid value %q: %v", lifecycleHooksAllowedOwnersKey, policy.GetNamespace(), policy.GetName(), value, err)
+		return nil
+	}
+	return owners
+}
+
+// validateLifecycleHookOwners denies lifecycle hooks added or changed by owners which are not allowed,
+// either by the cluster policy or by the allowedLifecycleHookOwnersAnnotation of the Machine.
+// Existing hooks are left alone, so that a policy change does not block updates to the Machine.
+func validateLifecycleHookOwners(m, oldM *machinev1.Machine, clusterOwners sets.String, path *field.Path) []error {
+	// Changes to the hooks of a deleting machine are forbidden by validateMachineLifecycleHooks.
+	if oldM != nil && isDeleting(m) {
+		return nil
+	}
+
+	allowed := clusterOwners
+	if value, ok := m.Annotations[allowedLifecycleHookOwnersAnnotation]; ok {
+		owners, err := parseLifecycleHookOwners(value)
+		if err != nil {
+			return []error{field.Invalid(field.NewPath("metadata", "annotations", allowedLifecycleHookOwnersAnnotation), value, err.Error())}
+		}
+		if allowed != nil {
+			owners = owners.Intersection(allowed)
+		}
+		allowed = owners
+	}
+	if allowed == nil {
+		return nil
+	}
+
+	var oldHooks machinev1.LifecycleHooks
+	if oldM != nil {
+		oldHooks = oldM.Spec.LifecycleHooks
+	}
+
+	var errs []error
+	for _, hooks := range []struct {
+		old, new []machinev1.LifecycleHook
+		path     *field.Path
+	}{
+		{old: oldHooks.PreDrain, new: m.Spec.LifecycleHooks.PreDrain, path: path.Child("preDrain")},
+		{old: oldHooks.PreTerminate, new: m.Spec.LifecycleHooks.PreTerminate, path: path.Child("preTerminate")},
+	} {
+		changed := sets.NewString()
+		for _, hook := range lifecyclehooks.GetChangedLifecycleHooks(hooks.old, hooks.new) {
+			changed.Insert(hook.Name)
+		}
+		for i, hook := range hooks.new {
+			if changed.Has(hook.Name) && !allowed.Has(hook.Owner) {
+				errs = append(errs, field.NotSupported(hooks.path.Index(i).Child("owner"), hook.Owner, allowed.List()))
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,151 @@
+package webhooks
+
+import (
+	"testing"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestLifecycleHookOwnersFromPolicy(t *testing.T) {
+	testCases := []struct {
+		testCase       string
+		policy         *corev1.ConfigMap
+		expectedOwners sets.String
+		invalid        bool
+	}{
+		{
+			testCase: "without a policy",
+		},
+		{
+			testCase: "without the key",
+			policy:   &corev1.ConfigMap{Data: map[string]string{}},
+		},
+		{
+			testCase:       "with a list of owners",
+			policy:         &corev1.ConfigMap{Data: map[string]string{lifecycleHooksAllowedOwnersKey: "etcd-operator, storage-operator"}},
+			expectedOwners: sets.NewString("etcd-operator", "storage-operator"),
+		},
+		{
+			testCase: "with an empty owner",
+			policy:   &corev1.ConfigMap{Data: map[string]string{lifecycleHooksAllowedOwnersKey: "etcd-operator,,"}},
+			invalid:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			owners := lifecycleHookOwnersFromPolicy(tc.policy)
+			if !owners.Equal(tc.expectedOwners) {
+				t.Errorf("expected owners: %v, got: %v", tc.expectedOwners.List(), owners.List())
+			}
+			if tc.policy != nil {
+				if err := validateValidationPolicy(tc.policy); (err != nil) != tc.invalid {
+					t.Errorf("expected the policy to be invalid: %v, got: %v", tc.invalid, err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateLifecycleHookOwners(t *testing.T) {
+	etcdHook := machinev1.LifecycleHook{Name: "etcd-quorum", Owner: "etcd-operator"}
+	unknownHook := machinev1.LifecycleHook{Name: "backup", Owner: "backup-controller"}
+	clusterOwners := sets.NewString("etcd-operator", "storage-operator")
+
+	testCases := []struct {
+		testCase      string
+		clusterOwners sets.String
+		annotations   map[string]string
+		oldHooks      *machinev1.LifecycleHooks
+		hooks         machinev1.LifecycleHooks
+		deleting      bool
+		expectedError string
+	}{
+		{
+			testCase: "without an allow-list",
+			hooks:    machinev1.LifecycleHooks{PreDrain: []machinev1.LifecycleHook{unknownHook}},
+		},
+		{
+			testCase:      "with an allowed owner on create",
+			clusterOwners: clusterOwners,
+			hooks:         machinev1.LifecycleHooks{PreDrain: []machinev1.LifecycleHook{etcdHook}},
+		},
+		{
+			testCase:      "with an unknown owner on create",
+			clusterOwners: clusterOwners,
+			hooks:         machinev1.LifecycleHooks{PreDrain: []machinev1.LifecycleHook{etcdHook}, PreTerminate: []machinev1.LifecycleHook{unknownHook}},
+			expectedError: `spec.lifecycleHooks.preTerminate[0].owner: Unsupported value: "backup-controller": supported values: "etcd-operator", "storage-operator"`,
+		},
+		{
+			testCase:      "with an unknown owner added on update",
+			clusterOwners: clusterOwners,
+			oldHooks:      &machinev1.LifecycleHooks{PreDrain: []machinev1.LifecycleHook{etcdHook}},
+			hooks:         machinev1.LifecycleHooks{PreDrain: []machinev1.LifecycleHook{etcdHook, unknownHook}},
+			expectedError: `spec.lifecycleHooks.preDrain[1].owner: Unsupported value: "backup-controller": supported values: "etcd-operator", "storage-operator"`,
+		},
+		{
+			testCase:      "with an existing hook of an unknown owner",
+			clusterOwners: clusterOwners,
+			oldHooks:      &machinev1.LifecycleHooks{PreDrain: []machinev1.LifecycleHook{unknownHook}},
+			hooks:         machinev1.LifecycleHooks{PreDrain: []machinev1.LifecycleHook{unknownHook, etcdHook}},
+		},
+		{
+			testCase:      "with an allowed owner restricted by the annotation",
+			clusterOwners: clusterOwners,
+			annotations:   map[string]string{allowedLifecycleHookOwnersAnnotation: "storage-operator,backup-controller"},
+			hooks:         machinev1.LifecycleHooks{PreDrain: []machinev1.LifecycleHook{etcdHook, unknownHook}},
+			expectedError: `[spec.lifecycleHooks.preDrain[0].owner: Unsupported value: "etcd-operator": supported values: "storage-operator", spec.lifecycleHooks.preDrain[1].owner: Unsupported value: "backup-controller": supported values: "storage-operator"]`,
+		},
+		{
+			testCase:    "with the annotation only",
+			annotations: map[string]string{allowedLifecycleHookOwnersAnnotation: "backup-controller"},
+			hooks:       machinev1.LifecycleHooks{PreDrain: []machinev1.LifecycleHook{unknownHook}},
+		},
+		{
+			testCase:      "with an invalid annotation",
+			annotations:   map[string]string{allowedLifecycleHookOwnersAnnotation: ""},
+			hooks:         machinev1.LifecycleHooks{PreDrain: []machinev1.LifecycleHook{unknownHook}},
+			expectedError: `metadata.annotations.machine.openshift.io/allowed-lifecycle-hook-owners: Invalid value: "": expected a comma separated list of owners`,
+		},
+		{
+			testCase:      "on a deleting machine",
+			clusterOwners: clusterOwners,
+			oldHooks:      &machinev1.LifecycleHooks{},
+			hooks:         machinev1.LifecycleHooks{PreDrain: []machinev1.LifecycleHook{unknownHook}},
+			deleting:      true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			m := &machinev1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Annotations: tc.annotations},
+				Spec:       machinev1.MachineSpec{LifecycleHooks: tc.hooks},
+			}
+			if tc.deleting {
+				now := metav1.Now()
+				m.DeletionTimestamp = &now
+			}
+			var oldM *machinev1.Machine
+			if tc.oldHooks != nil {
+				oldM = m.DeepCopy()
+				oldM.Spec.LifecycleHooks = *tc.oldHooks
+			}
+
+			errs := validateLifecycleHookOwners(m, oldM, tc.clusterOwners, field.NewPath("spec", "lifecycleHooks"))
+
+			var errString string
+			if len(errs) > 0 {
+				errString = utilerrors.NewAggregate(errs).Error()
+			}
+			if errString != tc.expectedError {
+				t.Errorf("expected error: %q, got: %q", tc.expectedError, errString)
+			}
+		})
+	}
+}
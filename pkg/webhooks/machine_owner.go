@@ -0,0 +1,53 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// orphanIntentAnnotation is set on machines which are knowingly created for a MachineSet being deleted,
+	// to be orphaned and adopted or cleaned up separately.
+	orphanIntentAnnotation = "machine.openshift.io/orphan-intent"
+
+	// garbageCollectorUsername is the user the Kubernetes garbage collector acts as.
+	garbageCollectorUsername = "system:serviceaccount:kube-system:generic-garbage-collector"
+)
+
+// validateMachineSetOwner denies the creation of a machine controlled by a MachineSet which is being deleted.
+// Such machines are left behind as orphans once the MachineSet is gone.
+// Requests from the garbage collector and machines with the orphan intent annotation are always allowed,
+// as are machines whose MachineSet cannot be found.
+func validateMachineSetOwner(c client.Client, m *machinev1.Machine, username string) error {
+	if username == garbageCollectorUsername {
+		return nil
+	}
+	if _, ok := m.Annotations[orphanIntentAnnotation]; ok {
+		return nil
+	}
+
+	ref := metav1.GetControllerOf(m)
+	if ref == nil || ref.Kind != "MachineSet" {
+		return nil
+	}
+
+	ms := &machinev1.MachineSet{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: m.GetNamespace(), Name: ref.Name}, ms); err != nil {
+		if !apierrors.IsNotFound(err) {
+			klog.V(3).Infof("Unable to check MachineSet %s/%s owning Machine %s: %v", m.GetNamespace(), ref.Name, m.GetName(), err)
+		}
+		return nil
+	}
+	if ms.UID != ref.UID || ms.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	return field.Forbidden(field.NewPath("metadata", "ownerReferences"), fmt.Sprintf("MachineSet %q is being deleted: machines cannot be added to it, set the %s annotation to create an orphaned machine", ms.GetName(), orphanIntentAnnotation))
+}
@@ -0,0 +1,215 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// testFinalizer keeps the MachineSets of the tests being deleted, as the garbage collector does while it deletes
+// their machines in the foreground.
+const testFinalizer = "machine.openshift.io/test"
+
+// createMachineSets creates a MachineSet and a MachineSet being deleted in the namespace, and returns them with
+// a function removing them.
+func createMachineSets(g *WithT, namespace string) (*machinev1.MachineSet, *machinev1.MachineSet, func()) {
+	machineSet := &machinev1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "machineset", Namespace: namespace},
+	}
+	deletingMachineSet := &machinev1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "deleting", Namespace: namespace, Finalizers: []string{testFinalizer}},
+	}
+	g.Expect(c.Create(ctx, machineSet)).To(Succeed())
+	g.Expect(c.Create(ctx, deletingMachineSet)).To(Succeed())
+	g.Expect(c.Delete(ctx, deletingMachineSet)).To(Succeed())
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(deletingMachineSet), deletingMachineSet)).To(Succeed())
+	g.Expect(deletingMachineSet.DeletionTimestamp).ToNot(BeNil())
+
+	return machineSet, deletingMachineSet, func() {
+		patch := client.MergeFrom(deletingMachineSet.DeepCopy())
+		deletingMachineSet.Finalizers = nil
+		g.Expect(c.Patch(ctx, deletingMachineSet, patch)).To(Succeed())
+		g.Expect(c.Delete(ctx, machineSet)).To(Succeed())
+	}
+}
+
+func TestValidateMachineSetOwner(t *testing.T) {
+	g := NewWithT(t)
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "machineset-owner-test",
+		},
+	}
+	g.Expect(c.Create(ctx, namespace)).To(Succeed())
+	defer func() {
+		g.Expect(c.Delete(ctx, namespace)).To(Succeed())
+	}()
+
+	machineSet, deletingMachineSet, cleanup := createMachineSets(g, namespace.Name)
+	defer cleanup()
+
+	ownedBy := func(ms *machinev1.MachineSet, controller bool) []metav1.OwnerReference {
+		ref := *metav1.NewControllerRef(ms, machinev1.SchemeGroupVersion.WithKind("MachineSet"))
+		ref.Controller = &controller
+		return []metav1.OwnerReference{ref}
+	}
+
+	testCases := []struct {
+		testCase    string
+		owners      []metav1.OwnerReference
+		annotations map[string]string
+		username    string
+		expectedErr string
+	}{
+		{
+			testCase: "without an owner",
+		},
+		{
+			testCase: "with an owner which is not being deleted",
+			owners:   ownedBy(machineSet, true),
+		},
+		{
+			testCase:    "with an owner which is being deleted",
+			owners:      ownedBy(deletingMachineSet, true),
+			expectedErr: "metadata.ownerReferences: Forbidden: MachineSet \"deleting\" is being deleted: machines cannot be added to it, set the machine.openshift.io/orphan-intent annotation to create an orphaned machine",
+		},
+		{
+			testCase: "with a non-controller owner which is being deleted",
+			owners:   ownedBy(deletingMachineSet, false),
+		},
+		{
+			testCase: "with an owner which does not exist",
+			owners: []metav1.OwnerReference{
+				*metav1.NewControllerRef(&machinev1.MachineSet{ObjectMeta: metav1.ObjectMeta{Name: "missing", UID: "missing-uid"}}, machinev1.SchemeGroupVersion.WithKind("MachineSet")),
+			},
+		},
+		{
+			testCase: "with an owner which is being deleted and was recreated",
+			owners: []metav1.OwnerReference{
+				*metav1.NewControllerRef(&machinev1.MachineSet{ObjectMeta: metav1.ObjectMeta{Name: "deleting", UID: "previous-uid"}}, machinev1.SchemeGroupVersion.WithKind("MachineSet")),
+			},
+		},
+		{
+			testCase:    "with an owner which is being deleted and the orphan intent annotation",
+			owners:      ownedBy(deletingMachineSet, true),
+			annotations: map[string]string{orphanIntentAnnotation: ""},
+		},
+		{
+			testCase: "with an owner which is being deleted and a request from the garbage collector",
+			owners:   ownedBy(deletingMachineSet, true),
+			username: garbageCollectorUsername,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			m := &machinev1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "machine",
+					Namespace:       namespace.Name,
+					OwnerReferences: tc.owners,
+					Annotations:     tc.annotations,
+				},
+			}
+
+			err := validateMachineSetOwner(c, m, tc.username)
+			if tc.expectedErr == "" {
+				if err != nil {
+					t.Errorf("expected no error, got: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.expectedErr {
+				t.Errorf("expected error %q, got: %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestMachineCreationWithMachineSetOwner(t *testing.T) {
+	g := NewWithT(t)
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "machineset-owner-creation-test",
+		},
+	}
+	g.Expect(c.Create(ctx, namespace)).To(Succeed())
+	defer func() {
+		g.Expect(c.Delete(ctx, namespace)).To(Succeed())
+	}()
+
+	machineSet, deletingMachineSet, cleanup := createMachineSets(g, namespace.Name)
+	defer cleanup()
+
+	mgr, err := manager.New(cfg, manager.Options{
+		MetricsBindAddress: "0",
+		Port:               testEnv.WebhookInstallOptions.LocalServingPort,
+		CertDir:            testEnv.WebhookInstallOptions.LocalServingCertDir,
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	machineValidator := createMachineValidator(plainInfra, c, plainDNS)
+	mgr.GetWebhookServer().Register(DefaultMachineValidatingHookPath, &webhook.Admission{Handler: machineValidator})
+
+	mgrCtx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		g.Expect(mgr.Start(mgrCtx)).To(Succeed())
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	g.Eventually(func() (bool, error) {
+		resp, err := insecureHTTPClient.Get(fmt.Sprintf("https://127.0.0.1:%d", testEnv.WebhookInstallOptions.LocalServingPort))
+		if err != nil {
+			return false, err
+		}
+		return resp.StatusCode == 404, nil
+	}).Should(BeTrue())
+
+	newMachine := func(ms *machinev1.MachineSet) *machinev1.Machine {
+		return &machinev1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName:    ms.Name + "-",
+				Namespace:       namespace.Name,
+				OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(ms, machinev1.SchemeGroupVersion.WithKind("MachineSet"))},
+			},
+		}
+	}
+
+	// The machineset controller keeps creating the machines of MachineSets which are not being deleted.
+	m := newMachine(machineSet)
+	g.Expect(c.Create(ctx, m)).To(Succeed())
+	defer func() {
+		g.Expect(c.Delete(ctx, m)).To(Succeed())
+	}()
+
+	err = c.Create(ctx, newMachine(deletingMachineSet))
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(string(apierrors.ReasonForError(err))).To(ContainSubstring("MachineSet \"deleting\" is being deleted"))
+
+	// Machines which exist already may still be updated, e.g. to remove finalizers.
+	orphan := newMachine(deletingMachineSet)
+	orphan.Annotations = map[string]string{orphanIntentAnnotation: ""}
+	g.Expect(c.Create(ctx, orphan)).To(Succeed())
+	defer func() {
+		g.Expect(c.Delete(ctx, orphan)).To(Succeed())
+	}()
+	patch := client.MergeFrom(orphan.DeepCopy())
+	orphan.Annotations = nil
+	g.Expect(c.Patch(ctx, orphan, patch)).To(Succeed())
+}
@@ -1,19 +1,23 @@
 package webhooks
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"runtime"
+	"strconv"
 	"strings"
 
 	osconfigv1 "github.com/openshift/api/config/v1"
 	machinev1 "github.com/openshift/api/machine/v1beta1"
-	osclientset "github.com/openshift/client-go/config/clientset/versioned"
+	"github.com/openshift/machine-api-operator/pkg/util/annotations"
 	"github.com/openshift/machine-api-operator/pkg/util/lifecyclehooks"
+	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	kruntime "k8s.io/apimachinery/pkg/runtime"
@@ -22,7 +26,6 @@ import (
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/klog/v2"
 	"k8s.io/utils/pointer"
-	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 	yaml "sigs.k8s.io/yaml"
@@ -72,6 +75,8 @@ const (
 	defaultWebhookServiceNamespace  = "openshift-machine-api"
 	defaultWebhookServicePort       = 443
 
+	nodeRoleLabelPrefix = "node-role.kubernetes.io/"
+
 	defaultUserDataSecret  = "worker-user-data"
 	defaultSecretNamespace = "openshift-machine-api"
 
@@ -96,6 +101,9 @@ const (
 	// https://github.com/openshift/installer/blob/796a99049d3b7489b6c08ec5bd7c7983731afbcf/data/data/rhcos.json#L90-L94
 	defaultGCPDiskImage = "projects/rhcos-cloud/global/images/rhcos-48-83-202103221318-0-gcp-x86-64"
 	defaultGCPGPUCount  = 1
+	// gcpNodeLabelsWarningThreshold is the number of spec.metadata.labels above which GCP machines get
+	// a reminder that node labels are not GCE instance labels. GCE allows at most 64 instance labels.
+	gcpNodeLabelsWarningThreshold = 60
 
 	// vSphere Defaults
 	defaultVSphereCredentialsSecret = "vsphere-cloud-credentials"
@@ -104,16 +112,36 @@ const (
 	minVSphereMemoryMiB = 2048
 	// https://docs.openshift.com/container-platform/4.1/installing/installing_vsphere/installing-vsphere.html#minimum-resource-requirements_installing-vsphere
 	minVSphereDiskGiB = 120
+
+	// Nutanix Defaults
+	defaultNutanixCredentialsSecret = "nutanix-credentials"
+	// Minimum Nutanix values taken from the Nutanix machine provider
+	minNutanixVCPUsPerSocket = 1
+	minNutanixVCPUSockets    = 1
+	minNutanixMemoryMiB      = 2048
+	minNutanixDiskGiB        = 20
+
+	// IBM Cloud Defaults
+	defaultIBMCloudCredentialsSecret = "ibmcloud-credentials"
+
+	// Alibaba Cloud Defaults
+	defaultAlibabaCloudCredentialsSecret = "alibabacloud-credentials"
+	defaultAlibabaCloudInstanceType      = "ecs.g6.large"
+
+	// credentialsRequestAnnotation is set by the cloud-credential-operator on the secrets it manages.
+	credentialsRequestAnnotation = "cloudcredential.openshift.io/credentials-request"
 )
 
 var (
 	// webhookFailurePolicy is ignore so we don't want to block machine lifecycle on the webhook operational aspects.
 	// This would be particularly problematic for chicken egg issues when bootstrapping a cluster.
+	// The machine-api-operator may set it to Fail on some platforms, with its --webhook-failure-policy flag.
 	webhookFailurePolicy = admissionregistrationv1.Ignore
 	webhookSideEffects   = admissionregistrationv1.SideEffectClassNone
 )
 
-func secretExists(c client.Client, name, namespace string) (bool, error) {
+// getSecret returns the named secret, or nil if it does not exist.
+func getSecret(c client.Reader, name, namespace string) (*corev1.Secret, error) {
 	key := client.ObjectKey{
 		Name:      name,
 		Namespace: namespace,
@@ -122,15 +150,15 @@ func secretExists(c client.Client, name, namespace string) (bool, error) {
 
 	if err := c.Get(context.Background(), key, obj); err != nil {
 		if apierrors.IsNotFound(err) {
-			return false, nil
+			return nil, nil
 		}
-		return false, err
+		return nil, err
 	}
-	return true, nil
+	return obj, nil
 }
 
-func credentialsSecretExists(c client.Client, name, namespace string) []string {
-	secretExists, err := secretExists(c, name, namespace)
+func validateCredentialsSecret(c client.Reader, name, namespace string) []string {
+	secret, err := getSecret(c, name, namespace)
 	if err != nil {
 		return []string{
 			field.Invalid(
@@ -141,7 +169,7 @@ func credentialsSecretExists(c client.Client, name, namespace string) []string {
 		}
 	}
 
-	if !secretExists {
+	if secret == nil {
 		return []string{
 			field.Invalid(
 				field.NewPath("providerSpec", "credentialsSecret"),
@@ -151,40 +179,20 @@ func credentialsSecretExists(c client.Client, name, namespace string) []string {
 		}
 	}
 
-	return []string{}
-}
+	warnings := []string{}
 
-func getInfra() (*osconfigv1.Infrastructure, error) {
-	cfg, err := ctrl.GetConfig()
-	if err != nil {
-		return nil, err
-	}
-	client, err := osclientset.NewForConfig(cfg)
-	if err != nil {
-		return nil, err
+	// An empty type is defaulted to Opaque by the API server.
+	if secret.Type != "" && secret.Type != corev1.SecretTypeOpaque {
+		warnings = append(warnings, fmt.Sprintf("providerSpec.credentialsSecret: %s has type %s: credentials secrets are expected to be of type %s and the machine controller may fail to read it", name, secret.Type, corev1.SecretTypeOpaque))
 	}
-	infra, err := client.ConfigV1().Infrastructures().Get(context.Background(), "cluster", metav1.GetOptions{})
-	if err != nil {
-		return nil, err
-	}
-	return infra, nil
-}
 
-func getDNS() (*osconfigv1.DNS, error) {
-	cfg, err := ctrl.GetConfig()
-	if err != nil {
-		return nil, err
-	}
-	client, err := osclientset.NewForConfig(cfg)
-	if err != nil {
-		return nil, err
-	}
-	dns, err := client.ConfigV1().DNSes().Get(context.Background(), "cluster", metav1.GetOptions{})
-	if err != nil {
-		return nil, err
+	// Secrets managed by the cloud-credential-operator are rotated by updating them in place,
+	// which an immutable secret does not allow.
+	if _, ok := secret.Annotations[credentialsRequestAnnotation]; ok && secret.Immutable != nil && *secret.Immutable {
+		warnings = append(warnings, fmt.Sprintf("providerSpec.credentialsSecret: %s is immutable but managed by the cloud-credential-operator: rotating its credentials requires the secret to be recreated", name))
 	}
 
-	return dns, nil
+	return warnings
 }
 
 type machineAdmissionFn func(m *machinev1.Machine, config *admissionConfig) (bool, []string, utilerrors.Aggregate)
@@ -194,12 +202,144 @@ type admissionConfig struct {
 	platformStatus  *osconfigv1.PlatformStatus
 	dnsDisconnected bool
 	client          client.Client
+	// vSphereFailureDomains are the failure domains defined in the Infrastructure spec on vSphere.
+	vSphereFailureDomains []vSphereFailureDomain
+	// arch is the architecture the defaulting webhooks default for.
+	arch string
+	// defaults are the effective machine defaults, only set for the defaulting webhooks.
+	defaults map[string]string
+	// requireAWSVolumeEncryption is set by the validation policy to deny unencrypted AWS block devices.
+	requireAWSVolumeEncryption bool
+	// azureVMSKUs are the Azure VM SKUs available to the cluster, keyed by location. They are only set when
+	// the validation policy enables the validation of Azure VM sizes and the SKUs are cached.
+	azureVMSKUs map[string]AzureVMSKUs
+	// httpClient is used for outbound HTTPS requests. It trusts the cluster trusted CA bundle, see TrustedCABundle.
+	httpClient *http.Client
+	// secrets, when set, serves the secrets read by the validations instead of client, see SecretsCache.
+	secrets client.Reader
+	// allowedLifecycleHookOwners are the owners allowed to add lifecycle hooks by the validation policy.
+	// Any owner is allowed when it is nil.
+	allowedLifecycleHookOwners sets.String
+}
+
+// secretsReader returns the reader of the secrets read by the validations.
+func (c *admissionConfig) secretsReader() client.Reader {
+	if c.secrets != nil {
+		return c.secrets
+	}
+	return c.client
 }
 
 type admissionHandler struct {
 	*admissionConfig
 	webhookOperations machineAdmissionFn
 	decoder           *admission.Decoder
+	// inputs, when set, is used to keep the cluster inputs of admissionConfig up to date.
+	inputs *clusterInputsCache
+	// validationMode is the validation mode used unless overridden by the validation policy ConfigMap.
+	validationMode ValidationMode
+	// hybridPlatformValidation is set when resources are validated against the platform of their providerSpec kind.
+	hybridPlatformValidation bool
+}
+
+// currentConfig returns the admissionConfig for a single admission request.
+// The cluster inputs are refreshed from the inputs cache when one is configured,
+// otherwise the config the handler was created with is used as is.
+func (a *admissionHandler) currentConfig() *admissionConfig {
+	if a.inputs == nil {
+		return a.admissionConfig
+	}
+
+	config := *a.admissionConfig
+	if infra, err := a.inputs.getInfrastructure(context.Background()); err != nil {
+		klog.Errorf("Unable to refresh Infrastructure, using the last known value: %v", err)
+	} else {
+		config.clusterID = infra.Status.InfrastructureName
+		config.platformStatus = infra.Status.PlatformStatus
+	}
+	if config.platformStatus != nil && config.platformStatus.Type == osconfigv1.VSpherePlatformType {
+		failureDomains, err := a.inputs.getVSphereFailureDomains(context.Background())
+		if err != nil {
+			klog.Errorf("Unable to refresh vSphere failure domains, using the last known value: %v", err)
+		}
+		config.vSphereFailureDomains = failureDomains
+	}
+	if dns, err := a.inputs.getDNS(context.Background()); err != nil {
+		klog.Errorf("Unable to refresh DNS, using the last known value: %v", err)
+	} else {
+		config.dnsDisconnected = dns.Spec.PublicZone == nil
+	}
+	if config.defaults != nil && config.platformStatus != nil {
+		config.defaults = a.currentDefaults(config.platformStatus.Type, config.arch)
+	}
+	policy, err := a.inputs.getValidationPolicy(context.Background())
+	if err != nil {
+		klog.Errorf("Unable to refresh the validation policy, using the last known value: %v", err)
+	}
+	config.requireAWSVolumeEncryption = requireAWSVolumeEncryptionFromPolicy(policy)
+	config.allowedLifecycleHookOwners = lifecycleHookOwnersFromPolicy(policy)
+	if config.platformStatus != nil && config.platformStatus.Type == osconfigv1.AzurePlatformType && AzureVMSizeAvailabilityFromPolicy(policy) {
+		config.azureVMSKUs = a.currentAzureVMSKUs()
+	}
+	return &config
+}
+
+// currentAzureVMSKUs returns the cached Azure VM SKUs, or nil when they are not cached or cannot be parsed.
+func (a *admissionHandler) currentAzureVMSKUs() map[string]AzureVMSKUs {
+	configMap, err := a.inputs.getAzureVMSKUs(context.Background())
+	if err != nil {
+		klog.Errorf("Unable to refresh the Azure VM SKUs, using the last known value: %v", err)
+	}
+	if configMap == nil {
+		return nil
+	}
+
+	skus, err := ParseAzureVMSKUs(configMap)
+	if err != nil {
+		klog.Errorf("Ignoring invalid ConfigMap %s/%s: %v", defaultWebhookServiceNamespace, AzureVMSKUsConfigMapName, err)
+		return nil
+	}
+	return skus
+}
+
+// currentDefaults returns the effective machine defaults with the defaults overrides ConfigMap applied.
+// The built-in defaults are used while the overrides cannot be read or are invalid.
+func (a *admissionHandler) currentDefaults(platform osconfigv1.PlatformType, arch string) map[string]string {
+	overrides, err := a.inputs.getDefaultsOverrides(context.Background())
+	if err != nil {
+		klog.Errorf("Unable to refresh the defaults overrides, using the last known value: %v", err)
+	}
+
+	defaults, err := EffectiveMachineDefaults(platform, arch, overrides)
+	if err != nil {
+		klog.Errorf("Ignoring invalid ConfigMap %s/%s: %v", defaultWebhookServiceNamespace, DefaultsOverridesConfigMapName, err)
+		return builtinMachineDefaults(platform, arch)
+	}
+	return defaults
+}
+
+// currentValidationMode returns the validation mode for a single admission request.
+// The validation policy ConfigMap takes precedence over the configured mode.
+func (a *admissionHandler) currentValidationMode() ValidationMode {
+	if a.inputs == nil {
+		return a.validationMode
+	}
+
+	policy, err := a.inputs.getValidationPolicy(context.Background())
+	if err != nil {
+		klog.Errorf("Unable to refresh the validation policy, using the last known value: %v", err)
+	}
+	return validationModeFromPolicy(policy, a.validationMode)
+}
+
+// SetHTTPClient sets the client used for outbound HTTPS requests.
+func (a *admissionHandler) SetHTTPClient(httpClient *http.Client) {
+	a.httpClient = httpClient
+}
+
+// SetSecretsCache sets the cache serving the secrets read by the validations.
+func (a *admissionHandler) SetSecretsCache(secrets *SecretsCache) {
+	a.secrets = secrets
 }
 
 // InjectDecoder injects the decoder.
@@ -223,18 +363,32 @@ type machineDefaulterHandler struct {
 }
 
 // NewValidator returns a new machineValidatorHandler.
-func NewMachineValidator(client client.Client) (*machineValidatorHandler, error) {
-	infra, err := getInfra()
+func NewMachineValidator(client client.Client, validationMode ValidationMode, hybridPlatformValidation bool) (*machineValidatorHandler, error) {
+	reader, err := newClusterInputsReader()
 	if err != nil {
 		return nil, err
 	}
+	inputs := newClusterInputsCache(reader)
 
-	dns, err := getDNS()
+	infra, err := inputs.getInfrastructure(context.Background())
 	if err != nil {
 		return nil, err
 	}
 
-	return createMachineValidator(infra, client, dns), nil
+	dns, err := inputs.getDNS(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	h := createMachineValidator(infra, client, dns)
+	h.inputs = inputs
+	h.validationMode = validationMode
+	h.hybridPlatformValidation = hybridPlatformValidation
+	if hybridPlatformValidation {
+		h.webhookOperations = getHybridMachineValidatorOperation(infra.Status.PlatformStatus.Type)
+	}
+	h.observeRuleSet()
+	return h, nil
 }
 
 func createMachineValidator(infra *osconfigv1.Infrastructure, client client.Client, dns *osconfigv1.DNS) *machineValidatorHandler {
@@ -248,6 +402,7 @@ func createMachineValidator(infra *osconfigv1.Infrastructure, client client.Clie
 		admissionHandler: &admissionHandler{
 			admissionConfig:   admissionConfig,
 			webhookOperations: getMachineValidatorOperation(infra.Status.PlatformStatus.Type),
+			validationMode:    ValidationModeEnforce,
 		},
 	}
 }
@@ -262,6 +417,16 @@ func getMachineValidatorOperation(platform osconfigv1.PlatformType) machineAdmis
 		return validateGCP
 	case osconfigv1.VSpherePlatformType:
 		return validateVSphere
+	case osconfigv1.OpenStackPlatformType:
+		return validateOpenStack
+	case nutanixPlatformType:
+		return validateNutanix
+	case osconfigv1.IBMCloudPlatformType:
+		return validateIBMCloud
+	case osconfigv1.PowerVSPlatformType:
+		return validatePowerVS
+	case osconfigv1.AlibabaCloudPlatformType:
+		return validateAlibabaCloud
 	default:
 		// just no-op
 		return func(m *machinev1.Machine, config *admissionConfig) (bool, []string, utilerrors.Aggregate) {
@@ -271,48 +436,73 @@ func getMachineValidatorOperation(platform osconfigv1.PlatformType) machineAdmis
 }
 
 // NewDefaulter returns a new machineDefaulterHandler.
+// The platform status and cluster ID are kept up to date from the cluster inputs cache.
 func NewMachineDefaulter() (*machineDefaulterHandler, error) {
-	infra, err := getInfra()
+	reader, err := newClusterInputsReader()
+	if err != nil {
+		return nil, err
+	}
+	inputs := newClusterInputsCache(reader)
+
+	infra, err := inputs.getInfrastructure(context.Background())
 	if err != nil {
 		return nil, err
 	}
 
-	return createMachineDefaulter(infra.Status.PlatformStatus, infra.Status.InfrastructureName), nil
+	h := createMachineDefaulter(infra.Status.PlatformStatus, infra.Status.InfrastructureName)
+	h.inputs = inputs
+	return h, nil
 }
 
 func createMachineDefaulter(platformStatus *osconfigv1.PlatformStatus, clusterID string) *machineDefaulterHandler {
 	return &machineDefaulterHandler{
 		admissionHandler: &admissionHandler{
-			admissionConfig:   &admissionConfig{clusterID: clusterID},
+			admissionConfig:   newDefaulterAdmissionConfig(platformStatus, clusterID),
 			webhookOperations: getMachineDefaulterOperation(platformStatus),
 		},
 	}
 }
 
+// newDefaulterAdmissionConfig returns the admissionConfig of the defaulting webhooks,
+// carrying the built-in machine defaults of the platform.
+func newDefaulterAdmissionConfig(platformStatus *osconfigv1.PlatformStatus, clusterID string) *admissionConfig {
+	return &admissionConfig{
+		clusterID:      clusterID,
+		platformStatus: platformStatus,
+		arch:           runtime.GOARCH,
+		defaults:       builtinMachineDefaults(platformStatus.Type, runtime.GOARCH),
+	}
+}
+
 func getMachineDefaulterOperation(platformStatus *osconfigv1.PlatformStatus) machineAdmissionFn {
+	var defaulter machineAdmissionFn
 	switch platformStatus.Type {
 	case osconfigv1.AWSPlatformType:
-		region := ""
-		if platformStatus.AWS != nil {
-			region = platformStatus.AWS.Region
-		}
-		arch := runtime.GOARCH
-		return awsDefaulter{region: region, arch: arch}.defaultAWS
+		defaulter = defaultAWS
 	case osconfigv1.AzurePlatformType:
-		return defaultAzure
+		defaulter = defaultAzure
 	case osconfigv1.GCPPlatformType:
-		return defaultGCP
+		defaulter = defaultGCP
 	case osconfigv1.VSpherePlatformType:
-		return defaultVSphere
+		defaulter = defaultVSphere
+	case nutanixPlatformType:
+		defaulter = defaultNutanix
+	case osconfigv1.IBMCloudPlatformType:
+		defaulter = defaultIBMCloud
+	case osconfigv1.AlibabaCloudPlatformType:
+		defaulter = defaultAlibabaCloud
 	default:
 		// just no-op
 		return func(m *machinev1.Machine, config *admissionConfig) (bool, []string, utilerrors.Aggregate) {
 			return true, []string{}, nil
 		}
 	}
+
+	disallowed := disallowedProviderSpecFields[platformStatus.Type]
+	return sanitizeProviderSpec(disallowed, denyUnsupportedProviderSpecFields(platformStatus.Type, defaulter))
 }
 
-// NewValidatingWebhookConfiguration creates a validation webhook configuration with configured Machine and MachineSet webhooks
+// NewValidatingWebhookConfiguration creates a validation webhook configuration with configured Machine, MachineSet and MachineHealthCheck webhooks
 func NewValidatingWebhookConfiguration() *admissionregistrationv1.ValidatingWebhookConfiguration {
 	validatingWebhookConfiguration := &admissionregistrationv1.ValidatingWebhookConfiguration{
 		ObjectMeta: metav1.ObjectMeta{
@@ -324,6 +514,7 @@ func NewValidatingWebhookConfiguration() *admissionregistrationv1.ValidatingWebh
 		Webhooks: []admissionregistrationv1.ValidatingWebhook{
 			MachineValidatingWebhook(),
 			MachineSetValidatingWebhook(),
+			MachineHealthCheckValidatingWebhook(),
 		},
 	}
 
@@ -359,6 +550,7 @@ func MachineValidatingWebhook() admissionregistrationv1.ValidatingWebhook {
 				Operations: []admissionregistrationv1.OperationType{
 					admissionregistrationv1.Create,
 					admissionregistrationv1.Update,
+					admissionregistrationv1.Delete,
 				},
 			},
 		},
@@ -393,6 +585,16 @@ func MachineSetValidatingWebhook() admissionregistrationv1.ValidatingWebhook {
 					admissionregistrationv1.Update,
 				},
 			},
+			{
+				Rule: admissionregistrationv1.Rule{
+					APIGroups:   []string{machinev1.GroupName},
+					APIVersions: []string{machinev1.SchemeGroupVersion.Version},
+					Resources:   []string{"machinesets/scale"},
+				},
+				Operations: []admissionregistrationv1.OperationType{
+					admissionregistrationv1.Update,
+				},
+			},
 		},
 	}
 }
@@ -482,12 +684,34 @@ func MachineSetMutatingWebhook() admissionregistrationv1.MutatingWebhook {
 
 func (h *machineValidatorHandler) validateMachine(m, oldM *machinev1.Machine) (bool, []string, utilerrors.Aggregate) {
 	errs := validateMachineLifecycleHooks(m, oldM)
+	errs = append(errs, validateMachineProviderSpecOnDelete(m, oldM)...)
+	errs = append(errs, validateTaints(m.Spec.Taints, field.NewPath("spec", "taints"))...)
 
-	ok, warnings, err := h.webhookOperations(m, h.admissionConfig)
-	if !ok {
-		errs = append(errs, err.Errors()...)
+	config := h.currentConfig()
+	var clusterPlatform osconfigv1.PlatformType
+	if config.platformStatus != nil {
+		clusterPlatform = config.platformStatus.Type
+	}
+
+	var warnings []string
+	if !skipPlatformRules(m, m, oldM, clusterPlatform) {
+		ok, platformWarnings, err := h.webhookOperations(m, config)
+		if !ok {
+			errs = append(errs, err.Errors()...)
+		}
+		warnings = platformWarnings
 	}
 
+	immutableWarnings, immutableErrs := validateImmutableProviderSpecFields(m, oldM, clusterPlatform)
+	warnings = append(warnings, immutableWarnings...)
+	errs = append(errs, immutableErrs...)
+	errs = append(errs, validateUnsupportedProviderSpecFields(m, oldM, clusterPlatform)...)
+
+	errs = append(errs, validateLifecycleHookOwners(m, oldM, config.allowedLifecycleHookOwners, field.NewPath("spec", "lifecycleHooks"))...)
+
+	warnings = append(warnings, validateNodeRoleLabels(m.Labels, m.Spec.ObjectMeta.Labels, field.NewPath("metadata", "labels"), field.NewPath("spec", "metadata", "labels"))...)
+	warnings = append(warnings, validateNodeManagedAnnotations(m.Spec.ObjectMeta.Annotations, field.NewPath("spec", "metadata", "annotations"))...)
+
 	if len(errs) > 0 {
 		return false, warnings, utilerrors.NewAggregate(errs)
 	}
@@ -496,6 +720,10 @@ func (h *machineValidatorHandler) validateMachine(m, oldM *machinev1.Machine) (b
 
 // Handle handles HTTP requests for admission webhook servers.
 func (h *machineValidatorHandler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation == admissionv1.Delete {
+		return h.handleDelete(req)
+	}
+
 	m := &machinev1.Machine{}
 
 	if err := h.decoder.Decode(req, m); err != nil {
@@ -516,11 +744,18 @@ func (h *machineValidatorHandler) Handle(ctx context.Context, req admission.Requ
 	klog.V(3).Infof("Validate webhook called for Machine: %s", m.GetName())
 
 	ok, warnings, errs := h.validateMachine(m, oldM)
-	if !ok {
-		return admission.Denied(errs.Error()).WithWarnings(warnings...)
+	if req.Operation == admissionv1.Create {
+		if err := validateMachineSetOwner(h.client, m, req.UserInfo.Username); err != nil {
+			var allErrs []error
+			if errs != nil {
+				allErrs = errs.Errors()
+			}
+			ok, errs = false, utilerrors.NewAggregate(append(allErrs, safetyDenials(err)...))
+		}
 	}
-
-	return admission.Allowed("Machine valid").WithWarnings(warnings...)
+	// Keep the reported rule set up to date with policy changes.
+	h.observeRuleSet()
+	return validationResponse(admissionResourceMachine, h.admissionPlatform(m), h.currentValidationMode(), ok, warnings, errs, "Machine valid")
 }
 
 // Handle handles HTTP requests for admission webhook servers.
@@ -533,6 +768,8 @@ func (h *machineDefaulterHandler) Handle(ctx context.Context, req admission.Requ
 
 	klog.V(3).Infof("Mutate webhook called for Machine: %s", m.GetName())
 
+	config := h.currentConfig()
+
 	// Only enforce the clusterID if it's not set.
 	// Otherwise a discrepancy on the value would leave the machine orphan
 	// and would trigger a new machine creation by the machineSet.
@@ -541,27 +778,18 @@ func (h *machineDefaulterHandler) Handle(ctx context.Context, req admission.Requ
 		m.Labels = make(map[string]string)
 	}
 	if _, ok := m.Labels[machinev1.MachineClusterIDLabel]; !ok {
-		m.Labels[machinev1.MachineClusterIDLabel] = h.clusterID
+		m.Labels[machinev1.MachineClusterIDLabel] = config.clusterID
 	}
 
-	ok, warnings, errs := h.webhookOperations(m, h.admissionConfig)
+	ok, warnings, errs := h.webhookOperations(m, config)
 	if !ok {
 		return admission.Denied(errs.Error()).WithWarnings(warnings...)
 	}
 
-	marshaledMachine, err := json.Marshal(m)
-	if err != nil {
-		return admission.Errored(http.StatusInternalServerError, err).WithWarnings(warnings...)
-	}
-	return admission.PatchResponseFromRaw(req.Object.Raw, marshaledMachine).WithWarnings(warnings...)
-}
-
-type awsDefaulter struct {
-	region string
-	arch   string
+	return defaultingResponse(req, m, warnings)
 }
 
-func (a awsDefaulter) defaultAWS(m *machinev1.Machine, config *admissionConfig) (bool, []string, utilerrors.Aggregate) {
+func defaultAWS(m *machinev1.Machine, config *admissionConfig) (bool, []string, utilerrors.Aggregate) {
 	klog.V(3).Infof("Defaulting AWS providerSpec")
 
 	var errs []error
@@ -573,23 +801,21 @@ func (a awsDefaulter) defaultAWS(m *machinev1.Machine, config *admissionConfig)
 	}
 
 	if providerSpec.InstanceType == "" {
-		if a.arch == "arm64" {
-			providerSpec.InstanceType = defaultAWSARMInstanceType
-		} else {
-			providerSpec.InstanceType = defaultAWSX86InstanceType
-		}
+		providerSpec.InstanceType = config.defaultValue(osconfigv1.AWSPlatformType, defaultsInstanceTypeKey)
 	}
+	// Machines are only defaulted on creation, so existing Machines are not warned about on every update.
+	warnings = append(warnings, warnAWSInstanceType(providerSpec)...)
 
-	if providerSpec.Placement.Region == "" {
-		providerSpec.Placement.Region = a.region
+	if providerSpec.Placement.Region == "" && config.platformStatus != nil && config.platformStatus.AWS != nil {
+		providerSpec.Placement.Region = config.platformStatus.AWS.Region
 	}
 
 	if providerSpec.UserDataSecret == nil {
-		providerSpec.UserDataSecret = &corev1.LocalObjectReference{Name: defaultUserDataSecret}
+		providerSpec.UserDataSecret = &corev1.LocalObjectReference{Name: config.defaultValue(osconfigv1.AWSPlatformType, defaultsUserDataSecretKey)}
 	}
 
 	if providerSpec.CredentialsSecret == nil {
-		providerSpec.CredentialsSecret = &corev1.LocalObjectReference{Name: defaultAWSCredentialsSecret}
+		providerSpec.CredentialsSecret = &corev1.LocalObjectReference{Name: config.defaultValue(osconfigv1.AWSPlatformType, defaultsCredentialsSecretKey)}
 	}
 
 	rawBytes, err := json.Marshal(providerSpec)
@@ -616,6 +842,38 @@ func unmarshalInto(m *machinev1.Machine, providerSpec interface{}) error {
 	return nil
 }
 
+// defaultRawProviderSpec sets the top level providerSpec fields which are unset to their default value.
+// The providerSpec is defaulted as a map, for the platforms whose provider types are not vendored,
+// so that the fields the webhook does not model are kept.
+func defaultRawProviderSpec(m *machinev1.Machine, defaults map[string]interface{}) error {
+	providerSpec := map[string]interface{}{}
+	if err := unmarshalInto(m, &providerSpec); err != nil {
+		return err
+	}
+
+	for name, value := range defaults {
+		if _, ok := providerSpec[name]; !ok {
+			providerSpec[name] = value
+		}
+	}
+
+	rawBytes, err := json.Marshal(providerSpec)
+	if err != nil {
+		return err
+	}
+	m.Spec.ProviderSpec.Value = &kruntime.RawExtension{Raw: rawBytes}
+	return nil
+}
+
+// rawProviderSpecSecretDefaults returns the default user data and credentials secrets of the platform
+// as raw providerSpec fields.
+func rawProviderSpecSecretDefaults(config *admissionConfig, platform osconfigv1.PlatformType) map[string]interface{} {
+	return map[string]interface{}{
+		"userDataSecret":    map[string]interface{}{"name": config.defaultValue(platform, defaultsUserDataSecretKey)},
+		"credentialsSecret": map[string]interface{}{"name": config.defaultValue(platform, defaultsCredentialsSecretKey)},
+	}
+}
+
 func validateAWS(m *machinev1.Machine, config *admissionConfig) (bool, []string, utilerrors.Aggregate) {
 	klog.V(3).Infof("Validating AWS providerSpec")
 
@@ -642,6 +900,18 @@ func validateAWS(m *machinev1.Machine, config *admissionConfig) (bool, []string,
 			warnings,
 			"can't use providerSpec.ami.arn, only providerSpec.ami.id can be used to reference AMI",
 		)
+
+		// AMIs are regional, an AMI of another region cannot be launched.
+		if region, ok := awsARNRegion(*providerSpec.AMI.ARN); ok && region != "" && providerSpec.Placement.Region != "" && region != providerSpec.Placement.Region {
+			errs = append(
+				errs,
+				field.Invalid(
+					field.NewPath("providerSpec", "ami", "arn"),
+					*providerSpec.AMI.ARN,
+					fmt.Sprintf("AMI is in region %s, but the machine is placed in region %s", region, providerSpec.Placement.Region),
+				),
+			)
+		}
 	}
 
 	if providerSpec.AMI.Filters != nil {
@@ -671,6 +941,12 @@ func validateAWS(m *machinev1.Machine, config *admissionConfig) (bool, []string,
 		)
 	}
 
+	warnings = append(warnings, validateAWSLocalZone(providerSpec)...)
+
+	spotWarnings, spotErrs := validateAWSSpotMarketOptions(config.client, providerSpec, m.Spec.ProviderSpec.Value.Raw)
+	warnings = append(warnings, spotWarnings...)
+	errs = append(errs, spotErrs...)
+
 	if providerSpec.UserDataSecret == nil {
 		errs = append(
 			errs,
@@ -690,7 +966,7 @@ func validateAWS(m *machinev1.Machine, config *admissionConfig) (bool, []string,
 			),
 		)
 	} else {
-		warnings = append(warnings, credentialsSecretExists(config.client, providerSpec.CredentialsSecret.Name, m.GetNamespace())...)
+		warnings = append(warnings, validateCredentialsSecret(config.secretsReader(), providerSpec.CredentialsSecret.Name, m.GetNamespace())...)
 	}
 
 	if providerSpec.Subnet.ARN == nil && providerSpec.Subnet.ID == nil && providerSpec.Subnet.Filters == nil {
@@ -700,12 +976,19 @@ func validateAWS(m *machinev1.Machine, config *admissionConfig) (bool, []string,
 		)
 	}
 
+	warnings = append(warnings, validateAWSControlPlaneSubnet(m, providerSpec, config.client)...)
+
 	if providerSpec.IAMInstanceProfile == nil {
 		warnings = append(warnings, "providerSpec.iamInstanceProfile: no IAM instance profile provided: nodes may be unable to join the cluster")
 	}
 
-	// TODO(alberto): Validate providerSpec.BlockDevices.
-	// https://github.com/openshift/cluster-api-provider-aws/pull/299#discussion_r433920532
+	blockDeviceWarnings, blockDeviceErrors := validateAWSBlockDevices(providerSpec)
+	warnings = append(warnings, blockDeviceWarnings...)
+	errs = append(errs, blockDeviceErrors...)
+
+	encryptionWarnings, encryptionErrors := validateAWSVolumeEncryption(providerSpec.BlockDevices, config.requireAWSVolumeEncryption)
+	warnings = append(warnings, encryptionWarnings...)
+	errs = append(errs, encryptionErrors...)
 
 	switch providerSpec.Placement.Tenancy {
 	case "", machinev1.DefaultTenancy, machinev1.DedicatedTenancy, machinev1.HostTenancy:
@@ -762,7 +1045,7 @@ func defaultAzure(m *machinev1.Machine, config *admissionConfig) (bool, []string
 	}
 
 	if providerSpec.VMSize == "" {
-		providerSpec.VMSize = defaultAzureVMSize
+		providerSpec.VMSize = config.defaultValue(osconfigv1.AzurePlatformType, defaultsVMSizeKey)
 	}
 
 	// Vnet and Subnet need to be provided together by the user
@@ -775,24 +1058,30 @@ func defaultAzure(m *machinev1.Machine, config *admissionConfig) (bool, []string
 		providerSpec.Image.ResourceID = defaultAzureImageResourceID(config.clusterID)
 	}
 
+	userDataSecret := config.defaultValue(osconfigv1.AzurePlatformType, defaultsUserDataSecretKey)
 	if providerSpec.UserDataSecret == nil {
-		providerSpec.UserDataSecret = &corev1.SecretReference{Name: defaultUserDataSecret}
+		providerSpec.UserDataSecret = &corev1.SecretReference{Name: userDataSecret}
 	} else if providerSpec.UserDataSecret.Name == "" {
-		providerSpec.UserDataSecret.Name = defaultUserDataSecret
+		providerSpec.UserDataSecret.Name = userDataSecret
 	}
 
+	credentialsSecret := config.defaultValue(osconfigv1.AzurePlatformType, defaultsCredentialsSecretKey)
+	credentialsSecretNamespace := config.defaultValue(osconfigv1.AzurePlatformType, defaultsCredentialsSecretNamespaceKey)
 	if providerSpec.CredentialsSecret == nil {
-		providerSpec.CredentialsSecret = &corev1.SecretReference{Name: defaultAzureCredentialsSecret, Namespace: defaultSecretNamespace}
+		providerSpec.CredentialsSecret = &corev1.SecretReference{Name: credentialsSecret, Namespace: credentialsSecretNamespace}
 	} else {
 		if providerSpec.CredentialsSecret.Namespace == "" {
-			providerSpec.CredentialsSecret.Namespace = defaultSecretNamespace
+			providerSpec.CredentialsSecret.Namespace = credentialsSecretNamespace
 		}
 		if providerSpec.CredentialsSecret.Name == "" {
-			providerSpec.CredentialsSecret.Name = defaultAzureCredentialsSecret
+			providerSpec.CredentialsSecret.Name = credentialsSecret
 		}
 	}
 
 	rawBytes, err := json.Marshal(providerSpec)
+	if err == nil {
+		rawBytes, err = defaultAzureDataDisks(rawBytes, m.Spec.ProviderSpec.Value.Raw)
+	}
 	if err != nil {
 		errs = append(errs, err)
 	}
@@ -816,10 +1105,20 @@ func validateAzure(m *machinev1.Machine, config *admissionConfig) (bool, []strin
 		return false, warnings, utilerrors.NewAggregate(errs)
 	}
 
+	dataDiskWarnings, dataDiskErrs := validateAzureDataDisks(m.Spec.ProviderSpec.Value.Raw)
+	warnings = append(warnings, dataDiskWarnings...)
+	errs = append(errs, dataDiskErrs...)
+
 	if providerSpec.VMSize == "" {
 		errs = append(errs, field.Required(field.NewPath("providerSpec", "vmSize"), "vmSize should be set to one of the supported Azure VM sizes"))
 	}
 
+	if config.azureVMSKUs != nil {
+		skuWarnings, skuErrs := validateAzureVMSizeAvailability(providerSpec, config.azureVMSKUs)
+		warnings = append(warnings, skuWarnings...)
+		errs = append(errs, skuErrs...)
+	}
+
 	if providerSpec.PublicIP && config.dnsDisconnected {
 		errs = append(errs, field.Forbidden(field.NewPath("providerSpec", "publicIP"), "publicIP is not allowed in Azure disconnected installation"))
 	}
@@ -834,6 +1133,7 @@ func validateAzure(m *machinev1.Machine, config *admissionConfig) (bool, []strin
 	}
 
 	errs = append(errs, validateAzureImage(providerSpec.Image)...)
+	warnings = append(warnings, validateAzureGen2Image(providerSpec.VMSize, providerSpec.Image)...)
 
 	if providerSpec.UserDataSecret == nil {
 		errs = append(errs, field.Required(field.NewPath("providerSpec", "userDataSecret"), "userDataSecret must be provided"))
@@ -851,7 +1151,7 @@ func validateAzure(m *machinev1.Machine, config *admissionConfig) (bool, []strin
 			errs = append(errs, field.Required(field.NewPath("providerSpec", "credentialsSecret", "name"), "name must be provided"))
 		}
 		if providerSpec.CredentialsSecret.Name != "" && providerSpec.CredentialsSecret.Namespace != "" {
-			warnings = append(warnings, credentialsSecretExists(config.client, providerSpec.CredentialsSecret.Name, providerSpec.CredentialsSecret.Namespace)...)
+			warnings = append(warnings, validateCredentialsSecret(config.secretsReader(), providerSpec.CredentialsSecret.Name, providerSpec.CredentialsSecret.Namespace)...)
 		}
 	}
 
@@ -859,6 +1159,10 @@ func validateAzure(m *machinev1.Machine, config *admissionConfig) (bool, []strin
 		errs = append(errs, field.Invalid(field.NewPath("providerSpec", "osDisk", "diskSizeGB"), providerSpec.OSDisk.DiskSizeGB, "diskSizeGB must be greater than zero and less than 32768"))
 	}
 
+	if config.dnsDisconnected {
+		warnings = append(warnings, validateAzureImageAccess(config.secretsReader(), providerSpec)...)
+	}
+
 	if isAzureGovCloud(config.platformStatus) && providerSpec.SpotVMOptions != nil {
 		warnings = append(warnings, "spot VMs may not be supported when using GovCloud region")
 	}
@@ -911,7 +1215,7 @@ func defaultGCP(m *machinev1.Machine, config *admissionConfig) (bool, []string,
 	}
 
 	if providerSpec.MachineType == "" {
-		providerSpec.MachineType = defaultGCPMachineType
+		providerSpec.MachineType = config.defaultValue(osconfigv1.GCPPlatformType, defaultsMachineTypeKey)
 	}
 
 	if len(providerSpec.NetworkInterfaces) == 0 {
@@ -921,7 +1225,7 @@ func defaultGCP(m *machinev1.Machine, config *admissionConfig) (bool, []string,
 		})
 	}
 
-	providerSpec.Disks = defaultGCPDisks(providerSpec.Disks, config.clusterID)
+	providerSpec.Disks = defaultGCPDisks(providerSpec.Disks, config)
 
 	if len(providerSpec.GPUs) != 0 {
 		// In case Count was not set it should default to 1, since there is no valid reason for it to be purposely set to 0.
@@ -935,11 +1239,11 @@ func defaultGCP(m *machinev1.Machine, config *admissionConfig) (bool, []string,
 	}
 
 	if providerSpec.UserDataSecret == nil {
-		providerSpec.UserDataSecret = &corev1.LocalObjectReference{Name: defaultUserDataSecret}
+		providerSpec.UserDataSecret = &corev1.LocalObjectReference{Name: config.defaultValue(osconfigv1.GCPPlatformType, defaultsUserDataSecretKey)}
 	}
 
 	if providerSpec.CredentialsSecret == nil {
-		providerSpec.CredentialsSecret = &corev1.LocalObjectReference{Name: defaultGCPCredentialsSecret}
+		providerSpec.CredentialsSecret = &corev1.LocalObjectReference{Name: config.defaultValue(osconfigv1.GCPPlatformType, defaultsCredentialsSecretKey)}
 	}
 
 	rawBytes, err := json.Marshal(providerSpec)
@@ -955,26 +1259,31 @@ func defaultGCP(m *machinev1.Machine, config *admissionConfig) (bool, []string,
 	return true, warnings, nil
 }
 
-func defaultGCPDisks(disks []*machinev1.GCPDisk, clusterID string) []*machinev1.GCPDisk {
+func defaultGCPDisks(disks []*machinev1.GCPDisk, config *admissionConfig) []*machinev1.GCPDisk {
+	diskType := config.defaultValue(osconfigv1.GCPPlatformType, defaultsDiskTypeKey)
+	diskImage := config.defaultValue(osconfigv1.GCPPlatformType, defaultsDiskImageKey)
+
 	if len(disks) == 0 {
+		// The overrides are validated, so the size always parses.
+		sizeGB, _ := strconv.ParseInt(config.defaultValue(osconfigv1.GCPPlatformType, defaultsDiskSizeGBKey), 10, 64)
 		return []*machinev1.GCPDisk{
 			{
 				AutoDelete: true,
 				Boot:       true,
-				SizeGB:     defaultGCPDiskSizeGb,
-				Type:       defaultGCPDiskType,
-				Image:      defaultGCPDiskImage,
+				SizeGB:     sizeGB,
+				Type:       diskType,
+				Image:      diskImage,
 			},
 		}
 	}
 
 	for _, disk := range disks {
 		if disk.Type == "" {
-			disk.Type = defaultGCPDiskType
+			disk.Type = diskType
 		}
 
 		if disk.Image == "" {
-			disk.Image = defaultGCPDiskImage
+			disk.Image = diskImage
 		}
 	}
 
@@ -1012,9 +1321,9 @@ func validateGCP(m *machinev1.Machine, config *admissionConfig) (bool, []string,
 		errs = append(errs, field.Invalid(field.NewPath("providerSpec", "restartPolicy"), providerSpec.RestartPolicy, fmt.Sprintf("restartPolicy must be either %s or %s.", machinev1.RestartPolicyNever, machinev1.RestartPolicyAlways)))
 	}
 
-	if len(providerSpec.GPUs) != 0 || strings.HasPrefix(providerSpec.MachineType, "a2-") {
+	if _, bundledGPUs := gcpBundledGPUMachineFamilyOf(providerSpec.MachineType); len(providerSpec.GPUs) != 0 || bundledGPUs {
 		if providerSpec.OnHostMaintenance == machinev1.MigrateHostMaintenanceType {
-			errs = append(errs, field.Forbidden(field.NewPath("providerSpec", "onHostMaintenance"), fmt.Sprintf("When GPUs are specified or using machineType with pre-attached GPUs(A2 machine family), onHostMaintenance must be set to %s.", machinev1.TerminateHostMaintenanceType)))
+			errs = append(errs, field.Forbidden(field.NewPath("providerSpec", "onHostMaintenance"), fmt.Sprintf("When GPUs are specified or using machineType with pre-attached GPUs(A2 and G2 machine families), onHostMaintenance must be set to %s.", machinev1.TerminateHostMaintenanceType)))
 		}
 	}
 
@@ -1022,6 +1331,13 @@ func validateGCP(m *machinev1.Machine, config *admissionConfig) (bool, []string,
 	errs = append(errs, validateGCPDisks(providerSpec.Disks, field.NewPath("providerSpec", "disks"))...)
 	errs = append(errs, validateGCPGPUs(providerSpec.GPUs, field.NewPath("providerSpec", "gpus"), providerSpec.MachineType)...)
 
+	if config.dnsDisconnected && config.platformStatus != nil && config.platformStatus.GCP != nil {
+		warnings = append(warnings, validateGCPDiskImageProjects(providerSpec.Disks, field.NewPath("providerSpec", "disks"), config.platformStatus.GCP.ProjectID)...)
+		warnings = append(warnings, validateGCPImageAccess(providerSpec, config.platformStatus.GCP.ProjectID)...)
+	}
+
+	warnings = append(warnings, validateGCPNodeLabels(m.Spec.ObjectMeta.Labels, field.NewPath("spec", "metadata", "labels"))...)
+
 	if len(providerSpec.ServiceAccounts) == 0 {
 		warnings = append(warnings, "providerSpec.serviceAccounts: no service account provided: nodes may be unable to join the cluster")
 	} else {
@@ -1042,7 +1358,7 @@ func validateGCP(m *machinev1.Machine, config *admissionConfig) (bool, []string,
 		if providerSpec.CredentialsSecret.Name == "" {
 			errs = append(errs, field.Required(field.NewPath("providerSpec", "credentialsSecret", "name"), "name must be provided"))
 		} else {
-			warnings = append(warnings, credentialsSecretExists(config.client, providerSpec.CredentialsSecret.Name, m.GetNamespace())...)
+			warnings = append(warnings, validateCredentialsSecret(config.secretsReader(), providerSpec.CredentialsSecret.Name, m.GetNamespace())...)
 		}
 	}
 
@@ -1101,25 +1417,46 @@ func validateGCPDisks(disks []*machinev1.GCPDisk, parentPath *field.Path) []erro
 	return errs
 }
 
-func validateGCPGPUs(guestAccelerators []machinev1.GCPGPUConfig, parentPath *field.Path, machineType string) []error {
-	var errs []error
-	if len(guestAccelerators) > 1 {
-		errs = append(errs, field.TooMany(parentPath, len(guestAccelerators), 1))
-	} else if len(guestAccelerators) == 1 {
-		accelerator := guestAccelerators[0]
-		if accelerator.Type == "" {
-			errs = append(errs, field.Required(parentPath.Child("Type"), "Type is required"))
+// validateGCPNodeLabels reminds users with many node labels that spec.metadata.labels are only
+// applied to the Node, never to the GCE instance. Instance labels are set in providerSpec.labels.
+func validateGCPNodeLabels(nodeLabels map[string]string, nodeLabelsPath *field.Path) []string {
+	if len(nodeLabels) <= gcpNodeLabelsWarningThreshold {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s: %d labels are set: these are Node labels and are not applied to the GCE instance, set GCE instance labels in providerSpec.labels instead", nodeLabelsPath, len(nodeLabels))}
+}
+
+// validateGCPDiskImageProjects warns about boot disk images which live outside of the cluster's project.
+// Disconnected clusters cannot reach public image projects such as rhcos-cloud, so images must be
+// mirrored into the cluster's project.
+func validateGCPDiskImageProjects(disks []*machinev1.GCPDisk, parentPath *field.Path, clusterProjectID string) []string {
+	var warnings []string
+	for i, disk := range disks {
+		if disk == nil || !disk.Boot {
+			continue
 		}
 
-		if accelerator.Type == "nvidia-tesla-a100" {
-			errs = append(errs, field.Invalid(parentPath.Child("Type"), accelerator.Type, " nvidia-tesla-a100 gpus, are only attached to the A2 machine types"))
+		imageProject := gcpImageProject(disk.Image)
+		if imageProject == "" || imageProject == clusterProjectID {
+			continue
 		}
 
-		if strings.HasPrefix(machineType, "a2-") {
-			errs = append(errs, field.Invalid(parentPath, accelerator.Type, "A2 machine types have already attached gpus, additional gpus cannot be specified"))
+		warnings = append(warnings, fmt.Sprintf("%s: image %q is in project %q which is not reachable from a disconnected cluster: mirror the image into the cluster's project (%s) and reference it from there", parentPath.Index(i).Child("image"), disk.Image, imageProject, clusterProjectID))
+	}
+	return warnings
+}
+
+// gcpImageProject returns the project referenced by a GCP image path or URL,
+// for example "rhcos-cloud" for "projects/rhcos-cloud/global/images/rhcos".
+// An empty string is returned when the image does not reference a project.
+func gcpImageProject(image string) string {
+	parts := strings.Split(image, "/")
+	for i := 0; i < len(parts)-1; i++ {
+		if parts[i] == "projects" {
+			return parts[i+1]
 		}
 	}
-	return errs
+	return ""
 }
 
 func validateGCPServiceAccounts(serviceAccounts []machinev1.GCPServiceAccount, parentPath *field.Path) []error {
@@ -1154,11 +1491,15 @@ func defaultVSphere(m *machinev1.Machine, config *admissionConfig) (bool, []stri
 	}
 
 	if providerSpec.UserDataSecret == nil {
-		providerSpec.UserDataSecret = &corev1.LocalObjectReference{Name: defaultUserDataSecret}
+		providerSpec.UserDataSecret = &corev1.LocalObjectReference{Name: config.defaultValue(osconfigv1.VSpherePlatformType, defaultsUserDataSecretKey)}
 	}
 
 	if providerSpec.CredentialsSecret == nil {
-		providerSpec.CredentialsSecret = &corev1.LocalObjectReference{Name: defaultVSphereCredentialsSecret}
+		providerSpec.CredentialsSecret = &corev1.LocalObjectReference{Name: config.defaultValue(osconfigv1.VSpherePlatformType, defaultsCredentialsSecretKey)}
+	}
+
+	if failureDomain, ok := vSphereFailureDomainForMachine(m, config.vSphereFailureDomains); ok {
+		providerSpec.Workspace = defaultVSphereWorkspace(providerSpec.Workspace, failureDomain, config.clusterID)
 	}
 
 	rawBytes, err := json.Marshal(providerSpec)
@@ -1192,6 +1533,7 @@ func validateVSphere(m *machinev1.Machine, config *admissionConfig) (bool, []str
 	workspaceWarnings, workspaceErrors := validateVSphereWorkspace(providerSpec.Workspace, field.NewPath("providerSpec", "workspace"))
 	warnings = append(warnings, workspaceWarnings...)
 	errs = append(errs, workspaceErrors...)
+	warnings = append(warnings, validateVSphereFailureDomain(m, providerSpec, config.vSphereFailureDomains)...)
 
 	errs = append(errs, validateVSphereNetwork(providerSpec.Network, field.NewPath("providerSpec", "network"))...)
 
@@ -1219,7 +1561,7 @@ func validateVSphere(m *machinev1.Machine, config *admissionConfig) (bool, []str
 		if providerSpec.CredentialsSecret.Name == "" {
 			errs = append(errs, field.Required(field.NewPath("providerSpec", "credentialsSecret", "name"), "name must be provided"))
 		} else {
-			warnings = append(warnings, credentialsSecretExists(config.client, providerSpec.CredentialsSecret.Name, m.GetNamespace())...)
+			warnings = append(warnings, validateCredentialsSecret(config.secretsReader(), providerSpec.CredentialsSecret.Name, m.GetNamespace())...)
 		}
 	}
 
@@ -1249,6 +1591,11 @@ func validateVSphereWorkspace(workspace *machinev1.Workspace, parentPath *field.
 			errs = append(errs, field.Invalid(parentPath.Child("folder"), workspace.Folder, errMsg))
 		}
 	}
+	// A bare datastore name may match datastores in several datacenters, or both a datastore
+	// and a datastore cluster. We can't check uniqueness here so only absolute paths are trusted.
+	if workspace.Datastore != "" && !strings.HasPrefix(workspace.Datastore, "/") {
+		warnings = append(warnings, fmt.Sprintf("%s: datastore %q is not an absolute inventory path: if the name is ambiguous, VM placement may be nondeterministic. Use the full inventory path, e.g. %q", parentPath.Child("datastore"), workspace.Datastore, fmt.Sprintf("/%s/datastore/%s", workspace.Datacenter, workspace.Datastore)))
+	}
 
 	return warnings, errs
 }
@@ -1293,6 +1640,70 @@ func validateMachineLifecycleHooks(m, oldM *machinev1.Machine) []error {
 	return errs
 }
 
+// validateMachineProviderSpecOnDelete forbids changes to the providerSpec of a machine which is marked for deletion,
+// as the machine controller relies on it, e.g. on the credentials secret, to terminate the instance.
+// Other changes, such as to labels, annotations and finalizers, remain allowed.
+func validateMachineProviderSpecOnDelete(m, oldM *machinev1.Machine) []error {
+	var errs []error
+	if oldM == nil || !isDeleting(oldM) {
+		return errs
+	}
+
+	if providerSpecChanged(oldM.Spec.ProviderSpec.Value, m.Spec.ProviderSpec.Value) {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "providerSpec", "value"), "providerSpec is immutable when machine is marked for deletion"))
+	}
+	return errs
+}
+
+// providerSpecChanged returns whether the providerSpec values differ, ignoring the formatting of their JSON.
+func providerSpecChanged(oldValue, value *kruntime.RawExtension) bool {
+	if oldValue == nil || value == nil {
+		return oldValue != value
+	}
+	if bytes.Equal(oldValue.Raw, value.Raw) {
+		return false
+	}
+
+	var oldSpec, spec interface{}
+	if err := json.Unmarshal(oldValue.Raw, &oldSpec); err != nil {
+		return true
+	}
+	if err := json.Unmarshal(value.Raw, &spec); err != nil {
+		return true
+	}
+	return !equality.Semantic.DeepEqual(oldSpec, spec)
+}
+
+// validateNodeRoleLabels warns about node role labels which are set on the Machine but not
+// in the Machine's spec.metadata. Only the latter are propagated to the Node.
+func validateNodeRoleLabels(machineLabels, nodeLabels map[string]string, machineLabelsPath, nodeLabelsPath *field.Path) []string {
+	var warnings []string
+	for _, label := range sets.StringKeySet(machineLabels).List() {
+		if !strings.HasPrefix(label, nodeRoleLabelPrefix) {
+			continue
+		}
+		if _, ok := nodeLabels[label]; !ok {
+			warnings = append(warnings, fmt.Sprintf("%s: label %q will not be applied to the Node: node labels must be set in %s", machineLabelsPath, label, nodeLabelsPath))
+		}
+	}
+	return warnings
+}
+
+// validateNodeManagedAnnotations warns about spec.metadata annotations which are owned by node
+// components such as the kubelet or CSI drivers, and would fight their owner if set on the Node.
+func validateNodeManagedAnnotations(nodeAnnotations map[string]string, nodeAnnotationsPath *field.Path) []string {
+	var managed []string
+	for _, key := range sets.StringKeySet(nodeAnnotations).List() {
+		if annotations.IsNodeManaged(key) {
+			managed = append(managed, key)
+		}
+	}
+	if len(managed) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s: annotations %q are managed by node components and must not be set on the Node from the Machine", nodeAnnotationsPath, managed)}
+}
+
 func isDeleting(obj metav1.Object) bool {
 	return obj.GetDeletionTimestamp() != nil
 }
@@ -16,6 +16,7 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	kruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"k8s.io/utils/pointer"
@@ -817,6 +818,36 @@ func TestMachineUpdate(t *testing.T) {
 				m.Spec.LifecycleHooks = machinev1.LifecycleHooks{}
 			},
 		},
+		{
+			name:         "when changing the instance type after the machine has been deleted",
+			platformType: osconfigv1.AWSPlatformType,
+			clusterID:    awsClusterID,
+			baseProviderSpecValue: &kruntime.RawExtension{
+				Object: defaultAWSProviderSpec.DeepCopy(),
+			},
+			updatedProviderSpecValue: func() *kruntime.RawExtension {
+				object := defaultAWSProviderSpec.DeepCopy()
+				object.InstanceType = "m5.xlarge"
+				return &kruntime.RawExtension{
+					Object: object,
+				}
+			},
+			updateAfterDelete: true,
+			expectedError:     "spec.providerSpec.value: Forbidden: providerSpec is immutable when machine is marked for deletion",
+		},
+		{
+			name:         "when changing labels and annotations after the machine has been deleted",
+			platformType: osconfigv1.AWSPlatformType,
+			clusterID:    awsClusterID,
+			baseProviderSpecValue: &kruntime.RawExtension{
+				Object: defaultAWSProviderSpec.DeepCopy(),
+			},
+			updateAfterDelete: true,
+			updateMachine: func(m *machinev1.Machine) {
+				m.Labels = map[string]string{"label": "value"}
+				m.Annotations = map[string]string{"annotation": "value"}
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -1082,6 +1113,13 @@ func TestValidateAWSProviderSpec(t *testing.T) {
 			},
 			expectedOk: true,
 		},
+		{
+			testCase: "with an unknown instance type",
+			modifySpec: func(p *machinev1.AWSMachineProviderConfig) {
+				p.InstanceType = "unknown.large"
+			},
+			expectedOk: true,
+		},
 		{
 			testCase: "with AMI ARN set",
 			modifySpec: func(p *machinev1.AWSMachineProviderConfig) {
@@ -1093,6 +1131,29 @@ func TestValidateAWSProviderSpec(t *testing.T) {
 			expectedOk:       true,
 			expectedWarnings: []string{"can't use providerSpec.ami.arn, only providerSpec.ami.id can be used to reference AMI"},
 		},
+		{
+			testCase: "with AMI ARN of another region",
+			modifySpec: func(p *machinev1.AWSMachineProviderConfig) {
+				p.AMI = machinev1.AWSResourceReference{
+					ID:  pointer.StringPtr("ami"),
+					ARN: pointer.StringPtr("arn:aws:ec2:us-west-2::image/ami-0123456789abcdef0"),
+				}
+			},
+			expectedOk:       false,
+			expectedError:    "providerSpec.ami.arn: Invalid value: \"arn:aws:ec2:us-west-2::image/ami-0123456789abcdef0\": AMI is in region us-west-2, but the machine is placed in region region",
+			expectedWarnings: []string{"can't use providerSpec.ami.arn, only providerSpec.ami.id can be used to reference AMI"},
+		},
+		{
+			testCase: "with AMI ARN of the placement region",
+			modifySpec: func(p *machinev1.AWSMachineProviderConfig) {
+				p.AMI = machinev1.AWSResourceReference{
+					ID:  pointer.StringPtr("ami"),
+					ARN: pointer.StringPtr("arn:aws:ec2:region::image/ami-0123456789abcdef0"),
+				}
+			},
+			expectedOk:       true,
+			expectedWarnings: []string{"can't use providerSpec.ami.arn, only providerSpec.ami.id can be used to reference AMI"},
+		},
 		{
 			testCase: "with AMI filters set",
 			modifySpec: func(p *machinev1.AWSMachineProviderConfig) {
@@ -1225,6 +1286,24 @@ func TestDefaultAWSProviderSpec(t *testing.T) {
 			expectedError:    "",
 			expectedWarnings: nil,
 		},
+		{
+			testCase: "it warns about an instance type smaller than a node",
+			providerSpec: &machinev1.AWSMachineProviderConfig{
+				InstanceType: "t3.micro",
+			},
+			expectedProviderSpec: &machinev1.AWSMachineProviderConfig{
+				InstanceType:      "t3.micro",
+				UserDataSecret:    &corev1.LocalObjectReference{Name: defaultUserDataSecret},
+				CredentialsSecret: &corev1.LocalObjectReference{Name: defaultAWSCredentialsSecret},
+				Placement: machinev1.Placement{
+					Region: "region",
+				},
+			},
+			expectedOk: true,
+			expectedWarnings: []string{
+				"providerSpec.instanceType: t3.micro has 2 vCPUs and 1024 MiB of memory, less than the minimum of a node (2 vCPUs and 8192 MiB): nodes may not become ready",
+			},
+		},
 	}
 
 	platformStatus := &osconfigv1.PlatformStatus{
@@ -1487,6 +1566,40 @@ func TestValidateAzureProviderSpec(t *testing.T) {
 			},
 			expectedOk: true,
 		},
+		{
+			testCase: "with a Gen2 only VM size and a Gen1 image SKU",
+			modifySpec: func(p *machinev1.AzureMachineProviderSpec) {
+				p.VMSize = "Standard_M208ms_v2"
+				p.Image = machinev1.Image{
+					Publisher: "RedHat",
+					Offer:     "RHEL",
+					SKU:       "8",
+					Version:   "latest",
+				}
+			},
+			expectedOk:       true,
+			expectedWarnings: []string{"providerSpec.image.sku: VM size \"Standard_M208ms_v2\" only supports Generation 2 images, but image SKU \"8\" does not look like a Generation 2 image: the VM will fail to deploy unless a Generation 2 SKU, typically suffixed with -gen2, is used"},
+		},
+		{
+			testCase: "with a Gen2 only VM size and a Gen2 image SKU",
+			modifySpec: func(p *machinev1.AzureMachineProviderSpec) {
+				p.VMSize = "Standard_DC8s_v3"
+				p.Image = machinev1.Image{
+					Publisher: "RedHat",
+					Offer:     "RHEL",
+					SKU:       "8-gen2",
+					Version:   "latest",
+				}
+			},
+			expectedOk: true,
+		},
+		{
+			testCase: "with a Gen2 only VM size and an image resourceID",
+			modifySpec: func(p *machinev1.AzureMachineProviderSpec) {
+				p.VMSize = "Standard_M208ms_v2"
+			},
+			expectedOk: true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -2023,7 +2136,7 @@ func TestValidateGCPProviderSpec(t *testing.T) {
 				}
 			},
 			expectedOk:    false,
-			expectedError: "providerSpec.onHostMaintenance: Forbidden: When GPUs are specified or using machineType with pre-attached GPUs(A2 machine family), onHostMaintenance must be set to Terminate.",
+			expectedError: "providerSpec.onHostMaintenance: Forbidden: When GPUs are specified or using machineType with pre-attached GPUs(A2 and G2 machine families), onHostMaintenance must be set to Terminate.",
 		},
 	}
 
@@ -2113,6 +2226,187 @@ func TestValidateGCPProviderSpec(t *testing.T) {
 	}
 }
 
+func TestValidateGCPDiskImageProjectDisconnected(t *testing.T) {
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "gcp-validation-test",
+		},
+	}
+
+	testCases := []struct {
+		testCase         string
+		disconnected     bool
+		image            string
+		boot             bool
+		expectedWarnings []string
+	}{
+		{
+			testCase:         "with a public image in a connected cluster",
+			image:            "projects/rhcos-cloud/global/images/rhcos",
+			boot:             true,
+			expectedWarnings: nil,
+		},
+		{
+			testCase:     "with a public image in a disconnected cluster",
+			disconnected: true,
+			image:        "projects/rhcos-cloud/global/images/rhcos",
+			boot:         true,
+			expectedWarnings: []string{
+				"providerSpec.disks[0].image: image \"projects/rhcos-cloud/global/images/rhcos\" is in project \"rhcos-cloud\" which is not reachable from a disconnected cluster: mirror the image into the cluster's project (projectID) and reference it from there",
+			},
+		},
+		{
+			testCase:     "with a public image URL in a disconnected cluster",
+			disconnected: true,
+			image:        "https://www.googleapis.com/compute/v1/projects/rhcos-cloud/global/images/rhcos",
+			boot:         true,
+			expectedWarnings: []string{
+				"providerSpec.disks[0].image: image \"https://www.googleapis.com/compute/v1/projects/rhcos-cloud/global/images/rhcos\" is in project \"rhcos-cloud\" which is not reachable from a disconnected cluster: mirror the image into the cluster's project (projectID) and reference it from there",
+			},
+		},
+		{
+			testCase:         "with a mirrored image in a disconnected cluster",
+			disconnected:     true,
+			image:            "projects/projectID/global/images/rhcos",
+			boot:             true,
+			expectedWarnings: nil,
+		},
+		{
+			testCase:         "with an image name in a disconnected cluster",
+			disconnected:     true,
+			image:            "rhcos",
+			boot:             true,
+			expectedWarnings: nil,
+		},
+		{
+			testCase:         "with a public image on a non boot disk in a disconnected cluster",
+			disconnected:     true,
+			image:            "projects/rhcos-cloud/global/images/rhcos",
+			boot:             false,
+			expectedWarnings: nil,
+		},
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "name",
+			Namespace: namespace.Name,
+		},
+	}
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, secret)
+	infra := plainInfra.DeepCopy()
+	infra.Status.InfrastructureName = "clusterID"
+	infra.Status.PlatformStatus.Type = osconfigv1.GCPPlatformType
+	infra.Status.PlatformStatus.GCP = &osconfigv1.GCPPlatformStatus{
+		ProjectID: "projectID",
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			dns := plainDNS.DeepCopy()
+			if !tc.disconnected {
+				dns.Spec.PublicZone = &osconfigv1.DNSZone{}
+			}
+			h := createMachineValidator(infra, c, dns)
+
+			providerSpec := &machinev1.GCPMachineProviderSpec{
+				Region:            "region",
+				Zone:              "region-zone",
+				ProjectID:         "projectID",
+				MachineType:       "machineType",
+				OnHostMaintenance: machinev1.TerminateHostMaintenanceType,
+				NetworkInterfaces: []*machinev1.GCPNetworkInterface{
+					{
+						Network:    "network",
+						Subnetwork: "subnetwork",
+					},
+				},
+				Disks: []*machinev1.GCPDisk{
+					{
+						Boot:   tc.boot,
+						Image:  tc.image,
+						SizeGB: 16,
+					},
+				},
+				ServiceAccounts: []machinev1.GCPServiceAccount{
+					{
+						Email:  "email",
+						Scopes: []string{gcpCloudPlatformScope},
+					},
+				},
+				UserDataSecret: &corev1.LocalObjectReference{
+					Name: "name",
+				},
+				CredentialsSecret: &corev1.LocalObjectReference{
+					Name: "name",
+				},
+			}
+
+			m := &machinev1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: namespace.Name,
+				},
+			}
+			rawBytes, err := json.Marshal(providerSpec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.Spec.ProviderSpec.Value = &kruntime.RawExtension{Raw: rawBytes}
+
+			ok, warnings, err := h.webhookOperations(m, h.admissionConfig)
+			if !ok {
+				t.Errorf("expected the machine to be admitted, got: %v", err)
+			}
+
+			if !reflect.DeepEqual(warnings, tc.expectedWarnings) {
+				t.Errorf("expected: %q, got: %q", tc.expectedWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestValidateGCPNodeLabels(t *testing.T) {
+	nodeLabels := func(count int) map[string]string {
+		labels := make(map[string]string, count)
+		for i := 0; i < count; i++ {
+			labels[fmt.Sprintf("example.com/label-%d", i)] = "value"
+		}
+		return labels
+	}
+
+	testCases := []struct {
+		testCase         string
+		nodeLabels       map[string]string
+		expectedWarnings []string
+	}{
+		{
+			testCase:         "without node labels",
+			expectedWarnings: nil,
+		},
+		{
+			testCase:         "with 60 node labels",
+			nodeLabels:       nodeLabels(60),
+			expectedWarnings: nil,
+		},
+		{
+			testCase:   "with 61 node labels",
+			nodeLabels: nodeLabels(61),
+			expectedWarnings: []string{
+				"spec.metadata.labels: 61 labels are set: these are Node labels and are not applied to the GCE instance, set GCE instance labels in providerSpec.labels instead",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			warnings := validateGCPNodeLabels(tc.nodeLabels, field.NewPath("spec", "metadata", "labels"))
+			if !reflect.DeepEqual(warnings, tc.expectedWarnings) {
+				t.Errorf("expected: %q, got: %q", tc.expectedWarnings, warnings)
+			}
+		})
+	}
+}
+
 func TestDefaultGCPProviderSpec(t *testing.T) {
 
 	clusterID := "clusterID"
@@ -2317,6 +2611,29 @@ func TestValidateVSphereProviderSpec(t *testing.T) {
 			expectedOk:    false,
 			expectedError: "providerSpec.workspace.folder: Invalid value: \"/foo/vm/folder\": folder must be absolute path: expected prefix \"/datacenter/vm/\"",
 		},
+		{
+			testCase: "with a bare workspace datastore name",
+			modifySpec: func(p *machinev1.VSphereMachineProviderSpec) {
+				p.Workspace = &machinev1.Workspace{
+					Server:     "server",
+					Datacenter: "datacenter",
+					Datastore:  "datastore",
+				}
+			},
+			expectedOk:       true,
+			expectedWarnings: []string{"providerSpec.workspace.datastore: datastore \"datastore\" is not an absolute inventory path: if the name is ambiguous, VM placement may be nondeterministic. Use the full inventory path, e.g. \"/datacenter/datastore/datastore\""},
+		},
+		{
+			testCase: "with an absolute workspace datastore path",
+			modifySpec: func(p *machinev1.VSphereMachineProviderSpec) {
+				p.Workspace = &machinev1.Workspace{
+					Server:     "server",
+					Datacenter: "datacenter",
+					Datastore:  "/datacenter/datastore/datastore",
+				}
+			},
+			expectedOk: true,
+		},
 		{
 			testCase: "with no network devices provided",
 			modifySpec: func(p *machinev1.VSphereMachineProviderSpec) {
@@ -2520,6 +2837,8 @@ func TestDefaultVSphereProviderSpec(t *testing.T) {
 	testCases := []struct {
 		testCase         string
 		providerSpec     *machinev1.VSphereMachineProviderSpec
+		labels           map[string]string
+		failureDomains   []vSphereFailureDomain
 		modifyDefault    func(*machinev1.VSphereMachineProviderSpec)
 		expectedError    string
 		expectedOk       bool
@@ -2531,6 +2850,43 @@ func TestDefaultVSphereProviderSpec(t *testing.T) {
 			expectedOk:    true,
 			expectedError: "",
 		},
+		{
+			testCase:       "it defaults the workspace from the only failure domain",
+			providerSpec:   &machinev1.VSphereMachineProviderSpec{},
+			failureDomains: testVSphereFailureDomains[:1],
+			modifyDefault: func(p *machinev1.VSphereMachineProviderSpec) {
+				p.Workspace = &machinev1.Workspace{
+					Server:       "vcenter.example.com",
+					Datacenter:   "dc1",
+					Datastore:    "/dc1/datastore/datastore1",
+					ResourcePool: "/dc1/host/cluster1/Resources",
+					Folder:       "/dc1/vm/clusterID",
+				}
+			},
+			expectedOk: true,
+		},
+		{
+			testCase:       "it defaults the workspace from the failure domain of the topology labels",
+			providerSpec:   &machinev1.VSphereMachineProviderSpec{},
+			labels:         map[string]string{machineRegionLabel: "us-east", machineZoneLabel: "us-east-1b"},
+			failureDomains: testVSphereFailureDomains,
+			modifyDefault: func(p *machinev1.VSphereMachineProviderSpec) {
+				p.Workspace = &machinev1.Workspace{
+					Server:       "vcenter.example.com",
+					Datacenter:   "dc2",
+					Datastore:    "/dc2/datastore/datastore2",
+					ResourcePool: "/dc2/host/cluster2/Resources",
+					Folder:       "/dc2/vm/clusterID",
+				}
+			},
+			expectedOk: true,
+		},
+		{
+			testCase:       "it does not default the workspace without topology labels on a zonal cluster",
+			providerSpec:   &machinev1.VSphereMachineProviderSpec{},
+			failureDomains: testVSphereFailureDomains,
+			expectedOk:     true,
+		},
 	}
 
 	platformStatus := &osconfigv1.PlatformStatus{Type: osconfigv1.VSpherePlatformType}
@@ -2550,14 +2906,16 @@ func TestDefaultVSphereProviderSpec(t *testing.T) {
 				tc.modifyDefault(defaultProviderSpec)
 			}
 
-			m := &machinev1.Machine{}
+			m := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Labels: tc.labels}}
 			rawBytes, err := json.Marshal(tc.providerSpec)
 			if err != nil {
 				t.Fatal(err)
 			}
 			m.Spec.ProviderSpec.Value = &kruntime.RawExtension{Raw: rawBytes}
 
-			ok, warnings, err := h.webhookOperations(m, h.admissionConfig)
+			config := *h.admissionConfig
+			config.vSphereFailureDomains = tc.failureDomains
+			ok, warnings, err := h.webhookOperations(m, &config)
 			if ok != tc.expectedOk {
 				t.Errorf("expected: %v, got: %v", tc.expectedOk, ok)
 			}
@@ -2586,3 +2944,293 @@ func TestDefaultVSphereProviderSpec(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateMachineNodeRoleLabels(t *testing.T) {
+	testCases := []struct {
+		testCase         string
+		labels           map[string]string
+		nodeLabels       map[string]string
+		expectedWarnings []string
+	}{
+		{
+			testCase:         "with the node role label only on the machine",
+			labels:           map[string]string{"node-role.kubernetes.io/infra": ""},
+			expectedWarnings: []string{"metadata.labels: label \"node-role.kubernetes.io/infra\" will not be applied to the Node: node labels must be set in spec.metadata.labels"},
+		},
+		{
+			testCase:         "with the node role label in spec.metadata.labels",
+			nodeLabels:       map[string]string{"node-role.kubernetes.io/infra": ""},
+			expectedWarnings: []string{},
+		},
+		{
+			testCase:         "with the node role label in both places",
+			labels:           map[string]string{"node-role.kubernetes.io/infra": ""},
+			nodeLabels:       map[string]string{"node-role.kubernetes.io/infra": ""},
+			expectedWarnings: []string{},
+		},
+	}
+
+	h := createMachineValidator(plainInfra, fake.NewFakeClientWithScheme(scheme.Scheme), plainDNS)
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			m := &machinev1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: tc.labels,
+				},
+				Spec: machinev1.MachineSpec{
+					ObjectMeta: machinev1.ObjectMeta{
+						Labels: tc.nodeLabels,
+					},
+				},
+			}
+
+			ok, warnings, err := h.validateMachine(m, nil)
+			if !ok {
+				t.Errorf("expected machine to be valid, got: %v", err)
+			}
+
+			if !reflect.DeepEqual(warnings, tc.expectedWarnings) {
+				t.Errorf("expected: %q, got: %q", tc.expectedWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestValidateMachineNodeManagedAnnotations(t *testing.T) {
+	testCases := []struct {
+		testCase         string
+		nodeAnnotations  map[string]string
+		expectedWarnings []string
+	}{
+		{
+			testCase: "with kubelet and CSI managed annotations",
+			nodeAnnotations: map[string]string{
+				"volumes.kubernetes.io/controller-managed-attach-detach": "true",
+				"node.alpha.kubernetes.io/ttl":                           "0",
+				"csi.volume.kubernetes.io/nodeid":                        "{}",
+				"example.com/owner":                                      "team",
+			},
+			expectedWarnings: []string{"spec.metadata.annotations: annotations [\"csi.volume.kubernetes.io/nodeid\" \"node.alpha.kubernetes.io/ttl\" \"volumes.kubernetes.io/controller-managed-attach-detach\"] are managed by node components and must not be set on the Node from the Machine"},
+		},
+		{
+			testCase:         "with only unmanaged annotations",
+			nodeAnnotations:  map[string]string{"example.com/owner": "team"},
+			expectedWarnings: []string{},
+		},
+		{
+			testCase:         "with no annotations",
+			expectedWarnings: []string{},
+		},
+	}
+
+	h := createMachineValidator(plainInfra, fake.NewFakeClientWithScheme(scheme.Scheme), plainDNS)
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			m := &machinev1.Machine{
+				Spec: machinev1.MachineSpec{
+					ObjectMeta: machinev1.ObjectMeta{
+						Annotations: tc.nodeAnnotations,
+					},
+				},
+			}
+
+			ok, warnings, err := h.validateMachine(m, nil)
+			if !ok {
+				t.Errorf("expected machine to be valid, got: %v", err)
+			}
+
+			if !reflect.DeepEqual(warnings, tc.expectedWarnings) {
+				t.Errorf("expected: %q, got: %q", tc.expectedWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestValidateCredentialsSecret(t *testing.T) {
+	testCases := []struct {
+		testCase         string
+		secret           *corev1.Secret
+		expectedWarnings []string
+	}{
+		{
+			testCase: "with an Opaque secret",
+			secret: &corev1.Secret{
+				Type: corev1.SecretTypeOpaque,
+			},
+			expectedWarnings: []string{},
+		},
+		{
+			testCase:         "with a secret without type",
+			secret:           &corev1.Secret{},
+			expectedWarnings: []string{},
+		},
+		{
+			testCase: "with a service account token secret",
+			secret: &corev1.Secret{
+				Type: corev1.SecretTypeServiceAccountToken,
+			},
+			expectedWarnings: []string{"providerSpec.credentialsSecret: credentials has type kubernetes.io/service-account-token: credentials secrets are expected to be of type Opaque and the machine controller may fail to read it"},
+		},
+		{
+			testCase: "with a docker registry secret",
+			secret: &corev1.Secret{
+				Type: corev1.SecretTypeDockerConfigJson,
+			},
+			expectedWarnings: []string{"providerSpec.credentialsSecret: credentials has type kubernetes.io/dockerconfigjson: credentials secrets are expected to be of type Opaque and the machine controller may fail to read it"},
+		},
+		{
+			testCase: "with an immutable secret managed by the cloud-credential-operator",
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						credentialsRequestAnnotation: "openshift-cloud-credential-operator/openshift-machine-api-aws",
+					},
+				},
+				Type:      corev1.SecretTypeOpaque,
+				Immutable: pointer.BoolPtr(true),
+			},
+			expectedWarnings: []string{"providerSpec.credentialsSecret: credentials is immutable but managed by the cloud-credential-operator: rotating its credentials requires the secret to be recreated"},
+		},
+		{
+			testCase: "with an immutable secret not managed by the cloud-credential-operator",
+			secret: &corev1.Secret{
+				Type:      corev1.SecretTypeOpaque,
+				Immutable: pointer.BoolPtr(true),
+			},
+			expectedWarnings: []string{},
+		},
+		{
+			testCase:         "with a missing secret",
+			secret:           nil,
+			expectedWarnings: []string{"providerSpec.credentialsSecret: Invalid value: \"credentials\": not found. Expected CredentialsSecret to exist"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			c := fake.NewFakeClientWithScheme(scheme.Scheme)
+			if tc.secret != nil {
+				secret := tc.secret.DeepCopy()
+				secret.Name = "credentials"
+				secret.Namespace = "namespace"
+				c = fake.NewFakeClientWithScheme(scheme.Scheme, secret)
+			}
+
+			warnings := validateCredentialsSecret(c, "credentials", "namespace")
+			if !reflect.DeepEqual(warnings, tc.expectedWarnings) {
+				t.Errorf("expected: %q, got: %q", tc.expectedWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestValidateMachineProviderSpecOnDelete(t *testing.T) {
+	deletionTimestamp := metav1.Now()
+
+	machine := func(deleting bool, providerSpec string) *machinev1.Machine {
+		m := &machinev1.Machine{
+			Spec: machinev1.MachineSpec{
+				ProviderSpec: machinev1.ProviderSpec{
+					Value: &kruntime.RawExtension{Raw: []byte(providerSpec)},
+				},
+			},
+		}
+		if deleting {
+			m.DeletionTimestamp = &deletionTimestamp
+		}
+		return m
+	}
+
+	testCases := []struct {
+		testCase      string
+		oldM          *machinev1.Machine
+		m             *machinev1.Machine
+		expectedError string
+	}{
+		{
+			testCase: "on create",
+			m:        machine(true, `{"instanceType":"m5.large"}`),
+		},
+		{
+			testCase: "when changing the providerSpec of a machine which is not deleted",
+			oldM:     machine(false, `{"instanceType":"m5.large"}`),
+			m:        machine(false, `{"instanceType":"m5.xlarge"}`),
+		},
+		{
+			testCase:      "when changing the providerSpec of a deleted machine",
+			oldM:          machine(true, `{"instanceType":"m5.large"}`),
+			m:             machine(true, `{"instanceType":"m5.xlarge"}`),
+			expectedError: "spec.providerSpec.value: Forbidden: providerSpec is immutable when machine is marked for deletion",
+		},
+		{
+			testCase: "when reformatting the providerSpec of a deleted machine",
+			oldM:     machine(true, `{"instanceType":"m5.large","placement":{"region":"region"}}`),
+			m:        machine(true, `{"placement": {"region": "region"}, "instanceType": "m5.large"}`),
+		},
+		{
+			testCase:      "when removing the providerSpec of a deleted machine",
+			oldM:          machine(true, `{"instanceType":"m5.large"}`),
+			m:             &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &deletionTimestamp}},
+			expectedError: "spec.providerSpec.value: Forbidden: providerSpec is immutable when machine is marked for deletion",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			errs := validateMachineProviderSpecOnDelete(tc.m, tc.oldM)
+			if tc.expectedError == "" {
+				if len(errs) > 0 {
+					t.Errorf("expected no error, got: %v", errs)
+				}
+				return
+			}
+			if len(errs) != 1 || errs[0].Error() != tc.expectedError {
+				t.Errorf("expected error %q, got: %v", tc.expectedError, errs)
+			}
+		})
+	}
+}
+
+func TestDefaultingUsesCurrentInfrastructure(t *testing.T) {
+	g := NewWithT(t)
+
+	infra := plainInfra.DeepCopy()
+	infra.Name = clusterConfigName
+	infra.Status.InfrastructureName = "clusterID"
+	infra.Status.PlatformStatus = &osconfigv1.PlatformStatus{
+		Type: osconfigv1.AWSPlatformType,
+		AWS:  &osconfigv1.AWSPlatformStatus{Region: "us-east-1"},
+	}
+	dns := &osconfigv1.DNS{ObjectMeta: metav1.ObjectMeta{Name: clusterConfigName}}
+	reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(infra, dns).Build()
+
+	h := createMachineDefaulter(infra.Status.PlatformStatus, infra.Status.InfrastructureName)
+	h.inputs = newClusterInputsCache(reader)
+	h.inputs.refreshInterval = 0
+
+	defaultRegion := func() string {
+		rawBytes, err := json.Marshal(&machinev1.AWSMachineProviderConfig{})
+		g.Expect(err).ToNot(HaveOccurred())
+		m := &machinev1.Machine{}
+		m.Spec.ProviderSpec.Value = &kruntime.RawExtension{Raw: rawBytes}
+
+		ok, _, errs := h.webhookOperations(m, h.currentConfig())
+		g.Expect(ok).To(BeTrue(), fmt.Sprintf("%v", errs))
+
+		providerSpec := &machinev1.AWSMachineProviderConfig{}
+		g.Expect(json.Unmarshal(m.Spec.ProviderSpec.Value.Raw, providerSpec)).To(Succeed())
+		return providerSpec.Placement.Region
+	}
+
+	g.Expect(defaultRegion()).To(Equal("us-east-1"))
+
+	// The region is corrected after the defaulter was created.
+	infra.Status.PlatformStatus.AWS.Region = "us-west-2"
+	infra.Status.InfrastructureName = "correctedClusterID"
+	g.Expect(reader.Update(context.Background(), infra)).To(Succeed())
+
+	g.Expect(defaultRegion()).To(Equal("us-west-2"), "the defaulter should pick up the current region without a restart")
+	g.Expect(h.currentConfig().clusterID).To(Equal("correctedClusterID"))
+}
@@ -0,0 +1,166 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/util/schedule"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	DefaultMachineHealthCheckValidatingHookPath = "/validate-machine-openshift-io-v1beta1-machinehealthcheck"
+
+	// defaultMHCNodeStartupTimeout is the nodeStartupTimeout the MachineHealthCheck controller uses when it is not set.
+	defaultMHCNodeStartupTimeout = 10 * time.Minute
+	// minMHCUnhealthyConditionTimeout is the shortest unhealthy condition timeout which does not risk
+	// remediating nodes for transient condition changes, such as a kubelet restart.
+	minMHCUnhealthyConditionTimeout = time.Minute
+	// mhcNegatedConditionStatusPrefix prefixes an unhealthy condition status to match node conditions
+	// which are absent or in any other status, eg. "!True".
+	mhcNegatedConditionStatusPrefix = "!"
+	// mhcRemediationScheduleAnnotation is the cron-style schedule of the minutes during which
+	// a MachineHealthCheck may remediate machines.
+	mhcRemediationScheduleAnnotation = "machine.openshift.io/remediation-schedule"
+)
+
+// machineHealthCheckValidatorHandler validates MachineHealthCheck resources.
+// The MachineHealthCheck validations only warn, they never deny a request.
+// implements type Handler interface.
+// https://godoc.org/github.com/kubernetes-sigs/controller-runtime/pkg/webhook/admission#Handler
+type machineHealthCheckValidatorHandler struct {
+	decoder *admission.Decoder
+}
+
+// NewMachineHealthCheckValidator returns a new machineHealthCheckValidatorHandler.
+func NewMachineHealthCheckValidator() *machineHealthCheckValidatorHandler {
+	return &machineHealthCheckValidatorHandler{}
+}
+
+// InjectDecoder injects the decoder.
+func (h *machineHealthCheckValidatorHandler) InjectDecoder(d *admission.Decoder) error {
+	h.decoder = d
+	return nil
+}
+
+// Handle handles HTTP requests for admission webhook servers.
+func (h *machineHealthCheckValidatorHandler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	mhc := &machinev1.MachineHealthCheck{}
+
+	if err := h.decoder.Decode(req, mhc); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	klog.V(3).Infof("Validate webhook called for MachineHealthCheck: %s", mhc.GetName())
+
+	warnings := validateMachineHealthCheckTimeouts(mhc)
+	warnings = append(warnings, validateMachineHealthCheckConditionStatuses(mhc)...)
+	warnings = append(warnings, validateMachineHealthCheckRemediationSchedule(mhc)...)
+	return admission.Allowed("MachineHealthCheck valid").WithWarnings(warnings...)
+}
+
+// MachineHealthCheckValidatingWebhook returns validating webhooks for machineHealthCheck to populate the configuration
+func MachineHealthCheckValidatingWebhook() admissionregistrationv1.ValidatingWebhook {
+	serviceReference := admissionregistrationv1.ServiceReference{
+		Namespace: defaultWebhookServiceNamespace,
+		Name:      defaultWebhookServiceName,
+		Path:      pointer.StringPtr(DefaultMachineHealthCheckValidatingHookPath),
+		Port:      pointer.Int32Ptr(defaultWebhookServicePort),
+	}
+	return admissionregistrationv1.ValidatingWebhook{
+		AdmissionReviewVersions: []string{"v1"},
+		Name:                    "validation.machinehealthcheck.machine.openshift.io",
+		FailurePolicy:           &webhookFailurePolicy,
+		SideEffects:             &webhookSideEffects,
+		ClientConfig: admissionregistrationv1.WebhookClientConfig{
+			Service: &serviceReference,
+		},
+		Rules: []admissionregistrationv1.RuleWithOperations{
+			{
+				Rule: admissionregistrationv1.Rule{
+					APIGroups:   []string{machinev1.GroupName},
+					APIVersions: []string{machinev1.SchemeGroupVersion.Version},
+					Resources:   []string{"machinehealthchecks"},
+				},
+				Operations: []admissionregistrationv1.OperationType{
+					admissionregistrationv1.Create,
+					admissionregistrationv1.Update,
+				},
+			},
+		},
+	}
+}
+
+// validateMachineHealthCheckTimeouts warns about unhealthy condition timeouts which are unlikely to be intended:
+// timeouts much longer than the nodeStartupTimeout remediate runtime failures slower than startup failures,
+// and timeouts which are all very short remediate nodes for transient condition changes.
+func validateMachineHealthCheckTimeouts(mhc *machinev1.MachineHealthCheck) []string {
+	var warnings []string
+	conditionsPath := field.NewPath("spec", "unhealthyConditions")
+
+	nodeStartupTimeout := defaultMHCNodeStartupTimeout
+	if mhc.Spec.NodeStartupTimeout != nil {
+		nodeStartupTimeout = mhc.Spec.NodeStartupTimeout.Duration
+	}
+
+	// A nodeStartupTimeout of 0 disables startup checks, so there is nothing to compare with.
+	if nodeStartupTimeout > 0 {
+		for i, condition := range mhc.Spec.UnhealthyConditions {
+			if condition.Timeout.Duration > 2*nodeStartupTimeout {
+				warnings = append(warnings, fmt.Sprintf("%s: timeout %s for condition %s=%s is more than twice the nodeStartupTimeout (%s): machines failing to start are remediated sooner than nodes failing at runtime", conditionsPath.Index(i).Child("timeout"), condition.Timeout.Duration, condition.Type, condition.Status, nodeStartupTimeout))
+			}
+		}
+	}
+
+	if len(mhc.Spec.UnhealthyConditions) > 0 {
+		allShort := true
+		for _, condition := range mhc.Spec.UnhealthyConditions {
+			if condition.Timeout.Duration >= minMHCUnhealthyConditionTimeout {
+				allShort = false
+				break
+			}
+		}
+		if allShort {
+			warnings = append(warnings, fmt.Sprintf("%s: all timeouts are shorter than %s: nodes may be remediated for transient condition changes, causing remediation churn", conditionsPath, minMHCUnhealthyConditionTimeout))
+		}
+	}
+
+	return warnings
+}
+
+// validateMachineHealthCheckConditionStatuses warns about unhealthy condition statuses which no node condition
+// can be in, so that the condition never matches. Statuses are True, False or Unknown, optionally negated.
+func validateMachineHealthCheckConditionStatuses(mhc *machinev1.MachineHealthCheck) []string {
+	var warnings []string
+	conditionsPath := field.NewPath("spec", "unhealthyConditions")
+	for i, condition := range mhc.Spec.UnhealthyConditions {
+		switch corev1.ConditionStatus(strings.TrimPrefix(string(condition.Status), mhcNegatedConditionStatusPrefix)) {
+		case corev1.ConditionTrue, corev1.ConditionFalse, corev1.ConditionUnknown:
+		default:
+			warnings = append(warnings, fmt.Sprintf("%s: status %q never matches the %s condition: expected one of True, False, Unknown, optionally prefixed with %s to match any other status", conditionsPath.Index(i).Child("status"), condition.Status, condition.Type, mhcNegatedConditionStatusPrefix))
+		}
+	}
+	return warnings
+}
+
+// validateMachineHealthCheckRemediationSchedule warns about an invalid remediation schedule,
+// which the MachineHealthCheck controller ignores, remediating machines at any time.
+func validateMachineHealthCheckRemediationSchedule(mhc *machinev1.MachineHealthCheck) []string {
+	value, ok := mhc.Annotations[mhcRemediationScheduleAnnotation]
+	if !ok {
+		return nil
+	}
+	if _, err := schedule.Parse(value); err != nil {
+		return []string{fmt.Sprintf("%s: invalid schedule %q, machines are remediated at any time: %v", field.NewPath("metadata", "annotations").Key(mhcRemediationScheduleAnnotation), value, err)}
+	}
+	return nil
+}
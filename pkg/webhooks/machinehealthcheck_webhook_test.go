@@ -0,0 +1,153 @@
+package webhooks
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateMachineHealthCheckTimeouts(t *testing.T) {
+	readyUnknown := func(timeout time.Duration) machinev1.UnhealthyCondition {
+		return machinev1.UnhealthyCondition{Type: corev1.NodeReady, Status: corev1.ConditionUnknown, Timeout: metav1.Duration{Duration: timeout}}
+	}
+	readyFalse := func(timeout time.Duration) machinev1.UnhealthyCondition {
+		return machinev1.UnhealthyCondition{Type: corev1.NodeReady, Status: corev1.ConditionFalse, Timeout: metav1.Duration{Duration: timeout}}
+	}
+
+	testCases := []struct {
+		testCase           string
+		nodeStartupTimeout *metav1.Duration
+		conditions         []machinev1.UnhealthyCondition
+		expectedWarnings   []string
+	}{
+		{
+			testCase:           "with timeouts shorter than the nodeStartupTimeout",
+			nodeStartupTimeout: &metav1.Duration{Duration: 10 * time.Minute},
+			conditions:         []machinev1.UnhealthyCondition{readyUnknown(5 * time.Minute), readyFalse(5 * time.Minute)},
+			expectedWarnings:   nil,
+		},
+		{
+			testCase:           "with a timeout of twice the nodeStartupTimeout",
+			nodeStartupTimeout: &metav1.Duration{Duration: 10 * time.Minute},
+			conditions:         []machinev1.UnhealthyCondition{readyUnknown(20 * time.Minute)},
+			expectedWarnings:   nil,
+		},
+		{
+			testCase:           "with a timeout of more than twice the nodeStartupTimeout",
+			nodeStartupTimeout: &metav1.Duration{Duration: 10 * time.Minute},
+			conditions:         []machinev1.UnhealthyCondition{readyUnknown(5 * time.Minute), readyFalse(30 * time.Minute)},
+			expectedWarnings: []string{
+				"spec.unhealthyConditions[1].timeout: timeout 30m0s for condition Ready=False is more than twice the nodeStartupTimeout (10m0s): machines failing to start are remediated sooner than nodes failing at runtime",
+			},
+		},
+		{
+			testCase:   "with a timeout of more than twice the default nodeStartupTimeout",
+			conditions: []machinev1.UnhealthyCondition{readyUnknown(25 * time.Minute)},
+			expectedWarnings: []string{
+				"spec.unhealthyConditions[0].timeout: timeout 25m0s for condition Ready=Unknown is more than twice the nodeStartupTimeout (10m0s): machines failing to start are remediated sooner than nodes failing at runtime",
+			},
+		},
+		{
+			testCase:           "with startup checks disabled",
+			nodeStartupTimeout: &metav1.Duration{Duration: 0},
+			conditions:         []machinev1.UnhealthyCondition{readyUnknown(time.Hour)},
+			expectedWarnings:   nil,
+		},
+		{
+			testCase:           "with all timeouts shorter than a minute",
+			nodeStartupTimeout: &metav1.Duration{Duration: 10 * time.Minute},
+			conditions:         []machinev1.UnhealthyCondition{readyUnknown(30 * time.Second), readyFalse(45 * time.Second)},
+			expectedWarnings: []string{
+				"spec.unhealthyConditions: all timeouts are shorter than 1m0s: nodes may be remediated for transient condition changes, causing remediation churn",
+			},
+		},
+		{
+			testCase:           "with some timeouts shorter than a minute",
+			nodeStartupTimeout: &metav1.Duration{Duration: 10 * time.Minute},
+			conditions:         []machinev1.UnhealthyCondition{readyUnknown(30 * time.Second), readyFalse(time.Minute)},
+			expectedWarnings:   nil,
+		},
+		{
+			testCase:           "with a short and an overlong timeout",
+			nodeStartupTimeout: &metav1.Duration{Duration: 10 * time.Second},
+			conditions:         []machinev1.UnhealthyCondition{readyUnknown(30 * time.Second)},
+			expectedWarnings: []string{
+				"spec.unhealthyConditions[0].timeout: timeout 30s for condition Ready=Unknown is more than twice the nodeStartupTimeout (10s): machines failing to start are remediated sooner than nodes failing at runtime",
+				"spec.unhealthyConditions: all timeouts are shorter than 1m0s: nodes may be remediated for transient condition changes, causing remediation churn",
+			},
+		},
+		{
+			testCase:           "without unhealthy conditions",
+			nodeStartupTimeout: &metav1.Duration{Duration: 10 * time.Minute},
+			expectedWarnings:   nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			mhc := &machinev1.MachineHealthCheck{
+				Spec: machinev1.MachineHealthCheckSpec{
+					NodeStartupTimeout:  tc.nodeStartupTimeout,
+					UnhealthyConditions: tc.conditions,
+				},
+			}
+
+			warnings := validateMachineHealthCheckTimeouts(mhc)
+			if !reflect.DeepEqual(warnings, tc.expectedWarnings) {
+				t.Errorf("expected: %q, got: %q", tc.expectedWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestValidateMachineHealthCheckConditionStatuses(t *testing.T) {
+	mhc := &machinev1.MachineHealthCheck{
+		Spec: machinev1.MachineHealthCheckSpec{
+			UnhealthyConditions: []machinev1.UnhealthyCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionUnknown},
+				{Type: corev1.NodeReady, Status: "!True"},
+				{Type: corev1.NodeNetworkUnavailable, Status: "true"},
+				{Type: corev1.NodeReady, Status: "!"},
+			},
+		},
+	}
+
+	expectedWarnings := []string{
+		`spec.unhealthyConditions[2].status: status "true" never matches the NetworkUnavailable condition: expected one of True, False, Unknown, optionally prefixed with ! to match any other status`,
+		`spec.unhealthyConditions[3].status: status "!" never matches the Ready condition: expected one of True, False, Unknown, optionally prefixed with ! to match any other status`,
+	}
+	if warnings := validateMachineHealthCheckConditionStatuses(mhc); !reflect.DeepEqual(warnings, expectedWarnings) {
+		t.Errorf("expected warnings: %q, got: %q", expectedWarnings, warnings)
+	}
+}
+
+func TestValidateMachineHealthCheckRemediationSchedule(t *testing.T) {
+	testCases := []struct {
+		schedule         string
+		expectedWarnings []string
+	}{
+		{schedule: "* 1-4 * * 1-5"},
+		{
+			schedule:         "* 25 * * *",
+			expectedWarnings: []string{`metadata.annotations[machine.openshift.io/remediation-schedule]: invalid schedule "* 25 * * *", machines are remediated at any time: invalid hour "25": must be an integer between 0 and 23`},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.schedule, func(t *testing.T) {
+			mhc := &machinev1.MachineHealthCheck{}
+			mhc.Annotations = map[string]string{mhcRemediationScheduleAnnotation: tc.schedule}
+			if warnings := validateMachineHealthCheckRemediationSchedule(mhc); !reflect.DeepEqual(warnings, tc.expectedWarnings) {
+				t.Errorf("expected warnings: %q, got: %q", tc.expectedWarnings, warnings)
+			}
+		})
+	}
+
+	if warnings := validateMachineHealthCheckRemediationSchedule(&machinev1.MachineHealthCheck{}); warnings != nil {
+		t.Errorf("expected no warnings without a schedule, got: %q", warnings)
+	}
+}
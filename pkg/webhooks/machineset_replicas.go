@@ -0,0 +1,192 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// scaleSubResource is the subresource of the admission requests scaling a MachineSet, eg. with oc scale
+	// or by the cluster autoscaler.
+	scaleSubResource = "scale"
+
+	// machineSetMinReplicasAnnotation and machineSetMaxReplicasAnnotation bound the replicas of a single
+	// MachineSet. They take precedence over the limits of the validation policy.
+	machineSetMinReplicasAnnotation = "machine.openshift.io/min-replicas"
+	machineSetMaxReplicasAnnotation = "machine.openshift.io/max-replicas"
+)
+
+// machineSetReplicaLimits bounds the replicas of a MachineSet. Unset bounds are not enforced.
+type machineSetReplicaLimits struct {
+	min *int32
+	max *int32
+	// minSource and maxSource tell where the bounds are set, for the error messages.
+	minSource string
+	maxSource string
+}
+
+// parseMachineSetReplicaLimits returns the MachineSet replica limits set in the policy ConfigMap.
+func parseMachineSetReplicaLimits(policy *corev1.ConfigMap) (machineSetReplicaLimits, error) {
+	limits := machineSetReplicaLimits{}
+	if policy == nil {
+		return limits, nil
+	}
+
+	for key, limit := range map[string]**int32{
+		machineSetMinReplicasKey: &limits.min,
+		machineSetMaxReplicasKey: &limits.max,
+	} {
+		value, ok := policy.Data[key]
+		if !ok {
+			continue
+		}
+		replicas, err := parseReplicaLimit(value)
+		if err != nil {
+			return machineSetReplicaLimits{}, fmt.Errorf("%s: %v", key, err)
+		}
+		*limit = replicas
+	}
+
+	if limits.min != nil && limits.max != nil && *limits.min > *limits.max {
+		return machineSetReplicaLimits{}, fmt.Errorf("%s %d is greater than %s %d", machineSetMinReplicasKey, *limits.min, machineSetMaxReplicasKey, *limits.max)
+	}
+	source := fmt.Sprintf("in ConfigMap %s/%s", defaultWebhookServiceNamespace, ValidationPolicyConfigMapName)
+	if limits.min != nil {
+		limits.minSource = fmt.Sprintf("%s %s", machineSetMinReplicasKey, source)
+	}
+	if limits.max != nil {
+		limits.maxSource = fmt.Sprintf("%s %s", machineSetMaxReplicasKey, source)
+	}
+	return limits, nil
+}
+
+// parseReplicaLimit parses a replica limit, which must be a non-negative integer.
+func parseReplicaLimit(value string) (*int32, error) {
+	replicas, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || replicas < 0 {
+		return nil, fmt.Errorf("invalid value %q: expected a non-negative integer", value)
+	}
+	parsed := int32(replicas)
+	return &parsed, nil
+}
+
+// withMachineSetAnnotations returns the limits of the MachineSet with the given annotations, whose replica
+// limit annotations take precedence over the limits.
+func (limits machineSetReplicaLimits) withMachineSetAnnotations(annotations map[string]string) (machineSetReplicaLimits, *field.Error) {
+	path := field.NewPath("metadata", "annotations")
+	for key, limit := range map[string]struct {
+		bound  **int32
+		source *string
+	}{
+		machineSetMinReplicasAnnotation: {&limits.min, &limits.minSource},
+		machineSetMaxReplicasAnnotation: {&limits.max, &limits.maxSource},
+	} {
+		value, ok := annotations[key]
+		if !ok {
+			continue
+		}
+		replicas, err := parseReplicaLimit(value)
+		if err != nil {
+			return machineSetReplicaLimits{}, field.Invalid(path.Key(key), value, "expected a non-negative integer")
+		}
+		*limit.bound = replicas
+		*limit.source = fmt.Sprintf("annotation %s", key)
+	}
+
+	if limits.min != nil && limits.max != nil && *limits.min > *limits.max {
+		return machineSetReplicaLimits{}, field.Invalid(path, annotations, fmt.Sprintf("minimum replicas %d, as set by %s, is greater than maximum replicas %d, as set by %s", *limits.min, limits.minSource, *limits.max, limits.maxSource))
+	}
+	return limits, nil
+}
+
+// machineSetReplicaLimitsFromPolicy returns the MachineSet replica limits set in the policy ConfigMap.
+// No limits are enforced when the ConfigMap does not exist or sets invalid limits.
+func machineSetReplicaLimitsFromPolicy(policy *corev1.ConfigMap) machineSetReplicaLimits {
+	limits, err := parseMachineSetReplicaLimits(policy)
+	if err != nil {
+		klog.Errorf("Ignoring the MachineSet replica limits in ConfigMap %s/%s: %v", policy.GetNamespace(), policy.GetName(), err)
+		return machineSetReplicaLimits{}
+	}
+	return limits
+}
+
+// currentMachineSetReplicaLimits returns the MachineSet replica limits for a single admission request.
+func (a *admissionHandler) currentMachineSetReplicaLimits() machineSetReplicaLimits {
+	if a.inputs == nil {
+		return machineSetReplicaLimits{}
+	}
+
+	policy, err := a.inputs.getValidationPolicy(context.Background())
+	if err != nil {
+		klog.Errorf("Unable to refresh the validation policy, using the last known value: %v", err)
+	}
+	return machineSetReplicaLimitsFromPolicy(policy)
+}
+
+// validateMachineSetReplicas checks the replicas against the limits. Replicas already outside of the limits,
+// eg. because the limits were changed, may still be brought closer to them.
+func validateMachineSetReplicas(replicas, oldReplicas *int32, limits machineSetReplicaLimits, path *field.Path) []error {
+	if replicas == nil {
+		return nil
+	}
+
+	if limits.min != nil && *replicas < *limits.min {
+		if oldReplicas == nil || *replicas < *oldReplicas {
+			return []error{field.Invalid(path, *replicas, fmt.Sprintf("replicas must be at least %d, as set by %s", *limits.min, limits.minSource))}
+		}
+	}
+	if limits.max != nil && *replicas > *limits.max {
+		if oldReplicas == nil || *replicas > *oldReplicas {
+			return []error{field.Invalid(path, *replicas, fmt.Sprintf("replicas must be at most %d, as set by %s", *limits.max, limits.maxSource))}
+		}
+	}
+	return nil
+}
+
+// handleScale validates the replicas of a MachineSet scaled through the scale subresource.
+func (h *machineSetValidatorHandler) handleScale(req admission.Request) admission.Response {
+	scale := &autoscalingv1.Scale{}
+	if err := json.Unmarshal(req.Object.Raw, scale); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	var oldReplicas *int32
+	if len(req.OldObject.Raw) > 0 {
+		oldScale := &autoscalingv1.Scale{}
+		if err := json.Unmarshal(req.OldObject.Raw, oldScale); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		oldReplicas = &oldScale.Spec.Replicas
+	}
+
+	klog.V(3).Infof("Validate webhook called for MachineSet scale: %s", req.Name)
+
+	// The scale does not carry the annotations of the MachineSet, whose limits take precedence.
+	limits := h.currentMachineSetReplicaLimits()
+	ms := &machinev1.MachineSet{}
+	if err := h.client.Get(context.Background(), client.ObjectKey{Namespace: req.Namespace, Name: req.Name}, ms); err != nil {
+		klog.Errorf("Unable to get MachineSet %s/%s, ignoring its replica limits: %v", req.Namespace, req.Name, err)
+	} else if msLimits, err := limits.withMachineSetAnnotations(ms.Annotations); err != nil {
+		klog.Errorf("Ignoring the invalid replica limits of MachineSet %s/%s: %v", req.Namespace, req.Name, err)
+	} else {
+		limits = msLimits
+	}
+
+	errs := validateMachineSetReplicas(&scale.Spec.Replicas, oldReplicas, limits, field.NewPath("spec", "replicas"))
+	if len(errs) > 0 {
+		return validationResponse(admissionResourceMachineSet, h.admissionPlatform(nil), h.currentValidationMode(), false, nil, utilerrors.NewAggregate(errs), "")
+	}
+	return validationResponse(admissionResourceMachineSet, h.admissionPlatform(nil), h.currentValidationMode(), true, nil, nil, "MachineSet scale valid")
+}
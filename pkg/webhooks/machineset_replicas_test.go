@@ -0,0 +1,349 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	admissionv1 "k8s.io/api/admission/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kruntime "k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestParseMachineSetReplicaLimits(t *testing.T) {
+	testCases := []struct {
+		testCase       string
+		data           map[string]string
+		expectedLimits machineSetReplicaLimits
+		expectedError  string
+	}{
+		{
+			testCase: "without limits",
+		},
+		{
+			testCase: "with a minimum and a maximum",
+			data:     map[string]string{machineSetMinReplicasKey: "1", machineSetMaxReplicasKey: "20"},
+			expectedLimits: machineSetReplicaLimits{
+				min:       pointer.Int32Ptr(1),
+				max:       pointer.Int32Ptr(20),
+				minSource: "machineSet.minReplicas in ConfigMap openshift-machine-api/machine-api-webhook-policy",
+				maxSource: "machineSet.maxReplicas in ConfigMap openshift-machine-api/machine-api-webhook-policy",
+			},
+		},
+		{
+			testCase: "with a maximum only",
+			data:     map[string]string{machineSetMaxReplicasKey: "0"},
+			expectedLimits: machineSetReplicaLimits{
+				max:       pointer.Int32Ptr(0),
+				maxSource: "machineSet.maxReplicas in ConfigMap openshift-machine-api/machine-api-webhook-policy",
+			},
+		},
+		{
+			testCase:      "with a limit which is not an integer",
+			data:          map[string]string{machineSetMaxReplicasKey: "ten"},
+			expectedError: "machineSet.maxReplicas: invalid value \"ten\": expected a non-negative integer",
+		},
+		{
+			testCase:      "with a negative limit",
+			data:          map[string]string{machineSetMinReplicasKey: "-1"},
+			expectedError: "machineSet.minReplicas: invalid value \"-1\": expected a non-negative integer",
+		},
+		{
+			testCase:      "with a minimum greater than the maximum",
+			data:          map[string]string{machineSetMinReplicasKey: "5", machineSetMaxReplicasKey: "2"},
+			expectedError: "machineSet.minReplicas 5 is greater than machineSet.maxReplicas 2",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			g := NewWithT(t)
+
+			limits, err := parseMachineSetReplicaLimits(&corev1.ConfigMap{Data: tc.data})
+			if tc.expectedError != "" {
+				g.Expect(err).To(MatchError(tc.expectedError))
+				g.Expect(validateValidationPolicy(&corev1.ConfigMap{Data: tc.data})).To(MatchError(tc.expectedError))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(limits).To(Equal(tc.expectedLimits))
+		})
+	}
+}
+
+func TestMachineSetReplicaLimitsWithAnnotations(t *testing.T) {
+	policyLimits, err := parseMachineSetReplicaLimits(&corev1.ConfigMap{
+		Data: map[string]string{machineSetMinReplicasKey: "1", machineSetMaxReplicasKey: "10"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		testCase       string
+		limits         machineSetReplicaLimits
+		annotations    map[string]string
+		expectedLimits machineSetReplicaLimits
+		expectedError  string
+	}{
+		{
+			testCase:       "without annotations",
+			limits:         policyLimits,
+			expectedLimits: policyLimits,
+		},
+		{
+			testCase:    "with a maximum annotation",
+			limits:      policyLimits,
+			annotations: map[string]string{machineSetMaxReplicasAnnotation: "50"},
+			expectedLimits: machineSetReplicaLimits{
+				min:       pointer.Int32Ptr(1),
+				max:       pointer.Int32Ptr(50),
+				minSource: policyLimits.minSource,
+				maxSource: "annotation machine.openshift.io/max-replicas",
+			},
+		},
+		{
+			testCase:    "with annotations and no policy limits",
+			annotations: map[string]string{machineSetMinReplicasAnnotation: "0", machineSetMaxReplicasAnnotation: "3"},
+			expectedLimits: machineSetReplicaLimits{
+				min:       pointer.Int32Ptr(0),
+				max:       pointer.Int32Ptr(3),
+				minSource: "annotation machine.openshift.io/min-replicas",
+				maxSource: "annotation machine.openshift.io/max-replicas",
+			},
+		},
+		{
+			testCase:      "with an annotation which is not an integer",
+			limits:        policyLimits,
+			annotations:   map[string]string{machineSetMaxReplicasAnnotation: "ten"},
+			expectedError: "metadata.annotations[machine.openshift.io/max-replicas]: Invalid value: \"ten\": expected a non-negative integer",
+		},
+		{
+			testCase:      "with a minimum annotation greater than the policy maximum",
+			limits:        policyLimits,
+			annotations:   map[string]string{machineSetMinReplicasAnnotation: "20"},
+			expectedError: "minimum replicas 20, as set by annotation machine.openshift.io/min-replicas, is greater than maximum replicas 10, as set by machineSet.maxReplicas in ConfigMap openshift-machine-api/machine-api-webhook-policy",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			g := NewWithT(t)
+
+			limits, err := tc.limits.withMachineSetAnnotations(tc.annotations)
+			if tc.expectedError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tc.expectedError))
+				return
+			}
+			g.Expect(err).To(BeNil())
+			g.Expect(limits).To(Equal(tc.expectedLimits))
+		})
+	}
+}
+
+func TestValidateMachineSetReplicas(t *testing.T) {
+	limits, err := parseMachineSetReplicaLimits(&corev1.ConfigMap{
+		Data: map[string]string{machineSetMinReplicasKey: "1", machineSetMaxReplicasKey: "10"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		testCase      string
+		replicas      *int32
+		oldReplicas   *int32
+		limits        machineSetReplicaLimits
+		expectedError string
+	}{
+		{
+			testCase: "without limits",
+			replicas: pointer.Int32Ptr(500),
+		},
+		{
+			testCase: "without replicas",
+			limits:   limits,
+		},
+		{
+			testCase: "within the limits",
+			replicas: pointer.Int32Ptr(10),
+			limits:   limits,
+		},
+		{
+			testCase:      "above the maximum",
+			replicas:      pointer.Int32Ptr(500),
+			oldReplicas:   pointer.Int32Ptr(3),
+			limits:        limits,
+			expectedError: "spec.replicas: Invalid value: 500: replicas must be at most 10, as set by machineSet.maxReplicas in ConfigMap openshift-machine-api/machine-api-webhook-policy",
+		},
+		{
+			testCase:      "below the minimum",
+			replicas:      pointer.Int32Ptr(0),
+			limits:        limits,
+			expectedError: "spec.replicas: Invalid value: 0: replicas must be at least 1, as set by machineSet.minReplicas in ConfigMap openshift-machine-api/machine-api-webhook-policy",
+		},
+		{
+			testCase:    "scaling down towards the maximum",
+			replicas:    pointer.Int32Ptr(15),
+			oldReplicas: pointer.Int32Ptr(20),
+			limits:      limits,
+		},
+		{
+			testCase:      "scaling up further above the maximum",
+			replicas:      pointer.Int32Ptr(25),
+			oldReplicas:   pointer.Int32Ptr(20),
+			limits:        limits,
+			expectedError: "spec.replicas: Invalid value: 25: replicas must be at most 10, as set by machineSet.maxReplicas in ConfigMap openshift-machine-api/machine-api-webhook-policy",
+		},
+		{
+			testCase:      "above the maximum of an annotation",
+			replicas:      pointer.Int32Ptr(4),
+			oldReplicas:   pointer.Int32Ptr(3),
+			limits:        machineSetReplicaLimits{max: pointer.Int32Ptr(3), maxSource: "annotation machine.openshift.io/max-replicas"},
+			expectedError: "spec.replicas: Invalid value: 4: replicas must be at most 3, as set by annotation machine.openshift.io/max-replicas",
+		},
+		{
+			testCase:    "keeping replicas below the minimum",
+			replicas:    pointer.Int32Ptr(0),
+			oldReplicas: pointer.Int32Ptr(0),
+			limits:      limits,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			errs := validateMachineSetReplicas(tc.replicas, tc.oldReplicas, tc.limits, field.NewPath("spec", "replicas"))
+
+			var errString string
+			if len(errs) > 0 {
+				errString = utilerrors.NewAggregate(errs).Error()
+			}
+			if errString != tc.expectedError {
+				t.Errorf("expected error: %q, got: %q", tc.expectedError, errString)
+			}
+		})
+	}
+}
+
+func TestMachineSetScaleSubresource(t *testing.T) {
+	policy := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ValidationPolicyConfigMapName,
+			Namespace: defaultWebhookServiceNamespace,
+		},
+		Data: map[string]string{machineSetMaxReplicasKey: "10"},
+	}
+
+	scaleRequest := func(g *WithT, oldReplicas, replicas int32) admission.Request {
+		rawScale := func(replicas int32) []byte {
+			raw, err := json.Marshal(&autoscalingv1.Scale{
+				ObjectMeta: metav1.ObjectMeta{Name: "machineset", Namespace: defaultWebhookServiceNamespace},
+				Spec:       autoscalingv1.ScaleSpec{Replicas: replicas},
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+			return raw
+		}
+		return admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{
+				Name:        "machineset",
+				Namespace:   defaultWebhookServiceNamespace,
+				Operation:   admissionv1.Update,
+				SubResource: scaleSubResource,
+				Object:      kruntime.RawExtension{Raw: rawScale(replicas)},
+				OldObject:   kruntime.RawExtension{Raw: rawScale(oldReplicas)},
+			},
+		}
+	}
+
+	testCases := []struct {
+		testCase        string
+		validationMode  ValidationMode
+		annotations     map[string]string
+		oldReplicas     int32
+		replicas        int32
+		expectedAllowed bool
+		expectedReason  string
+	}{
+		{
+			testCase:        "scaling within the limits",
+			validationMode:  ValidationModeEnforce,
+			oldReplicas:     3,
+			replicas:        10,
+			expectedAllowed: true,
+		},
+		{
+			testCase:        "scaling above the maximum",
+			validationMode:  ValidationModeEnforce,
+			oldReplicas:     3,
+			replicas:        500,
+			expectedAllowed: false,
+			expectedReason:  "spec.replicas: Invalid value: 500: replicas must be at most 10",
+		},
+		{
+			testCase:        "scaling above the maximum allowed by an annotation",
+			validationMode:  ValidationModeEnforce,
+			annotations:     map[string]string{machineSetMaxReplicasAnnotation: "500"},
+			oldReplicas:     3,
+			replicas:        500,
+			expectedAllowed: true,
+		},
+		{
+			testCase:        "scaling above the maximum of an annotation",
+			validationMode:  ValidationModeEnforce,
+			annotations:     map[string]string{machineSetMaxReplicasAnnotation: "2"},
+			oldReplicas:     1,
+			replicas:        3,
+			expectedAllowed: false,
+			expectedReason:  "spec.replicas: Invalid value: 3: replicas must be at most 2, as set by annotation machine.openshift.io/max-replicas",
+		},
+		{
+			testCase:        "scaling above the maximum in audit mode",
+			validationMode:  ValidationModeAudit,
+			oldReplicas:     3,
+			replicas:        500,
+			expectedAllowed: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ms := &machinev1.MachineSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "machineset",
+					Namespace:   defaultWebhookServiceNamespace,
+					Annotations: tc.annotations,
+				},
+			}
+			c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(policy.DeepCopy(), ms).Build()
+			h := &machineSetValidatorHandler{
+				admissionHandler: &admissionHandler{
+					admissionConfig: &admissionConfig{client: c},
+					inputs:          newClusterInputsCache(c),
+					validationMode:  tc.validationMode,
+				},
+			}
+
+			resp := h.Handle(context.Background(), scaleRequest(g, tc.oldReplicas, tc.replicas))
+			g.Expect(resp.Allowed).To(Equal(tc.expectedAllowed))
+			if tc.expectedReason != "" {
+				g.Expect(string(resp.Result.Reason)).To(ContainSubstring(tc.expectedReason))
+			}
+			if tc.validationMode == ValidationModeAudit {
+				g.Expect(resp.AuditAnnotations).To(HaveKeyWithValue(auditDenialAnnotation, ContainSubstring("spec.replicas")))
+			}
+		})
+	}
+}
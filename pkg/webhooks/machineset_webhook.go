@@ -2,7 +2,6 @@ package webhooks
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"reflect"
@@ -34,48 +33,72 @@ type machineSetDefaulterHandler struct {
 }
 
 // NewMachineSetValidator returns a new machineSetValidatorHandler.
-func NewMachineSetValidator(client client.Client) (*machineSetValidatorHandler, error) {
-	infra, err := getInfra()
+func NewMachineSetValidator(client client.Client, validationMode ValidationMode, hybridPlatformValidation bool) (*machineSetValidatorHandler, error) {
+	reader, err := newClusterInputsReader()
 	if err != nil {
 		return nil, err
 	}
+	inputs := newClusterInputsCache(reader)
 
-	dns, err := getDNS()
+	infra, err := inputs.getInfrastructure(context.Background())
 	if err != nil {
 		return nil, err
 	}
 
-	return createMachineSetValidator(infra, client, dns), nil
+	dns, err := inputs.getDNS(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	h := createMachineSetValidator(infra, client, dns)
+	h.inputs = inputs
+	h.validationMode = validationMode
+	h.hybridPlatformValidation = hybridPlatformValidation
+	if hybridPlatformValidation {
+		h.webhookOperations = getHybridMachineValidatorOperation(infra.Status.PlatformStatus.Type)
+	}
+	return h, nil
 }
 
 func createMachineSetValidator(infra *osconfigv1.Infrastructure, client client.Client, dns *osconfigv1.DNS) *machineSetValidatorHandler {
 	admissionConfig := &admissionConfig{
 		dnsDisconnected: dns.Spec.PublicZone == nil,
 		clusterID:       infra.Status.InfrastructureName,
+		platformStatus:  infra.Status.PlatformStatus,
 		client:          client,
 	}
 	return &machineSetValidatorHandler{
 		admissionHandler: &admissionHandler{
 			admissionConfig:   admissionConfig,
 			webhookOperations: getMachineValidatorOperation(infra.Status.PlatformStatus.Type),
+			validationMode:    ValidationModeEnforce,
 		},
 	}
 }
 
 // NewMachineSetDefaulter returns a new machineSetDefaulterHandler.
+// The platform status and cluster ID are kept up to date from the cluster inputs cache.
 func NewMachineSetDefaulter() (*machineSetDefaulterHandler, error) {
-	infra, err := getInfra()
+	reader, err := newClusterInputsReader()
 	if err != nil {
 		return nil, err
 	}
+	inputs := newClusterInputsCache(reader)
 
-	return createMachineSetDefaulter(infra.Status.PlatformStatus, infra.Status.InfrastructureName), nil
+	infra, err := inputs.getInfrastructure(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	h := createMachineSetDefaulter(infra.Status.PlatformStatus, infra.Status.InfrastructureName)
+	h.inputs = inputs
+	return h, nil
 }
 
 func createMachineSetDefaulter(platformStatus *osconfigv1.PlatformStatus, clusterID string) *machineSetDefaulterHandler {
 	return &machineSetDefaulterHandler{
 		admissionHandler: &admissionHandler{
-			admissionConfig:   &admissionConfig{clusterID: clusterID},
+			admissionConfig:   newDefaulterAdmissionConfig(platformStatus, clusterID),
 			webhookOperations: getMachineDefaulterOperation(platformStatus),
 		},
 	}
@@ -83,6 +106,10 @@ func createMachineSetDefaulter(platformStatus *osconfigv1.PlatformStatus, cluste
 
 // Handle handles HTTP requests for admission webhook servers.
 func (h *machineSetValidatorHandler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.SubResource == scaleSubResource {
+		return h.handleScale(req)
+	}
+
 	ms := &machinev1.MachineSet{}
 
 	if err := h.decoder.Decode(req, ms); err != nil {
@@ -103,11 +130,7 @@ func (h *machineSetValidatorHandler) Handle(ctx context.Context, req admission.R
 	klog.V(3).Infof("Validate webhook called for MachineSet: %s", ms.GetName())
 
 	ok, warnings, errs := h.validateMachineSet(ms, oldMS)
-	if !ok {
-		return admission.Denied(errs.Error()).WithWarnings(warnings...)
-	}
-
-	return admission.Allowed("MachineSet valid").WithWarnings(warnings...)
+	return validationResponse(admissionResourceMachineSet, h.admissionPlatform(machineFromTemplate(ms)), h.currentValidationMode(), ok, warnings, errs, "MachineSet valid")
 }
 
 // Handle handles HTTP requests for admission webhook servers.
@@ -125,27 +148,52 @@ func (h *machineSetDefaulterHandler) Handle(ctx context.Context, req admission.R
 		return admission.Denied(errs.Error()).WithWarnings(warnings...)
 	}
 
-	marshaledMachineSet, err := json.Marshal(ms)
-	if err != nil {
-		return admission.Errored(http.StatusInternalServerError, err).WithWarnings(warnings...)
-	}
-	return admission.PatchResponseFromRaw(req.Object.Raw, marshaledMachineSet).WithWarnings(warnings...)
+	return defaultingResponse(req, ms, warnings)
 }
 
 func (h *machineSetValidatorHandler) validateMachineSet(ms, oldMS *machinev1.MachineSet) (bool, []string, utilerrors.Aggregate) {
 	errs := validateMachineSetSpec(ms, oldMS)
 
-	// Create a Machine from the MachineSet and validate the Machine template
-	m := &machinev1.Machine{
-		ObjectMeta: metav1.ObjectMeta{
-			Namespace: ms.GetNamespace(),
-		},
-		Spec: ms.Spec.Template.Spec,
+	var oldReplicas *int32
+	if oldMS != nil {
+		oldReplicas = oldMS.Spec.Replicas
 	}
-	ok, warnings, err := h.webhookOperations(m, h.admissionConfig)
-	if !ok {
-		errs = append(errs, err.Errors()...)
+	if limits, err := h.currentMachineSetReplicaLimits().withMachineSetAnnotations(ms.Annotations); err != nil {
+		errs = append(errs, err)
+	} else {
+		errs = append(errs, validateMachineSetReplicas(ms.Spec.Replicas, oldReplicas, limits, field.NewPath("spec", "replicas"))...)
+	}
+
+	// Validate the Machine template as the Machine webhook validates the replicas created from it,
+	// so that an invalid template is denied before any replica is created.
+	m, config := machineFromTemplate(ms), h.currentConfig()
+	var oldM *machinev1.Machine
+	if oldMS != nil {
+		oldM = machineFromTemplate(oldMS)
+	}
+	var clusterPlatform osconfigv1.PlatformType
+	if config.platformStatus != nil {
+		clusterPlatform = config.platformStatus.Type
+	}
+
+	var warnings []string
+	if !skipPlatformRules(ms, m, oldM, clusterPlatform) {
+		ok, platformWarnings, err := h.webhookOperations(m, config)
+		if !ok {
+			errs = append(errs, err.Errors()...)
+		}
+		warnings = platformWarnings
+	}
+
+	templatePath := field.NewPath("spec", "template")
+	errs = append(errs, validateLifecycleHookOwners(m, oldM, config.allowedLifecycleHookOwners, templatePath.Child("spec", "lifecycleHooks"))...)
+	if !isDeleting(ms) {
+		errs = append(errs, validateUnsupportedProviderSpecFields(m, oldM, clusterPlatform)...)
 	}
+	errs = append(errs, validateTaints(ms.Spec.Template.Spec.Taints, templatePath.Child("spec", "taints"))...)
+	warnings = append(warnings, validateTemplateNoExecuteTaints(ms.Spec.Template.Spec.Taints, templatePath.Child("spec", "taints"))...)
+	warnings = append(warnings, validateNodeRoleLabels(ms.Spec.Template.Labels, ms.Spec.Template.Spec.ObjectMeta.Labels, templatePath.Child("metadata", "labels"), templatePath.Child("spec", "metadata", "labels"))...)
+	warnings = append(warnings, validateNodeManagedAnnotations(ms.Spec.Template.Spec.ObjectMeta.Annotations, templatePath.Child("spec", "metadata", "annotations"))...)
 
 	if len(errs) > 0 {
 		return false, warnings, utilerrors.NewAggregate(errs)
@@ -154,18 +202,46 @@ func (h *machineSetValidatorHandler) validateMachineSet(ms, oldMS *machinev1.Mac
 }
 
 func (h *machineSetDefaulterHandler) defaultMachineSet(ms *machinev1.MachineSet) (bool, []string, utilerrors.Aggregate) {
-	// Create a Machine from the MachineSet and default the Machine template
+	// Create a Machine from the MachineSet and default the Machine template.
+	// The template labels are copied as they may place the machine, for example in a vSphere failure domain.
 	m := &machinev1.Machine{Spec: ms.Spec.Template.Spec}
-	ok, warnings, err := h.webhookOperations(m, h.admissionConfig)
+	if len(ms.Spec.Template.Labels) > 0 {
+		m.Labels = make(map[string]string, len(ms.Spec.Template.Labels))
+		for k, v := range ms.Spec.Template.Labels {
+			m.Labels[k] = v
+		}
+	}
+	ok, warnings, err := h.webhookOperations(m, h.currentConfig())
 	if !ok {
 		return false, warnings, utilerrors.NewAggregate(err.Errors())
 	}
 
 	// Restore the defaulted template
 	ms.Spec.Template.Spec = m.Spec
+	if removed, ok := m.Annotations[removedProviderSpecFieldsAnnotation]; ok {
+		if ms.Annotations == nil {
+			ms.Annotations = map[string]string{}
+		}
+		ms.Annotations[removedProviderSpecFieldsAnnotation] = removed
+	}
 	return true, warnings, nil
 }
 
+// machineFromTemplate returns a Machine as the MachineSet controller creates it from the MachineSet template,
+// so that the template can be validated like the machines created from it.
+func machineFromTemplate(ms *machinev1.MachineSet) *machinev1.Machine {
+	template := ms.Spec.Template.DeepCopy()
+	return &machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: ms.GetName() + "-",
+			Namespace:    ms.GetNamespace(),
+			Labels:       template.Labels,
+			Annotations:  template.Annotations,
+		},
+		Spec: template.Spec,
+	}
+}
+
 // validateMachineSetSpec is used to validate any changes to the MachineSet spec outside of
 // the providerSpec. Eg it can be used to verify changes to the selector.
 func validateMachineSetSpec(ms, oldMS *machinev1.MachineSet) []error {
@@ -2,6 +2,7 @@ package webhooks
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"testing"
 
@@ -12,10 +13,12 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"k8s.io/utils/pointer"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
@@ -872,3 +875,236 @@ func TestMachineSetUpdate(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateMachineSetNodeRoleLabels(t *testing.T) {
+	testCases := []struct {
+		testCase         string
+		labels           map[string]string
+		nodeLabels       map[string]string
+		expectedWarnings []string
+	}{
+		{
+			testCase:         "with the node role label only on the machine template",
+			labels:           map[string]string{"node-role.kubernetes.io/infra": ""},
+			expectedWarnings: []string{"spec.template.metadata.labels: label \"node-role.kubernetes.io/infra\" will not be applied to the Node: node labels must be set in spec.template.spec.metadata.labels"},
+		},
+		{
+			testCase:         "with the node role label in spec.template.spec.metadata.labels",
+			nodeLabels:       map[string]string{"node-role.kubernetes.io/infra": ""},
+			expectedWarnings: []string{},
+		},
+		{
+			testCase:         "with the node role label in both places",
+			labels:           map[string]string{"node-role.kubernetes.io/infra": ""},
+			nodeLabels:       map[string]string{"node-role.kubernetes.io/infra": ""},
+			expectedWarnings: []string{},
+		},
+	}
+
+	h := createMachineSetValidator(plainInfra, fake.NewFakeClientWithScheme(scheme.Scheme), plainDNS)
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ms := &machinev1.MachineSet{
+				Spec: machinev1.MachineSetSpec{
+					Template: machinev1.MachineTemplateSpec{
+						ObjectMeta: machinev1.ObjectMeta{
+							Labels: tc.labels,
+						},
+						Spec: machinev1.MachineSpec{
+							ObjectMeta: machinev1.ObjectMeta{
+								Labels: tc.nodeLabels,
+							},
+						},
+					},
+				},
+			}
+
+			ok, warnings, err := h.validateMachineSet(ms, nil)
+			g.Expect(ok).To(BeTrue(), fmt.Sprintf("%v", err))
+			g.Expect(warnings).To(Equal(tc.expectedWarnings))
+		})
+	}
+}
+
+func TestValidateMachineSetTaints(t *testing.T) {
+	testCases := []struct {
+		testCase         string
+		taints           []corev1.Taint
+		expectedOk       bool
+		expectedError    string
+		expectedWarnings []string
+	}{
+		{
+			testCase:         "with a NoSchedule taint",
+			taints:           []corev1.Taint{{Key: "dedicated", Value: "infra", Effect: corev1.TaintEffectNoSchedule}},
+			expectedOk:       true,
+			expectedWarnings: []string{},
+		},
+		{
+			testCase:      "with an invalid effect",
+			taints:        []corev1.Taint{{Key: "dedicated", Value: "infra", Effect: "NoScheduling"}},
+			expectedOk:    false,
+			expectedError: "spec.template.spec.taints[0].effect: Unsupported value: \"NoScheduling\": supported values: \"NoExecute\", \"NoSchedule\", \"PreferNoSchedule\"",
+		},
+		{
+			testCase: "with a duplicate taint",
+			taints: []corev1.Taint{
+				{Key: "dedicated", Value: "infra", Effect: corev1.TaintEffectNoSchedule},
+				{Key: "dedicated", Value: "edge", Effect: corev1.TaintEffectNoSchedule},
+			},
+			expectedOk:    false,
+			expectedError: "spec.template.spec.taints[1]: Duplicate value: \"dedicated:NoSchedule\"",
+		},
+		{
+			testCase: "with the same key and different effects",
+			taints: []corev1.Taint{
+				{Key: "dedicated", Value: "infra", Effect: corev1.TaintEffectNoSchedule},
+				{Key: "dedicated", Value: "infra", Effect: corev1.TaintEffectPreferNoSchedule},
+			},
+			expectedOk:       true,
+			expectedWarnings: []string{},
+		},
+		{
+			testCase:   "with a NoExecute taint",
+			taints:     []corev1.Taint{{Key: "dedicated", Value: "infra", Effect: corev1.TaintEffectNoExecute}},
+			expectedOk: true,
+			expectedWarnings: []string{
+				"spec.template.spec.taints[0]: NoExecute taint \"dedicated\" evicts the pods without a matching toleration from every new Node as soon as it joins the cluster, use NoSchedule to only prevent new pods from being scheduled",
+			},
+		},
+	}
+
+	h := createMachineSetValidator(plainInfra, fake.NewFakeClientWithScheme(scheme.Scheme), plainDNS)
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ms := &machinev1.MachineSet{
+				Spec: machinev1.MachineSetSpec{
+					Template: machinev1.MachineTemplateSpec{
+						Spec: machinev1.MachineSpec{
+							Taints: tc.taints,
+						},
+					},
+				},
+			}
+
+			ok, warnings, err := h.validateMachineSet(ms, nil)
+			g.Expect(ok).To(Equal(tc.expectedOk))
+			if tc.expectedError != "" {
+				g.Expect(err).To(MatchError(tc.expectedError))
+				return
+			}
+			g.Expect(err).To(BeNil())
+			g.Expect(warnings).To(Equal(tc.expectedWarnings))
+		})
+	}
+}
+
+func TestValidateMachineSetTemplateMatchesMachine(t *testing.T) {
+	testCases := []struct {
+		testCase       string
+		platformStatus *osconfigv1.PlatformStatus
+		providerSpec   interface{}
+		labels         map[string]string
+	}{
+		{
+			testCase:       "with an AWS providerSpec with errors and warnings",
+			platformStatus: &osconfigv1.PlatformStatus{Type: osconfigv1.AWSPlatformType},
+			providerSpec: &machinev1.AWSMachineProviderConfig{
+				InstanceType: "t3.micro",
+				Placement:    machinev1.Placement{Region: "us-east-1"},
+			},
+		},
+		{
+			testCase:       "with an Azure providerSpec",
+			platformStatus: &osconfigv1.PlatformStatus{Type: osconfigv1.AzurePlatformType},
+			providerSpec:   &machinev1.AzureMachineProviderSpec{VMSize: "Standard_D4s_v3", Vnet: "vnet"},
+		},
+		{
+			testCase:       "with a GCP providerSpec",
+			platformStatus: &osconfigv1.PlatformStatus{Type: osconfigv1.GCPPlatformType},
+			providerSpec:   &machinev1.GCPMachineProviderSpec{MachineType: "n2-standard-4", GPUs: []machinev1.GCPGPUConfig{{Type: "nvidia-tesla-t4"}}},
+		},
+		{
+			testCase: "with an Alibaba Cloud providerSpec validated against the cluster region",
+			platformStatus: &osconfigv1.PlatformStatus{
+				Type:         osconfigv1.AlibabaCloudPlatformType,
+				AlibabaCloud: &osconfigv1.AlibabaCloudPlatformStatus{Region: "cn-hangzhou"},
+			},
+			providerSpec: &alibabaCloudProviderSpec{RegionID: "us-east-1", ZoneID: "us-east-1a"},
+			labels:       map[string]string{"node-role.kubernetes.io/infra": ""},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			g := NewWithT(t)
+
+			rawBytes, err := json.Marshal(tc.providerSpec)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			infra := plainInfra.DeepCopy()
+			infra.Status.PlatformStatus = tc.platformStatus
+			c := fake.NewFakeClientWithScheme(scheme.Scheme)
+
+			ms := &machinev1.MachineSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "workers", Namespace: "openshift-machine-api"},
+				Spec: machinev1.MachineSetSpec{
+					Template: machinev1.MachineTemplateSpec{
+						ObjectMeta: machinev1.ObjectMeta{Labels: tc.labels},
+						Spec: machinev1.MachineSpec{
+							ProviderSpec: machinev1.ProviderSpec{Value: &runtime.RawExtension{Raw: rawBytes}},
+						},
+					},
+				},
+			}
+			msOk, msWarnings, msErr := createMachineSetValidator(infra, c, plainDNS).validateMachineSet(ms, nil)
+
+			m := machineFromTemplate(ms)
+			mOk, mWarnings, mErr := createMachineValidator(infra, c, plainDNS).validateMachine(m, nil)
+
+			g.Expect(mOk).To(BeFalse())
+			g.Expect(msOk).To(Equal(mOk))
+			g.Expect(msErr).To(Equal(mErr))
+			// Label warnings refer to the template of the MachineSet and to the Machine respectively.
+			if tc.labels == nil {
+				g.Expect(msWarnings).To(Equal(mWarnings))
+			} else {
+				g.Expect(msWarnings).To(HaveLen(len(mWarnings)))
+			}
+		})
+	}
+}
+
+func TestMachineFromTemplate(t *testing.T) {
+	g := NewWithT(t)
+
+	ms := &machinev1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "workers", Namespace: "openshift-machine-api"},
+		Spec: machinev1.MachineSetSpec{
+			Template: machinev1.MachineTemplateSpec{
+				ObjectMeta: machinev1.ObjectMeta{
+					Labels:      map[string]string{"machine.openshift.io/cluster-api-machineset": "workers"},
+					Annotations: map[string]string{"example.com/annotation": "value"},
+				},
+				Spec: machinev1.MachineSpec{ProviderID: pointer.StringPtr("providerID")},
+			},
+		},
+	}
+
+	m := machineFromTemplate(ms)
+	g.Expect(m.GenerateName).To(Equal("workers-"))
+	g.Expect(m.Namespace).To(Equal("openshift-machine-api"))
+	g.Expect(m.Labels).To(Equal(ms.Spec.Template.Labels))
+	g.Expect(m.Annotations).To(Equal(ms.Spec.Template.Annotations))
+	g.Expect(m.Spec).To(Equal(ms.Spec.Template.Spec))
+
+	// The template is not modified through the machine.
+	m.Labels["example.com/label"] = "value"
+	g.Expect(ms.Spec.Template.Labels).ToNot(HaveKey("example.com/label"))
+}
@@ -0,0 +1,133 @@
+package webhooks
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+)
+
+// nutanixPlatformType is the Nutanix platform. The vendored config API predates Nutanix support.
+const nutanixPlatformType osconfigv1.PlatformType = "Nutanix"
+
+const (
+	// nutanixIdentifierUUID and nutanixIdentifierName are the types of Nutanix resource identifiers.
+	nutanixIdentifierUUID = "uuid"
+	nutanixIdentifierName = "name"
+)
+
+// nutanixProviderSpec is the subset of the Nutanix providerSpec the webhooks default and validate.
+// It models the Prism references of the VM, its cluster, image and subnets, and the VM sizing checked
+// against the minimum vCPUs and memory of a node.
+type nutanixProviderSpec struct {
+	Cluster           nutanixResourceIdentifier    `json:"cluster"`
+	Image             nutanixResourceIdentifier    `json:"image"`
+	Subnets           []nutanixResourceIdentifier  `json:"subnets,omitempty"`
+	VCPUsPerSocket    int32                        `json:"vcpusPerSocket,omitempty"`
+	VCPUSockets       int32                        `json:"vcpuSockets,omitempty"`
+	MemorySize        resource.Quantity            `json:"memorySize,omitempty"`
+	SystemDiskSize    resource.Quantity            `json:"systemDiskSize,omitempty"`
+	UserDataSecret    *corev1.LocalObjectReference `json:"userDataSecret,omitempty"`
+	CredentialsSecret *corev1.LocalObjectReference `json:"credentialsSecret,omitempty"`
+}
+
+// nutanixResourceIdentifier identifies a Prism resource by UUID or by name.
+type nutanixResourceIdentifier struct {
+	Type string  `json:"type"`
+	UUID *string `json:"uuid,omitempty"`
+	Name *string `json:"name,omitempty"`
+}
+
+// defaultNutanix defaults the secrets of the Nutanix providerSpec.
+func defaultNutanix(m *machinev1.Machine, config *admissionConfig) (bool, []string, utilerrors.Aggregate) {
+	klog.V(3).Infof("Defaulting Nutanix providerSpec")
+
+	if err := defaultRawProviderSpec(m, rawProviderSpecSecretDefaults(config, nutanixPlatformType)); err != nil {
+		return false, []string{}, utilerrors.NewAggregate([]error{err})
+	}
+	return true, []string{}, nil
+}
+
+func validateNutanix(m *machinev1.Machine, config *admissionConfig) (bool, []string, utilerrors.Aggregate) {
+	klog.V(3).Infof("Validating Nutanix providerSpec")
+
+	var errs []error
+	var warnings []string
+	providerSpec := new(nutanixProviderSpec)
+	if err := unmarshalInto(m, providerSpec); err != nil {
+		errs = append(errs, err)
+		return false, warnings, utilerrors.NewAggregate(errs)
+	}
+
+	errs = append(errs, validateNutanixResourceIdentifier(providerSpec.Cluster, field.NewPath("providerSpec", "cluster"))...)
+	errs = append(errs, validateNutanixResourceIdentifier(providerSpec.Image, field.NewPath("providerSpec", "image"))...)
+
+	if len(providerSpec.Subnets) == 0 {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "subnets"), "at least 1 subnet must be provided"))
+	}
+	for i, subnet := range providerSpec.Subnets {
+		errs = append(errs, validateNutanixResourceIdentifier(subnet, field.NewPath("providerSpec", "subnets").Index(i))...)
+	}
+
+	if providerSpec.VCPUsPerSocket < minNutanixVCPUsPerSocket {
+		errs = append(errs, field.Invalid(field.NewPath("providerSpec", "vcpusPerSocket"), providerSpec.VCPUsPerSocket, fmt.Sprintf("vcpusPerSocket must be at least %d", minNutanixVCPUsPerSocket)))
+	}
+	if providerSpec.VCPUSockets < minNutanixVCPUSockets {
+		errs = append(errs, field.Invalid(field.NewPath("providerSpec", "vcpuSockets"), providerSpec.VCPUSockets, fmt.Sprintf("vcpuSockets must be at least %d", minNutanixVCPUSockets)))
+	}
+	minMemorySize := resource.MustParse(fmt.Sprintf("%dMi", minNutanixMemoryMiB))
+	if providerSpec.MemorySize.Cmp(minMemorySize) < 0 {
+		errs = append(errs, field.Invalid(field.NewPath("providerSpec", "memorySize"), providerSpec.MemorySize.String(), fmt.Sprintf("memorySize must be at least %s", minMemorySize.String())))
+	}
+	minDiskSize := resource.MustParse(fmt.Sprintf("%dGi", minNutanixDiskGiB))
+	if providerSpec.SystemDiskSize.Cmp(minDiskSize) < 0 {
+		errs = append(errs, field.Invalid(field.NewPath("providerSpec", "systemDiskSize"), providerSpec.SystemDiskSize.String(), fmt.Sprintf("systemDiskSize must be at least %s", minDiskSize.String())))
+	}
+
+	if providerSpec.UserDataSecret == nil {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "userDataSecret"), "userDataSecret must be provided"))
+	} else if providerSpec.UserDataSecret.Name == "" {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "userDataSecret", "name"), "name must be provided"))
+	}
+
+	if providerSpec.CredentialsSecret == nil {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "credentialsSecret"), "credentialsSecret must be provided"))
+	} else if providerSpec.CredentialsSecret.Name == "" {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "credentialsSecret", "name"), "name must be provided"))
+	} else {
+		warnings = append(warnings, validateCredentialsSecret(config.secretsReader(), providerSpec.CredentialsSecret.Name, m.GetNamespace())...)
+	}
+
+	if len(errs) > 0 {
+		return false, warnings, utilerrors.NewAggregate(errs)
+	}
+	return true, warnings, nil
+}
+
+// validateNutanixResourceIdentifier checks that the identifier sets the UUID or name its type refers to.
+func validateNutanixResourceIdentifier(identifier nutanixResourceIdentifier, fldPath *field.Path) []error {
+	switch identifier.Type {
+	case nutanixIdentifierUUID:
+		if identifier.UUID == nil || *identifier.UUID == "" {
+			return []error{field.Required(fldPath.Child("uuid"), "uuid must be provided when type is uuid")}
+		}
+		if _, err := uuid.Parse(*identifier.UUID); err != nil {
+			return []error{field.Invalid(fldPath.Child("uuid"), *identifier.UUID, "uuid must be a valid UUID")}
+		}
+	case nutanixIdentifierName:
+		if identifier.Name == nil || *identifier.Name == "" {
+			return []error{field.Required(fldPath.Child("name"), "name must be provided when type is name")}
+		}
+	case "":
+		return []error{field.Required(fldPath.Child("type"), "type must be provided")}
+	default:
+		return []error{field.NotSupported(fldPath.Child("type"), identifier.Type, []string{nutanixIdentifierUUID, nutanixIdentifierName})}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package webhooks
+
+import (
+	"reflect"
+	"testing"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	yaml "sigs.k8s.io/yaml"
+)
+
+func TestDefaultNutanix(t *testing.T) {
+	testCases := []struct {
+		testCase             string
+		providerSpec         string
+		expectedProviderSpec map[string]interface{}
+	}{
+		{
+			testCase:     "it defaults the secrets and keeps the other fields",
+			providerSpec: `{"kind": "NutanixMachineProviderConfig", "bootType": "UEFI"}`,
+			expectedProviderSpec: map[string]interface{}{
+				"kind":              "NutanixMachineProviderConfig",
+				"bootType":          "UEFI",
+				"userDataSecret":    map[string]interface{}{"name": defaultUserDataSecret},
+				"credentialsSecret": map[string]interface{}{"name": defaultNutanixCredentialsSecret},
+			},
+		},
+		{
+			testCase:     "it does not override the secrets",
+			providerSpec: `{"userDataSecret": {"name": "user-data"}, "credentialsSecret": {"name": "credentials"}}`,
+			expectedProviderSpec: map[string]interface{}{
+				"userDataSecret":    map[string]interface{}{"name": "user-data"},
+				"credentialsSecret": map[string]interface{}{"name": "credentials"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			m := &machinev1.Machine{
+				Spec: machinev1.MachineSpec{
+					ProviderSpec: machinev1.ProviderSpec{Value: &kruntime.RawExtension{Raw: []byte(tc.providerSpec)}},
+				},
+			}
+
+			ok, _, err := defaultNutanix(m, &admissionConfig{})
+			if !ok || err != nil {
+				t.Fatalf("expected the providerSpec to be defaulted, got: %v", err)
+			}
+
+			providerSpec := map[string]interface{}{}
+			if err := yaml.Unmarshal(m.Spec.ProviderSpec.Value.Raw, &providerSpec); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(providerSpec, tc.expectedProviderSpec) {
+				t.Errorf("expected: %+v, got: %+v", tc.expectedProviderSpec, providerSpec)
+			}
+		})
+	}
+}
+
+func TestValidateNutanix(t *testing.T) {
+	namespace := "openshift-machine-api"
+	credentialsSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: defaultNutanixCredentialsSecret, Namespace: namespace},
+	}
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, credentialsSecret)
+
+	testCases := []struct {
+		testCase         string
+		providerSpec     string
+		expectedOk       bool
+		expectedError    string
+		expectedWarnings []string
+	}{
+		{
+			testCase: "with a valid providerSpec",
+			providerSpec: `{
+				"cluster": {"type": "uuid", "uuid": "0005b0f1-8f43-a0f2-02b7-3cecef193712"},
+				"image": {"type": "name", "name": "rhcos"},
+				"subnets": [{"type": "uuid", "uuid": "c7938dc6-7659-453e-a688-e26020c68e43"}],
+				"vcpusPerSocket": 4, "vcpuSockets": 1, "memorySize": "16Gi", "systemDiskSize": "120Gi",
+				"userDataSecret": {"name": "worker-user-data"}, "credentialsSecret": {"name": "nutanix-credentials"}
+			}`,
+			expectedOk: true,
+		},
+		{
+			testCase:      "with an empty providerSpec",
+			providerSpec:  `{}`,
+			expectedOk:    false,
+			expectedError: "[providerSpec.cluster.type: Required value: type must be provided, providerSpec.image.type: Required value: type must be provided, providerSpec.subnets: Required value: at least 1 subnet must be provided, providerSpec.vcpusPerSocket: Invalid value: 0: vcpusPerSocket must be at least 1, providerSpec.vcpuSockets: Invalid value: 0: vcpuSockets must be at least 1, providerSpec.memorySize: Invalid value: \"0\": memorySize must be at least 2Gi, providerSpec.systemDiskSize: Invalid value: \"0\": systemDiskSize must be at least 20Gi, providerSpec.userDataSecret: Required value: userDataSecret must be provided, providerSpec.credentialsSecret: Required value: credentialsSecret must be provided]",
+		},
+		{
+			testCase: "with resource identifiers that do not match their type",
+			providerSpec: `{
+				"cluster": {"type": "uuid", "name": "cluster"},
+				"image": {"type": "name", "uuid": "0005b0f1-8f43-a0f2-02b7-3cecef193712"},
+				"subnets": [{"type": "uuid", "uuid": "subnet"}, {"type": "label"}],
+				"vcpusPerSocket": 4, "vcpuSockets": 1, "memorySize": "16Gi", "systemDiskSize": "120Gi",
+				"userDataSecret": {"name": "worker-user-data"}, "credentialsSecret": {"name": "nutanix-credentials"}
+			}`,
+			expectedOk:    false,
+			expectedError: "[providerSpec.cluster.uuid: Required value: uuid must be provided when type is uuid, providerSpec.image.name: Required value: name must be provided when type is name, providerSpec.subnets[0].uuid: Invalid value: \"subnet\": uuid must be a valid UUID, providerSpec.subnets[1].type: Unsupported value: \"label\": supported values: \"uuid\", \"name\"]",
+		},
+		{
+			testCase: "with too little memory and disk",
+			providerSpec: `{
+				"cluster": {"type": "name", "name": "cluster"},
+				"image": {"type": "name", "name": "rhcos"},
+				"subnets": [{"type": "name", "name": "subnet"}],
+				"vcpusPerSocket": 1, "vcpuSockets": 1, "memorySize": "1Gi", "systemDiskSize": "10Gi",
+				"userDataSecret": {"name": "worker-user-data"}, "credentialsSecret": {"name": "nutanix-credentials"}
+			}`,
+			expectedOk:    false,
+			expectedError: "[providerSpec.memorySize: Invalid value: \"1Gi\": memorySize must be at least 2Gi, providerSpec.systemDiskSize: Invalid value: \"10Gi\": systemDiskSize must be at least 20Gi]",
+		},
+		{
+			testCase: "with a credentials secret that does not exist",
+			providerSpec: `{
+				"cluster": {"type": "name", "name": "cluster"},
+				"image": {"type": "name", "name": "rhcos"},
+				"subnets": [{"type": "name", "name": "subnet"}],
+				"vcpusPerSocket": 1, "vcpuSockets": 2, "memorySize": "8Gi", "systemDiskSize": "120Gi",
+				"userDataSecret": {"name": "worker-user-data"}, "credentialsSecret": {"name": "missing"}
+			}`,
+			expectedOk: true,
+			expectedWarnings: []string{
+				"providerSpec.credentialsSecret: Invalid value: \"missing\": not found. Expected CredentialsSecret to exist",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			m := &machinev1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace},
+				Spec: machinev1.MachineSpec{
+					ProviderSpec: machinev1.ProviderSpec{Value: &kruntime.RawExtension{Raw: []byte(tc.providerSpec)}},
+				},
+			}
+
+			ok, warnings, err := validateNutanix(m, &admissionConfig{client: c})
+			if ok != tc.expectedOk {
+				t.Errorf("expected: %v, got: %v", tc.expectedOk, ok)
+			}
+			if err == nil {
+				if tc.expectedError != "" {
+					t.Errorf("expected: %q, got: %v", tc.expectedError, err)
+				}
+			} else {
+				if err.Error() != tc.expectedError {
+					t.Errorf("expected: %q, got: %q", tc.expectedError, err.Error())
+				}
+			}
+			if !reflect.DeepEqual(warnings, tc.expectedWarnings) {
+				t.Errorf("expected: %q, got: %q", tc.expectedWarnings, warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package webhooks
+
+import (
+	"fmt"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	yaml "sigs.k8s.io/yaml"
+)
+
+// openStackCloudsYAMLKey is the key of the clouds.yaml in the clouds secret of OpenStack machines.
+const openStackCloudsYAMLKey = "clouds.yaml"
+
+// openStackProviderSpec is the subset of the OpenStack providerSpec the webhook validates.
+// It models what a server cannot be created without: the cloud and its credentials, the flavor, the boot
+// image or root volume, and the networks or ports the server is attached to.
+type openStackProviderSpec struct {
+	CloudsSecret   *corev1.SecretReference `json:"cloudsSecret,omitempty"`
+	CloudName      string                  `json:"cloudName,omitempty"`
+	Flavor         string                  `json:"flavor,omitempty"`
+	Image          string                  `json:"image,omitempty"`
+	RootVolume     *openStackRootVolume    `json:"rootVolume,omitempty"`
+	Networks       []openStackNetwork      `json:"networks,omitempty"`
+	Ports          []openStackPort         `json:"ports,omitempty"`
+	UserDataSecret *corev1.SecretReference `json:"userDataSecret,omitempty"`
+}
+
+// openStackRootVolume is the boot volume of an OpenStack machine.
+type openStackRootVolume struct {
+	SourceUUID string `json:"sourceUUID,omitempty"`
+	Size       int    `json:"diskSize,omitempty"`
+}
+
+// openStackNetwork selects a network of an OpenStack machine by UUID, by filter or by its subnets.
+type openStackNetwork struct {
+	UUID    string                   `json:"uuid,omitempty"`
+	Filter  map[string]interface{}   `json:"filter,omitempty"`
+	Subnets []map[string]interface{} `json:"subnets,omitempty"`
+}
+
+// openStackPort is a port created for an OpenStack machine.
+type openStackPort struct {
+	NetworkID string `json:"networkID,omitempty"`
+}
+
+func validateOpenStack(m *machinev1.Machine, config *admissionConfig) (bool, []string, utilerrors.Aggregate) {
+	klog.V(3).Infof("Validating OpenStack providerSpec")
+
+	var errs []error
+	var warnings []string
+	providerSpec := new(openStackProviderSpec)
+	if err := unmarshalInto(m, providerSpec); err != nil {
+		errs = append(errs, err)
+		return false, warnings, utilerrors.NewAggregate(errs)
+	}
+
+	if providerSpec.Flavor == "" {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "flavor"), "flavor must be provided"))
+	}
+
+	errs = append(errs, validateOpenStackImage(providerSpec)...)
+	errs = append(errs, validateOpenStackNetworks(providerSpec)...)
+
+	if providerSpec.UserDataSecret == nil {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "userDataSecret"), "userDataSecret must be provided"))
+	} else if providerSpec.UserDataSecret.Name == "" {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "userDataSecret", "name"), "name must be provided"))
+	}
+
+	if providerSpec.CloudName == "" {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "cloudName"), "cloudName must be provided"))
+	} else if config.platformStatus != nil && config.platformStatus.Type == osconfigv1.OpenStackPlatformType &&
+		config.platformStatus.OpenStack != nil && config.platformStatus.OpenStack.CloudName != "" &&
+		providerSpec.CloudName != config.platformStatus.OpenStack.CloudName {
+		warnings = append(warnings, fmt.Sprintf("providerSpec.cloudName: %s differs from the cloud of the cluster (%s): the machine will be created in another cloud than the rest of the cluster", providerSpec.CloudName, config.platformStatus.OpenStack.CloudName))
+	}
+
+	if providerSpec.CloudsSecret == nil {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "cloudsSecret"), "cloudsSecret must be provided"))
+	} else if providerSpec.CloudsSecret.Name == "" {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "cloudsSecret", "name"), "name must be provided"))
+	} else {
+		namespace := providerSpec.CloudsSecret.Namespace
+		if namespace == "" {
+			namespace = m.GetNamespace()
+		}
+		warnings = append(warnings, validateOpenStackCloudsSecret(config.secretsReader(), providerSpec.CloudsSecret.Name, namespace, providerSpec.CloudName)...)
+	}
+
+	if len(errs) > 0 {
+		return false, warnings, utilerrors.NewAggregate(errs)
+	}
+	return true, warnings, nil
+}
+
+// validateOpenStackImage checks that the machine boots from an image or from a root volume created from one.
+func validateOpenStackImage(providerSpec *openStackProviderSpec) []error {
+	if providerSpec.RootVolume == nil {
+		if providerSpec.Image == "" {
+			return []error{field.Required(field.NewPath("providerSpec", "image"), "image must be provided when no rootVolume is set")}
+		}
+		return nil
+	}
+
+	var errs []error
+	rootVolumePath := field.NewPath("providerSpec", "rootVolume")
+	if providerSpec.RootVolume.SourceUUID == "" && providerSpec.Image == "" {
+		errs = append(errs, field.Required(rootVolumePath.Child("sourceUUID"), "sourceUUID or providerSpec.image must be provided"))
+	}
+	if providerSpec.RootVolume.Size <= 0 {
+		errs = append(errs, field.Invalid(rootVolumePath.Child("diskSize"), providerSpec.RootVolume.Size, "diskSize must be greater than zero"))
+	}
+	return errs
+}
+
+// validateOpenStackNetworks checks that the machine is attached to at least one network
+// and that each network and port identifies the network it is on.
+func validateOpenStackNetworks(providerSpec *openStackProviderSpec) []error {
+	if len(providerSpec.Networks) == 0 && len(providerSpec.Ports) == 0 {
+		return []error{field.Required(field.NewPath("providerSpec", "networks"), "at least 1 network or port must be provided")}
+	}
+
+	var errs []error
+	for i, network := range providerSpec.Networks {
+		if network.UUID == "" && len(network.Filter) == 0 && len(network.Subnets) == 0 {
+			errs = append(errs, field.Required(field.NewPath("providerSpec", "networks").Index(i), "uuid, filter or subnets must be provided"))
+		}
+	}
+	for i, port := range providerSpec.Ports {
+		if port.NetworkID == "" {
+			errs = append(errs, field.Required(field.NewPath("providerSpec", "ports").Index(i).Child("networkID"), "networkID must be provided"))
+		}
+	}
+	return errs
+}
+
+// validateOpenStackCloudsSecret warns when the clouds secret does not exist
+// or its clouds.yaml does not define the cloud of the machine.
+func validateOpenStackCloudsSecret(c client.Reader, name, namespace, cloudName string) []string {
+	fldPath := field.NewPath("providerSpec", "cloudsSecret")
+	secret, err := getSecret(c, name, namespace)
+	if err != nil {
+		return []string{field.Invalid(fldPath, name, fmt.Sprintf("failed to get cloudsSecret: %v", err)).Error()}
+	}
+	if secret == nil {
+		return []string{field.Invalid(fldPath, name, "not found. Expected cloudsSecret to exist").Error()}
+	}
+
+	cloudsYAML, ok := secret.Data[openStackCloudsYAMLKey]
+	if !ok {
+		return []string{field.Invalid(fldPath, name, fmt.Sprintf("has no %s key: the machine controller will fail to authenticate", openStackCloudsYAMLKey)).Error()}
+	}
+
+	clouds := struct {
+		Clouds map[string]interface{} `json:"clouds"`
+	}{}
+	if err := yaml.Unmarshal(cloudsYAML, &clouds); err != nil {
+		return []string{field.Invalid(fldPath, name, fmt.Sprintf("failed to parse %s: %v", openStackCloudsYAMLKey, err)).Error()}
+	}
+	if cloudName != "" {
+		if _, ok := clouds.Clouds[cloudName]; !ok {
+			return []string{field.Invalid(fldPath, name, fmt.Sprintf("%s does not define the cloud %s: the machine controller will fail to authenticate", openStackCloudsYAMLKey, cloudName)).Error()}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package webhooks
+
+import (
+	"reflect"
+	"testing"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestValidateOpenStack(t *testing.T) {
+	namespace := "openshift-machine-api"
+	cloudsSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "openstack-cloud-credentials", Namespace: namespace},
+		Data: map[string][]byte{
+			openStackCloudsYAMLKey: []byte("clouds:\n  openstack:\n    auth:\n      auth_url: https://keystone.example.com\n"),
+		},
+	}
+	noCloudsYAMLSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "no-clouds-yaml", Namespace: namespace},
+	}
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, cloudsSecret, noCloudsYAMLSecret)
+
+	validProviderSpec := func() string {
+		return `{
+			"kind": "OpenstackProviderSpec",
+			"cloudName": "openstack",
+			"cloudsSecret": {"name": "openstack-cloud-credentials", "namespace": "openshift-machine-api"},
+			"flavor": "m1.large",
+			"image": "rhcos",
+			"networks": [{"filter": {}, "subnets": [{"filter": {"name": "nodes"}}]}],
+			"userDataSecret": {"name": "worker-user-data"}
+		}`
+	}
+
+	testCases := []struct {
+		testCase         string
+		providerSpec     string
+		cloudName        string
+		expectedOk       bool
+		expectedError    string
+		expectedWarnings []string
+	}{
+		{
+			testCase:     "with a valid providerSpec",
+			providerSpec: validProviderSpec(),
+			cloudName:    "openstack",
+			expectedOk:   true,
+		},
+		{
+			testCase:     "with a root volume instead of an image",
+			providerSpec: `{"cloudName": "openstack", "cloudsSecret": {"name": "openstack-cloud-credentials"}, "flavor": "m1.large", "rootVolume": {"sourceUUID": "rhcos", "diskSize": 100}, "ports": [{"networkID": "network"}], "userDataSecret": {"name": "worker-user-data"}}`,
+			expectedOk:   true,
+		},
+		{
+			testCase:      "with no flavor, image or networks",
+			providerSpec:  `{"cloudName": "openstack", "cloudsSecret": {"name": "openstack-cloud-credentials"}, "userDataSecret": {"name": "worker-user-data"}}`,
+			expectedOk:    false,
+			expectedError: "[providerSpec.flavor: Required value: flavor must be provided, providerSpec.image: Required value: image must be provided when no rootVolume is set, providerSpec.networks: Required value: at least 1 network or port must be provided]",
+		},
+		{
+			testCase:      "with an incomplete root volume",
+			providerSpec:  `{"cloudName": "openstack", "cloudsSecret": {"name": "openstack-cloud-credentials"}, "flavor": "m1.large", "rootVolume": {}, "networks": [{"uuid": "network"}], "userDataSecret": {"name": "worker-user-data"}}`,
+			expectedOk:    false,
+			expectedError: "[providerSpec.rootVolume.sourceUUID: Required value: sourceUUID or providerSpec.image must be provided, providerSpec.rootVolume.diskSize: Invalid value: 0: diskSize must be greater than zero]",
+		},
+		{
+			testCase:      "with a network and a port that identify no network",
+			providerSpec:  `{"cloudName": "openstack", "cloudsSecret": {"name": "openstack-cloud-credentials"}, "flavor": "m1.large", "image": "rhcos", "networks": [{"uuid": "network"}, {}], "ports": [{}], "userDataSecret": {"name": "worker-user-data"}}`,
+			expectedOk:    false,
+			expectedError: "[providerSpec.networks[1]: Required value: uuid, filter or subnets must be provided, providerSpec.ports[0].networkID: Required value: networkID must be provided]",
+		},
+		{
+			testCase:      "with no cloud or secrets",
+			providerSpec:  `{"flavor": "m1.large", "image": "rhcos", "networks": [{"uuid": "network"}]}`,
+			expectedOk:    false,
+			expectedError: "[providerSpec.userDataSecret: Required value: userDataSecret must be provided, providerSpec.cloudName: Required value: cloudName must be provided, providerSpec.cloudsSecret: Required value: cloudsSecret must be provided]",
+		},
+		{
+			testCase:     "with a cloud other than the cluster cloud",
+			providerSpec: validProviderSpec(),
+			cloudName:    "other",
+			expectedOk:   true,
+			expectedWarnings: []string{
+				"providerSpec.cloudName: openstack differs from the cloud of the cluster (other): the machine will be created in another cloud than the rest of the cluster",
+			},
+		},
+		{
+			testCase:     "with a clouds secret that does not exist",
+			providerSpec: `{"cloudName": "openstack", "cloudsSecret": {"name": "missing"}, "flavor": "m1.large", "image": "rhcos", "networks": [{"uuid": "network"}], "userDataSecret": {"name": "worker-user-data"}}`,
+			expectedOk:   true,
+			expectedWarnings: []string{
+				"providerSpec.cloudsSecret: Invalid value: \"missing\": not found. Expected cloudsSecret to exist",
+			},
+		},
+		{
+			testCase:     "with a clouds secret without clouds.yaml",
+			providerSpec: `{"cloudName": "openstack", "cloudsSecret": {"name": "no-clouds-yaml"}, "flavor": "m1.large", "image": "rhcos", "networks": [{"uuid": "network"}], "userDataSecret": {"name": "worker-user-data"}}`,
+			expectedOk:   true,
+			expectedWarnings: []string{
+				"providerSpec.cloudsSecret: Invalid value: \"no-clouds-yaml\": has no clouds.yaml key: the machine controller will fail to authenticate",
+			},
+		},
+		{
+			testCase:     "with a cloud the clouds secret does not define",
+			providerSpec: `{"cloudName": "undefined", "cloudsSecret": {"name": "openstack-cloud-credentials"}, "flavor": "m1.large", "image": "rhcos", "networks": [{"uuid": "network"}], "userDataSecret": {"name": "worker-user-data"}}`,
+			expectedOk:   true,
+			expectedWarnings: []string{
+				"providerSpec.cloudsSecret: Invalid value: \"openstack-cloud-credentials\": clouds.yaml does not define the cloud undefined: the machine controller will fail to authenticate",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			config := &admissionConfig{
+				client: c,
+				platformStatus: &osconfigv1.PlatformStatus{
+					Type:      osconfigv1.OpenStackPlatformType,
+					OpenStack: &osconfigv1.OpenStackPlatformStatus{CloudName: tc.cloudName},
+				},
+			}
+			m := &machinev1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace},
+				Spec: machinev1.MachineSpec{
+					ProviderSpec: machinev1.ProviderSpec{Value: &kruntime.RawExtension{Raw: []byte(tc.providerSpec)}},
+				},
+			}
+
+			ok, warnings, err := validateOpenStack(m, config)
+			if ok != tc.expectedOk {
+				t.Errorf("expected: %v, got: %v", tc.expectedOk, ok)
+			}
+			if err == nil {
+				if tc.expectedError != "" {
+					t.Errorf("expected: %q, got: %v", tc.expectedError, err)
+				}
+			} else {
+				if err.Error() != tc.expectedError {
+					t.Errorf("expected: %q, got: %q", tc.expectedError, err.Error())
+				}
+			}
+			if !reflect.DeepEqual(warnings, tc.expectedWarnings) {
+				t.Errorf("expected: %q, got: %q", tc.expectedWarnings, warnings)
+			}
+		})
+	}
+}
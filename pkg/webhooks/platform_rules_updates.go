@@ -0,0 +1,37 @@
+package webhooks
+
+import (
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// platformRulesValidatedOnChange are the platforms whose Machines were admitted without validating their
+// providerSpec before the platform rules were added. Existing Machines may not satisfy the rules, so the rules
+// only apply to new providerSpecs and to changed ones: Machines can still be labeled, scaled down or deleted
+// without fixing a providerSpec their machine controller already runs with.
+var platformRulesValidatedOnChange = map[osconfigv1.PlatformType]bool{
+	osconfigv1.OpenStackPlatformType:    true,
+	nutanixPlatformType:                 true,
+	osconfigv1.IBMCloudPlatformType:     true,
+	osconfigv1.PowerVSPlatformType:      true,
+	osconfigv1.AlibabaCloudPlatformType: true,
+}
+
+// skipPlatformRules returns whether the platform rules are skipped on the update of obj, the Machine m or the
+// MachineSet templating it, as its platform is only validated on change and its providerSpec is unchanged,
+// or as obj is being deleted.
+func skipPlatformRules(obj metav1.Object, m, oldM *machinev1.Machine, clusterPlatform osconfigv1.PlatformType) bool {
+	if oldM == nil {
+		return false
+	}
+
+	platform := providerSpecPlatform(m)
+	if platform == "" {
+		platform = clusterPlatform
+	}
+	if !platformRulesValidatedOnChange[platform] {
+		return false
+	}
+	return isDeleting(obj) || !providerSpecChanged(oldM.Spec.ProviderSpec.Value, m.Spec.ProviderSpec.Value)
+}
@@ -0,0 +1,153 @@
+package webhooks
+
+import (
+	"testing"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPlatformRulesOnUpdate(t *testing.T) {
+	testCases := []struct {
+		testCase        string
+		platform        osconfigv1.PlatformType
+		oldProviderSpec string
+		providerSpec    string
+		deleting        bool
+		expectedOk      bool
+	}{
+		{
+			testCase:     "OpenStack with an invalid providerSpec on create",
+			platform:     osconfigv1.OpenStackPlatformType,
+			providerSpec: `{"kind":"OpenstackProviderSpec"}`,
+			expectedOk:   false,
+		},
+		{
+			testCase:        "OpenStack with an unchanged invalid providerSpec",
+			platform:        osconfigv1.OpenStackPlatformType,
+			oldProviderSpec: `{"kind":"OpenstackProviderSpec"}`,
+			providerSpec:    `{"kind": "OpenstackProviderSpec"}`,
+			expectedOk:      true,
+		},
+		{
+			testCase:        "OpenStack with a changed invalid providerSpec",
+			platform:        osconfigv1.OpenStackPlatformType,
+			oldProviderSpec: `{"kind":"OpenstackProviderSpec"}`,
+			providerSpec:    `{"kind":"OpenstackProviderSpec","flavor":"m1.large"}`,
+			expectedOk:      false,
+		},
+		{
+			testCase:        "OpenStack with a changed invalid providerSpec while deleting",
+			platform:        osconfigv1.OpenStackPlatformType,
+			oldProviderSpec: `{"kind":"OpenstackProviderSpec"}`,
+			providerSpec:    `{"kind":"OpenstackProviderSpec","flavor":"m1.large"}`,
+			deleting:        true,
+			expectedOk:      true,
+		},
+		{
+			testCase:     "Nutanix with an invalid providerSpec on create",
+			platform:     nutanixPlatformType,
+			providerSpec: `{"kind":"NutanixMachineProviderConfig"}`,
+			expectedOk:   false,
+		},
+		{
+			testCase:        "Nutanix with an unchanged invalid providerSpec",
+			platform:        nutanixPlatformType,
+			oldProviderSpec: `{"kind":"NutanixMachineProviderConfig"}`,
+			providerSpec:    `{"kind": "NutanixMachineProviderConfig"}`,
+			expectedOk:      true,
+		},
+		{
+			testCase:     "IBM Cloud with an invalid providerSpec on create",
+			platform:     osconfigv1.IBMCloudPlatformType,
+			providerSpec: `{"kind":"IBMCloudMachineProviderSpec"}`,
+			expectedOk:   false,
+		},
+		{
+			testCase:        "IBM Cloud with an unchanged invalid providerSpec",
+			platform:        osconfigv1.IBMCloudPlatformType,
+			oldProviderSpec: `{"kind":"IBMCloudMachineProviderSpec"}`,
+			providerSpec:    `{"kind": "IBMCloudMachineProviderSpec"}`,
+			expectedOk:      true,
+		},
+		{
+			testCase:     "PowerVS with an invalid providerSpec on create",
+			platform:     osconfigv1.PowerVSPlatformType,
+			providerSpec: `{"kind":"PowerVSMachineProviderConfig"}`,
+			expectedOk:   false,
+		},
+		{
+			testCase:        "PowerVS with an unchanged invalid providerSpec",
+			platform:        osconfigv1.PowerVSPlatformType,
+			oldProviderSpec: `{"kind":"PowerVSMachineProviderConfig"}`,
+			providerSpec:    `{"kind": "PowerVSMachineProviderConfig"}`,
+			expectedOk:      true,
+		},
+		{
+			testCase:     "Alibaba Cloud with an invalid providerSpec on create",
+			platform:     osconfigv1.AlibabaCloudPlatformType,
+			providerSpec: `{"kind":"AlibabaCloudMachineProviderConfig"}`,
+			expectedOk:   false,
+		},
+		{
+			testCase:        "Alibaba Cloud with an unchanged invalid providerSpec",
+			platform:        osconfigv1.AlibabaCloudPlatformType,
+			oldProviderSpec: `{"kind":"AlibabaCloudMachineProviderConfig"}`,
+			providerSpec:    `{"kind": "AlibabaCloudMachineProviderConfig"}`,
+			expectedOk:      true,
+		},
+		{
+			testCase:        "AWS with an unchanged invalid providerSpec",
+			platform:        osconfigv1.AWSPlatformType,
+			oldProviderSpec: `{"kind":"AWSMachineProviderConfig"}`,
+			providerSpec:    `{"kind":"AWSMachineProviderConfig"}`,
+			expectedOk:      false,
+		},
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme)
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			infra := plainInfra.DeepCopy()
+			infra.Status.PlatformStatus.Type = tc.platform
+
+			m := &machinev1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "namespace"},
+			}
+			m.Spec.ProviderSpec.Value = &kruntime.RawExtension{Raw: []byte(tc.providerSpec)}
+			if tc.deleting {
+				now := metav1.Now()
+				m.DeletionTimestamp = &now
+			}
+			var oldM *machinev1.Machine
+			if tc.oldProviderSpec != "" {
+				oldM = m.DeepCopy()
+				oldM.DeletionTimestamp = nil
+				oldM.Spec.ProviderSpec.Value = &kruntime.RawExtension{Raw: []byte(tc.oldProviderSpec)}
+			}
+
+			ok, _, errs := createMachineValidator(infra, c, plainDNS).validateMachine(m, oldM)
+			if ok != tc.expectedOk {
+				t.Errorf("machine: expected: %v, got: %v: %v", tc.expectedOk, ok, errs)
+			}
+
+			ms := &machinev1.MachineSet{ObjectMeta: m.ObjectMeta}
+			ms.Spec.Template.Spec = m.Spec
+			var oldMS *machinev1.MachineSet
+			if oldM != nil {
+				oldMS = &machinev1.MachineSet{ObjectMeta: oldM.ObjectMeta}
+				oldMS.Spec.Template.Spec = oldM.Spec
+			}
+
+			ok, _, errs = createMachineSetValidator(infra, c, plainDNS).validateMachineSet(ms, oldMS)
+			if ok != tc.expectedOk {
+				t.Errorf("machineset: expected: %v, got: %v: %v", tc.expectedOk, ok, errs)
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/metrics"
+	corev1 "k8s.io/api/core/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// ValidationMode controls what the validating webhooks do with invalid resources.
+type ValidationMode string
+
+const (
+	// ValidationModeEnforce denies invalid resources.
+	ValidationModeEnforce ValidationMode = "enforce"
+	// ValidationModeAudit admits invalid resources, returning the would-be denial
+	// as warnings and audit annotations.
+	ValidationModeAudit ValidationMode = "audit"
+
+	// ValidationPolicyConfigMapName is the ConfigMap in the webhook namespace which overrides
+	// the validation mode set on the command line. Changes take effect without a restart.
+	ValidationPolicyConfigMapName = "machine-api-webhook-policy"
+	// validationPolicyModeKey is the ValidationPolicyConfigMapName key holding the validation mode.
+	validationPolicyModeKey = "validationMode"
+	// awsRequireVolumeEncryptionKey is the ValidationPolicyConfigMapName key which, when true,
+	// requires all AWS block devices to be encrypted.
+	awsRequireVolumeEncryptionKey = "aws.requireVolumeEncryption"
+	// azureValidateVMSizeAvailabilityKey is the ValidationPolicyConfigMapName key which, when true,
+	// denies Azure VM sizes which are not available in their location or zone, see AzureVMSKUsConfigMapName.
+	azureValidateVMSizeAvailabilityKey = "azure.validateVMSizeAvailability"
+	// machineSetMinReplicasKey and machineSetMaxReplicasKey are the ValidationPolicyConfigMapName keys
+	// bounding the replicas of every MachineSet, including when scaled through the scale subresource.
+	machineSetMinReplicasKey = "machineSet.minReplicas"
+	machineSetMaxReplicasKey = "machineSet.maxReplicas"
+	// lifecycleHooksAllowedOwnersKey is the ValidationPolicyConfigMapName key listing, comma separated,
+	// the owners allowed to add lifecycle hooks to machines. Any owner is allowed when it is not set.
+	lifecycleHooksAllowedOwnersKey = "lifecycleHooks.allowedOwners"
+	// controlPlaneQuorumKey is the ValidationPolicyConfigMapName key holding the number of control plane
+	// machines which must remain after deleting a control plane Machine, 0 allowing any deletion.
+	controlPlaneQuorumKey = "controlPlane.quorum"
+
+	// auditDenialAnnotation is the audit annotation recording the would-be denial in audit mode.
+	auditDenialAnnotation = "audit-denial"
+
+	// admissionResourceMachine and admissionResourceMachineSet are the resource labels of the admission metrics.
+	admissionResourceMachine    = "machine"
+	admissionResourceMachineSet = "machineset"
+
+	// admissionDecisionAllowed, admissionDecisionDenied and admissionDecisionAudited are the decision labels of the
+	// admission metrics. Audited requests are invalid requests admitted in audit mode.
+	admissionDecisionAllowed = "allowed"
+	admissionDecisionDenied  = "denied"
+	admissionDecisionAudited = "audited"
+
+	// unknownAdmissionPlatform is the platform label of the admission metrics when the platform is not known.
+	unknownAdmissionPlatform = "unknown"
+)
+
+// PolicyConfigMaps are the ConfigMaps in the webhook namespace which configure the webhooks,
+// keyed by name, with a function checking that their contents parse.
+var PolicyConfigMaps = map[string]func(*corev1.ConfigMap) error{
+	ValidationPolicyConfigMapName:  validateValidationPolicy,
+	DefaultsOverridesConfigMapName: validateDefaultsOverrides,
+}
+
+// validateValidationPolicy checks that the validation policy ConfigMap sets a known validation mode
+// and valid policy switches.
+func validateValidationPolicy(policy *corev1.ConfigMap) error {
+	if value, ok := policy.Data[validationPolicyModeKey]; ok {
+		if _, err := ParseValidationMode(value); err != nil {
+			return fmt.Errorf("%s: %w", validationPolicyModeKey, err)
+		}
+	}
+
+	for _, key := range []string{awsRequireVolumeEncryptionKey, azureValidateVMSizeAvailabilityKey} {
+		if value, ok := policy.Data[key]; ok {
+			if _, err := strconv.ParseBool(value); err != nil {
+				return fmt.Errorf("%s: invalid value %q: expected true or false", key, value)
+			}
+		}
+	}
+
+	if value, ok := policy.Data[lifecycleHooksAllowedOwnersKey]; ok {
+		if _, err := parseLifecycleHookOwners(value); err != nil {
+			return fmt.Errorf("%s: invalid value %q: %w", lifecycleHooksAllowedOwnersKey, value, err)
+		}
+	}
+
+	if _, err := parseControlPlaneQuorum(policy); err != nil {
+		return err
+	}
+
+	_, err := parseMachineSetReplicaLimits(policy)
+	return err
+}
+
+// ParseValidationMode returns the ValidationMode named by mode.
+func ParseValidationMode(mode string) (ValidationMode, error) {
+	switch ValidationMode(mode) {
+	case ValidationModeEnforce, ValidationModeAudit:
+		return ValidationMode(mode), nil
+	default:
+		return "", fmt.Errorf("unknown validation mode %q: expected one of %s, %s", mode, ValidationModeEnforce, ValidationModeAudit)
+	}
+}
+
+// validationModeFromPolicy returns the validation mode set in the policy ConfigMap,
+// or defaultMode when the ConfigMap does not exist or does not set a valid mode.
+func validationModeFromPolicy(policy *corev1.ConfigMap, defaultMode ValidationMode) ValidationMode {
+	if policy == nil {
+		return defaultMode
+	}
+
+	value, ok := policy.Data[validationPolicyModeKey]
+	if !ok {
+		return defaultMode
+	}
+
+	mode, err := ParseValidationMode(value)
+	if err != nil {
+		klog.Errorf("Ignoring %s in ConfigMap %s/%s: %v", validationPolicyModeKey, policy.GetNamespace(), policy.GetName(), err)
+		return defaultMode
+	}
+	return mode
+}
+
+// requireAWSVolumeEncryptionFromPolicy returns whether the policy ConfigMap requires AWS block devices
+// to be encrypted. Encryption is not required when the ConfigMap does not exist or does not set a valid value.
+func requireAWSVolumeEncryptionFromPolicy(policy *corev1.ConfigMap) bool {
+	return policySwitch(policy, awsRequireVolumeEncryptionKey)
+}
+
+// AzureVMSizeAvailabilityFromPolicy returns whether the policy ConfigMap enables the validation of Azure VM sizes
+// against the SKUs cached in the AzureVMSKUsConfigMapName ConfigMap. The validation is disabled when the
+// ConfigMap does not exist or does not set a valid value.
+func AzureVMSizeAvailabilityFromPolicy(policy *corev1.ConfigMap) bool {
+	return policySwitch(policy, azureValidateVMSizeAvailabilityKey)
+}
+
+// policySwitch returns the boolean value of the policy ConfigMap key, false when it is not set to a valid value.
+func policySwitch(policy *corev1.ConfigMap, key string) bool {
+	if policy == nil {
+		return false
+	}
+
+	value, ok := policy.Data[key]
+	if !ok {
+		return false
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		klog.Errorf("Ignoring %s in ConfigMap %s/%s: invalid value %q", key, policy.GetNamespace(), policy.GetName(), value)
+		return false
+	}
+	return enabled
+}
+
+// safetyDenialError is the error of a built-in safety check, eg. the control plane quorum check.
+// Audit mode is meant to roll out the validation of the resources, it does not lift these checks.
+type safetyDenialError struct {
+	err error
+}
+
+func (e *safetyDenialError) Error() string {
+	return e.err.Error()
+}
+
+func (e *safetyDenialError) Unwrap() error {
+	return e.err
+}
+
+// safetyDenials marks the errors as errors of a built-in safety check, which deny requests in audit mode too.
+func safetyDenials(errs ...error) []error {
+	denials := make([]error, 0, len(errs))
+	for _, err := range errs {
+		denials = append(denials, &safetyDenialError{err: err})
+	}
+	return denials
+}
+
+// hasSafetyDenial returns whether one of the errors is the error of a built-in safety check.
+func hasSafetyDenial(errs utilerrors.Aggregate) bool {
+	for _, err := range errs.Errors() {
+		var safetyErr *safetyDenialError
+		if errors.As(err, &safetyErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// validationResponse returns the admission response for the outcome of the validation of the resource of the platform.
+// In audit mode a denial is turned into an allowed response carrying the errors as
+// warnings and audit annotations, and is counted per validation rule, unless one of the errors is
+// the error of a built-in safety check.
+// The decision, the warnings and the errors of denials are counted in the admission metrics.
+func validationResponse(resource, platform string, mode ValidationMode, ok bool, warnings []string, errs utilerrors.Aggregate, allowedMsg string) admission.Response {
+	metrics.ObserveWebhookAdmissionWarnings(resource, platform, len(warnings))
+
+	if ok {
+		metrics.ObserveWebhookAdmissionDecision(resource, platform, admissionDecisionAllowed)
+		return admission.Allowed(allowedMsg).WithWarnings(warnings...)
+	}
+
+	if mode != ValidationModeAudit || hasSafetyDenial(errs) {
+		metrics.ObserveWebhookAdmissionDecision(resource, platform, admissionDecisionDenied)
+		for _, err := range errs.Errors() {
+			metrics.ObserveWebhookAdmissionDenial(resource, platform, validationRule(err))
+		}
+		return admission.Denied(errs.Error()).WithWarnings(warnings...)
+	}
+
+	metrics.ObserveWebhookAdmissionDecision(resource, platform, admissionDecisionAudited)
+	for _, err := range errs.Errors() {
+		metrics.ObserveWebhookAuditDenial(validationRule(err))
+		warnings = append(warnings, fmt.Sprintf("audit mode: would be denied: %v", err))
+	}
+
+	resp := admission.Allowed("admitted in audit mode").WithWarnings(warnings...)
+	resp.AuditAnnotations = map[string]string{
+		auditDenialAnnotation: errs.Error(),
+	}
+	return resp
+}
+
+// fieldPathSubscript matches the list indexes and map keys of a field path, eg. [0] or [machine.openshift.io/zone].
+var fieldPathSubscript = regexp.MustCompile(`\[[^\]]*\]`)
+
+// validationRule identifies the validation which produced err for metrics.
+// Field errors are identified by their field path and error type. The indexes and keys of the field path
+// are replaced by [*], as they would make the values of the rule label unbounded.
+func validationRule(err error) string {
+	var fieldErr *field.Error
+	if errors.As(err, &fieldErr) {
+		return fmt.Sprintf("%s:%s", fieldPathSubscript.ReplaceAllString(fieldErr.Field, "[*]"), string(fieldErr.Type))
+	}
+	return "unknown"
+}
+
+// admissionPlatform returns the platform label of the admission metrics of the machine: the platform of its
+// providerSpec kind, or else the cluster platform. The machine may be nil, eg. when scaling a MachineSet.
+func (a *admissionHandler) admissionPlatform(m *machinev1.Machine) string {
+	if m != nil {
+		if platform := providerSpecPlatform(m); platform != "" {
+			return string(platform)
+		}
+	}
+	if a.admissionConfig != nil && a.platformStatus != nil && a.platformStatus.Type != "" {
+		return string(a.platformStatus.Type)
+	}
+	return unknownAdmissionPlatform
+}
@@ -0,0 +1,315 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/machine-api-operator/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kruntime "k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// auditDenials returns the mapi_webhook_audit_denials_total value for the rule.
+func auditDenials(g *WithT, rule string) float64 {
+	registry := prometheus.NewRegistry()
+	g.Expect(registry.Register(metrics.WebhookAuditDenialsTotal)).To(Succeed())
+
+	families, err := registry.Gather()
+	g.Expect(err).ToNot(HaveOccurred())
+
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "rule" && label.GetValue() == rule {
+					return metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// admissionMetricValue returns the value of the admission counter with the labels.
+func admissionMetricValue(g *WithT, counter *prometheus.CounterVec, labels prometheus.Labels) float64 {
+	metric := &dto.Metric{}
+	g.Expect(counter.With(labels).Write(metric)).To(Succeed())
+	return metric.GetCounter().GetValue()
+}
+
+func TestValidationResponseAdmissionMetrics(t *testing.T) {
+	const (
+		resource = admissionResourceMachine
+		platform = "AWS"
+		rule     = "providerSpec.ami:FieldValueRequired"
+	)
+	errs := utilerrors.NewAggregate([]error{field.Required(field.NewPath("providerSpec", "ami"), "expected providerSpec.ami.id to be populated")})
+
+	testCases := []struct {
+		name             string
+		mode             ValidationMode
+		ok               bool
+		errs             utilerrors.Aggregate
+		expectedDecision string
+		expectedDenials  float64
+	}{
+		{
+			name:             "with a valid request",
+			mode:             ValidationModeEnforce,
+			ok:               true,
+			expectedDecision: admissionDecisionAllowed,
+		},
+		{
+			name:             "with an invalid request",
+			mode:             ValidationModeEnforce,
+			errs:             errs,
+			expectedDecision: admissionDecisionDenied,
+			expectedDenials:  1,
+		},
+		{
+			name:             "with an invalid request in audit mode",
+			mode:             ValidationModeAudit,
+			errs:             errs,
+			expectedDecision: admissionDecisionAudited,
+		},
+		{
+			name:             "with a safety denial in audit mode",
+			mode:             ValidationModeAudit,
+			errs:             utilerrors.NewAggregate(safetyDenials(errs.Errors()...)),
+			expectedDecision: admissionDecisionDenied,
+			expectedDenials:  1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			decisionLabels := prometheus.Labels{"resource": resource, "platform": platform, "decision": tc.expectedDecision}
+			denialLabels := prometheus.Labels{"resource": resource, "platform": platform, "rule": rule}
+			warningLabels := prometheus.Labels{"resource": resource, "platform": platform}
+			decisionsBefore := admissionMetricValue(g, metrics.WebhookAdmissionDecisionsTotal, decisionLabels)
+			denialsBefore := admissionMetricValue(g, metrics.WebhookAdmissionDenialsTotal, denialLabels)
+			warningsBefore := admissionMetricValue(g, metrics.WebhookAdmissionWarningsTotal, warningLabels)
+
+			resp := validationResponse(resource, platform, tc.mode, tc.ok, []string{"first warning", "second warning"}, tc.errs, "valid")
+			g.Expect(resp.Allowed).To(Equal(tc.expectedDecision != admissionDecisionDenied))
+
+			g.Expect(admissionMetricValue(g, metrics.WebhookAdmissionDecisionsTotal, decisionLabels)).To(Equal(decisionsBefore + 1))
+			g.Expect(admissionMetricValue(g, metrics.WebhookAdmissionDenialsTotal, denialLabels)).To(Equal(denialsBefore + tc.expectedDenials))
+			g.Expect(admissionMetricValue(g, metrics.WebhookAdmissionWarningsTotal, warningLabels)).To(Equal(warningsBefore + 2))
+		})
+	}
+}
+
+func TestAdmissionPlatform(t *testing.T) {
+	g := NewWithT(t)
+
+	h := &admissionHandler{admissionConfig: &admissionConfig{platformStatus: &osconfigv1.PlatformStatus{Type: osconfigv1.AWSPlatformType}}}
+
+	gcpMachine := &machinev1.Machine{}
+	gcpMachine.Spec.ProviderSpec.Value = &kruntime.RawExtension{Raw: []byte(`{"kind":"GCPMachineProviderSpec"}`)}
+	g.Expect(h.admissionPlatform(gcpMachine)).To(Equal("GCP"))
+	g.Expect(h.admissionPlatform(&machinev1.Machine{})).To(Equal("AWS"))
+	g.Expect(h.admissionPlatform(nil)).To(Equal("AWS"))
+	g.Expect((&admissionHandler{}).admissionPlatform(nil)).To(Equal(unknownAdmissionPlatform))
+}
+
+func TestValidationRule(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{
+			name:     "with a field error",
+			err:      field.Required(field.NewPath("providerSpec", "ami"), "required"),
+			expected: "providerSpec.ami:FieldValueRequired",
+		},
+		{
+			name:     "with a field error on a list index",
+			err:      field.Invalid(field.NewPath("providerSpec", "blockDevices").Index(3).Child("ebs", "kmsKey"), "", "invalid"),
+			expected: "providerSpec.blockDevices[*].ebs.kmsKey:FieldValueInvalid",
+		},
+		{
+			name:     "with a field error on a map key",
+			err:      field.Invalid(field.NewPath("metadata", "annotations").Key("machine.openshift.io/max-replicas"), "ten", "invalid"),
+			expected: "metadata.annotations[*]:FieldValueInvalid",
+		},
+		{
+			name:     "with a safety denial",
+			err:      safetyDenials(field.Forbidden(field.NewPath("metadata", "name"), "forbidden"))[0],
+			expected: "metadata.name:FieldValueForbidden",
+		},
+		{
+			name:     "with another error",
+			err:      errors.New("failed"),
+			expected: "unknown",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := validationRule(tc.err); got != tc.expected {
+				t.Errorf("expected %q, got: %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestValidationMode(t *testing.T) {
+	const amiRule = "providerSpec.ami:FieldValueRequired"
+
+	infra := plainInfra.DeepCopy()
+	infra.ObjectMeta = metav1.ObjectMeta{Name: clusterConfigName}
+	infra.Status.InfrastructureName = "clusterID"
+	infra.Status.PlatformStatus.Type = osconfigv1.AWSPlatformType
+	dns := &osconfigv1.DNS{ObjectMeta: metav1.ObjectMeta{Name: clusterConfigName}}
+
+	// The providerSpec is missing the AMI, which is always denied in enforce mode.
+	rawProviderSpec, err := json.Marshal(&machinev1.AWSMachineProviderConfig{
+		InstanceType: "m5.xlarge",
+		Placement:    machinev1.Placement{Region: "region"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: defaultWebhookServiceNamespace},
+		Spec: machinev1.MachineSpec{
+			ProviderSpec: machinev1.ProviderSpec{Value: &kruntime.RawExtension{Raw: rawProviderSpec}},
+		},
+	}
+	rawMachine, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Create,
+			Object:    kruntime.RawExtension{Raw: rawMachine},
+		},
+	}
+
+	policy := func(mode string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      ValidationPolicyConfigMapName,
+				Namespace: defaultWebhookServiceNamespace,
+			},
+			Data: map[string]string{validationPolicyModeKey: mode},
+		}
+	}
+
+	testCases := []struct {
+		name              string
+		flagMode          ValidationMode
+		policy            *corev1.ConfigMap
+		expectedAllowed   bool
+		expectedAuditMode bool
+	}{
+		{
+			name:            "enforce mode denies the broken spec",
+			flagMode:        ValidationModeEnforce,
+			expectedAllowed: false,
+		},
+		{
+			name:              "audit mode admits the broken spec",
+			flagMode:          ValidationModeAudit,
+			expectedAllowed:   true,
+			expectedAuditMode: true,
+		},
+		{
+			name:              "the policy ConfigMap overrides enforce mode",
+			flagMode:          ValidationModeEnforce,
+			policy:            policy("audit"),
+			expectedAllowed:   true,
+			expectedAuditMode: true,
+		},
+		{
+			name:            "the policy ConfigMap overrides audit mode",
+			flagMode:        ValidationModeAudit,
+			policy:          policy("enforce"),
+			expectedAllowed: false,
+		},
+		{
+			name:              "an invalid policy ConfigMap mode is ignored",
+			flagMode:          ValidationModeAudit,
+			policy:            policy("permissive"),
+			expectedAllowed:   true,
+			expectedAuditMode: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			builder := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(infra.DeepCopy(), dns.DeepCopy())
+			if tc.policy != nil {
+				builder = builder.WithObjects(tc.policy)
+			}
+			c := builder.Build()
+
+			h := createMachineValidator(infra, c, dns)
+			h.inputs = newClusterInputsCache(c)
+			h.validationMode = tc.flagMode
+			decoder, err := admission.NewDecoder(scheme.Scheme)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(h.InjectDecoder(decoder)).To(Succeed())
+
+			deniesBefore := auditDenials(g, amiRule)
+			resp := h.Handle(context.Background(), req)
+			g.Expect(resp.Allowed).To(Equal(tc.expectedAllowed))
+
+			if tc.expectedAuditMode {
+				g.Expect(resp.Warnings).To(ContainElement("audit mode: would be denied: providerSpec.ami: Required value: expected providerSpec.ami.id to be populated"))
+				g.Expect(resp.AuditAnnotations).To(HaveKeyWithValue(auditDenialAnnotation, ContainSubstring("providerSpec.ami")))
+				g.Expect(auditDenials(g, amiRule)).To(Equal(deniesBefore + 1))
+			} else {
+				g.Expect(string(resp.Result.Reason)).To(ContainSubstring("providerSpec.ami"))
+				g.Expect(resp.AuditAnnotations).To(BeEmpty())
+				g.Expect(auditDenials(g, amiRule)).To(Equal(deniesBefore))
+			}
+		})
+	}
+}
+
+func TestValidationModePolicyUpdate(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	h := &admissionHandler{
+		inputs:         newClusterInputsCache(c),
+		validationMode: ValidationModeEnforce,
+	}
+	h.inputs.refreshInterval = 0
+
+	g.Expect(h.currentValidationMode()).To(Equal(ValidationModeEnforce))
+
+	policy := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ValidationPolicyConfigMapName,
+			Namespace: defaultWebhookServiceNamespace,
+		},
+		Data: map[string]string{validationPolicyModeKey: string(ValidationModeAudit)},
+	}
+	g.Expect(c.Create(context.Background(), policy)).To(Succeed())
+	g.Expect(h.currentValidationMode()).To(Equal(ValidationModeAudit), "creating the policy should take effect without a restart")
+
+	g.Expect(c.Delete(context.Background(), policy)).To(Succeed())
+	g.Expect(h.currentValidationMode()).To(Equal(ValidationModeEnforce), "deleting the policy should restore the configured mode")
+}
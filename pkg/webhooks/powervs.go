@@ -0,0 +1,165 @@
+package webhooks
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// PowerVS processor types. Shared and capped processors are allocated in fractions of a core.
+	powerVSProcessorTypeDedicated = "Dedicated"
+	powerVSProcessorTypeShared    = "Shared"
+	powerVSProcessorTypeCapped    = "Capped"
+	// powerVSProcessorIncrement is the smallest fraction of a core shared and capped processors are allocated in.
+	powerVSProcessorIncrement = 0.25
+
+	minPowerVSMemoryGiB = 2
+	// Recommended minimum PowerVS values for RHCOS nodes.
+	// https://docs.openshift.com/container-platform/4.10/installing/installing_ibm_powervs/installing-ibm-power-vs-customizations.html
+	recommendedPowerVSProcessors = 0.5
+	recommendedPowerVSMemoryGiB  = 16
+)
+
+// powerVSSystemLimits are the processor and memory limits of a single virtual server on a PowerVS system type.
+// https://cloud.ibm.com/docs/power-iaas?topic=power-iaas-pricing-virtual-server
+type powerVSSystemLimits struct {
+	maxProcessors float64
+	maxMemoryGiB  int32
+}
+
+// powerVSSystemTypes are the supported PowerVS system types.
+var powerVSSystemTypes = map[string]powerVSSystemLimits{
+	"s922": {maxProcessors: 15, maxMemoryGiB: 942},
+	"e980": {maxProcessors: 143, maxMemoryGiB: 15307},
+}
+
+// powerVSProviderSpec is the subset of the PowerVS providerSpec the webhook validates.
+// It models the service instance the LPAR is created in, its image, network, system and processor type,
+// and its processor and memory allotment.
+type powerVSProviderSpec struct {
+	ServiceInstanceID string                       `json:"serviceInstanceID,omitempty"`
+	Image             powerVSResource              `json:"image,omitempty"`
+	Network           powerVSResource              `json:"network,omitempty"`
+	SystemType        string                       `json:"systemType,omitempty"`
+	ProcessorType     string                       `json:"processorType,omitempty"`
+	Processors        intstr.IntOrString           `json:"processors,omitempty"`
+	MemoryGiB         int32                        `json:"memoryGiB,omitempty"`
+	UserDataSecret    *corev1.LocalObjectReference `json:"userDataSecret,omitempty"`
+	CredentialsSecret *corev1.LocalObjectReference `json:"credentialsSecret,omitempty"`
+}
+
+// powerVSResource references a PowerVS image or network by ID, by name or, for networks, by a name regex.
+type powerVSResource struct {
+	ID    *string `json:"id,omitempty"`
+	Name  *string `json:"name,omitempty"`
+	RegEx *string `json:"regex,omitempty"`
+}
+
+func validatePowerVS(m *machinev1.Machine, config *admissionConfig) (bool, []string, utilerrors.Aggregate) {
+	klog.V(3).Infof("Validating PowerVS providerSpec")
+
+	var errs []error
+	var warnings []string
+	providerSpec := new(powerVSProviderSpec)
+	if err := unmarshalInto(m, providerSpec); err != nil {
+		errs = append(errs, err)
+		return false, warnings, utilerrors.NewAggregate(errs)
+	}
+
+	if providerSpec.ServiceInstanceID == "" {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "serviceInstanceID"), "serviceInstanceID must be provided"))
+	}
+	if !providerSpec.Image.set(false) {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "image"), "image id or name must be provided"))
+	}
+	if !providerSpec.Network.set(true) {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "network"), "network id, name or regex must be provided"))
+	}
+
+	capacityWarnings, capacityErrs := validatePowerVSCapacity(providerSpec)
+	warnings = append(warnings, capacityWarnings...)
+	errs = append(errs, capacityErrs...)
+
+	if providerSpec.UserDataSecret == nil {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "userDataSecret"), "userDataSecret must be provided"))
+	} else if providerSpec.UserDataSecret.Name == "" {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "userDataSecret", "name"), "name must be provided"))
+	}
+
+	if providerSpec.CredentialsSecret == nil {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "credentialsSecret"), "credentialsSecret must be provided"))
+	} else if providerSpec.CredentialsSecret.Name == "" {
+		errs = append(errs, field.Required(field.NewPath("providerSpec", "credentialsSecret", "name"), "name must be provided"))
+	} else {
+		warnings = append(warnings, validateCredentialsSecret(config.secretsReader(), providerSpec.CredentialsSecret.Name, m.GetNamespace())...)
+	}
+
+	if len(errs) > 0 {
+		return false, warnings, utilerrors.NewAggregate(errs)
+	}
+	return true, warnings, nil
+}
+
+// set returns true if the resource is referenced by ID or name, or by regex when allowed.
+func (r powerVSResource) set(allowRegEx bool) bool {
+	nonEmpty := func(s *string) bool { return s != nil && *s != "" }
+	return nonEmpty(r.ID) || nonEmpty(r.Name) || (allowRegEx && nonEmpty(r.RegEx))
+}
+
+// validatePowerVSCapacity checks the processors and memory against the limits of the system type,
+// and warns when they are below what RHCOS nodes need to boot.
+func validatePowerVSCapacity(providerSpec *powerVSProviderSpec) ([]string, []error) {
+	var warnings []string
+	var errs []error
+
+	limits, ok := powerVSSystemTypes[providerSpec.SystemType]
+	if !ok {
+		errs = append(errs, field.NotSupported(field.NewPath("providerSpec", "systemType"), providerSpec.SystemType, sets.StringKeySet(powerVSSystemTypes).List()))
+	}
+
+	processorsPath := field.NewPath("providerSpec", "processors")
+	processors, err := strconv.ParseFloat(providerSpec.Processors.String(), 64)
+	if err != nil {
+		errs = append(errs, field.Invalid(processorsPath, providerSpec.Processors.String(), "processors must be a number"))
+	} else {
+		switch providerSpec.ProcessorType {
+		case powerVSProcessorTypeDedicated:
+			if processors < 1 || processors != math.Trunc(processors) {
+				errs = append(errs, field.Invalid(processorsPath, providerSpec.Processors.String(), "dedicated processors must be a whole number of at least 1"))
+			}
+		case "", powerVSProcessorTypeShared, powerVSProcessorTypeCapped:
+			if processors < powerVSProcessorIncrement || math.Mod(processors, powerVSProcessorIncrement) != 0 {
+				errs = append(errs, field.Invalid(processorsPath, providerSpec.Processors.String(), fmt.Sprintf("shared and capped processors must be a multiple of %v", powerVSProcessorIncrement)))
+			}
+		default:
+			errs = append(errs, field.NotSupported(field.NewPath("providerSpec", "processorType"), providerSpec.ProcessorType, []string{powerVSProcessorTypeCapped, powerVSProcessorTypeDedicated, powerVSProcessorTypeShared}))
+		}
+
+		if ok && processors > limits.maxProcessors {
+			errs = append(errs, field.Invalid(processorsPath, providerSpec.Processors.String(), fmt.Sprintf("processors must be at most %v on %s systems", limits.maxProcessors, providerSpec.SystemType)))
+		}
+		if processors > 0 && processors < recommendedPowerVSProcessors {
+			warnings = append(warnings, fmt.Sprintf("providerSpec.processors: %s is less than the recommended minimum value (%v): with too few SMT threads nodes may not boot correctly", providerSpec.Processors.String(), recommendedPowerVSProcessors))
+		}
+	}
+
+	memoryPath := field.NewPath("providerSpec", "memoryGiB")
+	if providerSpec.MemoryGiB < minPowerVSMemoryGiB {
+		errs = append(errs, field.Invalid(memoryPath, providerSpec.MemoryGiB, fmt.Sprintf("memoryGiB must be at least %d", minPowerVSMemoryGiB)))
+	} else if ok && providerSpec.MemoryGiB > limits.maxMemoryGiB {
+		errs = append(errs, field.Invalid(memoryPath, providerSpec.MemoryGiB, fmt.Sprintf("memoryGiB must be at most %d on %s systems", limits.maxMemoryGiB, providerSpec.SystemType)))
+	} else if providerSpec.MemoryGiB < recommendedPowerVSMemoryGiB {
+		warnings = append(warnings, fmt.Sprintf("providerSpec.memoryGiB: %d is less than the recommended minimum value (%d): nodes may not boot correctly", providerSpec.MemoryGiB, recommendedPowerVSMemoryGiB))
+	}
+
+	return warnings, errs
+}
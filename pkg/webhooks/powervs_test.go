@@ -0,0 +1,176 @@
+package webhooks
+
+import (
+	"reflect"
+	"testing"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	yaml "sigs.k8s.io/yaml"
+)
+
+func TestValidatePowerVS(t *testing.T) {
+	namespace := "openshift-machine-api"
+	credentialsSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "powervs-credentials", Namespace: namespace},
+	}
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, credentialsSecret)
+
+	validProviderSpec := func(modify func(*powerVSProviderSpec)) *powerVSProviderSpec {
+		providerSpec := &powerVSProviderSpec{
+			ServiceInstanceID: "e449d86e-c3a0-4c07-959e-8557fdf55482",
+			Image:             powerVSResource{Name: pointer.StringPtr("rhcos")},
+			Network:           powerVSResource{RegEx: pointer.StringPtr("^DHCPSERVER.*Private$")},
+			SystemType:        "s922",
+			ProcessorType:     powerVSProcessorTypeShared,
+			Processors:        intstr.FromString("0.5"),
+			MemoryGiB:         32,
+			UserDataSecret:    &corev1.LocalObjectReference{Name: defaultUserDataSecret},
+			CredentialsSecret: &corev1.LocalObjectReference{Name: "powervs-credentials"},
+		}
+		if modify != nil {
+			modify(providerSpec)
+		}
+		return providerSpec
+	}
+
+	testCases := []struct {
+		testCase         string
+		providerSpec     *powerVSProviderSpec
+		expectedOk       bool
+		expectedError    string
+		expectedWarnings []string
+	}{
+		{
+			testCase:     "with a valid providerSpec",
+			providerSpec: validProviderSpec(nil),
+			expectedOk:   true,
+		},
+		{
+			testCase: "with dedicated processors",
+			providerSpec: validProviderSpec(func(p *powerVSProviderSpec) {
+				p.ProcessorType = powerVSProcessorTypeDedicated
+				p.Processors = intstr.FromInt(2)
+			}),
+			expectedOk: true,
+		},
+		{
+			testCase: "without references",
+			providerSpec: validProviderSpec(func(p *powerVSProviderSpec) {
+				p.ServiceInstanceID = ""
+				p.Image = powerVSResource{RegEx: pointer.StringPtr("rhcos.*")}
+				p.Network = powerVSResource{}
+			}),
+			expectedOk:    false,
+			expectedError: "[providerSpec.serviceInstanceID: Required value: serviceInstanceID must be provided, providerSpec.image: Required value: image id or name must be provided, providerSpec.network: Required value: network id, name or regex must be provided]",
+		},
+		{
+			testCase: "with an unknown system type",
+			providerSpec: validProviderSpec(func(p *powerVSProviderSpec) {
+				p.SystemType = "e880"
+			}),
+			expectedOk:    false,
+			expectedError: "providerSpec.systemType: Unsupported value: \"e880\": supported values: \"e980\", \"s922\"",
+		},
+		{
+			testCase: "with fractional dedicated processors",
+			providerSpec: validProviderSpec(func(p *powerVSProviderSpec) {
+				p.ProcessorType = powerVSProcessorTypeDedicated
+			}),
+			expectedOk:    false,
+			expectedError: "providerSpec.processors: Invalid value: \"0.5\": dedicated processors must be a whole number of at least 1",
+		},
+		{
+			testCase: "with shared processors that are not a multiple of the increment",
+			providerSpec: validProviderSpec(func(p *powerVSProviderSpec) {
+				p.Processors = intstr.FromString("0.6")
+			}),
+			expectedOk:    false,
+			expectedError: "providerSpec.processors: Invalid value: \"0.6\": shared and capped processors must be a multiple of 0.25",
+		},
+		{
+			testCase: "with more processors and memory than the system type allows",
+			providerSpec: validProviderSpec(func(p *powerVSProviderSpec) {
+				p.Processors = intstr.FromInt(16)
+				p.MemoryGiB = 1024
+			}),
+			expectedOk:    false,
+			expectedError: "[providerSpec.processors: Invalid value: \"16\": processors must be at most 15 on s922 systems, providerSpec.memoryGiB: Invalid value: 1024: memoryGiB must be at most 942 on s922 systems]",
+		},
+		{
+			testCase: "with more processors than s922 but not e980 systems allow",
+			providerSpec: validProviderSpec(func(p *powerVSProviderSpec) {
+				p.SystemType = "e980"
+				p.Processors = intstr.FromInt(16)
+				p.MemoryGiB = 1024
+			}),
+			expectedOk: true,
+		},
+		{
+			testCase: "with a non numeric processor count",
+			providerSpec: validProviderSpec(func(p *powerVSProviderSpec) {
+				p.Processors = intstr.FromString("half")
+			}),
+			expectedOk:    false,
+			expectedError: "providerSpec.processors: Invalid value: \"half\": processors must be a number",
+		},
+		{
+			testCase: "with too little memory",
+			providerSpec: validProviderSpec(func(p *powerVSProviderSpec) {
+				p.MemoryGiB = 1
+			}),
+			expectedOk:    false,
+			expectedError: "providerSpec.memoryGiB: Invalid value: 1: memoryGiB must be at least 2",
+		},
+		{
+			testCase: "with processors and memory unlikely to boot RHCOS",
+			providerSpec: validProviderSpec(func(p *powerVSProviderSpec) {
+				p.Processors = intstr.FromString("0.25")
+				p.MemoryGiB = 8
+			}),
+			expectedOk: true,
+			expectedWarnings: []string{
+				"providerSpec.processors: 0.25 is less than the recommended minimum value (0.5): with too few SMT threads nodes may not boot correctly",
+				"providerSpec.memoryGiB: 8 is less than the recommended minimum value (16): nodes may not boot correctly",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			rawBytes, err := yaml.Marshal(tc.providerSpec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := &machinev1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace},
+				Spec: machinev1.MachineSpec{
+					ProviderSpec: machinev1.ProviderSpec{Value: &kruntime.RawExtension{Raw: rawBytes}},
+				},
+			}
+
+			ok, warnings, err := validatePowerVS(m, &admissionConfig{client: c})
+			if ok != tc.expectedOk {
+				t.Errorf("expected: %v, got: %v", tc.expectedOk, ok)
+			}
+			if err == nil {
+				if tc.expectedError != "" {
+					t.Errorf("expected: %q, got: %v", tc.expectedError, err)
+				}
+			} else {
+				if err.Error() != tc.expectedError {
+					t.Errorf("expected: %q, got: %q", tc.expectedError, err.Error())
+				}
+			}
+			if !reflect.DeepEqual(warnings, tc.expectedWarnings) {
+				t.Errorf("expected: %q, got: %q", tc.expectedWarnings, warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/machine-api-operator/pkg/metrics"
+	"github.com/openshift/machine-api-operator/pkg/version"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// RuleSet describes the build of the Machine validating webhook and the configuration it validates with,
+// so that differences in admission behaviour between clusters can be told apart.
+type RuleSet struct {
+	Version                  string                  `json:"version"`
+	Commit                   string                  `json:"commit"`
+	Platform                 osconfigv1.PlatformType `json:"platform"`
+	HybridPlatformValidation bool                    `json:"hybridPlatformValidation"`
+	ValidationMode           ValidationMode          `json:"validationMode"`
+	// PolicyConfigMaps holds the data of the policy ConfigMaps which exist, keyed by name.
+	PolicyConfigMaps map[string]map[string]string `json:"policyConfigMaps,omitempty"`
+	// Hash identifies the rule set, regardless of the build.
+	Hash string `json:"hash"`
+}
+
+// currentRuleSet returns the rule set the handler validates with.
+func (h *machineValidatorHandler) currentRuleSet() RuleSet {
+	ruleSet := RuleSet{
+		HybridPlatformValidation: h.hybridPlatformValidation,
+		ValidationMode:           h.currentValidationMode(),
+	}
+	if h.platformStatus != nil {
+		ruleSet.Platform = h.platformStatus.Type
+	}
+
+	if h.inputs != nil {
+		policy, err := h.inputs.getValidationPolicy(context.Background())
+		if err != nil {
+			klog.Errorf("Unable to refresh the validation policy, using the last known value: %v", err)
+		}
+		overrides, err := h.inputs.getDefaultsOverrides(context.Background())
+		if err != nil {
+			klog.Errorf("Unable to refresh the defaults overrides, using the last known value: %v", err)
+		}
+
+		for _, configMap := range []*corev1.ConfigMap{policy, overrides} {
+			if configMap == nil {
+				continue
+			}
+			if ruleSet.PolicyConfigMaps == nil {
+				ruleSet.PolicyConfigMaps = map[string]map[string]string{}
+			}
+			ruleSet.PolicyConfigMaps[configMap.GetName()] = configMap.Data
+		}
+	}
+
+	ruleSet.Hash = ruleSetHash(ruleSet)
+	ruleSet.Version = version.Raw
+	ruleSet.Commit = version.Commit
+	return ruleSet
+}
+
+// ruleSetHash returns a short hash of the rule set, ignoring the build and any hash set already.
+func ruleSetHash(ruleSet RuleSet) string {
+	ruleSet.Version, ruleSet.Commit, ruleSet.Hash = "", "", ""
+
+	// Map keys are sorted when marshalling, so equal rule sets always have the same hash.
+	data, err := json.Marshal(ruleSet)
+	if err != nil {
+		klog.Errorf("Unable to hash the rule set: %v", err)
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// observeRuleSet reports the current rule set via the mapi_webhook_build_info metric and returns it.
+func (h *machineValidatorHandler) observeRuleSet() RuleSet {
+	ruleSet := h.currentRuleSet()
+	metrics.ObserveWebhookBuildInfo(ruleSet.Version, ruleSet.Commit, ruleSet.Hash)
+	return ruleSet
+}
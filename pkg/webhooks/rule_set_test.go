@@ -0,0 +1,84 @@
+package webhooks
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/openshift/machine-api-operator/pkg/metrics"
+	"github.com/openshift/machine-api-operator/pkg/version"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// buildInfoLabels returns the labels of the mapi_webhook_build_info series.
+func buildInfoLabels(g *WithT) []map[string]string {
+	registry := prometheus.NewRegistry()
+	g.Expect(registry.Register(metrics.WebhookBuildInfo)).To(Succeed())
+
+	families, err := registry.Gather()
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var series []map[string]string
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			g.Expect(metric.GetGauge().GetValue()).To(Equal(1.0))
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			series = append(series, labels)
+		}
+	}
+	return series
+}
+
+func TestRuleSet(t *testing.T) {
+	g := NewWithT(t)
+
+	raw, commit := version.Raw, version.Commit
+	version.Raw, version.Commit = "v4.10.0", "0123abcd"
+	defer func() { version.Raw, version.Commit = raw, commit }()
+
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	h := createMachineValidator(plainInfra, c, plainDNS)
+	h.inputs = newClusterInputsCache(c)
+	h.inputs.refreshInterval = 0
+
+	initial := h.observeRuleSet()
+	g.Expect(initial.Version).To(Equal("v4.10.0"))
+	g.Expect(initial.Commit).To(Equal("0123abcd"))
+	g.Expect(initial.ValidationMode).To(Equal(ValidationModeEnforce))
+	g.Expect(initial.PolicyConfigMaps).To(BeEmpty())
+	g.Expect(buildInfoLabels(g)).To(ConsistOf(map[string]string{
+		"version":       "v4.10.0",
+		"commit":        "0123abcd",
+		"rule_set_hash": initial.Hash,
+	}))
+
+	policy := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ValidationPolicyConfigMapName,
+			Namespace: defaultWebhookServiceNamespace,
+		},
+		Data: map[string]string{awsRequireVolumeEncryptionKey: "true"},
+	}
+	g.Expect(c.Create(context.Background(), policy)).To(Succeed())
+
+	enabled := h.observeRuleSet()
+	g.Expect(enabled.Hash).ToNot(Equal(initial.Hash), "toggling a policy override should change the hash")
+	g.Expect(enabled.PolicyConfigMaps).To(HaveKeyWithValue(ValidationPolicyConfigMapName, policy.Data))
+	g.Expect(buildInfoLabels(g)).To(ConsistOf(HaveKeyWithValue("rule_set_hash", enabled.Hash)), "the previous rule set should no longer be reported")
+
+	policy.Data[awsRequireVolumeEncryptionKey] = "false"
+	g.Expect(c.Update(context.Background(), policy)).To(Succeed())
+	disabled := h.observeRuleSet()
+	g.Expect(disabled.Hash).ToNot(Equal(enabled.Hash))
+
+	// The hash does not depend on the build.
+	version.Raw = "v4.11.0"
+	g.Expect(h.currentRuleSet().Hash).To(Equal(disabled.Hash))
+}
@@ -0,0 +1,85 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	kruntime "k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	yaml "sigs.k8s.io/yaml"
+)
+
+// removedProviderSpecFieldsAnnotation records the providerSpec fields removed by the defaulting webhook.
+const removedProviderSpecFieldsAnnotation = "machine.openshift.io/removed-provider-spec-fields"
+
+// disallowedProviderSpecFields are the top level providerSpec keys, per platform, which some
+// clients write into the providerSpec but which describe the instance rather than the desired
+// state. They are removed before defaulting so that they are never persisted.
+var disallowedProviderSpecFields = map[osconfigv1.PlatformType][]string{
+	osconfigv1.AWSPlatformType:     {"instanceId", "instanceState"},
+	osconfigv1.AzurePlatformType:   {"vmId"},
+	osconfigv1.GCPPlatformType:     {"selfLink"},
+	osconfigv1.VSpherePlatformType: {"instanceUuid"},
+}
+
+// sanitizeProviderSpec wraps a defaulting operation so that the disallowed providerSpec fields
+// are removed before defaulting. Removed fields are recorded in an annotation and a warning.
+func sanitizeProviderSpec(disallowed []string, defaulter machineAdmissionFn) machineAdmissionFn {
+	return func(m *machinev1.Machine, config *admissionConfig) (bool, []string, utilerrors.Aggregate) {
+		removed, err := removeProviderSpecFields(m, disallowed)
+		if err != nil {
+			return false, []string{}, utilerrors.NewAggregate([]error{err})
+		}
+
+		ok, warnings, errs := defaulter(m, config)
+		if len(removed) == 0 {
+			return ok, warnings, errs
+		}
+
+		if m.Annotations == nil {
+			m.Annotations = map[string]string{}
+		}
+		m.Annotations[removedProviderSpecFieldsAnnotation] = strings.Join(removed, ",")
+		warnings = append(warnings, fmt.Sprintf("providerSpec: removed %q: these fields describe the instance and must not be set in the providerSpec", removed))
+
+		return ok, warnings, errs
+	}
+}
+
+// removeProviderSpecFields removes the given top level keys from the raw providerSpec
+// and returns the sorted keys which were present.
+func removeProviderSpecFields(m *machinev1.Machine, keys []string) ([]string, error) {
+	if m.Spec.ProviderSpec.Value == nil || len(m.Spec.ProviderSpec.Value.Raw) == 0 {
+		// Nothing to sanitize, a missing providerSpec is reported by the defaulter.
+		return nil, nil
+	}
+
+	fields := map[string]interface{}{}
+	if err := yaml.Unmarshal(m.Spec.ProviderSpec.Value.Raw, &fields); err != nil {
+		return nil, field.Invalid(field.NewPath("providerSpec", "value"), string(m.Spec.ProviderSpec.Value.Raw), err.Error())
+	}
+
+	removed := sets.NewString()
+	for _, key := range keys {
+		if _, ok := fields[key]; ok {
+			delete(fields, key)
+			removed.Insert(key)
+		}
+	}
+	if removed.Len() == 0 {
+		return nil, nil
+	}
+
+	rawBytes, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	m.Spec.ProviderSpec.Value = &kruntime.RawExtension{Raw: rawBytes}
+
+	return removed.List(), nil
+}
@@ -0,0 +1,111 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	kruntime "k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestSanitizeProviderSpec(t *testing.T) {
+	testCases := []struct {
+		name             string
+		platformType     osconfigv1.PlatformType
+		providerSpec     map[string]interface{}
+		expectedRemoved  []string
+		expectedWarning  string
+		expectAnnotation bool
+	}{
+		{
+			name:         "with a polluted AWS providerSpec",
+			platformType: osconfigv1.AWSPlatformType,
+			providerSpec: map[string]interface{}{
+				"instanceType":  "m5.xlarge",
+				"instanceId":    "i-0123456789",
+				"instanceState": "running",
+			},
+			expectedRemoved:  []string{"instanceId", "instanceState"},
+			expectedWarning:  "providerSpec: removed [\"instanceId\" \"instanceState\"]: these fields describe the instance and must not be set in the providerSpec",
+			expectAnnotation: true,
+		},
+		{
+			name:         "with a polluted Azure providerSpec",
+			platformType: osconfigv1.AzurePlatformType,
+			providerSpec: map[string]interface{}{
+				"vmSize": "Standard_D4s_V3",
+				"vmId":   "00000000-0000-0000-0000-000000000000",
+			},
+			expectedRemoved:  []string{"vmId"},
+			expectedWarning:  "providerSpec: removed [\"vmId\"]: these fields describe the instance and must not be set in the providerSpec",
+			expectAnnotation: true,
+		},
+		{
+			name:         "with a polluted GCP providerSpec",
+			platformType: osconfigv1.GCPPlatformType,
+			providerSpec: map[string]interface{}{
+				"machineType": "n1-standard-4",
+				"selfLink":    "https://www.googleapis.com/compute/v1/projects/project/zones/zone/instances/instance",
+			},
+			expectedRemoved:  []string{"selfLink"},
+			expectedWarning:  "providerSpec: removed [\"selfLink\"]: these fields describe the instance and must not be set in the providerSpec",
+			expectAnnotation: true,
+		},
+		{
+			name:         "with a polluted vSphere providerSpec",
+			platformType: osconfigv1.VSpherePlatformType,
+			providerSpec: map[string]interface{}{
+				"template":     "template",
+				"instanceUuid": "00000000-0000-0000-0000-000000000000",
+			},
+			expectedRemoved:  []string{"instanceUuid"},
+			expectedWarning:  "providerSpec: removed [\"instanceUuid\"]: these fields describe the instance and must not be set in the providerSpec",
+			expectAnnotation: true,
+		},
+		{
+			name:         "with a clean AWS providerSpec",
+			platformType: osconfigv1.AWSPlatformType,
+			providerSpec: map[string]interface{}{
+				"instanceType": "m5.xlarge",
+			},
+			expectAnnotation: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			rawBytes, err := json.Marshal(tc.providerSpec)
+			g.Expect(err).ToNot(HaveOccurred())
+			m := &machinev1.Machine{}
+			m.Spec.ProviderSpec.Value = &kruntime.RawExtension{Raw: rawBytes}
+
+			platformStatus := &osconfigv1.PlatformStatus{
+				Type: tc.platformType,
+				GCP:  &osconfigv1.GCPPlatformStatus{ProjectID: "project"},
+			}
+			h := createMachineDefaulter(platformStatus, "clusterID")
+
+			ok, warnings, errs := h.webhookOperations(m, h.admissionConfig)
+			g.Expect(ok).To(BeTrue(), "unexpected errors: %v", errs)
+
+			fields := map[string]interface{}{}
+			g.Expect(json.Unmarshal(m.Spec.ProviderSpec.Value.Raw, &fields)).To(Succeed())
+			for _, key := range tc.expectedRemoved {
+				g.Expect(fields).ToNot(HaveKey(key))
+			}
+
+			if tc.expectAnnotation {
+				g.Expect(warnings).To(ContainElement(tc.expectedWarning))
+				g.Expect(m.Annotations).To(HaveKey(removedProviderSpecFieldsAnnotation))
+				g.Expect(m.Annotations[removedProviderSpecFieldsAnnotation]).To(Equal(strings.Join(tc.expectedRemoved, ",")))
+			} else {
+				g.Expect(m.Annotations).ToNot(HaveKey(removedProviderSpecFieldsAnnotation))
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package webhooks
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/openshift/machine-api-operator/pkg/metrics"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// defaultSecretsCacheTTL is how long a secret read from the API server, or its absence, is remembered.
+	defaultSecretsCacheTTL = 30 * time.Second
+
+	secretsCacheHit         = "hit"
+	secretsCacheNegativeHit = "negative_hit"
+	secretsCacheMiss        = "miss"
+)
+
+// blank assignment to verify that SecretsCache implements client.Reader
+var _ client.Reader = &SecretsCache{}
+
+// SecretsCache serves the secrets read by the validating webhooks, eg. the credentials secrets.
+// Secrets of the namespace watched by the manager are read from its shared informer-backed cache.
+// Secrets of other namespaces, and secrets the informers have not seen yet, are read from the API server
+// and remembered for the cache TTL, as is their absence, so that frequent admissions of the same
+// MachineSets do not query the API server each time.
+type SecretsCache struct {
+	// informer is the shared informer-backed cache of the manager.
+	informer client.Reader
+	// watchNamespace is the namespace cached by the informers, all namespaces when empty.
+	watchNamespace string
+	// live reads from the API server.
+	live client.Reader
+	ttl  time.Duration
+
+	lock    sync.Mutex
+	entries map[client.ObjectKey]secretsCacheEntry
+
+	// nowFunc is used to mock time in testing. It should be nil in production.
+	nowFunc func() time.Time
+}
+
+// secretsCacheEntry is a secret read from the API server. The secret is nil when it does not exist.
+type secretsCacheEntry struct {
+	secret  *corev1.Secret
+	fetched time.Time
+}
+
+// NewSecretsCache returns a SecretsCache reading the secrets of watchNamespace from the informer-backed
+// cache, and the other secrets from the live reader.
+func NewSecretsCache(informer client.Reader, watchNamespace string, live client.Reader) *SecretsCache {
+	return &SecretsCache{
+		informer:       informer,
+		watchNamespace: watchNamespace,
+		live:           live,
+		ttl:            defaultSecretsCacheTTL,
+		entries:        map[client.ObjectKey]secretsCacheEntry{},
+	}
+}
+
+// Get implements client.Reader. Objects other than secrets are read from the API server.
+func (c *SecretsCache) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	secret, ok := obj.(*corev1.Secret)
+	if !ok {
+		return c.live.Get(ctx, key, obj)
+	}
+
+	if c.informer != nil && (c.watchNamespace == "" || c.watchNamespace == key.Namespace) {
+		err := c.informer.Get(ctx, key, secret)
+		if err == nil {
+			// The informers are kept up to date by their watch, so a secret they serve is a refresh.
+			metrics.ObserveWebhookSecretsCacheLookup(secretsCacheHit)
+			secretVersions.observe(cacheResourceSecrets, secret)
+			return nil
+		}
+		// The secret may have been created since the informers were last updated.
+		if !apierrors.IsNotFound(err) {
+			klog.V(3).Infof("Failed to get secret %s from the informer cache: %v", key, err)
+		}
+	}
+
+	c.lock.Lock()
+	entry, ok := c.entries[key]
+	c.lock.Unlock()
+	if ok && c.now().Sub(entry.fetched) < c.ttl {
+		if entry.secret == nil {
+			metrics.ObserveWebhookSecretsCacheLookup(secretsCacheNegativeHit)
+			return apierrors.NewNotFound(corev1.Resource("secrets"), key.Name)
+		}
+		metrics.ObserveWebhookSecretsCacheLookup(secretsCacheHit)
+		entry.secret.DeepCopyInto(secret)
+		return nil
+	}
+
+	metrics.ObserveWebhookSecretsCacheLookup(secretsCacheMiss)
+	err := c.live.Get(ctx, key, secret)
+	switch {
+	case apierrors.IsNotFound(err):
+		c.store(key, nil)
+	case err == nil:
+		c.store(key, secret.DeepCopy())
+		secretVersions.observe(cacheResourceSecrets, secret)
+	default:
+		metrics.ObserveWebhookCacheRefreshFailure(cacheResourceSecrets)
+	}
+	return err
+}
+
+// List implements client.Reader. Lists are read from the API server.
+func (c *SecretsCache) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	return c.live.List(ctx, list, opts...)
+}
+
+// store remembers the secret read from the API server, dropping the expired entries.
+func (c *SecretsCache) store(key client.ObjectKey, secret *corev1.Secret) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := c.now()
+	for cached, entry := range c.entries {
+		if now.Sub(entry.fetched) >= c.ttl {
+			delete(c.entries, cached)
+		}
+	}
+	c.entries[key] = secretsCacheEntry{secret: secret, fetched: now}
+}
+
+// now is used to get the current time. If the cache nowFunc is not nil this will be used instead of time.Now().
+func (c *SecretsCache) now() time.Time {
+	if c.nowFunc != nil {
+		return c.nowFunc()
+	}
+	return time.Now()
+}
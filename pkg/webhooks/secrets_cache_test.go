@@ -0,0 +1,172 @@
+package webhooks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/openshift/machine-api-operator/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// countingReader counts the reads of the wrapped reader.
+type countingReader struct {
+	client.Reader
+	gets int
+}
+
+func (r *countingReader) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	r.gets++
+	return r.Reader.Get(ctx, key, obj)
+}
+
+// secretsCacheLookups returns the mapi_webhook_secrets_cache_lookups_total value for the result.
+func secretsCacheLookups(g *WithT, result string) float64 {
+	registry := prometheus.NewRegistry()
+	g.Expect(registry.Register(metrics.WebhookSecretsCacheLookupsTotal)).To(Succeed())
+
+	families, err := registry.Gather()
+	g.Expect(err).ToNot(HaveOccurred())
+
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "result" && label.GetValue() == result {
+					return metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestSecretsCache(t *testing.T) {
+	secret := func(namespace, name string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Data:       map[string][]byte{"key": []byte(name)},
+		}
+	}
+	watched := secret(defaultWebhookServiceNamespace, "watched")
+	created := secret(defaultWebhookServiceNamespace, "created")
+	other := secret("other", "other")
+
+	testCases := []struct {
+		name              string
+		key               client.ObjectKey
+		expectedData      string
+		expectedNotFound  bool
+		expectedLiveGets  int
+		expectedResults   []string
+		afterTTL          bool
+		expectedTTLResult string
+	}{
+		{
+			name:             "with a secret of the watched namespace",
+			key:              client.ObjectKeyFromObject(watched),
+			expectedData:     "watched",
+			expectedLiveGets: 0,
+			expectedResults:  []string{secretsCacheHit, secretsCacheHit},
+		},
+		{
+			name:             "with a secret the informers have not seen yet",
+			key:              client.ObjectKeyFromObject(created),
+			expectedData:     "created",
+			expectedLiveGets: 1,
+			expectedResults:  []string{secretsCacheMiss, secretsCacheHit},
+		},
+		{
+			name:             "with a secret of another namespace",
+			key:              client.ObjectKeyFromObject(other),
+			expectedData:     "other",
+			expectedLiveGets: 1,
+			expectedResults:  []string{secretsCacheMiss, secretsCacheHit},
+		},
+		{
+			name:             "with a missing secret",
+			key:              client.ObjectKey{Namespace: "other", Name: "missing"},
+			expectedNotFound: true,
+			expectedLiveGets: 1,
+			expectedResults:  []string{secretsCacheMiss, secretsCacheNegativeHit},
+		},
+		{
+			name:              "with a missing secret once the TTL expired",
+			key:               client.ObjectKey{Namespace: "other", Name: "missing"},
+			expectedNotFound:  true,
+			expectedLiveGets:  2,
+			expectedResults:   []string{secretsCacheMiss, secretsCacheNegativeHit},
+			afterTTL:          true,
+			expectedTTLResult: secretsCacheMiss,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			informer := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(watched.DeepCopy()).Build()
+			live := &countingReader{Reader: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(watched.DeepCopy(), created.DeepCopy(), other.DeepCopy()).Build()}
+
+			now := time.Now()
+			cache := NewSecretsCache(informer, defaultWebhookServiceNamespace, live)
+			cache.nowFunc = func() time.Time { return now }
+
+			results := append([]string{}, tc.expectedResults...)
+			if tc.afterTTL {
+				results = append(results, tc.expectedTTLResult)
+			}
+			for i, result := range results {
+				if i == len(tc.expectedResults) {
+					now = now.Add(defaultSecretsCacheTTL)
+				}
+
+				lookupsBefore := secretsCacheLookups(g, result)
+				got := &corev1.Secret{}
+				err := cache.Get(context.Background(), tc.key, got)
+				if tc.expectedNotFound {
+					g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "expected a NotFound error, got: %v", err)
+				} else {
+					g.Expect(err).ToNot(HaveOccurred())
+					g.Expect(string(got.Data["key"])).To(Equal(tc.expectedData))
+				}
+				g.Expect(secretsCacheLookups(g, result)).To(Equal(lookupsBefore+1), "lookup %d should be a %s", i, result)
+			}
+			g.Expect(live.gets).To(Equal(tc.expectedLiveGets))
+		})
+	}
+}
+
+func TestSecretsCacheRefreshMetrics(t *testing.T) {
+	g := NewWithT(t)
+
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "refreshed"}}
+	live := &failingReader{Reader: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secret).Build()}
+
+	now := time.Now()
+	cache := NewSecretsCache(nil, defaultWebhookServiceNamespace, live)
+	cache.nowFunc = func() time.Time { return now }
+
+	g.Expect(cache.Get(context.Background(), client.ObjectKeyFromObject(secret), &corev1.Secret{})).To(Succeed())
+	ageBefore, ok := webhookCacheMetric(g, "mapi_webhook_cache_resource_age_seconds", cacheResourceSecrets)
+	g.Expect(ok).To(BeTrue())
+
+	// A secret served from the cache is not a refresh.
+	time.Sleep(10 * time.Millisecond)
+	g.Expect(cache.Get(context.Background(), client.ObjectKeyFromObject(secret), &corev1.Secret{})).To(Succeed())
+	ageAfter, _ := webhookCacheMetric(g, "mapi_webhook_cache_resource_age_seconds", cacheResourceSecrets)
+	g.Expect(ageAfter).To(BeNumerically(">", ageBefore))
+
+	failuresBefore, _ := webhookCacheMetric(g, "mapi_webhook_cache_refresh_failures_total", cacheResourceSecrets)
+	live.fail = true
+	now = now.Add(defaultSecretsCacheTTL)
+	g.Expect(cache.Get(context.Background(), client.ObjectKeyFromObject(secret), &corev1.Secret{})).ToNot(Succeed())
+	failuresAfter, _ := webhookCacheMetric(g, "mapi_webhook_cache_refresh_failures_total", cacheResourceSecrets)
+	g.Expect(failuresAfter).To(Equal(failuresBefore + 1))
+}
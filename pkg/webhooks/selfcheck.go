@@ -0,0 +1,190 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// selfCheckProviderSpecs are the minimal providerSpecs submitted for each platform.
+// They rely on the defaulting webhook to fill in everything else.
+var selfCheckProviderSpecs = map[osconfigv1.PlatformType]kruntime.Object{
+	osconfigv1.AWSPlatformType: &machinev1.AWSMachineProviderConfig{
+		AMI: machinev1.AWSResourceReference{ID: pointer.StringPtr("ami-webhook-self-check")},
+	},
+	osconfigv1.AzurePlatformType: &machinev1.AzureMachineProviderSpec{
+		OSDisk: machinev1.OSDisk{DiskSizeGB: 128},
+	},
+	osconfigv1.GCPPlatformType: &machinev1.GCPMachineProviderSpec{
+		Region: "region",
+		Zone:   "region-a",
+	},
+	osconfigv1.VSpherePlatformType: &machinev1.VSphereMachineProviderSpec{
+		Template:  "template",
+		Workspace: &machinev1.Workspace{Server: "server", Datacenter: "datacenter"},
+		Network: machinev1.NetworkSpec{
+			Devices: []machinev1.NetworkDeviceSpec{{NetworkName: "network"}},
+		},
+	},
+}
+
+// SelfCheckResult is the outcome of a dry-run admission of a canned Machine.
+type SelfCheckResult struct {
+	Platform       osconfigv1.PlatformType `json:"platform"`
+	Admitted       bool                    `json:"admitted"`
+	DeniedReason   string                  `json:"deniedReason,omitempty"`
+	Warnings       []string                `json:"warnings,omitempty"`
+	Latency        string                  `json:"latency"`
+	CABundleExpiry *metav1.Time            `json:"caBundleExpiry,omitempty"`
+}
+
+// String returns a human readable summary of the result.
+func (r SelfCheckResult) String() string {
+	outcome := "admitted"
+	if !r.Admitted {
+		outcome = fmt.Sprintf("denied: %s", r.DeniedReason)
+	}
+
+	summary := fmt.Sprintf("platform %s: %s in %s", r.Platform, outcome, r.Latency)
+	if r.CABundleExpiry != nil {
+		summary += fmt.Sprintf("\n  webhook CA bundle expires at %s", r.CABundleExpiry.UTC().Format(time.RFC3339))
+	}
+	for _, warning := range r.Warnings {
+		summary += fmt.Sprintf("\n  warning: %s", warning)
+	}
+	return summary
+}
+
+// JSON returns the result encoded as indented JSON.
+func (r SelfCheckResult) JSON() (string, error) {
+	out, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// warningRecorder collects the warnings returned by the API server.
+type warningRecorder struct {
+	lock     sync.Mutex
+	warnings []string
+}
+
+// HandleWarningHeader implements rest.WarningHandler.
+func (w *warningRecorder) HandleWarningHeader(code int, agent string, message string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.warnings = append(w.warnings, message)
+}
+
+// RunSelfCheck submits a canned Machine for the platform as a server-side dry-run create
+// and reports how the machine webhooks handled it.
+// No Machine is persisted, so this is safe to run against a live cluster.
+func RunSelfCheck(ctx context.Context, cfg *rest.Config, namespace string, platform osconfigv1.PlatformType) (*SelfCheckResult, error) {
+	recorder := &warningRecorder{}
+	cfg = rest.CopyConfig(cfg)
+	cfg.WarningHandler = recorder
+
+	scheme := kruntime.NewScheme()
+	if err := machinev1.Install(scheme); err != nil {
+		return nil, err
+	}
+	if err := admissionregistrationv1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, err
+	}
+
+	m := &machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "webhook-self-check-",
+			Namespace:    namespace,
+		},
+	}
+	if providerSpec, ok := selfCheckProviderSpecs[platform]; ok {
+		m.Spec.ProviderSpec.Value = &kruntime.RawExtension{Object: providerSpec}
+	}
+
+	result := &SelfCheckResult{Platform: platform}
+
+	start := time.Now()
+	err = c.Create(ctx, m, client.DryRunAll)
+	result.Latency = time.Since(start).String()
+
+	switch {
+	case err == nil:
+		result.Admitted = true
+	case apierrors.IsForbidden(err) || apierrors.IsInvalid(err):
+		result.DeniedReason = err.Error()
+	default:
+		return nil, fmt.Errorf("failed to submit dry-run Machine: %w", err)
+	}
+
+	recorder.lock.Lock()
+	result.Warnings = recorder.warnings
+	recorder.lock.Unlock()
+
+	expiry, err := webhookCABundleExpiry(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	result.CABundleExpiry = expiry
+
+	return result, nil
+}
+
+// webhookCABundleExpiry returns the earliest expiry of the certificates in the CA bundle of the
+// machine validating webhook, or nil if no CA bundle has been injected yet.
+func webhookCABundleExpiry(ctx context.Context, c client.Client) (*metav1.Time, error) {
+	config := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+	if err := c.Get(ctx, client.ObjectKey{Name: defaultWebhookConfigurationName}, config); err != nil {
+		return nil, fmt.Errorf("failed to get validating webhook configuration: %w", err)
+	}
+
+	for _, webhook := range config.Webhooks {
+		if webhook.Name != MachineValidatingWebhook().Name || len(webhook.ClientConfig.CABundle) == 0 {
+			continue
+		}
+
+		var expiry *metav1.Time
+		remaining := webhook.ClientConfig.CABundle
+		for {
+			var block *pem.Block
+			block, remaining = pem.Decode(remaining)
+			if block == nil {
+				break
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse webhook CA bundle: %w", err)
+			}
+			if expiry == nil || cert.NotAfter.Before(expiry.Time) {
+				expiry = &metav1.Time{Time: cert.NotAfter}
+			}
+		}
+		if expiry == nil {
+			return nil, errors.New("webhook CA bundle contains no certificates")
+		}
+		return expiry, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,86 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+func TestRunSelfCheck(t *testing.T) {
+	g := NewWithT(t)
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "webhook-self-check-test",
+		},
+	}
+	g.Expect(c.Create(ctx, namespace)).To(Succeed())
+	defer func() {
+		g.Expect(c.Delete(ctx, namespace)).To(Succeed())
+	}()
+
+	for _, platformType := range []osconfigv1.PlatformType{osconfigv1.AWSPlatformType, osconfigv1.VSpherePlatformType} {
+		t.Run(string(platformType), func(t *testing.T) {
+			gs := NewWithT(t)
+
+			mgr, err := manager.New(cfg, manager.Options{
+				MetricsBindAddress: "0",
+				Port:               testEnv.WebhookInstallOptions.LocalServingPort,
+				CertDir:            testEnv.WebhookInstallOptions.LocalServingCertDir,
+			})
+			gs.Expect(err).ToNot(HaveOccurred())
+
+			platformStatus := &osconfigv1.PlatformStatus{
+				Type: platformType,
+				AWS: &osconfigv1.AWSPlatformStatus{
+					Region: "region",
+				},
+			}
+			infra := plainInfra.DeepCopy()
+			infra.Status.InfrastructureName = "self-check-cluster"
+			infra.Status.PlatformStatus = platformStatus
+
+			machineDefaulter := createMachineDefaulter(platformStatus, infra.Status.InfrastructureName)
+			machineValidator := createMachineValidator(infra, c, plainDNS)
+			mgr.GetWebhookServer().Register(DefaultMachineMutatingHookPath, &webhook.Admission{Handler: machineDefaulter})
+			mgr.GetWebhookServer().Register(DefaultMachineValidatingHookPath, &webhook.Admission{Handler: machineValidator})
+
+			mgrCtx, cancel := context.WithCancel(context.Background())
+			stopped := make(chan struct{})
+			go func() {
+				gs.Expect(mgr.Start(mgrCtx)).To(Succeed())
+				close(stopped)
+			}()
+			defer func() {
+				cancel()
+				<-stopped
+			}()
+
+			gs.Eventually(func() (bool, error) {
+				resp, err := insecureHTTPClient.Get(fmt.Sprintf("https://127.0.0.1:%d", testEnv.WebhookInstallOptions.LocalServingPort))
+				if err != nil {
+					return false, err
+				}
+				return resp.StatusCode == 404, nil
+			}).Should(BeTrue())
+
+			result, err := RunSelfCheck(ctx, cfg, namespace.Name, platformType)
+			gs.Expect(err).ToNot(HaveOccurred())
+			gs.Expect(result.Admitted).To(BeTrue(), "denied: %s", result.DeniedReason)
+			gs.Expect(result.CABundleExpiry).ToNot(BeNil())
+
+			machines := &machinev1.MachineList{}
+			gs.Expect(c.List(ctx, machines, client.InNamespace(namespace.Name))).To(Succeed())
+			gs.Expect(machines.Items).To(BeEmpty(), "the dry-run create should not persist a Machine")
+		})
+	}
+}
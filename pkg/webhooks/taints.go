@@ -0,0 +1,62 @@
+package webhooks
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// supportedTaintEffects are the taint effects accepted on Nodes.
+var supportedTaintEffects = sets.NewString(
+	string(corev1.TaintEffectNoSchedule),
+	string(corev1.TaintEffectPreferNoSchedule),
+	string(corev1.TaintEffectNoExecute),
+)
+
+// validateTaints validates taints the way the API server validates Node taints.
+// Otherwise invalid taints are only rejected once the Node exists and the taints are applied to it.
+func validateTaints(taints []corev1.Taint, path *field.Path) []error {
+	var errs []error
+	seen := sets.NewString()
+	for i, taint := range taints {
+		taintPath := path.Index(i)
+
+		for _, msg := range validation.IsQualifiedName(taint.Key) {
+			errs = append(errs, field.Invalid(taintPath.Child("key"), taint.Key, msg))
+		}
+		for _, msg := range validation.IsValidLabelValue(taint.Value) {
+			errs = append(errs, field.Invalid(taintPath.Child("value"), taint.Value, msg))
+		}
+
+		switch {
+		case taint.Effect == "":
+			errs = append(errs, field.Required(taintPath.Child("effect"), "expected the taint effect to be populated"))
+		case !supportedTaintEffects.Has(string(taint.Effect)):
+			errs = append(errs, field.NotSupported(taintPath.Child("effect"), taint.Effect, supportedTaintEffects.List()))
+		}
+
+		// Taints are unique by key and effect.
+		keyEffect := fmt.Sprintf("%s:%s", taint.Key, taint.Effect)
+		if seen.Has(keyEffect) {
+			errs = append(errs, field.Duplicate(taintPath, keyEffect))
+		}
+		seen.Insert(keyEffect)
+	}
+	return errs
+}
+
+// validateTemplateNoExecuteTaints warns about NoExecute taints in a machine template.
+// They are applied to every new Node once it has joined the cluster, evicting the pods already
+// scheduled onto it which do not tolerate the taint, e.g. DaemonSet pods.
+func validateTemplateNoExecuteTaints(taints []corev1.Taint, path *field.Path) []string {
+	var warnings []string
+	for i, taint := range taints {
+		if taint.Effect == corev1.TaintEffectNoExecute {
+			warnings = append(warnings, fmt.Sprintf("%s: NoExecute taint %q evicts the pods without a matching toleration from every new Node as soon as it joins the cluster, use NoSchedule to only prevent new pods from being scheduled", path.Index(i), taint.Key))
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,65 @@
+package webhooks
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidateTaints(t *testing.T) {
+	testCases := []struct {
+		testCase       string
+		taints         []corev1.Taint
+		expectedErrors []string
+	}{
+		{
+			testCase: "with valid taints",
+			taints: []corev1.Taint{
+				{Key: "node.example.com/dedicated", Value: "infra", Effect: corev1.TaintEffectNoSchedule},
+				{Key: "gpu", Effect: corev1.TaintEffectPreferNoSchedule},
+				{Key: "maintenance", Effect: corev1.TaintEffectNoExecute},
+			},
+		},
+		{
+			testCase: "with an invalid key",
+			taints:   []corev1.Taint{{Key: "dedicated node", Effect: corev1.TaintEffectNoSchedule}},
+			expectedErrors: []string{
+				"spec.taints[0].key: Invalid value: \"dedicated node\": name part must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character (e.g. 'MyName',  or 'my.name',  or '123-abc', regex used for validation is '([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]')",
+			},
+		},
+		{
+			testCase: "with an invalid value",
+			taints:   []corev1.Taint{{Key: "dedicated", Value: "infra/edge", Effect: corev1.TaintEffectNoSchedule}},
+			expectedErrors: []string{
+				"spec.taints[0].value: Invalid value: \"infra/edge\": a valid label must be an empty string or consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character (e.g. 'MyValue',  or 'my_value',  or '12345', regex used for validation is '(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])?')",
+			},
+		},
+		{
+			testCase:       "without an effect",
+			taints:         []corev1.Taint{{Key: "dedicated"}},
+			expectedErrors: []string{"spec.taints[0].effect: Required value: expected the taint effect to be populated"},
+		},
+		{
+			testCase: "with a duplicate taint",
+			taints: []corev1.Taint{
+				{Key: "dedicated", Effect: corev1.TaintEffectNoExecute},
+				{Key: "dedicated", Effect: corev1.TaintEffectNoExecute},
+			},
+			expectedErrors: []string{"spec.taints[1]: Duplicate value: \"dedicated:NoExecute\""},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			g := NewWithT(t)
+
+			var errs []string
+			for _, err := range validateTaints(tc.taints, field.NewPath("spec", "taints")) {
+				errs = append(errs, err.Error())
+			}
+			g.Expect(errs).To(Equal(tc.expectedErrors))
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// DefaultTLSMinVersion is the minimum TLS version accepted by the webhook server by default.
+const DefaultTLSMinVersion = "VersionTLS12"
+
+// DefaultTLSCipherSuites are the cipher suites accepted by the webhook server by default.
+// They match the Kubernetes intermediate TLS profile. TLS 1.3 cipher suites are not
+// configurable and are always enabled.
+var DefaultTLSCipherSuites = []string{
+	"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+	"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+	"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+	"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+	"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256",
+	"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
+}
+
+var tlsVersions = map[string]uint16{
+	"VersionTLS10": tls.VersionTLS10,
+	"VersionTLS11": tls.VersionTLS11,
+	"VersionTLS12": tls.VersionTLS12,
+	"VersionTLS13": tls.VersionTLS13,
+}
+
+// ParseTLSVersion converts a TLS version name, eg. VersionTLS12, to its tls.Config value.
+func ParseTLSVersion(name string) (uint16, error) {
+	version, ok := tlsVersions[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown TLS version %q: expected one of VersionTLS10, VersionTLS11, VersionTLS12, VersionTLS13", name)
+	}
+	return version, nil
+}
+
+// ParseTLSCipherSuites converts IANA cipher suite names to their tls.Config values.
+func ParseTLSCipherSuites(names []string) ([]uint16, error) {
+	known := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	for _, suite := range tls.InsecureCipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	var ids []uint16
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown TLS cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// TLSServer serves the webhooks registered with the embedded controller-runtime webhook server.
+// The controller-runtime server only allows the minimum TLS version to be configured,
+// TLSServer additionally restricts the cipher suites.
+type TLSServer struct {
+	*webhook.Server
+
+	// MinVersion is the minimum TLS version accepted.
+	MinVersion uint16
+	// CipherSuites are the cipher suites accepted for TLS 1.2 and below.
+	CipherSuites []uint16
+}
+
+// NewTLSServer returns a TLSServer with the default TLS configuration.
+func NewTLSServer(server *webhook.Server) (*TLSServer, error) {
+	minVersion, err := ParseTLSVersion(DefaultTLSMinVersion)
+	if err != nil {
+		return nil, err
+	}
+	cipherSuites, err := ParseTLSCipherSuites(DefaultTLSCipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TLSServer{
+		Server:       server,
+		MinVersion:   minVersion,
+		CipherSuites: cipherSuites,
+	}, nil
+}
+
+// Start runs the server until the context is done.
+func (s *TLSServer) Start(ctx context.Context) error {
+	// Registering a handler defaults the embedded server, ensure the mux exists
+	// even when nothing was registered.
+	if s.WebhookMux == nil {
+		s.WebhookMux = http.NewServeMux()
+	}
+
+	certName, keyName := s.CertName, s.KeyName
+	if certName == "" {
+		certName = "tls.crt"
+	}
+	if keyName == "" {
+		keyName = "tls.key"
+	}
+
+	certWatcher, err := certwatcher.New(filepath.Join(s.CertDir, certName), filepath.Join(s.CertDir, keyName))
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		if err := certWatcher.Start(ctx); err != nil {
+			klog.Errorf("Webhook certificate watcher error: %v", err)
+		}
+	}()
+
+	cfg := &tls.Config{
+		NextProtos:     []string{"h2"},
+		GetCertificate: certWatcher.GetCertificate,
+		MinVersion:     s.MinVersion,
+		CipherSuites:   s.CipherSuites,
+	}
+
+	port := s.Port
+	if port <= 0 {
+		port = webhook.DefaultPort
+	}
+
+	listener, err := tls.Listen("tcp", net.JoinHostPort(s.Host, strconv.Itoa(port)), cfg)
+	if err != nil {
+		return err
+	}
+
+	klog.Infof("Serving webhooks on %s", listener.Addr())
+
+	srv := &http.Server{
+		Handler: s.WebhookMux,
+	}
+
+	idleConnsClosed := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		klog.Info("Shutting down webhook server")
+
+		if err := srv.Shutdown(context.Background()); err != nil {
+			klog.Errorf("Error shutting down the webhook server: %v", err)
+		}
+		close(idleConnsClosed)
+	}()
+
+	if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+
+	<-idleConnsClosed
+	return nil
+}
@@ -0,0 +1,165 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// writeServingCert writes a self-signed serving certificate for 127.0.0.1 to dir.
+func writeServingCert(g *WithT, dir string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	g.Expect(err).ToNot(HaveOccurred())
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(ioutil.WriteFile(filepath.Join(dir, "tls.crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0600)).To(Succeed())
+	g.Expect(ioutil.WriteFile(filepath.Join(dir, "tls.key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)).To(Succeed())
+}
+
+// freePort returns a port which is free to listen on.
+func freePort(g *WithT) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	g.Expect(err).ToNot(HaveOccurred())
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestTLSServerHandshake(t *testing.T) {
+	g := NewWithT(t)
+
+	certDir, err := ioutil.TempDir("", "webhook-tls")
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(certDir)
+	writeServingCert(g, certDir)
+
+	port := freePort(g)
+	server, err := NewTLSServer(&webhook.Server{
+		Host:    "127.0.0.1",
+		Port:    port,
+		CertDir: certDir,
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		g.Expect(server.Start(ctx)).To(Succeed())
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	address := net.JoinHostPort("127.0.0.1", fmt.Sprint(port))
+	handshake := func(version uint16) error {
+		conn, err := tls.Dial("tcp", address, &tls.Config{
+			InsecureSkipVerify: true,
+			MinVersion:         version,
+			MaxVersion:         version,
+		})
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	g.Eventually(func() error {
+		return handshake(tls.VersionTLS12)
+	}).Should(Succeed())
+
+	testCases := []struct {
+		name        string
+		version     uint16
+		expectError bool
+	}{
+		{
+			name:        "TLS 1.0 is rejected",
+			version:     tls.VersionTLS10,
+			expectError: true,
+		},
+		{
+			name:        "TLS 1.1 is rejected",
+			version:     tls.VersionTLS11,
+			expectError: true,
+		},
+		{
+			name:    "TLS 1.2 is accepted",
+			version: tls.VersionTLS12,
+		},
+		{
+			name:    "TLS 1.3 is accepted",
+			version: tls.VersionTLS13,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gs := NewWithT(t)
+
+			err := handshake(tc.version)
+			if tc.expectError {
+				gs.Expect(err).To(HaveOccurred())
+			} else {
+				gs.Expect(err).ToNot(HaveOccurred())
+			}
+		})
+	}
+
+	t.Run("a cipher suite outside of the defaults is rejected", func(t *testing.T) {
+		gs := NewWithT(t)
+
+		conn, err := tls.Dial("tcp", address, &tls.Config{
+			InsecureSkipVerify: true,
+			MaxVersion:         tls.VersionTLS12,
+			CipherSuites:       []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA},
+		})
+		if err == nil {
+			conn.Close()
+		}
+		gs.Expect(err).To(HaveOccurred())
+	})
+}
+
+func TestParseTLSCipherSuites(t *testing.T) {
+	g := NewWithT(t)
+
+	ids, err := ParseTLSCipherSuites(DefaultTLSCipherSuites)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ids).To(HaveLen(len(DefaultTLSCipherSuites)))
+
+	_, err = ParseTLSCipherSuites([]string{"TLS_NOT_A_CIPHER"})
+	g.Expect(err).To(MatchError("unknown TLS cipher suite \"TLS_NOT_A_CIPHER\""))
+
+	_, err = ParseTLSVersion("VersionTLS9")
+	g.Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,162 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/klog/v2"
+)
+
+// outboundRequestTimeout bounds the outbound HTTPS requests made by the webhooks.
+const outboundRequestTimeout = 30 * time.Second
+
+// TrustedCABundle trusts the CA certificates of a PEM bundle, in addition to the system roots,
+// for the outbound HTTPS requests made by the webhooks. This is required on clusters with a custom PKI.
+// The bundle is typically the cluster trusted CA bundle ConfigMap mounted into the webhook,
+// it is reloaded whenever the file changes.
+type TrustedCABundle struct {
+	lock      sync.RWMutex
+	transport *http.Transport
+
+	path    string
+	watcher *fsnotify.Watcher
+}
+
+// NewTrustedCABundle returns a TrustedCABundle trusting the CA certificates in the file at path.
+// Only the system roots are trusted when path is empty.
+func NewTrustedCABundle(path string) (*TrustedCABundle, error) {
+	b := &TrustedCABundle{path: path}
+	if err := b.ReadBundle(); err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return b, nil
+	}
+
+	var err error
+	if b.watcher, err = fsnotify.NewWatcher(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// ReadBundle reads the CA bundle from disk. Requests started afterwards trust the new bundle.
+func (b *TrustedCABundle) ReadBundle() error {
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		klog.Warningf("Unable to load the system CA certificates: %v", err)
+		roots = x509.NewCertPool()
+	}
+
+	if b.path != "" {
+		bundle, err := ioutil.ReadFile(b.path)
+		if err != nil {
+			return fmt.Errorf("unable to read the trusted CA bundle: %w", err)
+		}
+		if !roots.AppendCertsFromPEM(bundle) {
+			return fmt.Errorf("no CA certificates found in the trusted CA bundle %s", b.path)
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		RootCAs:    roots,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	b.lock.Lock()
+	previous := b.transport
+	b.transport = transport
+	b.lock.Unlock()
+
+	// Connections of the previous transport were verified against the previous bundle.
+	if previous != nil {
+		previous.CloseIdleConnections()
+		klog.Infof("Updated the trusted CA bundle from %s", b.path)
+	}
+	return nil
+}
+
+// RoundTrip implements http.RoundTripper with the currently trusted CA certificates.
+func (b *TrustedCABundle) RoundTrip(req *http.Request) (*http.Response, error) {
+	b.lock.RLock()
+	transport := b.transport
+	b.lock.RUnlock()
+
+	return transport.RoundTrip(req)
+}
+
+// HTTPClient returns a client trusting the CA bundle, for the outbound HTTPS requests made by the webhooks.
+func (b *TrustedCABundle) HTTPClient() *http.Client {
+	return &http.Client{
+		Transport: b,
+		Timeout:   outboundRequestTimeout,
+	}
+}
+
+// NeedLeaderElection implements the manager.LeaderElectionRunnable interface,
+// the bundle is needed by the webhooks of all replicas.
+func (b *TrustedCABundle) NeedLeaderElection() bool {
+	return false
+}
+
+// Start watches the CA bundle for changes until the context is done.
+func (b *TrustedCABundle) Start(ctx context.Context) error {
+	if b.watcher == nil {
+		<-ctx.Done()
+		return nil
+	}
+
+	if err := b.watcher.Add(b.path); err != nil {
+		return err
+	}
+
+	klog.Infof("Watching the trusted CA bundle %s", b.path)
+	go b.watch()
+
+	<-ctx.Done()
+	return b.watcher.Close()
+}
+
+// watch reloads the CA bundle on changes until the watcher is closed.
+func (b *TrustedCABundle) watch() {
+	for {
+		select {
+		case event, ok := <-b.watcher.Events:
+			if !ok {
+				return
+			}
+			b.handleEvent(event)
+
+		case err, ok := <-b.watcher.Errors:
+			if !ok {
+				return
+			}
+			klog.Errorf("Trusted CA bundle watch error: %v", err)
+		}
+	}
+}
+
+func (b *TrustedCABundle) handleEvent(event fsnotify.Event) {
+	if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove) == 0 {
+		return
+	}
+
+	// Mounted ConfigMaps are updated by replacing the file, which removes the watch.
+	if event.Op&fsnotify.Remove != 0 {
+		if err := b.watcher.Add(event.Name); err != nil {
+			klog.Errorf("Unable to watch the trusted CA bundle %s again: %v", event.Name, err)
+		}
+	}
+
+	if err := b.ReadBundle(); err != nil {
+		klog.Errorf("Unable to reload the trusted CA bundle, using the last known value: %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// testCA is a custom CA issuing serving certificates for 127.0.0.1.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(g *WithT, name string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	g.Expect(err).ToNot(HaveOccurred())
+	cert, err := x509.ParseCertificate(der)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// servingCert returns a serving certificate for 127.0.0.1 signed by the CA.
+func (ca *testCA) servingCert(g *WithT) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// newTestTLSServer returns a started HTTPS server serving a certificate signed by ca.
+func newTestTLSServer(g *WithT, ca *testCA) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{ca.servingCert(g)}}
+	server.StartTLS()
+	return server
+}
+
+func TestTrustedCABundle(t *testing.T) {
+	g := NewWithT(t)
+
+	dir, err := ioutil.TempDir("", "trusted-ca")
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(dir)
+	bundlePath := filepath.Join(dir, "tls-ca-bundle.pem")
+
+	ca := newTestCA(g, "custom-ca")
+	server := newTestTLSServer(g, ca)
+	defer server.Close()
+
+	// Without the bundle, the custom CA is not trusted.
+	systemRoots, err := NewTrustedCABundle("")
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = systemRoots.HTTPClient().Get(server.URL)
+	g.Expect(err).To(MatchError(ContainSubstring("certificate signed by unknown authority")))
+
+	_, err = NewTrustedCABundle(bundlePath)
+	g.Expect(err).To(MatchError(ContainSubstring("unable to read the trusted CA bundle")))
+
+	g.Expect(ioutil.WriteFile(bundlePath, []byte("not a certificate"), 0600)).To(Succeed())
+	_, err = NewTrustedCABundle(bundlePath)
+	g.Expect(err).To(MatchError(ContainSubstring("no CA certificates found")))
+
+	g.Expect(ioutil.WriteFile(bundlePath, ca.pem, 0600)).To(Succeed())
+	bundle, err := NewTrustedCABundle(bundlePath)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// The client is shared with the webhooks via the admissionConfig.
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	h := createMachineValidator(plainInfra, c, plainDNS)
+	h.SetHTTPClient(bundle.HTTPClient())
+	httpClient := h.currentConfig().httpClient
+	g.Expect(httpClient).ToNot(BeNil())
+
+	resp, err := httpClient.Get(server.URL)
+	g.Expect(err).ToNot(HaveOccurred())
+	resp.Body.Close()
+	g.Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		g.Expect(bundle.Start(ctx)).To(Succeed())
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	// Once the CA is rotated, servers signed by the new CA are trusted without a restart.
+	rotatedCA := newTestCA(g, "rotated-ca")
+	rotatedServer := newTestTLSServer(g, rotatedCA)
+	defer rotatedServer.Close()
+
+	// Give the watcher time to start before rotating.
+	g.Eventually(func() error {
+		if err := ioutil.WriteFile(bundlePath, rotatedCA.pem, 0600); err != nil {
+			return err
+		}
+		resp, err := httpClient.Get(rotatedServer.URL)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}, 5*time.Second).Should(Succeed())
+
+	_, err = httpClient.Get(server.URL)
+	g.Expect(err).To(MatchError(ContainSubstring("certificate signed by unknown authority")), "the previous CA should no longer be trusted")
+}
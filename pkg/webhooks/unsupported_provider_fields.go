@@ -0,0 +1,173 @@
+package webhooks
+
+import (
+	"sort"
+
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/yaml"
+)
+
+// eachItem is the element of an unsupported field path standing for every item of a list.
+const eachItem = "*"
+
+// unsupportedProviderSpecField is a providerSpec field which the providerSpec type of its platform
+// does not model. The defaulting webhook re-encodes the providerSpec, dropping the field.
+type unsupportedProviderSpecField struct {
+	path   []string
+	reason string
+}
+
+// unsupportedProviderSpecFields are the unsupported providerSpec fields, per platform.
+var unsupportedProviderSpecFields = map[osconfigv1.PlatformType][]unsupportedProviderSpecField{
+	osconfigv1.AWSPlatformType: {
+		// Instances keep the default metadata hop limit of 1, so that pods without host networking cannot
+		// reach the instance metadata service whatever hop limit is requested.
+		{
+			path:   []string{"metadataServiceOptions"},
+			reason: "instance metadata options, such as the metadata hop limit, are not supported by this version of the AWS providerSpec and would be ignored",
+		},
+		// Burstable instances are launched with the default credit option of their family, standard for T2
+		// and unlimited for later families, which sets what bursting above the baseline costs.
+		{
+			path:   []string{"cpuCredits"},
+			reason: "the CPU credit option of burstable instances is not supported by this version of the AWS providerSpec and would be ignored: the instance would use the default credit option of its family",
+		},
+		// Instances are launched with all the cores and threads of their instance type.
+		{
+			path:   []string{"cpuOptions"},
+			reason: "CPU options, such as the core count and threads per core, are not supported by this version of the AWS providerSpec and would be ignored",
+		},
+	},
+	osconfigv1.GCPPlatformType: {
+		// Network interfaces are created with the VIRTIO_NET type. GVNIC would need a boot image with the
+		// gVNIC driver, which cannot be checked here.
+		{
+			path:   []string{"networkInterfaces", eachItem, "nicType"},
+			reason: "the network interface type, such as GVNIC, is not supported by this version of the GCP providerSpec and would be ignored: the instance would use the default VIRTIO_NET interface",
+		},
+	},
+	osconfigv1.VSpherePlatformType: {
+		// The cloned virtual machine is not customized with static IPs, so its network devices are
+		// configured by DHCP whatever addresses, or pools of addresses, they ask for.
+		{
+			path:   []string{"network", "devices", eachItem, "addressesFromPools"},
+			reason: "IP address pools are not supported by this version of the vSphere providerSpec and would be ignored: the device would be configured by DHCP",
+		},
+		{
+			path:   []string{"network", "devices", eachItem, "gateway"},
+			reason: "static IP addressing is not supported by this version of the vSphere providerSpec and the gateway would be ignored: the device would be configured by DHCP",
+		},
+		{
+			path:   []string{"network", "devices", eachItem, "ipAddrs"},
+			reason: "static IP addressing is not supported by this version of the vSphere providerSpec and the addresses would be ignored: the device would be configured by DHCP",
+		},
+		{
+			path:   []string{"network", "devices", eachItem, "nameservers"},
+			reason: "static IP addressing is not supported by this version of the vSphere providerSpec and the nameservers would be ignored: the device would be configured by DHCP",
+		},
+	},
+}
+
+// unsupportedFieldValue is the value of an unsupported field set in a providerSpec.
+type unsupportedFieldValue struct {
+	path   *field.Path
+	reason string
+	value  interface{}
+}
+
+// denyUnsupportedProviderSpecFields wraps a defaulting operation so that the unsupported providerSpec fields
+// of the platform are denied before the defaulting drops them. Machines are only defaulted on creation.
+func denyUnsupportedProviderSpecFields(platform osconfigv1.PlatformType, defaulter machineAdmissionFn) machineAdmissionFn {
+	return func(m *machinev1.Machine, config *admissionConfig) (bool, []string, utilerrors.Aggregate) {
+		if errs := validateUnsupportedProviderSpecFields(m, nil, platform); len(errs) > 0 {
+			return false, []string{}, utilerrors.NewAggregate(errs)
+		}
+		return defaulter(m, config)
+	}
+}
+
+// validateUnsupportedProviderSpecFields denies the unsupported providerSpec fields set on a new Machine, and
+// those added or changed on an existing one. Machines which already carry unsupported fields, eg. as they
+// were created before the fields were denied, can still be updated and deleted.
+func validateUnsupportedProviderSpecFields(m, oldM *machinev1.Machine, clusterPlatform osconfigv1.PlatformType) []error {
+	if isDeleting(m) || m.Spec.ProviderSpec.Value == nil {
+		return nil
+	}
+	if oldM != nil && (isDeleting(oldM) || !providerSpecChanged(oldM.Spec.ProviderSpec.Value, m.Spec.ProviderSpec.Value)) {
+		return nil
+	}
+
+	platform := providerSpecPlatform(m)
+	if platform == "" {
+		platform = clusterPlatform
+	}
+	unsupported, ok := unsupportedProviderSpecFields[platform]
+	if !ok {
+		return nil
+	}
+
+	values, err := unsupportedProviderSpecFieldValues(m.Spec.ProviderSpec.Value.Raw, unsupported)
+	if err != nil {
+		// The providerSpec is validated by the platform rules.
+		return nil
+	}
+	var oldValues map[string]unsupportedFieldValue
+	if oldM != nil && oldM.Spec.ProviderSpec.Value != nil {
+		oldValues, _ = unsupportedProviderSpecFieldValues(oldM.Spec.ProviderSpec.Value.Raw, unsupported)
+	}
+
+	paths := make([]string, 0, len(values))
+	for path := range values {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var errs []error
+	for _, path := range paths {
+		value := values[path]
+		if oldValue, ok := oldValues[path]; ok && equality.Semantic.DeepEqual(oldValue.value, value.value) {
+			continue
+		}
+		errs = append(errs, field.Forbidden(value.path, value.reason))
+	}
+	return errs
+}
+
+// unsupportedProviderSpecFieldValues returns the values of the unsupported fields set in the raw providerSpec,
+// by field path.
+func unsupportedProviderSpecFieldValues(raw []byte, unsupported []unsupportedProviderSpecField) (map[string]unsupportedFieldValue, error) {
+	fields := map[string]interface{}{}
+	if err := yaml.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+
+	values := map[string]unsupportedFieldValue{}
+	for _, f := range unsupported {
+		collectUnsupportedFieldValues(fields, f.path, field.NewPath("providerSpec"), f.reason, values)
+	}
+	return values, nil
+}
+
+func collectUnsupportedFieldValues(obj interface{}, path []string, fldPath *field.Path, reason string, values map[string]unsupportedFieldValue) {
+	if len(path) == 0 {
+		values[fldPath.String()] = unsupportedFieldValue{path: fldPath, reason: reason, value: obj}
+		return
+	}
+
+	if path[0] == eachItem {
+		items, _ := obj.([]interface{})
+		for i, item := range items {
+			collectUnsupportedFieldValues(item, path[1:], fldPath.Index(i), reason, values)
+		}
+		return
+	}
+
+	fields, _ := obj.(map[string]interface{})
+	if value, ok := fields[path[0]]; ok {
+		collectUnsupportedFieldValues(value, path[1:], fldPath.Child(path[0]), reason, values)
+	}
+}
@@ -0,0 +1,292 @@
+package webhooks
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	osconfigv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kruntime "k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidateUnsupportedProviderSpecFields(t *testing.T) {
+	hopLimit := func(limit string) string {
+		return `{"instanceType":"m5.large","metadataServiceOptions":{"httpPutResponseHopLimit":` + limit + `}}`
+	}
+
+	testCases := []struct {
+		testCase        string
+		clusterPlatform osconfigv1.PlatformType
+		oldProviderSpec string
+		providerSpec    string
+		deleting        bool
+		expectedFields  []string
+	}{
+		{
+			testCase:        "without unsupported fields",
+			clusterPlatform: osconfigv1.AWSPlatformType,
+			providerSpec:    `{"instanceType":"m5.large"}`,
+		},
+		{
+			testCase:        "with a hop limit of 0",
+			clusterPlatform: osconfigv1.AWSPlatformType,
+			providerSpec:    hopLimit("0"),
+			expectedFields:  []string{"providerSpec.metadataServiceOptions"},
+		},
+		{
+			testCase:        "with a hop limit of 1",
+			clusterPlatform: osconfigv1.AWSPlatformType,
+			providerSpec:    hopLimit("1"),
+			expectedFields:  []string{"providerSpec.metadataServiceOptions"},
+		},
+		{
+			testCase:        "with a hop limit of 2",
+			clusterPlatform: osconfigv1.AWSPlatformType,
+			providerSpec:    hopLimit("2"),
+			expectedFields:  []string{"providerSpec.metadataServiceOptions"},
+		},
+		{
+			testCase:        "with a hop limit of 3",
+			clusterPlatform: osconfigv1.AWSPlatformType,
+			providerSpec:    hopLimit("3"),
+			expectedFields:  []string{"providerSpec.metadataServiceOptions"},
+		},
+		{
+			testCase:        "with a hop limit of 64",
+			clusterPlatform: osconfigv1.AWSPlatformType,
+			providerSpec:    hopLimit("64"),
+			expectedFields:  []string{"providerSpec.metadataServiceOptions"},
+		},
+		{
+			testCase:        "with a hop limit of 65",
+			clusterPlatform: osconfigv1.AWSPlatformType,
+			providerSpec:    hopLimit("65"),
+			expectedFields:  []string{"providerSpec.metadataServiceOptions"},
+		},
+		{
+			testCase:        "with unlimited CPU credits on a burstable instance",
+			clusterPlatform: osconfigv1.AWSPlatformType,
+			providerSpec:    `{"instanceType":"t3.large","cpuCredits":"unlimited"}`,
+			expectedFields:  []string{"providerSpec.cpuCredits"},
+		},
+		{
+			testCase:        "with CPU credits on a fixed performance instance",
+			clusterPlatform: osconfigv1.AWSPlatformType,
+			providerSpec:    `{"instanceType":"m5.large","cpuCredits":"standard"}`,
+			expectedFields:  []string{"providerSpec.cpuCredits"},
+		},
+		{
+			testCase:        "with a core count which does not fit the instance type",
+			clusterPlatform: osconfigv1.AWSPlatformType,
+			providerSpec:    `{"instanceType":"m5.large","cpuOptions":{"coreCount":3,"threadsPerCore":2}}`,
+			expectedFields:  []string{"providerSpec.cpuOptions"},
+		},
+		{
+			testCase:        "with CPU credits and CPU options",
+			clusterPlatform: osconfigv1.AWSPlatformType,
+			providerSpec:    `{"instanceType":"t3.large","cpuCredits":"standard","cpuOptions":{"coreCount":1}}`,
+			expectedFields:  []string{"providerSpec.cpuCredits", "providerSpec.cpuOptions"},
+		},
+		{
+			testCase:        "with CPU credits changed on update",
+			clusterPlatform: osconfigv1.AWSPlatformType,
+			oldProviderSpec: `{"instanceType":"t3.large","cpuCredits":"standard","cpuOptions":{"coreCount":1}}`,
+			providerSpec:    `{"instanceType":"t3.large","cpuCredits":"unlimited","cpuOptions":{"coreCount":1}}`,
+			expectedFields:  []string{"providerSpec.cpuCredits"},
+		},
+		{
+			testCase:        "without a nic type",
+			clusterPlatform: osconfigv1.GCPPlatformType,
+			providerSpec:    `{"networkInterfaces":[{"network":"network","subnetwork":"subnetwork"}]}`,
+		},
+		{
+			testCase:        "with the VIRTIO_NET nic type",
+			clusterPlatform: osconfigv1.GCPPlatformType,
+			providerSpec:    `{"networkInterfaces":[{"network":"network","subnetwork":"subnetwork","nicType":"VIRTIO_NET"}]}`,
+			expectedFields:  []string{"providerSpec.networkInterfaces[0].nicType"},
+		},
+		{
+			testCase:        "with the GVNIC nic type on the second interface",
+			clusterPlatform: osconfigv1.GCPPlatformType,
+			providerSpec:    `{"networkInterfaces":[{"network":"network","subnetwork":"subnetwork"},{"network":"other","subnetwork":"other","nicType":"GVNIC"}]}`,
+			expectedFields:  []string{"providerSpec.networkInterfaces[1].nicType"},
+		},
+		{
+			testCase:        "with a nic type moved to another interface on update",
+			clusterPlatform: osconfigv1.GCPPlatformType,
+			oldProviderSpec: `{"networkInterfaces":[{"network":"network","subnetwork":"subnetwork","nicType":"GVNIC"},{"network":"other","subnetwork":"other"}]}`,
+			providerSpec:    `{"networkInterfaces":[{"network":"network","subnetwork":"subnetwork"},{"network":"other","subnetwork":"other","nicType":"GVNIC"}]}`,
+			expectedFields:  []string{"providerSpec.networkInterfaces[1].nicType"},
+		},
+		{
+			testCase:        "with a nic type kept on update",
+			clusterPlatform: osconfigv1.GCPPlatformType,
+			oldProviderSpec: `{"machineType":"n1-standard-4","networkInterfaces":[{"network":"network","subnetwork":"subnetwork","nicType":"GVNIC"}]}`,
+			providerSpec:    `{"machineType":"n2-standard-4","networkInterfaces":[{"network":"network","subnetwork":"subnetwork","nicType":"GVNIC"}]}`,
+		},
+		{
+			testCase:        "with only a vSphere network name",
+			clusterPlatform: osconfigv1.VSpherePlatformType,
+			providerSpec:    `{"network":{"devices":[{"networkName":"network"}]}}`,
+		},
+		{
+			testCase:        "with static vSphere addresses",
+			clusterPlatform: osconfigv1.VSpherePlatformType,
+			providerSpec:    `{"network":{"devices":[{"networkName":"network","ipAddrs":["192.168.1.10/24"]}]}}`,
+			expectedFields:  []string{"providerSpec.network.devices[0].ipAddrs"},
+		},
+		{
+			testCase:        "with a full static vSphere configuration on the second device",
+			clusterPlatform: osconfigv1.VSpherePlatformType,
+			providerSpec:    `{"network":{"devices":[{"networkName":"network"},{"networkName":"other","ipAddrs":["192.168.1.10/24"],"gateway":"192.168.1.1","nameservers":["192.168.1.2"]}]}}`,
+			expectedFields: []string{
+				"providerSpec.network.devices[1].gateway",
+				"providerSpec.network.devices[1].ipAddrs",
+				"providerSpec.network.devices[1].nameservers",
+			},
+		},
+		{
+			testCase:        "with vSphere addresses from an IP address pool",
+			clusterPlatform: osconfigv1.VSpherePlatformType,
+			providerSpec:    `{"network":{"devices":[{"networkName":"network","addressesFromPools":[{"group":"machine.openshift.io","resource":"ipaddresspools","name":"pool"}]}]}}`,
+			expectedFields:  []string{"providerSpec.network.devices[0].addressesFromPools"},
+		},
+		{
+			testCase:        "with a vSphere static address changed on update",
+			clusterPlatform: osconfigv1.VSpherePlatformType,
+			oldProviderSpec: `{"network":{"devices":[{"networkName":"network","ipAddrs":["192.168.1.10/24"],"gateway":"192.168.1.1"}]}}`,
+			providerSpec:    `{"network":{"devices":[{"networkName":"network","ipAddrs":["192.168.1.11/24"],"gateway":"192.168.1.1"}]}}`,
+			expectedFields:  []string{"providerSpec.network.devices[0].ipAddrs"},
+		},
+		{
+			testCase:        "with the providerSpec kind naming another platform than the cluster",
+			clusterPlatform: osconfigv1.GCPPlatformType,
+			providerSpec:    `{"kind":"AWSMachineProviderConfig","metadataServiceOptions":{"httpPutResponseHopLimit":2}}`,
+			expectedFields:  []string{"providerSpec.metadataServiceOptions"},
+		},
+		{
+			testCase:        "on a platform without unsupported fields",
+			clusterPlatform: osconfigv1.OpenStackPlatformType,
+			providerSpec:    hopLimit("2"),
+		},
+		{
+			testCase:        "with an unsupported field added on update",
+			clusterPlatform: osconfigv1.AWSPlatformType,
+			oldProviderSpec: `{"instanceType":"m5.large"}`,
+			providerSpec:    hopLimit("2"),
+			expectedFields:  []string{"providerSpec.metadataServiceOptions"},
+		},
+		{
+			testCase:        "with an unsupported field changed on update",
+			clusterPlatform: osconfigv1.AWSPlatformType,
+			oldProviderSpec: hopLimit("1"),
+			providerSpec:    hopLimit("2"),
+			expectedFields:  []string{"providerSpec.metadataServiceOptions"},
+		},
+		{
+			testCase:        "with an unchanged unsupported field on update",
+			clusterPlatform: osconfigv1.AWSPlatformType,
+			oldProviderSpec: hopLimit("2"),
+			providerSpec:    hopLimit("2"),
+		},
+		{
+			testCase:        "with another field changed next to an unchanged unsupported field",
+			clusterPlatform: osconfigv1.AWSPlatformType,
+			oldProviderSpec: hopLimit("2"),
+			providerSpec:    `{"instanceType":"m5.xlarge","metadataServiceOptions":{"httpPutResponseHopLimit":2}}`,
+		},
+		{
+			testCase:        "with an unsupported field removed on update",
+			clusterPlatform: osconfigv1.AWSPlatformType,
+			oldProviderSpec: hopLimit("2"),
+			providerSpec:    `{"instanceType":"m5.large"}`,
+		},
+		{
+			testCase:        "on a deleting machine",
+			clusterPlatform: osconfigv1.AWSPlatformType,
+			oldProviderSpec: hopLimit("2"),
+			providerSpec:    hopLimit("2"),
+			deleting:        true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			g := NewWithT(t)
+
+			m := &machinev1.Machine{
+				Spec: machinev1.MachineSpec{
+					ProviderSpec: machinev1.ProviderSpec{Value: &kruntime.RawExtension{Raw: []byte(tc.providerSpec)}},
+				},
+			}
+			if tc.deleting {
+				now := metav1.Now()
+				m.DeletionTimestamp = &now
+			}
+			var oldM *machinev1.Machine
+			if tc.oldProviderSpec != "" {
+				oldM = m.DeepCopy()
+				oldM.Spec.ProviderSpec.Value = &kruntime.RawExtension{Raw: []byte(tc.oldProviderSpec)}
+			}
+
+			var fields []string
+			for _, err := range validateUnsupportedProviderSpecFields(m, oldM, tc.clusterPlatform) {
+				fieldErr, ok := err.(*field.Error)
+				g.Expect(ok).To(BeTrue(), "expected a field error, got %v", err)
+				g.Expect(fieldErr.Type).To(Equal(field.ErrorTypeForbidden))
+				fields = append(fields, fieldErr.Field)
+			}
+			g.Expect(fields).To(Equal(tc.expectedFields))
+		})
+	}
+}
+
+func TestDenyUnsupportedProviderSpecFields(t *testing.T) {
+	testCases := []struct {
+		testCase         string
+		providerSpec     string
+		expectedDefaults bool
+		expectedError    string
+	}{
+		{
+			testCase:         "without unsupported fields",
+			providerSpec:     `{"instanceType":"m5.large"}`,
+			expectedDefaults: true,
+		},
+		{
+			testCase:      "with an unsupported field",
+			providerSpec:  `{"instanceType":"m5.large","metadataServiceOptions":{"httpPutResponseHopLimit":2}}`,
+			expectedError: "providerSpec.metadataServiceOptions: Forbidden: instance metadata options, such as the metadata hop limit, are not supported by this version of the AWS providerSpec and would be ignored",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			g := NewWithT(t)
+
+			defaulted := false
+			defaulter := denyUnsupportedProviderSpecFields(osconfigv1.AWSPlatformType, func(m *machinev1.Machine, config *admissionConfig) (bool, []string, utilerrors.Aggregate) {
+				defaulted = true
+				return true, []string{}, nil
+			})
+
+			m := &machinev1.Machine{
+				Spec: machinev1.MachineSpec{
+					ProviderSpec: machinev1.ProviderSpec{Value: &kruntime.RawExtension{Raw: []byte(tc.providerSpec)}},
+				},
+			}
+			ok, _, errs := defaulter(m, &admissionConfig{})
+			g.Expect(defaulted).To(Equal(tc.expectedDefaults))
+			if tc.expectedError != "" {
+				g.Expect(ok).To(BeFalse())
+				g.Expect(errs).To(MatchError(tc.expectedError))
+				return
+			}
+			g.Expect(ok).To(BeTrue())
+			g.Expect(errs).To(BeNil())
+		})
+	}
+}
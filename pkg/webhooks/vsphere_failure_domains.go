@@ -0,0 +1,171 @@
+package webhooks
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	kruntime "k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// machineRegionLabel and machineZoneLabel place a machine in a vSphere failure domain.
+	machineRegionLabel = "machine.openshift.io/region"
+	machineZoneLabel   = "machine.openshift.io/zone"
+)
+
+// vSphereFailureDomain is a failure domain from the vSphere platform spec of the cluster Infrastructure.
+// The vendored config API predates vSphere failure domains, so they are read from the
+// unstructured Infrastructure.
+type vSphereFailureDomain struct {
+	Name     string                       `json:"name"`
+	Region   string                       `json:"region"`
+	Zone     string                       `json:"zone"`
+	Server   string                       `json:"server"`
+	Topology vSphereFailureDomainTopology `json:"topology"`
+}
+
+// vSphereFailureDomainTopology is the vCenter topology of a vSphere failure domain.
+type vSphereFailureDomainTopology struct {
+	Datacenter     string   `json:"datacenter"`
+	ComputeCluster string   `json:"computeCluster"`
+	Networks       []string `json:"networks,omitempty"`
+	Datastore      string   `json:"datastore"`
+	ResourcePool   string   `json:"resourcePool,omitempty"`
+	Folder         string   `json:"folder,omitempty"`
+}
+
+// vSphereFailureDomainsFromInfrastructure returns the failure domains defined in
+// spec.platformSpec.vsphere.failureDomains of the Infrastructure.
+func vSphereFailureDomainsFromInfrastructure(infra *unstructured.Unstructured) ([]vSphereFailureDomain, error) {
+	items, found, err := unstructured.NestedSlice(infra.Object, "spec", "platformSpec", "vsphere", "failureDomains")
+	if err != nil || !found {
+		return nil, err
+	}
+
+	failureDomains := make([]vSphereFailureDomain, 0, len(items))
+	for i, item := range items {
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("spec.platformSpec.vsphere.failureDomains[%d]: expected an object, got %T", i, item)
+		}
+		failureDomain := vSphereFailureDomain{}
+		if err := kruntime.DefaultUnstructuredConverter.FromUnstructured(object, &failureDomain); err != nil {
+			return nil, fmt.Errorf("spec.platformSpec.vsphere.failureDomains[%d]: %w", i, err)
+		}
+		failureDomains = append(failureDomains, failureDomain)
+	}
+	return failureDomains, nil
+}
+
+// vSphereFailureDomainForMachine returns the failure domain the machine is placed in by its region and
+// zone labels. A machine without these labels is placed in the failure domain of a cluster which only has one.
+func vSphereFailureDomainForMachine(m *machinev1.Machine, failureDomains []vSphereFailureDomain) (vSphereFailureDomain, bool) {
+	region, zone := m.Labels[machineRegionLabel], m.Labels[machineZoneLabel]
+	if region == "" && zone == "" {
+		if len(failureDomains) == 1 {
+			return failureDomains[0], true
+		}
+		return vSphereFailureDomain{}, false
+	}
+
+	for _, failureDomain := range failureDomains {
+		if failureDomain.Region == region && failureDomain.Zone == zone {
+			return failureDomain, true
+		}
+	}
+	return vSphereFailureDomain{}, false
+}
+
+// defaultVSphereWorkspace sets the unset fields of the workspace from the topology of the failure domain.
+// A workspace on another vCenter than the failure domain is left as is. As the installer does, the resource
+// pool defaults to the root resource pool of the compute cluster and the folder to the folder named after
+// the cluster ID.
+func defaultVSphereWorkspace(workspace *machinev1.Workspace, failureDomain vSphereFailureDomain, clusterID string) *machinev1.Workspace {
+	if workspace == nil {
+		workspace = &machinev1.Workspace{}
+	}
+	if workspace.Server != "" && workspace.Server != failureDomain.Server {
+		return workspace
+	}
+
+	topology := failureDomain.Topology
+	if workspace.Server == "" {
+		workspace.Server = failureDomain.Server
+	}
+	if workspace.Datacenter == "" {
+		workspace.Datacenter = topology.Datacenter
+	}
+	if workspace.Datastore == "" {
+		workspace.Datastore = topology.Datastore
+	}
+	if workspace.ResourcePool == "" {
+		workspace.ResourcePool = topology.ResourcePool
+		if workspace.ResourcePool == "" && topology.ComputeCluster != "" {
+			workspace.ResourcePool = path.Join(topology.ComputeCluster, "Resources")
+		}
+	}
+	if workspace.Folder == "" {
+		workspace.Folder = topology.Folder
+		if workspace.Folder == "" && workspace.Datacenter != "" && clusterID != "" {
+			workspace.Folder = path.Join("/", path.Base(workspace.Datacenter), "vm", clusterID)
+		}
+	}
+	return workspace
+}
+
+// validateVSphereFailureDomain warns when a machine on a zonal cluster would not be placed in any
+// failure domain. Machines without region and zone labels, whose workspace does not match the topology
+// of a failure domain, are zone-less, which skews the topology of persistent volumes.
+func validateVSphereFailureDomain(m *machinev1.Machine, providerSpec *machinev1.VSphereMachineProviderSpec, failureDomains []vSphereFailureDomain) []string {
+	// A single failure domain is the whole cluster, there is nothing to choose from.
+	if len(failureDomains) <= 1 {
+		return nil
+	}
+
+	if m.Labels[machineRegionLabel] != "" && m.Labels[machineZoneLabel] != "" {
+		return nil
+	}
+
+	for _, failureDomain := range failureDomains {
+		if vSphereWorkspaceInFailureDomain(providerSpec.Workspace, failureDomain) {
+			return nil
+		}
+	}
+
+	names := make([]string, 0, len(failureDomains))
+	for _, failureDomain := range failureDomains {
+		names = append(names, failureDomain.Name)
+	}
+	return []string{fmt.Sprintf("providerSpec.workspace: the machine has no %s and %s labels and its workspace does not match any of the failure domains defined in the cluster Infrastructure (%s): the machine will not be placed in a zone, create it from one of the zonal MachineSets instead", machineRegionLabel, machineZoneLabel, strings.Join(names, ", "))}
+}
+
+// vSphereWorkspaceInFailureDomain returns true if the workspace places the machine in the failure domain.
+// The workspace datacenter, datastore and resource pool may be names or inventory paths.
+func vSphereWorkspaceInFailureDomain(workspace *machinev1.Workspace, failureDomain vSphereFailureDomain) bool {
+	if workspace == nil {
+		return false
+	}
+
+	topology := failureDomain.Topology
+	if workspace.Server != failureDomain.Server || !vSphereInventoryNameMatches(workspace.Datacenter, topology.Datacenter) {
+		return false
+	}
+	if workspace.Datastore != "" && topology.Datastore != "" && !vSphereInventoryNameMatches(workspace.Datastore, topology.Datastore) {
+		return false
+	}
+	if workspace.ResourcePool != "" && topology.ComputeCluster != "" && !strings.HasPrefix(workspace.ResourcePool, topology.ComputeCluster) {
+		return false
+	}
+	return true
+}
+
+// vSphereInventoryNameMatches compares a name or inventory path with an inventory path.
+func vSphereInventoryNameMatches(nameOrPath, inventoryPath string) bool {
+	if strings.HasPrefix(nameOrPath, "/") {
+		return path.Clean(nameOrPath) == path.Clean(inventoryPath)
+	}
+	return nameOrPath == path.Base(inventoryPath)
+}
@@ -0,0 +1,229 @@
+package webhooks
+
+import (
+	"reflect"
+	"testing"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var testVSphereFailureDomains = []vSphereFailureDomain{
+	{
+		Name:   "us-east-1a",
+		Region: "us-east",
+		Zone:   "us-east-1a",
+		Server: "vcenter.example.com",
+		Topology: vSphereFailureDomainTopology{
+			Datacenter:     "dc1",
+			ComputeCluster: "/dc1/host/cluster1",
+			Networks:       []string{"network1"},
+			Datastore:      "/dc1/datastore/datastore1",
+		},
+	},
+	{
+		Name:   "us-east-1b",
+		Region: "us-east",
+		Zone:   "us-east-1b",
+		Server: "vcenter.example.com",
+		Topology: vSphereFailureDomainTopology{
+			Datacenter:     "dc2",
+			ComputeCluster: "/dc2/host/cluster2",
+			Networks:       []string{"network2"},
+			Datastore:      "/dc2/datastore/datastore2",
+		},
+	},
+}
+
+func TestVSphereFailureDomainsFromInfrastructure(t *testing.T) {
+	infra := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"platformSpec": map[string]interface{}{
+				"type": "VSphere",
+				"vsphere": map[string]interface{}{
+					"failureDomains": []interface{}{
+						map[string]interface{}{
+							"name":   "us-east-1a",
+							"region": "us-east",
+							"zone":   "us-east-1a",
+							"server": "vcenter.example.com",
+							"topology": map[string]interface{}{
+								"datacenter":     "dc1",
+								"computeCluster": "/dc1/host/cluster1",
+								"networks":       []interface{}{"network1"},
+								"datastore":      "/dc1/datastore/datastore1",
+							},
+						},
+					},
+				},
+			},
+		},
+	}}
+
+	got, err := vSphereFailureDomainsFromInfrastructure(infra)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := testVSphereFailureDomains[:1]; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %+v, got: %+v", expected, got)
+	}
+
+	got, err = vSphereFailureDomainsFromInfrastructure(&unstructured.Unstructured{Object: map[string]interface{}{}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != nil {
+		t.Errorf("expected no failure domains, got: %+v", got)
+	}
+}
+
+func TestValidateVSphereFailureDomain(t *testing.T) {
+	matchingWorkspace := &machinev1.Workspace{
+		Server:       "vcenter.example.com",
+		Datacenter:   "dc2",
+		Datastore:    "datastore2",
+		ResourcePool: "/dc2/host/cluster2/Resources",
+	}
+	otherWorkspace := &machinev1.Workspace{
+		Server:       "vcenter.example.com",
+		Datacenter:   "dc3",
+		Datastore:    "datastore3",
+		ResourcePool: "/dc3/host/cluster3/Resources",
+	}
+
+	testCases := []struct {
+		testCase         string
+		labels           map[string]string
+		workspace        *machinev1.Workspace
+		failureDomains   []vSphereFailureDomain
+		expectedWarnings []string
+	}{
+		{
+			testCase:         "with a workspace matching a failure domain",
+			workspace:        matchingWorkspace,
+			failureDomains:   testVSphereFailureDomains,
+			expectedWarnings: nil,
+		},
+		{
+			testCase: "with topology labels",
+			labels: map[string]string{
+				machineRegionLabel: "us-east",
+				machineZoneLabel:   "us-east-1a",
+			},
+			workspace:        otherWorkspace,
+			failureDomains:   testVSphereFailureDomains,
+			expectedWarnings: nil,
+		},
+		{
+			testCase:       "without topology labels and a workspace matching no failure domain",
+			workspace:      otherWorkspace,
+			failureDomains: testVSphereFailureDomains,
+			expectedWarnings: []string{
+				"providerSpec.workspace: the machine has no machine.openshift.io/region and machine.openshift.io/zone labels and its workspace does not match any of the failure domains defined in the cluster Infrastructure (us-east-1a, us-east-1b): the machine will not be placed in a zone, create it from one of the zonal MachineSets instead",
+			},
+		},
+		{
+			testCase:       "without topology labels and without a workspace",
+			workspace:      nil,
+			failureDomains: testVSphereFailureDomains,
+			expectedWarnings: []string{
+				"providerSpec.workspace: the machine has no machine.openshift.io/region and machine.openshift.io/zone labels and its workspace does not match any of the failure domains defined in the cluster Infrastructure (us-east-1a, us-east-1b): the machine will not be placed in a zone, create it from one of the zonal MachineSets instead",
+			},
+		},
+		{
+			testCase:         "with a single failure domain",
+			workspace:        otherWorkspace,
+			failureDomains:   testVSphereFailureDomains[:1],
+			expectedWarnings: nil,
+		},
+		{
+			testCase:         "without failure domains",
+			workspace:        otherWorkspace,
+			failureDomains:   nil,
+			expectedWarnings: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			m := &machinev1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Labels: tc.labels},
+			}
+			providerSpec := &machinev1.VSphereMachineProviderSpec{Workspace: tc.workspace}
+
+			warnings := validateVSphereFailureDomain(m, providerSpec, tc.failureDomains)
+			if !reflect.DeepEqual(warnings, tc.expectedWarnings) {
+				t.Errorf("expected: %q, got: %q", tc.expectedWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestDefaultVSphereWorkspace(t *testing.T) {
+	testCases := []struct {
+		testCase          string
+		workspace         *machinev1.Workspace
+		failureDomain     vSphereFailureDomain
+		expectedWorkspace *machinev1.Workspace
+	}{
+		{
+			testCase:      "without a workspace",
+			failureDomain: testVSphereFailureDomains[0],
+			expectedWorkspace: &machinev1.Workspace{
+				Server:       "vcenter.example.com",
+				Datacenter:   "dc1",
+				Datastore:    "/dc1/datastore/datastore1",
+				ResourcePool: "/dc1/host/cluster1/Resources",
+				Folder:       "/dc1/vm/clusterID",
+			},
+		},
+		{
+			testCase: "with a resource pool and folder in the topology",
+			failureDomain: vSphereFailureDomain{
+				Server: "vcenter.example.com",
+				Topology: vSphereFailureDomainTopology{
+					Datacenter:     "/dc1",
+					ComputeCluster: "/dc1/host/cluster1",
+					Datastore:      "/dc1/datastore/datastore1",
+					ResourcePool:   "/dc1/host/cluster1/Resources/pool",
+					Folder:         "/dc1/vm/folder",
+				},
+			},
+			expectedWorkspace: &machinev1.Workspace{
+				Server:       "vcenter.example.com",
+				Datacenter:   "/dc1",
+				Datastore:    "/dc1/datastore/datastore1",
+				ResourcePool: "/dc1/host/cluster1/Resources/pool",
+				Folder:       "/dc1/vm/folder",
+			},
+		},
+		{
+			testCase:      "with a partial workspace",
+			workspace:     &machinev1.Workspace{Datastore: "datastore3", Folder: "/dc1/vm/custom"},
+			failureDomain: testVSphereFailureDomains[0],
+			expectedWorkspace: &machinev1.Workspace{
+				Server:       "vcenter.example.com",
+				Datacenter:   "dc1",
+				Datastore:    "datastore3",
+				ResourcePool: "/dc1/host/cluster1/Resources",
+				Folder:       "/dc1/vm/custom",
+			},
+		},
+		{
+			testCase:          "with a workspace on another vCenter",
+			workspace:         &machinev1.Workspace{Server: "other.example.com"},
+			failureDomain:     testVSphereFailureDomains[0],
+			expectedWorkspace: &machinev1.Workspace{Server: "other.example.com"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			workspace := defaultVSphereWorkspace(tc.workspace, tc.failureDomain, "clusterID")
+			if !reflect.DeepEqual(workspace, tc.expectedWorkspace) {
+				t.Errorf("expected: %+v, got: %+v", tc.expectedWorkspace, workspace)
+			}
+		})
+	}
+}